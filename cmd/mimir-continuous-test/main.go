@@ -6,6 +6,8 @@ import (
 	"context"
 	"flag"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/go-kit/log/level"
 	"github.com/prometheus/client_golang/prometheus"
@@ -25,6 +27,9 @@ type Config struct {
 	Client              continuoustest.ClientConfig
 	Manager             continuoustest.ManagerConfig
 	WriteReadSeriesTest continuoustest.WriteReadSeriesTestConfig
+
+	LabelCardinalityTest continuoustest.LabelCardinalityTestConfig
+	SeriesMetadataTest   continuoustest.SeriesMetadataTestConfig
 }
 
 func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
@@ -33,6 +38,8 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	cfg.Client.RegisterFlags(f)
 	cfg.Manager.RegisterFlags(f)
 	cfg.WriteReadSeriesTest.RegisterFlags(f)
+	cfg.LabelCardinalityTest.RegisterFlags(f)
+	cfg.SeriesMetadataTest.RegisterFlags(f)
 }
 
 func main() {
@@ -54,26 +61,131 @@ func main() {
 
 	logger := util_log.Logger
 
-	// Run the instrumentation server.
+	m := continuoustest.NewManager(cfg.Manager, logger)
+
+	// Run the instrumentation server, which also exposes the readiness and health probes.
 	registry := prometheus.NewRegistry()
 	registry.MustRegister(collectors.NewGoCollector())
 
 	i := instrumentation.NewMetricsServer(cfg.ServerMetricsPort, registry)
+	i.Handle("/ready", m.ReadyHandler())
+	i.Handle("/healthy", m.HealthyHandler())
 	if err := i.Start(); err != nil {
 		level.Error(logger).Log("msg", "Unable to start instrumentation server", "err", err.Error())
 		os.Exit(1)
 	}
 
-	// Init the client used to write/read to/from Mimir.
-	client, err := continuoustest.NewClient(cfg.Client, logger)
+	// Init the clients used to write/read to/from Mimir. There's a client for each configured pair
+	// of write and read endpoints, or for each configured tenant.
+	clients, err := continuoustest.NewClients(cfg.Client, logger)
 	if err != nil {
 		level.Error(logger).Log("msg", "Failed to initialize client", "err", err.Error())
 		os.Exit(1)
 	}
 
+	// Init the tests. When writing to multiple endpoints or tenants, each one is tested independently.
+	var writeReadSeriesTests []*continuoustest.WriteReadSeriesTest
+	for i, client := range clients {
+		var writeReadSeriesTest *continuoustest.WriteReadSeriesTest
+		switch {
+		case len(cfg.Client.TenantIDs) > 0:
+			writeReadSeriesTest, err = continuoustest.NewWriteReadSeriesTestForTenant(cfg.WriteReadSeriesTest, cfg.Client.TenantIDs[i], client, logger, registry)
+		case len(clients) == 1:
+			writeReadSeriesTest, err = continuoustest.NewWriteReadSeriesTest(cfg.WriteReadSeriesTest, client, logger, registry)
+		default:
+			writeReadSeriesTest, err = continuoustest.NewWriteReadSeriesTestForEndpoint(cfg.WriteReadSeriesTest, cfg.Client.WriteEndpoints[i], client, logger, registry)
+		}
+		if err != nil {
+			level.Error(logger).Log("msg", "Failed to initialize write-read-series test", "err", err.Error())
+			os.Exit(1)
+		}
+		m.AddTest(writeReadSeriesTest)
+		writeReadSeriesTests = append(writeReadSeriesTests, writeReadSeriesTest)
+
+		if cfg.LabelCardinalityTest.Enabled {
+			var labelCardinalityTest *continuoustest.LabelCardinalityTest
+			switch {
+			case len(cfg.Client.TenantIDs) > 0:
+				labelCardinalityTest, err = continuoustest.NewLabelCardinalityTestForTenant(cfg.LabelCardinalityTest, cfg.Client.TenantIDs[i], client, logger, registry)
+			case len(clients) == 1:
+				labelCardinalityTest, err = continuoustest.NewLabelCardinalityTest(cfg.LabelCardinalityTest, client, logger, registry)
+			default:
+				labelCardinalityTest, err = continuoustest.NewLabelCardinalityTestForEndpoint(cfg.LabelCardinalityTest, cfg.Client.WriteEndpoints[i], client, logger, registry)
+			}
+			if err != nil {
+				level.Error(logger).Log("msg", "Failed to initialize label cardinality test", "err", err.Error())
+				os.Exit(1)
+			}
+			m.AddTest(labelCardinalityTest)
+		}
+
+		if cfg.SeriesMetadataTest.Enabled {
+			var seriesMetadataTest *continuoustest.SeriesMetadataTest
+			switch {
+			case len(cfg.Client.TenantIDs) > 0:
+				seriesMetadataTest, err = continuoustest.NewSeriesMetadataTestForTenant(cfg.SeriesMetadataTest, cfg.Client.TenantIDs[i], client, logger, registry)
+			case len(clients) == 1:
+				seriesMetadataTest, err = continuoustest.NewSeriesMetadataTest(cfg.SeriesMetadataTest, client, logger, registry)
+			default:
+				seriesMetadataTest, err = continuoustest.NewSeriesMetadataTestForEndpoint(cfg.SeriesMetadataTest, cfg.Client.WriteEndpoints[i], client, logger, registry)
+			}
+			if err != nil {
+				level.Error(logger).Log("msg", "Failed to initialize series metadata test", "err", err.Error())
+				os.Exit(1)
+			}
+			m.AddTest(seriesMetadataTest)
+		}
+	}
+
+	// Init the test against the secondary backend, if configured. The same series are written to it and its query
+	// results are checked independently, in order to validate Mimir against a reference.
+	secondaryClient, err := continuoustest.NewSecondaryClient(cfg.Client, logger)
+	if err != nil {
+		level.Error(logger).Log("msg", "Failed to initialize secondary client", "err", err.Error())
+		os.Exit(1)
+	}
+	if cfg.WriteReadSeriesTest.ReferenceCompareEnabled {
+		// The Mimir query results are compared with the secondary backend ones, so the series are written to it
+		// by the Mimir test, instead of testing the secondary backend independently.
+		if secondaryClient == nil || len(writeReadSeriesTests) != 1 {
+			level.Error(logger).Log("msg", "The reference compare requires the secondary backend and a single write endpoint and tenant to be configured")
+			os.Exit(1)
+		}
+		writeReadSeriesTests[0].SetReferenceClient(secondaryClient)
+	} else if secondaryClient != nil {
+		// The tests running for multiple tenants label their metrics with the tenant ID, so the secondary backend
+		// test must be labelled too, otherwise its metrics can't be registered to the same registry.
+		tenantID := ""
+		if len(cfg.Client.TenantIDs) > 0 {
+			tenantID = cfg.Client.TenantID
+		}
+
+		secondaryTest, err := continuoustest.NewWriteReadSeriesTestForSecondaryBackend(cfg.WriteReadSeriesTest, tenantID, secondaryClient, logger, registry)
+		if err != nil {
+			level.Error(logger).Log("msg", "Failed to initialize write-read-series test for the secondary backend", "err", err.Error())
+			os.Exit(1)
+		}
+		m.AddTest(secondaryTest)
+		writeReadSeriesTests = append(writeReadSeriesTests, secondaryTest)
+	}
+
+	// Reload the number of series written by the tests on SIGHUP, if configured.
+	if cfg.WriteReadSeriesTest.NumSeriesFile != "" {
+		reload := make(chan os.Signal, 1)
+		signal.Notify(reload, syscall.SIGHUP)
+
+		go func() {
+			for range reload {
+				for _, test := range writeReadSeriesTests {
+					if err := test.ReloadNumSeries(); err != nil {
+						level.Error(logger).Log("msg", "Failed to reload the number of series", "err", err.Error())
+					}
+				}
+			}
+		}()
+	}
+
 	// Run continuous testing.
-	m := continuoustest.NewManager(cfg.Manager, logger)
-	m.AddTest(continuoustest.NewWriteReadSeriesTest(cfg.WriteReadSeriesTest, client, logger, registry))
 	if err := m.Run(context.Background()); err != nil {
 		level.Error(logger).Log("msg", "Failed to run continuous test", "err", err.Error())
 		os.Exit(1)
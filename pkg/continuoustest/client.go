@@ -4,29 +4,93 @@ package continuoustest
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-kit/log"
 	"github.com/gogo/protobuf/proto"
 	"github.com/golang/snappy"
+	"github.com/grafana/dskit/crypto/tls"
 	"github.com/grafana/dskit/flagext"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/api"
 	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/prompb"
+	"github.com/prometheus/prometheus/storage/remote"
+	"github.com/weaveworks/common/httpgrpc"
+	"github.com/weaveworks/common/middleware"
+	"github.com/weaveworks/common/user"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 
+	"github.com/grafana/mimir/pkg/distributor/distributorpb"
+	"github.com/grafana/mimir/pkg/mimirpb"
+	"github.com/grafana/mimir/pkg/util"
+	"github.com/grafana/mimir/pkg/util/globalerror"
 	"github.com/grafana/mimir/pkg/util/instrumentation"
 	util_math "github.com/grafana/mimir/pkg/util/math"
+	"github.com/grafana/mimir/pkg/util/push"
 )
 
 const (
 	maxErrMsgLen = 256
+
+	// The path of the remote write API on Mimir.
+	mimirRemoteWritePath = "/api/v1/push"
+)
+
+// The supported transports through which series are written through the remote write API.
+const (
+	writeTransportHTTP = "http"
+	writeTransportGRPC = "grpc"
+)
+
+var writeTransports = []string{writeTransportHTTP, writeTransportGRPC}
+
+// The supported versions of the remote write protocol.
+const (
+	remoteWriteVersion1 = "1.0"
+	remoteWriteVersion2 = "2.0"
+)
+
+var remoteWriteVersions = []string{remoteWriteVersion1, remoteWriteVersion2}
+
+// The supported compressions of the write requests body.
+const (
+	writeCompressionNone   = "none"
+	writeCompressionSnappy = "snappy"
+	writeCompressionGzip   = "gzip"
+)
+
+var (
+	remoteWriteCompressions = []string{writeCompressionSnappy, writeCompressionGzip}
+	otlpWriteCompressions   = []string{writeCompressionNone, writeCompressionGzip}
+)
+
+// Reasons used to classify failed queries.
+const (
+	queryErrorReasonTimeout       = "timeout"
+	queryErrorReasonLimitExceeded = "limit_exceeded"
+	queryErrorReasonServerError   = "5xx"
+	queryErrorReasonNetwork       = "network"
+	queryErrorReasonParse         = "parse"
+	queryErrorReasonOther         = "other"
 )
 
 // MimirClient is the interface implemented by a client used to interact with Mimir.
@@ -35,64 +99,306 @@ type MimirClient interface {
 	// an error. The error is always returned if request was not successful (eg. received a 4xx or 5xx error).
 	WriteSeries(ctx context.Context, series []prompb.TimeSeries) (statusCode int, err error)
 
+	// WriteSeriesOTLP is like WriteSeries, but writes the input series through the OTLP ingestion API.
+	WriteSeriesOTLP(ctx context.Context, series []prompb.TimeSeries) (statusCode int, err error)
+
+	// WriteMetadata writes the input metric metadata through the remote write API.
+	WriteMetadata(ctx context.Context, metadata []prompb.MetricMetadata) (statusCode int, err error)
+
 	// QueryRange performs a range query.
 	QueryRange(ctx context.Context, query string, start, end time.Time, step time.Duration, options ...RequestOption) (model.Matrix, error)
 
 	// Query performs an instant query.
 	Query(ctx context.Context, query string, ts time.Time, options ...RequestOption) (model.Vector, error)
+
+	// QueryRelativeTime performs an instant query, whose evaluation time is the input relative time string
+	// (for example "now-5m"), parsed by the server.
+	QueryRelativeTime(ctx context.Context, query string, relativeTime string, options ...RequestOption) (model.Vector, error)
+
+	// ReadSeries reads the raw samples of the series matching the input matchers between start and end
+	// (both included), through the remote read API.
+	ReadSeries(ctx context.Context, matchers []*labels.Matcher, start, end time.Time) (model.Matrix, error)
+
+	// QueryExemplars queries the exemplars of the series matching the input query between start and end.
+	QueryExemplars(ctx context.Context, query string, start, end time.Time) ([]v1.ExemplarQueryResult, error)
+
+	// LabelValues returns the values of the input label name, for the series matching any of the input series
+	// selectors between start and end. All series are considered if no series selector is provided.
+	LabelValues(ctx context.Context, name string, matchers []string, start, end time.Time) (model.LabelValues, error)
+
+	// Series returns the label sets of the series matching any of the input series selectors between start and end.
+	Series(ctx context.Context, matchers []string, start, end time.Time) ([]model.LabelSet, error)
+
+	// LabelNamesCardinality returns the number of values of each label name, for the ingesters' in-memory series
+	// matching the input series selector, through the cardinality API. All series are considered if the selector
+	// is empty. At most limit label names are returned.
+	LabelNamesCardinality(ctx context.Context, selector string, limit int) (*LabelNamesCardinalityResponse, error)
+
+	// LabelValuesCardinality returns the number of series of each value of the input label names, for the
+	// ingesters' in-memory series matching the input series selector, through the cardinality API. All series
+	// are considered if the selector is empty. At most limit values are returned for each label name.
+	LabelValuesCardinality(ctx context.Context, labelNames []string, selector string, limit int) (*LabelValuesCardinalityResponse, error)
+
+	// ActiveSeries returns the label sets of the ingesters' active series matching all the input label matchers
+	// (for example `series_id="1"`), through the active series cardinality API.
+	ActiveSeries(ctx context.Context, matchers []string) ([]model.LabelSet, error)
+
+	// Flush triggers a flush of the ingesters' in-memory series to blocks, and waits until it's completed.
+	Flush(ctx context.Context) error
+
+	// BuildInfo returns the build information of the target, through the build info API.
+	BuildInfo(ctx context.Context) (v1.BuildinfoResult, error)
+
+	// Metadata returns the metadata of the input metric, through the metadata API.
+	Metadata(ctx context.Context, metric string) ([]v1.Metadata, error)
+}
+
+// LabelNamesCardinalityResponse is the response of the label names cardinality API.
+type LabelNamesCardinalityResponse struct {
+	LabelValuesCountTotal int                         `json:"label_values_count_total"`
+	LabelNamesCount       int                         `json:"label_names_count"`
+	Cardinality           []LabelNamesCardinalityItem `json:"cardinality"`
+}
+
+type LabelNamesCardinalityItem struct {
+	LabelName        string `json:"label_name"`
+	LabelValuesCount int    `json:"label_values_count"`
+}
+
+// LabelValuesCardinalityResponse is the response of the label values cardinality API.
+type LabelValuesCardinalityResponse struct {
+	SeriesCountTotal uint64                        `json:"series_count_total"`
+	Labels           []LabelValuesCardinalityLabel `json:"labels"`
+}
+
+type LabelValuesCardinalityLabel struct {
+	LabelName        string                       `json:"label_name"`
+	LabelValuesCount uint64                       `json:"label_values_count"`
+	SeriesCount      uint64                       `json:"series_count"`
+	Cardinality      []LabelValuesCardinalityItem `json:"cardinality"`
+}
+
+type LabelValuesCardinalityItem struct {
+	LabelValue  string `json:"label_value"`
+	SeriesCount uint64 `json:"series_count"`
+}
+
+// activeSeriesResponse is the response of the active series cardinality API.
+type activeSeriesResponse struct {
+	Data []model.LabelSet `json:"data"`
 }
 
 type ClientConfig struct {
 	TenantID          string
+	TenantIDs         flagext.StringSliceCSV
 	BasicAuthUser     string
 	BasicAuthPassword string
 	BearerToken       string
 
+	// TLS and the connections reuse settings configure the HTTP transport used to write and query. They don't
+	// apply to the gRPC write transport.
+	TLS                 tls.ClientConfig
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+	HTTP2Enabled        bool
+
 	WriteBaseEndpoint flagext.URLValue
 	WriteBatchSize    int
 	WriteTimeout      time.Duration
+	WriteTransport    string
+	GRPCWriteEndpoint string
+
+	RemoteWriteVersion     string
+	RemoteWriteCompression string
+	OTLPWriteCompression   string
 
 	ReadBaseEndpoint flagext.URLValue
 	ReadTimeout      time.Duration
+
+	WriteEndpoints flagext.StringSliceCSV
+	ReadEndpoints  flagext.StringSliceCSV
+
+	FlushTimeout time.Duration
+
+	SecondaryWriteEndpoint   flagext.URLValue
+	SecondaryReadEndpoint    flagext.URLValue
+	SecondaryRemoteWritePath string
+
+	ParquetReadEndpoint flagext.URLValue
+	ParquetReadHeaders  flagext.StringSliceCSV
 }
 
 func (cfg *ClientConfig) RegisterFlags(f *flag.FlagSet) {
 	f.StringVar(&cfg.TenantID, "tests.tenant-id", "anonymous", "The tenant ID to use to write and read metrics in tests. (mutually exclusive with basic-auth or bearer-token flags)")
+	f.Var(&cfg.TenantIDs, "tests.tenant-ids", "Comma-separated list of tenant IDs to use to write and read metrics in tests. When set, the tests run independently for each tenant and -tests.tenant-id is ignored. (mutually exclusive with basic-auth or bearer-token flags, and with -tests.write-endpoints)")
 	f.StringVar(&cfg.BasicAuthUser, "tests.basic-auth-user", "", "The username to use for HTTP bearer authentication. (mutually exclusive with tenant-id or bearer-token flags)")
 	f.StringVar(&cfg.BasicAuthPassword, "tests.basic-auth-password", "", "The password to use for HTTP bearer authentication. (mutually exclusive with tenant-id or bearer-token flags)")
 	f.StringVar(&cfg.BearerToken, "tests.bearer-token", "", "The bearer token to use for HTTP bearer authentication. (mutually exclusive with tenant-id flag or basic-auth flags)")
+	cfg.TLS.RegisterFlagsWithPrefix("tests", f)
+	f.IntVar(&cfg.MaxIdleConns, "tests.max-idle-connections", 100, "Maximum number of idle (keep-alive) HTTP connections across all hosts. 0 means no limit.")
+	f.IntVar(&cfg.MaxIdleConnsPerHost, "tests.max-idle-connections-per-host", 100, "Maximum number of idle (keep-alive) HTTP connections to keep per-host. The default allows reusing the connections of concurrent queries and writes, instead of opening new ones at each run. If 0, a built-in default value of 2 is used.")
+	f.DurationVar(&cfg.IdleConnTimeout, "tests.idle-conn-timeout", 90*time.Second, "The time an idle HTTP connection will remain idle before closing. 0 means no limit.")
+	f.BoolVar(&cfg.HTTP2Enabled, "tests.http2-enabled", true, "Attempt to use HTTP/2 when connecting to HTTPS endpoints. If disabled, HTTP/1.1 is used.")
 
 	f.Var(&cfg.WriteBaseEndpoint, "tests.write-endpoint", "The base endpoint on the write path. The URL should have no trailing slash. The specific API path is appended by the tool to the URL, for example /api/v1/push for the remote write API endpoint, so the configured URL must not include it.")
 	f.IntVar(&cfg.WriteBatchSize, "tests.write-batch-size", 1000, "The maximum number of series to write in a single request.")
 	f.DurationVar(&cfg.WriteTimeout, "tests.write-timeout", 5*time.Second, "The timeout for a single write request.")
+	f.StringVar(&cfg.WriteTransport, "tests.write-transport", writeTransportHTTP, fmt.Sprintf("The transport through which series are written through the remote write API. When set to %s, series are pushed to the distributor gRPC endpoint configured in -tests.grpc-write-endpoint, and the gRPC status codes are translated into the equivalent HTTP status codes. The OTLP write path and the flush are not affected. Supported values: %s.", writeTransportGRPC, strings.Join(writeTransports, ", ")))
+	f.StringVar(&cfg.GRPCWriteEndpoint, "tests.grpc-write-endpoint", "", "The host:port address of the distributor gRPC endpoint series are pushed to when the write transport is grpc.")
+	f.StringVar(&cfg.RemoteWriteVersion, "tests.remote-write-version", remoteWriteVersion1, fmt.Sprintf("The version of the remote write protocol used to write series over HTTP. A write rejected with the 415 status code, returned by servers not supporting the configured version, stops the run regardless of the configured write error actions. Supported values: %s.", strings.Join(remoteWriteVersions, ", ")))
+	f.StringVar(&cfg.RemoteWriteCompression, "tests.remote-write-compression", writeCompressionSnappy, fmt.Sprintf("The compression of the requests body sent to the remote write API over HTTP. Supported values: %s.", strings.Join(remoteWriteCompressions, ", ")))
+	f.StringVar(&cfg.OTLPWriteCompression, "tests.otlp-write-compression", writeCompressionNone, fmt.Sprintf("The compression of the requests body sent to the OTLP write API. Supported values: %s.", strings.Join(otlpWriteCompressions, ", ")))
 
-	f.Var(&cfg.ReadBaseEndpoint, "tests.read-endpoint", "The base endpoint on the read path. The URL should have no trailing slash. The specific API path is appended by the tool to the URL, for example /api/v1/query_range for range query API, so the configured URL must not include it.")
+	f.Var(&cfg.ReadBaseEndpoint, "tests.read-endpoint", "The base endpoint on the read path, for example the query-frontend address in a deployment where the writes and the queries are served by different addresses. If not set, the queries are sent to the write endpoint. The URL should have no trailing slash. The specific API path is appended by the tool to the URL, for example /api/v1/query_range for range query API, so the configured URL must not include it.")
 	f.DurationVar(&cfg.ReadTimeout, "tests.read-timeout", 60*time.Second, "The timeout for a single read request.")
+
+	f.Var(&cfg.WriteEndpoints, "tests.write-endpoints", "Comma-separated list of base endpoints on the write path, used to write the same series to multiple independent clusters (for example, mirrored clusters). Each write endpoint is paired with the read endpoint at the same position in -tests.read-endpoints. When set, -tests.write-endpoint and -tests.read-endpoint are ignored.")
+	f.Var(&cfg.ReadEndpoints, "tests.read-endpoints", "Comma-separated list of base endpoints on the read path, paired with the write endpoints configured in -tests.write-endpoints.")
+
+	f.DurationVar(&cfg.FlushTimeout, "tests.flush-timeout", 5*time.Minute, "The timeout for a single flush request. The flush request is sent to the write endpoint.")
+
+	f.Var(&cfg.SecondaryWriteEndpoint, "tests.secondary-write-endpoint", "The base endpoint on the write path of a secondary backend, for example a vanilla Prometheus, used as a reference to validate Mimir. The same series are written to the secondary backend, and its query results are checked independently. The URL should have no trailing slash. The remote write API path configured in -tests.secondary-remote-write-path is appended by the tool to the URL.")
+	f.Var(&cfg.SecondaryReadEndpoint, "tests.secondary-read-endpoint", "The base endpoint on the read path of the secondary backend. It must be set when -tests.secondary-write-endpoint is set.")
+	f.StringVar(&cfg.SecondaryRemoteWritePath, "tests.secondary-remote-write-path", "/api/v1/write", "The path of the remote write API on the secondary backend.")
+
+	f.Var(&cfg.ParquetReadEndpoint, "tests.parquet-read-endpoint", "The base endpoint of the long-term Parquet storage query path, to which the queries selected by -tests.write-read-series-test.parquet-query-min-age are sent. The URL should have no trailing slash. If not set, the queries are sent to the read endpoint.")
+	f.Var(&cfg.ParquetReadHeaders, "tests.parquet-read-headers", "Comma-separated list of name=value HTTP headers added to the queries sent to the long-term Parquet storage query path.")
 }
 
 type Client struct {
-	writeClient *http.Client
-	readClient  v1.API
-	cfg         ClientConfig
-	logger      log.Logger
+	writeClient      *http.Client
+	grpcWriteClient  distributorpb.DistributorClient
+	readClient       v1.API
+	remoteReadClient *http.Client
+	cfg              ClientConfig
+	logger           log.Logger
+
+	// The path of the remote write API, appended to the write endpoint.
+	remoteWritePath string
+}
+
+// NewClients returns a client for each pair of write and read endpoints configured in -tests.write-endpoints
+// and -tests.read-endpoints, or a client for each tenant configured in -tests.tenant-ids. If neither multiple
+// endpoints nor multiple tenants are configured, it returns a single client for the endpoints configured in
+// -tests.write-endpoint and -tests.read-endpoint.
+func NewClients(cfg ClientConfig, logger log.Logger) ([]*Client, error) {
+	if len(cfg.TenantIDs) > 0 {
+		if len(cfg.WriteEndpoints) > 0 || len(cfg.ReadEndpoints) > 0 {
+			return nil, errors.New("multiple tenants and multiple endpoints can't be configured at the same time")
+		}
+
+		clients := make([]*Client, 0, len(cfg.TenantIDs))
+		for _, tenantID := range cfg.TenantIDs {
+			if tenantID == "" {
+				return nil, errors.New("the tenant IDs must not be empty")
+			}
+
+			tenantCfg := cfg
+			tenantCfg.TenantID = tenantID
+
+			client, err := NewClient(tenantCfg, log.With(logger, "tenant", tenantID))
+			if err != nil {
+				return nil, err
+			}
+			clients = append(clients, client)
+		}
+
+		return clients, nil
+	}
+
+	if len(cfg.WriteEndpoints) == 0 && len(cfg.ReadEndpoints) == 0 {
+		client, err := NewClient(cfg, logger)
+		if err != nil {
+			return nil, err
+		}
+		return []*Client{client}, nil
+	}
+
+	if len(cfg.WriteEndpoints) != len(cfg.ReadEndpoints) {
+		return nil, fmt.Errorf("the number of write endpoints (%d) doesn't match the number of read endpoints (%d)", len(cfg.WriteEndpoints), len(cfg.ReadEndpoints))
+	}
+
+	clients := make([]*Client, 0, len(cfg.WriteEndpoints))
+	for i := range cfg.WriteEndpoints {
+		endpointCfg := cfg
+		if err := endpointCfg.WriteBaseEndpoint.Set(cfg.WriteEndpoints[i]); err != nil {
+			return nil, errors.Wrapf(err, "invalid write endpoint %q", cfg.WriteEndpoints[i])
+		}
+		if err := endpointCfg.ReadBaseEndpoint.Set(cfg.ReadEndpoints[i]); err != nil {
+			return nil, errors.Wrapf(err, "invalid read endpoint %q", cfg.ReadEndpoints[i])
+		}
+
+		client, err := NewClient(endpointCfg, log.With(logger, "write_endpoint", cfg.WriteEndpoints[i], "read_endpoint", cfg.ReadEndpoints[i]))
+		if err != nil {
+			return nil, err
+		}
+		clients = append(clients, client)
+	}
+
+	return clients, nil
+}
+
+// NewSecondaryClient returns a client for the secondary backend configured in -tests.secondary-write-endpoint and
+// -tests.secondary-read-endpoint, or nil if no secondary backend is configured. The client uses the same
+// authentication of the primary backend.
+func NewSecondaryClient(cfg ClientConfig, logger log.Logger) (*Client, error) {
+	if cfg.SecondaryWriteEndpoint.URL == nil && cfg.SecondaryReadEndpoint.URL == nil {
+		return nil, nil
+	}
+	if cfg.SecondaryWriteEndpoint.URL == nil || cfg.SecondaryReadEndpoint.URL == nil {
+		return nil, errors.New("both the secondary write and read endpoints must be set")
+	}
+
+	secondaryCfg := cfg
+	secondaryCfg.WriteBaseEndpoint = cfg.SecondaryWriteEndpoint
+	secondaryCfg.ReadBaseEndpoint = cfg.SecondaryReadEndpoint
+	// The secondary backend is a vanilla remote write receiver, so the series are always written to it over HTTP,
+	// even if they're pushed to the Mimir distributor over gRPC.
+	secondaryCfg.WriteTransport = writeTransportHTTP
+	secondaryCfg.GRPCWriteEndpoint = ""
+
+	client, err := NewClient(secondaryCfg, log.With(logger, "backend", "secondary"))
+	if err != nil {
+		return nil, err
+	}
+	client.remoteWritePath = cfg.SecondaryRemoteWritePath
+	return client, nil
 }
 
 func NewClient(cfg ClientConfig, logger log.Logger) (*Client, error) {
+	transport, err := newHTTPTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	rt := &clientRoundTripper{
 		tenantID:          cfg.TenantID,
 		basicAuthUser:     cfg.BasicAuthUser,
 		basicAuthPassword: cfg.BasicAuthPassword,
 		bearerToken:       cfg.BearerToken,
-		rt:                instrumentation.TracerTransport{},
+		rt:                instrumentation.TracerTransport{Next: transport},
 	}
 
 	// Ensure the required config has been set.
 	if cfg.WriteBaseEndpoint.URL == nil {
 		return nil, errors.New("the write endpoint has not been set")
 	}
+	// The queries are sent to the write endpoint, if no separate read endpoint has been set.
 	if cfg.ReadBaseEndpoint.URL == nil {
-		return nil, errors.New("the read endpoint has not been set")
+		cfg.ReadBaseEndpoint = cfg.WriteBaseEndpoint
+	}
+
+	// The queries sent to the Parquet storage query path are redirected to its endpoint, if any.
+	if cfg.ParquetReadEndpoint.URL != nil {
+		rt.readBasePath = cfg.ReadBaseEndpoint.Path
+		rt.parquetReadEndpoint = cfg.ParquetReadEndpoint.URL
 	}
+	parquetReadHeaders, err := parseHeaders(cfg.ParquetReadHeaders)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid Parquet read headers")
+	}
+	rt.parquetReadHeaders = parquetReadHeaders
 	// Ensure not both tenant-id and basic-auth are used at the same time
 	// anonymous is the default value for TenantID.
 	if (cfg.TenantID != "anonymous" && cfg.BasicAuthUser != "" && cfg.BasicAuthPassword != "" && cfg.BearerToken != "") || // all authentication at once
@@ -112,14 +418,72 @@ func NewClient(cfg ClientConfig, logger log.Logger) (*Client, error) {
 		return nil, errors.Wrap(err, "failed to create read client")
 	}
 
+	var grpcWriteClient distributorpb.DistributorClient
+	switch cfg.WriteTransport {
+	case writeTransportHTTP:
+	case writeTransportGRPC:
+		if cfg.GRPCWriteEndpoint == "" {
+			return nil, errors.New("the gRPC write endpoint has not been set")
+		}
+
+		// The connection is established lazily, so that the client can be created even if the endpoint is not
+		// reachable yet, like the HTTP client.
+		conn, err := grpc.Dial(cfg.GRPCWriteEndpoint,
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithUnaryInterceptor(middleware.ClientUserHeaderInterceptor),
+		)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create gRPC write client")
+		}
+		grpcWriteClient = distributorpb.NewDistributorClient(conn)
+	default:
+		return nil, fmt.Errorf("unsupported write transport %q (supported values: %s)", cfg.WriteTransport, strings.Join(writeTransports, ", "))
+	}
+
+	if !util.StringsContain(remoteWriteVersions, cfg.RemoteWriteVersion) {
+		return nil, fmt.Errorf("unsupported remote write version %q (supported values: %s)", cfg.RemoteWriteVersion, strings.Join(remoteWriteVersions, ", "))
+	}
+	if cfg.RemoteWriteVersion == remoteWriteVersion2 && cfg.WriteTransport == writeTransportGRPC {
+		return nil, fmt.Errorf("the remote write version %s is not supported by the %s write transport", remoteWriteVersion2, writeTransportGRPC)
+	}
+	if !util.StringsContain(remoteWriteCompressions, cfg.RemoteWriteCompression) {
+		return nil, fmt.Errorf("unsupported remote write compression %q (supported values: %s)", cfg.RemoteWriteCompression, strings.Join(remoteWriteCompressions, ", "))
+	}
+	if !util.StringsContain(otlpWriteCompressions, cfg.OTLPWriteCompression) {
+		return nil, fmt.Errorf("unsupported OTLP write compression %q (supported values: %s)", cfg.OTLPWriteCompression, strings.Join(otlpWriteCompressions, ", "))
+	}
+
 	return &Client{
-		writeClient: &http.Client{Transport: rt},
-		readClient:  v1.NewAPI(readClient),
-		cfg:         cfg,
-		logger:      logger,
+		writeClient:      &http.Client{Transport: rt},
+		grpcWriteClient:  grpcWriteClient,
+		readClient:       v1.NewAPI(readClient),
+		remoteReadClient: &http.Client{Transport: rt},
+		cfg:              cfg,
+		logger:           logger,
+		remoteWritePath:  mimirRemoteWritePath,
 	}, nil
 }
 
+// newHTTPTransport returns the HTTP transport used to write and query, configured with the TLS and connections
+// reuse settings.
+func newHTTPTransport(cfg ClientConfig) (*http.Transport, error) {
+	// The TLS certificates and keys are loaded right away, so that a misconfiguration fails at startup
+	// instead of at the first request.
+	tlsConfig, err := cfg.TLS.GetTLSConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid TLS config")
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	transport.MaxIdleConns = cfg.MaxIdleConns
+	transport.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	transport.IdleConnTimeout = cfg.IdleConnTimeout
+	// HTTP/2 is only attempted when forced, because the TLS config is customized.
+	transport.ForceAttemptHTTP2 = cfg.HTTP2Enabled
+	return transport, nil
+}
+
 // QueryRange implements MimirClient.
 func (c *Client) QueryRange(ctx context.Context, query string, start, end time.Time, step time.Duration, options ...RequestOption) (model.Matrix, error) {
 	ctx = contextWithRequestOptions(ctx, options...)
@@ -170,18 +534,231 @@ func (c *Client) Query(ctx context.Context, query string, ts time.Time, options
 	return vector, nil
 }
 
+// QueryRelativeTime implements MimirClient.
+func (c *Client) QueryRelativeTime(ctx context.Context, query string, relativeTime string, options ...RequestOption) (model.Vector, error) {
+	ctx = contextWithRequestOptions(ctx, options...)
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.ReadTimeout)
+	defer cancel()
+
+	// The Prometheus API client only accepts absolute times, so the request is built here.
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("time", relativeTime)
+
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", c.cfg.ReadBaseEndpoint.String()+"/api/v1/query?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("User-Agent", "mimir-continuous-test")
+
+	httpResp, err := c.remoteReadClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+
+	body, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "server returned HTTP status %s and client failed to read response body", httpResp.Status)
+	}
+
+	// The errors are returned like the Prometheus API client does, so that they're classified the same way.
+	if httpResp.StatusCode == http.StatusBadRequest || httpResp.StatusCode == http.StatusUnprocessableEntity {
+		var resp struct {
+			ErrorType v1.ErrorType `json:"errorType"`
+			Error     string       `json:"error"`
+		}
+		if err := json.Unmarshal(body, &resp); err == nil && resp.ErrorType != "" {
+			return nil, &v1.Error{Type: resp.ErrorType, Msg: resp.Error}
+		}
+	}
+	switch {
+	case httpResp.StatusCode/100 == 4:
+		return nil, &v1.Error{Type: v1.ErrClient, Msg: fmt.Sprintf("client error: %d", httpResp.StatusCode), Detail: string(body)}
+	case httpResp.StatusCode/100 != 2:
+		return nil, &v1.Error{Type: v1.ErrServer, Msg: fmt.Sprintf("server error: %d", httpResp.StatusCode), Detail: string(body)}
+	}
+
+	var resp struct {
+		Data struct {
+			ResultType string       `json:"resultType"`
+			Result     model.Vector `json:"result"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, errors.Wrap(err, "failed to decode query response")
+	}
+	if resp.Data.ResultType != model.ValVector.String() {
+		return nil, fmt.Errorf("was expecting to get a Vector, but got %s", resp.Data.ResultType)
+	}
+
+	return resp.Data.Result, nil
+}
+
+// QueryExemplars implements MimirClient.
+func (c *Client) QueryExemplars(ctx context.Context, query string, start, end time.Time) ([]v1.ExemplarQueryResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.ReadTimeout)
+	defer cancel()
+
+	return c.readClient.QueryExemplars(ctx, query, start, end)
+}
+
+// BuildInfo implements MimirClient.
+func (c *Client) BuildInfo(ctx context.Context) (v1.BuildinfoResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.ReadTimeout)
+	defer cancel()
+
+	return c.readClient.Buildinfo(ctx)
+}
+
+// Metadata implements MimirClient.
+func (c *Client) Metadata(ctx context.Context, metric string) ([]v1.Metadata, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.ReadTimeout)
+	defer cancel()
+
+	metadata, err := c.readClient.Metadata(ctx, metric, "")
+	if err != nil {
+		return nil, err
+	}
+	return metadata[metric], nil
+}
+
+// LabelValues implements MimirClient.
+func (c *Client) LabelValues(ctx context.Context, name string, matchers []string, start, end time.Time) (model.LabelValues, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.ReadTimeout)
+	defer cancel()
+
+	values, _, err := c.readClient.LabelValues(ctx, name, matchers, start, end)
+	return values, err
+}
+
+// Series implements MimirClient.
+func (c *Client) Series(ctx context.Context, matchers []string, start, end time.Time) ([]model.LabelSet, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.ReadTimeout)
+	defer cancel()
+
+	series, _, err := c.readClient.Series(ctx, matchers, start, end)
+	return series, err
+}
+
+// LabelNamesCardinality implements MimirClient.
+func (c *Client) LabelNamesCardinality(ctx context.Context, selector string, limit int) (*LabelNamesCardinalityResponse, error) {
+	params := url.Values{}
+	if selector != "" {
+		params.Set("selector", selector)
+	}
+	params.Set("limit", strconv.Itoa(limit))
+
+	resp := &LabelNamesCardinalityResponse{}
+	if err := c.getCardinality(ctx, "label_names", params, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// LabelValuesCardinality implements MimirClient.
+func (c *Client) LabelValuesCardinality(ctx context.Context, labelNames []string, selector string, limit int) (*LabelValuesCardinalityResponse, error) {
+	params := url.Values{}
+	for _, name := range labelNames {
+		params.Add("label_names[]", name)
+	}
+	if selector != "" {
+		params.Set("selector", selector)
+	}
+	params.Set("limit", strconv.Itoa(limit))
+
+	resp := &LabelValuesCardinalityResponse{}
+	if err := c.getCardinality(ctx, "label_values", params, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// ActiveSeries implements MimirClient.
+func (c *Client) ActiveSeries(ctx context.Context, matchers []string) ([]model.LabelSet, error) {
+	if len(matchers) == 0 {
+		return nil, errors.New("at least one label matcher is required to query the active series")
+	}
+
+	params := url.Values{}
+	params.Set("selector", "{"+strings.Join(matchers, ",")+"}")
+
+	resp := &activeSeriesResponse{}
+	if err := c.getCardinality(ctx, "active_series", params, resp); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// getCardinality sends a GET request to the input cardinality API endpoint, and decodes the JSON response into out.
+func (c *Client) getCardinality(ctx context.Context, endpoint string, params url.Values, out interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.ReadTimeout)
+	defer cancel()
+
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", c.cfg.ReadBaseEndpoint.String()+"/api/v1/cardinality/"+endpoint+"?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("User-Agent", "mimir-continuous-test")
+
+	httpResp, err := c.remoteReadClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode/100 != 2 {
+		truncatedBody, err := io.ReadAll(io.LimitReader(httpResp.Body, maxErrMsgLen))
+		if err != nil {
+			return errors.Wrapf(err, "server returned HTTP status %s and client failed to read response body", httpResp.Status)
+		}
+
+		return fmt.Errorf("server returned HTTP status %s and body %q (truncated to %d bytes)", httpResp.Status, string(truncatedBody), maxErrMsgLen)
+	}
+
+	if err := json.NewDecoder(httpResp.Body).Decode(out); err != nil {
+		return errors.Wrap(err, "failed to decode cardinality response")
+	}
+	return nil
+}
+
 // WriteSeries implements MimirClient.
 func (c *Client) WriteSeries(ctx context.Context, series []prompb.TimeSeries) (int, error) {
+	return c.writeSeriesInBatches(series, func(batch []prompb.TimeSeries) (int, error) {
+		if c.grpcWriteClient != nil {
+			return c.sendGRPCWriteRequest(ctx, &prompb.WriteRequest{Timeseries: batch})
+		}
+		return c.sendWriteRequest(ctx, &prompb.WriteRequest{Timeseries: batch})
+	})
+}
+
+// WriteMetadata implements MimirClient.
+func (c *Client) WriteMetadata(ctx context.Context, metadata []prompb.MetricMetadata) (int, error) {
+	if c.grpcWriteClient != nil {
+		return c.sendGRPCWriteRequest(ctx, &prompb.WriteRequest{Metadata: metadata})
+	}
+	return c.sendWriteRequest(ctx, &prompb.WriteRequest{Metadata: metadata})
+}
+
+// WriteSeriesOTLP implements MimirClient.
+func (c *Client) WriteSeriesOTLP(ctx context.Context, series []prompb.TimeSeries) (int, error) {
+	return c.writeSeriesInBatches(series, func(batch []prompb.TimeSeries) (int, error) {
+		return c.sendOTLPWriteRequest(ctx, batch)
+	})
+}
+
+// writeSeriesInBatches splits the input series in batches honoring the configured batch size, and sends each
+// batch through the input function. It stops at the first failed batch.
+func (c *Client) writeSeriesInBatches(series []prompb.TimeSeries, send func(batch []prompb.TimeSeries) (int, error)) (int, error) {
 	lastStatusCode := 0
 
-	// Honor the batch size.
 	for len(series) > 0 {
 		end := util_math.Min(len(series), c.cfg.WriteBatchSize)
 		batch := series[0:end]
 		series = series[end:]
 
 		var err error
-		lastStatusCode, err = c.sendWriteRequest(ctx, &prompb.WriteRequest{Timeseries: batch})
+		lastStatusCode, err = send(batch)
 		if err != nil {
 			return lastStatusCode, err
 		}
@@ -190,27 +767,291 @@ func (c *Client) WriteSeries(ctx context.Context, series []prompb.TimeSeries) (i
 	return lastStatusCode, nil
 }
 
+// ReadSeries implements MimirClient.
+func (c *Client) ReadSeries(ctx context.Context, matchers []*labels.Matcher, start, end time.Time) (model.Matrix, error) {
+	query, err := remote.ToQuery(start.UnixMilli(), end.UnixMilli(), matchers, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := proto.Marshal(&prompb.ReadRequest{
+		Queries:               []*prompb.Query{query},
+		AcceptedResponseTypes: []prompb.ReadRequest_ResponseType{prompb.ReadRequest_SAMPLES},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.ReadTimeout)
+	defer cancel()
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.cfg.ReadBaseEndpoint.String()+"/api/v1/read", bytes.NewReader(snappy.Encode(nil, data)))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Add("Content-Encoding", "snappy")
+	httpReq.Header.Add("Accept-Encoding", "snappy")
+	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+	httpReq.Header.Set("User-Agent", "mimir-continuous-test")
+	httpReq.Header.Set("X-Prometheus-Remote-Read-Version", "0.1.0")
+
+	httpResp, err := c.remoteReadClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode/100 != 2 {
+		truncatedBody, err := io.ReadAll(io.LimitReader(httpResp.Body, maxErrMsgLen))
+		if err != nil {
+			return nil, errors.Wrapf(err, "server returned HTTP status %s and client failed to read response body", httpResp.Status)
+		}
+
+		return nil, fmt.Errorf("server returned HTTP status %s and body %q (truncated to %d bytes)", httpResp.Status, string(truncatedBody), maxErrMsgLen)
+	}
+
+	compressed, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read remote read response body")
+	}
+	uncompressed, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decompress remote read response body")
+	}
+
+	var resp prompb.ReadResponse
+	if err := proto.Unmarshal(uncompressed, &resp); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal remote read response")
+	}
+	if len(resp.Results) != 1 {
+		return nil, fmt.Errorf("expected 1 result in the remote read response but got %d", len(resp.Results))
+	}
+
+	matrix := make(model.Matrix, 0, len(resp.Results[0].Timeseries))
+	for _, series := range resp.Results[0].Timeseries {
+		metric := make(model.Metric, len(series.Labels))
+		for _, l := range series.Labels {
+			metric[model.LabelName(l.Name)] = model.LabelValue(l.Value)
+		}
+
+		values := make([]model.SamplePair, 0, len(series.Samples))
+		for _, sample := range series.Samples {
+			values = append(values, model.SamplePair{Timestamp: model.Time(sample.Timestamp), Value: model.SampleValue(sample.Value)})
+		}
+
+		matrix = append(matrix, &model.SampleStream{Metric: metric, Values: values})
+	}
+
+	return matrix, nil
+}
+
+// Flush implements MimirClient.
+func (c *Client) Flush(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.FlushTimeout)
+	defer cancel()
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.cfg.WriteBaseEndpoint.String()+"/ingester/flush?wait=true", nil)
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("User-Agent", "mimir-continuous-test")
+
+	httpResp, err := c.writeClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode/100 != 2 {
+		truncatedBody, err := io.ReadAll(io.LimitReader(httpResp.Body, maxErrMsgLen))
+		if err != nil {
+			return errors.Wrapf(err, "server returned HTTP status %s and client failed to read response body", httpResp.Status)
+		}
+
+		return fmt.Errorf("server returned HTTP status %s and body %q (truncated to %d bytes)", httpResp.Status, string(truncatedBody), maxErrMsgLen)
+	}
+
+	return nil
+}
+
 func (c *Client) sendWriteRequest(ctx context.Context, req *prompb.WriteRequest) (int, error) {
+	// The remote write 2.0 protocol attaches the metadata to the series, so the metadata-only requests are
+	// always written through the remote write 1.0 protocol.
+	version := c.cfg.RemoteWriteVersion
+	if len(req.Metadata) > 0 {
+		version = remoteWriteVersion1
+	}
+
+	var (
+		data []byte
+		err  error
+	)
+	if version == remoteWriteVersion2 {
+		data, err = marshalWriteV2Request(req.Timeseries)
+	} else {
+		data, err = proto.Marshal(req)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.WriteTimeout)
+	defer cancel()
+
+	compressed, err := compressWriteRequest(data, c.cfg.RemoteWriteCompression)
+	if err != nil {
+		return 0, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.cfg.WriteBaseEndpoint.String()+c.remoteWritePath, bytes.NewReader(compressed))
+	if err != nil {
+		// Errors from NewRequest are from unparseable URLs, so are not
+		// recoverable.
+		return 0, err
+	}
+	httpReq.Header.Add("Content-Encoding", c.cfg.RemoteWriteCompression)
+	httpReq.Header.Set("User-Agent", "mimir-continuous-test")
+	if version == remoteWriteVersion2 {
+		httpReq.Header.Set("Content-Type", remoteWriteV2ContentType)
+		httpReq.Header.Set("X-Prometheus-Remote-Write-Version", "2.0.0")
+	} else {
+		httpReq.Header.Set("Content-Type", "application/x-protobuf")
+		httpReq.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	}
+
+	statusCode, err := c.doWriteRequest(httpReq)
+	if statusCode == http.StatusUnsupportedMediaType && version == remoteWriteVersion2 {
+		return statusCode, errors.Wrapf(err, "the server doesn't support the remote write version %s", remoteWriteVersion2)
+	}
+	return statusCode, err
+}
+
+// sendGRPCWriteRequest pushes the input write request to the distributor gRPC endpoint, and returns the HTTP
+// status code equivalent to the gRPC response status, so that failures are tracked like the HTTP ones.
+func (c *Client) sendGRPCWriteRequest(ctx context.Context, req *prompb.WriteRequest) (int, error) {
+	// The Prometheus and Mimir write requests share the same wire format.
 	data, err := proto.Marshal(req)
 	if err != nil {
 		return 0, err
 	}
+	mimirReq := &mimirpb.WriteRequest{}
+	if err := mimirReq.Unmarshal(data); err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.WriteTimeout)
+	defer cancel()
+
+	if c.cfg.BearerToken != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+c.cfg.BearerToken)
+	} else if c.cfg.BasicAuthUser != "" && c.cfg.BasicAuthPassword != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(c.cfg.BasicAuthUser+":"+c.cfg.BasicAuthPassword)))
+	} else {
+		ctx = user.InjectOrgID(ctx, c.cfg.TenantID)
+	}
+
+	_, err = c.grpcWriteClient.Push(ctx, mimirReq)
+	statusCode := grpcStatusCode(err)
+	if err != nil {
+		return statusCode, errors.Wrapf(err, "server returned gRPC error translated to HTTP status %d", statusCode)
+	}
+	return statusCode, nil
+}
+
+// grpcStatusCode returns the HTTP status code equivalent to the status of the input gRPC error, or 200 if the
+// error is nil. Errors carrying an HTTP response, like the ones returned by Mimir, are translated into the
+// response status code.
+func grpcStatusCode(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+	if resp, ok := httpgrpc.HTTPResponseFromError(err); ok {
+		return int(resp.Code)
+	}
+
+	switch status.Code(err) {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		// The non-standard status code used by Mimir when the client closes the request.
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (c *Client) sendOTLPWriteRequest(ctx context.Context, series []prompb.TimeSeries) (int, error) {
+	// The samples timestamps are preserved by the conversion, so the written samples are aligned to the
+	// write interval exactly like the ones written through the remote write API.
+	data, err := push.TimeseriesToOTLPRequest(series).MarshalProto()
+	if err != nil {
+		return 0, err
+	}
 
 	ctx, cancel := context.WithTimeout(ctx, c.cfg.WriteTimeout)
 	defer cancel()
 
-	compressed := snappy.Encode(nil, data)
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.cfg.WriteBaseEndpoint.String()+"/api/v1/push", bytes.NewReader(compressed))
+	compressed, err := compressWriteRequest(data, c.cfg.OTLPWriteCompression)
+	if err != nil {
+		return 0, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.cfg.WriteBaseEndpoint.String()+"/otlp/v1/metrics", bytes.NewReader(compressed))
 	if err != nil {
 		// Errors from NewRequest are from unparseable URLs, so are not
 		// recoverable.
 		return 0, err
 	}
-	httpReq.Header.Add("Content-Encoding", "snappy")
+	if c.cfg.OTLPWriteCompression != writeCompressionNone {
+		httpReq.Header.Add("Content-Encoding", c.cfg.OTLPWriteCompression)
+	}
 	httpReq.Header.Set("Content-Type", "application/x-protobuf")
 	httpReq.Header.Set("User-Agent", "mimir-continuous-test")
-	httpReq.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
 
+	return c.doWriteRequest(httpReq)
+}
+
+// compressWriteRequest compresses the input write request body with the input compression.
+func compressWriteRequest(data []byte, compression string) ([]byte, error) {
+	switch compression {
+	case writeCompressionNone:
+		return data, nil
+	case writeCompressionSnappy:
+		return snappy.Encode(nil, data), nil
+	case writeCompressionGzip:
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		if _, err := gz.Write(data); err != nil {
+			return nil, err
+		}
+		if err := gz.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unsupported write compression %q", compression)
+	}
+}
+
+// doWriteRequest sends the input write request, and returns the response status code and an error
+// if the request failed.
+func (c *Client) doWriteRequest(httpReq *http.Request) (int, error) {
 	httpResp, err := c.writeClient.Do(httpReq)
 	if err != nil {
 		return 0, err
@@ -229,6 +1070,123 @@ func (c *Client) sendWriteRequest(ctx context.Context, req *prompb.WriteRequest)
 	return httpResp.StatusCode, nil
 }
 
+// parseHeaders parses the input list of "name=value" pairs into HTTP headers.
+func parseHeaders(pairs []string) (http.Header, error) {
+	headers := http.Header{}
+	for _, pair := range pairs {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("the header %q is not in the name=value format", pair)
+		}
+		headers.Add(name, value)
+	}
+	return headers, nil
+}
+
+// queryLimitErrorIDs are the IDs of the errors returned by Mimir when a query hits a limit.
+var queryLimitErrorIDs = []globalerror.ID{
+	globalerror.MaxSeriesPerQuery,
+	globalerror.MaxChunksPerQuery,
+	globalerror.MaxChunkBytesPerQuery,
+	globalerror.MaxQueryLength,
+	globalerror.MaxTotalQueryLength,
+	globalerror.RequestRateLimited,
+}
+
+// isQueryLimitError returns whether the input error message is the one returned by Mimir when a query hits a limit.
+func isQueryLimitError(msg string) bool {
+	if strings.HasPrefix(msg, "the query exceeded") {
+		return true
+	}
+	for _, id := range queryLimitErrorIDs {
+		// The message of the errors with an ID ends with the ID, or with the ID followed by a suggestion on how
+		// to adjust the limit.
+		if strings.Contains(msg, strings.TrimSpace(id.Message(""))) {
+			return true
+		}
+	}
+	return false
+}
+
+// classifyQueryError returns the reason why a query failed, based on the error returned by Client.Query()
+// or Client.QueryRange().
+func classifyQueryError(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return queryErrorReasonTimeout
+	}
+
+	var apiErr *v1.Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.Type {
+		case v1.ErrTimeout:
+			return queryErrorReasonTimeout
+		case v1.ErrServer:
+			// The Prometheus API client doesn't decode the response body on 5xx errors,
+			// so we have to look into it to find out whether the query timed out.
+			var body struct {
+				ErrorType v1.ErrorType `json:"errorType"`
+			}
+			if json.Unmarshal([]byte(apiErr.Detail), &body) == nil && body.ErrorType == v1.ErrTimeout {
+				return queryErrorReasonTimeout
+			}
+			return queryErrorReasonServerError
+		case v1.ErrBadData, v1.ErrExec:
+			// Mimir rejects the queries hitting a limit with a 400 or 422 response.
+			if isQueryLimitError(apiErr.Msg) {
+				return queryErrorReasonLimitExceeded
+			}
+			if apiErr.Type == v1.ErrBadData {
+				return queryErrorReasonParse
+			}
+		case v1.ErrBadResponse:
+			return queryErrorReasonParse
+		case v1.ErrClient:
+			if apiErr.Msg == fmt.Sprintf("client error: %d", http.StatusTooManyRequests) {
+				return queryErrorReasonLimitExceeded
+			}
+		}
+		return queryErrorReasonOther
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return queryErrorReasonTimeout
+		}
+		return queryErrorReasonNetwork
+	}
+
+	return queryErrorReasonOther
+}
+
+// queryErrorStatusCode returns the HTTP status code of the failed query response, based on the error returned
+// by Client.Query() or Client.QueryRange(). It returns "0" if the query failed without a non-2xx response
+// (eg. a network error or a client-side timeout).
+func queryErrorStatusCode(err error) string {
+	var apiErr *v1.Error
+	if !errors.As(err, &apiErr) {
+		return "0"
+	}
+
+	switch apiErr.Type {
+	case v1.ErrClient, v1.ErrServer:
+		// The Prometheus API client reports the status code in the error message, unless the response
+		// body is decoded.
+		if idx := strings.LastIndex(apiErr.Msg, "error: "); idx >= 0 {
+			if statusCode, err := strconv.Atoi(apiErr.Msg[idx+len("error: "):]); err == nil {
+				return strconv.Itoa(statusCode)
+			}
+		}
+	case v1.ErrBadData:
+		// The Prometheus API client only decodes the response body on 400 and 422 responses, and the
+		// error type maps to the status code.
+		return strconv.Itoa(http.StatusBadRequest)
+	case v1.ErrExec:
+		return strconv.Itoa(http.StatusUnprocessableEntity)
+	}
+	return "0"
+}
+
 // RequestOption defines a functional-style request option.
 type RequestOption func(options *requestOptions)
 
@@ -240,6 +1198,21 @@ func WithResultsCacheEnabled(enabled bool) RequestOption {
 	}
 }
 
+// WithParquetStorage controls whether the query should be sent to the long-term Parquet storage query path.
+func WithParquetStorage(enabled bool) RequestOption {
+	return func(options *requestOptions) {
+		options.parquetStorage = enabled
+	}
+}
+
+// WithLookbackDelta sets the lookback delta of the instant query, sent as the lookback_delta parameter. The
+// lookback delta configured in the target is used if 0.
+func WithLookbackDelta(lookbackDelta time.Duration) RequestOption {
+	return func(options *requestOptions) {
+		options.lookbackDelta = lookbackDelta
+	}
+}
+
 // contextWithRequestOptions returns a context.Context with the request options applied.
 func contextWithRequestOptions(ctx context.Context, options ...RequestOption) context.Context {
 	actual := &requestOptions{}
@@ -252,6 +1225,8 @@ func contextWithRequestOptions(ctx context.Context, options ...RequestOption) co
 
 type requestOptions struct {
 	resultsCacheDisabled bool
+	parquetStorage       bool
+	lookbackDelta        time.Duration
 }
 
 type key int
@@ -264,6 +1239,12 @@ type clientRoundTripper struct {
 	basicAuthPassword string
 	bearerToken       string
 	rt                http.RoundTripper
+
+	// The base path of the read endpoint, replaced with the Parquet read endpoint for the queries sent to the
+	// Parquet storage query path. The endpoint is nil if they're sent to the read endpoint.
+	readBasePath        string
+	parquetReadEndpoint *url.URL
+	parquetReadHeaders  http.Header
 }
 
 // RoundTrip add the tenant ID header required by Mimir.
@@ -273,6 +1254,25 @@ func (rt *clientRoundTripper) RoundTrip(req *http.Request) (*http.Response, erro
 		// Despite the name, the "no-store" directive also disables results cache lookup in Mimir.
 		req.Header.Set("Cache-Control", "no-store")
 	}
+	if options != nil && options.parquetStorage {
+		if rt.parquetReadEndpoint != nil {
+			req = req.Clone(req.Context())
+			req.URL.Scheme = rt.parquetReadEndpoint.Scheme
+			req.URL.Host = rt.parquetReadEndpoint.Host
+			req.URL.Path = rt.parquetReadEndpoint.Path + strings.TrimPrefix(req.URL.Path, rt.readBasePath)
+			req.Host = ""
+		}
+		for name, values := range rt.parquetReadHeaders {
+			req.Header[name] = values
+		}
+	}
+	if options != nil && options.lookbackDelta > 0 {
+		// The parameter is added to the URL query, which is parsed together with the form in the request body.
+		req = req.Clone(req.Context())
+		query := req.URL.Query()
+		query.Set("lookback_delta", strconv.FormatFloat(options.lookbackDelta.Seconds(), 'f', -1, 64))
+		req.URL.RawQuery = query.Encode()
+	}
 
 	if rt.bearerToken != "" {
 		req.Header.Set("Authorization", "Bearer "+rt.bearerToken)
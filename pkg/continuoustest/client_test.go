@@ -3,10 +3,21 @@
 package continuoustest
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -14,13 +25,279 @@ import (
 	"github.com/gogo/protobuf/proto"
 	"github.com/golang/snappy"
 	"github.com/grafana/dskit/flagext"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/prompb"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/httpgrpc"
+	"github.com/weaveworks/common/middleware"
+	"github.com/weaveworks/common/user"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/grafana/mimir/integration/ca"
+	"github.com/grafana/mimir/pkg/distributor/distributorpb"
+	"github.com/grafana/mimir/pkg/mimirpb"
 )
 
+func TestNewClients(t *testing.T) {
+	t.Run("should return a single client if multiple endpoints are not configured", func(t *testing.T) {
+		cfg := ClientConfig{}
+		flagext.DefaultValues(&cfg)
+		require.NoError(t, cfg.WriteBaseEndpoint.Set("http://localhost:8080"))
+		require.NoError(t, cfg.ReadBaseEndpoint.Set("http://localhost:8080"))
+
+		clients, err := NewClients(cfg, log.NewNopLogger())
+		require.NoError(t, err)
+		assert.Len(t, clients, 1)
+	})
+
+	t.Run("should fail if the number of write and read endpoints doesn't match", func(t *testing.T) {
+		cfg := ClientConfig{}
+		flagext.DefaultValues(&cfg)
+		require.NoError(t, cfg.WriteEndpoints.Set("http://cluster-a,http://cluster-b"))
+		require.NoError(t, cfg.ReadEndpoints.Set("http://cluster-a"))
+
+		_, err := NewClients(cfg, log.NewNopLogger())
+		require.Error(t, err)
+	})
+
+	t.Run("should return a client for each pair of write and read endpoints", func(t *testing.T) {
+		var failingWrites, succeedingWrites int
+
+		failingServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			failingWrites++
+			writer.WriteHeader(http.StatusInternalServerError)
+		}))
+		t.Cleanup(failingServer.Close)
+
+		succeedingServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			succeedingWrites++
+			writer.WriteHeader(http.StatusOK)
+		}))
+		t.Cleanup(succeedingServer.Close)
+
+		cfg := ClientConfig{}
+		flagext.DefaultValues(&cfg)
+		require.NoError(t, cfg.WriteEndpoints.Set(failingServer.URL+","+succeedingServer.URL))
+		require.NoError(t, cfg.ReadEndpoints.Set(failingServer.URL+","+succeedingServer.URL))
+
+		clients, err := NewClients(cfg, log.NewNopLogger())
+		require.NoError(t, err)
+		require.Len(t, clients, 2)
+
+		series := generateSineWaveSeries("test", time.Now(), 10)
+
+		statusCode, err := clients[0].WriteSeries(context.Background(), series)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusInternalServerError, statusCode)
+
+		statusCode, err = clients[1].WriteSeries(context.Background(), series)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, statusCode)
+
+		assert.Equal(t, 1, failingWrites)
+		assert.Equal(t, 1, succeedingWrites)
+	})
+
+	t.Run("should fail if both multiple tenants and multiple endpoints are configured", func(t *testing.T) {
+		cfg := ClientConfig{}
+		flagext.DefaultValues(&cfg)
+		require.NoError(t, cfg.TenantIDs.Set("tenant-1,tenant-2"))
+		require.NoError(t, cfg.WriteEndpoints.Set("http://cluster-a,http://cluster-b"))
+		require.NoError(t, cfg.ReadEndpoints.Set("http://cluster-a,http://cluster-b"))
+
+		_, err := NewClients(cfg, log.NewNopLogger())
+		require.Error(t, err)
+	})
+
+	t.Run("should return a client for each tenant", func(t *testing.T) {
+		var receivedTenantIDs []string
+
+		server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			receivedTenantIDs = append(receivedTenantIDs, request.Header.Get("X-Scope-OrgID"))
+			writer.WriteHeader(http.StatusOK)
+		}))
+		t.Cleanup(server.Close)
+
+		cfg := ClientConfig{}
+		flagext.DefaultValues(&cfg)
+		require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+		require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+		require.NoError(t, cfg.TenantIDs.Set("tenant-1,tenant-2"))
+
+		clients, err := NewClients(cfg, log.NewNopLogger())
+		require.NoError(t, err)
+		require.Len(t, clients, 2)
+
+		series := generateSineWaveSeries("test", time.Now(), 10)
+		for _, client := range clients {
+			_, err := client.WriteSeries(context.Background(), series)
+			require.NoError(t, err)
+		}
+
+		assert.Equal(t, []string{"tenant-1", "tenant-2"}, receivedTenantIDs)
+	})
+}
+
+func TestNewClient_Endpoints(t *testing.T) {
+	newServer := func(paths *[]string) *httptest.Server {
+		server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			*paths = append(*paths, request.URL.Path)
+			if request.URL.Path == "/api/v1/query_range" {
+				writer.Header().Set("Content-Type", "application/json")
+				_, _ = writer.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[]}}`))
+				return
+			}
+			writer.WriteHeader(http.StatusOK)
+		}))
+		t.Cleanup(server.Close)
+		return server
+	}
+
+	writeAndQuery := func(t *testing.T, client *Client) {
+		_, err := client.WriteSeries(context.Background(), generateSineWaveSeries("test", time.Now(), 1))
+		require.NoError(t, err)
+		_, err = client.QueryRange(context.Background(), "test", time.Now().Add(-time.Minute), time.Now(), time.Minute)
+		require.NoError(t, err)
+	}
+
+	t.Run("should write to the write endpoint and query the read endpoint", func(t *testing.T) {
+		var writePaths, readPaths []string
+		writeServer, readServer := newServer(&writePaths), newServer(&readPaths)
+
+		cfg := ClientConfig{}
+		flagext.DefaultValues(&cfg)
+		require.NoError(t, cfg.WriteBaseEndpoint.Set(writeServer.URL))
+		require.NoError(t, cfg.ReadBaseEndpoint.Set(readServer.URL))
+
+		client, err := NewClient(cfg, log.NewNopLogger())
+		require.NoError(t, err)
+		writeAndQuery(t, client)
+
+		assert.Equal(t, []string{"/api/v1/push"}, writePaths)
+		assert.Equal(t, []string{"/api/v1/query_range"}, readPaths)
+	})
+
+	t.Run("should query the write endpoint if the read endpoint is not set", func(t *testing.T) {
+		var paths []string
+		server := newServer(&paths)
+
+		cfg := ClientConfig{}
+		flagext.DefaultValues(&cfg)
+		require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+
+		client, err := NewClient(cfg, log.NewNopLogger())
+		require.NoError(t, err)
+		writeAndQuery(t, client)
+
+		assert.Equal(t, []string{"/api/v1/push", "/api/v1/query_range"}, paths)
+	})
+
+	t.Run("should fail if the write endpoint is not set", func(t *testing.T) {
+		cfg := ClientConfig{}
+		flagext.DefaultValues(&cfg)
+		require.NoError(t, cfg.ReadBaseEndpoint.Set("http://localhost:8080"))
+
+		_, err := NewClient(cfg, log.NewNopLogger())
+		require.EqualError(t, err, "the write endpoint has not been set")
+	})
+}
+
+func TestNewSecondaryClient(t *testing.T) {
+	t.Run("should return no client if the secondary backend is not configured", func(t *testing.T) {
+		cfg := ClientConfig{}
+		flagext.DefaultValues(&cfg)
+		require.NoError(t, cfg.WriteBaseEndpoint.Set("http://localhost:8080"))
+		require.NoError(t, cfg.ReadBaseEndpoint.Set("http://localhost:8080"))
+
+		client, err := NewSecondaryClient(cfg, log.NewNopLogger())
+		require.NoError(t, err)
+		assert.Nil(t, client)
+	})
+
+	t.Run("should fail if only one of the secondary endpoints is configured", func(t *testing.T) {
+		cfg := ClientConfig{}
+		flagext.DefaultValues(&cfg)
+		require.NoError(t, cfg.SecondaryWriteEndpoint.Set("http://prometheus:9090"))
+
+		_, err := NewSecondaryClient(cfg, log.NewNopLogger())
+		require.Error(t, err)
+	})
+
+	t.Run("should write to the secondary backend through the configured remote write path", func(t *testing.T) {
+		var primaryPaths, secondaryPaths []string
+
+		primaryServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			primaryPaths = append(primaryPaths, request.URL.Path)
+			writer.WriteHeader(http.StatusOK)
+		}))
+		t.Cleanup(primaryServer.Close)
+
+		secondaryServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			secondaryPaths = append(secondaryPaths, request.URL.Path)
+			writer.WriteHeader(http.StatusOK)
+		}))
+		t.Cleanup(secondaryServer.Close)
+
+		cfg := ClientConfig{}
+		flagext.DefaultValues(&cfg)
+		require.NoError(t, cfg.WriteBaseEndpoint.Set(primaryServer.URL))
+		require.NoError(t, cfg.ReadBaseEndpoint.Set(primaryServer.URL))
+		require.NoError(t, cfg.SecondaryWriteEndpoint.Set(secondaryServer.URL))
+		require.NoError(t, cfg.SecondaryReadEndpoint.Set(secondaryServer.URL))
+
+		primaryClient, err := NewClient(cfg, log.NewNopLogger())
+		require.NoError(t, err)
+		secondaryClient, err := NewSecondaryClient(cfg, log.NewNopLogger())
+		require.NoError(t, err)
+		require.NotNil(t, secondaryClient)
+
+		series := generateSineWaveSeries("test", time.Now(), 10)
+		_, err = primaryClient.WriteSeries(context.Background(), series)
+		require.NoError(t, err)
+		_, err = secondaryClient.WriteSeries(context.Background(), series)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"/api/v1/push"}, primaryPaths)
+		assert.Equal(t, []string{"/api/v1/write"}, secondaryPaths)
+	})
+
+	t.Run("should write to the secondary backend over HTTP if the write transport is gRPC", func(t *testing.T) {
+		var secondaryPaths []string
+
+		secondaryServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			secondaryPaths = append(secondaryPaths, request.URL.Path)
+			writer.WriteHeader(http.StatusOK)
+		}))
+		t.Cleanup(secondaryServer.Close)
+
+		cfg := ClientConfig{}
+		flagext.DefaultValues(&cfg)
+		cfg.WriteTransport = writeTransportGRPC
+		cfg.GRPCWriteEndpoint = "localhost:9095"
+		require.NoError(t, cfg.WriteBaseEndpoint.Set("http://localhost:8080"))
+		require.NoError(t, cfg.SecondaryWriteEndpoint.Set(secondaryServer.URL))
+		require.NoError(t, cfg.SecondaryReadEndpoint.Set(secondaryServer.URL))
+
+		secondaryClient, err := NewSecondaryClient(cfg, log.NewNopLogger())
+		require.NoError(t, err)
+		require.NotNil(t, secondaryClient)
+		assert.Nil(t, secondaryClient.grpcWriteClient)
+
+		statusCode, err := secondaryClient.WriteSeries(context.Background(), generateSineWaveSeries("test", time.Now(), 10))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, statusCode)
+		assert.Equal(t, []string{"/api/v1/write"}, secondaryPaths)
+	})
+}
+
 func TestClient_WriteSeries(t *testing.T) {
 	var (
 		nextStatusCode   = http.StatusOK
@@ -106,22 +383,40 @@ func TestClient_WriteSeries(t *testing.T) {
 	})
 }
 
-func TestClient_QueryRange(t *testing.T) {
+func TestClient_WriteSeries_RemoteWriteV2(t *testing.T) {
 	var (
+		supportsV2       bool
 		receivedRequests []*http.Request
+		receivedSeries   [][]prompb.TimeSeries
 	)
 
 	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
 		receivedRequests = append(receivedRequests, request)
 
-		writer.WriteHeader(http.StatusOK)
-		_, err := writer.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[]}}`))
+		// Servers only supporting remote write 1.0 reject any other content type.
+		if request.Header.Get("Content-Type") != "application/x-protobuf" && !supportsV2 {
+			writer.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+
+		body, err := io.ReadAll(request.Body)
+		require.NoError(t, err)
+		require.NoError(t, request.Body.Close())
+
+		body, err = snappy.Decode(nil, body)
 		require.NoError(t, err)
+
+		_, series := unmarshalWriteV2Request(t, body)
+		receivedSeries = append(receivedSeries, series)
+
+		writer.WriteHeader(http.StatusNoContent)
 	}))
 	t.Cleanup(server.Close)
 
 	cfg := ClientConfig{}
 	flagext.DefaultValues(&cfg)
+	cfg.WriteBatchSize = 10
+	cfg.RemoteWriteVersion = remoteWriteVersion2
 	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
 	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
 
@@ -129,44 +424,245 @@ func TestClient_QueryRange(t *testing.T) {
 	require.NoError(t, err)
 
 	ctx := context.Background()
+	now := time.Now()
 
-	t.Run("results cache not explicitly disabled", func(t *testing.T) {
+	t.Run("write series to a server supporting remote write 2.0", func(t *testing.T) {
+		supportsV2 = true
 		receivedRequests = nil
+		receivedSeries = nil
 
-		_, err := c.QueryRange(ctx, "up", time.Unix(0, 0), time.Unix(1000, 0), 10)
+		series := generateSineWaveSeries("test", now, 12)
+		statusCode, err := c.WriteSeries(ctx, series)
 		require.NoError(t, err)
+		assert.Equal(t, 204, statusCode)
 
-		require.Len(t, receivedRequests, 1)
-		assert.Empty(t, receivedRequests[0].Header.Get("Cache-Control"))
+		require.Len(t, receivedRequests, 2)
+		assert.Equal(t, "application/x-protobuf;proto=io.prometheus.write.v2.Request", receivedRequests[0].Header.Get("Content-Type"))
+		assert.Equal(t, "2.0.0", receivedRequests[0].Header.Get("X-Prometheus-Remote-Write-Version"))
+		assert.Equal(t, series[0:10], receivedSeries[0])
+		assert.Equal(t, series[10:12], receivedSeries[1])
 	})
 
-	t.Run("results cache disabled", func(t *testing.T) {
+	t.Run("write series to a server only supporting remote write 1.0", func(t *testing.T) {
+		supportsV2 = false
 		receivedRequests = nil
+		receivedSeries = nil
 
-		_, err := c.QueryRange(ctx, "up", time.Unix(0, 0), time.Unix(1000, 0), 10, WithResultsCacheEnabled(false))
+		statusCode, err := c.WriteSeries(ctx, generateSineWaveSeries("test", now, 1))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "the server doesn't support the remote write version 2.0")
+		assert.Equal(t, 415, statusCode)
+	})
+
+	t.Run("should fail on unsupported remote write version", func(t *testing.T) {
+		invalidCfg := cfg
+		invalidCfg.RemoteWriteVersion = "3.0"
+
+		_, err := NewClient(invalidCfg, log.NewNopLogger())
+		require.Error(t, err)
+	})
+
+	t.Run("should fail on remote write 2.0 through the gRPC transport", func(t *testing.T) {
+		invalidCfg := cfg
+		invalidCfg.WriteTransport = writeTransportGRPC
+		invalidCfg.GRPCWriteEndpoint = "localhost:9095"
+
+		_, err := NewClient(invalidCfg, log.NewNopLogger())
+		require.Error(t, err)
+	})
+}
+
+type distributorServerMock struct {
+	distributorpb.UnimplementedDistributorServer
+
+	nextErr          error
+	receivedTenants  []string
+	receivedRequests []prompb.WriteRequest
+}
+
+func (m *distributorServerMock) Push(ctx context.Context, req *mimirpb.WriteRequest) (*mimirpb.WriteResponse, error) {
+	tenantID, err := user.ExtractOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Convert the request back to the Prometheus format, to compare it with the written series.
+	data, err := req.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	var promReq prompb.WriteRequest
+	if err := proto.Unmarshal(data, &promReq); err != nil {
+		return nil, err
+	}
+
+	m.receivedTenants = append(m.receivedTenants, tenantID)
+	m.receivedRequests = append(m.receivedRequests, promReq)
+	if m.nextErr != nil {
+		return nil, m.nextErr
+	}
+	return &mimirpb.WriteResponse{}, nil
+}
+
+func TestClient_WriteSeries_GRPC(t *testing.T) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+
+	distributor := &distributorServerMock{}
+	server := grpc.NewServer(grpc.UnaryInterceptor(middleware.ServerUserHeaderInterceptor))
+	distributorpb.RegisterDistributorServer(server, distributor)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.TenantID = "user-1"
+	cfg.WriteBatchSize = 10
+	cfg.WriteTransport = writeTransportGRPC
+	cfg.GRPCWriteEndpoint = listener.Addr().String()
+	require.NoError(t, cfg.WriteBaseEndpoint.Set("http://localhost:8080"))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set("http://localhost:8080"))
+
+	c, err := NewClient(cfg, log.NewNopLogger())
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	now := time.Now()
+
+	t.Run("write series in multiple batches", func(t *testing.T) {
+		distributor.receivedTenants = nil
+		distributor.receivedRequests = nil
+		distributor.nextErr = nil
+
+		series := generateSineWaveSeries("test", now, 12)
+		statusCode, err := c.WriteSeries(ctx, series)
 		require.NoError(t, err)
+		assert.Equal(t, 200, statusCode)
 
-		require.Len(t, receivedRequests, 1)
-		assert.Equal(t, "no-store", receivedRequests[0].Header.Get("Cache-Control"))
+		require.Len(t, distributor.receivedRequests, 2)
+		assert.Equal(t, series[0:10], distributor.receivedRequests[0].Timeseries)
+		assert.Equal(t, series[10:12], distributor.receivedRequests[1].Timeseries)
+		assert.Equal(t, []string{"user-1", "user-1"}, distributor.receivedTenants)
+	})
+
+	t.Run("request failed with an HTTP error", func(t *testing.T) {
+		distributor.receivedRequests = nil
+		distributor.nextErr = httpgrpc.Errorf(http.StatusTooManyRequests, "ingestion rate limit exceeded")
+
+		statusCode, err := c.WriteSeries(ctx, generateSineWaveSeries("test", now, 1))
+		require.Error(t, err)
+		assert.Equal(t, 429, statusCode)
+		require.Len(t, distributor.receivedRequests, 1)
+	})
+
+	t.Run("request failed with a gRPC error", func(t *testing.T) {
+		distributor.receivedRequests = nil
+		distributor.nextErr = status.Error(codes.InvalidArgument, "out of order sample")
+
+		statusCode, err := c.WriteSeries(ctx, generateSineWaveSeries("test", now, 1))
+		require.Error(t, err)
+		assert.Equal(t, 400, statusCode)
+		require.Len(t, distributor.receivedRequests, 1)
 	})
 }
 
-func TestClient_Query(t *testing.T) {
+func TestNewClient_WriteTransport(t *testing.T) {
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	require.NoError(t, cfg.WriteBaseEndpoint.Set("http://localhost:8080"))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set("http://localhost:8080"))
+
+	t.Run("should fail on unsupported write transport", func(t *testing.T) {
+		invalidCfg := cfg
+		invalidCfg.WriteTransport = "unknown"
+
+		_, err := NewClient(invalidCfg, log.NewNopLogger())
+		require.Error(t, err)
+	})
+
+	t.Run("should fail if the gRPC write endpoint is not set", func(t *testing.T) {
+		invalidCfg := cfg
+		invalidCfg.WriteTransport = writeTransportGRPC
+
+		_, err := NewClient(invalidCfg, log.NewNopLogger())
+		require.Error(t, err)
+	})
+}
+
+func TestGRPCStatusCode(t *testing.T) {
+	tests := map[string]struct {
+		err      error
+		expected int
+	}{
+		"no error": {
+			err:      nil,
+			expected: 200,
+		},
+		"HTTP error": {
+			err:      httpgrpc.Errorf(http.StatusRequestEntityTooLarge, "request too large"),
+			expected: 413,
+		},
+		"invalid argument": {
+			err:      status.Error(codes.InvalidArgument, "invalid"),
+			expected: 400,
+		},
+		"resource exhausted": {
+			err:      status.Error(codes.ResourceExhausted, "limit"),
+			expected: 429,
+		},
+		"unavailable": {
+			err:      status.Error(codes.Unavailable, "connection refused"),
+			expected: 503,
+		},
+		"deadline exceeded": {
+			err:      status.Error(codes.DeadlineExceeded, "timeout"),
+			expected: 504,
+		},
+		"internal": {
+			err:      status.Error(codes.Internal, "internal"),
+			expected: 500,
+		},
+		"non-gRPC error": {
+			err:      errors.New("network error"),
+			expected: 500,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			assert.Equal(t, testData.expected, grpcStatusCode(testData.err))
+		})
+	}
+}
+
+func TestClient_WriteSeriesOTLP(t *testing.T) {
 	var (
+		nextStatusCode   = http.StatusOK
 		receivedRequests []*http.Request
+		receivedMetrics  []pmetric.Metrics
 	)
 
 	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		// Read the entire body.
+		body, err := io.ReadAll(request.Body)
+		require.NoError(t, err)
+		require.NoError(t, request.Body.Close())
+
+		// Unmarshal it.
+		req := pmetricotlp.NewExportRequest()
+		require.NoError(t, req.UnmarshalProto(body))
 		receivedRequests = append(receivedRequests, request)
+		receivedMetrics = append(receivedMetrics, req.Metrics())
 
-		writer.WriteHeader(http.StatusOK)
-		_, err := writer.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
-		require.NoError(t, err)
+		writer.WriteHeader(nextStatusCode)
 	}))
 	t.Cleanup(server.Close)
 
 	cfg := ClientConfig{}
 	flagext.DefaultValues(&cfg)
+	cfg.WriteBatchSize = 10
 	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
 	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
 
@@ -174,44 +670,1348 @@ func TestClient_Query(t *testing.T) {
 	require.NoError(t, err)
 
 	ctx := context.Background()
+	now := time.UnixMilli(time.Now().UnixMilli()).UTC()
 
-	t.Run("results cache not explicitly disabled", func(t *testing.T) {
+	t.Run("write series in multiple batches", func(t *testing.T) {
 		receivedRequests = nil
+		receivedMetrics = nil
+		nextStatusCode = http.StatusOK
 
-		_, err := c.Query(ctx, "up", time.Unix(0, 0))
+		series := generateSineWaveSeries("test", now, 12)
+		statusCode, err := c.WriteSeriesOTLP(ctx, series)
 		require.NoError(t, err)
+		assert.Equal(t, 200, statusCode)
 
-		require.Len(t, receivedRequests, 1)
-		assert.Empty(t, receivedRequests[0].Header.Get("Cache-Control"))
+		require.Len(t, receivedRequests, 2)
+		assert.Equal(t, "/otlp/v1/metrics", receivedRequests[0].URL.Path)
+		assert.Equal(t, "application/x-protobuf", receivedRequests[0].Header.Get("Content-Type"))
+		assert.Equal(t, 10, receivedMetrics[0].DataPointCount())
+		assert.Equal(t, 2, receivedMetrics[1].DataPointCount())
+
+		// The samples timestamp and value are preserved.
+		metric := receivedMetrics[0].ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0)
+		assert.Equal(t, "test", metric.Name())
+		require.Equal(t, 1, metric.Gauge().DataPoints().Len())
+		assert.Equal(t, now, metric.Gauge().DataPoints().At(0).Timestamp().AsTime())
+		assert.Equal(t, generateSineWaveValue(now), metric.Gauge().DataPoints().At(0).DoubleValue())
 	})
 
-	t.Run("results cache disabled", func(t *testing.T) {
+	t.Run("request failed with 4xx error", func(t *testing.T) {
 		receivedRequests = nil
+		receivedMetrics = nil
+		nextStatusCode = http.StatusBadRequest
 
-		_, err := c.Query(ctx, "up", time.Unix(0, 0), WithResultsCacheEnabled(false))
-		require.NoError(t, err)
-
-		require.Len(t, receivedRequests, 1)
-		assert.Equal(t, "no-store", receivedRequests[0].Header.Get("Cache-Control"))
+		statusCode, err := c.WriteSeriesOTLP(ctx, generateSineWaveSeries("test", now, 1))
+		require.Error(t, err)
+		assert.Equal(t, 400, statusCode)
 	})
 }
 
-// ClientMock mocks MimirClient.
-type ClientMock struct {
-	mock.Mock
-}
+func TestClient_TLS(t *testing.T) {
+	dir := t.TempDir()
+	caCertFile := filepath.Join(dir, "ca.crt")
+	serverCertFile, serverKeyFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	clientCertFile, clientKeyFile := filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
 
-func (m *ClientMock) WriteSeries(ctx context.Context, series []prompb.TimeSeries) (int, error) {
-	args := m.Called(ctx, series)
-	return args.Int(0), args.Error(1)
+	testCA := ca.New("continuous test")
+	require.NoError(t, testCA.WriteCACertificate(caCertFile))
+	require.NoError(t, testCA.WriteCertificate(&x509.Certificate{
+		Subject:     pkix.Name{CommonName: "server"},
+		DNSNames:    []string{"mimir.test"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, serverCertFile, serverKeyFile))
+	require.NoError(t, testCA.WriteCertificate(&x509.Certificate{
+		Subject:     pkix.Name{CommonName: "client"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, clientCertFile, clientKeyFile))
+
+	caCert, err := os.ReadFile(caCertFile)
+	require.NoError(t, err)
+	caCertPool := x509.NewCertPool()
+	require.True(t, caCertPool.AppendCertsFromPEM(caCert))
+	serverCert, err := tls.LoadX509KeyPair(serverCertFile, serverKeyFile)
+	require.NoError(t, err)
+
+	// The server requires a client certificate signed by the CA.
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		switch request.URL.Path {
+		case "/api/v1/push":
+			writer.WriteHeader(http.StatusOK)
+		case "/api/v1/query":
+			writer.Header().Set("Content-Type", "application/json")
+			_, _ = writer.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+		default:
+			writer.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientCAs:    caCertPool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	newClientConfig := func() ClientConfig {
+		cfg := ClientConfig{}
+		flagext.DefaultValues(&cfg)
+		require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+		require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+		return cfg
+	}
+
+	series := []prompb.TimeSeries{{
+		Labels:  []prompb.Label{{Name: "__name__", Value: "metric_1"}},
+		Samples: []prompb.Sample{{Value: 1, Timestamp: 1}},
+	}}
+
+	tests := map[string]struct {
+		setup       func(cfg *ClientConfig)
+		expectedErr bool
+	}{
+		"should write and query with the client certificate, verifying the server certificate against the CA": {
+			setup: func(cfg *ClientConfig) {
+				cfg.TLS.CAPath = caCertFile
+				cfg.TLS.CertPath = clientCertFile
+				cfg.TLS.KeyPath = clientKeyFile
+				cfg.TLS.ServerName = "mimir.test"
+			},
+		},
+		"should write and query with the client certificate, skipping the server certificate verification": {
+			setup: func(cfg *ClientConfig) {
+				cfg.TLS.CertPath = clientCertFile
+				cfg.TLS.KeyPath = clientKeyFile
+				cfg.TLS.InsecureSkipVerify = true
+			},
+		},
+		"should fail if the server name doesn't match the server certificate": {
+			setup: func(cfg *ClientConfig) {
+				cfg.TLS.CAPath = caCertFile
+				cfg.TLS.CertPath = clientCertFile
+				cfg.TLS.KeyPath = clientKeyFile
+			},
+			expectedErr: true,
+		},
+		"should fail without the client certificate": {
+			setup: func(cfg *ClientConfig) {
+				cfg.TLS.CAPath = caCertFile
+				cfg.TLS.ServerName = "mimir.test"
+			},
+			expectedErr: true,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			cfg := newClientConfig()
+			testData.setup(&cfg)
+
+			c, err := NewClient(cfg, log.NewNopLogger())
+			require.NoError(t, err)
+
+			_, writeErr := c.WriteSeries(context.Background(), series)
+			_, queryErr := c.Query(context.Background(), "metric_1", time.Unix(1, 0))
+			if testData.expectedErr {
+				assert.Error(t, writeErr)
+				assert.Error(t, queryErr)
+			} else {
+				assert.NoError(t, writeErr)
+				assert.NoError(t, queryErr)
+			}
+		})
+	}
+
+	t.Run("should fail at construction on invalid TLS config", func(t *testing.T) {
+		cfg := newClientConfig()
+		cfg.TLS.CertPath = clientCertFile
+		_, err := NewClient(cfg, log.NewNopLogger())
+		require.Error(t, err)
+
+		cfg = newClientConfig()
+		cfg.TLS.CertPath = clientCertFile
+		cfg.TLS.KeyPath = filepath.Join(dir, "missing.key")
+		_, err = NewClient(cfg, log.NewNopLogger())
+		require.Error(t, err)
+
+		cfg = newClientConfig()
+		cfg.TLS.CAPath = filepath.Join(dir, "missing.crt")
+		_, err = NewClient(cfg, log.NewNopLogger())
+		require.Error(t, err)
+	})
 }
 
-func (m *ClientMock) QueryRange(ctx context.Context, query string, start, end time.Time, step time.Duration, options ...RequestOption) (model.Matrix, error) {
-	args := m.Called(ctx, query, start, end, step, options)
-	return args.Get(0).(model.Matrix), args.Error(1)
+func TestNewHTTPTransport(t *testing.T) {
+	// The server reports the HTTP protocol version of the request.
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		_, _ = writer.Write([]byte(request.Proto))
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	tests := map[string]struct {
+		setup         func(cfg *ClientConfig)
+		expectedProto string
+	}{
+		"should use HTTP/2 by default": {
+			setup:         func(*ClientConfig) {},
+			expectedProto: "HTTP/2.0",
+		},
+		"should use HTTP/1.1 if HTTP/2 is disabled": {
+			setup:         func(cfg *ClientConfig) { cfg.HTTP2Enabled = false },
+			expectedProto: "HTTP/1.1",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			cfg := ClientConfig{}
+			flagext.DefaultValues(&cfg)
+			cfg.TLS.InsecureSkipVerify = true
+			cfg.MaxIdleConns = 50
+			cfg.MaxIdleConnsPerHost = 10
+			cfg.IdleConnTimeout = time.Minute
+			testData.setup(&cfg)
+
+			transport, err := newHTTPTransport(cfg)
+			require.NoError(t, err)
+			t.Cleanup(transport.CloseIdleConnections)
+
+			assert.Equal(t, 50, transport.MaxIdleConns)
+			assert.Equal(t, 10, transport.MaxIdleConnsPerHost)
+			assert.Equal(t, time.Minute, transport.IdleConnTimeout)
+			assert.Equal(t, cfg.HTTP2Enabled, transport.ForceAttemptHTTP2)
+
+			res, err := (&http.Client{Transport: transport}).Get(server.URL)
+			require.NoError(t, err)
+			defer res.Body.Close()
+
+			body, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+			assert.Equal(t, testData.expectedProto, string(body))
+		})
+	}
 }
 
-func (m *ClientMock) Query(ctx context.Context, query string, ts time.Time, options ...RequestOption) (model.Vector, error) {
-	args := m.Called(ctx, query, ts, options)
-	return args.Get(0).(model.Vector), args.Error(1)
+func TestClient_WriteCompression(t *testing.T) {
+	var (
+		acceptedEncodings []string
+		receivedEncodings []string
+		receivedBodies    [][]byte
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		encoding := request.Header.Get("Content-Encoding")
+		receivedEncodings = append(receivedEncodings, encoding)
+
+		for _, accepted := range acceptedEncodings {
+			if encoding == accepted {
+				body, err := io.ReadAll(request.Body)
+				require.NoError(t, err)
+				require.NoError(t, request.Body.Close())
+
+				// Decode the body like the server would do.
+				switch encoding {
+				case "snappy":
+					body, err = snappy.Decode(nil, body)
+					require.NoError(t, err)
+				case "gzip":
+					gz, err := gzip.NewReader(bytes.NewReader(body))
+					require.NoError(t, err)
+					body, err = io.ReadAll(gz)
+					require.NoError(t, err)
+				}
+				receivedBodies = append(receivedBodies, body)
+
+				writer.WriteHeader(http.StatusOK)
+				return
+			}
+		}
+
+		writer.WriteHeader(http.StatusUnsupportedMediaType)
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	ctx := context.Background()
+	now := time.UnixMilli(time.Now().UnixMilli()).UTC()
+	series := generateSineWaveSeries("test", now, 1)
+
+	tests := map[string]struct {
+		remoteWriteCompression string
+		otlpWriteCompression   string
+		acceptedEncodings      []string
+		otlp                   bool
+		expectedEncoding       string
+		expectedStatusCode     int
+	}{
+		"remote write with snappy compression": {
+			remoteWriteCompression: writeCompressionSnappy,
+			acceptedEncodings:      []string{"snappy"},
+			expectedEncoding:       "snappy",
+			expectedStatusCode:     200,
+		},
+		"remote write with gzip compression": {
+			remoteWriteCompression: writeCompressionGzip,
+			acceptedEncodings:      []string{"snappy", "gzip"},
+			expectedEncoding:       "gzip",
+			expectedStatusCode:     200,
+		},
+		"remote write with gzip compression rejected by the server": {
+			remoteWriteCompression: writeCompressionGzip,
+			acceptedEncodings:      []string{"snappy"},
+			expectedEncoding:       "gzip",
+			expectedStatusCode:     415,
+		},
+		"OTLP write without compression": {
+			otlpWriteCompression: writeCompressionNone,
+			acceptedEncodings:    []string{""},
+			otlp:                 true,
+			expectedEncoding:     "",
+			expectedStatusCode:   200,
+		},
+		"OTLP write with gzip compression": {
+			otlpWriteCompression: writeCompressionGzip,
+			acceptedEncodings:    []string{"", "gzip"},
+			otlp:                 true,
+			expectedEncoding:     "gzip",
+			expectedStatusCode:   200,
+		},
+		"OTLP write with gzip compression rejected by the server": {
+			otlpWriteCompression: writeCompressionGzip,
+			acceptedEncodings:    []string{""},
+			otlp:                 true,
+			expectedEncoding:     "gzip",
+			expectedStatusCode:   415,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			acceptedEncodings = testData.acceptedEncodings
+			receivedEncodings = nil
+			receivedBodies = nil
+
+			clientCfg := cfg
+			if testData.remoteWriteCompression != "" {
+				clientCfg.RemoteWriteCompression = testData.remoteWriteCompression
+			}
+			if testData.otlpWriteCompression != "" {
+				clientCfg.OTLPWriteCompression = testData.otlpWriteCompression
+			}
+
+			c, err := NewClient(clientCfg, log.NewNopLogger())
+			require.NoError(t, err)
+
+			var statusCode int
+			if testData.otlp {
+				statusCode, err = c.WriteSeriesOTLP(ctx, series)
+			} else {
+				statusCode, err = c.WriteSeries(ctx, series)
+			}
+			assert.Equal(t, testData.expectedStatusCode, statusCode)
+			assert.Equal(t, []string{testData.expectedEncoding}, receivedEncodings)
+
+			if testData.expectedStatusCode != 200 {
+				require.Error(t, err)
+				assert.Empty(t, receivedBodies)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Len(t, receivedBodies, 1)
+			if testData.otlp {
+				req := pmetricotlp.NewExportRequest()
+				require.NoError(t, req.UnmarshalProto(receivedBodies[0]))
+				assert.Equal(t, 1, req.Metrics().DataPointCount())
+			} else {
+				var req prompb.WriteRequest
+				require.NoError(t, proto.Unmarshal(receivedBodies[0], &req))
+				assert.Equal(t, series, req.Timeseries)
+			}
+		})
+	}
+
+	t.Run("should fail on unsupported remote write compression", func(t *testing.T) {
+		invalidCfg := cfg
+		invalidCfg.RemoteWriteCompression = writeCompressionNone
+
+		_, err := NewClient(invalidCfg, log.NewNopLogger())
+		require.Error(t, err)
+	})
+
+	t.Run("should fail on unsupported OTLP write compression", func(t *testing.T) {
+		invalidCfg := cfg
+		invalidCfg.OTLPWriteCompression = writeCompressionSnappy
+
+		_, err := NewClient(invalidCfg, log.NewNopLogger())
+		require.Error(t, err)
+	})
+}
+
+func TestClient_QueryRange(t *testing.T) {
+	var (
+		receivedRequests []*http.Request
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		require.NoError(t, request.ParseForm())
+		receivedRequests = append(receivedRequests, request)
+
+		writer.WriteHeader(http.StatusOK)
+		_, err := writer.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[]}}`))
+		require.NoError(t, err)
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	c, err := NewClient(cfg, log.NewNopLogger())
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	t.Run("results cache not explicitly disabled", func(t *testing.T) {
+		receivedRequests = nil
+
+		_, err := c.QueryRange(ctx, "up", time.Unix(0, 0), time.Unix(1000, 0), 10)
+		require.NoError(t, err)
+
+		require.Len(t, receivedRequests, 1)
+		assert.Empty(t, receivedRequests[0].Header.Get("Cache-Control"))
+	})
+
+	t.Run("results cache disabled", func(t *testing.T) {
+		receivedRequests = nil
+
+		_, err := c.QueryRange(ctx, "up", time.Unix(0, 0), time.Unix(1000, 0), 10, WithResultsCacheEnabled(false))
+		require.NoError(t, err)
+
+		require.Len(t, receivedRequests, 1)
+		assert.Equal(t, "no-store", receivedRequests[0].Header.Get("Cache-Control"))
+	})
+
+	t.Run("zero or negative step", func(t *testing.T) {
+		for _, step := range []time.Duration{0, -10 * time.Second} {
+			receivedRequests = nil
+
+			_, err := c.QueryRange(ctx, "up", time.Unix(0, 0), time.Unix(1000, 0), step)
+			require.NoError(t, err)
+
+			// The step is passed as is, in order to let the server validate it.
+			require.Len(t, receivedRequests, 1)
+			assert.Equal(t, strconv.FormatFloat(step.Seconds(), 'f', -1, 64), receivedRequests[0].Form.Get("step"))
+		}
+	})
+}
+
+func TestClient_Query(t *testing.T) {
+	var (
+		receivedRequests []*http.Request
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		// The form is parsed while the request body can still be read.
+		require.NoError(t, request.ParseForm())
+		receivedRequests = append(receivedRequests, request)
+
+		writer.WriteHeader(http.StatusOK)
+		_, err := writer.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+		require.NoError(t, err)
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	c, err := NewClient(cfg, log.NewNopLogger())
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	t.Run("results cache not explicitly disabled", func(t *testing.T) {
+		receivedRequests = nil
+
+		_, err := c.Query(ctx, "up", time.Unix(0, 0))
+		require.NoError(t, err)
+
+		require.Len(t, receivedRequests, 1)
+		assert.Empty(t, receivedRequests[0].Header.Get("Cache-Control"))
+	})
+
+	t.Run("results cache disabled", func(t *testing.T) {
+		receivedRequests = nil
+
+		_, err := c.Query(ctx, "up", time.Unix(0, 0), WithResultsCacheEnabled(false))
+		require.NoError(t, err)
+
+		require.Len(t, receivedRequests, 1)
+		assert.Equal(t, "no-store", receivedRequests[0].Header.Get("Cache-Control"))
+	})
+
+	t.Run("lookback delta not set", func(t *testing.T) {
+		receivedRequests = nil
+
+		_, err := c.Query(ctx, "up", time.Unix(0, 0))
+		require.NoError(t, err)
+
+		require.Len(t, receivedRequests, 1)
+		assert.Equal(t, "up", receivedRequests[0].Form.Get("query"))
+		assert.Empty(t, receivedRequests[0].Form.Get("lookback_delta"))
+	})
+
+	t.Run("lookback delta set", func(t *testing.T) {
+		receivedRequests = nil
+
+		_, err := c.Query(ctx, "up", time.Unix(0, 0), WithLookbackDelta(90*time.Second))
+		require.NoError(t, err)
+
+		require.Len(t, receivedRequests, 1)
+		assert.Equal(t, "up", receivedRequests[0].Form.Get("query"))
+		assert.Equal(t, "90", receivedRequests[0].Form.Get("lookback_delta"))
+	})
+}
+
+func TestClient_QueryRelativeTime(t *testing.T) {
+	var (
+		nextStatusCode   = http.StatusOK
+		nextBody         = `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1000.5,"2"]}]}}`
+		receivedRequests []*http.Request
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		receivedRequests = append(receivedRequests, request)
+
+		writer.WriteHeader(nextStatusCode)
+		_, err := writer.Write([]byte(nextBody))
+		require.NoError(t, err)
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	c, err := NewClient(cfg, log.NewNopLogger())
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	t.Run("should send the relative time as is", func(t *testing.T) {
+		receivedRequests = nil
+
+		vector, err := c.QueryRelativeTime(ctx, "sum(up)", "now-5m", WithResultsCacheEnabled(false))
+		require.NoError(t, err)
+		assert.Equal(t, model.Vector{{Metric: model.Metric{}, Timestamp: model.TimeFromUnixNano(1000500 * int64(time.Millisecond)), Value: 2}}, vector)
+
+		require.Len(t, receivedRequests, 1)
+		assert.Equal(t, "/api/v1/query", receivedRequests[0].URL.Path)
+		assert.Equal(t, "sum(up)", receivedRequests[0].URL.Query().Get("query"))
+		assert.Equal(t, "now-5m", receivedRequests[0].URL.Query().Get("time"))
+		assert.Equal(t, "no-store", receivedRequests[0].Header.Get("Cache-Control"))
+		assert.Equal(t, "anonymous", receivedRequests[0].Header.Get("X-Scope-OrgID"))
+	})
+
+	t.Run("should return the status code of failed requests", func(t *testing.T) {
+		for statusCode, body := range map[int]string{
+			http.StatusBadRequest:          `{"status":"error","errorType":"bad_data","error":"invalid parameter \"time\""}`,
+			http.StatusTooManyRequests:     `too many requests`,
+			http.StatusInternalServerError: `internal error`,
+		} {
+			nextStatusCode = statusCode
+			nextBody = body
+
+			_, err := c.QueryRelativeTime(ctx, "sum(up)", "now-5m")
+			require.Error(t, err)
+			assert.Equal(t, strconv.Itoa(statusCode), queryErrorStatusCode(err))
+		}
+	})
+}
+
+func TestClient_ParquetStorage(t *testing.T) {
+	var (
+		receivedRequests        []*http.Request
+		receivedParquetRequests []*http.Request
+	)
+
+	handler := func(received *[]*http.Request) http.HandlerFunc {
+		return func(writer http.ResponseWriter, request *http.Request) {
+			*received = append(*received, request)
+
+			resultType := "vector"
+			if strings.HasSuffix(request.URL.Path, "/query_range") {
+				resultType = "matrix"
+			}
+
+			writer.WriteHeader(http.StatusOK)
+			_, err := writer.Write([]byte(`{"status":"success","data":{"resultType":"` + resultType + `","result":[]}}`))
+			require.NoError(t, err)
+		}
+	}
+
+	server := httptest.NewServer(handler(&receivedRequests))
+	t.Cleanup(server.Close)
+	parquetServer := httptest.NewServer(handler(&receivedParquetRequests))
+	t.Cleanup(parquetServer.Close)
+
+	newClient := func(t *testing.T, parquetEndpoint string) *Client {
+		cfg := ClientConfig{}
+		flagext.DefaultValues(&cfg)
+		require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+		require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL+"/prometheus"))
+		require.NoError(t, cfg.ParquetReadHeaders.Set("X-Query-Storage=parquet"))
+		if parquetEndpoint != "" {
+			require.NoError(t, cfg.ParquetReadEndpoint.Set(parquetEndpoint))
+		}
+
+		c, err := NewClient(cfg, log.NewNopLogger())
+		require.NoError(t, err)
+		return c
+	}
+
+	ctx := context.Background()
+
+	t.Run("should send the queries to the read endpoint if the Parquet storage is not selected", func(t *testing.T) {
+		receivedRequests, receivedParquetRequests = nil, nil
+		c := newClient(t, parquetServer.URL+"/parquet")
+
+		_, err := c.QueryRange(ctx, "up", time.Unix(0, 0), time.Unix(1000, 0), 10*time.Second, WithParquetStorage(false))
+		require.NoError(t, err)
+		_, err = c.Query(ctx, "up", time.Unix(0, 0))
+		require.NoError(t, err)
+
+		require.Len(t, receivedRequests, 2)
+		require.Empty(t, receivedParquetRequests)
+		assert.Equal(t, "/prometheus/api/v1/query_range", receivedRequests[0].URL.Path)
+		assert.Equal(t, "/prometheus/api/v1/query", receivedRequests[1].URL.Path)
+		for _, req := range receivedRequests {
+			assert.Empty(t, req.Header.Get("X-Query-Storage"))
+		}
+	})
+
+	t.Run("should send the queries to the Parquet read endpoint if the Parquet storage is selected", func(t *testing.T) {
+		receivedRequests, receivedParquetRequests = nil, nil
+		c := newClient(t, parquetServer.URL+"/parquet")
+
+		_, err := c.QueryRange(ctx, "up", time.Unix(0, 0), time.Unix(1000, 0), 10*time.Second, WithResultsCacheEnabled(false), WithParquetStorage(true))
+		require.NoError(t, err)
+		_, err = c.Query(ctx, "up", time.Unix(0, 0), WithParquetStorage(true))
+		require.NoError(t, err)
+
+		require.Empty(t, receivedRequests)
+		require.Len(t, receivedParquetRequests, 2)
+		assert.Equal(t, "/parquet/api/v1/query_range", receivedParquetRequests[0].URL.Path)
+		assert.Equal(t, "no-store", receivedParquetRequests[0].Header.Get("Cache-Control"))
+		assert.Equal(t, "/parquet/api/v1/query", receivedParquetRequests[1].URL.Path)
+		for _, req := range receivedParquetRequests {
+			assert.Equal(t, "parquet", req.Header.Get("X-Query-Storage"))
+			assert.Equal(t, "anonymous", req.Header.Get("X-Scope-OrgID"))
+		}
+	})
+
+	t.Run("should only add the Parquet read headers if the Parquet read endpoint is not set", func(t *testing.T) {
+		receivedRequests, receivedParquetRequests = nil, nil
+		c := newClient(t, "")
+
+		_, err := c.Query(ctx, "up", time.Unix(0, 0), WithParquetStorage(true))
+		require.NoError(t, err)
+
+		require.Len(t, receivedRequests, 1)
+		require.Empty(t, receivedParquetRequests)
+		assert.Equal(t, "/prometheus/api/v1/query", receivedRequests[0].URL.Path)
+		assert.Equal(t, "parquet", receivedRequests[0].Header.Get("X-Query-Storage"))
+	})
+
+	t.Run("should fail if the Parquet read headers are invalid", func(t *testing.T) {
+		cfg := ClientConfig{}
+		flagext.DefaultValues(&cfg)
+		require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+		require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+		require.NoError(t, cfg.ParquetReadHeaders.Set("X-Query-Storage"))
+
+		_, err := NewClient(cfg, log.NewNopLogger())
+		require.Error(t, err)
+	})
+}
+
+func TestClient_QueryExemplars(t *testing.T) {
+	var receivedRequests []*http.Request
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		receivedRequests = append(receivedRequests, request)
+
+		writer.WriteHeader(http.StatusOK)
+		_, err := writer.Write([]byte(`{"status":"success","data":[{"seriesLabels":{"__name__":"up"},"exemplars":[{"labels":{"trace_id":"abc"},"value":"1.5","timestamp":10}]}]}`))
+		require.NoError(t, err)
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	c, err := NewClient(cfg, log.NewNopLogger())
+	require.NoError(t, err)
+
+	results, err := c.QueryExemplars(context.Background(), "up", time.Unix(0, 0), time.Unix(20, 0))
+	require.NoError(t, err)
+
+	require.Len(t, receivedRequests, 1)
+	assert.Equal(t, "/api/v1/query_exemplars", receivedRequests[0].URL.Path)
+	assert.Equal(t, "up", receivedRequests[0].URL.Query().Get("query"))
+
+	assert.Equal(t, []v1.ExemplarQueryResult{{
+		SeriesLabels: model.LabelSet{"__name__": "up"},
+		Exemplars:    []v1.Exemplar{{Labels: model.LabelSet{"trace_id": "abc"}, Value: 1.5, Timestamp: 10000}},
+	}}, results)
+}
+
+func TestClient_BuildInfo(t *testing.T) {
+	var receivedRequests []*http.Request
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		receivedRequests = append(receivedRequests, request)
+
+		writer.WriteHeader(http.StatusOK)
+		_, err := writer.Write([]byte(`{"status":"success","data":{"application":"Mimir","version":"2.7.0","revision":"abc","branch":"main","goVersion":"go1.20"}}`))
+		require.NoError(t, err)
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	c, err := NewClient(cfg, log.NewNopLogger())
+	require.NoError(t, err)
+
+	info, err := c.BuildInfo(context.Background())
+	require.NoError(t, err)
+
+	require.Len(t, receivedRequests, 1)
+	assert.Equal(t, "/api/v1/status/buildinfo", receivedRequests[0].URL.Path)
+	assert.Equal(t, v1.BuildinfoResult{Version: "2.7.0", Revision: "abc", Branch: "main", GoVersion: "go1.20"}, info)
+}
+
+func TestClient_Metadata(t *testing.T) {
+	var receivedRequests []*http.Request
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		receivedRequests = append(receivedRequests, request)
+
+		writer.WriteHeader(http.StatusOK)
+		_, err := writer.Write([]byte(`{"status":"success","data":{"test":[{"type":"gauge","help":"Test metric.","unit":""}]}}`))
+		require.NoError(t, err)
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	c, err := NewClient(cfg, log.NewNopLogger())
+	require.NoError(t, err)
+
+	metadata, err := c.Metadata(context.Background(), "test")
+	require.NoError(t, err)
+
+	require.Len(t, receivedRequests, 1)
+	assert.Equal(t, "/api/v1/metadata", receivedRequests[0].URL.Path)
+	assert.Equal(t, "test", receivedRequests[0].URL.Query().Get("metric"))
+	assert.Equal(t, []v1.Metadata{{Type: v1.MetricTypeGauge, Help: "Test metric."}}, metadata)
+}
+
+func TestClient_WriteMetadata(t *testing.T) {
+	var (
+		receivedRequests []*http.Request
+		receivedBodies   []prompb.WriteRequest
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body, err := io.ReadAll(request.Body)
+		require.NoError(t, err)
+		require.NoError(t, request.Body.Close())
+
+		body, err = snappy.Decode(nil, body)
+		require.NoError(t, err)
+
+		var req prompb.WriteRequest
+		require.NoError(t, proto.Unmarshal(body, &req))
+		receivedRequests = append(receivedRequests, request)
+		receivedBodies = append(receivedBodies, req)
+
+		writer.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	metadata := []prompb.MetricMetadata{{Type: prompb.MetricMetadata_GAUGE, MetricFamilyName: "test", Help: "Test metric."}}
+
+	for _, version := range remoteWriteVersions {
+		t.Run("remote write version "+version, func(t *testing.T) {
+			receivedRequests = nil
+			receivedBodies = nil
+
+			cfg := ClientConfig{}
+			flagext.DefaultValues(&cfg)
+			cfg.RemoteWriteVersion = version
+			require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+			require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+			c, err := NewClient(cfg, log.NewNopLogger())
+			require.NoError(t, err)
+
+			statusCode, err := c.WriteMetadata(context.Background(), metadata)
+			require.NoError(t, err)
+			assert.Equal(t, 200, statusCode)
+
+			// The metadata is always written through the remote write 1.0 protocol.
+			require.Len(t, receivedRequests, 1)
+			assert.Equal(t, "/api/v1/push", receivedRequests[0].URL.Path)
+			assert.Equal(t, "application/x-protobuf", receivedRequests[0].Header.Get("Content-Type"))
+			assert.Empty(t, receivedBodies[0].Timeseries)
+			assert.Equal(t, metadata, receivedBodies[0].Metadata)
+		})
+	}
+}
+
+func TestClient_LabelValues(t *testing.T) {
+	var receivedRequests []*http.Request
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		require.NoError(t, request.ParseForm())
+		receivedRequests = append(receivedRequests, request)
+
+		writer.WriteHeader(http.StatusOK)
+		_, err := writer.Write([]byte(`{"status":"success","data":["0","1","2"]}`))
+		require.NoError(t, err)
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	c, err := NewClient(cfg, log.NewNopLogger())
+	require.NoError(t, err)
+
+	values, err := c.LabelValues(context.Background(), "series_id", []string{`{__name__="up"}`}, time.Unix(0, 0), time.Unix(20, 0))
+	require.NoError(t, err)
+
+	require.Len(t, receivedRequests, 1)
+	assert.Equal(t, "/api/v1/label/series_id/values", receivedRequests[0].URL.Path)
+	assert.Equal(t, []string{`{__name__="up"}`}, receivedRequests[0].Form["match[]"])
+	assert.Equal(t, "0", receivedRequests[0].Form.Get("start"))
+	assert.Equal(t, "20", receivedRequests[0].Form.Get("end"))
+
+	assert.Equal(t, model.LabelValues{"0", "1", "2"}, values)
+}
+
+func TestClient_Series(t *testing.T) {
+	var receivedRequests []*http.Request
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		require.NoError(t, request.ParseForm())
+		receivedRequests = append(receivedRequests, request)
+
+		writer.WriteHeader(http.StatusOK)
+		_, err := writer.Write([]byte(`{"status":"success","data":[{"__name__":"up","series_id":"0"},{"__name__":"up","series_id":"1"}]}`))
+		require.NoError(t, err)
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	c, err := NewClient(cfg, log.NewNopLogger())
+	require.NoError(t, err)
+
+	series, err := c.Series(context.Background(), []string{`{__name__="up"}`}, time.Unix(0, 0), time.Unix(20, 0))
+	require.NoError(t, err)
+
+	require.Len(t, receivedRequests, 1)
+	assert.Equal(t, "/api/v1/series", receivedRequests[0].URL.Path)
+	assert.Equal(t, []string{`{__name__="up"}`}, receivedRequests[0].Form["match[]"])
+	assert.Equal(t, "0", receivedRequests[0].Form.Get("start"))
+	assert.Equal(t, "20", receivedRequests[0].Form.Get("end"))
+
+	assert.Equal(t, []model.LabelSet{
+		{"__name__": "up", "series_id": "0"},
+		{"__name__": "up", "series_id": "1"},
+	}, series)
+}
+
+func TestClient_ReadSeries(t *testing.T) {
+	var (
+		nextStatusCode   = http.StatusOK
+		receivedRequests []prompb.ReadRequest
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		// Read the entire body.
+		body, err := io.ReadAll(request.Body)
+		require.NoError(t, err)
+		require.NoError(t, request.Body.Close())
+
+		// Decode and unmarshal it.
+		body, err = snappy.Decode(nil, body)
+		require.NoError(t, err)
+
+		var req prompb.ReadRequest
+		require.NoError(t, proto.Unmarshal(body, &req))
+		receivedRequests = append(receivedRequests, req)
+
+		if nextStatusCode != http.StatusOK {
+			writer.WriteHeader(nextStatusCode)
+			return
+		}
+
+		data, err := proto.Marshal(&prompb.ReadResponse{Results: []*prompb.QueryResult{{
+			Timeseries: []*prompb.TimeSeries{{
+				Labels:  []prompb.Label{{Name: "__name__", Value: "test"}, {Name: "series_id", Value: "0"}},
+				Samples: []prompb.Sample{{Timestamp: 1000, Value: 1}, {Timestamp: 2000, Value: 2}},
+			}},
+		}}})
+		require.NoError(t, err)
+
+		writer.Header().Set("Content-Type", "application/x-protobuf")
+		writer.Header().Set("Content-Encoding", "snappy")
+		_, err = writer.Write(snappy.Encode(nil, data))
+		require.NoError(t, err)
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	c, err := NewClient(cfg, log.NewNopLogger())
+	require.NoError(t, err)
+
+	matchers := []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "__name__", "test")}
+
+	t.Run("read succeeded", func(t *testing.T) {
+		receivedRequests = nil
+		nextStatusCode = http.StatusOK
+
+		matrix, err := c.ReadSeries(context.Background(), matchers, time.UnixMilli(1000), time.UnixMilli(2000))
+		require.NoError(t, err)
+		assert.Equal(t, model.Matrix{{
+			Metric: model.Metric{"__name__": "test", "series_id": "0"},
+			Values: []model.SamplePair{{Timestamp: 1000, Value: 1}, {Timestamp: 2000, Value: 2}},
+		}}, matrix)
+
+		require.Len(t, receivedRequests, 1)
+		require.Len(t, receivedRequests[0].Queries, 1)
+		assert.Equal(t, int64(1000), receivedRequests[0].Queries[0].StartTimestampMs)
+		assert.Equal(t, int64(2000), receivedRequests[0].Queries[0].EndTimestampMs)
+		assert.Equal(t, []*prompb.LabelMatcher{{Type: prompb.LabelMatcher_EQ, Name: "__name__", Value: "test"}}, receivedRequests[0].Queries[0].Matchers)
+	})
+
+	t.Run("read failed", func(t *testing.T) {
+		receivedRequests = nil
+		nextStatusCode = http.StatusInternalServerError
+
+		_, err := c.ReadSeries(context.Background(), matchers, time.UnixMilli(1000), time.UnixMilli(2000))
+		require.Error(t, err)
+		require.Len(t, receivedRequests, 1)
+	})
+}
+
+func TestClient_Cardinality(t *testing.T) {
+	var (
+		nextStatusCode   = http.StatusOK
+		receivedRequests []*http.Request
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		receivedRequests = append(receivedRequests, request)
+		if nextStatusCode != http.StatusOK {
+			writer.WriteHeader(nextStatusCode)
+			return
+		}
+
+		writer.Header().Set("Content-Type", "application/json")
+		switch request.URL.Path {
+		case "/api/v1/cardinality/label_names":
+			_, _ = writer.Write([]byte(`{"label_values_count_total":3,"label_names_count":2,"cardinality":[{"label_name":"series_id","label_values_count":2},{"label_name":"__name__","label_values_count":1}]}`))
+		case "/api/v1/cardinality/label_values":
+			_, _ = writer.Write([]byte(`{"series_count_total":2,"labels":[{"label_name":"series_id","label_values_count":2,"series_count":2,"cardinality":[{"label_value":"0","series_count":1},{"label_value":"1","series_count":1}]}]}`))
+		case "/api/v1/cardinality/active_series":
+			_, _ = writer.Write([]byte(`{"data":[{"__name__":"test","series_id":"0"},{"__name__":"test","series_id":"1"}]}`))
+		default:
+			writer.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	c, err := NewClient(cfg, log.NewNopLogger())
+	require.NoError(t, err)
+
+	t.Run("label names cardinality", func(t *testing.T) {
+		receivedRequests = nil
+		nextStatusCode = http.StatusOK
+
+		resp, err := c.LabelNamesCardinality(context.Background(), `{__name__="test"}`, 10)
+		require.NoError(t, err)
+		assert.Equal(t, &LabelNamesCardinalityResponse{
+			LabelValuesCountTotal: 3,
+			LabelNamesCount:       2,
+			Cardinality:           []LabelNamesCardinalityItem{{LabelName: "series_id", LabelValuesCount: 2}, {LabelName: "__name__", LabelValuesCount: 1}},
+		}, resp)
+
+		require.Len(t, receivedRequests, 1)
+		assert.Equal(t, "GET", receivedRequests[0].Method)
+		assert.Equal(t, `{__name__="test"}`, receivedRequests[0].URL.Query().Get("selector"))
+		assert.Equal(t, "10", receivedRequests[0].URL.Query().Get("limit"))
+		assert.Equal(t, "anonymous", receivedRequests[0].Header.Get("X-Scope-OrgID"))
+	})
+
+	t.Run("label values cardinality", func(t *testing.T) {
+		receivedRequests = nil
+		nextStatusCode = http.StatusOK
+
+		resp, err := c.LabelValuesCardinality(context.Background(), []string{"series_id", "__name__"}, "", 0)
+		require.NoError(t, err)
+		assert.Equal(t, &LabelValuesCardinalityResponse{
+			SeriesCountTotal: 2,
+			Labels: []LabelValuesCardinalityLabel{{
+				LabelName:        "series_id",
+				LabelValuesCount: 2,
+				SeriesCount:      2,
+				Cardinality:      []LabelValuesCardinalityItem{{LabelValue: "0", SeriesCount: 1}, {LabelValue: "1", SeriesCount: 1}},
+			}},
+		}, resp)
+
+		require.Len(t, receivedRequests, 1)
+		assert.Equal(t, []string{"series_id", "__name__"}, receivedRequests[0].URL.Query()["label_names[]"])
+		assert.False(t, receivedRequests[0].URL.Query().Has("selector"))
+		assert.Equal(t, "0", receivedRequests[0].URL.Query().Get("limit"))
+	})
+
+	t.Run("active series", func(t *testing.T) {
+		receivedRequests = nil
+		nextStatusCode = http.StatusOK
+
+		series, err := c.ActiveSeries(context.Background(), []string{`__name__="test"`, `series_id=~"[0-9]+"`})
+		require.NoError(t, err)
+		assert.Equal(t, []model.LabelSet{
+			{"__name__": "test", "series_id": "0"},
+			{"__name__": "test", "series_id": "1"},
+		}, series)
+
+		require.Len(t, receivedRequests, 1)
+		assert.Equal(t, "GET", receivedRequests[0].Method)
+		assert.Equal(t, `{__name__="test",series_id=~"[0-9]+"}`, receivedRequests[0].URL.Query().Get("selector"))
+
+		// The selector can't be empty.
+		_, err = c.ActiveSeries(context.Background(), nil)
+		require.Error(t, err)
+		require.Len(t, receivedRequests, 1)
+	})
+
+	t.Run("cardinality query failed", func(t *testing.T) {
+		receivedRequests = nil
+		nextStatusCode = http.StatusBadRequest
+
+		_, err := c.LabelValuesCardinality(context.Background(), []string{"series_id"}, "", 0)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "400")
+
+		_, err = c.LabelNamesCardinality(context.Background(), "", 0)
+		require.Error(t, err)
+		require.Len(t, receivedRequests, 2)
+	})
+}
+
+func TestClient_Flush(t *testing.T) {
+	var (
+		nextStatusCode   = http.StatusNoContent
+		receivedRequests []*http.Request
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		receivedRequests = append(receivedRequests, request)
+		writer.WriteHeader(nextStatusCode)
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	c, err := NewClient(cfg, log.NewNopLogger())
+	require.NoError(t, err)
+
+	t.Run("flush succeeded", func(t *testing.T) {
+		receivedRequests = nil
+		nextStatusCode = http.StatusNoContent
+
+		require.NoError(t, c.Flush(context.Background()))
+
+		require.Len(t, receivedRequests, 1)
+		assert.Equal(t, "POST", receivedRequests[0].Method)
+		assert.Equal(t, "/ingester/flush", receivedRequests[0].URL.Path)
+		assert.Equal(t, "true", receivedRequests[0].URL.Query().Get("wait"))
+		assert.Equal(t, "anonymous", receivedRequests[0].Header.Get("X-Scope-OrgID"))
+	})
+
+	t.Run("flush failed", func(t *testing.T) {
+		receivedRequests = nil
+		nextStatusCode = http.StatusNotFound
+
+		require.Error(t, c.Flush(context.Background()))
+		require.Len(t, receivedRequests, 1)
+	})
+}
+
+func TestClassifyQueryError(t *testing.T) {
+	tests := map[string]struct {
+		handler            http.HandlerFunc
+		timeout            time.Duration
+		expectedReason     string
+		expectedStatusCode string
+	}{
+		"request timed out": {
+			handler: func(writer http.ResponseWriter, request *http.Request) {
+				time.Sleep(100 * time.Millisecond)
+			},
+			timeout:            10 * time.Millisecond,
+			expectedReason:     queryErrorReasonTimeout,
+			expectedStatusCode: "0",
+		},
+		"query timed out on the server": {
+			handler: func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusServiceUnavailable)
+				_, _ = writer.Write([]byte(`{"status":"error","errorType":"timeout","error":"query timed out in expression evaluation"}`))
+			},
+			expectedReason:     queryErrorReasonTimeout,
+			expectedStatusCode: "503",
+		},
+		"query limit exceeded": {
+			handler: func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusUnprocessableEntity)
+				_, _ = writer.Write([]byte(`{"status":"error","errorType":"execution","error":"the query exceeded the maximum number of series (limit: 10) (err-mimir-max-series-per-query)"}`))
+			},
+			expectedReason:     queryErrorReasonLimitExceeded,
+			expectedStatusCode: "422",
+		},
+		"request rate limited": {
+			handler: func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusTooManyRequests)
+				_, _ = writer.Write([]byte("too many outstanding requests"))
+			},
+			expectedReason:     queryErrorReasonLimitExceeded,
+			expectedStatusCode: "429",
+		},
+		"query time range limit exceeded": {
+			handler: func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+				_, _ = writer.Write([]byte(`{"status":"error","errorType":"bad_data","error":"the query time range exceeds the limit (query length: 745h0m0s, limit: 720h0m0s) (err-mimir-max-query-length). To adjust the related per-tenant limit, configure -query-frontend.max-total-query-length, or contact your service administrator."}`))
+			},
+			expectedReason:     queryErrorReasonLimitExceeded,
+			expectedStatusCode: "400",
+		},
+		"server error": {
+			handler: func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusInternalServerError)
+				_, _ = writer.Write([]byte("internal error"))
+			},
+			expectedReason:     queryErrorReasonServerError,
+			expectedStatusCode: "500",
+		},
+		"server error mentioning a limit": {
+			handler: func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadGateway)
+				_, _ = writer.Write([]byte("upstream request rate limited"))
+			},
+			expectedReason:     queryErrorReasonServerError,
+			expectedStatusCode: "502",
+		},
+		"execution error mentioning a limit": {
+			handler: func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusUnprocessableEntity)
+				_, _ = writer.Write([]byte(`{"status":"error","errorType":"execution","error":"vector cannot contain metrics with the same labelset: mimir_limits_overrides"}`))
+			},
+			expectedReason:     queryErrorReasonOther,
+			expectedStatusCode: "422",
+		},
+		"invalid query": {
+			handler: func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+				_, _ = writer.Write([]byte(`{"status":"error","errorType":"bad_data","error":"1:5: parse error: unexpected end of input"}`))
+			},
+			expectedReason:     queryErrorReasonParse,
+			expectedStatusCode: "400",
+		},
+		"invalid query mentioning a limit": {
+			handler: func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusBadRequest)
+				_, _ = writer.Write([]byte(`{"status":"error","errorType":"bad_data","error":"1:1: parse error: unknown function with name \"limitk\""}`))
+			},
+			expectedReason:     queryErrorReasonParse,
+			expectedStatusCode: "400",
+		},
+		"malformed response": {
+			handler: func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusOK)
+				_, _ = writer.Write([]byte(`{"status":"success","data":`))
+			},
+			expectedReason:     queryErrorReasonParse,
+			expectedStatusCode: "0",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			server := httptest.NewServer(testData.handler)
+			t.Cleanup(server.Close)
+
+			cfg := ClientConfig{}
+			flagext.DefaultValues(&cfg)
+			require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+			require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+			if testData.timeout > 0 {
+				cfg.ReadTimeout = testData.timeout
+			}
+
+			c, err := NewClient(cfg, log.NewNopLogger())
+			require.NoError(t, err)
+
+			_, err = c.QueryRange(context.Background(), "up", time.Unix(0, 0), time.Unix(1000, 0), 10)
+			require.Error(t, err)
+			assert.Equal(t, testData.expectedReason, classifyQueryError(err))
+			assert.Equal(t, testData.expectedStatusCode, queryErrorStatusCode(err))
+
+			_, err = c.Query(context.Background(), "up", time.Unix(0, 0))
+			require.Error(t, err)
+			assert.Equal(t, testData.expectedReason, classifyQueryError(err))
+			assert.Equal(t, testData.expectedStatusCode, queryErrorStatusCode(err))
+		})
+	}
+
+	t.Run("network error", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		server.Close()
+
+		cfg := ClientConfig{}
+		flagext.DefaultValues(&cfg)
+		require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+		require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+		c, err := NewClient(cfg, log.NewNopLogger())
+		require.NoError(t, err)
+
+		_, err = c.Query(context.Background(), "up", time.Unix(0, 0))
+		require.Error(t, err)
+		assert.Equal(t, queryErrorReasonNetwork, classifyQueryError(err))
+		assert.Equal(t, "0", queryErrorStatusCode(err))
+	})
+}
+
+// ClientMock mocks MimirClient.
+type ClientMock struct {
+	mock.Mock
+}
+
+func (m *ClientMock) WriteSeries(ctx context.Context, series []prompb.TimeSeries) (int, error) {
+	args := m.Called(ctx, series)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *ClientMock) WriteSeriesOTLP(ctx context.Context, series []prompb.TimeSeries) (int, error) {
+	args := m.Called(ctx, series)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *ClientMock) QueryRange(ctx context.Context, query string, start, end time.Time, step time.Duration, options ...RequestOption) (model.Matrix, error) {
+	args := m.Called(ctx, query, start, end, step, options)
+	return args.Get(0).(model.Matrix), args.Error(1)
+}
+
+func (m *ClientMock) Query(ctx context.Context, query string, ts time.Time, options ...RequestOption) (model.Vector, error) {
+	args := m.Called(ctx, query, ts, options)
+	return args.Get(0).(model.Vector), args.Error(1)
+}
+
+func (m *ClientMock) WriteMetadata(ctx context.Context, metadata []prompb.MetricMetadata) (int, error) {
+	args := m.Called(ctx, metadata)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *ClientMock) Metadata(ctx context.Context, metric string) ([]v1.Metadata, error) {
+	args := m.Called(ctx, metric)
+	return args.Get(0).([]v1.Metadata), args.Error(1)
+}
+
+func (m *ClientMock) QueryRelativeTime(ctx context.Context, query string, relativeTime string, options ...RequestOption) (model.Vector, error) {
+	args := m.Called(ctx, query, relativeTime, options)
+	return args.Get(0).(model.Vector), args.Error(1)
+}
+
+func (m *ClientMock) ReadSeries(ctx context.Context, matchers []*labels.Matcher, start, end time.Time) (model.Matrix, error) {
+	args := m.Called(ctx, matchers, start, end)
+	return args.Get(0).(model.Matrix), args.Error(1)
+}
+
+func (m *ClientMock) QueryExemplars(ctx context.Context, query string, start, end time.Time) ([]v1.ExemplarQueryResult, error) {
+	args := m.Called(ctx, query, start, end)
+	return args.Get(0).([]v1.ExemplarQueryResult), args.Error(1)
+}
+
+func (m *ClientMock) LabelValues(ctx context.Context, name string, matchers []string, start, end time.Time) (model.LabelValues, error) {
+	args := m.Called(ctx, name, matchers, start, end)
+	return args.Get(0).(model.LabelValues), args.Error(1)
+}
+
+func (m *ClientMock) Series(ctx context.Context, matchers []string, start, end time.Time) ([]model.LabelSet, error) {
+	args := m.Called(ctx, matchers, start, end)
+	return args.Get(0).([]model.LabelSet), args.Error(1)
+}
+
+func (m *ClientMock) LabelNamesCardinality(ctx context.Context, selector string, limit int) (*LabelNamesCardinalityResponse, error) {
+	args := m.Called(ctx, selector, limit)
+	return args.Get(0).(*LabelNamesCardinalityResponse), args.Error(1)
+}
+
+func (m *ClientMock) LabelValuesCardinality(ctx context.Context, labelNames []string, selector string, limit int) (*LabelValuesCardinalityResponse, error) {
+	args := m.Called(ctx, labelNames, selector, limit)
+	return args.Get(0).(*LabelValuesCardinalityResponse), args.Error(1)
+}
+
+func (m *ClientMock) ActiveSeries(ctx context.Context, matchers []string) ([]model.LabelSet, error) {
+	args := m.Called(ctx, matchers)
+	return args.Get(0).([]model.LabelSet), args.Error(1)
+}
+
+func (m *ClientMock) Flush(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func (m *ClientMock) BuildInfo(ctx context.Context) (v1.BuildinfoResult, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(v1.BuildinfoResult), args.Error(1)
 }
@@ -0,0 +1,393 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/multierror"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/prompb"
+
+	"github.com/grafana/mimir/pkg/util/spanlogger"
+)
+
+const (
+	labelCardinalityTestName = "label-cardinality"
+
+	// The metric written by the label cardinality test, and the label whose values are checked.
+	labelCardinalityMetricName = "mimir_continuous_test_label_cardinality"
+	labelCardinalityLabelName  = "series_id"
+
+	// The label added to the written series to scope them to the configured number of values, so that the
+	// values written by previous runs with a different configuration are not returned by the check.
+	labelCardinalityNumValuesLabelName = "num_values"
+
+	// The label added to the written series when the cardinality API check is enabled, holding the start of the
+	// window the series have been written in.
+	labelCardinalityWindowLabelName = "cardinality_window"
+)
+
+type LabelCardinalityTestConfig struct {
+	Enabled        bool
+	NumValues      int
+	ValuesPerWrite int
+	WriteInterval  time.Duration
+
+	CardinalityAPICheckEnabled bool
+	CardinalityAPICheckWindow  time.Duration
+}
+
+func (cfg *LabelCardinalityTestConfig) RegisterFlags(f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, "tests.label-cardinality-test.enabled", false, "Run the label cardinality test, which writes series with a rotating set of series_id label values, and checks that the label values API returns exactly the written values.")
+	f.IntVar(&cfg.NumValues, "tests.label-cardinality-test.num-values", 100, "Number of distinct series_id label values written by the test. The values are written in rotation.")
+	f.IntVar(&cfg.ValuesPerWrite, "tests.label-cardinality-test.values-per-write", 10, "Number of series_id label values written at each write interval. The label values are checked once all values have been written within the checked time range.")
+	f.DurationVar(&cfg.WriteInterval, "tests.label-cardinality-test.write-interval", defaultWriteInterval, "How frequently series are written. Written samples timestamps are aligned to the interval.")
+	f.BoolVar(&cfg.CardinalityAPICheckEnabled, "tests.label-cardinality-test.cardinality-api-check-enabled", false, "Also check that the label values cardinality API reports exactly the configured number of series_id label values. The cardinality API requires cardinality analysis to be enabled for the tenant.")
+	f.DurationVar(&cfg.CardinalityAPICheckWindow, "tests.label-cardinality-test.cardinality-api-check-window", time.Hour, "The written series are labelled with the window they have been written in, and the cardinality API check only counts the series of the current window, because the cardinality API reads all the ingesters' in-memory series regardless of when they have been written. It must be a multiple of the write interval, and long enough to write all label values.")
+}
+
+// LabelCardinalityTest writes series with a known, rotating set of label values, and checks that the label values
+// API returns exactly the values written in the queried time range, in order to catch label values silently
+// dropped by the index.
+type LabelCardinalityTest struct {
+	name    string
+	cfg     LabelCardinalityTestConfig
+	client  MimirClient
+	logger  log.Logger
+	metrics *TestMetrics
+
+	// The series selector used to scope the label values to the series written by the test.
+	seriesSelector string
+
+	lastWrittenTimestamp time.Time
+
+	// The timestamp of the oldest interval written without gaps up to the last written timestamp.
+	writtenMinTime time.Time
+}
+
+func NewLabelCardinalityTest(cfg LabelCardinalityTestConfig, client MimirClient, logger log.Logger, reg prometheus.Registerer) (*LabelCardinalityTest, error) {
+	return newLabelCardinalityTest(labelCardinalityTestName, "", cfg, client, logger, reg)
+}
+
+// NewLabelCardinalityTestForEndpoint is like NewLabelCardinalityTest, but the endpoint is part of the test name,
+// so that each endpoint is tracked independently in metrics and logs.
+func NewLabelCardinalityTestForEndpoint(cfg LabelCardinalityTestConfig, endpoint string, client MimirClient, logger log.Logger, reg prometheus.Registerer) (*LabelCardinalityTest, error) {
+	return newLabelCardinalityTest(labelCardinalityTestName+"-"+endpoint, "", cfg, client, logger, reg)
+}
+
+// NewLabelCardinalityTestForTenant is like NewLabelCardinalityTest, but the input client must be bound to the
+// tenant, and the tenant ID is added as a label to the test metrics.
+func NewLabelCardinalityTestForTenant(cfg LabelCardinalityTestConfig, tenantID string, client MimirClient, logger log.Logger, reg prometheus.Registerer) (*LabelCardinalityTest, error) {
+	return newLabelCardinalityTest(labelCardinalityTestName, tenantID, cfg, client, logger, reg)
+}
+
+func newLabelCardinalityTest(name, tenantID string, cfg LabelCardinalityTestConfig, client MimirClient, logger log.Logger, reg prometheus.Registerer) (*LabelCardinalityTest, error) {
+	if cfg.WriteInterval <= 0 {
+		return nil, errors.New("the write interval must be greater than 0")
+	}
+	if cfg.NumValues <= 0 {
+		return nil, errors.New("the number of label values must be greater than 0")
+	}
+	if cfg.ValuesPerWrite <= 0 || cfg.ValuesPerWrite > cfg.NumValues {
+		return nil, fmt.Errorf("the number of label values per write must be greater than 0 and not greater than the number of label values (%d)", cfg.NumValues)
+	}
+	if cfg.CardinalityAPICheckEnabled {
+		rotation := time.Duration((cfg.NumValues+cfg.ValuesPerWrite-1)/cfg.ValuesPerWrite) * cfg.WriteInterval
+		if cfg.CardinalityAPICheckWindow < rotation || cfg.CardinalityAPICheckWindow%cfg.WriteInterval != 0 {
+			return nil, fmt.Errorf("the cardinality API check window must be a multiple of the write interval and at least the time it takes to write all label values (%s)", rotation)
+		}
+	}
+
+	var metrics *TestMetrics
+	if tenantID != "" {
+		metrics = NewTenantTestMetrics(name, tenantID, reg)
+		logger = log.With(logger, "tenant", tenantID)
+	} else {
+		metrics = NewTestMetrics(name, reg)
+	}
+
+	return &LabelCardinalityTest{
+		name:    name,
+		cfg:     cfg,
+		client:  client,
+		logger:  log.With(logger, "test", name),
+		metrics: metrics,
+		seriesSelector: seriesSelector(labelCardinalityMetricName, []prompb.Label{{
+			Name:  labelCardinalityNumValuesLabelName,
+			Value: strconv.Itoa(cfg.NumValues),
+		}}),
+	}, nil
+}
+
+// Name implements Test.
+func (t *LabelCardinalityTest) Name() string {
+	return t.name
+}
+
+// Init implements Test. The previously written series are not recovered, because the label values are checked only
+// once all of them have been written by the current run of the testing tool.
+func (t *LabelCardinalityTest) Init(context.Context, time.Time) error {
+	return nil
+}
+
+// Run implements Test.
+func (t *LabelCardinalityTest) Run(ctx context.Context, now time.Time) error {
+	// Restart writing from the current interval if the last written one is too old to catch up with.
+	if !t.lastWrittenTimestamp.IsZero() && t.lastWrittenTimestamp.Before(now.Add(-writeMaxAge)) {
+		t.lastWrittenTimestamp = time.Time{}
+		t.writtenMinTime = time.Time{}
+	}
+
+	// Collect all errors on this test run
+	errs := new(multierror.MultiError)
+
+	for timestamp := t.nextWriteTimestamp(now); !timestamp.After(now); timestamp = t.nextWriteTimestamp(now) {
+		if err := t.writeSeries(ctx, timestamp); err != nil {
+			errs.Add(err)
+
+			// Keep writing the next intervals if the write has been rejected, because retrying it isn't
+			// expected to succeed.
+			if !errors.Is(err, errWriteRejected) {
+				return errs.Err()
+			}
+		}
+	}
+
+	errs.Add(t.runLabelValuesCheck(ctx))
+	if t.cfg.CardinalityAPICheckEnabled {
+		errs.Add(t.runCardinalityAPICheck(ctx))
+	}
+	return errs.Err()
+}
+
+// writeSeries writes the series with the label values rotated in at the input timestamp.
+func (t *LabelCardinalityTest) writeSeries(ctx context.Context, timestamp time.Time) error {
+	sp, ctx := spanlogger.NewWithLogger(ctx, t.logger, "LabelCardinalityTest.writeSeries")
+	defer sp.Finish()
+	logger := log.With(sp, "timestamp", timestamp.String(), "num_series", t.cfg.ValuesPerWrite)
+
+	series := make([]prompb.TimeSeries, 0, t.cfg.ValuesPerWrite)
+	for _, value := range t.labelValuesAt(timestamp) {
+		lbls := make([]prompb.Label, 0, 4)
+		lbls = append(lbls, prompb.Label{Name: "__name__", Value: labelCardinalityMetricName})
+		if t.cfg.CardinalityAPICheckEnabled {
+			lbls = append(lbls, prompb.Label{Name: labelCardinalityWindowLabelName, Value: t.windowAt(timestamp)})
+		}
+		lbls = append(lbls,
+			prompb.Label{Name: labelCardinalityNumValuesLabelName, Value: strconv.Itoa(t.cfg.NumValues)},
+			prompb.Label{Name: labelCardinalityLabelName, Value: value},
+		)
+
+		series = append(series, prompb.TimeSeries{
+			Labels:  lbls,
+			Samples: []prompb.Sample{{Value: 1, Timestamp: timestamp.UnixMilli()}},
+		})
+	}
+
+	statusCode, err := t.client.WriteSeries(ctx, series)
+
+	t.metrics.writesTotal.Inc()
+	t.metrics.writeSamplesTotal.Add(float64(countSamples(series)))
+	if statusCode/100 != 2 {
+		t.metrics.writesFailedTotal.WithLabelValues(strconv.Itoa(statusCode)).Inc()
+		level.Warn(logger).Log("msg", "Failed to remote write series", "status_code", statusCode, "err", err)
+	}
+
+	// If the write request failed because of a 4xx error, we keep writing the next interval, but the written
+	// label values have a gap, so we wait for all label values to be written again before checking them.
+	if statusCode/100 == 4 {
+		t.lastWrittenTimestamp = timestamp
+		t.writtenMinTime = time.Time{}
+		return errors.Wrapf(errWriteRejected, "remote write series failed with status code %d: %v", statusCode, err)
+	}
+
+	// If the write request failed because of a network or 5xx error, we'll retry to write series
+	// in the next test run.
+	if err != nil {
+		return errors.Wrap(err, "failed to remote write series")
+	}
+	if statusCode/100 != 2 {
+		return errors.Wrapf(err, "remote write series failed with status code %d", statusCode)
+	}
+
+	t.lastWrittenTimestamp = timestamp
+	if t.writtenMinTime.IsZero() {
+		t.writtenMinTime = timestamp
+	}
+	return nil
+}
+
+// runLabelValuesCheck queries the label values over the time range covering the last full rotation of the written
+// label values, and checks that the returned values exactly match the configured ones. The check is skipped until
+// a full rotation has been written without gaps.
+func (t *LabelCardinalityTest) runLabelValuesCheck(ctx context.Context) error {
+	if t.writtenMinTime.IsZero() {
+		return nil
+	}
+
+	end := t.lastWrittenTimestamp
+	start := end.Add(-time.Duration(t.rotationIntervals()-1) * t.cfg.WriteInterval)
+	if t.writtenMinTime.After(start) {
+		level.Debug(t.logger).Log("msg", "Skipped label values check because not all label values have been written yet", "written_min_time", t.writtenMinTime, "start", start)
+		return nil
+	}
+
+	sp, ctx := spanlogger.NewWithLogger(ctx, t.logger, "LabelCardinalityTest.runLabelValuesCheck")
+	defer sp.Finish()
+
+	logger := log.With(sp, "label", labelCardinalityLabelName, "matcher", t.seriesSelector, "start", start.UnixMilli(), "end", end.UnixMilli())
+	level.Debug(logger).Log("msg", "Running label values query")
+
+	t.metrics.queriesTotal.Inc()
+	values, err := t.client.LabelValues(ctx, labelCardinalityLabelName, []string{t.seriesSelector}, start, end)
+	if err != nil {
+		t.metrics.queriesFailedTotal.WithLabelValues(classifyQueryError(err), queryErrorStatusCode(err)).Inc()
+		level.Warn(logger).Log("msg", "Failed to execute label values query", "err", err)
+		return errors.Wrap(err, "failed to execute label values query")
+	}
+
+	checksTotal, checksFailedTotal := t.metrics.queryResultCheckCounters(readPathQueryAPI, storageDefault, queryTypeRange)
+	checksTotal.Inc()
+
+	missing, unexpected := diffLabelValues(t.expectedLabelValues(), values)
+	if len(missing) > 0 || len(unexpected) > 0 {
+		checksFailedTotal.Inc()
+		t.metrics.labelValuesMismatchesTotal.Add(float64(len(missing) + len(unexpected)))
+		level.Warn(logger).Log("msg", "Label values check failed", "missing", strings.Join(missing, ","), "unexpected", strings.Join(unexpected, ","))
+		return fmt.Errorf("label values check failed: %d label values are missing (%s) and %d are unexpected (%s)", len(missing), strings.Join(missing, ","), len(unexpected), strings.Join(unexpected, ","))
+	}
+	return nil
+}
+
+// runCardinalityAPICheck checks that the label values cardinality API reports exactly the configured number of
+// label values, and one series for each of them. The cardinality API reads the ingesters' in-memory series, which
+// may include series written long before the ones still being written, so the check is scoped to the series written
+// in the current window, and skipped until a full rotation has been written within it without gaps.
+func (t *LabelCardinalityTest) runCardinalityAPICheck(ctx context.Context) error {
+	const checkName = "cardinality_api"
+
+	if t.writtenMinTime.IsZero() {
+		return nil
+	}
+
+	end := t.lastWrittenTimestamp
+	start := end.Add(-time.Duration(t.rotationIntervals()-1) * t.cfg.WriteInterval)
+	if t.writtenMinTime.After(start) || alignTimestampToInterval(start, t.cfg.CardinalityAPICheckWindow).Before(alignTimestampToInterval(end, t.cfg.CardinalityAPICheckWindow)) {
+		level.Debug(t.logger).Log("msg", "Skipped cardinality API check because not all label values have been written in the current window yet", "written_min_time", t.writtenMinTime, "start", start)
+		return nil
+	}
+
+	sp, ctx := spanlogger.NewWithLogger(ctx, t.logger, "LabelCardinalityTest.runCardinalityAPICheck")
+	defer sp.Finish()
+
+	selector := seriesSelector(labelCardinalityMetricName, []prompb.Label{
+		{Name: labelCardinalityWindowLabelName, Value: t.windowAt(end)},
+		{Name: labelCardinalityNumValuesLabelName, Value: strconv.Itoa(t.cfg.NumValues)},
+	})
+	logger := log.With(sp, "label", labelCardinalityLabelName, "selector", selector)
+	level.Debug(logger).Log("msg", "Running label values cardinality query")
+
+	// Only the label values count and the series count are checked, so no label values are requested.
+	t.metrics.queriesTotal.Inc()
+	resp, err := t.client.LabelValuesCardinality(ctx, []string{labelCardinalityLabelName}, selector, 0)
+	if err != nil {
+		t.metrics.queriesFailedTotal.WithLabelValues(classifyQueryError(err), queryErrorStatusCode(err)).Inc()
+		level.Warn(logger).Log("msg", "Failed to execute label values cardinality query", "err", err)
+		return errors.Wrap(err, "failed to execute label values cardinality query")
+	}
+
+	checksTotal, checksFailedTotal := t.metrics.additionalCheckCounters(checkName)
+	checksTotal.Inc()
+
+	var valuesCount, seriesCount uint64
+	for _, l := range resp.Labels {
+		if l.LabelName == labelCardinalityLabelName {
+			valuesCount, seriesCount = l.LabelValuesCount, l.SeriesCount
+		}
+	}
+
+	expected := uint64(t.cfg.NumValues)
+	if valuesCount != expected || seriesCount != expected {
+		checksFailedTotal.Inc()
+		level.Warn(logger).Log("msg", "Cardinality API check failed", "expected", expected, "label_values_count", valuesCount, "series_count", seriesCount)
+		return fmt.Errorf("cardinality API check failed: expected %d label values and series, but got %d label values and %d series", expected, valuesCount, seriesCount)
+	}
+	return nil
+}
+
+// windowAt returns the value of the cardinality window label of the series written at the input timestamp.
+func (t *LabelCardinalityTest) windowAt(timestamp time.Time) string {
+	return strconv.FormatInt(alignTimestampToInterval(timestamp, t.cfg.CardinalityAPICheckWindow).Unix(), 10)
+}
+
+func (t *LabelCardinalityTest) nextWriteTimestamp(now time.Time) time.Time {
+	if t.lastWrittenTimestamp.IsZero() {
+		return alignTimestampToInterval(now, t.cfg.WriteInterval)
+	}
+
+	return t.lastWrittenTimestamp.Add(t.cfg.WriteInterval)
+}
+
+// labelValuesAt returns the label values written at the input interval-aligned timestamp. Consecutive intervals
+// write consecutive label values, wrapping around once all configured values have been written.
+func (t *LabelCardinalityTest) labelValuesAt(timestamp time.Time) []string {
+	interval := timestamp.UnixNano() / t.cfg.WriteInterval.Nanoseconds()
+	first := (interval * int64(t.cfg.ValuesPerWrite)) % int64(t.cfg.NumValues)
+
+	values := make([]string, 0, t.cfg.ValuesPerWrite)
+	for i := int64(0); i < int64(t.cfg.ValuesPerWrite); i++ {
+		values = append(values, strconv.FormatInt((first+i)%int64(t.cfg.NumValues), 10))
+	}
+	return values
+}
+
+// rotationIntervals returns the number of consecutive intervals it takes to write all configured label values.
+func (t *LabelCardinalityTest) rotationIntervals() int {
+	return (t.cfg.NumValues + t.cfg.ValuesPerWrite - 1) / t.cfg.ValuesPerWrite
+}
+
+func (t *LabelCardinalityTest) expectedLabelValues() []string {
+	values := make([]string, 0, t.cfg.NumValues)
+	for i := 0; i < t.cfg.NumValues; i++ {
+		values = append(values, strconv.Itoa(i))
+	}
+	return values
+}
+
+// diffLabelValues returns the sorted expected values which are not in actual, and the sorted actual values which
+// are not expected.
+func diffLabelValues(expected []string, actual model.LabelValues) (missing, unexpected []string) {
+	expectedSet := make(map[string]struct{}, len(expected))
+	for _, v := range expected {
+		expectedSet[v] = struct{}{}
+	}
+
+	actualSet := make(map[string]struct{}, len(actual))
+	for _, v := range actual {
+		actualSet[string(v)] = struct{}{}
+		if _, ok := expectedSet[string(v)]; !ok {
+			unexpected = append(unexpected, string(v))
+		}
+	}
+
+	for _, v := range expected {
+		if _, ok := actualSet[v]; !ok {
+			missing = append(missing, v)
+		}
+	}
+
+	sort.Strings(missing)
+	sort.Strings(unexpected)
+	return missing, unexpected
+}
@@ -0,0 +1,318 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/flagext"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLabelCardinalityTest(t *testing.T) {
+	tests := map[string]struct {
+		numValues      int
+		valuesPerWrite int
+		expectedErr    bool
+	}{
+		"should succeed if values per write is lower than the number of values": {
+			numValues:      10,
+			valuesPerWrite: 3,
+		},
+		"should succeed if values per write is equal to the number of values": {
+			numValues:      10,
+			valuesPerWrite: 10,
+		},
+		"should fail if values per write is greater than the number of values": {
+			numValues:      10,
+			valuesPerWrite: 11,
+			expectedErr:    true,
+		},
+		"should fail if values per write is 0": {
+			numValues:      10,
+			valuesPerWrite: 0,
+			expectedErr:    true,
+		},
+		"should fail if the number of values is 0": {
+			numValues:      0,
+			valuesPerWrite: 0,
+			expectedErr:    true,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			cfg := LabelCardinalityTestConfig{}
+			flagext.DefaultValues(&cfg)
+			cfg.NumValues = testData.numValues
+			cfg.ValuesPerWrite = testData.valuesPerWrite
+
+			_, err := NewLabelCardinalityTest(cfg, &ClientMock{}, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+			if testData.expectedErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestLabelCardinalityTest_Run(t *testing.T) {
+	const expectedSelector = `mimir_continuous_test_label_cardinality{num_values="4"}`
+
+	cfg := LabelCardinalityTestConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.Enabled = true
+	cfg.NumValues = 4
+	cfg.ValuesPerWrite = 2
+	cfg.WriteInterval = 20 * time.Second
+
+	now := time.Unix(1000, 0)
+	writtenLabelValues := func(series []prompb.TimeSeries) (values []string) {
+		for _, s := range series {
+			assert.Equal(t, labelCardinalityMetricName, s.Labels[0].Value)
+			assert.Equal(t, "4", s.Labels[1].Value)
+			values = append(values, s.Labels[2].Value)
+		}
+		return values
+	}
+
+	t.Run("should check the label values once all values have been written", func(t *testing.T) {
+		client := &ClientMock{}
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+		client.On("LabelValues", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.LabelValues{"0", "1", "2", "3"}, nil)
+
+		reg := prometheus.NewPedanticRegistry()
+		test, err := NewLabelCardinalityTest(cfg, client, log.NewNopLogger(), reg)
+		require.NoError(t, err)
+
+		// The first run writes only half of the values, so they're not checked yet.
+		require.NoError(t, test.Run(context.Background(), now))
+		client.AssertNumberOfCalls(t, "WriteSeries", 1)
+		client.AssertNumberOfCalls(t, "LabelValues", 0)
+		assert.Equal(t, []string{"0", "1"}, writtenLabelValues(client.Calls[0].Arguments.Get(1).([]prompb.TimeSeries)))
+
+		// The second run writes the other half of the values, and then checks them.
+		require.NoError(t, test.Run(context.Background(), now.Add(cfg.WriteInterval)))
+		client.AssertNumberOfCalls(t, "WriteSeries", 2)
+		assert.Equal(t, []string{"2", "3"}, writtenLabelValues(client.Calls[1].Arguments.Get(1).([]prompb.TimeSeries)))
+		client.AssertNumberOfCalls(t, "LabelValues", 1)
+		client.AssertCalled(t, "LabelValues", mock.Anything, "series_id", []string{expectedSelector}, now, now.Add(cfg.WriteInterval))
+
+		assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+			# HELP mimir_continuous_test_query_result_checks_total Total number of query results checked for correctness.
+			# TYPE mimir_continuous_test_query_result_checks_total counter
+			mimir_continuous_test_query_result_checks_total{query_type="instant",read_path="query_api",storage="default",test="label-cardinality"} 0
+			mimir_continuous_test_query_result_checks_total{query_type="range",read_path="query_api",storage="default",test="label-cardinality"} 1
+
+			# HELP mimir_continuous_test_query_result_checks_failed_total Total number of query results failed when checking for correctness.
+			# TYPE mimir_continuous_test_query_result_checks_failed_total counter
+			mimir_continuous_test_query_result_checks_failed_total{query_type="instant",read_path="query_api",storage="default",test="label-cardinality"} 0
+			mimir_continuous_test_query_result_checks_failed_total{query_type="range",read_path="query_api",storage="default",test="label-cardinality"} 0
+
+			# HELP mimir_continuous_test_label_values_mismatches_total Total number of label values missing from or unexpectedly returned by the label values API.
+			# TYPE mimir_continuous_test_label_values_mismatches_total counter
+			mimir_continuous_test_label_values_mismatches_total{test="label-cardinality"} 0
+		`), "mimir_continuous_test_query_result_checks_total", "mimir_continuous_test_query_result_checks_failed_total", "mimir_continuous_test_label_values_mismatches_total"))
+	})
+
+	t.Run("should fail if the returned label values don't exactly match the written ones", func(t *testing.T) {
+		client := &ClientMock{}
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+		client.On("LabelValues", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.LabelValues{"0", "1", "2", "5"}, nil)
+
+		reg := prometheus.NewPedanticRegistry()
+		test, err := NewLabelCardinalityTest(cfg, client, log.NewNopLogger(), reg)
+		require.NoError(t, err)
+
+		require.NoError(t, test.Run(context.Background(), now))
+		err = test.Run(context.Background(), now.Add(cfg.WriteInterval))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "1 label values are missing (3) and 1 are unexpected (5)")
+
+		assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+			# HELP mimir_continuous_test_query_result_checks_failed_total Total number of query results failed when checking for correctness.
+			# TYPE mimir_continuous_test_query_result_checks_failed_total counter
+			mimir_continuous_test_query_result_checks_failed_total{query_type="instant",read_path="query_api",storage="default",test="label-cardinality"} 0
+			mimir_continuous_test_query_result_checks_failed_total{query_type="range",read_path="query_api",storage="default",test="label-cardinality"} 1
+
+			# HELP mimir_continuous_test_label_values_mismatches_total Total number of label values missing from or unexpectedly returned by the label values API.
+			# TYPE mimir_continuous_test_label_values_mismatches_total counter
+			mimir_continuous_test_label_values_mismatches_total{test="label-cardinality"} 2
+		`), "mimir_continuous_test_query_result_checks_failed_total", "mimir_continuous_test_label_values_mismatches_total"))
+	})
+
+	t.Run("should not check the label values if a write has been rejected within the checked time range", func(t *testing.T) {
+		client := &ClientMock{}
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(400, errors.New("bad request")).Once()
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+
+		test, err := NewLabelCardinalityTest(cfg, client, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+		require.NoError(t, err)
+
+		require.Error(t, test.Run(context.Background(), now))
+		require.NoError(t, test.Run(context.Background(), now.Add(cfg.WriteInterval)))
+		client.AssertNumberOfCalls(t, "WriteSeries", 2)
+		client.AssertNumberOfCalls(t, "LabelValues", 0)
+	})
+
+	t.Run("should retry the write in the next run if it failed with a 5xx error", func(t *testing.T) {
+		client := &ClientMock{}
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(500, errors.New("internal error")).Once()
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+
+		test, err := NewLabelCardinalityTest(cfg, client, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+		require.NoError(t, err)
+
+		require.Error(t, test.Run(context.Background(), now))
+		client.AssertNumberOfCalls(t, "WriteSeries", 1)
+
+		require.NoError(t, test.Run(context.Background(), now))
+		client.AssertNumberOfCalls(t, "WriteSeries", 2)
+		assert.Equal(t, []string{"0", "1"}, writtenLabelValues(client.Calls[1].Arguments.Get(1).([]prompb.TimeSeries)))
+	})
+}
+
+func TestLabelCardinalityTest_CardinalityAPICheck(t *testing.T) {
+	cfg := LabelCardinalityTestConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.Enabled = true
+	cfg.NumValues = 4
+	cfg.ValuesPerWrite = 2
+	cfg.WriteInterval = 20 * time.Second
+	cfg.CardinalityAPICheckEnabled = true
+	cfg.CardinalityAPICheckWindow = 2 * time.Minute
+
+	// The window starting at 960s spans the intervals between 960s and 1060s.
+	now := time.Unix(960, 0)
+	const expectedSelector = `mimir_continuous_test_label_cardinality{cardinality_window="960",num_values="4"}`
+
+	cardinalityResponse := func(valuesCount, seriesCount uint64) *LabelValuesCardinalityResponse {
+		return &LabelValuesCardinalityResponse{
+			SeriesCountTotal: seriesCount,
+			Labels:           []LabelValuesCardinalityLabel{{LabelName: "series_id", LabelValuesCount: valuesCount, SeriesCount: seriesCount}},
+		}
+	}
+
+	t.Run("should fail validation if the window is shorter than a full rotation", func(t *testing.T) {
+		cfg := cfg
+		cfg.CardinalityAPICheckWindow = 20 * time.Second
+
+		_, err := NewLabelCardinalityTest(cfg, &ClientMock{}, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+		require.Error(t, err)
+	})
+
+	t.Run("should fail validation if the window is not a multiple of the write interval", func(t *testing.T) {
+		cfg := cfg
+		cfg.CardinalityAPICheckWindow = 50 * time.Second
+
+		_, err := NewLabelCardinalityTest(cfg, &ClientMock{}, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+		require.Error(t, err)
+	})
+
+	t.Run("should check the cardinality of the series written in the current window", func(t *testing.T) {
+		client := &ClientMock{}
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+		client.On("LabelValues", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.LabelValues{"0", "1", "2", "3"}, nil)
+		client.On("LabelValuesCardinality", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(cardinalityResponse(4, 4), nil)
+
+		reg := prometheus.NewPedanticRegistry()
+		test, err := NewLabelCardinalityTest(cfg, client, log.NewNopLogger(), reg)
+		require.NoError(t, err)
+
+		require.NoError(t, test.Run(context.Background(), now))
+		client.AssertNumberOfCalls(t, "LabelValuesCardinality", 0)
+
+		require.NoError(t, test.Run(context.Background(), now.Add(cfg.WriteInterval)))
+		client.AssertNumberOfCalls(t, "LabelValuesCardinality", 1)
+		client.AssertCalled(t, "LabelValuesCardinality", mock.Anything, []string{"series_id"}, expectedSelector, 0)
+
+		// The written series are labelled with their window.
+		for _, s := range client.Calls[0].Arguments.Get(1).([]prompb.TimeSeries) {
+			assert.Equal(t, prompb.Label{Name: "cardinality_window", Value: "960"}, s.Labels[1])
+		}
+
+		assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+			# HELP mimir_continuous_test_additional_checks_total Total number of additional (opt-in) checks run.
+			# TYPE mimir_continuous_test_additional_checks_total counter
+			mimir_continuous_test_additional_checks_total{check="cardinality_api",test="label-cardinality"} 1
+
+			# HELP mimir_continuous_test_additional_checks_failed_total Total number of additional (opt-in) checks failed.
+			# TYPE mimir_continuous_test_additional_checks_failed_total counter
+			mimir_continuous_test_additional_checks_failed_total{check="cardinality_api",test="label-cardinality"} 0
+		`), "mimir_continuous_test_additional_checks_total", "mimir_continuous_test_additional_checks_failed_total"))
+	})
+
+	t.Run("should not check the cardinality until a full rotation has been written in the current window", func(t *testing.T) {
+		client := &ClientMock{}
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+		client.On("LabelValues", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.LabelValues{"0", "1", "2", "3"}, nil)
+		client.On("LabelValuesCardinality", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(cardinalityResponse(4, 4), nil)
+
+		test, err := NewLabelCardinalityTest(cfg, client, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+		require.NoError(t, err)
+
+		// The rotation straddles the window boundary at 1080s.
+		require.NoError(t, test.Run(context.Background(), time.Unix(1060, 0)))
+		require.NoError(t, test.Run(context.Background(), time.Unix(1080, 0)))
+		client.AssertNumberOfCalls(t, "LabelValues", 1)
+		client.AssertNumberOfCalls(t, "LabelValuesCardinality", 0)
+
+		require.NoError(t, test.Run(context.Background(), time.Unix(1100, 0)))
+		client.AssertNumberOfCalls(t, "LabelValuesCardinality", 1)
+		client.AssertCalled(t, "LabelValuesCardinality", mock.Anything, []string{"series_id"}, `mimir_continuous_test_label_cardinality{cardinality_window="1080",num_values="4"}`, 0)
+	})
+
+	t.Run("should fail if the cardinality API reports a different number of label values", func(t *testing.T) {
+		client := &ClientMock{}
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+		client.On("LabelValues", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.LabelValues{"0", "1", "2", "3"}, nil)
+		client.On("LabelValuesCardinality", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(cardinalityResponse(3, 3), nil)
+
+		reg := prometheus.NewPedanticRegistry()
+		test, err := NewLabelCardinalityTest(cfg, client, log.NewNopLogger(), reg)
+		require.NoError(t, err)
+
+		require.NoError(t, test.Run(context.Background(), now))
+		err = test.Run(context.Background(), now.Add(cfg.WriteInterval))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "expected 4 label values and series, but got 3 label values and 3 series")
+
+		assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+			# HELP mimir_continuous_test_additional_checks_failed_total Total number of additional (opt-in) checks failed.
+			# TYPE mimir_continuous_test_additional_checks_failed_total counter
+			mimir_continuous_test_additional_checks_failed_total{check="cardinality_api",test="label-cardinality"} 1
+		`), "mimir_continuous_test_additional_checks_failed_total"))
+	})
+
+	t.Run("should track the failed cardinality query", func(t *testing.T) {
+		client := &ClientMock{}
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+		client.On("LabelValues", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.LabelValues{"0", "1", "2", "3"}, nil)
+		client.On("LabelValuesCardinality", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return((*LabelValuesCardinalityResponse)(nil), errors.New("cardinality analysis is disabled"))
+
+		reg := prometheus.NewPedanticRegistry()
+		test, err := NewLabelCardinalityTest(cfg, client, log.NewNopLogger(), reg)
+		require.NoError(t, err)
+
+		require.NoError(t, test.Run(context.Background(), now))
+		require.Error(t, test.Run(context.Background(), now.Add(cfg.WriteInterval)))
+
+		assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+			# HELP mimir_continuous_test_queries_total Total number of attempted query requests.
+			# TYPE mimir_continuous_test_queries_total counter
+			mimir_continuous_test_queries_total{test="label-cardinality"} 2
+		`), "mimir_continuous_test_queries_total"))
+	})
+}
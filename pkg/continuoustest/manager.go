@@ -5,10 +5,16 @@ package continuoustest
 import (
 	"context"
 	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/multierror"
+	"go.uber.org/atomic"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -24,13 +30,21 @@ type Test interface {
 	Run(ctx context.Context, now time.Time) error
 }
 
+// SchemaValidator is an optional interface implemented by a Test which can validate, once at startup and
+// before the test is initialized, that the target accepts the data written by the test.
+type SchemaValidator interface {
+	// ValidateSchema returns an error if the target doesn't accept the data written by the test.
+	// If the validation fails, the testing tool will terminate.
+	ValidateSchema(ctx context.Context, now time.Time) error
+}
+
 type ManagerConfig struct {
 	SmokeTest   bool
 	RunInterval time.Duration
 }
 
 func (cfg *ManagerConfig) RegisterFlags(f *flag.FlagSet) {
-	f.BoolVar(&cfg.SmokeTest, "tests.smoke-test", false, "Run a smoke test, i.e. run all tests once and exit.")
+	f.BoolVar(&cfg.SmokeTest, "tests.smoke-test", false, "Run a smoke test, i.e. run all tests once and exit. The process exit code is non-zero if any write, query or query result check failed.")
 	f.DurationVar(&cfg.RunInterval, "tests.run-interval", 5*time.Minute, "How frequently tests should run.")
 }
 
@@ -38,6 +52,11 @@ type Manager struct {
 	cfg    ManagerConfig
 	logger log.Logger
 	tests  []Test
+
+	// Whether all tests have been successfully initialized, and whether each test has completed at least one
+	// successful run. They're read by the readiness probe, concurrently with the tests running.
+	initialized  atomic.Bool
+	runSucceeded []*atomic.Bool
 }
 
 func NewManager(cfg ManagerConfig, logger log.Logger) *Manager {
@@ -49,33 +68,40 @@ func NewManager(cfg ManagerConfig, logger log.Logger) *Manager {
 
 func (m *Manager) AddTest(t Test) {
 	m.tests = append(m.tests, t)
+	m.runSucceeded = append(m.runSucceeded, atomic.NewBool(false))
 }
 
 func (m *Manager) Run(ctx context.Context) error {
+	// Validate the schema of all tests supporting it, before initializing any test.
+	for _, t := range m.tests {
+		if v, ok := t.(SchemaValidator); ok {
+			if err := v.ValidateSchema(ctx, time.Now().UTC()); err != nil {
+				return err
+			}
+		}
+	}
+
 	// Initialize all tests.
 	for _, t := range m.tests {
-		if err := t.Init(ctx, time.Now()); err != nil {
+		if err := t.Init(ctx, time.Now().UTC()); err != nil {
 			return err
 		}
 	}
+	m.initialized.Store(true)
+
+	if m.cfg.SmokeTest {
+		return m.runSmokeTest(ctx)
+	}
 
 	// Continuously run all tests. Each test is executed in a dedicated goroutine.
 	group, ctx := errgroup.WithContext(ctx)
 
-	for _, test := range m.tests {
-		t := test
+	for i := range m.tests {
+		i := i
 		group.Go(func() error {
 
 			// Run it immediately, and then every configured period.
-			err := t.Run(ctx, time.Now())
-			if m.cfg.SmokeTest {
-				if err != nil {
-					level.Info(m.logger).Log("msg", "Test failed", "test", t.Name(), "err", err)
-				} else {
-					level.Info(m.logger).Log("msg", "Test passed", "test", t.Name())
-				}
-				return err
-			}
+			_ = m.runTest(ctx, i)
 
 			ticker := time.NewTicker(m.cfg.RunInterval)
 
@@ -84,7 +110,7 @@ func (m *Manager) Run(ctx context.Context) error {
 				case <-ticker.C:
 					// This error is intentionally ignored because we want to
 					// continue running the tests forever.
-					_ = t.Run(ctx, time.Now())
+					_ = m.runTest(ctx, i)
 				case <-ctx.Done():
 					return nil
 				}
@@ -94,3 +120,73 @@ func (m *Manager) Run(ctx context.Context) error {
 
 	return group.Wait()
 }
+
+// runSmokeTest runs all tests once, concurrently, and returns the errors of all failed tests. A failing test
+// doesn't interrupt the other ones, so that the outcome of each test is always reported.
+func (m *Manager) runSmokeTest(ctx context.Context) error {
+	var (
+		wg   sync.WaitGroup
+		errs = make([]error, len(m.tests))
+	)
+
+	for i, test := range m.tests {
+		i, t := i, test
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			errs[i] = m.runTest(ctx, i)
+			if errs[i] != nil {
+				level.Info(m.logger).Log("msg", "Test failed", "test", t.Name(), "err", errs[i])
+			} else {
+				level.Info(m.logger).Log("msg", "Test passed", "test", t.Name())
+			}
+		}()
+	}
+
+	wg.Wait()
+	return multierror.New(errs...).Err()
+}
+
+// runTest runs a single cycle of the i-th test, and tracks whether it succeeded.
+func (m *Manager) runTest(ctx context.Context, i int) error {
+	// Tests always get the current time in UTC, so that the timestamps they compute, compare and log
+	// don't depend on the local time zone of the testing tool.
+	err := m.tests[i].Run(ctx, time.Now().UTC())
+	if err == nil {
+		m.runSucceeded[i].Store(true)
+	}
+	return err
+}
+
+// ReadyHandler returns an HTTP handler responding with 200 once all tests have been successfully initialized
+// and each of them has completed at least one successful run, and with 503 otherwise.
+func (m *Manager) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if !m.initialized.Load() {
+			http.Error(w, "the tests have not been initialized yet", http.StatusServiceUnavailable)
+			return
+		}
+
+		var pending []string
+		for i, t := range m.tests {
+			if !m.runSucceeded[i].Load() {
+				pending = append(pending, t.Name())
+			}
+		}
+		if len(pending) > 0 {
+			http.Error(w, fmt.Sprintf("the following tests have not completed a successful run yet: %s", strings.Join(pending, ", ")), http.StatusServiceUnavailable)
+			return
+		}
+
+		_, _ = w.Write([]byte("ready\n"))
+	})
+}
+
+// HealthyHandler returns an HTTP handler always responding with 200, as long as the process is able to serve it.
+func (m *Manager) HealthyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("healthy\n"))
+	})
+}
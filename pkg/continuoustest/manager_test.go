@@ -5,6 +5,8 @@ package continuoustest
 import (
 	"context"
 	"flag"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -14,8 +16,13 @@ import (
 )
 
 type dummyTest struct {
-	runs int
-	err  error
+	inits int
+	runs  int
+	err   error
+
+	// The time passed to the last Init and Run calls.
+	lastInitNow time.Time
+	lastRunNow  time.Time
 }
 
 // Name implements Test.
@@ -25,15 +32,30 @@ func (d *dummyTest) Name() string {
 
 // Init implements Test.
 func (d *dummyTest) Init(ctx context.Context, now time.Time) error {
+	d.inits++
+	d.lastInitNow = now
 	return nil
 }
 
 // Run implements Test.
 func (d *dummyTest) Run(ctx context.Context, now time.Time) error {
 	d.runs++
+	d.lastRunNow = now
 	return d.err
 }
 
+type dummySchemaValidatingTest struct {
+	dummyTest
+	validations int
+	validateErr error
+}
+
+// ValidateSchema implements SchemaValidator.
+func (d *dummySchemaValidatingTest) ValidateSchema(ctx context.Context, now time.Time) error {
+	d.validations++
+	return d.validateErr
+}
+
 func TestManager_PeriodicRun(t *testing.T) {
 	logger := log.NewNopLogger()
 	cfg := ManagerConfig{}
@@ -96,4 +118,123 @@ func TestManager_SmokeTest(t *testing.T) {
 		require.ErrorIs(t, err, dummyTest.err)
 		require.Equal(t, dummyTest.runs, 1)
 	})
+
+	t.Run("failed smoke test with multiple tests", func(t *testing.T) {
+		logger := log.NewNopLogger()
+		cfg := ManagerConfig{}
+		cfg.RegisterFlags(flag.NewFlagSet("", flag.ContinueOnError))
+		cfg.SmokeTest = true
+
+		manager := NewManager(cfg, logger)
+
+		firstTest := &dummyTest{err: errors.New("first test error")}
+		secondTest := &dummyTest{}
+		thirdTest := &dummyTest{err: errors.New("third test error")}
+		manager.AddTest(firstTest)
+		manager.AddTest(secondTest)
+		manager.AddTest(thirdTest)
+
+		err := manager.Run(context.Background())
+
+		// A failing test doesn't interrupt the other ones, and the errors of all failed tests are returned.
+		require.ErrorIs(t, err, firstTest.err)
+		require.ErrorIs(t, err, thirdTest.err)
+		require.Equal(t, 1, firstTest.runs)
+		require.Equal(t, 1, secondTest.runs)
+		require.Equal(t, 1, thirdTest.runs)
+	})
+}
+
+func TestManager_PassesCurrentTimeInUTC(t *testing.T) {
+	// Run the testing tool in a time zone whose offset is not a multiple of 1h.
+	originalLocal := time.Local
+	time.Local = time.FixedZone("UTC+05:30", 5*3600+1800)
+	t.Cleanup(func() { time.Local = originalLocal })
+
+	cfg := ManagerConfig{}
+	cfg.RegisterFlags(flag.NewFlagSet("", flag.ContinueOnError))
+	cfg.SmokeTest = true
+
+	manager := NewManager(cfg, log.NewNopLogger())
+
+	dummyTest := &dummyTest{}
+	manager.AddTest(dummyTest)
+	require.NoError(t, manager.Run(context.Background()))
+
+	require.Equal(t, time.UTC, dummyTest.lastInitNow.Location())
+	require.Equal(t, time.UTC, dummyTest.lastRunNow.Location())
+}
+
+func TestManager_ValidateSchema(t *testing.T) {
+	t.Run("successful schema validation", func(t *testing.T) {
+		logger := log.NewNopLogger()
+		cfg := ManagerConfig{}
+		cfg.RegisterFlags(flag.NewFlagSet("", flag.ContinueOnError))
+		cfg.SmokeTest = true
+
+		manager := NewManager(cfg, logger)
+
+		dummyTest := &dummySchemaValidatingTest{}
+		manager.AddTest(dummyTest)
+
+		require.NoError(t, manager.Run(context.Background()))
+		require.Equal(t, 1, dummyTest.validations)
+		require.Equal(t, 1, dummyTest.inits)
+		require.Equal(t, 1, dummyTest.runs)
+	})
+
+	t.Run("failed schema validation", func(t *testing.T) {
+		logger := log.NewNopLogger()
+		cfg := ManagerConfig{}
+		cfg.RegisterFlags(flag.NewFlagSet("", flag.ContinueOnError))
+		cfg.SmokeTest = true
+
+		manager := NewManager(cfg, logger)
+
+		dummyTest := &dummySchemaValidatingTest{}
+		dummyTest.validateErr = errors.New("validation error")
+		manager.AddTest(dummyTest)
+
+		require.ErrorIs(t, manager.Run(context.Background()), dummyTest.validateErr)
+		require.Equal(t, 1, dummyTest.validations)
+		require.Equal(t, 0, dummyTest.inits)
+		require.Equal(t, 0, dummyTest.runs)
+	})
+}
+
+func TestManager_ReadinessAndHealthProbes(t *testing.T) {
+	probe := func(handler http.Handler) int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		return rec.Code
+	}
+
+	cfg := ManagerConfig{}
+	cfg.RegisterFlags(flag.NewFlagSet("", flag.ContinueOnError))
+	cfg.SmokeTest = true
+
+	manager := NewManager(cfg, log.NewNopLogger())
+	succeedingTest := &dummyTest{}
+	failingTest := &dummyTest{err: errors.New("test error")}
+	manager.AddTest(succeedingTest)
+	manager.AddTest(failingTest)
+
+	// The tests have not been initialized yet.
+	require.Equal(t, http.StatusServiceUnavailable, probe(manager.ReadyHandler()))
+	require.Equal(t, http.StatusOK, probe(manager.HealthyHandler()))
+
+	// The tests have been initialized, but one of them has not completed a successful run yet.
+	require.Error(t, manager.Run(context.Background()))
+	require.True(t, manager.initialized.Load())
+	require.Equal(t, http.StatusServiceUnavailable, probe(manager.ReadyHandler()))
+
+	// All tests have completed at least one successful run, even if not the last one.
+	failingTest.err = nil
+	require.NoError(t, manager.Run(context.Background()))
+	require.Equal(t, http.StatusOK, probe(manager.ReadyHandler()))
+
+	succeedingTest.err = errors.New("test error")
+	require.Error(t, manager.Run(context.Background()))
+	require.Equal(t, http.StatusOK, probe(manager.ReadyHandler()))
+	require.Equal(t, http.StatusOK, probe(manager.HealthyHandler()))
 }
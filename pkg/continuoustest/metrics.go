@@ -3,52 +3,368 @@
 package continuoustest
 
 import (
+	"time"
+
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Read paths through which query results are checked.
+const (
+	readPathQueryAPI   = "query_api"
+	readPathRemoteRead = "remote_read"
+)
+
+// Storages from which query results are read.
+const (
+	storageDefault = "default"
+	storageParquet = "parquet"
+)
+
+// Types of the queries run to check the written series.
+const (
+	queryTypeRange   = "range"
+	queryTypeInstant = "instant"
 )
 
+var queryTypes = []string{queryTypeInstant, queryTypeRange}
+
 // TestMetrics holds generic metrics tracked by tests. The common metrics are used to enforce the same
 // metric names and labels to track the same information across different tests.
 type TestMetrics struct {
-	writesTotal                  prometheus.Counter
-	writesFailedTotal            *prometheus.CounterVec
-	queriesTotal                 prometheus.Counter
-	queriesFailedTotal           prometheus.Counter
-	queryResultChecksTotal       prometheus.Counter
-	queryResultChecksFailedTotal prometheus.Counter
+	writesTotal                      prometheus.Counter
+	writesFailedTotal                *prometheus.CounterVec
+	writeSamplesTotal                prometheus.Counter
+	writeDurationSeconds             prometheus.Histogram
+	queriesTotal                     prometheus.Counter
+	queriesFailedTotal               *prometheus.CounterVec
+	queryDurationSeconds             *prometheus.HistogramVec
+	queryResultChecksTotal           *prometheus.CounterVec
+	queryResultChecksFailedTotal     *prometheus.CounterVec
+	additionalChecksTotal            *prometheus.CounterVec
+	additionalChecksFailedTotal      *prometheus.CounterVec
+	deepRangeCheckMismatchesTotal    prometheus.Counter
+	coarseStepCheckMismatchesTotal   prometheus.Counter
+	cardinality                      prometheus.Gauge
+	querySLOViolationsTotal          prometheus.Counter
+	rateAggregationDivergenceTotal   prometheus.Counter
+	runIntervalSeconds               prometheus.Gauge
+	duplicateSamplesAcceptedTotal    prometheus.Counter
+	invalidStepQueriesAcceptedTotal  prometheus.Counter
+	labelOrderDuplicateSeriesTotal   prometheus.Counter
+	burstConsistencySeconds          prometheus.Histogram
+	regexMatcherDivergenceTotal      prometheus.Counter
+	nameMatcherDivergenceTotal       prometheus.Counter
+	equivalentQueriesDivergenceTotal prometheus.Counter
+	writeRetriesTotal                prometheus.Counter
+	timestampDeviationsTotal         prometheus.Counter
+	absentDataUnexpectedSamplesTotal prometheus.Counter
+	labelValuesMismatchesTotal       prometheus.Counter
+	seriesMismatchesTotal            prometheus.Counter
+	exemplarChecksFailedTotal        prometheus.Counter
+	querySplitMismatchTotal          prometheus.Counter
+	lastCheckSuccess                 *prometheus.GaugeVec
+	verifiedOldestSampleAgeSeconds   *prometheus.GaugeVec
+	gapCheckAnomaliesTotal           prometheus.Counter
+	targetVersionInfo                *prometheus.GaugeVec
+	skippedIterationsTotal           prometheus.Counter
+	referenceMismatchTotal           prometheus.Counter
 }
 
 func NewTestMetrics(testName string, reg prometheus.Registerer) *TestMetrics {
-	return &TestMetrics{
+	return newTestMetrics(map[string]string{"test": testName}, reg)
+}
+
+// NewTenantTestMetrics is like NewTestMetrics, but the metrics are also labelled with the input tenant ID, so that
+// failures can be attributed to each tenant when the same test runs for multiple tenants.
+func NewTenantTestMetrics(testName, tenantID string, reg prometheus.Registerer) *TestMetrics {
+	return newTestMetrics(map[string]string{"test": testName, "tenant": tenantID}, reg)
+}
+
+func newTestMetrics(constLabels map[string]string, reg prometheus.Registerer) *TestMetrics {
+	m := &TestMetrics{
 		writesTotal: promauto.With(reg).NewCounter(prometheus.CounterOpts{
 			Name:        "mimir_continuous_test_writes_total",
 			Help:        "Total number of attempted write requests.",
-			ConstLabels: map[string]string{"test": testName},
+			ConstLabels: constLabels,
 		}),
 		writesFailedTotal: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
 			Name:        "mimir_continuous_test_writes_failed_total",
 			Help:        "Total number of failed write requests.",
-			ConstLabels: map[string]string{"test": testName},
+			ConstLabels: constLabels,
 		}, []string{"status_code"}),
+		writeSamplesTotal: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name:        "mimir_continuous_test_write_samples_total",
+			Help:        "Total number of samples sent in write requests, including failed ones.",
+			ConstLabels: constLabels,
+		}),
+		writeDurationSeconds: promauto.With(reg).NewHistogram(prometheus.HistogramOpts{
+			Name:        "mimir_continuous_test_write_duration_seconds",
+			Help:        "Time it takes to execute a write request, including failed ones.",
+			Buckets:     prometheus.DefBuckets,
+			ConstLabels: constLabels,
+		}),
 		queriesTotal: promauto.With(reg).NewCounter(prometheus.CounterOpts{
 			Name:        "mimir_continuous_test_queries_total",
 			Help:        "Total number of attempted query requests.",
-			ConstLabels: map[string]string{"test": testName},
+			ConstLabels: constLabels,
 		}),
-		queriesFailedTotal: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+		queriesFailedTotal: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
 			Name:        "mimir_continuous_test_queries_failed_total",
 			Help:        "Total number of failed query requests.",
-			ConstLabels: map[string]string{"test": testName},
-		}),
-		queryResultChecksTotal: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			ConstLabels: constLabels,
+		}, []string{"reason", "status_code"}),
+		queryDurationSeconds: promauto.With(reg).NewHistogramVec(prometheus.HistogramOpts{
+			Name:        "mimir_continuous_test_query_duration_seconds",
+			Help:        "Time it takes to execute a query request, including failed ones.",
+			Buckets:     prometheus.DefBuckets,
+			ConstLabels: constLabels,
+		}, []string{"type"}),
+		queryResultChecksTotal: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
 			Name:        "mimir_continuous_test_query_result_checks_total",
 			Help:        "Total number of query results checked for correctness.",
-			ConstLabels: map[string]string{"test": testName},
-		}),
-		queryResultChecksFailedTotal: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			ConstLabels: constLabels,
+		}, []string{"read_path", "storage", "query_type"}),
+		queryResultChecksFailedTotal: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
 			Name:        "mimir_continuous_test_query_result_checks_failed_total",
 			Help:        "Total number of query results failed when checking for correctness.",
-			ConstLabels: map[string]string{"test": testName},
+			ConstLabels: constLabels,
+		}, []string{"read_path", "storage", "query_type"}),
+		additionalChecksTotal: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name:        "mimir_continuous_test_additional_checks_total",
+			Help:        "Total number of additional (opt-in) checks run.",
+			ConstLabels: constLabels,
+		}, []string{"check"}),
+		additionalChecksFailedTotal: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name:        "mimir_continuous_test_additional_checks_failed_total",
+			Help:        "Total number of additional (opt-in) checks failed.",
+			ConstLabels: constLabels,
+		}, []string{"check"}),
+		deepRangeCheckMismatchesTotal: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name:        "mimir_continuous_test_deep_range_check_mismatched_points_total",
+			Help:        "Total number of points missing or having an unexpected value in the deep range check.",
+			ConstLabels: constLabels,
+		}),
+		coarseStepCheckMismatchesTotal: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name:        "mimir_continuous_test_coarse_step_check_mismatched_points_total",
+			Help:        "Total number of points missing or not having the value of the most recent sample in the coarse step check.",
+			ConstLabels: constLabels,
+		}),
+		cardinality: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Name:        "mimir_continuous_test_cardinality",
+			Help:        "Number of series written by the test.",
+			ConstLabels: constLabels,
+		}),
+		querySLOViolationsTotal: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name:        "mimir_continuous_test_query_slo_violations_total",
+			Help:        "Total number of queries whose latency exceeded the configured SLO.",
+			ConstLabels: constLabels,
+		}),
+		rateAggregationDivergenceTotal: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name:        "mimir_continuous_test_rate_aggregation_divergence_total",
+			Help:        "Total number of times the sum of the rates diverged from the rate of the sum in the rate aggregation check.",
+			ConstLabels: constLabels,
+		}),
+		runIntervalSeconds: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Name:        "mimir_continuous_test_run_interval_seconds",
+			Help:        "Wall time in seconds between the two most recent test runs.",
+			ConstLabels: constLabels,
+		}),
+		duplicateSamplesAcceptedTotal: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name:        "mimir_continuous_test_duplicate_samples_accepted_total",
+			Help:        "Total number of samples with the same timestamp but a different value of an already written sample, which have been unexpectedly accepted.",
+			ConstLabels: constLabels,
+		}),
+		invalidStepQueriesAcceptedTotal: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name:        "mimir_continuous_test_invalid_step_queries_accepted_total",
+			Help:        "Total number of range queries with a zero or negative step, which have been unexpectedly accepted.",
+			ConstLabels: constLabels,
+		}),
+		labelOrderDuplicateSeriesTotal: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name:        "mimir_continuous_test_label_order_duplicate_series_total",
+			Help:        "Total number of duplicate series unexpectedly stored when writing the same series with its labels in different orders in the label order check.",
+			ConstLabels: constLabels,
+		}),
+		burstConsistencySeconds: promauto.With(reg).NewHistogram(prometheus.HistogramOpts{
+			Name:        "mimir_continuous_test_burst_consistency_seconds",
+			Help:        "Time it takes for the samples written in a burst to be queryable.",
+			Buckets:     prometheus.ExponentialBuckets(0.5, 2, 8),
+			ConstLabels: constLabels,
+		}),
+		regexMatcherDivergenceTotal: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name:        "mimir_continuous_test_regex_matcher_divergence_total",
+			Help:        "Total number of times the query with a regex label matcher diverged from the query without it in the regex matcher check.",
+			ConstLabels: constLabels,
+		}),
+		nameMatcherDivergenceTotal: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name:        "mimir_continuous_test_name_matcher_divergence_total",
+			Help:        "Total number of times the query with an explicit __name__ label matcher diverged from the query with the bare metric name in the name matcher check.",
+			ConstLabels: constLabels,
+		}),
+		equivalentQueriesDivergenceTotal: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name:        "mimir_continuous_test_equivalent_queries_divergence_total",
+			Help:        "Total number of times two logically identical but textually different queries returned different results in the equivalent queries check.",
+			ConstLabels: constLabels,
+		}),
+		writeRetriesTotal: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name:        "mimir_continuous_test_write_retries_total",
+			Help:        "Total number of retried write requests.",
+			ConstLabels: constLabels,
+		}),
+		timestampDeviationsTotal: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name:        "mimir_continuous_test_query_result_timestamp_deviations_total",
+			Help:        "Total number of samples returned by queries whose timestamp doesn't exactly match the expected one.",
+			ConstLabels: constLabels,
+		}),
+		absentDataUnexpectedSamplesTotal: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name:        "mimir_continuous_test_absent_data_unexpected_samples_total",
+			Help:        "Total number of samples unexpectedly returned in the time range where no data is expected to exist in the absent data check.",
+			ConstLabels: constLabels,
 		}),
+		labelValuesMismatchesTotal: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name:        "mimir_continuous_test_label_values_mismatches_total",
+			Help:        "Total number of label values missing from or unexpectedly returned by the label values API.",
+			ConstLabels: constLabels,
+		}),
+		seriesMismatchesTotal: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name:        "mimir_continuous_test_series_mismatches_total",
+			Help:        "Total number of series missing from or unexpectedly returned by the series API.",
+			ConstLabels: constLabels,
+		}),
+		exemplarChecksFailedTotal: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name:        "mimir_continuous_test_exemplar_checks_failed_total",
+			Help:        "Total number of exemplars whose trace ID doesn't match the one assigned to the series and timestamp they're attached to.",
+			ConstLabels: constLabels,
+		}),
+		querySplitMismatchTotal: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name:        "mimir_continuous_test_query_split_mismatch_total",
+			Help:        "Total number of points of the range queries straddling a query-frontend split boundary which differ from the unsplit baseline of instant queries.",
+			ConstLabels: constLabels,
+		}),
+		lastCheckSuccess: promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
+			Name:        "mimir_continuous_test_last_check_success",
+			Help:        "Whether the query results of the written metric have been successfully checked by the last run (1) or not (0).",
+			ConstLabels: constLabels,
+		}, []string{"metric_name", "query_type"}),
+		verifiedOldestSampleAgeSeconds: promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
+			Name:        "mimir_continuous_test_verified_oldest_sample_age_seconds",
+			Help:        "Age of the oldest sample of the written metric whose query results are checked, as of the last run. 0 if no samples are checked.",
+			ConstLabels: constLabels,
+		}, []string{"metric_name"}),
+		gapCheckAnomaliesTotal: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name:        "mimir_continuous_test_gap_check_anomalies_total",
+			Help:        "Total number of points missing, unexpected or with an unexpected value in the range queries run by the gap check.",
+			ConstLabels: constLabels,
+		}),
+		targetVersionInfo: promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
+			Name:        "mimir_continuous_test_target_version_info",
+			Help:        "Set to 1, with the version observed at startup, when the version of the target differs from the expected one.",
+			ConstLabels: constLabels,
+		}, []string{"version", "expected_version"}),
+		skippedIterationsTotal: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name:        "mimir_continuous_test_skipped_iterations_total",
+			Help:        "Total number of runs skipped because the previous run was still in progress.",
+			ConstLabels: constLabels,
+		}),
+		referenceMismatchTotal: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name:        "mimir_continuous_test_reference_mismatch_total",
+			Help:        "Total number of query results which differ from the result of the same query run against the reference backend.",
+			ConstLabels: constLabels,
+		}),
+	}
+
+	// The query API is always checked, so its counters are exported since the beginning, for both query types,
+	// even if the test runs only one of them.
+	m.queryResultCheckCounters(readPathQueryAPI, storageDefault, queryTypeRange)
+	m.queryResultCheckCounters(readPathQueryAPI, storageDefault, queryTypeInstant)
+
+	return m
+}
+
+// queryResultCheckCounters returns the counters tracking the total and failed query result checks for the
+// input read path, storage and query type. Both counters are exported as soon as the read path and storage are
+// checked for the first time through the query type. The queries selecting a time range, other than the range
+// queries, like the remote read and the label values ones, are tracked as range queries.
+func (m *TestMetrics) queryResultCheckCounters(readPath, storage, queryType string) (total, failed prometheus.Counter) {
+	return m.queryResultChecksTotal.WithLabelValues(readPath, storage, queryType), m.queryResultChecksFailedTotal.WithLabelValues(readPath, storage, queryType)
+}
+
+// additionalCheckCounters returns the counters tracking the total and failed runs of the additional check
+// with the input name. Both counters are exported as soon as the check runs for the first time.
+func (m *TestMetrics) additionalCheckCounters(check string) (total, failed prometheus.Counter) {
+	return m.additionalChecksTotal.WithLabelValues(check), m.additionalChecksFailedTotal.WithLabelValues(check)
+}
+
+// setLastCheckSuccess tracks whether the query results of the input metric, read through the input query type,
+// have been successfully checked by the current run.
+func (m *TestMetrics) setLastCheckSuccess(metricName, queryType string, success bool) {
+	value := 0.0
+	if success {
+		value = 1
+	}
+	m.lastCheckSuccess.WithLabelValues(metricName, queryType).Set(value)
+}
+
+// setVerifiedOldestSampleAge tracks the age, as of the input time, of the oldest sample of the input metric whose
+// query results are checked. The zero oldest sample time means that no samples are checked.
+func (m *TestMetrics) setVerifiedOldestSampleAge(metricName string, now, oldest time.Time) {
+	age := 0.0
+	if !oldest.IsZero() {
+		age = now.Sub(oldest).Seconds()
+	}
+	m.verifiedOldestSampleAgeSeconds.WithLabelValues(metricName).Set(age)
+}
+
+// runTotals is a snapshot of the cumulative values of the counters reported for each test run.
+type runTotals struct {
+	writes        float64
+	failures      float64
+	queries       float64
+	queryFailures float64
+	checks        float64
+	checkFailures float64
+}
+
+// sub returns the difference between t and the input (earlier) snapshot.
+func (t runTotals) sub(other runTotals) runTotals {
+	return runTotals{
+		writes:        t.writes - other.writes,
+		failures:      t.failures - other.failures,
+		queries:       t.queries - other.queries,
+		queryFailures: t.queryFailures - other.queryFailures,
+		checks:        t.checks - other.checks,
+		checkFailures: t.checkFailures - other.checkFailures,
+	}
+}
+
+// runTotals returns a snapshot of the counters reported for each test run. The failures include both
+// failed writes and failed queries, while the checks include both query result checks and additional checks.
+func (m *TestMetrics) runTotals() runTotals {
+	return runTotals{
+		writes:        counterSum(m.writesTotal),
+		failures:      counterSum(m.writesFailedTotal) + counterSum(m.queriesFailedTotal),
+		queries:       counterSum(m.queriesTotal),
+		queryFailures: counterSum(m.queriesFailedTotal),
+		checks:        counterSum(m.queryResultChecksTotal) + counterSum(m.additionalChecksTotal),
+		checkFailures: counterSum(m.queryResultChecksFailedTotal) + counterSum(m.additionalChecksFailedTotal),
+	}
+}
+
+// counterSum returns the sum of the values of all counters collected from c.
+func counterSum(c prometheus.Collector) float64 {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+
+	sum := 0.0
+	for metric := range ch {
+		m := &dto.Metric{}
+		if err := metric.Write(m); err == nil && m.Counter != nil {
+			sum += m.Counter.GetValue()
+		}
 	}
+	return sum
 }
@@ -0,0 +1,128 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"math"
+
+	"github.com/prometheus/prometheus/prompb"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The content type of the remote write 2.0 requests, as defined by the remote write 2.0 specification.
+const remoteWriteV2ContentType = "application/x-protobuf;proto=io.prometheus.write.v2.Request"
+
+// The field numbers of the io.prometheus.write.v2 protobuf messages. The vendored Prometheus version doesn't
+// include the generated remote write 2.0 types, so the requests are encoded here.
+const (
+	writeV2RequestSymbolsField    protowire.Number = 4
+	writeV2RequestTimeseriesField protowire.Number = 5
+
+	writeV2TimeSeriesLabelsRefsField protowire.Number = 1
+	writeV2TimeSeriesSamplesField    protowire.Number = 2
+	writeV2TimeSeriesHistogramsField protowire.Number = 3
+	writeV2TimeSeriesExemplarsField  protowire.Number = 4
+
+	writeV2SampleValueField     protowire.Number = 1
+	writeV2SampleTimestampField protowire.Number = 2
+
+	writeV2ExemplarLabelsRefsField protowire.Number = 1
+	writeV2ExemplarValueField      protowire.Number = 2
+	writeV2ExemplarTimestampField  protowire.Number = 3
+)
+
+// writeV2SymbolsTable interns the label names and values of a remote write 2.0 request. As required by the
+// specification, the first symbol is always the empty string.
+type writeV2SymbolsTable struct {
+	symbols []string
+	refs    map[string]uint32
+}
+
+func newWriteV2SymbolsTable() *writeV2SymbolsTable {
+	return &writeV2SymbolsTable{
+		symbols: []string{""},
+		refs:    map[string]uint32{"": 0},
+	}
+}
+
+// symbolize returns the reference of the input symbol, adding it to the table if missing.
+func (t *writeV2SymbolsTable) symbolize(symbol string) uint32 {
+	if ref, ok := t.refs[symbol]; ok {
+		return ref
+	}
+	ref := uint32(len(t.symbols))
+	t.symbols = append(t.symbols, symbol)
+	t.refs[symbol] = ref
+	return ref
+}
+
+// symbolizeLabels returns the packed encoding of the references of the input labels names and values.
+func (t *writeV2SymbolsTable) symbolizeLabels(labels []prompb.Label) []byte {
+	var refs []byte
+	for _, l := range labels {
+		refs = protowire.AppendVarint(refs, uint64(t.symbolize(l.Name)))
+		refs = protowire.AppendVarint(refs, uint64(t.symbolize(l.Value)))
+	}
+	return refs
+}
+
+// marshalWriteV2Request encodes the input series into a remote write 2.0 request. The native histograms are
+// encoded as is, because the remote write 1.0 and 2.0 histograms share the same wire format.
+func marshalWriteV2Request(series []prompb.TimeSeries) ([]byte, error) {
+	symbols := newWriteV2SymbolsTable()
+
+	var timeseries [][]byte
+	for _, s := range series {
+		var ts []byte
+		ts = protowire.AppendTag(ts, writeV2TimeSeriesLabelsRefsField, protowire.BytesType)
+		ts = protowire.AppendBytes(ts, symbols.symbolizeLabels(s.Labels))
+
+		for _, sample := range s.Samples {
+			var b []byte
+			b = protowire.AppendTag(b, writeV2SampleValueField, protowire.Fixed64Type)
+			b = protowire.AppendFixed64(b, math.Float64bits(sample.Value))
+			b = protowire.AppendTag(b, writeV2SampleTimestampField, protowire.VarintType)
+			b = protowire.AppendVarint(b, uint64(sample.Timestamp))
+
+			ts = protowire.AppendTag(ts, writeV2TimeSeriesSamplesField, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, b)
+		}
+
+		for _, h := range s.Histograms {
+			b, err := h.Marshal()
+			if err != nil {
+				return nil, err
+			}
+
+			ts = protowire.AppendTag(ts, writeV2TimeSeriesHistogramsField, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, b)
+		}
+
+		for _, e := range s.Exemplars {
+			var b []byte
+			b = protowire.AppendTag(b, writeV2ExemplarLabelsRefsField, protowire.BytesType)
+			b = protowire.AppendBytes(b, symbols.symbolizeLabels(e.Labels))
+			b = protowire.AppendTag(b, writeV2ExemplarValueField, protowire.Fixed64Type)
+			b = protowire.AppendFixed64(b, math.Float64bits(e.Value))
+			b = protowire.AppendTag(b, writeV2ExemplarTimestampField, protowire.VarintType)
+			b = protowire.AppendVarint(b, uint64(e.Timestamp))
+
+			ts = protowire.AppendTag(ts, writeV2TimeSeriesExemplarsField, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, b)
+		}
+
+		timeseries = append(timeseries, ts)
+	}
+
+	// The symbols table is complete only once all series have been encoded.
+	var req []byte
+	for _, symbol := range symbols.symbols {
+		req = protowire.AppendTag(req, writeV2RequestSymbolsField, protowire.BytesType)
+		req = protowire.AppendString(req, symbol)
+	}
+	for _, ts := range timeseries {
+		req = protowire.AppendTag(req, writeV2RequestTimeseriesField, protowire.BytesType)
+		req = protowire.AppendBytes(req, ts)
+	}
+	return req, nil
+}
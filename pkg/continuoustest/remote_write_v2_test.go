@@ -0,0 +1,144 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestMarshalWriteV2Request(t *testing.T) {
+	now := time.Unix(1000, 0)
+
+	series := appendExemplars(generateSineWaveSeries("test", now, 3))
+	series = append(series, prompb.TimeSeries{
+		Labels:     []prompb.Label{{Name: "__name__", Value: "test_histogram"}},
+		Histograms: []prompb.Histogram{{Count: &prompb.Histogram_CountInt{CountInt: 3}, Sum: 4.5, Schema: 1, Timestamp: now.UnixMilli()}},
+	})
+
+	data, err := marshalWriteV2Request(series)
+	require.NoError(t, err)
+
+	symbols, decoded := unmarshalWriteV2Request(t, data)
+
+	// The first symbol is the empty string, and each symbol is interned once.
+	require.NotEmpty(t, symbols)
+	assert.Equal(t, "", symbols[0])
+	unique := map[string]struct{}{}
+	for _, symbol := range symbols {
+		unique[symbol] = struct{}{}
+	}
+	assert.Len(t, unique, len(symbols))
+
+	assert.Equal(t, series, decoded)
+}
+
+// unmarshalWriteV2Request decodes the input remote write 2.0 request, and returns its symbols table and
+// series, with the labels references resolved.
+func unmarshalWriteV2Request(t *testing.T, data []byte) ([]string, []prompb.TimeSeries) {
+	var (
+		symbols    []string
+		timeseries [][]byte
+	)
+	forEachField(t, data, func(num protowire.Number, typ protowire.Type, value []byte, _ uint64) {
+		switch num {
+		case writeV2RequestSymbolsField:
+			symbols = append(symbols, string(value))
+		case writeV2RequestTimeseriesField:
+			timeseries = append(timeseries, value)
+		}
+	})
+
+	resolveLabels := func(refs []byte) []prompb.Label {
+		var labels []prompb.Label
+		for len(refs) > 0 {
+			name, n := protowire.ConsumeVarint(refs)
+			require.GreaterOrEqual(t, n, 0)
+			refs = refs[n:]
+			value, n := protowire.ConsumeVarint(refs)
+			require.GreaterOrEqual(t, n, 0)
+			refs = refs[n:]
+
+			require.Less(t, int(value), len(symbols))
+			labels = append(labels, prompb.Label{Name: symbols[name], Value: symbols[value]})
+		}
+		return labels
+	}
+
+	var series []prompb.TimeSeries
+	for _, ts := range timeseries {
+		s := prompb.TimeSeries{}
+		forEachField(t, ts, func(num protowire.Number, _ protowire.Type, value []byte, _ uint64) {
+			switch num {
+			case writeV2TimeSeriesLabelsRefsField:
+				s.Labels = resolveLabels(value)
+			case writeV2TimeSeriesSamplesField:
+				sample := prompb.Sample{}
+				forEachField(t, value, func(num protowire.Number, _ protowire.Type, _ []byte, v uint64) {
+					switch num {
+					case writeV2SampleValueField:
+						sample.Value = math.Float64frombits(v)
+					case writeV2SampleTimestampField:
+						sample.Timestamp = int64(v)
+					}
+				})
+				s.Samples = append(s.Samples, sample)
+			case writeV2TimeSeriesHistogramsField:
+				h := prompb.Histogram{}
+				require.NoError(t, h.Unmarshal(value))
+				s.Histograms = append(s.Histograms, h)
+			case writeV2TimeSeriesExemplarsField:
+				exemplar := prompb.Exemplar{}
+				forEachField(t, value, func(num protowire.Number, _ protowire.Type, value []byte, v uint64) {
+					switch num {
+					case writeV2ExemplarLabelsRefsField:
+						exemplar.Labels = resolveLabels(value)
+					case writeV2ExemplarValueField:
+						exemplar.Value = math.Float64frombits(v)
+					case writeV2ExemplarTimestampField:
+						exemplar.Timestamp = int64(v)
+					}
+				})
+				s.Exemplars = append(s.Exemplars, exemplar)
+			}
+		})
+		series = append(series, s)
+	}
+	return symbols, series
+}
+
+// forEachField calls f for each field of the input protobuf message, with either the value of length-delimited
+// fields or the numeric value of the other fields.
+func forEachField(t *testing.T, data []byte, f func(num protowire.Number, typ protowire.Type, value []byte, v uint64)) {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		require.GreaterOrEqual(t, n, 0)
+		data = data[n:]
+
+		switch typ {
+		case protowire.BytesType:
+			value, n := protowire.ConsumeBytes(data)
+			require.GreaterOrEqual(t, n, 0)
+			f(num, typ, value, 0)
+			data = data[n:]
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(data)
+			require.GreaterOrEqual(t, n, 0)
+			f(num, typ, nil, v)
+			data = data[n:]
+		case protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(data)
+			require.GreaterOrEqual(t, n, 0)
+			f(num, typ, nil, v)
+			data = data[n:]
+		default:
+			require.Fail(t, "unexpected wire type", typ)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/multierror"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/prompb"
+
+	"github.com/grafana/mimir/pkg/util/spanlogger"
+)
+
+const (
+	seriesMetadataTestName = "series-metadata"
+
+	// The metrics written by the series metadata test. The histogram metric name is matched by the same series
+	// selector as the float one, so that the series API is also checked against native histogram series.
+	seriesMetadataMetricName          = "mimir_continuous_test_series_metadata"
+	seriesMetadataHistogramMetricName = "mimir_continuous_test_series_metadata_histogram"
+	seriesMetadataLabelName           = "series_id"
+
+	// The labels added to the written series to scope them to the configuration, so that the series written by
+	// previous runs with a different configuration are not returned by the check.
+	seriesMetadataNumSeriesLabelName  = "num_series"
+	seriesMetadataHistogramsLabelName = "histograms"
+)
+
+type SeriesMetadataTestConfig struct {
+	Enabled           bool
+	NumSeries         int
+	HistogramsEnabled bool
+	WriteInterval     time.Duration
+}
+
+func (cfg *SeriesMetadataTestConfig) RegisterFlags(f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, "tests.series-metadata-test.enabled", false, "Run the series metadata test, which writes a known set of series, and checks that the series API returns exactly the written series.")
+	f.IntVar(&cfg.NumSeries, "tests.series-metadata-test.num-series", 10, "Number of series written by the test at each write interval. Each series has a distinct series_id label value.")
+	f.BoolVar(&cfg.HistogramsEnabled, "tests.series-metadata-test.histograms-enabled", false, "Also write a native histogram series for each series_id, and expect it to be returned by the series API. Native histograms ingestion must be enabled for the tenant.")
+	f.DurationVar(&cfg.WriteInterval, "tests.series-metadata-test.write-interval", defaultWriteInterval, "How frequently series are written. Written samples timestamps are aligned to the interval.")
+}
+
+// SeriesMetadataTest writes a known set of series, and checks that the series API returns exactly the written label
+// sets. The series API looks up the head and block indexes differently than the query API, so it's checked
+// independently.
+type SeriesMetadataTest struct {
+	name    string
+	cfg     SeriesMetadataTestConfig
+	client  MimirClient
+	logger  log.Logger
+	metrics *TestMetrics
+
+	// The labels added to all written series, and the series selector matching both the float and histogram series.
+	scopeLabels    []prompb.Label
+	seriesSelector string
+
+	lastWrittenTimestamp time.Time
+
+	// Whether the series have been successfully written at the last written timestamp.
+	lastWriteSucceeded bool
+}
+
+func NewSeriesMetadataTest(cfg SeriesMetadataTestConfig, client MimirClient, logger log.Logger, reg prometheus.Registerer) (*SeriesMetadataTest, error) {
+	return newSeriesMetadataTest(seriesMetadataTestName, "", cfg, client, logger, reg)
+}
+
+// NewSeriesMetadataTestForEndpoint is like NewSeriesMetadataTest, but the endpoint is part of the test name,
+// so that each endpoint is tracked independently in metrics and logs.
+func NewSeriesMetadataTestForEndpoint(cfg SeriesMetadataTestConfig, endpoint string, client MimirClient, logger log.Logger, reg prometheus.Registerer) (*SeriesMetadataTest, error) {
+	return newSeriesMetadataTest(seriesMetadataTestName+"-"+endpoint, "", cfg, client, logger, reg)
+}
+
+// NewSeriesMetadataTestForTenant is like NewSeriesMetadataTest, but the input client must be bound to the
+// tenant, and the tenant ID is added as a label to the test metrics.
+func NewSeriesMetadataTestForTenant(cfg SeriesMetadataTestConfig, tenantID string, client MimirClient, logger log.Logger, reg prometheus.Registerer) (*SeriesMetadataTest, error) {
+	return newSeriesMetadataTest(seriesMetadataTestName, tenantID, cfg, client, logger, reg)
+}
+
+func newSeriesMetadataTest(name, tenantID string, cfg SeriesMetadataTestConfig, client MimirClient, logger log.Logger, reg prometheus.Registerer) (*SeriesMetadataTest, error) {
+	if cfg.WriteInterval <= 0 {
+		return nil, errors.New("the write interval must be greater than 0")
+	}
+	if cfg.NumSeries <= 0 {
+		return nil, errors.New("the number of series must be greater than 0")
+	}
+
+	var metrics *TestMetrics
+	if tenantID != "" {
+		metrics = NewTenantTestMetrics(name, tenantID, reg)
+		logger = log.With(logger, "tenant", tenantID)
+	} else {
+		metrics = NewTestMetrics(name, reg)
+	}
+
+	scopeLabels := []prompb.Label{
+		{Name: seriesMetadataHistogramsLabelName, Value: strconv.FormatBool(cfg.HistogramsEnabled)},
+		{Name: seriesMetadataNumSeriesLabelName, Value: strconv.Itoa(cfg.NumSeries)},
+	}
+
+	return &SeriesMetadataTest{
+		name:           name,
+		cfg:            cfg,
+		client:         client,
+		logger:         log.With(logger, "test", name),
+		metrics:        metrics,
+		scopeLabels:    scopeLabels,
+		seriesSelector: seriesSelector("", scopeLabels, fmt.Sprintf("__name__=~%q", seriesMetadataMetricName+"(_histogram)?")),
+	}, nil
+}
+
+// Name implements Test.
+func (t *SeriesMetadataTest) Name() string {
+	return t.name
+}
+
+// Init implements Test. The previously written series are not recovered, because the series are checked only
+// at the timestamps written by the current run of the testing tool.
+func (t *SeriesMetadataTest) Init(context.Context, time.Time) error {
+	return nil
+}
+
+// Run implements Test.
+func (t *SeriesMetadataTest) Run(ctx context.Context, now time.Time) error {
+	// Restart writing from the current interval if the last written one is too old to catch up with.
+	if !t.lastWrittenTimestamp.IsZero() && t.lastWrittenTimestamp.Before(now.Add(-writeMaxAge)) {
+		t.lastWrittenTimestamp = time.Time{}
+		t.lastWriteSucceeded = false
+	}
+
+	// Collect all errors on this test run
+	errs := new(multierror.MultiError)
+
+	for timestamp := t.nextWriteTimestamp(now); !timestamp.After(now); timestamp = t.nextWriteTimestamp(now) {
+		if err := t.writeSeries(ctx, timestamp); err != nil {
+			errs.Add(err)
+
+			// Keep writing the next intervals if the write has been rejected, because retrying it isn't
+			// expected to succeed.
+			if !errors.Is(err, errWriteRejected) {
+				return errs.Err()
+			}
+		}
+	}
+
+	errs.Add(t.runSeriesCheck(ctx))
+	return errs.Err()
+}
+
+// writeSeries writes all the expected series at the input timestamp.
+func (t *SeriesMetadataTest) writeSeries(ctx context.Context, timestamp time.Time) error {
+	sp, ctx := spanlogger.NewWithLogger(ctx, t.logger, "SeriesMetadataTest.writeSeries")
+	defer sp.Finish()
+
+	series := make([]prompb.TimeSeries, 0, 2*t.cfg.NumSeries)
+	for i := 0; i < t.cfg.NumSeries; i++ {
+		series = append(series, prompb.TimeSeries{
+			Labels:  t.seriesLabels(seriesMetadataMetricName, i),
+			Samples: []prompb.Sample{{Value: 1, Timestamp: timestamp.UnixMilli()}},
+		})
+
+		if t.cfg.HistogramsEnabled {
+			histogramSeries := generateHistogramSeries(seriesMetadataHistogramMetricName, timestamp, defaultHistogramLayout)
+			histogramSeries.Labels = t.seriesLabels(seriesMetadataHistogramMetricName, i)
+			series = append(series, histogramSeries)
+		}
+	}
+
+	logger := log.With(sp, "timestamp", timestamp.String(), "num_series", len(series))
+	statusCode, err := t.client.WriteSeries(ctx, series)
+
+	t.metrics.writesTotal.Inc()
+	t.metrics.writeSamplesTotal.Add(float64(countSamples(series)))
+	if statusCode/100 != 2 {
+		t.metrics.writesFailedTotal.WithLabelValues(strconv.Itoa(statusCode)).Inc()
+		level.Warn(logger).Log("msg", "Failed to remote write series", "status_code", statusCode, "err", err)
+	}
+
+	// If the write request failed because of a 4xx error, we keep writing the next interval, but the series
+	// are not checked until they've been successfully written again.
+	if statusCode/100 == 4 {
+		t.lastWrittenTimestamp = timestamp
+		t.lastWriteSucceeded = false
+		return errors.Wrapf(errWriteRejected, "remote write series failed with status code %d: %v", statusCode, err)
+	}
+
+	// If the write request failed because of a network or 5xx error, we'll retry to write series
+	// in the next test run.
+	if err != nil {
+		return errors.Wrap(err, "failed to remote write series")
+	}
+	if statusCode/100 != 2 {
+		return errors.Wrapf(err, "remote write series failed with status code %d", statusCode)
+	}
+
+	t.lastWrittenTimestamp = timestamp
+	t.lastWriteSucceeded = true
+	return nil
+}
+
+// runSeriesCheck queries the series at the last written timestamp, and checks that the returned label sets exactly
+// match the written ones. The check is skipped if the series haven't been successfully written at that timestamp.
+func (t *SeriesMetadataTest) runSeriesCheck(ctx context.Context) error {
+	if !t.lastWriteSucceeded {
+		return nil
+	}
+
+	sp, ctx := spanlogger.NewWithLogger(ctx, t.logger, "SeriesMetadataTest.runSeriesCheck")
+	defer sp.Finish()
+
+	ts := t.lastWrittenTimestamp
+	logger := log.With(sp, "matcher", t.seriesSelector, "start", ts.UnixMilli(), "end", ts.UnixMilli())
+	level.Debug(logger).Log("msg", "Running series query")
+
+	t.metrics.queriesTotal.Inc()
+	series, err := t.client.Series(ctx, []string{t.seriesSelector}, ts, ts)
+	if err != nil {
+		t.metrics.queriesFailedTotal.WithLabelValues(classifyQueryError(err), queryErrorStatusCode(err)).Inc()
+		level.Warn(logger).Log("msg", "Failed to execute series query", "err", err)
+		return errors.Wrap(err, "failed to execute series query")
+	}
+
+	checksTotal, checksFailedTotal := t.metrics.queryResultCheckCounters(readPathQueryAPI, storageDefault, queryTypeRange)
+	checksTotal.Inc()
+
+	missing, unexpected := diffLabelSets(t.expectedLabelSets(), series)
+	if len(missing) > 0 || len(unexpected) > 0 {
+		checksFailedTotal.Inc()
+		t.metrics.seriesMismatchesTotal.Add(float64(len(missing) + len(unexpected)))
+		level.Warn(logger).Log("msg", "Series check failed", "missing", strings.Join(missing, ","), "unexpected", strings.Join(unexpected, ","))
+		return fmt.Errorf("series check failed: %d series are missing (%s) and %d are unexpected (%s)", len(missing), strings.Join(missing, ","), len(unexpected), strings.Join(unexpected, ","))
+	}
+	return nil
+}
+
+func (t *SeriesMetadataTest) nextWriteTimestamp(now time.Time) time.Time {
+	if t.lastWrittenTimestamp.IsZero() {
+		return alignTimestampToInterval(now, t.cfg.WriteInterval)
+	}
+
+	return t.lastWrittenTimestamp.Add(t.cfg.WriteInterval)
+}
+
+func (t *SeriesMetadataTest) seriesLabels(name string, id int) []prompb.Label {
+	lbls := make([]prompb.Label, 0, len(t.scopeLabels)+2)
+	lbls = append(lbls, prompb.Label{Name: "__name__", Value: name})
+	lbls = append(lbls, t.scopeLabels...)
+	return append(lbls, prompb.Label{Name: seriesMetadataLabelName, Value: strconv.Itoa(id)})
+}
+
+// expectedLabelSets returns the label sets of the float series and, if enabled, of the histogram series written
+// at each interval.
+func (t *SeriesMetadataTest) expectedLabelSets() []model.LabelSet {
+	names := []string{seriesMetadataMetricName}
+	if t.cfg.HistogramsEnabled {
+		names = append(names, seriesMetadataHistogramMetricName)
+	}
+
+	expected := make([]model.LabelSet, 0, len(names)*t.cfg.NumSeries)
+	for _, name := range names {
+		for i := 0; i < t.cfg.NumSeries; i++ {
+			set := model.LabelSet{}
+			for _, l := range t.seriesLabels(name, i) {
+				set[model.LabelName(l.Name)] = model.LabelValue(l.Value)
+			}
+			expected = append(expected, set)
+		}
+	}
+	return expected
+}
+
+// diffLabelSets returns the sorted expected label sets which are not in actual, and the sorted actual label sets
+// which are not expected.
+func diffLabelSets(expected, actual []model.LabelSet) (missing, unexpected []string) {
+	expectedSet := make(map[string]struct{}, len(expected))
+	for _, s := range expected {
+		expectedSet[s.String()] = struct{}{}
+	}
+
+	actualSet := make(map[string]struct{}, len(actual))
+	for _, s := range actual {
+		actualSet[s.String()] = struct{}{}
+		if _, ok := expectedSet[s.String()]; !ok {
+			unexpected = append(unexpected, s.String())
+		}
+	}
+
+	for _, s := range expected {
+		if _, ok := actualSet[s.String()]; !ok {
+			missing = append(missing, s.String())
+		}
+	}
+
+	sort.Strings(missing)
+	sort.Strings(unexpected)
+	return missing, unexpected
+}
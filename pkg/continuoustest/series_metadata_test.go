@@ -0,0 +1,173 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/flagext"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeriesMetadataTest_Run(t *testing.T) {
+	cfg := SeriesMetadataTestConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.Enabled = true
+	cfg.NumSeries = 2
+	cfg.WriteInterval = 20 * time.Second
+
+	now := time.Unix(1000, 0)
+
+	labelSet := func(name, histograms, id string) model.LabelSet {
+		return model.LabelSet{"__name__": model.LabelValue(name), "histograms": model.LabelValue(histograms), "num_series": "2", "series_id": model.LabelValue(id)}
+	}
+
+	t.Run("should check the series returned by the series API", func(t *testing.T) {
+		client := &ClientMock{}
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+		client.On("Series", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]model.LabelSet{
+			labelSet(seriesMetadataMetricName, "false", "1"),
+			labelSet(seriesMetadataMetricName, "false", "0"),
+		}, nil)
+
+		reg := prometheus.NewPedanticRegistry()
+		test, err := NewSeriesMetadataTest(cfg, client, log.NewNopLogger(), reg)
+		require.NoError(t, err)
+
+		require.NoError(t, test.Run(context.Background(), now))
+		client.AssertNumberOfCalls(t, "WriteSeries", 1)
+		client.AssertNumberOfCalls(t, "Series", 1)
+		client.AssertCalled(t, "Series", mock.Anything, []string{`{histograms="false",num_series="2",__name__=~"mimir_continuous_test_series_metadata(_histogram)?"}`}, now, now)
+
+		written := client.Calls[0].Arguments.Get(1).([]prompb.TimeSeries)
+		require.Len(t, written, 2)
+		for _, s := range written {
+			assert.Len(t, s.Samples, 1)
+			assert.Empty(t, s.Histograms)
+		}
+
+		assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+			# HELP mimir_continuous_test_query_result_checks_total Total number of query results checked for correctness.
+			# TYPE mimir_continuous_test_query_result_checks_total counter
+			mimir_continuous_test_query_result_checks_total{query_type="instant",read_path="query_api",storage="default",test="series-metadata"} 0
+			mimir_continuous_test_query_result_checks_total{query_type="range",read_path="query_api",storage="default",test="series-metadata"} 1
+
+			# HELP mimir_continuous_test_query_result_checks_failed_total Total number of query results failed when checking for correctness.
+			# TYPE mimir_continuous_test_query_result_checks_failed_total counter
+			mimir_continuous_test_query_result_checks_failed_total{query_type="instant",read_path="query_api",storage="default",test="series-metadata"} 0
+			mimir_continuous_test_query_result_checks_failed_total{query_type="range",read_path="query_api",storage="default",test="series-metadata"} 0
+
+			# HELP mimir_continuous_test_series_mismatches_total Total number of series missing from or unexpectedly returned by the series API.
+			# TYPE mimir_continuous_test_series_mismatches_total counter
+			mimir_continuous_test_series_mismatches_total{test="series-metadata"} 0
+		`), "mimir_continuous_test_query_result_checks_total", "mimir_continuous_test_query_result_checks_failed_total", "mimir_continuous_test_series_mismatches_total"))
+	})
+
+	t.Run("should expect the histogram series matched by the same selector if enabled", func(t *testing.T) {
+		cfg := cfg
+		cfg.HistogramsEnabled = true
+
+		client := &ClientMock{}
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+		client.On("Series", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]model.LabelSet{
+			labelSet(seriesMetadataMetricName, "true", "0"),
+			labelSet(seriesMetadataMetricName, "true", "1"),
+			labelSet(seriesMetadataHistogramMetricName, "true", "0"),
+			labelSet(seriesMetadataHistogramMetricName, "true", "1"),
+		}, nil)
+
+		test, err := NewSeriesMetadataTest(cfg, client, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+		require.NoError(t, err)
+
+		require.NoError(t, test.Run(context.Background(), now))
+
+		written := client.Calls[0].Arguments.Get(1).([]prompb.TimeSeries)
+		require.Len(t, written, 4)
+		var numHistograms int
+		for _, s := range written {
+			if len(s.Histograms) > 0 {
+				numHistograms++
+				assert.Equal(t, seriesMetadataHistogramMetricName, s.Labels[0].Value)
+			}
+		}
+		assert.Equal(t, 2, numHistograms)
+	})
+
+	t.Run("should fail if the histogram series are returned but histograms are not enabled", func(t *testing.T) {
+		client := &ClientMock{}
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+		client.On("Series", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]model.LabelSet{
+			labelSet(seriesMetadataMetricName, "false", "0"),
+			labelSet(seriesMetadataMetricName, "false", "1"),
+			labelSet(seriesMetadataHistogramMetricName, "false", "0"),
+		}, nil)
+
+		test, err := NewSeriesMetadataTest(cfg, client, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+		require.NoError(t, err)
+
+		err = test.Run(context.Background(), now)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "0 series are missing () and 1 are unexpected")
+	})
+
+	t.Run("should fail if the returned series don't exactly match the written ones", func(t *testing.T) {
+		client := &ClientMock{}
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+		client.On("Series", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]model.LabelSet{
+			labelSet(seriesMetadataMetricName, "false", "0"),
+			labelSet(seriesMetadataMetricName, "false", "5"),
+		}, nil)
+
+		reg := prometheus.NewPedanticRegistry()
+		test, err := NewSeriesMetadataTest(cfg, client, log.NewNopLogger(), reg)
+		require.NoError(t, err)
+
+		err = test.Run(context.Background(), now)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "1 series are missing")
+		assert.Contains(t, err.Error(), `series_id="1"`)
+		assert.Contains(t, err.Error(), `series_id="5"`)
+
+		assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+			# HELP mimir_continuous_test_query_result_checks_failed_total Total number of query results failed when checking for correctness.
+			# TYPE mimir_continuous_test_query_result_checks_failed_total counter
+			mimir_continuous_test_query_result_checks_failed_total{query_type="instant",read_path="query_api",storage="default",test="series-metadata"} 0
+			mimir_continuous_test_query_result_checks_failed_total{query_type="range",read_path="query_api",storage="default",test="series-metadata"} 1
+
+			# HELP mimir_continuous_test_series_mismatches_total Total number of series missing from or unexpectedly returned by the series API.
+			# TYPE mimir_continuous_test_series_mismatches_total counter
+			mimir_continuous_test_series_mismatches_total{test="series-metadata"} 2
+		`), "mimir_continuous_test_query_result_checks_failed_total", "mimir_continuous_test_series_mismatches_total"))
+	})
+
+	t.Run("should not check the series if the last write has been rejected", func(t *testing.T) {
+		client := &ClientMock{}
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(400, errors.New("bad request")).Once()
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+		client.On("Series", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]model.LabelSet{
+			labelSet(seriesMetadataMetricName, "false", "0"),
+			labelSet(seriesMetadataMetricName, "false", "1"),
+		}, nil)
+
+		test, err := NewSeriesMetadataTest(cfg, client, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+		require.NoError(t, err)
+
+		require.Error(t, test.Run(context.Background(), now))
+		client.AssertNumberOfCalls(t, "Series", 0)
+
+		require.NoError(t, test.Run(context.Background(), now.Add(cfg.WriteInterval)))
+		client.AssertNumberOfCalls(t, "WriteSeries", 2)
+		client.AssertCalled(t, "Series", mock.Anything, mock.Anything, now.Add(cfg.WriteInterval), now.Add(cfg.WriteInterval))
+	})
+}
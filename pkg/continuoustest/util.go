@@ -3,22 +3,46 @@
 package continuoustest
 
 import (
+	"errors"
 	"fmt"
+	"hash/fnv"
 	"math"
 	"math/rand"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/histogram"
 	"github.com/prometheus/prometheus/prompb"
+	"github.com/prometheus/prometheus/storage/remote"
 )
 
 const (
-	maxComparisonDelta = 0.001
+	// The period of the generated waves.
+	wavePeriod = 10 * time.Minute
+
+	// The label added to the churned series, whose value changes at each write interval.
+	churnGenerationLabel = "churn_generation"
+
+	// The maximum number of points per series returned by a range query, as enforced by the PromQL engine.
+	maxRangeQueryPoints = 11000
 )
 
+// alignTimestampToInterval returns the input timestamp truncated to a multiple of the interval since the Unix epoch.
+// The alignment is computed on the Unix timestamp in milliseconds, which doesn't count leap seconds, so the aligned
+// timestamps are evenly spaced across leap seconds. We don't use time.Time.Truncate() because it aligns to the zero
+// time, which is not aligned to the Unix epoch for every interval.
 func alignTimestampToInterval(ts time.Time, interval time.Duration) time.Time {
-	return ts.Truncate(interval)
+	intervalMillis := interval.Milliseconds()
+	if intervalMillis <= 0 {
+		return ts
+	}
+
+	millis := ts.UnixMilli()
+	return time.UnixMilli(millis - millis%intervalMillis).In(ts.Location())
 }
 
 // getQueryStep returns the query step to use to run a test query. The returned step
@@ -40,9 +64,79 @@ func getQueryStep(start, end time.Time, alignInterval time.Duration) time.Durati
 	return step
 }
 
+// The supported shapes of the generated series.
+const (
+	waveShapeSine    = "sine"
+	waveShapeSquare  = "square"
+	waveShapeCounter = "counter"
+)
+
+var waveShapes = []string{waveShapeSine, waveShapeSquare, waveShapeCounter}
+
 func generateSineWaveSeries(name string, t time.Time, numSeries int) []prompb.TimeSeries {
+	return generateSeries(name, t, numSeries, generateSineWaveValue(t))
+}
+
+func generateSineWaveValue(t time.Time) float64 {
+	radians := 2 * math.Pi * float64(t.UnixNano()) / float64(wavePeriod.Nanoseconds())
+	return math.Sin(radians)
+}
+
+// generateSineWaveSeriesWithPhaseOffsets is like generateSineWaveSeries, but the sine wave of each series is shifted
+// by a phase offset derived from its series ID, so that the series don't all have the same value. The values are
+// rounded to the input number of significant digits, if greater than 0.
+func generateSineWaveSeriesWithPhaseOffsets(name string, t time.Time, numSeries, digits int) []prompb.TimeSeries {
+	out := generateSeries(name, t, numSeries, 0)
+	for i := range out {
+		out[i].Samples[0].Value = roundToSignificantDigits(generateSineWaveValueWithPhaseOffset(t, i), digits)
+	}
+	return out
+}
+
+// generateSineWaveValueWithPhaseOffset returns the value of the series with the input series ID, written by
+// generateSineWaveSeriesWithPhaseOffsets. The series with ID 0 has no phase offset.
+func generateSineWaveValueWithPhaseOffset(t time.Time, seriesID int) float64 {
+	// The offsets are spread by the golden ratio, so that they are distinct for each series ID and don't depend on
+	// the number of written series, which may change over time.
+	_, offset := math.Modf(float64(seriesID) * (math.Sqrt(5) - 1) / 2)
+	radians := 2 * math.Pi * (float64(t.UnixNano())/float64(wavePeriod.Nanoseconds()) + offset)
+	return math.Sin(radians)
+}
+
+// generateSineWaveValuesSumWithPhaseOffsets returns the sum of the values of numSeries series written by
+// generateSineWaveSeriesWithPhaseOffsets at the input timestamp, with the same rounding.
+func generateSineWaveValuesSumWithPhaseOffsets(t time.Time, numSeries, digits int) float64 {
+	sum := 0.0
+	for i := 0; i < numSeries; i++ {
+		sum += roundToSignificantDigits(generateSineWaveValueWithPhaseOffset(t, i), digits)
+	}
+	return sum
+}
+
+func generateSquareWaveSeries(name string, t time.Time, numSeries int, period time.Duration) []prompb.TimeSeries {
+	return generateSeries(name, t, numSeries, generateSquareWaveValue(t, period))
+}
+
+// generateSquareWaveValue returns 1 in the first half of each wave period and -1 in the second half.
+func generateSquareWaveValue(t time.Time, period time.Duration) float64 {
+	if t.UnixNano()%period.Nanoseconds() < period.Nanoseconds()/2 {
+		return 1
+	}
+	return -1
+}
+
+func generateCounterSeries(name string, t time.Time, numSeries int) []prompb.TimeSeries {
+	return generateSeries(name, t, numSeries, generateCounterValue(t))
+}
+
+// generateCounterValue returns a monotonically increasing value, which increases by 1 every second. The value
+// only depends on the input time, so that it can be recovered after a restart.
+func generateCounterValue(t time.Time) float64 {
+	return float64(t.UnixMilli()) / 1000
+}
+
+func generateSeries(name string, t time.Time, numSeries int, value float64) []prompb.TimeSeries {
 	out := make([]prompb.TimeSeries, 0, numSeries)
-	value := generateSineWaveValue(t)
 
 	for i := 0; i < numSeries; i++ {
 		out = append(out, prompb.TimeSeries{
@@ -63,21 +157,327 @@ func generateSineWaveSeries(name string, t time.Time, numSeries int) []prompb.Ti
 	return out
 }
 
-func generateSineWaveValue(t time.Time) float64 {
-	period := 10 * time.Minute
-	radians := 2 * math.Pi * float64(t.UnixNano()) / float64(period.Nanoseconds())
-	return math.Sin(radians)
+// The max number of significant digits the generated values can be rounded to. A float64 value is uniquely
+// identified by 17 significant digits, so rounding to more digits has no effect.
+const maxValueRoundingDigits = 17
+
+// roundToSignificantDigits returns the float64 value closest to the input value rounded to the input number of
+// significant digits. The value is returned as is if the number of digits is 0 or the value is not finite.
+func roundToSignificantDigits(value float64, digits int) float64 {
+	if digits <= 0 || math.IsNaN(value) || math.IsInf(value, 0) {
+		return value
+	}
+
+	// Formatting the value in decimal is the simplest way to round it without accumulating errors.
+	rounded, err := strconv.ParseFloat(strconv.FormatFloat(value, 'g', digits, 64), 64)
+	if err != nil {
+		return value
+	}
+	return rounded
+}
+
+// roundSeriesValues rounds the values of the float samples of the input series to the input number of
+// significant digits, and returns the input series.
+func roundSeriesValues(series []prompb.TimeSeries, digits int) []prompb.TimeSeries {
+	for i := range series {
+		for j := range series[i].Samples {
+			series[i].Samples[j].Value = roundToSignificantDigits(series[i].Samples[j].Value, digits)
+		}
+	}
+	return series
+}
+
+// The range of the supported native histograms exponential schemas.
+const (
+	nativeHistogramSchemaMin = -4
+	nativeHistogramSchemaMax = 8
+)
+
+// histogramLayout configures the buckets of the native histograms generated by generateHistogramSeries.
+type histogramLayout struct {
+	schema int32
+
+	// The number of populated positive and negative buckets. If both are 0, a fixed set of buckets is used.
+	positiveBuckets int
+	negativeBuckets int
+}
+
+// defaultHistogramLayout is the layout of the fixed set of buckets.
+var defaultHistogramLayout = histogramLayout{schema: 1}
+
+// generateHistogramSeries returns a series with a single native histogram sample at the input timestamp. The
+// histogram has observations in the zero bucket, and in the positive and negative buckets configured by the
+// input layout.
+func generateHistogramSeries(name string, t time.Time, layout histogramLayout) prompb.TimeSeries {
+	h := &histogram.Histogram{
+		Schema:          layout.schema,
+		ZeroThreshold:   0.001,
+		ZeroCount:       2,
+		Count:           9,
+		Sum:             18.4,
+		PositiveSpans:   []histogram.Span{{Offset: 0, Length: 2}, {Offset: 1, Length: 2}},
+		PositiveBuckets: []int64{1, 1, -1, 0},
+		NegativeSpans:   []histogram.Span{{Offset: 0, Length: 2}},
+		NegativeBuckets: []int64{1, 0},
+	}
+
+	if layout.positiveBuckets > 0 || layout.negativeBuckets > 0 {
+		// The positive bucket at index i has i+1 observations, while each negative bucket has 1 observation.
+		// The buckets are delta-encoded.
+		h.PositiveSpans, h.PositiveBuckets = nil, nil
+		if layout.positiveBuckets > 0 {
+			h.PositiveSpans = []histogram.Span{{Offset: 0, Length: uint32(layout.positiveBuckets)}}
+			h.PositiveBuckets = make([]int64, layout.positiveBuckets)
+			for i := range h.PositiveBuckets {
+				h.PositiveBuckets[i] = 1
+			}
+		}
+		h.NegativeSpans, h.NegativeBuckets = nil, nil
+		if layout.negativeBuckets > 0 {
+			h.NegativeSpans = []histogram.Span{{Offset: 0, Length: uint32(layout.negativeBuckets)}}
+			h.NegativeBuckets = make([]int64, layout.negativeBuckets)
+			h.NegativeBuckets[0] = 1
+		}
+
+		h.Count = h.ZeroCount + uint64(layout.positiveBuckets*(layout.positiveBuckets+1)/2) + uint64(layout.negativeBuckets)
+		h.Sum = float64(h.Count)
+	}
+
+	return prompb.TimeSeries{
+		Labels: []prompb.Label{{
+			Name:  "__name__",
+			Value: name,
+		}},
+		Histograms: []prompb.Histogram{remote.HistogramToHistogramProto(t.UnixMilli(), h)},
+	}
+}
+
+// churnedSeriesCount returns the number of series, out of numSeries, whose identity is rotated at each interval
+// according to the input churn rate.
+func churnedSeriesCount(numSeries int, churnRate float64) int {
+	return int(math.Round(float64(numSeries) * churnRate))
+}
+
+// churnSeries rotates the identity of the first count series, by adding a label whose value is the input generation.
+// The labels are appended in place.
+func churnSeries(series []prompb.TimeSeries, count int, generation int64) []prompb.TimeSeries {
+	for i := 0; i < count && i < len(series); i++ {
+		series[i].Labels = append(series[i].Labels, prompb.Label{Name: churnGenerationLabel, Value: strconv.FormatInt(generation, 10)})
+	}
+	return series
+}
+
+// parseExtraLabels parses the input list of "name=value" pairs into labels sorted by name. The __name__, series_id
+// and churn_generation labels are reserved, because they're set by the test itself.
+func parseExtraLabels(pairs []string) ([]prompb.Label, error) {
+	out := make([]prompb.Label, 0, len(pairs))
+	seen := make(map[string]struct{}, len(pairs))
+
+	for _, pair := range pairs {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("the extra label %q is not in the name=value format", pair)
+		}
+		if !model.LabelName(name).IsValid() {
+			return nil, fmt.Errorf("the extra label name %q is invalid", name)
+		}
+		if name == model.MetricNameLabel || name == "series_id" || name == churnGenerationLabel {
+			return nil, fmt.Errorf("the extra label name %q is reserved", name)
+		}
+		if _, ok := seen[name]; ok {
+			return nil, fmt.Errorf("the extra label name %q is not unique", name)
+		}
+		seen[name] = struct{}{}
+
+		out = append(out, prompb.Label{Name: name, Value: value})
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+// parseWriteErrorActions parses the input list of "status=action" pairs into a map keyed by the status, which is
+// either a 4xx status code, like "401", or the 4xx class.
+func parseWriteErrorActions(pairs []string) (map[string]string, error) {
+	out := make(map[string]string, len(pairs))
+
+	for _, pair := range pairs {
+		status, action, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("the write error action %q is not in the status=action format", pair)
+		}
+		if status != writeErrorClass4xx {
+			if code, err := strconv.Atoi(status); err != nil || code/100 != 4 {
+				return nil, fmt.Errorf("the write error action status %q is neither a 4xx status code nor %s", status, writeErrorClass4xx)
+			}
+		}
+		switch action {
+		case writeErrorActionStop, writeErrorActionContinue:
+		default:
+			return nil, fmt.Errorf("unsupported write error action %q (supported values: %s)", action, strings.Join(writeErrorActions, ", "))
+		}
+		if _, ok := out[status]; ok {
+			return nil, fmt.Errorf("the write error action status %q is not unique", status)
+		}
+
+		out[status] = action
+	}
+	return out, nil
+}
+
+// appendLabels appends the input labels to each series. The labels are appended in place.
+func appendLabels(series []prompb.TimeSeries, labels []prompb.Label) []prompb.TimeSeries {
+	for i := range series {
+		series[i].Labels = append(series[i].Labels, labels...)
+	}
+	return series
+}
+
+// exemplarTraceIDLabel is the name of the label of the exemplars attached to the written samples.
+const exemplarTraceIDLabel = "trace_id"
+
+// countSamples returns the total number of samples of the input series.
+func countSamples(series []prompb.TimeSeries) int {
+	count := 0
+	for _, s := range series {
+		count += len(s.Samples)
+	}
+	return count
+}
+
+// appendExemplars attaches an exemplar to each sample of the input series, having the same value and timestamp
+// of the sample and a trace ID computed from the series ID and the timestamp. The exemplars are appended in place.
+func appendExemplars(series []prompb.TimeSeries) []prompb.TimeSeries {
+	for i := range series {
+		seriesID := ""
+		for _, l := range series[i].Labels {
+			if l.Name == "series_id" {
+				seriesID = l.Value
+				break
+			}
+		}
+
+		for _, sample := range series[i].Samples {
+			series[i].Exemplars = append(series[i].Exemplars, prompb.Exemplar{
+				Labels:    []prompb.Label{{Name: exemplarTraceIDLabel, Value: exemplarTraceID(seriesID, time.UnixMilli(sample.Timestamp))}},
+				Value:     sample.Value,
+				Timestamp: sample.Timestamp,
+			})
+		}
+	}
+	return series
+}
+
+// exemplarTraceID returns the trace ID of the exemplar attached to the sample of the input series written at the
+// input timestamp. The trace ID depends on the series too, so that exemplars attached to the wrong series are detected.
+func exemplarTraceID(seriesID string, t time.Time) string {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(seriesID))
+	return fmt.Sprintf("%016x%016x", h.Sum64(), t.UnixMilli())
+}
+
+// verifyExemplars checks that the input exemplars contain exactly one exemplar for each of the expected series
+// at each interval-aligned timestamp between from and to (both included), with the value generated by
+// generateValue and the trace ID computed from the series ID and the timestamp. Returns the number of exemplars
+// whose trace ID doesn't match the series they're attached to, which are reported in the error too.
+func verifyExemplars(results []v1.ExemplarQueryResult, expectedSeries int, from, to time.Time, interval time.Duration, generateValue func(time.Time) float64, tolerance float64) (driftedExemplars int, err error) {
+	expectedCount := 0
+	for ts := alignTimestampToInterval(from, interval); !ts.After(to); ts = ts.Add(interval) {
+		if !ts.Before(from) {
+			expectedCount += expectedSeries
+		}
+	}
+
+	var driftErr error
+	actualCount := 0
+	for _, result := range results {
+		for _, exemplar := range result.Exemplars {
+			actualCount++
+
+			ts := exemplar.Timestamp.Time()
+			if ts.Before(from) || ts.After(to) || !ts.Equal(alignTimestampToInterval(ts, interval)) {
+				return driftedExemplars, fmt.Errorf("exemplar of series %s has the unexpected timestamp %d", result.SeriesLabels.String(), ts.UnixMilli())
+			}
+			if expected := generateValue(ts); !compareSampleValues(expected, float64(exemplar.Value), tolerance) {
+				return driftedExemplars, fmt.Errorf("exemplar of series %s at timestamp %d has value %f while was expecting %f", result.SeriesLabels.String(), ts.UnixMilli(), float64(exemplar.Value), expected)
+			}
+
+			// Keep checking the other exemplars, in order to count all the drifted ones.
+			if actual, expected := string(exemplar.Labels[exemplarTraceIDLabel]), exemplarTraceID(string(result.SeriesLabels["series_id"]), ts); actual != expected {
+				driftedExemplars++
+				if driftErr == nil {
+					driftErr = fmt.Errorf("exemplar of series %s at timestamp %d has trace ID %q while was expecting %q", result.SeriesLabels.String(), ts.UnixMilli(), actual, expected)
+				}
+			}
+		}
+	}
+
+	if driftErr != nil {
+		return driftedExemplars, fmt.Errorf("%d exemplars are attached to the wrong series, the first one being: %w", driftedExemplars, driftErr)
+	}
+	if actualCount != expectedCount {
+		return 0, fmt.Errorf("expected %d exemplars but got %d", expectedCount, actualCount)
+	}
+	return 0, nil
+}
+
+// seriesSelector returns a PromQL series selector matching the input metric name, the input labels
+// (with equality matchers) and any additional matcher.
+func seriesSelector(name string, labels []prompb.Label, matchers ...string) string {
+	all := make([]string, 0, len(labels)+len(matchers))
+	for _, l := range labels {
+		all = append(all, fmt.Sprintf("%s=%q", l.Name, l.Value))
+	}
+	all = append(all, matchers...)
+
+	if len(all) == 0 {
+		return name
+	}
+	return name + "{" + strings.Join(all, ",") + "}"
 }
 
-// verifySineWaveSamplesSum assumes the input matrix is the result of a range query summing the values
-// of expectedSeries sine wave series and checks whether the actual values match the expected ones.
+// generateValuesSum returns the sum of the values of numSeries series generated by generateValue, whose samples
+// have been written at each interval-aligned timestamp between from and to (both included).
+func generateValuesSum(from, to time.Time, interval time.Duration, numSeries int, generateValue func(time.Time) float64) float64 {
+	sum := 0.0
+	for ts := alignTimestampToInterval(from, interval); !ts.After(to); ts = ts.Add(interval) {
+		if ts.Before(from) {
+			continue
+		}
+		sum += generateValue(ts) * float64(numSeries)
+	}
+	return sum
+}
+
+// generateValuesMinMax returns the min and max values of the samples generated by generateValue and written
+// at each interval-aligned timestamp between from and to (both included). Returns false if no sample has been
+// written in the time range.
+func generateValuesMinMax(from, to time.Time, interval time.Duration, generateValue func(time.Time) float64) (minValue, maxValue float64, ok bool) {
+	minValue, maxValue = math.Inf(1), math.Inf(-1)
+	for ts := alignTimestampToInterval(from, interval); !ts.After(to); ts = ts.Add(interval) {
+		if ts.Before(from) {
+			continue
+		}
+		value := generateValue(ts)
+		minValue = math.Min(minValue, value)
+		maxValue = math.Max(maxValue, value)
+		ok = true
+	}
+	return minValue, maxValue, ok
+}
+
+// verifySamplesSum assumes the input matrix is the result of a range query summing the values of expectedSeries
+// series generated by generateValue and checks whether the actual values match the expected ones.
 // Samples are checked in backward order, from newest to oldest. Returns error if values don't match,
 // and the index of the last sample that matched the expectation or -1 if no sample matches.
-func verifySineWaveSamplesSum(matrix model.Matrix, expectedSeries int, expectedStep time.Duration) (lastMatchingIdx int, err error) {
+func verifySamplesSum(matrix model.Matrix, expectedSeries int, expectedStep time.Duration, generateValue func(time.Time) float64, tolerance float64) (lastMatchingIdx int, err error) {
 	lastMatchingIdx = -1
 	if len(matrix) != 1 {
 		return lastMatchingIdx, fmt.Errorf("expected 1 series in the result but got %d", len(matrix))
 	}
+	if len(matrix[0].Histograms) > 0 {
+		return lastMatchingIdx, fmt.Errorf("expected only float samples in the result but got %d native histogram samples", len(matrix[0].Histograms))
+	}
 
 	samples := matrix[0].Values
 
@@ -86,8 +486,8 @@ func verifySineWaveSamplesSum(matrix model.Matrix, expectedSeries int, expectedS
 		ts := time.UnixMilli(int64(sample.Timestamp)).UTC()
 
 		// Assert on value.
-		expectedValue := generateSineWaveValue(ts) * float64(expectedSeries)
-		if !compareSampleValues(float64(sample.Value), expectedValue) {
+		expectedValue := generateValue(ts) * float64(expectedSeries)
+		if !compareSampleValues(expectedValue, float64(sample.Value), tolerance) {
 			return lastMatchingIdx, fmt.Errorf("sample at timestamp %d (%s) has value %f while was expecting %f", sample.Timestamp, ts.String(), sample.Value, expectedValue)
 		}
 
@@ -108,9 +508,166 @@ func verifySineWaveSamplesSum(matrix model.Matrix, expectedSeries int, expectedS
 	return lastMatchingIdx, nil
 }
 
-func compareSampleValues(actual, expected float64) bool {
-	delta := math.Abs((actual - expected) / maxComparisonDelta)
-	return delta < maxComparisonDelta
+// sumSeries returns a matrix with a single series whose samples are the sum, at each timestamp, of the samples
+// of all input series, sorted by timestamp. Returns an empty matrix if the input matrix has no series.
+func sumSeries(matrix model.Matrix) model.Matrix {
+	if len(matrix) == 0 {
+		return model.Matrix{}
+	}
+
+	sums := map[model.Time]model.SampleValue{}
+	for _, series := range matrix {
+		for _, sample := range series.Values {
+			sums[sample.Timestamp] += sample.Value
+		}
+	}
+
+	values := make([]model.SamplePair, 0, len(sums))
+	for ts, value := range sums {
+		values = append(values, model.SamplePair{Timestamp: ts, Value: value})
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i].Timestamp < values[j].Timestamp })
+
+	return model.Matrix{{Values: values}}
+}
+
+// countSampleTimestampDeviations returns the number of samples in the input matrix whose timestamp, in milliseconds,
+// doesn't exactly match any of the expected query evaluation timestamps: start plus a multiple of step, up to end
+// (both included). A step of 0 means that only start is expected, like for instant queries.
+func countSampleTimestampDeviations(matrix model.Matrix, start, end time.Time, step time.Duration) int {
+	startMillis, endMillis, stepMillis := start.UnixMilli(), end.UnixMilli(), step.Milliseconds()
+
+	deviations := 0
+	for _, series := range matrix {
+		for _, sample := range series.Values {
+			ts := int64(sample.Timestamp)
+
+			switch {
+			case ts < startMillis || ts > endMillis:
+				deviations++
+			case stepMillis == 0 && ts != startMillis:
+				deviations++
+			case stepMillis > 0 && (ts-startMillis)%stepMillis != 0:
+				deviations++
+			}
+		}
+	}
+
+	return deviations
+}
+
+// countSamplesSumMismatches assumes the input matrix is the result of a range query summing the values
+// of expectedSeries series generated by generateValue between start and end (both included) and returns the number of points,
+// at each step, which are missing or whose value doesn't match the expected one. Returns error if the result
+// contains more than 1 series.
+func countSamplesSumMismatches(matrix model.Matrix, expectedSeries int, start, end time.Time, step time.Duration, generateValue func(time.Time) float64, tolerance float64) (int, error) {
+	if len(matrix) > 1 {
+		return 0, fmt.Errorf("expected 1 series in the result but got %d", len(matrix))
+	}
+
+	actual := map[int64]float64{}
+	if len(matrix) == 1 {
+		for _, sample := range matrix[0].Values {
+			actual[int64(sample.Timestamp)] = float64(sample.Value)
+		}
+	}
+
+	mismatches := 0
+	for ts := start; !ts.After(end); ts = ts.Add(step) {
+		value, ok := actual[ts.UnixMilli()]
+		if !ok || !compareSampleValues(generateValue(ts)*float64(expectedSeries), value, tolerance) {
+			mismatches++
+		}
+	}
+
+	return mismatches, nil
+}
+
+// verifyLeftBoundarySample checks whether the first sample of the input matrix, which is assumed to be the result
+// of a range query summing the values of expectedSeries series generated by generateValue, has the expected timestamp and
+// the value of the sample written at lookbackTs.
+func verifyLeftBoundarySample(matrix model.Matrix, expectedTs, lookbackTs time.Time, expectedSeries int, generateValue func(time.Time) float64, tolerance float64) error {
+	if len(matrix) != 1 {
+		return fmt.Errorf("expected 1 series in the result but got %d", len(matrix))
+	}
+	if len(matrix[0].Values) == 0 {
+		return errors.New("expected at least 1 sample in the result but got none")
+	}
+
+	sample := matrix[0].Values[0]
+	if sample.Timestamp.Time().UnixMilli() != expectedTs.UnixMilli() {
+		return fmt.Errorf("first sample has timestamp %d while was expecting %d", sample.Timestamp, expectedTs.UnixMilli())
+	}
+
+	expectedValue := generateValue(lookbackTs) * float64(expectedSeries)
+	if !compareSampleValues(expectedValue, float64(sample.Value), tolerance) {
+		return fmt.Errorf("first sample at timestamp %d (%s) has value %f while was expecting %f (the value of the sample at %s)",
+			sample.Timestamp, expectedTs.UTC().String(), sample.Value, expectedValue, lookbackTs.UTC().String())
+	}
+
+	return nil
+}
+
+// compareMatrices checks whether the two input matrices, which are assumed to be the result of range queries
+// summing the written series, contain the same samples. Returns error if samples don't match.
+func compareMatrices(first, second model.Matrix, tolerance float64) error {
+	if len(first) != 1 || len(second) != 1 {
+		return fmt.Errorf("expected 1 series in both results but got %d and %d", len(first), len(second))
+	}
+
+	firstSamples, secondSamples := first[0].Values, second[0].Values
+	if len(firstSamples) != len(secondSamples) {
+		return fmt.Errorf("expected the same number of samples in both results but got %d and %d", len(firstSamples), len(secondSamples))
+	}
+
+	for idx := range firstSamples {
+		if firstSamples[idx].Timestamp != secondSamples[idx].Timestamp {
+			return fmt.Errorf("sample at index %d has timestamp %d in the first result and %d in the second one", idx, firstSamples[idx].Timestamp, secondSamples[idx].Timestamp)
+		}
+		if !compareSampleValues(float64(secondSamples[idx].Value), float64(firstSamples[idx].Value), tolerance) {
+			return fmt.Errorf("sample at timestamp %d has value %f in the first result and %f in the second one", firstSamples[idx].Timestamp, firstSamples[idx].Value, secondSamples[idx].Value)
+		}
+	}
+
+	return nil
+}
+
+// countPointMismatches returns the number of expected points missing from the input matrix or having a different value,
+// plus the number of points in the matrix which are not expected. Points of different series having the same timestamp
+// are counted as unexpected.
+func countPointMismatches(matrix model.Matrix, expected []model.SamplePair, tolerance float64) int {
+	mismatches := 0
+
+	actual := make(map[model.Time]model.SampleValue)
+	for _, series := range matrix {
+		for _, point := range series.Values {
+			if _, ok := actual[point.Timestamp]; ok {
+				mismatches++
+				continue
+			}
+			actual[point.Timestamp] = point.Value
+		}
+	}
+
+	for _, point := range expected {
+		value, ok := actual[point.Timestamp]
+		if !ok || !compareSampleValues(float64(point.Value), float64(value), tolerance) {
+			mismatches++
+		}
+		delete(actual, point.Timestamp)
+	}
+
+	return mismatches + len(actual)
+}
+
+// compareSampleValues returns whether the actual value matches the expected one within the input relative
+// tolerance. If the expected value is exactly zero, a relative tolerance is meaningless (any non-zero actual
+// value would be infinitely far from it), so the tolerance is treated as absolute.
+func compareSampleValues(expected, actual, tolerance float64) bool {
+	if expected == 0 {
+		return math.Abs(actual) <= tolerance
+	}
+	return math.Abs(actual-expected) <= tolerance*math.Abs(expected)
 }
 
 func minTime(first, second time.Time) time.Time {
@@ -136,3 +693,21 @@ func randTime(min, max time.Time) time.Time {
 	sec := rand.Int63n(delta) + min.Unix()
 	return time.Unix(sec, 0)
 }
+
+// randTimeRange returns a random time range between min and max, whose width is random between minWidth and maxWidth
+// (both included). The width is capped to the whole time range between min and max.
+func randTimeRange(rng *rand.Rand, min, max time.Time, minWidth, maxWidth time.Duration) [2]time.Time {
+	width := int64(minWidth.Seconds())
+	if delta := int64(maxWidth.Seconds()) - width; delta > 0 {
+		width += rng.Int63n(delta + 1)
+	}
+
+	// The range end is picked such that the whole range falls between min and max.
+	latestStart := max.Unix() - width
+	if latestStart <= min.Unix() {
+		return [2]time.Time{min, max}
+	}
+
+	start := time.Unix(min.Unix()+rng.Int63n(latestStart-min.Unix()+1), 0)
+	return [2]time.Time{start, start.Add(time.Duration(width) * time.Second)}
+}
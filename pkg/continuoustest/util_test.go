@@ -3,10 +3,17 @@
 package continuoustest
 
 import (
+	"math"
+	"math/rand"
+	"strconv"
 	"testing"
 	"time"
 
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/prometheus/prometheus/storage/remote"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -16,6 +23,36 @@ func TestAlignTimestampToInterval(t *testing.T) {
 	assert.Equal(t, time.Unix(30, 0), alignTimestampToInterval(time.Unix(31, 0), 10*time.Second))
 	assert.Equal(t, time.Unix(30, 0), alignTimestampToInterval(time.Unix(39, 0), 10*time.Second))
 	assert.Equal(t, time.Unix(40, 0), alignTimestampToInterval(time.Unix(40, 0), 10*time.Second))
+
+	// The timestamp is aligned to the Unix epoch even if the interval is not a divisor of a day.
+	assert.Equal(t, time.Unix(35, 0), alignTimestampToInterval(time.Unix(41, 0), 7*time.Second))
+
+	// The location of the input timestamp is preserved.
+	assert.Equal(t, time.Unix(30, 0).UTC(), alignTimestampToInterval(time.Unix(31, 0).UTC(), 10*time.Second))
+}
+
+func TestAlignTimestampToInterval_LeapSecond(t *testing.T) {
+	// A leap second has been inserted at 2016-12-31T23:59:60Z. Unix time doesn't count leap seconds,
+	// so the interval-aligned timestamps around it must be evenly spaced.
+	leapSecond := time.Date(2017, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, interval := range []time.Duration{time.Second, 7 * time.Second, 20 * time.Second, time.Minute} {
+		t.Run(interval.String(), func(t *testing.T) {
+			for offset := -3 * interval; offset <= 3*interval; offset += interval / 4 {
+				ts := leapSecond.Add(offset)
+				aligned := alignTimestampToInterval(ts, interval)
+
+				assert.Zero(t, aligned.UnixMilli()%interval.Milliseconds(), "timestamp: %s", ts)
+				assert.False(t, aligned.After(ts), "timestamp: %s", ts)
+				assert.Less(t, ts.Sub(aligned), interval, "timestamp: %s", ts)
+			}
+
+			// The leap second instant is aligned to any interval dividing a minute.
+			if time.Minute%interval == 0 {
+				assert.Equal(t, leapSecond, alignTimestampToInterval(leapSecond, interval))
+			}
+		})
+	}
 }
 
 func TestGetQueryStep(t *testing.T) {
@@ -47,7 +84,308 @@ func TestGetQueryStep(t *testing.T) {
 	}
 }
 
-func TestVerifySineWaveSamplesSum(t *testing.T) {
+func TestGenerateSineWaveSeriesWithPhaseOffsets(t *testing.T) {
+	ts := time.Unix(1234, 0)
+	series := generateSineWaveSeriesWithPhaseOffsets("test", ts, 10, 0)
+
+	require.Len(t, series, 10)
+	values := map[float64]struct{}{}
+	sum := 0.0
+	for i, s := range series {
+		assert.Equal(t, []prompb.Label{{Name: "__name__", Value: "test"}, {Name: "series_id", Value: strconv.Itoa(i)}}, s.Labels)
+		require.Len(t, s.Samples, 1)
+		assert.Equal(t, ts.UnixMilli(), s.Samples[0].Timestamp)
+		assert.Equal(t, generateSineWaveValueWithPhaseOffset(ts, i), s.Samples[0].Value)
+
+		values[s.Samples[0].Value] = struct{}{}
+		sum += s.Samples[0].Value
+	}
+
+	// Each series has a distinct value, and the first one has no phase offset.
+	assert.Len(t, values, 10)
+	assert.Equal(t, generateSineWaveValue(ts), series[0].Samples[0].Value)
+	assert.InDelta(t, sum, generateSineWaveValuesSumWithPhaseOffsets(ts, 10, 0), 1e-12)
+	assert.NotEqual(t, 10*generateSineWaveValue(ts), generateSineWaveValuesSumWithPhaseOffsets(ts, 10, 0))
+
+	// The phase offset of a series doesn't depend on the number of series.
+	assert.Equal(t, series[3].Samples[0].Value, generateSineWaveSeriesWithPhaseOffsets("test", ts, 5, 0)[3].Samples[0].Value)
+}
+
+func TestRoundToSignificantDigits(t *testing.T) {
+	tests := map[string]struct {
+		value    float64
+		digits   int
+		expected float64
+	}{
+		"no rounding":             {value: 0.123456789, digits: 0, expected: 0.123456789},
+		"round down":              {value: 0.123456789, digits: 3, expected: 0.123},
+		"round up":                {value: 0.98765, digits: 2, expected: 0.99},
+		"round negative":          {value: -0.98765, digits: 2, expected: -0.99},
+		"round large value":       {value: 123456.789, digits: 4, expected: 123500},
+		"zero":                    {value: 0, digits: 3, expected: 0},
+		"max digits are lossless": {value: 0.1 + 0.2, digits: maxValueRoundingDigits, expected: 0.1 + 0.2},
+		"infinite value":          {value: math.Inf(-1), digits: 3, expected: math.Inf(-1)},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			assert.Equal(t, testData.expected, roundToSignificantDigits(testData.value, testData.digits))
+		})
+	}
+
+	assert.True(t, math.IsNaN(roundToSignificantDigits(math.NaN(), 3)))
+}
+
+func TestRoundSeriesValues(t *testing.T) {
+	ts := time.Unix(1234, 0)
+	series := roundSeriesValues(generateSineWaveSeries("test", ts, 2), 4)
+
+	require.Len(t, series, 2)
+	for _, s := range series {
+		assert.Equal(t, []prompb.Sample{{Value: roundToSignificantDigits(generateSineWaveValue(ts), 4), Timestamp: ts.UnixMilli()}}, s.Samples)
+	}
+
+	// The sum of the rounded values of the series with phase offsets is computed with the same rounding.
+	sum := 0.0
+	for _, s := range generateSineWaveSeriesWithPhaseOffsets("test", ts, 5, 4) {
+		assert.Equal(t, roundToSignificantDigits(s.Samples[0].Value, 4), s.Samples[0].Value)
+		sum += s.Samples[0].Value
+	}
+	assert.Equal(t, sum, generateSineWaveValuesSumWithPhaseOffsets(ts, 5, 4))
+}
+
+func TestGenerateSquareWaveValue(t *testing.T) {
+	tests := map[time.Time]float64{
+		time.Unix(0, 0):   1,
+		time.Unix(299, 0): 1,
+		time.Unix(300, 0): -1,
+		time.Unix(599, 0): -1,
+		time.Unix(600, 0): 1,
+		time.Unix(900, 0): -1,
+	}
+
+	for ts, expected := range tests {
+		assert.Equal(t, expected, generateSquareWaveValue(ts, wavePeriod), "timestamp: %d", ts.Unix())
+	}
+
+	// A non-default period.
+	tests = map[time.Time]float64{
+		time.Unix(0, 0):   1,
+		time.Unix(59, 0):  1,
+		time.Unix(60, 0):  -1,
+		time.Unix(119, 0): -1,
+		time.Unix(120, 0): 1,
+		time.Unix(300, 0): -1,
+	}
+
+	for ts, expected := range tests {
+		assert.Equal(t, expected, generateSquareWaveValue(ts, 2*time.Minute), "timestamp: %d", ts.Unix())
+	}
+}
+
+func TestGenerateSquareWaveSeries(t *testing.T) {
+	ts := time.Unix(300, 0)
+	series := generateSquareWaveSeries("test", ts, 2, wavePeriod)
+
+	require.Len(t, series, 2)
+	for i, s := range series {
+		assert.Equal(t, []prompb.Label{{Name: "__name__", Value: "test"}, {Name: "series_id", Value: strconv.Itoa(i)}}, s.Labels)
+		assert.Equal(t, []prompb.Sample{{Value: -1, Timestamp: ts.UnixMilli()}}, s.Samples)
+	}
+}
+
+func TestGenerateHistogramSeries(t *testing.T) {
+	tests := map[string]struct {
+		layout                  histogramLayout
+		expectedPositiveBuckets int
+		expectedNegativeBuckets int
+	}{
+		"default layout": {
+			layout:                  defaultHistogramLayout,
+			expectedPositiveBuckets: 4,
+			expectedNegativeBuckets: 2,
+		},
+		"high resolution layout": {
+			layout:                  histogramLayout{schema: 8, positiveBuckets: 160, negativeBuckets: 40},
+			expectedPositiveBuckets: 160,
+			expectedNegativeBuckets: 40,
+		},
+		"low resolution layout with positive buckets only": {
+			layout:                  histogramLayout{schema: -4, positiveBuckets: 3},
+			expectedPositiveBuckets: 3,
+		},
+		"layout with negative buckets only": {
+			layout:                  histogramLayout{schema: 0, negativeBuckets: 5},
+			expectedNegativeBuckets: 5,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			ts := time.Unix(300, 0)
+			series := generateHistogramSeries("test", ts, testData.layout)
+
+			assert.Equal(t, []prompb.Label{{Name: "__name__", Value: "test"}}, series.Labels)
+			assert.Empty(t, series.Samples)
+			require.Len(t, series.Histograms, 1)
+			assert.Equal(t, ts.UnixMilli(), series.Histograms[0].Timestamp)
+
+			h := remote.HistogramProtoToHistogram(series.Histograms[0])
+			assert.Equal(t, testData.layout.schema, h.Schema)
+
+			// The total count must match the observations in the zero, positive and negative buckets,
+			// otherwise the histogram would be rejected on write.
+			count := h.ZeroCount
+			numBuckets := make([]int, 2)
+			for i, it := range []histogram.BucketIterator[uint64]{h.PositiveBucketIterator(), h.NegativeBucketIterator()} {
+				for it.Next() {
+					count += it.At().Count
+					numBuckets[i]++
+				}
+			}
+			assert.Equal(t, h.Count, count)
+			assert.Equal(t, []int{testData.expectedPositiveBuckets, testData.expectedNegativeBuckets}, numBuckets)
+		})
+	}
+}
+
+func TestGenerateCounterValue(t *testing.T) {
+	assert.Equal(t, 1000.0, generateCounterValue(time.Unix(1000, 0)))
+	assert.Equal(t, 1000.5, generateCounterValue(time.UnixMilli(1000500)))
+
+	// The value increases by 1 every second.
+	assert.Equal(t, 20.0, generateCounterValue(time.Unix(1020, 0))-generateCounterValue(time.Unix(1000, 0)))
+}
+
+func TestChurnedSeriesCount(t *testing.T) {
+	assert.Equal(t, 0, churnedSeriesCount(10, 0))
+	assert.Equal(t, 1, churnedSeriesCount(10, 0.1))
+	assert.Equal(t, 3, churnedSeriesCount(10, 0.25))
+	assert.Equal(t, 10, churnedSeriesCount(10, 1))
+	assert.Equal(t, 0, churnedSeriesCount(1, 0.1))
+}
+
+func TestChurnSeries(t *testing.T) {
+	ts := time.Unix(300, 0)
+	series := churnSeries(generateSineWaveSeries("test", ts, 3), 2, 15)
+
+	require.Len(t, series, 3)
+	assert.Equal(t, []prompb.Label{{Name: "__name__", Value: "test"}, {Name: "series_id", Value: "0"}, {Name: "churn_generation", Value: "15"}}, series[0].Labels)
+	assert.Equal(t, []prompb.Label{{Name: "__name__", Value: "test"}, {Name: "series_id", Value: "1"}, {Name: "churn_generation", Value: "15"}}, series[1].Labels)
+	assert.Equal(t, []prompb.Label{{Name: "__name__", Value: "test"}, {Name: "series_id", Value: "2"}}, series[2].Labels)
+
+	// The count is capped to the number of series.
+	assert.Len(t, churnSeries(generateSineWaveSeries("test", ts, 1), 2, 15)[0].Labels, 3)
+}
+
+func TestParseExtraLabels(t *testing.T) {
+	tests := map[string]struct {
+		input       []string
+		expected    []prompb.Label
+		expectedErr bool
+	}{
+		"no labels": {
+			input:    nil,
+			expected: []prompb.Label{},
+		},
+		"labels are sorted by name": {
+			input:    []string{"zone=a", "cluster=eu-west-1"},
+			expected: []prompb.Label{{Name: "cluster", Value: "eu-west-1"}, {Name: "zone", Value: "a"}},
+		},
+		"empty value": {
+			input:    []string{"cluster="},
+			expected: []prompb.Label{{Name: "cluster", Value: ""}},
+		},
+		"missing separator": {
+			input:       []string{"cluster"},
+			expectedErr: true,
+		},
+		"invalid name": {
+			input:       []string{"cluster-name=eu-west-1"},
+			expectedErr: true,
+		},
+		"reserved name": {
+			input:       []string{"series_id=1"},
+			expectedErr: true,
+		},
+		"duplicated name": {
+			input:       []string{"cluster=eu-west-1", "cluster=eu-west-2"},
+			expectedErr: true,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			actual, err := parseExtraLabels(testData.input)
+			if testData.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, testData.expected, actual)
+		})
+	}
+}
+
+func TestSeriesSelector(t *testing.T) {
+	labels := []prompb.Label{{Name: "cluster", Value: "eu-west-1"}, {Name: "zone", Value: "a"}}
+
+	assert.Equal(t, "metric", seriesSelector("metric", nil))
+	assert.Equal(t, `metric{cluster="eu-west-1",zone="a"}`, seriesSelector("metric", labels))
+	assert.Equal(t, `metric{series_id=~"[0-9]+"}`, seriesSelector("metric", nil, `series_id=~"[0-9]+"`))
+	assert.Equal(t, `metric{cluster="eu-west-1",zone="a",series_id=~"[0-9]+"}`, seriesSelector("metric", labels, `series_id=~"[0-9]+"`))
+}
+
+func TestGenerateValuesSum(t *testing.T) {
+	from := time.Unix(1000, 0)
+	to := time.Unix(1060, 0)
+
+	expected := 0.0
+	for _, ts := range []time.Time{time.Unix(1000, 0), time.Unix(1020, 0), time.Unix(1040, 0), time.Unix(1060, 0)} {
+		expected += 3 * generateSineWaveValue(ts)
+	}
+	assert.InDelta(t, expected, generateValuesSum(from, to, 20*time.Second, 3, generateSineWaveValue), 1e-9)
+
+	// Timestamps not aligned to the interval.
+	expected = 3*generateSineWaveValue(time.Unix(1020, 0)) + 3*generateSineWaveValue(time.Unix(1040, 0))
+	assert.InDelta(t, expected, generateValuesSum(from.Add(time.Second), to.Add(-time.Second), 20*time.Second, 3, generateSineWaveValue), 1e-9)
+
+	// Square wave, with 3 samples in the high plateau and 2 in the low plateau.
+	assert.InDelta(t, 3.0, generateValuesSum(time.Unix(240, 0), time.Unix(320, 0), 20*time.Second, 3, func(t time.Time) float64 { return generateSquareWaveValue(t, wavePeriod) }), 1e-9)
+}
+
+func TestGenerateValuesMinMax(t *testing.T) {
+	// The sine wave period is 10m, so the whole period is within the time range.
+	minValue, maxValue, ok := generateValuesMinMax(time.Unix(0, 0), time.Unix(600, 0), 30*time.Second, generateSineWaveValue)
+	require.True(t, ok)
+	assert.InDelta(t, -1, minValue, 1e-9)
+	assert.InDelta(t, 1, maxValue, 1e-9)
+
+	// The first quarter of the period, where the sine wave is increasing.
+	minValue, maxValue, ok = generateValuesMinMax(time.Unix(0, 0), time.Unix(150, 0), 30*time.Second, generateSineWaveValue)
+	require.True(t, ok)
+	assert.InDelta(t, 0, minValue, 1e-9)
+	assert.InDelta(t, 1, maxValue, 1e-9)
+
+	// Timestamps not aligned to the interval.
+	minValue, maxValue, ok = generateValuesMinMax(time.Unix(1, 0), time.Unix(149, 0), 30*time.Second, generateSineWaveValue)
+	require.True(t, ok)
+	assert.InDelta(t, generateSineWaveValue(time.Unix(30, 0)), minValue, 1e-9)
+	assert.InDelta(t, generateSineWaveValue(time.Unix(120, 0)), maxValue, 1e-9)
+
+	// No sample within the time range.
+	_, _, ok = generateValuesMinMax(time.Unix(1, 0), time.Unix(29, 0), 30*time.Second, generateSineWaveValue)
+	assert.False(t, ok)
+
+	// Square wave, across a plateau change.
+	minValue, maxValue, ok = generateValuesMinMax(time.Unix(240, 0), time.Unix(320, 0), 20*time.Second, func(t time.Time) float64 { return generateSquareWaveValue(t, wavePeriod) })
+	require.True(t, ok)
+	assert.Equal(t, -1.0, minValue)
+	assert.Equal(t, 1.0, maxValue)
+}
+
+func TestVerifySamplesSum(t *testing.T) {
 	// Round to millis since that's the precision of Prometheus timestamps.
 	now := time.UnixMilli(time.Now().UnixMilli()).UTC()
 
@@ -117,7 +455,7 @@ func TestVerifySineWaveSamplesSum(t *testing.T) {
 	for testName, testData := range tests {
 		t.Run(testName, func(t *testing.T) {
 			matrix := model.Matrix{{Values: testData.samples}}
-			actualLastMatchingIdx, actualErr := verifySineWaveSamplesSum(matrix, testData.expectedSeries, testData.expectedStep)
+			actualLastMatchingIdx, actualErr := verifySamplesSum(matrix, testData.expectedSeries, testData.expectedStep, generateSineWaveValue, defaultResultCheckTolerance)
 			if testData.expectedErr == "" {
 				assert.NoError(t, actualErr)
 			} else {
@@ -129,6 +467,404 @@ func TestVerifySineWaveSamplesSum(t *testing.T) {
 	}
 }
 
+func TestCountSamplesSumMismatches(t *testing.T) {
+	const (
+		numSeries = 5
+		step      = 20 * time.Second
+	)
+
+	start := time.Unix(1000, 0)
+	end := start.Add(10000 * step)
+
+	t.Run("should return 0 if all points match", func(t *testing.T) {
+		matrix := model.Matrix{{Values: generateSineWaveSamplesSum(start, end, numSeries, step)}}
+
+		mismatches, err := countSamplesSumMismatches(matrix, numSeries, start, end, step, generateSineWaveValue, defaultResultCheckTolerance)
+		require.NoError(t, err)
+		assert.Equal(t, 0, mismatches)
+	})
+
+	t.Run("should count a single point with an unexpected value", func(t *testing.T) {
+		samples := generateSineWaveSamplesSum(start, end, numSeries, step)
+		samples[5000].Value += 1
+		matrix := model.Matrix{{Values: samples}}
+
+		mismatches, err := countSamplesSumMismatches(matrix, numSeries, start, end, step, generateSineWaveValue, defaultResultCheckTolerance)
+		require.NoError(t, err)
+		assert.Equal(t, 1, mismatches)
+	})
+
+	t.Run("should count missing points", func(t *testing.T) {
+		samples := generateSineWaveSamplesSum(start, end, numSeries, step)
+		samples = append(samples[:5000], samples[5002:]...)
+		matrix := model.Matrix{{Values: samples}}
+
+		mismatches, err := countSamplesSumMismatches(matrix, numSeries, start, end, step, generateSineWaveValue, defaultResultCheckTolerance)
+		require.NoError(t, err)
+		assert.Equal(t, 2, mismatches)
+	})
+
+	t.Run("should count all points as mismatching if the result is empty", func(t *testing.T) {
+		mismatches, err := countSamplesSumMismatches(model.Matrix{}, numSeries, start, end, step, generateSineWaveValue, defaultResultCheckTolerance)
+		require.NoError(t, err)
+		assert.Equal(t, 10001, mismatches)
+	})
+
+	t.Run("should return error if the result contains more than 1 series", func(t *testing.T) {
+		matrix := model.Matrix{{}, {}}
+
+		_, err := countSamplesSumMismatches(matrix, numSeries, start, end, step, generateSineWaveValue, defaultResultCheckTolerance)
+		require.Error(t, err)
+	})
+}
+
+func TestSumSeries(t *testing.T) {
+	assert.Equal(t, model.Matrix{}, sumSeries(model.Matrix{}))
+
+	actual := sumSeries(model.Matrix{
+		{Values: []model.SamplePair{{Timestamp: 1000, Value: 1}, {Timestamp: 2000, Value: 2}}},
+		{Values: []model.SamplePair{{Timestamp: 2000, Value: 3}, {Timestamp: 3000, Value: 4}}},
+	})
+	assert.Equal(t, model.Matrix{{Values: []model.SamplePair{
+		{Timestamp: 1000, Value: 1},
+		{Timestamp: 2000, Value: 5},
+		{Timestamp: 3000, Value: 4},
+	}}}, actual)
+}
+
+func TestCountSampleTimestampDeviations(t *testing.T) {
+	start := time.Unix(1000, 0)
+	end := time.Unix(1060, 0)
+	step := 20 * time.Second
+
+	tests := map[string]struct {
+		matrix             model.Matrix
+		start, end         time.Time
+		step               time.Duration
+		expectedDeviations int
+	}{
+		"no samples": {
+			matrix: model.Matrix{},
+			start:  start,
+			end:    end,
+			step:   step,
+		},
+		"all timestamps match": {
+			matrix: model.Matrix{{Values: []model.SamplePair{
+				newSamplePair(time.Unix(1000, 0), 1),
+				newSamplePair(time.Unix(1020, 0), 1),
+				newSamplePair(time.Unix(1060, 0), 1),
+			}}},
+			start: start,
+			end:   end,
+			step:  step,
+		},
+		"a timestamp is 1ms off": {
+			matrix: model.Matrix{{Values: []model.SamplePair{
+				newSamplePair(time.Unix(1000, 0), 1),
+				newSamplePair(time.Unix(1020, 0).Add(time.Millisecond), 1),
+				newSamplePair(time.Unix(1040, 0), 1),
+			}}},
+			start:              start,
+			end:                end,
+			step:               step,
+			expectedDeviations: 1,
+		},
+		"timestamps outside the time range": {
+			matrix: model.Matrix{{Values: []model.SamplePair{
+				newSamplePair(time.Unix(980, 0), 1),
+				newSamplePair(time.Unix(1080, 0), 1),
+			}}},
+			start:              start,
+			end:                end,
+			step:               step,
+			expectedDeviations: 2,
+		},
+		"instant query timestamp matches": {
+			matrix: model.Matrix{{Values: []model.SamplePair{newSamplePair(start, 1)}}},
+			start:  start,
+			end:    start,
+		},
+		"instant query timestamp is 1ms off": {
+			matrix:             model.Matrix{{Values: []model.SamplePair{newSamplePair(start.Add(-time.Millisecond), 1)}}},
+			start:              start,
+			end:                start,
+			expectedDeviations: 1,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			assert.Equal(t, testData.expectedDeviations, countSampleTimestampDeviations(testData.matrix, testData.start, testData.end, testData.step))
+		})
+	}
+}
+
+func TestVerifyLeftBoundarySample(t *testing.T) {
+	lookbackTs := time.Unix(1000, 0)
+	start := lookbackTs.Add(10 * time.Second)
+
+	tests := map[string]struct {
+		matrix      model.Matrix
+		expectedErr string
+	}{
+		"should return no error if the first sample has the value of the sample preceding the range start": {
+			matrix: model.Matrix{{Values: []model.SamplePair{
+				newSamplePair(start, 5*generateSineWaveValue(lookbackTs)),
+				newSamplePair(start.Add(20*time.Second), 5*generateSineWaveValue(lookbackTs.Add(20*time.Second))),
+			}}},
+		},
+		"should return error if the first sample has the value of the sample following the range start": {
+			matrix: model.Matrix{{Values: []model.SamplePair{
+				newSamplePair(start, 5*generateSineWaveValue(lookbackTs.Add(20*time.Second))),
+			}}},
+			expectedErr: "first sample at timestamp .* has value .* while was expecting .*",
+		},
+		"should return error if the first sample has an unexpected timestamp": {
+			matrix: model.Matrix{{Values: []model.SamplePair{
+				newSamplePair(start.Add(20*time.Second), 5*generateSineWaveValue(lookbackTs.Add(20*time.Second))),
+			}}},
+			expectedErr: "first sample has timestamp .* while was expecting .*",
+		},
+		"should return error if the result is empty": {
+			matrix:      model.Matrix{{}},
+			expectedErr: "expected at least 1 sample in the result but got none",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			actualErr := verifyLeftBoundarySample(testData.matrix, start, lookbackTs, 5, generateSineWaveValue, defaultResultCheckTolerance)
+			if testData.expectedErr == "" {
+				assert.NoError(t, actualErr)
+			} else {
+				require.Error(t, actualErr)
+				assert.Regexp(t, testData.expectedErr, actualErr.Error())
+			}
+		})
+	}
+}
+
+func TestAppendExemplars(t *testing.T) {
+	ts := time.Unix(300, 0)
+	series := appendExemplars(generateSineWaveSeries("test", ts, 2))
+
+	require.Len(t, series, 2)
+	for i, expectedTraceID := range []string{"af63ad4c86019caf00000000000493e0", "af63ac4c86019afc00000000000493e0"} {
+		assert.Equal(t, []prompb.Exemplar{{
+			Labels:    []prompb.Label{{Name: "trace_id", Value: expectedTraceID}},
+			Value:     generateSineWaveValue(ts),
+			Timestamp: ts.UnixMilli(),
+		}}, series[i].Exemplars)
+	}
+}
+
+func TestVerifyExemplars(t *testing.T) {
+	from := time.Unix(1000, 0)
+	to := from.Add(20 * time.Second)
+
+	newExemplar := func(seriesID string, ts time.Time, value float64) v1.Exemplar {
+		return v1.Exemplar{
+			Labels:    model.LabelSet{exemplarTraceIDLabel: model.LabelValue(exemplarTraceID(seriesID, ts))},
+			Value:     model.SampleValue(value),
+			Timestamp: model.Time(ts.UnixMilli()),
+		}
+	}
+
+	tests := map[string]struct {
+		results         []v1.ExemplarQueryResult
+		expectedErr     string
+		expectedDrifted int
+	}{
+		"should return no error if there's an exemplar for each series and timestamp": {
+			results: []v1.ExemplarQueryResult{
+				{SeriesLabels: model.LabelSet{"series_id": "0"}, Exemplars: []v1.Exemplar{newExemplar("0", from, generateSineWaveValue(from)), newExemplar("0", to, generateSineWaveValue(to))}},
+				{SeriesLabels: model.LabelSet{"series_id": "1"}, Exemplars: []v1.Exemplar{newExemplar("1", from, generateSineWaveValue(from)), newExemplar("1", to, generateSineWaveValue(to))}},
+			},
+		},
+		"should return error if some exemplars are missing": {
+			results: []v1.ExemplarQueryResult{
+				{SeriesLabels: model.LabelSet{"series_id": "0"}, Exemplars: []v1.Exemplar{newExemplar("0", from, generateSineWaveValue(from)), newExemplar("0", to, generateSineWaveValue(to))}},
+				{SeriesLabels: model.LabelSet{"series_id": "1"}, Exemplars: []v1.Exemplar{newExemplar("1", to, generateSineWaveValue(to))}},
+			},
+			expectedErr: "expected 4 exemplars but got 3",
+		},
+		"should return error if no exemplars are returned": {
+			results:     []v1.ExemplarQueryResult{},
+			expectedErr: "expected 4 exemplars but got 0",
+		},
+		"should return error if an exemplar has an unexpected value": {
+			results: []v1.ExemplarQueryResult{
+				{SeriesLabels: model.LabelSet{"series_id": "0"}, Exemplars: []v1.Exemplar{newExemplar("0", from, generateSineWaveValue(from)), newExemplar("0", to, 123)}},
+			},
+			expectedErr: "exemplar of series .* at timestamp .* has value 123.000000 while was expecting .*",
+		},
+		"should return error if an exemplar has an unexpected trace ID": {
+			results: []v1.ExemplarQueryResult{
+				{SeriesLabels: model.LabelSet{"series_id": "0"}, Exemplars: []v1.Exemplar{{
+					Labels:    model.LabelSet{exemplarTraceIDLabel: "unknown"},
+					Value:     model.SampleValue(generateSineWaveValue(from)),
+					Timestamp: model.Time(from.UnixMilli()),
+				}}},
+			},
+			expectedErr:     "exemplar of series .* at timestamp .* has trace ID \"unknown\" while was expecting .*",
+			expectedDrifted: 1,
+		},
+		"should return error and count the exemplars attached to the wrong series": {
+			results: []v1.ExemplarQueryResult{
+				{SeriesLabels: model.LabelSet{"series_id": "0"}, Exemplars: []v1.Exemplar{newExemplar("1", from, generateSineWaveValue(from)), newExemplar("1", to, generateSineWaveValue(to))}},
+				{SeriesLabels: model.LabelSet{"series_id": "1"}, Exemplars: []v1.Exemplar{newExemplar("1", from, generateSineWaveValue(from)), newExemplar("1", to, generateSineWaveValue(to))}},
+			},
+			expectedErr:     "2 exemplars are attached to the wrong series, the first one being: exemplar of series .*series_id=\"0\".* at timestamp .* has trace ID .*",
+			expectedDrifted: 2,
+		},
+		"should return error if an exemplar has a timestamp not aligned to the interval": {
+			results: []v1.ExemplarQueryResult{
+				{SeriesLabels: model.LabelSet{"series_id": "0"}, Exemplars: []v1.Exemplar{newExemplar("0", from.Add(time.Second), generateSineWaveValue(from))}},
+			},
+			expectedErr: "exemplar of series .* has the unexpected timestamp .*",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			actualDrifted, actualErr := verifyExemplars(testData.results, 2, from, to, 20*time.Second, generateSineWaveValue, defaultResultCheckTolerance)
+			assert.Equal(t, testData.expectedDrifted, actualDrifted)
+			if testData.expectedErr == "" {
+				assert.NoError(t, actualErr)
+			} else {
+				require.Error(t, actualErr)
+				assert.Regexp(t, testData.expectedErr, actualErr.Error())
+			}
+		})
+	}
+}
+
+func TestCompareMatrices(t *testing.T) {
+	from := time.Unix(1000, 0)
+	to := time.Unix(1100, 0)
+	samples := generateSineWaveSamplesSum(from, to, 2, 20*time.Second)
+
+	tests := map[string]struct {
+		first       model.Matrix
+		second      model.Matrix
+		expectedErr bool
+	}{
+		"same samples": {
+			first:  model.Matrix{{Values: samples}},
+			second: model.Matrix{{Values: generateSineWaveSamplesSum(from, to, 2, 20*time.Second)}},
+		},
+		"no series in the second result": {
+			first:       model.Matrix{{Values: samples}},
+			second:      model.Matrix{},
+			expectedErr: true,
+		},
+		"missing sample in the second result": {
+			first:       model.Matrix{{Values: samples}},
+			second:      model.Matrix{{Values: samples[1:]}},
+			expectedErr: true,
+		},
+		"different timestamps": {
+			first:       model.Matrix{{Values: samples}},
+			second:      model.Matrix{{Values: generateSineWaveSamplesSum(from.Add(time.Second), to.Add(time.Second), 2, 20*time.Second)}},
+			expectedErr: true,
+		},
+		"different values": {
+			first:       model.Matrix{{Values: samples}},
+			second:      model.Matrix{{Values: generateSineWaveSamplesSum(from, to, 3, 20*time.Second)}},
+			expectedErr: true,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			err := compareMatrices(testData.first, testData.second, defaultResultCheckTolerance)
+			if testData.expectedErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestCountPointMismatches(t *testing.T) {
+	expected := []model.SamplePair{{Timestamp: 1000, Value: 1}, {Timestamp: 2000, Value: 2}}
+
+	tests := map[string]struct {
+		matrix             model.Matrix
+		expectedMismatches int
+	}{
+		"same points": {
+			matrix: model.Matrix{{Values: []model.SamplePair{{Timestamp: 1000, Value: 1}, {Timestamp: 2000, Value: 2}}}},
+		},
+		"no series": {
+			matrix:             model.Matrix{},
+			expectedMismatches: 2,
+		},
+		"missing point": {
+			matrix:             model.Matrix{{Values: []model.SamplePair{{Timestamp: 2000, Value: 2}}}},
+			expectedMismatches: 1,
+		},
+		"unexpected point": {
+			matrix:             model.Matrix{{Values: []model.SamplePair{{Timestamp: 1000, Value: 1}, {Timestamp: 1500, Value: 1}, {Timestamp: 2000, Value: 2}}}},
+			expectedMismatches: 1,
+		},
+		"different value": {
+			matrix:             model.Matrix{{Values: []model.SamplePair{{Timestamp: 1000, Value: 1}, {Timestamp: 2000, Value: 3}}}},
+			expectedMismatches: 1,
+		},
+		"same points in multiple series": {
+			matrix:             model.Matrix{{Values: expected}, {Values: expected}},
+			expectedMismatches: 2,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			assert.Equal(t, testData.expectedMismatches, countPointMismatches(testData.matrix, expected, defaultResultCheckTolerance))
+		})
+	}
+}
+
+func TestCompareSampleValues(t *testing.T) {
+	tests := map[string]struct {
+		expected, actual, tolerance float64
+		expectedMatch               bool
+	}{
+		"exact match": {
+			expected: 1.5, actual: 1.5, tolerance: 0,
+			expectedMatch: true,
+		},
+		"within the relative tolerance": {
+			expected: 1e6, actual: 1e6 + 1e-4, tolerance: 1e-9,
+			expectedMatch: true,
+		},
+		"outside the relative tolerance": {
+			expected: 1e6, actual: 1e6 + 1e-2, tolerance: 1e-9,
+			expectedMatch: false,
+		},
+		"the relative tolerance applies to negative values too": {
+			expected: -1e6, actual: -1e6 - 1e-4, tolerance: 1e-9,
+			expectedMatch: true,
+		},
+		"expected zero, within the absolute tolerance": {
+			expected: 0, actual: 1e-10, tolerance: 1e-9,
+			expectedMatch: true,
+		},
+		"expected zero, outside the absolute tolerance": {
+			expected: 0, actual: 1e-8, tolerance: 1e-9,
+			expectedMatch: false,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			assert.Equal(t, testData.expectedMatch, compareSampleValues(testData.expected, testData.actual, testData.tolerance))
+		})
+	}
+}
+
 func TestMinTime(t *testing.T) {
 	first := time.Now()
 	second := first.Add(time.Second)
@@ -156,6 +892,35 @@ func TestRandTime(t *testing.T) {
 	}
 }
 
+func TestRandTimeRange(t *testing.T) {
+	min := time.Unix(1000, 0)
+	max := time.Unix(10000, 0)
+
+	t.Run("should return a range within min and max, whose width is between the min and max width", func(t *testing.T) {
+		rng := rand.New(rand.NewSource(1))
+
+		for i := 0; i < 100; i++ {
+			actual := randTimeRange(rng, min, max, time.Minute, time.Hour)
+			require.GreaterOrEqual(t, actual[0].Unix(), min.Unix())
+			require.LessOrEqual(t, actual[1].Unix(), max.Unix())
+			require.GreaterOrEqual(t, actual[1].Sub(actual[0]), time.Minute)
+			require.LessOrEqual(t, actual[1].Sub(actual[0]), time.Hour)
+		}
+	})
+
+	t.Run("should return the whole range if narrower than the min width", func(t *testing.T) {
+		rng := rand.New(rand.NewSource(1))
+		assert.Equal(t, [2]time.Time{min, max}, randTimeRange(rng, min, max, 24*time.Hour, 48*time.Hour))
+	})
+
+	t.Run("should return the same ranges with the same seed", func(t *testing.T) {
+		first, second := rand.New(rand.NewSource(1)), rand.New(rand.NewSource(1))
+		for i := 0; i < 10; i++ {
+			assert.Equal(t, randTimeRange(first, min, max, time.Minute, time.Hour), randTimeRange(second, min, max, time.Minute, time.Hour))
+		}
+	})
+}
+
 func newSamplePair(ts time.Time, value float64) model.SamplePair {
 	return model.SamplePair{
 		Timestamp: model.Time(ts.UnixMilli()),
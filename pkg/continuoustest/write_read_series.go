@@ -4,338 +4,3513 @@ package continuoustest
 
 import (
 	"context"
+	"encoding/csv"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"os"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/pkg/errors"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/prometheus/prometheus/prompb"
+	"go.uber.org/atomic"
 	"golang.org/x/time/rate"
 
+	"github.com/grafana/dskit/backoff"
+	"github.com/grafana/dskit/concurrency"
+	"github.com/grafana/dskit/flagext"
 	"github.com/grafana/dskit/multierror"
 
+	util_math "github.com/grafana/mimir/pkg/util/math"
 	"github.com/grafana/mimir/pkg/util/spanlogger"
 )
 
 const (
-	writeInterval = 20 * time.Second
-	writeMaxAge   = 50 * time.Minute
-	metricName    = "mimir_continuous_test_sine_wave"
+	writeReadSeriesTestName = "write-read-series"
+
+	defaultWriteInterval = 20 * time.Second
+	writeMaxAge          = 50 * time.Minute
+	metricName           = "mimir_continuous_test_sine_wave"
+
+	// The default relative tolerance used when comparing query results with the expected values.
+	defaultResultCheckTolerance = 1e-9
+
+	// The metric written and queried by the one-time schema validation at startup. We use a different metric
+	// because the probe sample is not aligned to the write interval.
+	schemaProbeMetricName = "mimir_continuous_test_schema_probe"
+
+	// The metric written and queried by the out-of-order ingestion check. We use a different metric because
+	// the out-of-order samples would otherwise overlap with the samples written in order.
+	outOfOrderProbeMetricName = "mimir_continuous_test_out_of_order_probe"
+
+	// The metric written by the duplicate sample check. We use a different metric because the check writes
+	// conflicting samples, which would otherwise overlap with the samples written by the test.
+	duplicateSampleProbeMetricName = "mimir_continuous_test_duplicate_sample_probe"
+
+	// The metric written by the label order check. We use a different metric because the check writes the
+	// same series with its labels in different orders.
+	labelOrderProbeMetricName = "mimir_continuous_test_label_order_probe"
+
+	// The metric written by the histogram identity check. We use a different metric because its samples are native
+	// histograms.
+	histogramProbeMetricName = "mimir_continuous_test_histogram_probe"
+
+	// The metric written by the gap check. We use a different metric because the check skips a write interval.
+	gapProbeMetricName = "mimir_continuous_test_gap_probe"
+
+	// The metrics written by the staleness and NaN checks. We use different metrics because a staleness marker
+	// removes the series from the sum of the written series, and a NaN sample turns the sum into NaN, so the
+	// exact sum comparison of the written series would fail.
+	stalenessProbeMetricName = "mimir_continuous_test_staleness_probe"
+	nanProbeMetricName       = "mimir_continuous_test_nan_probe"
+
+	// The range selector used by the rate aggregation check.
+	rateAggregationCheckRange = 5 * time.Minute
+
+	// How frequently the burst written samples are queried until they're all queryable.
+	defaultBurstPollInterval = time.Second
+
+	// How frequently the active series are queried until their number matches the written one.
+	defaultActiveSeriesPollInterval = 5 * time.Second
+
+	// How frequently the flushed time range is queried until it's queryable from the blocks.
+	defaultBlockPathPollInterval = 10 * time.Second
+)
+
+// The supported paths through which the test writes series.
+const (
+	writePathRemoteWrite = "remote_write"
+	writePathOTLP        = "otlp"
 )
 
-var (
-	// We use max_over_time() with a 1s range selector in order to fetch only the samples we previously
-	// wrote and ensure the PromQL lookback period doesn't influence query results. This help to avoid
-	// false positives when finding the last written sample, or when restarting the testing tool with
-	// a different number of configured series to write and read.
-	queryMetricSum = fmt.Sprintf("sum(max_over_time(%s[1s]))", metricName)
+var writePaths = []string{writePathRemoteWrite, writePathOTLP}
+
+// The supported anchors of the day windows in which the queried time range is split.
+const (
+	queryAgeAnchorNow      = "now"
+	queryAgeAnchorMidnight = "midnight"
+)
+
+var queryAgeAnchors = []string{queryAgeAnchorNow, queryAgeAnchorMidnight}
+
+// The supported PromQL modifiers shifting the evaluation time of the queries run by the time modifiers check.
+const (
+	timeModifierOffset = "offset"
+	timeModifierAt     = "at"
+)
+
+var timeModifiers = []string{timeModifierOffset, timeModifierAt}
+
+// The supported actions taken by a run when a write is rejected with a 4xx error, and the status class matching
+// all 4xx status codes.
+const (
+	writeErrorActionStop     = "stop"
+	writeErrorActionContinue = "continue"
+	writeErrorClass4xx       = "4xx"
 )
 
+var writeErrorActions = []string{writeErrorActionStop, writeErrorActionContinue}
+
+// errWriteRejected is returned when a write request fails because of a 4xx error. The error is reported,
+// but the test keeps writing the next intervals, unless configured to stop for the status code.
+var errWriteRejected = errors.New("write request rejected")
+
+// errRunInProgress is returned when a run is skipped because the previous one is still in progress.
+var errRunInProgress = errors.New("the previous run is still in progress")
+
+// writeRejectedError wraps errWriteRejected, and carries the status code of the rejected write request.
+type writeRejectedError struct {
+	statusCode int
+	err        error
+}
+
+func (e writeRejectedError) Error() string { return e.err.Error() }
+func (e writeRejectedError) Unwrap() error { return e.err }
+
 type WriteReadSeriesTestConfig struct {
-	NumSeries   int
-	MaxQueryAge time.Duration
+	NumSeries         int
+	NumSeriesFile     string
+	MaxQueryAge       time.Duration
+	MaxCardinality    int
+	WriteInterval     time.Duration
+	WriteRetries      int
+	WriteBackoff      backoff.Config
+	WriteErrorActions flagext.StringSliceCSV
+	WriteJitter       time.Duration
+	InstanceID        string
+	WritePath         string
+	ExpectedVersion   string
+	WaveShape         string
+	SquareWavePeriod  time.Duration
+
+	SeriesPhaseOffsetsEnabled bool
+	ValueRounding             int
+
+	MaxSamplesPerWrite int
+	DryRun             bool
+	WarmupDuration     time.Duration
+
+	InitQueryInterval time.Duration
+	InitQueryRetries  int
+	InitQueryBackoff  backoff.Config
+	InitSeed          bool
+
+	MetricNamePrefix string
+	ExtraLabels      flagext.StringSliceCSV
+	SeriesChurnRate  float64
+
+	ResultCheckTolerance float64
+
+	QueryAgeAnchor     string
+	QueryAgeLocation   string
+	QueryTypes         flagext.StringSliceCSV
+	QueryStep          time.Duration
+	QueryConcurrency   int
+	ParquetQueryMinAge time.Duration
+	QueryLookback      time.Duration
+
+	RandomQueryRanges        int
+	RandomQueryRangeMinWidth time.Duration
+	RandomQueryRangeMaxWidth time.Duration
+
+	WithExemplars        bool
+	ExemplarsCheckMaxAge time.Duration
+	WithOutOfOrder       bool
+	WithStaleness        bool
+	WithNaN              bool
+	WithMetadata         bool
+
+	ValidateSchemaOnStart         bool
+	LeftBoundaryCheckEnabled      bool
+	DeepRangeCheck                bool
+	CoarseStepCheckFactor         int
+	OOOWindow                     time.Duration
+	FlushCheckEnabled             bool
+	SumOverTimeCheckWindow        time.Duration
+	QueryLatencySLO               time.Duration
+	RateAggregationCheckEnabled   bool
+	MinMaxOverTimeCheckWindow     time.Duration
+	TimeModifiersCheckOffset      time.Duration
+	QueryFrontendSplitInterval    time.Duration
+	TimeModifiers                 flagext.StringSliceCSV
+	RelativeTimeCheckOffset       time.Duration
+	DuplicateSampleCheckEnabled   bool
+	InvalidStepCheckEnabled       bool
+	LabelOrderCheckEnabled        bool
+	GapCheckEnabled               bool
+	HistogramIdentityCheckEnabled bool
+	HistogramSchema               int
+	HistogramPositiveBuckets      int
+	HistogramNegativeBuckets      int
+	RegexMatcherCheckEnabled      bool
+	NameMatcherCheckEnabled       bool
+	EquivalentQueriesCheckEnabled bool
+	RemoteReadCheckEnabled        bool
+	AbsentDataCheckEnabled        bool
+	BurstIntervals                int
+	BurstPollDeadline             time.Duration
+	ActiveSeriesCheckEnabled      bool
+	ActiveSeriesIdleTimeout       time.Duration
+	ActiveSeriesPollDeadline      time.Duration
+	BlockPathCheckInterval        time.Duration
+	BlockPathPollDeadline         time.Duration
+	ReferenceCompareEnabled       bool
+
+	// CustomChecks can't be configured via CLI flags, but only when embedding the test.
+	CustomChecks []CustomCheck
+
+	// CSVReportWriter, when set, gets a CSV row appended at the end of each run, summarizing the run results.
+	// A header row is written before the first row. It can't be configured via CLI flags, but only when
+	// embedding the test.
+	CSVReportWriter io.Writer
+
+	// ReportWriter, when set, gets the RunReport of each run appended as a JSON line. It can't be configured
+	// via CLI flags, but only when embedding the test.
+	ReportWriter io.Writer
+}
+
+// CustomCheck is a PromQL expression run by the test as an instant query, whose expected result is computed
+// by the caller.
+type CustomCheck struct {
+	// Name uniquely identifies the check in metrics and logs.
+	Name string
+
+	// Query is the PromQL expression. It must return a single series.
+	Query string
+
+	// ExpectedValue returns the expected result of the query evaluated at the input time.
+	ExpectedValue func(now time.Time) float64
+}
+
+// numSeriesChange tracks the number of series written from a timestamp onward.
+type numSeriesChange struct {
+	from      time.Time
+	numSeries int
+}
+
+// RunReport is a machine-readable summary of the outcome of a single run of the test.
+type RunReport struct {
+	// Timestamp is the time the run has been executed at.
+	Timestamp time.Time `json:"timestamp"`
+
+	// Writes tracks the write requests sent by the run, including the retried ones, by the name of the
+	// written metric.
+	Writes map[string]RunReportWrites `json:"writes"`
+
+	QueriesAttempted int `json:"queries_attempted"`
+	QueriesFailed    int `json:"queries_failed"`
+
+	// The checks include both the query result checks and the additional checks.
+	ChecksPassed int `json:"checks_passed"`
+	ChecksFailed int `json:"checks_failed"`
+
+	// FirstError is the first error hit by the run, or empty if the run succeeded.
+	FirstError string `json:"first_error,omitempty"`
+}
+
+// RunReportWrites tracks the write requests sent for a metric in a RunReport.
+type RunReportWrites struct {
+	Attempted int `json:"attempted"`
+	Failed    int `json:"failed"`
+}
+
+func (cfg *WriteReadSeriesTestConfig) RegisterFlags(f *flag.FlagSet) {
+	f.IntVar(&cfg.NumSeries, "tests.write-read-series-test.num-series", 10000, "Number of series used for the test.")
+	f.StringVar(&cfg.NumSeriesFile, "tests.write-read-series-test.num-series-file", "", "Path of a file containing the number of series used for the test, read when the process receives SIGHUP. The new number of series is written from the next written interval, and the query results are checked against the number of series written at each timestamp, so that the number of series can be gradually changed without restarting the tool.")
+	f.DurationVar(&cfg.MaxQueryAge, "tests.write-read-series-test.max-query-age", 7*24*time.Hour, "How back in the past metrics can be queried at most.")
+	f.StringVar(&cfg.QueryAgeAnchor, "tests.write-read-series-test.query-age-anchor", queryAgeAnchorNow, fmt.Sprintf("The anchor of the day windows in which the queried time range is split. When set to %s, the windows span the 24h before the current time. When set to %s, the windows are aligned to the midnight in the configured location, and span a calendar day, which lasts 23h or 25h on DST transitions. Supported values: %s.", queryAgeAnchorNow, queryAgeAnchorMidnight, strings.Join(queryAgeAnchors, ", ")))
+	f.StringVar(&cfg.QueryAgeLocation, "tests.write-read-series-test.query-age-location", "Local", "The IANA time zone name of the location whose midnight the day windows are aligned to, when the query age anchor is midnight.")
+	cfg.QueryTypes = []string{queryTypeInstant, queryTypeRange}
+	f.Var(&cfg.QueryTypes, "tests.write-read-series-test.query-types", fmt.Sprintf("Comma-separated list of the types of queries run to check the written series. The queries run by the additional checks are not affected. Supported values: %s.", strings.Join(queryTypes, ", ")))
+	f.DurationVar(&cfg.WarmupDuration, "tests.write-read-series-test.warmup-duration", 0, "How long after the oldest sample of the continuously written time range the query results checks start. During the warmup, series are written but no query results checks run, so that bootstrapping a fresh tenant doesn't cause failures. The oldest written sample is recovered at startup, so the warmup is not restarted when the tool restarts. 0 to disable.")
+	f.IntVar(&cfg.RandomQueryRanges, "tests.write-read-series-test.random-query-ranges", 0, "Number of additional range queries run over a random time range, whose width is random between -tests.write-read-series-test.random-query-range-min-width and -tests.write-read-series-test.random-query-range-max-width. The time ranges are randomized with a seed derived from the time of the run, so that they can be reproduced. 0 to disable.")
+	f.DurationVar(&cfg.RandomQueryRangeMinWidth, "tests.write-read-series-test.random-query-range-min-width", time.Minute, "The minimum width of the additional random query time ranges.")
+	f.DurationVar(&cfg.RandomQueryRangeMaxWidth, "tests.write-read-series-test.random-query-range-max-width", 24*time.Hour, "The maximum width of the additional random query time ranges.")
+	f.DurationVar(&cfg.QueryStep, "tests.write-read-series-test.query-step", 0, "The step of the range queries run to check the written series. It must be a multiple of the write interval, so that each point falls on a written sample, and it's increased to a larger multiple when the queried time range would have too many points. 0 to use the write interval.")
+	f.IntVar(&cfg.QueryConcurrency, "tests.write-read-series-test.query-concurrency", 1, "Maximum number of range and instant queries checking the written series run concurrently by a single run. Increase it when a run takes longer than the run interval because of the number of queried time ranges. Runs never overlap: a run is skipped if the previous one is still in progress.")
+	f.DurationVar(&cfg.QueryLookback, "tests.write-read-series-test.query-lookback", 0, "When greater than 0, run an instant query at the time of each run, without a range selector and with the configured lookback delta sent as the lookback_delta parameter, and check that it returns the sum of the most recently written samples if they're within the lookback delta, and an empty result otherwise. The target must support the lookback_delta parameter. It can't be enabled together with the series churn. 0 to disable.")
+	f.DurationVar(&cfg.ParquetQueryMinAge, "tests.write-read-series-test.parquet-query-min-age", 0, "When greater than 0, the range and instant queries run to check the written series, whose start is older than the configured age, are sent to the long-term Parquet storage query path configured in -tests.parquet-read-endpoint and -tests.parquet-read-headers. The query results are checked like the other ones, and tracked with the storage=\"parquet\" label. It should be greater than the time range served by the default query path. 0 to disable.")
+	f.DurationVar(&cfg.WriteInterval, "tests.write-read-series-test.write-interval", defaultWriteInterval, "How frequently samples are written for each series. Written samples timestamps are aligned to the interval.")
+	f.IntVar(&cfg.MaxSamplesPerWrite, "tests.write-read-series-test.max-samples-per-write", 0, "Maximum number of samples written in a single write, when the test catches up with multiple missing intervals. The samples of as many whole intervals as fit in the limit are written at once, and the write may still be split in multiple requests by the write batch size. 0 to write each interval separately.")
+	f.IntVar(&cfg.WriteRetries, "tests.write-read-series-test.write-retries", 0, "Maximum number of times a write request failed because of a network or 5xx error is retried, with exponential backoff, before giving up until the next run. 0 to disable.")
+	f.DurationVar(&cfg.WriteBackoff.MinBackoff, "tests.write-read-series-test.write-backoff-min-period", 100*time.Millisecond, "Minimum delay before retrying a failed write request.")
+	f.DurationVar(&cfg.WriteBackoff.MaxBackoff, "tests.write-read-series-test.write-backoff-max-period", 2*time.Second, "Maximum delay before retrying a failed write request.")
+	cfg.WriteErrorActions = []string{"401=" + writeErrorActionStop, "403=" + writeErrorActionStop, "413=" + writeErrorActionStop, writeErrorClass4xx + "=" + writeErrorActionContinue}
+	f.Var(&cfg.WriteErrorActions, "tests.write-read-series-test.write-error-actions", fmt.Sprintf("Comma-separated list of status=action pairs, defining whether a run keeps writing the next intervals after a write has been rejected with a 4xx error. The status is either a 4xx status code or %s, matching the status codes not explicitly listed. Rejected writes are never retried, except 429 ones, which are retried with backoff up to the configured write retries before the action is taken. Writes rejected with the 415 status code, because the content type of the write requests is not supported, and writes failed because of a 5xx or network error always stop the run, and are retried by the next one. Supported actions: %s.", writeErrorClass4xx, strings.Join(writeErrorActions, ", ")))
+	f.BoolVar(&cfg.DryRun, "tests.write-read-series-test.dry-run", false, "Log the series that would be written, and the range and instant queries that would be run with their time ranges, without sending any request. The written series are assumed to be successfully written. The additional checks are skipped. Use it to validate the configuration before sending any traffic to a cluster.")
+	f.BoolVar(&cfg.InitSeed, "tests.write-read-series-test.init-seed", false, "When no previously written samples are found at startup, for example when writing to a new tenant, backfill the samples of the max query age, so that the query results can be checked right away. Samples older than 50m are rejected by Mimir unless within the out-of-order window, so the backfilled time range is limited to the greater of 50m and -tests.write-read-series-test.out-of-order-window.")
+	f.DurationVar(&cfg.InitQueryInterval, "tests.write-read-series-test.init-query-interval", 0, "How long to wait between the consecutive range queries run at startup to find the previously written samples, one for each day window, in order to reduce the load on the cluster. 0 to disable.")
+	f.IntVar(&cfg.InitQueryRetries, "tests.write-read-series-test.init-query-retries", 5, "Maximum number of times a range query run at startup to find the previously written samples is retried, with exponential backoff, if it's rate limited (429). The search stops if the query fails for any other reason, or if it's still rate limited after all retries. 0 to disable.")
+	f.DurationVar(&cfg.InitQueryBackoff.MinBackoff, "tests.write-read-series-test.init-query-backoff-min-period", time.Second, "Minimum delay before retrying a rate limited range query run at startup.")
+	f.DurationVar(&cfg.InitQueryBackoff.MaxBackoff, "tests.write-read-series-test.init-query-backoff-max-period", 30*time.Second, "Maximum delay before retrying a rate limited range query run at startup.")
+	f.Var(&cfg.ExtraLabels, "tests.write-read-series-test.extra-labels", "Comma-separated list of name=value labels added to all written series, and used to select them when querying. Useful to distinguish the series written by different instances of the tool.")
+	f.Float64Var(&cfg.SeriesChurnRate, "tests.write-read-series-test.series-churn-rate", 0, "Fraction of the written series, between 0 and 1, whose identity is rotated at each write interval, by adding a label whose value changes at every interval. The same number of series is written at each interval, so the query results checks are not affected, but the number of series created over time increases. 0 to disable.")
+	f.DurationVar(&cfg.WriteJitter, "tests.write-read-series-test.write-jitter", 0, "When greater than 0, each write is delayed by a stable pseudo-random offset, lower than the configured jitter, derived from the instance ID. Use it to spread the writes of multiple instances of the tool over the write interval. The written samples timestamps are still aligned to the write interval. It must be lower than the write interval. 0 to disable.")
+	f.StringVar(&cfg.InstanceID, "tests.write-read-series-test.instance-id", "", "The ID of this instance of the tool, used to compute the write jitter offset. Instances with the same ID write at the same instant.")
+	f.StringVar(&cfg.ExpectedVersion, "tests.write-read-series-test.expected-version", "", "The version of Mimir the target is expected to run. When set, the version reported by the build info API is checked at startup, and a warning is logged and the mimir_continuous_test_target_version_info metric is set if it differs, for example while the cluster is being upgraded. The test runs regardless of the outcome.")
+	f.StringVar(&cfg.WritePath, "tests.write-read-series-test.write-path", writePathRemoteWrite, fmt.Sprintf("The path through which series are written. Supported values: %s.", strings.Join(writePaths, ", ")))
+	f.StringVar(&cfg.WaveShape, "tests.write-read-series-test.wave-shape", waveShapeSine, fmt.Sprintf("The shape of the values of the written series. Supported values: %s.", strings.Join(waveShapes, ", ")))
+	f.IntVar(&cfg.ValueRounding, "tests.write-read-series-test.value-rounding", 0, fmt.Sprintf("When greater than 0, round the generated values to the configured number of significant digits before writing them, and check the query results against the rounded values, so that both are on the same grid of float values. Up to %d digits. Not supported with the counter wave shape. 0 to disable.", maxValueRoundingDigits))
+	f.DurationVar(&cfg.SquareWavePeriod, "tests.write-read-series-test.square-wave-period", wavePeriod, "The period of the square wave, when the square wave shape is configured. The values alternate between the high and low plateaus every half period. It must be a multiple of twice the write interval.")
+	f.BoolVar(&cfg.SeriesPhaseOffsetsEnabled, "tests.write-read-series-test.series-phase-offsets-enabled", false, "Shift the sine wave of each written series by a distinct phase offset, derived from its series_id, so that the series don't all have the same value, and check that the query results match the sum of the values of each series. Supported only with the sine wave shape. Changing it makes the samples written by the previous runs not match the expected values.")
+	f.StringVar(&cfg.MetricNamePrefix, "tests.write-read-series-test.metric-name-prefix", "", "The prefix added to the name of the written metrics. Use it to avoid collisions when running multiple instances of the testing tool writing to the same tenant.")
+	f.Float64Var(&cfg.ResultCheckTolerance, "tests.write-read-series-test.result-check-tolerance", defaultResultCheckTolerance, "The relative tolerance used when comparing query results with the expected values. When the expected value is exactly zero, the tolerance is absolute.")
+	f.BoolVar(&cfg.WithExemplars, "tests.write-read-series-test.with-exemplars", false, "Attach an exemplar to each written sample, and check that the exemplars written recently are queryable.")
+	f.DurationVar(&cfg.ExemplarsCheckMaxAge, "tests.write-read-series-test.exemplars-check-max-age", time.Minute, "How back in the past exemplars are checked at most. Exemplars are kept by Mimir in a fixed-size per-tenant buffer, so older exemplars may have been evicted.")
+	f.IntVar(&cfg.MaxCardinality, "tests.write-read-series-test.max-cardinality", 0, "Maximum number of series the test is allowed to write. The testing tool fails to start if the configured test would write more series. 0 to disable.")
+	f.BoolVar(&cfg.ValidateSchemaOnStart, "tests.write-read-series-test.validate-schema-on-start", false, "Write a probe sample and query it back once at startup, before writing any test series. The testing tool terminates if the probe fails.")
+	f.BoolVar(&cfg.LeftBoundaryCheckEnabled, "tests.write-read-series-test.left-boundary-check-enabled", false, "Check that the first point of a range query, whose start falls between two written samples, is computed from the sample preceding the range start within the PromQL lookback period.")
+	f.BoolVar(&cfg.DeepRangeCheck, "tests.write-read-series-test.deep-range-check-enabled", false, "Query the whole time range up to the max query age at the write interval step, and check every single point. This is the most thorough but also the most expensive check.")
+	f.IntVar(&cfg.CoarseStepCheckFactor, "tests.write-read-series-test.coarse-step-check-factor", 0, "When greater than 1, run a range query at a step equal to the write interval multiplied by the configured factor, with points falling between two written samples, and check that each point has the value of the most recent sample written before it. 0 to disable.")
+	f.BoolVar(&cfg.FlushCheckEnabled, "tests.write-read-series-test.flush-check-enabled", false, "Trigger a flush of the ingesters at each run, through the /ingester/flush admin endpoint, and then check that the recently written series are still queryable.")
+	f.IntVar(&cfg.BurstIntervals, "tests.write-read-series-test.burst-intervals", 0, "When greater than 0, at the beginning of each run the test writes up to the configured number of intervals at once, without any rate limiting, and then queries them until they're all queryable, tracking the time it takes. 0 to disable.")
+	f.DurationVar(&cfg.BurstPollDeadline, "tests.write-read-series-test.burst-poll-deadline", time.Minute, "How long to wait for the samples written in a burst to be queryable before considering the check failed.")
+	f.BoolVar(&cfg.ActiveSeriesCheckEnabled, "tests.write-read-series-test.active-series-check-enabled", false, "Check that the number of active series reported by the active series cardinality API converges to the number of written series. The check can't be enabled together with the series churn.")
+	f.DurationVar(&cfg.ActiveSeriesIdleTimeout, "tests.write-read-series-test.active-series-idle-timeout", 10*time.Minute, "The time after which a series no longer written isn't counted as active anymore. Set it to the -ingester.active-series-metrics-idle-timeout of the target. After the number of written series has been changed, the active series check is skipped until the idle timeout has expired.")
+	f.DurationVar(&cfg.ActiveSeriesPollDeadline, "tests.write-read-series-test.active-series-poll-deadline", time.Minute, "How long to wait for the number of active series to match the number of written series before considering the check failed.")
+	f.DurationVar(&cfg.BlockPathCheckInterval, "tests.write-read-series-test.block-path-check-interval", 0, "How frequently to trigger a flush of the ingesters, through the /ingester/flush admin endpoint, and then check that the samples written since the previous flush are queryable from the flushed blocks. The flush waits until the blocks have been shipped to the storage, and then the flushed time range is queried until it's queryable or -tests.write-read-series-test.block-path-poll-deadline expires, to tolerate the delay with which the blocks are loaded. To check the store-gateways read path, configure the queriers to not query the ingesters for the flushed time range. 0 to disable.")
+	f.DurationVar(&cfg.BlockPathPollDeadline, "tests.write-read-series-test.block-path-poll-deadline", 5*time.Minute, "How long to wait for the flushed time range to be queryable before considering the block path check failed.")
+	f.BoolVar(&cfg.ReferenceCompareEnabled, "tests.write-read-series-test.reference-compare-enabled", false, "Write the series also to the secondary backend, configured with -tests.secondary-write-endpoint, and compare the result of each range and instant query with the result of the same query run against it. The query results are still checked against the expected values. When enabled, the secondary backend is used as a reference and not tested independently, and a single write endpoint and tenant must be configured.")
+	f.BoolVar(&cfg.AbsentDataCheckEnabled, "tests.write-read-series-test.absent-data-check-enabled", false, "Check that no samples are returned between the max query age and the oldest sample written by the test, where no data is expected to exist. Enable it only when the test writes to a tenant having no data written by previous runs.")
+	f.BoolVar(&cfg.RemoteReadCheckEnabled, "tests.write-read-series-test.remote-read-check-enabled", false, "Read the raw samples written in the last hour through the remote read API, and check that their sum matches the expected one.")
+	f.BoolVar(&cfg.EquivalentQueriesCheckEnabled, "tests.write-read-series-test.equivalent-queries-check-enabled", false, "Check that two logically identical but textually different range queries return the same result when the results cache is enabled, in order to catch results cache key issues.")
+	f.BoolVar(&cfg.NameMatcherCheckEnabled, "tests.write-read-series-test.name-matcher-check-enabled", false, "Check that a query selecting the written series with an explicit __name__ label matcher returns the same result of the query selecting them by the bare metric name.")
+	f.BoolVar(&cfg.RegexMatcherCheckEnabled, "tests.write-read-series-test.regex-matcher-check-enabled", false, "Check that a query with a regex label matcher matching all written series returns the same result of the query without the matcher.")
+	f.BoolVar(&cfg.DuplicateSampleCheckEnabled, "tests.write-read-series-test.duplicate-sample-check-enabled", false, "Check that writing a sample with the same timestamp but a different value of an already written sample is rejected.")
+	f.BoolVar(&cfg.InvalidStepCheckEnabled, "tests.write-read-series-test.invalid-step-check-enabled", false, "Check that range queries with a zero or negative step are rejected.")
+	f.BoolVar(&cfg.LabelOrderCheckEnabled, "tests.write-read-series-test.label-order-check-enabled", false, "Check that writing the same series with its labels in different orders results in a single series.")
+	f.BoolVar(&cfg.GapCheckEnabled, "tests.write-read-series-test.gap-check-enabled", false, "Write a probe series skipping a write interval, and check that range queries return the points surrounding the gap, that the gap is filled by the PromQL lookback when selecting the series, and that it's omitted when selecting a range shorter than the write interval. The PromQL lookback period must be greater than the write interval.")
+	f.BoolVar(&cfg.HistogramIdentityCheckEnabled, "tests.write-read-series-test.histogram-identity-check-enabled", false, "Write a native histogram probe sample, and check that adding to it the same histogram multiplied by 0 returns the original histogram, in order to catch arithmetic bugs in native histograms operations. The probe sample is always written through the remote write API. It requires the native histograms ingestion to be enabled for the tenant.")
+	f.IntVar(&cfg.HistogramSchema, "tests.write-read-series-test.histogram-schema", int(defaultHistogramLayout.schema), fmt.Sprintf("The schema of the native histogram probe samples, between %d and %d. Higher schemas have a higher resolution.", nativeHistogramSchemaMin, nativeHistogramSchemaMax))
+	f.IntVar(&cfg.HistogramPositiveBuckets, "tests.write-read-series-test.histogram-positive-buckets", 0, "The number of populated positive buckets of the native histogram probe samples. If both the positive and negative buckets are 0, a fixed set of a few buckets is used.")
+	f.IntVar(&cfg.HistogramNegativeBuckets, "tests.write-read-series-test.histogram-negative-buckets", 0, "The number of populated negative buckets of the native histogram probe samples. If both the positive and negative buckets are 0, a fixed set of a few buckets is used.")
+	f.DurationVar(&cfg.MinMaxOverTimeCheckWindow, "tests.write-read-series-test.min-max-over-time-check-window", 0, "When greater than 0, check that min_over_time() and max_over_time() over the configured window match the min and max of the written values in the window. 0 to disable.")
+	f.BoolVar(&cfg.RateAggregationCheckEnabled, "tests.write-read-series-test.rate-aggregation-check-enabled", false, "Check that the sum of the rates of the written series matches the rate of their sum.")
+	f.DurationVar(&cfg.SumOverTimeCheckWindow, "tests.write-read-series-test.sum-over-time-check-window", 0, "When greater than 0, check that sum_over_time() over the configured window matches the sum of the written values in the window. 0 to disable.")
+	f.DurationVar(&cfg.TimeModifiersCheckOffset, "tests.write-read-series-test.time-modifiers-check-offset", 0, "When greater than 0, check that range and instant queries using the configured time modifiers to shift the evaluation time back by the configured duration return the values written at the shifted time. It must be a multiple of the write interval. 0 to disable.")
+	cfg.TimeModifiers = []string{timeModifierOffset, timeModifierAt}
+	f.Var(&cfg.TimeModifiers, "tests.write-read-series-test.time-modifiers", fmt.Sprintf("Comma-separated list of the PromQL modifiers used by the time modifiers check. The offset modifier shifts each evaluation step back by the check offset, while the @ modifier pins all steps to the most recently written sample minus the check offset. Supported values: %s.", strings.Join(timeModifiers, ", ")))
+	f.DurationVar(&cfg.RelativeTimeCheckOffset, "tests.write-read-series-test.relative-time-check-offset", 0, "When greater than 0, check that an instant query whose evaluation time is the relative time string now-<offset>, parsed by the server, returns the sum of the values written at the evaluation time. The check is skipped if the server rejects relative times with a 400 status code. 0 to disable.")
+	f.DurationVar(&cfg.QueryFrontendSplitInterval, "tests.write-read-series-test.query-frontend-split-interval", 0, "The interval the query-frontend splits range queries by. When greater than 0, check that a range query straddling the most recent split boundary returns the same points of the instant queries run at each step, in order to catch samples lost at the split boundaries. 0 to disable.")
+	f.DurationVar(&cfg.QueryLatencySLO, "tests.write-read-series-test.query-latency-slo", 0, "When greater than 0, queries taking longer than the configured latency are tracked as SLO violations. 0 to disable.")
+	f.BoolVar(&cfg.WithOutOfOrder, "tests.write-read-series-test.with-out-of-order", false, "At each run writing multiple intervals, hold back one interval, up to half of the out-of-order window before the last one, and write it after the following intervals, so that it's ingested out-of-order. The query results checks include the out-of-order samples. It requires the out-of-order window to be at least the write interval.")
+	f.BoolVar(&cfg.WithStaleness, "tests.write-read-series-test.with-staleness", false, "Write two probe series, and then a staleness marker for one of them at the following interval, and check that the sum of both series is returned before the staleness marker, and that the stale series stops being returned by instant queries at the staleness marker timestamp. The probe series are not included in the exact sum comparison of the written series. They're always written through the remote write API.")
+	f.BoolVar(&cfg.WithMetadata, "tests.write-read-series-test.with-metadata", false, "At each run, write the metadata of the written metric, and check that the metadata API returns it right after the write, because the metadata may be only kept for a limited time. The metadata is always written through the remote write API.")
+	f.BoolVar(&cfg.WithNaN, "tests.write-read-series-test.with-nan", false, "Write two probe series, one of them with a NaN sample, and check that their sum is NaN, rather than the NaN sample being dropped. The probe series are not included in the exact sum comparison of the written series. They're always written through the remote write API.")
+	f.DurationVar(&cfg.OOOWindow, "tests.write-read-series-test.out-of-order-window", 0, "The out-of-order time window configured in Mimir for the tenant. When greater than 0, the test checks that an out-of-order sample within the window is ingested and queryable. 0 to disable.")
+}
+
+// Validate checks that the config values are consistent with each other. The values parsed when creating the
+// test, like the wave shape, the query types and the extra labels, are checked by NewWriteReadSeriesTest.
+func (cfg *WriteReadSeriesTestConfig) Validate() error {
+	if cfg.WriteInterval <= 0 {
+		return errors.New("the write interval must be greater than 0")
+	}
+	if cfg.NumSeries <= 0 {
+		return fmt.Errorf("the number of series must be greater than 0 but got %d", cfg.NumSeries)
+	}
+	if cfg.MaxQueryAge < cfg.WriteInterval {
+		return fmt.Errorf("the max query age must be at least the write interval (%s) but got %s", cfg.WriteInterval, cfg.MaxQueryAge)
+	}
+	if cfg.ResultCheckTolerance < 0 {
+		return fmt.Errorf("the result check tolerance must be greater than or equal to 0 but got %f", cfg.ResultCheckTolerance)
+	}
+	if cfg.WithExemplars && cfg.ExemplarsCheckMaxAge <= 0 {
+		return errors.New("the exemplars check max age must be greater than 0")
+	}
+	if cfg.WriteJitter < 0 || cfg.WriteJitter >= cfg.WriteInterval {
+		return fmt.Errorf("the write jitter must be between 0 and the write interval %s but got %s", cfg.WriteInterval, cfg.WriteJitter)
+	}
+	if cfg.MaxSamplesPerWrite < 0 {
+		return fmt.Errorf("the max samples per write must be greater than or equal to 0 but got %d", cfg.MaxSamplesPerWrite)
+	}
+	if cfg.WithOutOfOrder && cfg.OOOWindow < cfg.WriteInterval {
+		return fmt.Errorf("the out-of-order window must be at least the write interval (%s) when writing out-of-order samples but got %s", cfg.WriteInterval, cfg.OOOWindow)
+	}
+	if cfg.CoarseStepCheckFactor < 0 {
+		return fmt.Errorf("the coarse step check factor must be greater than or equal to 0 but got %d", cfg.CoarseStepCheckFactor)
+	}
+	if cfg.QueryStep < 0 || cfg.QueryStep%cfg.WriteInterval != 0 {
+		return fmt.Errorf("the query step must be a multiple of the write interval (%s) but got %s", cfg.WriteInterval, cfg.QueryStep)
+	}
+	if cfg.QueryConcurrency <= 0 {
+		return fmt.Errorf("the query concurrency must be greater than 0 but got %d", cfg.QueryConcurrency)
+	}
+	if cfg.RandomQueryRanges < 0 {
+		return fmt.Errorf("the number of random query ranges must be greater than or equal to 0 but got %d", cfg.RandomQueryRanges)
+	}
+	if cfg.RandomQueryRanges > 0 && (cfg.RandomQueryRangeMinWidth <= 0 || cfg.RandomQueryRangeMaxWidth < cfg.RandomQueryRangeMinWidth) {
+		return fmt.Errorf("the random query range min width must be greater than 0 and not greater than the max width but got %s and %s", cfg.RandomQueryRangeMinWidth, cfg.RandomQueryRangeMaxWidth)
+	}
+	if cfg.TimeModifiersCheckOffset < 0 || cfg.TimeModifiersCheckOffset%cfg.WriteInterval != 0 {
+		return fmt.Errorf("the time modifiers check offset must be a multiple of the write interval (%s) but got %s", cfg.WriteInterval, cfg.TimeModifiersCheckOffset)
+	}
+	if cfg.RelativeTimeCheckOffset < 0 {
+		return fmt.Errorf("the relative time check offset must be greater than or equal to 0 but got %s", cfg.RelativeTimeCheckOffset)
+	}
+	if cfg.TimeModifiersCheckOffset > 0 {
+		if len(cfg.TimeModifiers) == 0 {
+			return errors.New("at least one time modifier must be enabled when the time modifiers check is enabled")
+		}
+		for _, modifier := range cfg.TimeModifiers {
+			switch modifier {
+			case timeModifierOffset, timeModifierAt:
+			default:
+				return fmt.Errorf("unsupported time modifier %q (supported values: %s)", modifier, strings.Join(timeModifiers, ", "))
+			}
+		}
+	}
+	if cfg.HistogramSchema < nativeHistogramSchemaMin || cfg.HistogramSchema > nativeHistogramSchemaMax {
+		return fmt.Errorf("the histogram schema must be between %d and %d but got %d", nativeHistogramSchemaMin, nativeHistogramSchemaMax, cfg.HistogramSchema)
+	}
+	if cfg.HistogramPositiveBuckets < 0 || cfg.HistogramNegativeBuckets < 0 {
+		return fmt.Errorf("the number of histogram positive and negative buckets must be greater than or equal to 0 but got %d and %d", cfg.HistogramPositiveBuckets, cfg.HistogramNegativeBuckets)
+	}
+	if cfg.WarmupDuration < 0 {
+		return fmt.Errorf("the warmup duration must be greater than or equal to 0 but got %s", cfg.WarmupDuration)
+	}
+	if cfg.QueryFrontendSplitInterval < 0 {
+		return fmt.Errorf("the query-frontend split interval must be greater than or equal to 0 but got %s", cfg.QueryFrontendSplitInterval)
+	}
+	if cfg.SeriesChurnRate < 0 || cfg.SeriesChurnRate > 1 {
+		return fmt.Errorf("the series churn rate must be between 0 and 1 but got %f", cfg.SeriesChurnRate)
+	}
+	if cfg.QueryLookback < 0 {
+		return fmt.Errorf("the query lookback must be greater than or equal to 0 but got %s", cfg.QueryLookback)
+	}
+	if cfg.QueryLookback > 0 && cfg.SeriesChurnRate > 0 {
+		// The churned series are selected until the lookback delta expires, so the sum would never match.
+		return errors.New("the query lookback can't be set together with the series churn")
+	}
+	if cfg.WaveShape == waveShapeSquare && (cfg.SquareWavePeriod <= 0 || cfg.SquareWavePeriod%(2*cfg.WriteInterval) != 0) {
+		return fmt.Errorf("the square wave period must be a positive multiple of twice the write interval (%s) but got %s", 2*cfg.WriteInterval, cfg.SquareWavePeriod)
+	}
+	if cfg.ValueRounding < 0 || cfg.ValueRounding > maxValueRoundingDigits {
+		return fmt.Errorf("the value rounding must be between 0 and %d significant digits but got %d", maxValueRoundingDigits, cfg.ValueRounding)
+	}
+	if cfg.ValueRounding > 0 && cfg.WaveShape == waveShapeCounter {
+		// The rounded counter would increase in steps, so its rate would not be constant.
+		return fmt.Errorf("the value rounding is not supported with the %q wave shape", waveShapeCounter)
+	}
+	if cfg.SeriesPhaseOffsetsEnabled {
+		if cfg.WaveShape != waveShapeSine {
+			return fmt.Errorf("the series phase offsets are only supported with the %q wave shape", waveShapeSine)
+		}
+		// These checks expect all the written series to have the same value at any timestamp.
+		if cfg.WithExemplars || cfg.MinMaxOverTimeCheckWindow > 0 || cfg.RateAggregationCheckEnabled {
+			return errors.New("the series phase offsets can't be enabled together with the exemplars, the min/max over time check or the rate aggregation check")
+		}
+	}
+	if cfg.BlockPathCheckInterval < 0 {
+		return fmt.Errorf("the block path check interval must be greater than or equal to 0 but got %s", cfg.BlockPathCheckInterval)
+	}
+	if cfg.BlockPathCheckInterval > 0 && cfg.BlockPathPollDeadline <= 0 {
+		return fmt.Errorf("the block path poll deadline must be greater than 0 but got %s", cfg.BlockPathPollDeadline)
+	}
+	if cfg.ActiveSeriesCheckEnabled {
+		// The churned series are counted as active until the idle timeout expires, so the number of active
+		// series would never match the number of written series.
+		if cfg.SeriesChurnRate > 0 {
+			return errors.New("the active series check can't be enabled together with the series churn")
+		}
+		if cfg.ActiveSeriesIdleTimeout <= 0 {
+			return fmt.Errorf("the active series idle timeout must be greater than 0 but got %s", cfg.ActiveSeriesIdleTimeout)
+		}
+	}
+
+	// Ensure the test doesn't write more series than the configured budget.
+	if cardinality := cfg.cardinality(); cfg.MaxCardinality > 0 && cardinality > cfg.MaxCardinality {
+		return fmt.Errorf("the test would write %d series, which exceeds the configured max cardinality %d", cardinality, cfg.MaxCardinality)
+	}
+	return nil
+}
+
+type WriteReadSeriesTest struct {
+	name    string
+	cfg     WriteReadSeriesTestConfig
+	client  MimirClient
+	logger  log.Logger
+	metrics *TestMetrics
+
+	// The names of the written metrics and the queries used to read them back, including the configured
+	// metric name prefix.
+	metricName                     string
+	metricSelector                 string
+	metricMatchers                 []*labels.Matcher
+	schemaProbeMetricName          string
+	outOfOrderProbeMetricName      string
+	duplicateSampleProbeMetricName string
+	labelOrderProbeMetricName      string
+	gapProbeMetricName             string
+	stalenessProbeMetricName       string
+	nanProbeMetricName             string
+	histogramProbeMetricName       string
+	schemaProbeSelector            string
+	outOfOrderProbeSelector        string
+	labelOrderProbeSelector        string
+	gapProbeSelector               string
+	stalenessProbeSelector         string
+	nanProbeSelector               string
+	histogramProbeSelector         string
+	queryMetricSum                 string
+	queryMetricSumWithLookback     string
+	queryMetricSumWithRegexMatcher string
+	queryMetricSumWithNameMatcher  string
+	queryMetricSumWithParentheses  string
+	queryMetricSumOfRates          string
+	queryMetricRateOfSum           string
+
+	// Writes the input series through the configured write path.
+	writeSeries func(ctx context.Context, series []prompb.TimeSeries) (int, error)
+
+	// The generators of the written series and their values, based on the configured wave shape.
+	generateSeries func(name string, t time.Time, numSeries int) []prompb.TimeSeries
+	generateValue  func(t time.Time) float64
+
+	// The generator of the series checked by the test and of the sum of their values at a timestamp. Unlike the
+	// probe series, built by generateSeries, each series may have a distinct phase offset.
+	generateMetricSeries func(name string, t time.Time, numSeries int) []prompb.TimeSeries
+	generateSeriesSum    func(t time.Time, numSeries int) float64
+
+	// The extra labels added to every written series, including the probe series not built by generateSeries.
+	extraLabels []prompb.Label
+
+	// The actions taken when a write is rejected, keyed by 4xx status code or class.
+	writeErrorActions map[string]string
+
+	// The layout of the native histogram probe samples.
+	histogramLayout histogramLayout
+
+	// How long each write is delayed after its aligned timestamp, computed from the configured write jitter.
+	writeOffset time.Duration
+
+	// The max number of intervals written at once, computed from the configured max samples per write.
+	intervalsPerWrite int
+
+	// The min step of the range queries run to check the written series, defaulting to the write interval.
+	queryStep time.Duration
+
+	// Whether the range and instant queries are run to check the written series.
+	rangeQueriesEnabled   bool
+	instantQueriesEnabled bool
+
+	// The location whose midnight the day windows are aligned to, or nil if they're anchored to the current time.
+	queryAgeLocation *time.Location
+
+	lastWrittenTimestamp time.Time
+	queryMinTime         time.Time
+	queryMaxTime         time.Time
+
+	// The timestamp of the oldest sample written with an exemplar. Exemplars written by previous runs
+	// of the testing tool are not checked.
+	exemplarsMinTime time.Time
+
+	// The reference backend the query results are compared with, if any, and the time range continuously written
+	// to it. Samples written by previous runs of the testing tool are not compared.
+	referenceClient  MimirClient
+	referenceMinTime time.Time
+	referenceMaxTime time.Time
+
+	// The timestamp of the last sample written by the gap check.
+	gapProbeLastTimestamp time.Time
+
+	// The timestamps of the last samples written by the staleness and NaN checks.
+	stalenessProbeLastTimestamp time.Time
+	nanProbeLastTimestamp       time.Time
+
+	// Whether the server has rejected the relative time of the relative time check, which is then skipped.
+	relativeTimeUnsupported bool
+
+	// The number of series written since each timestamp, sorted by timestamp. The first change starts at the
+	// zero time. The number of series currently written is tracked by cfg.NumSeries.
+	numSeriesChanges []numSeriesChange
+
+	// The number of series set by SetNumSeries, applied by the next run. Protected by nextNumSeriesMx, because
+	// it may be set while a run is in progress.
+	nextNumSeriesMx sync.Mutex
+	nextNumSeries   int
+
+	// The wall time when Run was called the last time.
+	lastRunTime time.Time
+
+	// The time of the run which last triggered the block path check, and the end of the time range it flushed.
+	lastBlockPathCheckTime time.Time
+	lastFlushedMaxTime     time.Time
+
+	// Whether a run is in progress. A run is skipped if the previous one hasn't finished yet, because the test
+	// state is updated by each run without synchronization.
+	running atomic.Bool
+
+	// The max latency of the queries run by the current run, reported in the CSV report. Protected by
+	// maxQueryLatencyMx, because the queries checking the written series may run concurrently.
+	maxQueryLatencyMx sync.Mutex
+	maxQueryLatency   time.Duration
+
+	// Whether the CSV report header has already been written.
+	csvReportHeaderWritten bool
+
+	// The report of the current run, or nil if no run is in progress.
+	runReport *RunReport
+
+	// Used to measure the queries latency and the interval between runs. Replaceable for testing purposes.
+	timeNow func() time.Time
+
+	// How frequently the burst written samples are polled. Replaceable for testing purposes.
+	burstPollInterval time.Duration
+
+	// How frequently the active series are polled. Replaceable for testing purposes.
+	activeSeriesPollInterval time.Duration
+
+	// How frequently the flushed time range is polled. Replaceable for testing purposes.
+	blockPathPollInterval time.Duration
+}
+
+func NewWriteReadSeriesTest(cfg WriteReadSeriesTestConfig, client MimirClient, logger log.Logger, reg prometheus.Registerer) (*WriteReadSeriesTest, error) {
+	return newWriteReadSeriesTest(writeReadSeriesTestName, "", cfg, client, logger, reg)
+}
+
+// NewWriteReadSeriesTestForEndpoint returns a test writing to and reading from the input endpoint, when the
+// same series are written to multiple independent endpoints. The endpoint is part of the test name, so that
+// each endpoint is tracked independently in metrics and logs.
+func NewWriteReadSeriesTestForEndpoint(cfg WriteReadSeriesTestConfig, endpoint string, client MimirClient, logger log.Logger, reg prometheus.Registerer) (*WriteReadSeriesTest, error) {
+	return newWriteReadSeriesTest(writeReadSeriesTestName+"-"+endpoint, "", cfg, client, logger, reg)
+}
+
+// NewWriteReadSeriesTestForTenant returns a test writing to and reading from the input tenant, when the same
+// test runs for multiple tenants. The input client must be bound to the tenant. The tenant ID is added as a
+// label to the test metrics, so that each tenant is tracked independently.
+func NewWriteReadSeriesTestForTenant(cfg WriteReadSeriesTestConfig, tenantID string, client MimirClient, logger log.Logger, reg prometheus.Registerer) (*WriteReadSeriesTest, error) {
+	return newWriteReadSeriesTest(writeReadSeriesTestName, tenantID, cfg, client, logger, reg)
+}
+
+// SetReferenceClient sets the client of the reference backend the written series are also written to, and the
+// query results are compared with, when the reference compare is enabled. It must be called before running the test.
+func (t *WriteReadSeriesTest) SetReferenceClient(client MimirClient) {
+	if t.cfg.ReferenceCompareEnabled {
+		t.referenceClient = client
+	}
+}
+
+// NewWriteReadSeriesTestForSecondaryBackend returns a test writing to and reading from the secondary backend, used as
+// a reference to validate Mimir. The test name has a "secondary" suffix, so that the failures of each backend are
+// tracked independently in metrics and logs. The tenant ID must be set when the other tests run for multiple
+// tenants, so that the metrics of all tests registered to the same registry have the same labels.
+func NewWriteReadSeriesTestForSecondaryBackend(cfg WriteReadSeriesTestConfig, tenantID string, client MimirClient, logger log.Logger, reg prometheus.Registerer) (*WriteReadSeriesTest, error) {
+	return newWriteReadSeriesTest(writeReadSeriesTestName+"-secondary", tenantID, cfg, client, logger, reg)
+}
+
+func newWriteReadSeriesTest(name, tenantID string, cfg WriteReadSeriesTestConfig, client MimirClient, logger log.Logger, reg prometheus.Registerer) (*WriteReadSeriesTest, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	var (
+		generateSeries func(name string, t time.Time, numSeries int) []prompb.TimeSeries
+		generateValue  func(t time.Time) float64
+	)
+	switch cfg.WaveShape {
+	case waveShapeSine:
+		generateSeries, generateValue = generateSineWaveSeries, generateSineWaveValue
+	case waveShapeSquare:
+		generateSeries = func(name string, t time.Time, numSeries int) []prompb.TimeSeries {
+			return generateSquareWaveSeries(name, t, numSeries, cfg.SquareWavePeriod)
+		}
+		generateValue = func(t time.Time) float64 {
+			return generateSquareWaveValue(t, cfg.SquareWavePeriod)
+		}
+	case waveShapeCounter:
+		generateSeries, generateValue = generateCounterSeries, generateCounterValue
+	default:
+		return nil, fmt.Errorf("unsupported wave shape %q (supported values: %s)", cfg.WaveShape, strings.Join(waveShapes, ", "))
+	}
+	if digits := cfg.ValueRounding; digits > 0 {
+		generateWaveSeries, generateWaveValue := generateSeries, generateValue
+		generateSeries = func(name string, t time.Time, numSeries int) []prompb.TimeSeries {
+			return roundSeriesValues(generateWaveSeries(name, t, numSeries), digits)
+		}
+		generateValue = func(t time.Time) float64 {
+			return roundToSignificantDigits(generateWaveValue(t), digits)
+		}
+	}
+
+	var writeSeries func(ctx context.Context, series []prompb.TimeSeries) (int, error)
+	switch cfg.WritePath {
+	case writePathRemoteWrite:
+		writeSeries = client.WriteSeries
+	case writePathOTLP:
+		// Exemplars are not converted to OTLP.
+		if cfg.WithExemplars {
+			return nil, errors.New("exemplars are not supported when writing through OTLP")
+		}
+		writeSeries = client.WriteSeriesOTLP
+	default:
+		return nil, fmt.Errorf("unsupported write path %q (supported values: %s)", cfg.WritePath, strings.Join(writePaths, ", "))
+	}
+
+	var queryAgeLocation *time.Location
+	switch cfg.QueryAgeAnchor {
+	case queryAgeAnchorNow:
+	case queryAgeAnchorMidnight:
+		loc, err := time.LoadLocation(cfg.QueryAgeLocation)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid query age location %q", cfg.QueryAgeLocation)
+		}
+		queryAgeLocation = loc
+	default:
+		return nil, fmt.Errorf("unsupported query age anchor %q (supported values: %s)", cfg.QueryAgeAnchor, strings.Join(queryAgeAnchors, ", "))
+	}
+
+	var rangeQueriesEnabled, instantQueriesEnabled bool
+	for _, queryType := range cfg.QueryTypes {
+		switch queryType {
+		case queryTypeRange:
+			rangeQueriesEnabled = true
+		case queryTypeInstant:
+			instantQueriesEnabled = true
+		default:
+			return nil, fmt.Errorf("unsupported query type %q (supported values: %s)", queryType, strings.Join(queryTypes, ", "))
+		}
+	}
+	if !rangeQueriesEnabled && !instantQueriesEnabled {
+		return nil, errors.New("at least one query type must be enabled")
+	}
+
+	intervalsPerWrite := 1
+	if cfg.MaxSamplesPerWrite > 0 {
+		intervalsPerWrite = util_math.Max(1, cfg.MaxSamplesPerWrite/cfg.NumSeries)
+	}
+	queryStep := cfg.QueryStep
+	if queryStep == 0 {
+		queryStep = cfg.WriteInterval
+	}
+
+	// Ensure the prefixed metric names are valid.
+	prefixedMetricName := cfg.MetricNamePrefix + metricName
+	if !model.IsValidMetricName(model.LabelValue(prefixedMetricName)) {
+		return nil, fmt.Errorf("the metric name prefix %q produces the invalid metric name %q", cfg.MetricNamePrefix, prefixedMetricName)
+	}
+
+	writeErrorActions, err := parseWriteErrorActions(cfg.WriteErrorActions)
+	if err != nil {
+		return nil, err
+	}
+
+	// The extra labels are identical across all written series, so they don't affect the sum of the values.
+	extraLabels, err := parseExtraLabels(cfg.ExtraLabels)
+	if err != nil {
+		return nil, err
+	}
+	generateMetricSeries := generateSeries
+	generateSeriesSum := func(t time.Time, numSeries int) float64 {
+		return generateValue(t) * float64(numSeries)
+	}
+	if cfg.SeriesPhaseOffsetsEnabled {
+		generateMetricSeries = func(name string, t time.Time, numSeries int) []prompb.TimeSeries {
+			return generateSineWaveSeriesWithPhaseOffsets(name, t, numSeries, cfg.ValueRounding)
+		}
+		generateSeriesSum = func(t time.Time, numSeries int) float64 {
+			return generateSineWaveValuesSumWithPhaseOffsets(t, numSeries, cfg.ValueRounding)
+		}
+	}
+	if len(extraLabels) > 0 {
+		generateWaveSeries, generateWaveMetricSeries := generateSeries, generateMetricSeries
+		generateSeries = func(name string, t time.Time, numSeries int) []prompb.TimeSeries {
+			return appendLabels(generateWaveSeries(name, t, numSeries), extraLabels)
+		}
+		generateMetricSeries = func(name string, t time.Time, numSeries int) []prompb.TimeSeries {
+			return appendLabels(generateWaveMetricSeries(name, t, numSeries), extraLabels)
+		}
+	}
+	selector := seriesSelector(prefixedMetricName, extraLabels)
+	matchers := []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, model.MetricNameLabel, prefixedMetricName)}
+	for _, l := range extraLabels {
+		matchers = append(matchers, labels.MustNewMatcher(labels.MatchEqual, l.Name, l.Value))
+	}
+
+	// Ensure custom checks are uniquely identified.
+	customCheckNames := make(map[string]struct{}, len(cfg.CustomChecks))
+	for _, check := range cfg.CustomChecks {
+		if check.Name == "" {
+			return nil, errors.New("the custom check name has not been set")
+		}
+		if _, ok := customCheckNames[check.Name]; ok {
+			return nil, fmt.Errorf("the custom check name %q is not unique", check.Name)
+		}
+		if check.ExpectedValue == nil {
+			return nil, fmt.Errorf("the custom check %q has no expected value function", check.Name)
+		}
+		customCheckNames[check.Name] = struct{}{}
+	}
+
+	var metrics *TestMetrics
+	if tenantID != "" {
+		metrics = NewTenantTestMetrics(name, tenantID, reg)
+		logger = log.With(logger, "tenant", tenantID)
+	} else {
+		metrics = NewTestMetrics(name, reg)
+	}
+	metrics.cardinality.Set(float64(cfg.cardinality()))
+
+	t := &WriteReadSeriesTest{
+		name:    name,
+		cfg:     cfg,
+		client:  client,
+		logger:  log.With(logger, "test", name),
+		metrics: metrics,
+		timeNow: time.Now,
+
+		writeOffset:       writeJitterOffset(cfg.InstanceID, cfg.WriteJitter),
+		intervalsPerWrite: intervalsPerWrite,
+		queryStep:         queryStep,
+		queryAgeLocation:  queryAgeLocation,
+
+		rangeQueriesEnabled:   rangeQueriesEnabled,
+		instantQueriesEnabled: instantQueriesEnabled,
+		extraLabels:           extraLabels,
+		writeErrorActions:     writeErrorActions,
+		histogramLayout: histogramLayout{
+			schema:          int32(cfg.HistogramSchema),
+			positiveBuckets: cfg.HistogramPositiveBuckets,
+			negativeBuckets: cfg.HistogramNegativeBuckets,
+		},
+
+		metricName:                     prefixedMetricName,
+		schemaProbeMetricName:          cfg.MetricNamePrefix + schemaProbeMetricName,
+		outOfOrderProbeMetricName:      cfg.MetricNamePrefix + outOfOrderProbeMetricName,
+		duplicateSampleProbeMetricName: cfg.MetricNamePrefix + duplicateSampleProbeMetricName,
+		labelOrderProbeMetricName:      cfg.MetricNamePrefix + labelOrderProbeMetricName,
+		gapProbeMetricName:             cfg.MetricNamePrefix + gapProbeMetricName,
+		stalenessProbeMetricName:       cfg.MetricNamePrefix + stalenessProbeMetricName,
+		nanProbeMetricName:             cfg.MetricNamePrefix + nanProbeMetricName,
+		histogramProbeMetricName:       cfg.MetricNamePrefix + histogramProbeMetricName,
+		metricSelector:                 selector,
+		metricMatchers:                 matchers,
+		schemaProbeSelector:            seriesSelector(cfg.MetricNamePrefix+schemaProbeMetricName, extraLabels),
+		outOfOrderProbeSelector:        seriesSelector(cfg.MetricNamePrefix+outOfOrderProbeMetricName, extraLabels),
+		labelOrderProbeSelector:        seriesSelector(cfg.MetricNamePrefix+labelOrderProbeMetricName, extraLabels),
+		gapProbeSelector:               seriesSelector(cfg.MetricNamePrefix+gapProbeMetricName, extraLabels),
+		stalenessProbeSelector:         seriesSelector(cfg.MetricNamePrefix+stalenessProbeMetricName, extraLabels),
+		nanProbeSelector:               seriesSelector(cfg.MetricNamePrefix+nanProbeMetricName, extraLabels),
+		histogramProbeSelector:         seriesSelector(cfg.MetricNamePrefix+histogramProbeMetricName, extraLabels),
+
+		// We use max_over_time() with a 1s range selector in order to fetch only the samples we previously
+		// wrote and ensure the PromQL lookback period doesn't influence query results. This help to avoid
+		// false positives when finding the last written sample, or when restarting the testing tool with
+		// a different number of configured series to write and read.
+		queryMetricSum: fmt.Sprintf("sum(max_over_time(%s[1s]))", selector),
+
+		// Unlike queryMetricSum, this query is subject to the PromQL lookback period.
+		queryMetricSumWithLookback: fmt.Sprintf("sum(%s)", selector),
+
+		// Same as queryMetricSum, but with a regex label matcher matching all written series.
+		queryMetricSumWithRegexMatcher: fmt.Sprintf("sum(max_over_time(%s[1s]))", seriesSelector(prefixedMetricName, extraLabels, `series_id=~"[0-9]+"`)),
+
+		// Same as queryMetricSum, but the metric is selected with an explicit __name__ label matcher.
+		queryMetricSumWithNameMatcher: fmt.Sprintf("sum(max_over_time(%s[1s]))", seriesSelector("", append([]prompb.Label{{Name: model.MetricNameLabel, Value: prefixedMetricName}}, extraLabels...))),
+
+		// Logically identical to queryMetricSum, but textually different.
+		queryMetricSumWithParentheses: fmt.Sprintf("sum((max_over_time(%s[1s])))", selector),
+
+		// All series have the same value at any timestamp, so the sum of the rates is expected to match the rate
+		// of the sum. The subquery step matches the write interval, so that the subquery evaluates to the written samples.
+		queryMetricSumOfRates: fmt.Sprintf("sum(rate(%s[%s]))", selector, model.Duration(rateAggregationCheckRange)),
+		queryMetricRateOfSum:  fmt.Sprintf("rate(sum(%s)[%s:%s])", selector, model.Duration(rateAggregationCheckRange), model.Duration(cfg.WriteInterval)),
+
+		generateSeries:       generateSeries,
+		generateValue:        generateValue,
+		generateMetricSeries: generateMetricSeries,
+		generateSeriesSum:    generateSeriesSum,
+
+		numSeriesChanges: []numSeriesChange{{numSeries: cfg.NumSeries}},
+		nextNumSeries:    cfg.NumSeries,
+
+		burstPollInterval:        defaultBurstPollInterval,
+		activeSeriesPollInterval: defaultActiveSeriesPollInterval,
+		blockPathPollInterval:    defaultBlockPathPollInterval,
+	}
+
+	// All writes are tracked in the report of the run they're sent by.
+	t.writeSeries = func(ctx context.Context, series []prompb.TimeSeries) (int, error) {
+		statusCode, err := writeSeries(ctx, series)
+		t.reportWrite(series, statusCode, err)
+		return statusCode, err
+	}
+
+	return t, nil
+}
+
+// cardinality returns the number of series written by the test. Each series written for a metric is uniquely
+// identified by the series_id label, which is the only label having a variable value.
+func (cfg *WriteReadSeriesTestConfig) cardinality() int {
+	cardinality := cfg.NumSeries
+	if cfg.ValidateSchemaOnStart {
+		cardinality++
+	}
+	if cfg.OOOWindow > 0 {
+		cardinality++
+	}
+	if cfg.DuplicateSampleCheckEnabled {
+		cardinality++
+	}
+	if cfg.LabelOrderCheckEnabled {
+		cardinality++
+	}
+	if cfg.GapCheckEnabled {
+		cardinality++
+	}
+	if cfg.HistogramIdentityCheckEnabled {
+		cardinality++
+	}
+	if cfg.WithStaleness {
+		cardinality += 2
+	}
+	if cfg.WithNaN {
+		cardinality += 2
+	}
+	return cardinality
+}
+
+// Name implements Test.
+func (t *WriteReadSeriesTest) Name() string {
+	return t.name
 }
 
-func (cfg *WriteReadSeriesTestConfig) RegisterFlags(f *flag.FlagSet) {
-	f.IntVar(&cfg.NumSeries, "tests.write-read-series-test.num-series", 10000, "Number of series used for the test.")
-	f.DurationVar(&cfg.MaxQueryAge, "tests.write-read-series-test.max-query-age", 7*24*time.Hour, "How back in the past metrics can be queried at most.")
-}
+// ValidateSchema implements SchemaValidator.
+func (t *WriteReadSeriesTest) ValidateSchema(ctx context.Context, now time.Time) error {
+	if !t.cfg.ValidateSchemaOnStart {
+		return nil
+	}
+	if t.cfg.DryRun {
+		level.Info(t.logger).Log("msg", "Dry run: skipped schema validation", "metric", t.schemaProbeMetricName)
+		return nil
+	}
+
+	// Prometheus timestamps have millisecond precision.
+	ts := time.UnixMilli(now.UnixMilli())
+	logger := log.With(t.logger, "metric", t.schemaProbeMetricName, "timestamp", ts.UnixMilli())
+	level.Info(logger).Log("msg", "Validating schema by writing and querying back a probe sample")
+
+	statusCode, err := t.writeSeries(ctx, t.generateSeries(t.schemaProbeMetricName, ts, 1))
+	if err != nil {
+		return errors.Wrapf(err, "schema validation failed: failed to write the probe sample (status code: %d)", statusCode)
+	}
+	if statusCode/100 != 2 {
+		return fmt.Errorf("schema validation failed: failed to write the probe sample (status code: %d)", statusCode)
+	}
+
+	vector, err := t.client.Query(ctx, t.schemaProbeSelector, ts, WithResultsCacheEnabled(false))
+	if err != nil {
+		return errors.Wrap(err, "schema validation failed: failed to query the probe sample")
+	}
+	if len(vector) != 1 {
+		return fmt.Errorf("schema validation failed: expected 1 series when querying the probe sample but got %d", len(vector))
+	}
+
+	expectedValue := t.generateValue(ts)
+	if !compareSampleValues(expectedValue, float64(vector[0].Value), t.cfg.ResultCheckTolerance) {
+		return fmt.Errorf("schema validation failed: the probe sample has value %f while was expecting %f", vector[0].Value, expectedValue)
+	}
+
+	level.Info(logger).Log("msg", "Schema validation succeeded")
+	return nil
+}
+
+// Init implements Test. It can be safely called multiple times: each call scans the previously written samples
+// again, and recovers the same time range as long as no samples have been written in the meanwhile.
+func (t *WriteReadSeriesTest) Init(ctx context.Context, now time.Time) error {
+	if t.cfg.DryRun {
+		level.Info(t.logger).Log("msg", "Dry run: skipped finding previously written samples time range", "query", t.queryMetricSum, "end", alignTimestampToInterval(now, t.cfg.WriteInterval), "step", t.cfg.WriteInterval, "max_query_age", t.cfg.MaxQueryAge)
+		return nil
+	}
+
+	if t.cfg.ExpectedVersion != "" {
+		t.checkTargetVersion(ctx)
+	}
+
+	level.Info(t.logger).Log("msg", "Finding previously written samples time range to recover writes and reads from previous run")
+
+	from, to := t.findPreviouslyWrittenTimeRange(ctx, now)
+	if from.IsZero() || to.IsZero() {
+		if t.cfg.InitSeed {
+			t.seedSamples(ctx, now)
+			return nil
+		}
+		level.Info(t.logger).Log("msg", "No valid previously written samples time range found, will continue writing from the nearest interval-aligned timestamp")
+		return nil
+	}
+	if to.Before(now.Add(-writeMaxAge)) {
+		level.Info(t.logger).Log("msg", "Previously written samples time range found but latest written sample is too old to recover", "last_sample_timestamp", to)
+		return nil
+	}
+
+	t.lastWrittenTimestamp = to
+	t.queryMinTime = from
+	t.queryMaxTime = to
+	level.Info(t.logger).Log("msg", "Successfully found previously written samples time range and recovered writes and reads from there", "last_written_timestamp", t.lastWrittenTimestamp, "query_min_time", t.queryMinTime, "query_max_time", t.queryMaxTime)
+
+	return nil
+}
+
+// seedSamples backfills the samples of the max query age up to the input time, when no previously written samples
+// have been found, so that the query results can be checked right away. The samples are written through
+// writeSamples, like the ones written by Run, so the written time range is tracked the same way. A failed write
+// stops the seeding, but doesn't fail the initialization: the next run continues writing from the last written
+// sample, if any.
+func (t *WriteReadSeriesTest) seedSamples(ctx context.Context, now time.Time) {
+	// Mimir rejects the samples older than the write max age, unless they're within the out-of-order window.
+	seedAge := util_math.Min(t.cfg.MaxQueryAge, util_math.Max(writeMaxAge, t.cfg.OOOWindow))
+	start := alignTimestampToInterval(now.Add(-seedAge), t.cfg.WriteInterval)
+	if start.Before(now.Add(-seedAge)) {
+		start = start.Add(t.cfg.WriteInterval)
+	}
+
+	level.Info(t.logger).Log("msg", "No valid previously written samples time range found, seeding the samples", "start", start, "seed_age", seedAge)
+
+	// The seeding is not rate limited, unlike the writes catching up with the missing intervals in Run, so
+	// that the query results can be checked as soon as possible. The samples of multiple intervals are written
+	// at once, up to the configured max samples per write.
+	for timestamp := start; !timestamp.Add(t.writeOffset).After(now); timestamp = t.nextWriteTimestamp(now) {
+		timestamps := []time.Time{timestamp}
+		for next := timestamp.Add(t.cfg.WriteInterval); len(timestamps) < t.intervalsPerWrite && !next.Add(t.writeOffset).After(now); next = next.Add(t.cfg.WriteInterval) {
+			timestamps = append(timestamps, next)
+		}
+
+		if err := t.writeSamples(ctx, timestamps); err != nil {
+			level.Warn(t.logger).Log("msg", "Failed to seed the samples, will continue writing from the last written sample", "last_written_timestamp", t.lastWrittenTimestamp, "err", err)
+			return
+		}
+	}
+
+	level.Info(t.logger).Log("msg", "Successfully seeded the samples", "last_written_timestamp", t.lastWrittenTimestamp, "query_min_time", t.queryMinTime, "query_max_time", t.queryMaxTime)
+}
+
+// SetNumSeries sets the number of series written by the next runs. It can be safely called while a run is
+// in progress: the new number of series is applied by the next run.
+func (t *WriteReadSeriesTest) SetNumSeries(numSeries int) error {
+	// The number of series in the config is changed by the run, so it's copied while holding the lock.
+	t.nextNumSeriesMx.Lock()
+	defer t.nextNumSeriesMx.Unlock()
+
+	cfg := t.cfg
+	cfg.NumSeries = numSeries
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	t.nextNumSeries = numSeries
+	return nil
+}
+
+// ReloadNumSeries reads the number of series from the configured file, and sets it via SetNumSeries.
+func (t *WriteReadSeriesTest) ReloadNumSeries() error {
+	if t.cfg.NumSeriesFile == "" {
+		return errors.New("the number of series file has not been set")
+	}
+
+	data, err := os.ReadFile(t.cfg.NumSeriesFile)
+	if err != nil {
+		return errors.Wrap(err, "failed to read the number of series file")
+	}
+	numSeries, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return errors.Wrap(err, "failed to parse the number of series file")
+	}
+	if err := t.SetNumSeries(numSeries); err != nil {
+		return err
+	}
+
+	level.Info(t.logger).Log("msg", "Reloaded the number of series, which is applied by the next run", "num_series", numSeries)
+	return nil
+}
+
+// applyNumSeriesChange applies the number of series set by SetNumSeries, if changed. The new number of series
+// is written from the next written timestamp onward, so the samples already written are still checked against
+// the number of series they have been written with.
+func (t *WriteReadSeriesTest) applyNumSeriesChange(now time.Time) {
+	t.nextNumSeriesMx.Lock()
+	defer t.nextNumSeriesMx.Unlock()
+
+	numSeries := t.nextNumSeries
+	if numSeries == t.cfg.NumSeries {
+		return
+	}
+
+	from := t.nextWriteTimestamp(now)
+	if t.lastWrittenTimestamp.IsZero() {
+		// No samples have been written yet, so there's no previous number of series to keep track of.
+		t.numSeriesChanges = nil
+		from = time.Time{}
+	}
+	t.numSeriesChanges = append(t.numSeriesChanges, numSeriesChange{from: from, numSeries: numSeries})
+
+	level.Info(t.logger).Log("msg", "Changed the number of series", "previous_num_series", t.cfg.NumSeries, "num_series", numSeries, "from", from)
+	t.cfg.NumSeries = numSeries
+	t.intervalsPerWrite = 1
+	if t.cfg.MaxSamplesPerWrite > 0 {
+		t.intervalsPerWrite = util_math.Max(1, t.cfg.MaxSamplesPerWrite/numSeries)
+	}
+	t.metrics.cardinality.Set(float64(t.cfg.cardinality()))
+}
+
+// numSeriesAt returns the number of series written at the input timestamp.
+func (t *WriteReadSeriesTest) numSeriesAt(ts time.Time) int {
+	numSeries := t.numSeriesChanges[0].numSeries
+	for _, change := range t.numSeriesChanges[1:] {
+		if ts.Before(change.from) {
+			break
+		}
+		numSeries = change.numSeries
+	}
+	return numSeries
+}
+
+// numSeriesIn returns the number of series written between from and to (both included), and false if it has
+// changed in the meanwhile.
+func (t *WriteReadSeriesTest) numSeriesIn(from, to time.Time) (int, bool) {
+	numSeries := t.numSeriesAt(from)
+	for _, change := range t.numSeriesChanges[1:] {
+		if change.from.After(from) && !change.from.After(to) && change.numSeries != numSeries {
+			return 0, false
+		}
+	}
+	return numSeries, true
+}
+
+// generateSumValue returns the sum of the values of the series written at the input timestamp, based on the
+// number of series written at that time. The expected sums are computed with it, passing 1 as the number of
+// expected series, so that they track the number of series written at each timestamp.
+func (t *WriteReadSeriesTest) generateSumValue(ts time.Time) float64 {
+	return t.generateSeriesSum(ts, t.numSeriesAt(ts))
+}
+
+// checkTargetVersion checks whether the version reported by the target matches the configured expected version,
+// in order to detect a mixed-version cluster, for example while it's being upgraded. The check is diagnostic only,
+// so the outcome is just logged and tracked.
+func (t *WriteReadSeriesTest) checkTargetVersion(ctx context.Context) {
+	logger := log.With(t.logger, "expected_version", t.cfg.ExpectedVersion)
+
+	info, err := t.client.BuildInfo(ctx)
+	if err != nil {
+		level.Warn(logger).Log("msg", "Failed to get the target build info to check its version", "err", err)
+		return
+	}
+
+	t.metrics.targetVersionInfo.Reset()
+	if info.Version != t.cfg.ExpectedVersion {
+		t.metrics.targetVersionInfo.WithLabelValues(info.Version, t.cfg.ExpectedVersion).Set(1)
+		level.Warn(logger).Log("msg", "The target version differs from the expected one", "version", info.Version, "revision", info.Revision)
+		return
+	}
+
+	level.Info(logger).Log("msg", "The target version matches the expected one", "version", info.Version)
+}
+
+// Run implements Test.
+func (t *WriteReadSeriesTest) Run(ctx context.Context, now time.Time) error {
+	_, err := t.RunWithReport(ctx, now)
+	return err
+}
+
+// RunWithReport is like Run, but it also returns the report of the run. The report is populated even if the
+// run is interrupted early, and it's written to the configured report writers.
+func (t *WriteReadSeriesTest) RunWithReport(ctx context.Context, now time.Time) (RunReport, error) {
+	report := RunReport{Timestamp: now, Writes: map[string]RunReportWrites{}}
+
+	// The skipped run is not written to the report writers, because it doesn't write or query anything.
+	if !t.running.CompareAndSwap(false, true) {
+		t.metrics.skippedIterationsTotal.Inc()
+		level.Warn(t.logger).Log("msg", "Skipped the run because the previous one is still in progress")
+		report.FirstError = errRunInProgress.Error()
+		return report, errRunInProgress
+	}
+	defer t.running.Store(false)
+
+	// Snapshot the counters, in order to report the results of this run only.
+	totals := t.metrics.runTotals()
+	t.maxQueryLatency = 0
+
+	// Collect all errors on this test run.
+	errs := multierror.MultiError{}
+	t.runReport = &report
+	t.run(ctx, now, &errs)
+	t.runReport = nil
+
+	// Tracked at the end of each run, regardless of whether any sample has been written by the run. No samples are
+	// checked during the warmup.
+	verifiedMinTime := t.queryMinTime
+	if _, ok := t.warmupEnd(now); ok {
+		verifiedMinTime = time.Time{}
+	}
+	t.metrics.setVerifiedOldestSampleAge(t.metricName, now, verifiedMinTime)
+
+	totals = t.metrics.runTotals().sub(totals)
+	report.QueriesAttempted = int(totals.queries)
+	report.QueriesFailed = int(totals.queryFailures)
+	report.ChecksPassed = int(totals.checks - totals.checkFailures)
+	report.ChecksFailed = int(totals.checkFailures)
+	if len(errs) > 0 {
+		report.FirstError = errs[0].Error()
+	}
+
+	if t.cfg.CSVReportWriter != nil {
+		if err := t.writeCSVReport(now, totals); err != nil {
+			level.Warn(t.logger).Log("msg", "Failed to write the CSV report", "err", err)
+		}
+	}
+	if t.cfg.ReportWriter != nil {
+		if err := json.NewEncoder(t.cfg.ReportWriter).Encode(report); err != nil {
+			level.Warn(t.logger).Log("msg", "Failed to write the JSON report", "err", err)
+		}
+	}
+
+	return report, errs.Err()
+}
+
+// run runs the test at the input time, and adds all errors to errs.
+func (t *WriteReadSeriesTest) run(ctx context.Context, now time.Time, errs *multierror.MultiError) {
+	// Track the wall time between consecutive runs, in order to detect when runs are not scheduled as expected.
+	runTime := t.timeNow()
+	if !t.lastRunTime.IsZero() {
+		t.metrics.runIntervalSeconds.Set(runTime.Sub(t.lastRunTime).Seconds())
+	}
+	t.lastRunTime = runTime
+
+	t.applyNumSeriesChange(now)
+
+	if t.cfg.DryRun {
+		errs.Add(t.dryRun(now))
+		return
+	}
+
+	// Configure the rate limiter to send a sample for each series per second. At startup, this test may catch up
+	// with previous missing writes: this rate limit reduces the chances to hit the ingestion limit on Mimir side.
+	writeLimiter := rate.NewLimiter(rate.Limit(t.cfg.NumSeries), t.cfg.NumSeries)
+
+	// Write a burst of samples, if enabled. The remaining samples are written below.
+	if t.cfg.BurstIntervals > 0 {
+		errs.Add(t.runBurstConsistencyCheck(ctx, now))
+	}
+
+	// When out-of-order writes are enabled, one of the timestamps is held back and written after the following ones.
+	outOfOrderTimestamp := t.outOfOrderWriteTimestamp(now)
+	outOfOrderHeldBack := false
+
+	// Write series for each expected timestamp until now. When the write jitter is enabled, each timestamp is
+	// written only once the write offset has elapsed, but the written samples timestamps are still aligned.
+	// Multiple intervals are written at once, up to the configured max samples per write.
+	for timestamp := t.nextWriteTimestamp(now); !timestamp.Add(t.writeOffset).After(now); timestamp = t.nextWriteTimestamp(now) {
+		if timestamp.Equal(outOfOrderTimestamp) {
+			// Skip the timestamp for now. It's written once the following timestamps have been written.
+			t.lastWrittenTimestamp = timestamp
+			outOfOrderHeldBack = true
+			continue
+		}
+
+		timestamps := []time.Time{timestamp}
+		for next := timestamp.Add(t.cfg.WriteInterval); len(timestamps) < t.intervalsPerWrite && !next.Add(t.writeOffset).After(now) && !next.Equal(outOfOrderTimestamp); next = next.Add(t.cfg.WriteInterval) {
+			timestamps = append(timestamps, next)
+		}
+
+		for range timestamps {
+			if err := writeLimiter.WaitN(ctx, t.cfg.NumSeries); err != nil {
+				// Context has been canceled, so we should interrupt.
+				errs.Add(err)
+				return
+			}
+		}
+
+		if err := t.writeSamples(ctx, timestamps); err != nil {
+			errs.Add(err)
+
+			// Keep writing the next intervals if the write has been rejected, because retrying it isn't
+			// expected to succeed, unless the configured action for the rejection status code is to stop.
+			var rejectedErr writeRejectedError
+			if !errors.As(err, &rejectedErr) || t.writeErrorAction(rejectedErr.statusCode) == writeErrorActionStop {
+				break
+			}
+		}
+	}
+	if outOfOrderHeldBack {
+		errs.Add(t.writeOutOfOrderSamples(ctx, writeLimiter, outOfOrderTimestamp))
+	}
+
+	if warmupEnd, ok := t.warmupEnd(now); ok {
+		level.Info(t.logger).Log("msg", "Skipped query results checks during warmup", "query_min_time", t.queryMinTime, "warmup_end", warmupEnd)
+		return
+	}
+
+	queryRanges, queryInstants, err := t.getQueryTimeRanges(now)
+	if err != nil {
+		errs.Add(err)
+	}
+	t.runQueryResultChecks(ctx, queryRanges, queryInstants, errs)
+	if t.cfg.LeftBoundaryCheckEnabled && len(queryRanges) > 0 {
+		errs.Add(t.runLeftBoundaryCheck(ctx))
+	}
+	if t.cfg.DeepRangeCheck && len(queryRanges) > 0 {
+		errs.Add(t.runDeepRangeCheck(ctx, now))
+	}
+	if t.cfg.CoarseStepCheckFactor > 1 && len(queryRanges) > 0 {
+		errs.Add(t.runCoarseStepCheck(ctx))
+	}
+	if t.cfg.OOOWindow > 0 {
+		errs.Add(t.runOutOfOrderCheck(ctx, now))
+	}
+	if t.cfg.DuplicateSampleCheckEnabled {
+		errs.Add(t.runDuplicateSampleCheck(ctx, now))
+	}
+	if t.cfg.LabelOrderCheckEnabled {
+		errs.Add(t.runLabelOrderCheck(ctx, now))
+	}
+	if t.cfg.InvalidStepCheckEnabled {
+		errs.Add(t.runInvalidStepCheck(ctx, now))
+	}
+	if t.cfg.GapCheckEnabled {
+		errs.Add(t.runGapCheck(ctx, now))
+	}
+	if t.cfg.WithStaleness {
+		errs.Add(t.runStalenessCheck(ctx, now))
+	}
+	if t.cfg.WithNaN {
+		errs.Add(t.runNaNCheck(ctx, now))
+	}
+	if t.cfg.WithMetadata {
+		errs.Add(t.runMetadataCheck(ctx))
+	}
+	if t.cfg.HistogramIdentityCheckEnabled {
+		err := t.runHistogramIdentityCheck(ctx, now)
+		t.metrics.setLastCheckSuccess(t.histogramProbeMetricName, queryTypeInstant, err == nil)
+		errs.Add(err)
+	}
+	for _, check := range t.cfg.CustomChecks {
+		errs.Add(t.runCustomCheck(ctx, check, now))
+	}
+	if t.cfg.SumOverTimeCheckWindow > 0 && len(queryRanges) > 0 {
+		errs.Add(t.runSumOverTimeCheck(ctx))
+	}
+	if t.cfg.MinMaxOverTimeCheckWindow > 0 && len(queryRanges) > 0 {
+		errs.Add(t.runMinMaxOverTimeCheck(ctx))
+	}
+	if t.cfg.TimeModifiersCheckOffset > 0 && len(queryRanges) > 0 {
+		errs.Add(t.runTimeModifiersCheck(ctx))
+	}
+	if t.cfg.RelativeTimeCheckOffset > 0 && len(queryRanges) > 0 && !t.relativeTimeUnsupported {
+		errs.Add(t.runRelativeTimeCheck(ctx))
+	}
+	if t.cfg.QueryFrontendSplitInterval > 0 && len(queryRanges) > 0 {
+		errs.Add(t.runQuerySplitCheck(ctx))
+	}
+	if t.cfg.WaveShape == waveShapeCounter && len(queryRanges) > 0 {
+		errs.Add(t.runCounterRateCheck(ctx))
+	}
+	if t.cfg.RateAggregationCheckEnabled && len(queryRanges) > 0 {
+		errs.Add(t.runRateAggregationCheck(ctx))
+	}
+	if t.cfg.RegexMatcherCheckEnabled && len(queryRanges) > 0 {
+		errs.Add(t.runRegexMatcherCheck(ctx))
+	}
+	if t.cfg.NameMatcherCheckEnabled && len(queryRanges) > 0 {
+		errs.Add(t.runNameMatcherCheck(ctx))
+	}
+	if t.cfg.WithExemplars && len(queryRanges) > 0 {
+		errs.Add(t.runExemplarsCheck(ctx))
+	}
+	if t.cfg.AbsentDataCheckEnabled && len(queryRanges) > 0 {
+		errs.Add(t.runAbsentDataCheck(ctx, now))
+	}
+	if t.cfg.RemoteReadCheckEnabled && len(queryRanges) > 0 {
+		errs.Add(t.runRemoteReadCheck(ctx))
+	}
+	if t.cfg.EquivalentQueriesCheckEnabled && len(queryRanges) > 0 {
+		errs.Add(t.runEquivalentQueriesCheck(ctx))
+	}
+	if t.cfg.ActiveSeriesCheckEnabled && len(queryRanges) > 0 {
+		errs.Add(t.runActiveSeriesCheck(ctx, now))
+	}
+	if t.cfg.QueryLookback > 0 && len(queryRanges) > 0 {
+		errs.Add(t.runLookbackCheck(ctx, now))
+	}
+	if t.cfg.FlushCheckEnabled && len(queryRanges) > 0 {
+		errs.Add(t.runFlushCheck(ctx))
+	}
+	if t.cfg.BlockPathCheckInterval > 0 && len(queryRanges) > 0 {
+		errs.Add(t.runBlockPathCheck(ctx, now))
+	}
+}
+
+// warmupEnd returns the time the warmup ends at, and true if the warmup is still in progress at the input time.
+// The warmup starts at the oldest sample of the continuously written time range, which is recovered by Init after
+// a restart.
+func (t *WriteReadSeriesTest) warmupEnd(now time.Time) (time.Time, bool) {
+	if t.cfg.WarmupDuration <= 0 {
+		return time.Time{}, false
+	}
+
+	end := t.queryMinTime.Add(t.cfg.WarmupDuration)
+	return end, t.queryMinTime.IsZero() || now.Before(end)
+}
+
+// reportWrite tracks the input write request, sent with the input outcome, in the report of the current run.
+// A request writing multiple metrics is tracked once for each of them.
+func (t *WriteReadSeriesTest) reportWrite(series []prompb.TimeSeries, statusCode int, err error) {
+	if t.runReport == nil {
+		return
+	}
+
+	seen := map[string]struct{}{}
+	for _, s := range series {
+		name := ""
+		for _, l := range s.Labels {
+			if l.Name == model.MetricNameLabel {
+				name = l.Value
+				break
+			}
+		}
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+
+		writes := t.runReport.Writes[name]
+		writes.Attempted++
+		if err != nil || statusCode/100 != 2 {
+			writes.Failed++
+		}
+		t.runReport.Writes[name] = writes
+	}
+}
+
+// writeCSVReport appends a row with the input results of the run at the input time to the CSV report,
+// preceded by the header row if this is the first row written.
+func (t *WriteReadSeriesTest) writeCSVReport(now time.Time, totals runTotals) error {
+	w := csv.NewWriter(t.cfg.CSVReportWriter)
+
+	if !t.csvReportHeaderWritten {
+		if err := w.Write([]string{"timestamp", "writes", "failures", "queries", "checks", "check_failures", "max_query_latency_seconds"}); err != nil {
+			return err
+		}
+		t.csvReportHeaderWritten = true
+	}
+
+	if err := w.Write([]string{
+		now.Format(time.RFC3339),
+		strconv.FormatFloat(totals.writes, 'f', -1, 64),
+		strconv.FormatFloat(totals.failures, 'f', -1, 64),
+		strconv.FormatFloat(totals.queries, 'f', -1, 64),
+		strconv.FormatFloat(totals.checks, 'f', -1, 64),
+		strconv.FormatFloat(totals.checkFailures, 'f', -1, 64),
+		strconv.FormatFloat(t.maxQueryLatency.Seconds(), 'f', -1, 64),
+	}); err != nil {
+		return err
+	}
+
+	w.Flush()
+	return w.Error()
+}
+
+// writeSamples writes the samples of all series for each input timestamp, in a single write. The input
+// timestamps are expected to be consecutive intervals.
+func (t *WriteReadSeriesTest) writeSamples(ctx context.Context, timestamps []time.Time) error {
+	first, last := timestamps[0], timestamps[len(timestamps)-1]
+
+	sp, ctx := spanlogger.NewWithLogger(ctx, t.logger, "WriteReadSeriesTest.writeSamples")
+	defer sp.Finish()
+	logger := log.With(sp, "timestamp", first.String(), "num_intervals", len(timestamps), "num_series", t.cfg.NumSeries)
+
+	var series []prompb.TimeSeries
+	for _, timestamp := range timestamps {
+		intervalSeries := t.generateMetricSeries(t.metricName, timestamp, t.cfg.NumSeries)
+		if t.cfg.WithExemplars {
+			intervalSeries = appendExemplars(intervalSeries)
+		}
+		if t.cfg.SeriesChurnRate > 0 {
+			// The churned series get a new identity at each interval, while the other series keep their identity.
+			generation := timestamp.UnixNano() / t.cfg.WriteInterval.Nanoseconds()
+			intervalSeries = churnSeries(intervalSeries, churnedSeriesCount(t.cfg.NumSeries, t.cfg.SeriesChurnRate), generation)
+		}
+		series = append(series, intervalSeries...)
+	}
+
+	statusCode, err := t.writeSeriesWithRetries(ctx, logger, series)
+
+	// A write may include the samples of multiple intervals, so it's tracked once regardless of the number
+	// of intervals, while the samples are tracked separately.
+	t.metrics.writesTotal.Inc()
+	t.metrics.writeSamplesTotal.Add(float64(countSamples(series)))
+	if statusCode/100 != 2 {
+		t.metrics.writesFailedTotal.WithLabelValues(strconv.Itoa(statusCode)).Inc()
+		level.Warn(logger).Log("msg", "Failed to remote write series", "status_code", statusCode, "err", err)
+	} else {
+		level.Debug(logger).Log("msg", "Remote write series succeeded")
+	}
+
+	// If the write request failed because of a 4xx error, retrying the request isn't expected to succeed.
+	// The series may have been not written at all or partially written (eg. we hit some limit).
+	// We keep writing the next interval, but we reset the query timestamp because we can't reliably
+	// assert on query results due to possible gaps.
+	if statusCode/100 == 4 {
+		t.lastWrittenTimestamp = maxTime(t.lastWrittenTimestamp, last)
+		t.queryMinTime = time.Time{}
+		t.queryMaxTime = time.Time{}
+		t.exemplarsMinTime = time.Time{}
+		return writeRejectedError{
+			statusCode: statusCode,
+			err:        errors.Wrapf(errWriteRejected, "remote write series failed with status code %d: %v", statusCode, err),
+		}
+	}
+
+	// If the write request failed because of a network or 5xx error, we'll retry to write series
+	// in the next test run.
+	if err != nil {
+		return errors.Wrap(err, "failed to remote write series")
+	}
+	if statusCode/100 != 2 {
+		return errors.Wrapf(err, "remote write series failed with status code %d", statusCode)
+	}
+
+	// The write request succeeded. The written timestamps may precede the last written one, if written out-of-order.
+	t.lastWrittenTimestamp = maxTime(t.lastWrittenTimestamp, last)
+	t.queryMaxTime = maxTime(t.queryMaxTime, last)
+	if t.queryMinTime.IsZero() {
+		t.queryMinTime = first
+	}
+	if t.cfg.WithExemplars && t.exemplarsMinTime.IsZero() {
+		t.exemplarsMinTime = first
+	}
+
+	if t.referenceClient != nil {
+		t.writeReferenceSamples(ctx, logger, series, first, last)
+	}
+	return nil
+}
+
+// writeReferenceSamples writes the input series, written to Mimir, also to the reference backend. A failed write
+// doesn't fail the run, because the reference is only used to cross-check the query results, but the results are
+// not compared until the series are continuously written to the reference again.
+func (t *WriteReadSeriesTest) writeReferenceSamples(ctx context.Context, logger log.Logger, series []prompb.TimeSeries, first, last time.Time) {
+	statusCode, err := t.referenceClient.WriteSeries(ctx, series)
+	if err != nil || statusCode/100 != 2 {
+		level.Warn(logger).Log("msg", "Failed to remote write series to the reference backend", "status_code", statusCode, "err", err)
+		t.referenceMinTime = time.Time{}
+		t.referenceMaxTime = time.Time{}
+		return
+	}
+
+	t.referenceMaxTime = maxTime(t.referenceMaxTime, last)
+	if t.referenceMinTime.IsZero() {
+		t.referenceMinTime = first
+	}
+}
+
+// writeErrorAction returns the action configured for a write rejected with the input 4xx status code. The action
+// configured for the exact status code takes precedence over the one configured for the 4xx class. Writes rejected
+// with the 415 status code always stop the run.
+func (t *WriteReadSeriesTest) writeErrorAction(statusCode int) string {
+	// The content type of the write requests is rejected, for example because the server doesn't support the
+	// configured remote write version, so none of the next writes is expected to succeed.
+	if statusCode == http.StatusUnsupportedMediaType {
+		return writeErrorActionStop
+	}
+	if action, ok := t.writeErrorActions[strconv.Itoa(statusCode)]; ok {
+		return action
+	}
+	if action, ok := t.writeErrorActions[writeErrorClass4xx]; ok {
+		return action
+	}
+	return writeErrorActionContinue
+}
+
+// outOfOrderWriteTimestamp returns the timestamp held back and written out-of-order by the run at the input time,
+// or the zero value if out-of-order writes are disabled or the run writes less than two timestamps. The returned
+// timestamp precedes the last one written by the run by up to half of the out-of-order window.
+func (t *WriteReadSeriesTest) outOfOrderWriteTimestamp(now time.Time) time.Time {
+	if !t.cfg.WithOutOfOrder || t.lastWrittenTimestamp.IsZero() {
+		return time.Time{}
+	}
+
+	first := t.nextWriteTimestamp(now)
+	last := alignTimestampToInterval(now.Add(-t.writeOffset), t.cfg.WriteInterval)
+	if !last.After(first) {
+		return time.Time{}
+	}
+
+	lag := util_math.Max(1, int(t.cfg.OOOWindow/2/t.cfg.WriteInterval))
+	return maxTime(first, last.Add(-time.Duration(lag)*t.cfg.WriteInterval))
+}
+
+// writeOutOfOrderSamples writes the samples at the input timestamp, which has been held back while the following
+// timestamps have been written. The write is not retried by the next runs, so if it fails the query time range is
+// reset, because the written samples have a gap.
+func (t *WriteReadSeriesTest) writeOutOfOrderSamples(ctx context.Context, writeLimiter *rate.Limiter, timestamp time.Time) error {
+	if err := writeLimiter.WaitN(ctx, t.cfg.NumSeries); err != nil {
+		return err
+	}
+
+	err := t.writeSamples(ctx, []time.Time{timestamp})
+	if err != nil && !errors.Is(err, errWriteRejected) {
+		t.queryMinTime = time.Time{}
+		t.queryMaxTime = time.Time{}
+		t.exemplarsMinTime = time.Time{}
+	}
+	return err
+}
+
+// writeSeriesWithRetries writes the input series, retrying up to the configured number of times with exponential
+// backoff if the write request fails because of a network, 5xx or 429 error. Requests failed because of any other
+// 4xx error are not retried, because retrying them isn't expected to succeed. Returns the outcome of the last attempt.
+func (t *WriteReadSeriesTest) writeSeriesWithRetries(ctx context.Context, logger log.Logger, series []prompb.TimeSeries) (int, error) {
+	retries := backoff.New(ctx, backoff.Config{
+		MinBackoff: t.cfg.WriteBackoff.MinBackoff,
+		MaxBackoff: t.cfg.WriteBackoff.MaxBackoff,
+		MaxRetries: t.cfg.WriteRetries,
+	})
+
+	for {
+		writeStart := t.timeNow()
+		statusCode, err := t.writeSeries(ctx, series)
+		t.metrics.writeDurationSeconds.Observe(t.timeNow().Sub(writeStart).Seconds())
+		if statusCode/100 == 2 || (statusCode/100 == 4 && statusCode != http.StatusTooManyRequests) || t.cfg.WriteRetries <= 0 || !retries.Ongoing() {
+			return statusCode, err
+		}
+
+		level.Debug(logger).Log("msg", "Failed to remote write series, retrying", "status_code", statusCode, "err", err, "retry", retries.NumRetries()+1)
+		t.metrics.writeRetriesTotal.Inc()
+		retries.Wait()
+	}
+}
+
+// runBurstConsistencyCheck writes up to the configured number of burst intervals at once, without any rate limiting,
+// and then polls a range query until all written samples are queryable or the configured deadline is reached.
+// The time it takes for the written samples to be queryable is tracked.
+func (t *WriteReadSeriesTest) runBurstConsistencyCheck(ctx context.Context, now time.Time) error {
+	const checkName = "burst_consistency"
+
+	var first, last time.Time
+	for i := 0; i < t.cfg.BurstIntervals; i++ {
+		timestamp := t.nextWriteTimestamp(now)
+		if timestamp.After(now) {
+			break
+		}
+
+		// If the write failed with a 4xx error, we can't reliably assert on the written samples. The error
+		// is reported, but the remaining samples are written below.
+		if err := t.writeSamples(ctx, []time.Time{timestamp}); err != nil {
+			return err
+		}
+
+		if first.IsZero() {
+			first = timestamp
+		}
+		last = timestamp
+	}
+
+	// Nothing has been written.
+	if first.IsZero() {
+		return nil
+	}
+
+	sp, ctx := spanlogger.NewWithLogger(ctx, t.logger, "WriteReadSeriesTest.runBurstConsistencyCheck")
+	defer sp.Finish()
+
+	logger := log.With(sp, "query", t.queryMetricSum, "start", first.UnixMilli(), "end", last.UnixMilli(), "step", t.cfg.WriteInterval)
+	level.Debug(logger).Log("msg", "Waiting until samples written in a burst are queryable")
+
+	checksTotal, checksFailedTotal := t.metrics.additionalCheckCounters(checkName)
+	checksTotal.Inc()
+
+	burstEnd := t.timeNow()
+	deadline := burstEnd.Add(t.cfg.BurstPollDeadline)
+
+	for {
+		t.metrics.queriesTotal.Inc()
+		matrix, err := t.client.QueryRange(ctx, t.queryMetricSum, first, last, t.cfg.WriteInterval, WithResultsCacheEnabled(false))
+		if err != nil {
+			t.metrics.queriesFailedTotal.WithLabelValues(classifyQueryError(err), queryErrorStatusCode(err)).Inc()
+			level.Warn(logger).Log("msg", "Failed to execute range query", "err", err)
+		} else if mismatches, err := countSamplesSumMismatches(matrix, 1, first, last, t.cfg.WriteInterval, t.generateSumValue, t.cfg.ResultCheckTolerance); err == nil && mismatches == 0 {
+			elapsed := t.timeNow().Sub(burstEnd)
+			t.metrics.burstConsistencySeconds.Observe(elapsed.Seconds())
+			level.Debug(logger).Log("msg", "Samples written in a burst are queryable", "elapsed", elapsed)
+			return nil
+		}
+
+		if !t.timeNow().Before(deadline) {
+			checksFailedTotal.Inc()
+			level.Warn(logger).Log("msg", "Samples written in a burst are not queryable within the deadline", "deadline", t.cfg.BurstPollDeadline)
+			return fmt.Errorf("burst consistency check failed: samples written between %d and %d are not queryable within %s", first.UnixMilli(), last.UnixMilli(), t.cfg.BurstPollDeadline)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(t.burstPollInterval):
+		}
+	}
+}
+
+// runActiveSeriesCheck checks that the number of active series reported by the ingesters matches the number of
+// written series. The series no longer written are still counted as active until the idle timeout expires, so
+// right after a change of the number of series the active series are overcounted: the check is skipped until
+// the number of series hasn't changed for the whole idle timeout, and then the active series are polled until
+// their number matches, to tolerate the delay with which the ingesters purge the idle series.
+func (t *WriteReadSeriesTest) runActiveSeriesCheck(ctx context.Context, now time.Time) error {
+	const checkName = "active_series"
+
+	// The written series are not active anymore if nothing has been written within the idle timeout.
+	from := now.Add(-t.cfg.ActiveSeriesIdleTimeout)
+	if t.queryMaxTime.Before(from) {
+		return nil
+	}
+	expectedSeries, ok := t.numSeriesIn(from, now)
+	if !ok {
+		level.Debug(t.logger).Log("msg", "Skipped the active series check because the number of series has changed within the idle timeout", "idle_timeout", t.cfg.ActiveSeriesIdleTimeout)
+		return nil
+	}
+
+	matchers := make([]string, 0, len(t.metricMatchers))
+	for _, m := range t.metricMatchers {
+		matchers = append(matchers, m.String())
+	}
+
+	sp, ctx := spanlogger.NewWithLogger(ctx, t.logger, "WriteReadSeriesTest.runActiveSeriesCheck")
+	defer sp.Finish()
+
+	logger := log.With(sp, "matchers", strings.Join(matchers, ","), "expected_series", expectedSeries)
+	level.Debug(logger).Log("msg", "Waiting until the number of active series matches the number of written series")
+
+	checksTotal, checksFailedTotal := t.metrics.additionalCheckCounters(checkName)
+	checksTotal.Inc()
+
+	deadline := t.timeNow().Add(t.cfg.ActiveSeriesPollDeadline)
+	actualSeries := 0
+
+	for {
+		t.metrics.queriesTotal.Inc()
+		series, err := t.client.ActiveSeries(ctx, matchers)
+		if err != nil {
+			t.metrics.queriesFailedTotal.WithLabelValues(classifyQueryError(err), queryErrorStatusCode(err)).Inc()
+			level.Warn(logger).Log("msg", "Failed to query the active series", "err", err)
+		} else if actualSeries = len(series); actualSeries == expectedSeries {
+			return nil
+		}
+
+		if !t.timeNow().Before(deadline) {
+			checksFailedTotal.Inc()
+			level.Warn(logger).Log("msg", "The number of active series doesn't match the number of written series within the deadline", "actual_series", actualSeries, "deadline", t.cfg.ActiveSeriesPollDeadline)
+			return fmt.Errorf("active series check failed: expected %d active series but got %d within %s", expectedSeries, actualSeries, t.cfg.ActiveSeriesPollDeadline)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(t.activeSeriesPollInterval):
+		}
+	}
+}
+
+// getQueryTimeRanges returns the start/end time ranges to use to run test range queries,
+// and the timestamps to use to run test instant queries. The returned ranges and timestamps never extend past the
+// max query time, even if it is older than now, because there is no data written after it.
+func (t *WriteReadSeriesTest) getQueryTimeRanges(now time.Time) (ranges [][2]time.Time, instants []time.Time, err error) {
+	// The min and max allowed query timestamps are zero if there's no successfully written data yet.
+	if t.queryMinTime.IsZero() || t.queryMaxTime.IsZero() {
+		level.Info(t.logger).Log("msg", "Skipped queries because there's no valid time range to query")
+		return nil, nil, errors.New("no valid time range to query")
+	}
+
+	// Honor the configured max age.
+	adjustedQueryMinTime := maxTime(t.queryMinTime, now.Add(-t.cfg.MaxQueryAge))
+	if t.queryMaxTime.Before(adjustedQueryMinTime) {
+		level.Info(t.logger).Log("msg", "Skipped queries because there's no valid time range to query after honoring configured max query age", "min_valid_time", t.queryMinTime, "max_valid_time", t.queryMaxTime, "max_query_age", t.cfg.MaxQueryAge)
+		return nil, nil, errors.New("no valid time range to query after honoring configured max query age")
+	}
+
+	// Last 1h.
+	if t.queryMaxTime.After(now.Add(-1 * time.Hour)) {
+		ranges = append(ranges, [2]time.Time{
+			maxTime(adjustedQueryMinTime, now.Add(-1*time.Hour)),
+			minTime(t.queryMaxTime, now),
+		})
+		instants = append(instants, minTime(t.queryMaxTime, now))
+	}
+
+	// Last 24h (only if the actual time range is not already covered by "Last 1h").
+	dayStart := t.dayWindowStart(now)
+	if t.queryMaxTime.After(dayStart) && adjustedQueryMinTime.Before(now.Add(-1*time.Hour)) {
+		ranges = append(ranges, [2]time.Time{
+			maxTime(adjustedQueryMinTime, dayStart),
+			minTime(t.queryMaxTime, now),
+		})
+		instants = append(instants, maxTime(adjustedQueryMinTime, dayStart))
+	}
+
+	// From last 23h to last 24h.
+	if adjustedQueryMinTime.Before(dayStart.Add(time.Hour)) && t.queryMaxTime.After(dayStart.Add(time.Hour)) {
+		ranges = append(ranges, [2]time.Time{
+			maxTime(adjustedQueryMinTime, dayStart),
+			minTime(t.queryMaxTime, dayStart.Add(time.Hour)),
+		})
+	}
+
+	// A random time range.
+	randMinTime := randTime(adjustedQueryMinTime, t.queryMaxTime)
+	ranges = append(ranges, [2]time.Time{randMinTime, randTime(randMinTime, t.queryMaxTime)})
+	instants = append(instants, randMinTime)
+
+	// Additional random time ranges of random width, if enabled. The seed is derived from the time of the run,
+	// so that the ranges can be reproduced. Each range is checked independently, like the other ones.
+	if t.cfg.RandomQueryRanges > 0 {
+		rng := rand.New(rand.NewSource(now.UnixNano()))
+		for i := 0; i < t.cfg.RandomQueryRanges; i++ {
+			ranges = append(ranges, randTimeRange(rng, adjustedQueryMinTime, t.queryMaxTime, t.cfg.RandomQueryRangeMinWidth, t.cfg.RandomQueryRangeMaxWidth))
+		}
+	}
+
+	return ranges, instants, nil
+}
+
+// runQueryResultChecks runs the range queries over the input time ranges and the instant queries at the input
+// timestamps, each one both with and without results cache, and checks their results. Up to the configured query
+// concurrency queries run at once. All errors are added to errs, in the same order regardless of the concurrency.
+func (t *WriteReadSeriesTest) runQueryResultChecks(ctx context.Context, ranges [][2]time.Time, instants []time.Time, errs *multierror.MultiError) {
+	type queryJob struct {
+		queryType           string
+		start, end          time.Time
+		resultsCacheEnabled bool
+	}
+
+	var jobs []queryJob
+	if t.rangeQueriesEnabled {
+		for _, timeRange := range ranges {
+			for _, resultsCacheEnabled := range []bool{true, false} {
+				jobs = append(jobs, queryJob{queryType: queryTypeRange, start: timeRange[0], end: timeRange[1], resultsCacheEnabled: resultsCacheEnabled})
+			}
+		}
+	}
+	if t.instantQueriesEnabled {
+		for _, ts := range instants {
+			for _, resultsCacheEnabled := range []bool{true, false} {
+				jobs = append(jobs, queryJob{queryType: queryTypeInstant, start: ts, end: ts, resultsCacheEnabled: resultsCacheEnabled})
+			}
+		}
+	}
+
+	// Each job tracks its own error, so that a failed check doesn't interrupt the other ones.
+	jobErrs := make([]error, len(jobs))
+	errs.Add(concurrency.ForEachJob(ctx, len(jobs), t.cfg.QueryConcurrency, func(ctx context.Context, idx int) error {
+		job := jobs[idx]
+		if job.queryType == queryTypeRange {
+			jobErrs[idx] = t.runRangeQueryAndVerifyResult(ctx, job.start, job.end, job.resultsCacheEnabled)
+		} else {
+			jobErrs[idx] = t.runInstantQueryAndVerifyResult(ctx, job.start, job.resultsCacheEnabled)
+		}
+		return nil
+	}))
+
+	success := map[string]bool{}
+	for idx, job := range jobs {
+		if _, ok := success[job.queryType]; !ok {
+			success[job.queryType] = true
+		}
+		success[job.queryType] = success[job.queryType] && jobErrs[idx] == nil
+		errs.Add(jobErrs[idx])
+	}
+	for queryType, ok := range success {
+		t.metrics.setLastCheckSuccess(t.metricName, queryType, ok)
+	}
+}
+
+// dryRun logs the series that would be written by the run at the input time, and the range and instant queries
+// that would be run to check them, without sending any request. The series are assumed to be successfully written.
+func (t *WriteReadSeriesTest) dryRun(now time.Time) error {
+	for timestamp := t.nextWriteTimestamp(now); !timestamp.Add(t.writeOffset).After(now); timestamp = t.nextWriteTimestamp(now) {
+		series := t.generateMetricSeries(t.metricName, timestamp, t.cfg.NumSeries)
+		level.Info(t.logger).Log("msg", "Dry run: skipped writing series", "selector", t.metricSelector, "timestamp", timestamp.UnixMilli(), "num_series", len(series))
+
+		t.lastWrittenTimestamp = timestamp
+		t.queryMaxTime = timestamp
+		if t.queryMinTime.IsZero() {
+			t.queryMinTime = timestamp
+		}
+	}
+
+	queryRanges, queryInstants, err := t.getQueryTimeRanges(now)
+	if err != nil {
+		return err
+	}
+	if t.rangeQueriesEnabled {
+		for _, timeRange := range queryRanges {
+			start, end, step, ok := t.rangeQueryParams(timeRange[0], timeRange[1])
+			if ok {
+				level.Info(t.logger).Log("msg", "Dry run: skipped range query", "query", t.queryMetricSum, "start", start.UnixMilli(), "end", end.UnixMilli(), "step", step, "storage", t.queryStorage(start))
+			}
+		}
+	}
+	if t.instantQueriesEnabled {
+		for _, ts := range queryInstants {
+			ts, ok := t.instantQueryTimestamp(ts)
+			if ok {
+				level.Info(t.logger).Log("msg", "Dry run: skipped instant query", "query", t.queryMetricSum, "ts", ts.UnixMilli(), "storage", t.queryStorage(ts))
+			}
+		}
+	}
+	return nil
+}
+
+// rangeQueryParams returns the start, end and step of the range query checking the written series between the
+// input start and end, or false if there are no written series to check in the time range.
+func (t *WriteReadSeriesTest) rangeQueryParams(start, end time.Time) (time.Time, time.Time, time.Duration, bool) {
+	// We align start, end and step to write interval in order to avoid any false positives
+	// when checking results correctness. The min/max query time is always aligned.
+	start = maxTime(t.queryMinTime, alignTimestampToInterval(start, t.cfg.WriteInterval))
+	end = minTime(t.queryMaxTime, alignTimestampToInterval(end, t.cfg.WriteInterval))
+	if end.Before(start) {
+		return start, end, 0, false
+	}
+
+	return start, end, getQueryStep(start, end, t.queryStep), true
+}
+
+// instantQueryTimestamp returns the timestamp of the instant query checking the written series at the input time,
+// or false if there are no written series to check at that time.
+func (t *WriteReadSeriesTest) instantQueryTimestamp(ts time.Time) (time.Time, bool) {
+	// We align the query timestamp to write interval in order to avoid any false positives
+	// when checking results correctness. The min/max query time is always aligned.
+	ts = maxTime(t.queryMinTime, alignTimestampToInterval(ts, t.cfg.WriteInterval))
+	return ts, !t.queryMaxTime.Before(ts)
+}
+
+func (t *WriteReadSeriesTest) runRangeQueryAndVerifyResult(ctx context.Context, start, end time.Time, resultsCacheEnabled bool) error {
+	start, end, step, ok := t.rangeQueryParams(start, end)
+	if !ok {
+		return nil
+	}
+
+	sp, ctx := spanlogger.NewWithLogger(ctx, t.logger, "WriteReadSeriesTest.runRangeQueryAndVerifyResult")
+	defer sp.Finish()
+
+	storage := t.queryStorage(start)
+	logger := log.With(sp, "query", t.queryMetricSum, "start", start.UnixMilli(), "end", end.UnixMilli(), "step", step, "results_cache", strconv.FormatBool(resultsCacheEnabled), "storage", storage)
+	level.Debug(logger).Log("msg", "Running range query")
+
+	t.metrics.queriesTotal.Inc()
+	queryStart := t.timeNow()
+	matrix, err := t.client.QueryRange(ctx, t.queryMetricSum, start, end, step, WithResultsCacheEnabled(resultsCacheEnabled), WithParquetStorage(storage == storageParquet))
+	t.trackQueryLatency(logger, queryTypeRange, queryStart)
+	if err != nil {
+		t.metrics.queriesFailedTotal.WithLabelValues(classifyQueryError(err), queryErrorStatusCode(err)).Inc()
+		level.Warn(logger).Log("msg", "Failed to execute range query", "err", err)
+		return errors.Wrap(err, "failed to execute range query")
+	}
+
+	// The comparison with the reference is an additional cross-check, so its error is only returned if the
+	// query result matches the expected values.
+	referenceErr := t.compareWithReference(ctx, logger, matrix, start, end, step)
+
+	checksTotal, checksFailedTotal := t.metrics.queryResultCheckCounters(readPathQueryAPI, storage, queryTypeRange)
+	checksTotal.Inc()
+	if err := t.verifySampleTimestamps(logger, matrix, start, end, step); err != nil {
+		checksFailedTotal.Inc()
+		return errors.Wrap(err, "range query result check failed")
+	}
+	_, err = verifySamplesSum(matrix, 1, step, t.generateSumValue, t.cfg.ResultCheckTolerance)
+	if err != nil {
+		checksFailedTotal.Inc()
+		level.Warn(logger).Log("msg", "Range query result check failed", "err", err)
+		return errors.Wrap(err, "range query result check failed")
+	}
+	return referenceErr
+}
+
+func (t *WriteReadSeriesTest) runInstantQueryAndVerifyResult(ctx context.Context, ts time.Time, resultsCacheEnabled bool) error {
+	ts, ok := t.instantQueryTimestamp(ts)
+	if !ok {
+		return nil
+	}
+
+	sp, ctx := spanlogger.NewWithLogger(ctx, t.logger, "WriteReadSeriesTest.runInstantQueryAndVerifyResult")
+	defer sp.Finish()
+
+	storage := t.queryStorage(ts)
+	logger := log.With(sp, "query", t.queryMetricSum, "ts", ts.UnixMilli(), "results_cache", strconv.FormatBool(resultsCacheEnabled), "storage", storage)
+	level.Debug(logger).Log("msg", "Running instant query")
+
+	t.metrics.queriesTotal.Inc()
+	queryStart := t.timeNow()
+	vector, err := t.client.Query(ctx, t.queryMetricSum, ts, WithResultsCacheEnabled(resultsCacheEnabled), WithParquetStorage(storage == storageParquet))
+	t.trackQueryLatency(logger, queryTypeInstant, queryStart)
+	if err != nil {
+		t.metrics.queriesFailedTotal.WithLabelValues(classifyQueryError(err), queryErrorStatusCode(err)).Inc()
+		level.Warn(logger).Log("msg", "Failed to execute instant query", "err", err)
+		return errors.Wrap(err, "failed to execute instant query")
+	}
+
+	// Convert the vector to matrix to reuse the same results comparison utility.
+	matrix := make(model.Matrix, 0, len(vector))
+	for _, entry := range vector {
+		matrix = append(matrix, &model.SampleStream{
+			Metric: entry.Metric,
+			Values: []model.SamplePair{{
+				Timestamp: entry.Timestamp,
+				Value:     entry.Value,
+			}},
+		})
+	}
+
+	// The comparison with the reference is an additional cross-check, so its error is only returned if the
+	// query result matches the expected value.
+	referenceErr := t.compareWithReference(ctx, logger, matrix, ts, ts, 0)
+
+	checksTotal, checksFailedTotal := t.metrics.queryResultCheckCounters(readPathQueryAPI, storage, queryTypeInstant)
+	checksTotal.Inc()
+	if err := t.verifySampleTimestamps(logger, matrix, ts, ts, 0); err != nil {
+		checksFailedTotal.Inc()
+		return errors.Wrap(err, "instant query result check failed")
+	}
+	_, err = verifySamplesSum(matrix, 1, 0, t.generateSumValue, t.cfg.ResultCheckTolerance)
+	if err != nil {
+		checksFailedTotal.Inc()
+		level.Warn(logger).Log("msg", "Instant query result check failed", "err", err)
+		return errors.Wrap(err, "instant query result check failed")
+	}
+	return referenceErr
+}
+
+// compareWithReference runs the query checking the written series between start and end, or at start if the step
+// is 0, against the reference backend, and checks whether its result matches the input one returned by Mimir.
+// The comparison is skipped if no reference is set, or the time range hasn't been continuously written to it.
+func (t *WriteReadSeriesTest) compareWithReference(ctx context.Context, logger log.Logger, matrix model.Matrix, start, end time.Time, step time.Duration) error {
+	const checkName = "reference_compare"
+
+	if t.referenceClient == nil || t.referenceMinTime.IsZero() || start.Before(t.referenceMinTime) || end.After(t.referenceMaxTime) {
+		return nil
+	}
+
+	var referenceMatrix model.Matrix
+	var err error
+	if step == 0 {
+		var vector model.Vector
+		vector, err = t.referenceClient.Query(ctx, t.queryMetricSum, start)
+		for _, entry := range vector {
+			referenceMatrix = append(referenceMatrix, &model.SampleStream{
+				Metric: entry.Metric,
+				Values: []model.SamplePair{{Timestamp: entry.Timestamp, Value: entry.Value}},
+			})
+		}
+	} else {
+		referenceMatrix, err = t.referenceClient.QueryRange(ctx, t.queryMetricSum, start, end, step)
+	}
+	if err != nil {
+		// The reference backend failing is not a divergence of Mimir.
+		level.Warn(logger).Log("msg", "Failed to execute query against the reference backend", "err", err)
+		return nil
+	}
+
+	checksTotal, checksFailedTotal := t.metrics.additionalCheckCounters(checkName)
+	checksTotal.Inc()
+	if err := compareMatrices(matrix, referenceMatrix, t.cfg.ResultCheckTolerance); err != nil {
+		checksFailedTotal.Inc()
+		t.metrics.referenceMismatchTotal.Inc()
+		level.Warn(logger).Log("msg", "Reference compare check failed", "err", err, "result", matrix.String(), "reference_result", referenceMatrix.String())
+		return errors.Wrap(err, "reference compare check failed: the query result differs from the reference backend one")
+	}
+	return nil
+}
+
+// queryStorage returns the storage the query API is expected to read the samples at the input time from. Samples
+// older than the configured Parquet query min age are read from the long-term Parquet storage.
+func (t *WriteReadSeriesTest) queryStorage(ts time.Time) string {
+	if t.cfg.ParquetQueryMinAge > 0 && ts.Before(t.timeNow().Add(-t.cfg.ParquetQueryMinAge)) {
+		return storageParquet
+	}
+	return storageDefault
+}
+
+// runRemoteReadCheck reads the raw samples written in the last hour through the remote read API, and checks
+// whether their sum matches the expected one. The result is tracked by the query result checks metrics.
+func (t *WriteReadSeriesTest) runRemoteReadCheck(ctx context.Context) error {
+	start := maxTime(t.queryMinTime, alignTimestampToInterval(t.queryMaxTime.Add(-time.Hour), t.cfg.WriteInterval))
+	end := t.queryMaxTime
+
+	sp, ctx := spanlogger.NewWithLogger(ctx, t.logger, "WriteReadSeriesTest.runRemoteReadCheck")
+	defer sp.Finish()
+
+	logger := log.With(sp, "matchers", fmt.Sprint(t.metricMatchers), "start", start.UnixMilli(), "end", end.UnixMilli())
+	level.Debug(logger).Log("msg", "Running remote read")
+
+	t.metrics.queriesTotal.Inc()
+	matrix, err := t.client.ReadSeries(ctx, t.metricMatchers, start, end)
+	if err != nil {
+		t.metrics.queriesFailedTotal.WithLabelValues(classifyQueryError(err), queryErrorStatusCode(err)).Inc()
+		level.Warn(logger).Log("msg", "Failed to execute remote read", "err", err)
+		return errors.Wrap(err, "failed to execute remote read")
+	}
+
+	checksTotal, checksFailedTotal := t.metrics.queryResultCheckCounters(readPathRemoteRead, storageDefault, queryTypeRange)
+	checksTotal.Inc()
+	_, err = verifySamplesSum(sumSeries(matrix), 1, t.cfg.WriteInterval, t.generateSumValue, t.cfg.ResultCheckTolerance)
+	if err != nil {
+		checksFailedTotal.Inc()
+		level.Warn(logger).Log("msg", "Remote read result check failed", "err", err)
+		return errors.Wrap(err, "remote read result check failed")
+	}
+	return nil
+}
+
+// verifySampleTimestamps checks whether the timestamps of all samples in the input matrix exactly match, in
+// milliseconds, the expected query evaluation timestamps. Deviations, for example caused by rounding bugs, are tracked.
+func (t *WriteReadSeriesTest) verifySampleTimestamps(logger log.Logger, matrix model.Matrix, start, end time.Time, step time.Duration) error {
+	deviations := countSampleTimestampDeviations(matrix, start, end, step)
+	if deviations == 0 {
+		return nil
+	}
+
+	t.metrics.timestampDeviationsTotal.Add(float64(deviations))
+	level.Warn(logger).Log("msg", "Query result contains samples whose timestamp doesn't match the expected one", "deviations", deviations)
+	return fmt.Errorf("%d samples have a timestamp not matching the expected one", deviations)
+}
+
+// trackQueryLatency tracks the duration of the query of the input type started at queryStart, the max latency
+// of the current run and a latency SLO violation if the query took longer than the configured query latency SLO.
+func (t *WriteReadSeriesTest) trackQueryLatency(logger log.Logger, queryType string, queryStart time.Time) {
+	elapsed := t.timeNow().Sub(queryStart)
+	t.metrics.queryDurationSeconds.WithLabelValues(queryType).Observe(elapsed.Seconds())
+
+	t.maxQueryLatencyMx.Lock()
+	if elapsed > t.maxQueryLatency {
+		t.maxQueryLatency = elapsed
+	}
+	t.maxQueryLatencyMx.Unlock()
+
+	if t.cfg.QueryLatencySLO > 0 && elapsed > t.cfg.QueryLatencySLO {
+		t.metrics.querySLOViolationsTotal.Inc()
+		level.Warn(logger).Log("msg", "Query latency exceeded the configured SLO", "latency", elapsed, "slo", t.cfg.QueryLatencySLO)
+	}
+}
+
+// runExemplarsCheck queries the exemplars attached to the samples written recently, and checks that there's
+// exactly one exemplar for each written sample, matching the sample value.
+func (t *WriteReadSeriesTest) runExemplarsCheck(ctx context.Context) error {
+	const checkName = "exemplars"
+
+	if t.exemplarsMinTime.IsZero() {
+		return nil
+	}
+	start := maxTime(t.exemplarsMinTime, t.queryMaxTime.Add(-t.cfg.ExemplarsCheckMaxAge))
+	end := t.queryMaxTime
+
+	// The number of exemplars is checked against the number of series, so it must not change in the time range.
+	numSeries, ok := t.numSeriesIn(start, end)
+	if !ok {
+		return nil
+	}
+
+	sp, ctx := spanlogger.NewWithLogger(ctx, t.logger, "WriteReadSeriesTest.runExemplarsCheck")
+	defer sp.Finish()
+
+	logger := log.With(sp, "query", t.metricSelector, "start", start.UnixMilli(), "end", end.UnixMilli())
+	level.Debug(logger).Log("msg", "Running exemplars query")
+
+	t.metrics.queriesTotal.Inc()
+	results, err := t.client.QueryExemplars(ctx, t.metricSelector, start, end)
+	if err != nil {
+		t.metrics.queriesFailedTotal.WithLabelValues(classifyQueryError(err), queryErrorStatusCode(err)).Inc()
+		level.Warn(logger).Log("msg", "Failed to execute exemplars query", "err", err)
+		return errors.Wrap(err, "failed to execute exemplars query")
+	}
+
+	checksTotal, checksFailedTotal := t.metrics.additionalCheckCounters(checkName)
+	checksTotal.Inc()
+	drifted, err := verifyExemplars(results, numSeries, start, end, t.cfg.WriteInterval, t.generateValue, t.cfg.ResultCheckTolerance)
+	t.metrics.exemplarChecksFailedTotal.Add(float64(drifted))
+	if err != nil {
+		checksFailedTotal.Inc()
+		level.Warn(logger).Log("msg", "Exemplars check failed", "err", err)
+		return errors.Wrap(err, "exemplars check failed")
+	}
+	return nil
+}
+
+// runAbsentDataCheck checks that no samples are returned in the time range between the max query age and the
+// oldest sample written by the test, which is never queried by the other checks because no data is expected there.
+func (t *WriteReadSeriesTest) runAbsentDataCheck(ctx context.Context, now time.Time) error {
+	const checkName = "absent_data"
+
+	// The range selector is left-open, so it includes the samples up to the max query age and excludes the
+	// oldest sample written by the test.
+	ts := t.queryMinTime.Add(-time.Millisecond)
+	window := ts.Sub(now.Add(-t.cfg.MaxQueryAge))
+	if window <= 0 {
+		return nil
+	}
+
+	query := fmt.Sprintf("sum(count_over_time(%s[%s]))", t.metricSelector, model.Duration(window))
+
+	sp, ctx := spanlogger.NewWithLogger(ctx, t.logger, "WriteReadSeriesTest.runAbsentDataCheck")
+	defer sp.Finish()
+
+	results, err := t.runInstantQueries(ctx, sp, ts, query)
+	if err != nil {
+		return err
+	}
+
+	checksTotal, checksFailedTotal := t.metrics.additionalCheckCounters(checkName)
+	checksTotal.Inc()
+	if vector := results[0]; len(vector) > 0 {
+		checksFailedTotal.Inc()
+		for _, sample := range vector {
+			t.metrics.absentDataUnexpectedSamplesTotal.Add(float64(sample.Value))
+		}
+		level.Warn(sp).Log("msg", "Absent data check failed", "ts", ts.UnixMilli(), "query", query, "result", vector.String())
+		return fmt.Errorf("absent data check failed: query %s at timestamp %d returned %s while was expecting no data", query, ts.UnixMilli(), vector.String())
+	}
+	return nil
+}
+
+// runLeftBoundaryCheck runs a range query whose start timestamp falls between two written samples and checks
+// whether the first returned point has been computed, through the PromQL lookback, from the sample preceding
+// the range start.
+func (t *WriteReadSeriesTest) runLeftBoundaryCheck(ctx context.Context) error {
+	const checkName = "left_boundary"
+
+	// Pick the sample written up to 1h before the most recent one, so that the range query spans several steps.
+	lookbackTs := maxTime(t.queryMinTime, alignTimestampToInterval(t.queryMaxTime.Add(-time.Hour), t.cfg.WriteInterval))
+	start := lookbackTs.Add(t.cfg.WriteInterval / 2)
+	end := t.queryMaxTime
+	if end.Before(start) {
+		return nil
+	}
+
+	sp, ctx := spanlogger.NewWithLogger(ctx, t.logger, "WriteReadSeriesTest.runLeftBoundaryCheck")
+	defer sp.Finish()
+
+	logger := log.With(sp, "query", t.queryMetricSumWithLookback, "start", start.UnixMilli(), "end", end.UnixMilli(), "step", t.cfg.WriteInterval)
+	level.Debug(logger).Log("msg", "Running range query to check the left boundary")
+
+	t.metrics.queriesTotal.Inc()
+	matrix, err := t.client.QueryRange(ctx, t.queryMetricSumWithLookback, start, end, t.cfg.WriteInterval, WithResultsCacheEnabled(false))
+	if err != nil {
+		t.metrics.queriesFailedTotal.WithLabelValues(classifyQueryError(err), queryErrorStatusCode(err)).Inc()
+		level.Warn(logger).Log("msg", "Failed to execute range query", "err", err)
+		return errors.Wrap(err, "failed to execute range query")
+	}
+
+	checksTotal, checksFailedTotal := t.metrics.additionalCheckCounters(checkName)
+	checksTotal.Inc()
+	if err := verifyLeftBoundarySample(matrix, start, lookbackTs, 1, t.generateSumValue, t.cfg.ResultCheckTolerance); err != nil {
+		checksFailedTotal.Inc()
+		level.Warn(logger).Log("msg", "Range query left boundary check failed", "err", err)
+		return errors.Wrap(err, "range query left boundary check failed")
+	}
+	return nil
+}
+
+// runDeepRangeCheck queries the whole time range up to the configured max query age, at the write interval
+// step, and checks every single point. Since the PromQL engine limits the number of points per series, the
+// time range is split into multiple consecutive range queries if required.
+func (t *WriteReadSeriesTest) runDeepRangeCheck(ctx context.Context, now time.Time) error {
+	const checkName = "deep_range"
+
+	start := maxTime(t.queryMinTime, alignTimestampToInterval(now.Add(-t.cfg.MaxQueryAge), t.cfg.WriteInterval).Add(t.cfg.WriteInterval))
+	end := t.queryMaxTime
+	if end.Before(start) {
+		return nil
+	}
+
+	sp, ctx := spanlogger.NewWithLogger(ctx, t.logger, "WriteReadSeriesTest.runDeepRangeCheck")
+	defer sp.Finish()
+
+	checksTotal, checksFailedTotal := t.metrics.additionalCheckCounters(checkName)
+	checksTotal.Inc()
+
+	mismatches := 0
+	for partStart := start; !partStart.After(end); partStart = partStart.Add(maxRangeQueryPoints * t.cfg.WriteInterval) {
+		partEnd := minTime(end, partStart.Add((maxRangeQueryPoints-1)*t.cfg.WriteInterval))
+
+		logger := log.With(sp, "query", t.queryMetricSum, "start", partStart.UnixMilli(), "end", partEnd.UnixMilli(), "step", t.cfg.WriteInterval)
+		level.Debug(logger).Log("msg", "Running deep range query")
+
+		t.metrics.queriesTotal.Inc()
+		matrix, err := t.client.QueryRange(ctx, t.queryMetricSum, partStart, partEnd, t.cfg.WriteInterval, WithResultsCacheEnabled(false))
+		if err != nil {
+			t.metrics.queriesFailedTotal.WithLabelValues(classifyQueryError(err), queryErrorStatusCode(err)).Inc()
+			level.Warn(logger).Log("msg", "Failed to execute deep range query", "err", err)
+			return errors.Wrap(err, "failed to execute deep range query")
+		}
+
+		partMismatches, err := countSamplesSumMismatches(matrix, 1, partStart, partEnd, t.cfg.WriteInterval, t.generateSumValue, t.cfg.ResultCheckTolerance)
+		if err != nil {
+			checksFailedTotal.Inc()
+			level.Warn(logger).Log("msg", "Deep range query result check failed", "err", err)
+			return errors.Wrap(err, "deep range query result check failed")
+		}
+		mismatches += partMismatches
+	}
+
+	t.metrics.deepRangeCheckMismatchesTotal.Add(float64(mismatches))
+	if mismatches > 0 {
+		checksFailedTotal.Inc()
+		level.Warn(sp).Log("msg", "Deep range query result check failed", "start", start.UnixMilli(), "end", end.UnixMilli(), "mismatched_points", mismatches)
+		return fmt.Errorf("deep range query result check failed: %d points between %d and %d are missing or have an unexpected value", mismatches, start.UnixMilli(), end.UnixMilli())
+	}
+	return nil
+}
+
+// runCoarseStepCheck runs a range query over the samples written in the last hour, at a step coarser than the
+// write interval, and checks that each point has the value of the most recent sample written at or before it.
+// The points fall between two written samples, so that the check doesn't pass if the value is picked from the
+// next sample.
+func (t *WriteReadSeriesTest) runCoarseStepCheck(ctx context.Context) error {
+	const checkName = "coarse_step"
+
+	step := time.Duration(t.cfg.CoarseStepCheckFactor) * t.cfg.WriteInterval
+	start := maxTime(t.queryMinTime, alignTimestampToInterval(t.queryMaxTime.Add(-time.Hour), t.cfg.WriteInterval)).Add(t.cfg.WriteInterval / 2)
+	end := t.queryMaxTime
+	if end.Before(start) {
+		return nil
+	}
+
+	sp, ctx := spanlogger.NewWithLogger(ctx, t.logger, "WriteReadSeriesTest.runCoarseStepCheck")
+	defer sp.Finish()
+
+	logger := log.With(sp, "query", t.queryMetricSumWithLookback, "start", start.UnixMilli(), "end", end.UnixMilli(), "step", step)
+	level.Debug(logger).Log("msg", "Running range query to check the last sample semantics at a coarse step")
+
+	t.metrics.queriesTotal.Inc()
+	matrix, err := t.client.QueryRange(ctx, t.queryMetricSumWithLookback, start, end, step, WithResultsCacheEnabled(false))
+	if err != nil {
+		t.metrics.queriesFailedTotal.WithLabelValues(classifyQueryError(err), queryErrorStatusCode(err)).Inc()
+		level.Warn(logger).Log("msg", "Failed to execute range query", "err", err)
+		return errors.Wrap(err, "failed to execute range query")
+	}
+
+	checksTotal, checksFailedTotal := t.metrics.additionalCheckCounters(checkName)
+	checksTotal.Inc()
+
+	// Each point is expected to have the value of the most recent sample written at or before it.
+	lastSampleValue := func(ts time.Time) float64 {
+		return t.generateSumValue(alignTimestampToInterval(ts, t.cfg.WriteInterval))
+	}
+	mismatches, err := countSamplesSumMismatches(matrix, 1, start, end, step, lastSampleValue, t.cfg.ResultCheckTolerance)
+	if err != nil {
+		checksFailedTotal.Inc()
+		level.Warn(logger).Log("msg", "Coarse step query result check failed", "err", err)
+		return errors.Wrap(err, "coarse step query result check failed")
+	}
+
+	t.metrics.coarseStepCheckMismatchesTotal.Add(float64(mismatches))
+	if mismatches > 0 {
+		checksFailedTotal.Inc()
+		level.Warn(logger).Log("msg", "Coarse step query result check failed", "mismatched_points", mismatches)
+		return fmt.Errorf("coarse step query result check failed: %d points between %d and %d are missing or don't have the value of the most recent sample", mismatches, start.UnixMilli(), end.UnixMilli())
+	}
+	return nil
+}
+
+// runOutOfOrderCheck writes a sample at the current time, then a sample in the past but within the configured
+// out-of-order window, and checks whether the out-of-order sample has been ingested and is queryable.
+func (t *WriteReadSeriesTest) runOutOfOrderCheck(ctx context.Context, now time.Time) error {
+	const checkName = "out_of_order"
+
+	inOrderTs := alignTimestampToInterval(now, t.cfg.WriteInterval)
+	outOfOrderTs := alignTimestampToInterval(now.Add(-t.cfg.OOOWindow/2), t.cfg.WriteInterval)
+	query := fmt.Sprintf("max_over_time(%s[1s])", t.outOfOrderProbeSelector)
+
+	sp, ctx := spanlogger.NewWithLogger(ctx, t.logger, "WriteReadSeriesTest.runOutOfOrderCheck")
+	defer sp.Finish()
+
+	logger := log.With(sp, "in_order_timestamp", inOrderTs.UnixMilli(), "out_of_order_timestamp", outOfOrderTs.UnixMilli(), "out_of_order_window", t.cfg.OOOWindow)
+
+	checksTotal, checksFailedTotal := t.metrics.additionalCheckCounters(checkName)
+	checksTotal.Inc()
+
+	// Write the samples in reverse order, so that the second one is out-of-order.
+	for _, ts := range []time.Time{inOrderTs, outOfOrderTs} {
+		if statusCode, err := t.writeSeries(ctx, t.generateSeries(t.outOfOrderProbeMetricName, ts, 1)); err != nil || statusCode/100 != 2 {
+			checksFailedTotal.Inc()
+			level.Warn(logger).Log("msg", "Failed to write sample for the out-of-order check", "timestamp", ts.UnixMilli(), "status_code", statusCode, "err", err)
+			return fmt.Errorf("out-of-order check failed: failed to write sample at timestamp %d (status code: %d): %v", ts.UnixMilli(), statusCode, err)
+		}
+	}
+
+	t.metrics.queriesTotal.Inc()
+	vector, err := t.client.Query(ctx, query, outOfOrderTs, WithResultsCacheEnabled(false))
+	if err != nil {
+		t.metrics.queriesFailedTotal.WithLabelValues(classifyQueryError(err), queryErrorStatusCode(err)).Inc()
+		level.Warn(logger).Log("msg", "Failed to execute instant query", "query", query, "err", err)
+		return errors.Wrap(err, "failed to execute instant query")
+	}
+
+	expectedValue := t.generateValue(outOfOrderTs)
+	if len(vector) != 1 || !compareSampleValues(expectedValue, float64(vector[0].Value), t.cfg.ResultCheckTolerance) {
+		checksFailedTotal.Inc()
+		level.Warn(logger).Log("msg", "Out-of-order check failed: the out-of-order sample is not queryable or has an unexpected value", "query", query, "result", vector.String())
+		return fmt.Errorf("out-of-order check failed: the out-of-order sample at timestamp %d is not queryable or has an unexpected value (expected: %f, result: %s)", outOfOrderTs.UnixMilli(), expectedValue, vector.String())
+	}
+	return nil
+}
+
+// runDuplicateSampleCheck writes a sample at the current time, then a sample with the same timestamp but a
+// different value, and checks whether the conflicting sample has been rejected.
+func (t *WriteReadSeriesTest) runDuplicateSampleCheck(ctx context.Context, now time.Time) error {
+	const checkName = "duplicate_sample"
+
+	ts := alignTimestampToInterval(now, t.cfg.WriteInterval)
+	original := t.generateSeries(t.duplicateSampleProbeMetricName, ts, 1)
+	conflicting := t.generateSeries(t.duplicateSampleProbeMetricName, ts, 1)
+	conflicting[0].Samples[0].Value++
+
+	sp, ctx := spanlogger.NewWithLogger(ctx, t.logger, "WriteReadSeriesTest.runDuplicateSampleCheck")
+	defer sp.Finish()
+
+	logger := log.With(sp, "timestamp", ts.UnixMilli())
+
+	checksTotal, checksFailedTotal := t.metrics.additionalCheckCounters(checkName)
+	checksTotal.Inc()
+
+	if statusCode, err := t.writeSeries(ctx, original); err != nil || statusCode/100 != 2 {
+		checksFailedTotal.Inc()
+		level.Warn(logger).Log("msg", "Failed to write sample for the duplicate sample check", "status_code", statusCode, "err", err)
+		return fmt.Errorf("duplicate sample check failed: failed to write sample at timestamp %d (status code: %d): %v", ts.UnixMilli(), statusCode, err)
+	}
+
+	statusCode, err := t.writeSeries(ctx, conflicting)
+	switch {
+	case statusCode/100 == 2:
+		checksFailedTotal.Inc()
+		t.metrics.duplicateSamplesAcceptedTotal.Inc()
+		level.Warn(logger).Log("msg", "Duplicate sample check failed: the sample with the same timestamp but a different value has been accepted")
+		return fmt.Errorf("duplicate sample check failed: the sample with the same timestamp %d but a different value has been accepted", ts.UnixMilli())
+	case statusCode/100 != 4:
+		checksFailedTotal.Inc()
+		level.Warn(logger).Log("msg", "Failed to write the conflicting sample for the duplicate sample check", "status_code", statusCode, "err", err)
+		return fmt.Errorf("duplicate sample check failed: failed to write the conflicting sample at timestamp %d (status code: %d): %v", ts.UnixMilli(), statusCode, err)
+	}
+
+	level.Debug(logger).Log("msg", "The sample with the same timestamp but a different value has been rejected", "status_code", statusCode)
+	return nil
+}
+
+// runInvalidStepCheck runs range queries with a zero and a negative step, and checks whether they're rejected
+// with a 4xx error.
+func (t *WriteReadSeriesTest) runInvalidStepCheck(ctx context.Context, now time.Time) error {
+	const checkName = "invalid_step"
+
+	end := alignTimestampToInterval(now, t.cfg.WriteInterval)
+	start := end.Add(-time.Hour)
+
+	sp, ctx := spanlogger.NewWithLogger(ctx, t.logger, "WriteReadSeriesTest.runInvalidStepCheck")
+	defer sp.Finish()
+
+	checksTotal, checksFailedTotal := t.metrics.additionalCheckCounters(checkName)
+	checksTotal.Inc()
+
+	errs := new(multierror.MultiError)
+	for _, step := range []time.Duration{0, -t.cfg.WriteInterval} {
+		logger := log.With(sp, "query", t.queryMetricSum, "start", start.UnixMilli(), "end", end.UnixMilli(), "step", step)
+		level.Debug(logger).Log("msg", "Running range query with an invalid step")
+
+		t.metrics.queriesTotal.Inc()
+		_, err := t.client.QueryRange(ctx, t.queryMetricSum, start, end, step, WithResultsCacheEnabled(false))
+		switch {
+		case err == nil:
+			t.metrics.invalidStepQueriesAcceptedTotal.Inc()
+			level.Warn(logger).Log("msg", "Invalid step check failed: the range query with an invalid step has been accepted")
+			errs.Add(fmt.Errorf("invalid step check failed: the range query with step %s has been accepted", step))
+		case !strings.HasPrefix(queryErrorStatusCode(err), "4"):
+			t.metrics.queriesFailedTotal.WithLabelValues(classifyQueryError(err), queryErrorStatusCode(err)).Inc()
+			level.Warn(logger).Log("msg", "Failed to execute range query with an invalid step", "err", err)
+			errs.Add(errors.Wrapf(err, "invalid step check failed: failed to execute range query with step %s", step))
+		default:
+			level.Debug(logger).Log("msg", "The range query with an invalid step has been rejected", "err", err)
+		}
+	}
+
+	if err := errs.Err(); err != nil {
+		checksFailedTotal.Inc()
+		return err
+	}
+	return nil
+}
+
+// runLabelOrderCheck writes the same series twice in the same request, once with its labels sorted and once with
+// its labels in reverse order, and checks whether a single series has been stored.
+func (t *WriteReadSeriesTest) runLabelOrderCheck(ctx context.Context, now time.Time) error {
+	const checkName = "label_order"
+
+	ts := alignTimestampToInterval(now, t.cfg.WriteInterval)
+	query := fmt.Sprintf("count(max_over_time(%s[1s]))", t.labelOrderProbeSelector)
+
+	sorted := t.generateSeries(t.labelOrderProbeMetricName, ts, 1)[0]
+	sort.Slice(sorted.Labels, func(i, j int) bool { return sorted.Labels[i].Name < sorted.Labels[j].Name })
+
+	reversed := prompb.TimeSeries{Samples: sorted.Samples}
+	for i := len(sorted.Labels) - 1; i >= 0; i-- {
+		reversed.Labels = append(reversed.Labels, sorted.Labels[i])
+	}
+
+	sp, ctx := spanlogger.NewWithLogger(ctx, t.logger, "WriteReadSeriesTest.runLabelOrderCheck")
+	defer sp.Finish()
+
+	logger := log.With(sp, "timestamp", ts.UnixMilli())
+
+	checksTotal, checksFailedTotal := t.metrics.additionalCheckCounters(checkName)
+	checksTotal.Inc()
+
+	if statusCode, err := t.writeSeries(ctx, []prompb.TimeSeries{sorted, reversed}); err != nil || statusCode/100 != 2 {
+		checksFailedTotal.Inc()
+		level.Warn(logger).Log("msg", "Failed to write series for the label order check", "status_code", statusCode, "err", err)
+		return fmt.Errorf("label order check failed: failed to write series at timestamp %d (status code: %d): %v", ts.UnixMilli(), statusCode, err)
+	}
+
+	t.metrics.queriesTotal.Inc()
+	vector, err := t.client.Query(ctx, query, ts, WithResultsCacheEnabled(false))
+	if err != nil {
+		t.metrics.queriesFailedTotal.WithLabelValues(classifyQueryError(err), queryErrorStatusCode(err)).Inc()
+		level.Warn(logger).Log("msg", "Failed to execute instant query", "query", query, "err", err)
+		return errors.Wrap(err, "failed to execute instant query")
+	}
+
+	if len(vector) != 1 || vector[0].Value != 1 {
+		checksFailedTotal.Inc()
+		if len(vector) == 1 && vector[0].Value > 1 {
+			t.metrics.labelOrderDuplicateSeriesTotal.Add(float64(vector[0].Value) - 1)
+		}
+		level.Warn(logger).Log("msg", "Label order check failed: the series written with its labels in different orders is not stored as a single series", "query", query, "result", vector.String())
+		return fmt.Errorf("label order check failed: query %s at timestamp %d returned %s while was expecting a single series", query, ts.UnixMilli(), vector.String())
+	}
+	return nil
+}
+
+// runGapCheck writes the samples of a probe series at three consecutive intervals, skipping the middle one, and
+// checks that range queries over them follow the PromQL semantics: the gap is filled by the PromQL lookback when
+// selecting the series, while it's omitted when selecting a range shorter than the write interval.
+func (t *WriteReadSeriesTest) runGapCheck(ctx context.Context, now time.Time) error {
+	const checkName = "gap"
+
+	end := alignTimestampToInterval(now, t.cfg.WriteInterval)
+	gap := end.Add(-t.cfg.WriteInterval)
+	start := gap.Add(-t.cfg.WriteInterval)
+
+	sp, ctx := spanlogger.NewWithLogger(ctx, t.logger, "WriteReadSeriesTest.runGapCheck")
+	defer sp.Finish()
+
+	logger := log.With(sp, "start", start.UnixMilli(), "gap", gap.UnixMilli(), "end", end.UnixMilli())
+
+	// The gap must not overlap with the samples written by the previous check.
+	if !start.After(t.gapProbeLastTimestamp) {
+		level.Debug(logger).Log("msg", "Skipped gap check because the time range overlaps with the previous check", "last_timestamp", t.gapProbeLastTimestamp.UnixMilli())
+		return nil
+	}
+
+	checksTotal, checksFailedTotal := t.metrics.additionalCheckCounters(checkName)
+	checksTotal.Inc()
+
+	t.gapProbeLastTimestamp = end
+	for _, ts := range []time.Time{start, end} {
+		if statusCode, err := t.writeSeries(ctx, t.generateSeries(t.gapProbeMetricName, ts, 1)); err != nil || statusCode/100 != 2 {
+			checksFailedTotal.Inc()
+			level.Warn(logger).Log("msg", "Failed to write sample for the gap check", "timestamp", ts.UnixMilli(), "status_code", statusCode, "err", err)
+			return fmt.Errorf("gap check failed: failed to write sample at timestamp %d (status code: %d): %v", ts.UnixMilli(), statusCode, err)
+		}
+	}
+
+	startTs, gapTs, endTs := model.Time(start.UnixMilli()), model.Time(gap.UnixMilli()), model.Time(end.UnixMilli())
+	startValue, endValue := model.SampleValue(t.generateValue(start)), model.SampleValue(t.generateValue(end))
+	checks := []struct {
+		query    string
+		expected []model.SamplePair
+	}{{
+		// The gap is filled by the PromQL lookback, with the value of the sample preceding it.
+		query:    t.gapProbeSelector,
+		expected: []model.SamplePair{{Timestamp: startTs, Value: startValue}, {Timestamp: gapTs, Value: startValue}, {Timestamp: endTs, Value: endValue}},
+	}, {
+		// The range selector is shorter than the write interval, so no sample is selected at the gap.
+		query:    fmt.Sprintf("count_over_time(%s[%dms])", t.gapProbeSelector, (t.cfg.WriteInterval / 2).Milliseconds()),
+		expected: []model.SamplePair{{Timestamp: startTs, Value: 1}, {Timestamp: endTs, Value: 1}},
+	}}
+
+	errs := new(multierror.MultiError)
+	for _, check := range checks {
+		t.metrics.queriesTotal.Inc()
+		matrix, err := t.client.QueryRange(ctx, check.query, start, end, t.cfg.WriteInterval, WithResultsCacheEnabled(false))
+		if err != nil {
+			t.metrics.queriesFailedTotal.WithLabelValues(classifyQueryError(err), queryErrorStatusCode(err)).Inc()
+			level.Warn(logger).Log("msg", "Failed to execute range query", "query", check.query, "err", err)
+			return errors.Wrap(err, "failed to execute range query")
+		}
+
+		if mismatches := countPointMismatches(matrix, check.expected, t.cfg.ResultCheckTolerance); mismatches > 0 {
+			t.metrics.gapCheckAnomaliesTotal.Add(float64(mismatches))
+			level.Warn(logger).Log("msg", "Gap check failed: the range query returned unexpected points", "query", check.query, "result", matrix.String(), "mismatches", mismatches)
+			errs.Add(fmt.Errorf("gap check failed: query %s returned %d points missing, unexpected or with an unexpected value (result: %s)", check.query, mismatches, matrix.String()))
+		}
+	}
+
+	if err := errs.Err(); err != nil {
+		checksFailedTotal.Inc()
+		return err
+	}
+	return nil
+}
+
+// runStalenessCheck writes two probe series at the interval preceding the current one, and then a sample for the
+// first series and a staleness marker for the second one at the current interval. It checks that the sum of both
+// series is returned before the staleness marker, and that the stale series stops being returned at the staleness
+// marker timestamp, even if its previous sample is within the PromQL lookback period.
+func (t *WriteReadSeriesTest) runStalenessCheck(ctx context.Context, now time.Time) error {
+	const checkName = "staleness"
+
+	end := alignTimestampToInterval(now, t.cfg.WriteInterval)
+	start := end.Add(-t.cfg.WriteInterval)
+
+	sp, ctx := spanlogger.NewWithLogger(ctx, t.logger, "WriteReadSeriesTest.runStalenessCheck")
+	defer sp.Finish()
+
+	logger := log.With(sp, "start", start.UnixMilli(), "end", end.UnixMilli())
+
+	// The samples must not overlap with the ones written by the previous check, otherwise the staleness marker
+	// would conflict with the sample previously written at the same timestamp.
+	if !start.After(t.stalenessProbeLastTimestamp) {
+		level.Debug(logger).Log("msg", "Skipped staleness check because the time range overlaps with the previous check", "last_timestamp", t.stalenessProbeLastTimestamp.UnixMilli())
+		return nil
+	}
+
+	checksTotal, checksFailedTotal := t.metrics.additionalCheckCounters(checkName)
+	checksTotal.Inc()
+
+	stale := t.generateSeries(t.stalenessProbeMetricName, end, 2)
+	stale[1].Samples[0].Value = math.Float64frombits(value.StaleNaN)
+
+	// Staleness markers may not survive the conversion to OTLP, so the probe series are always written through
+	// the remote write API.
+	t.stalenessProbeLastTimestamp = end
+	for _, series := range [][]prompb.TimeSeries{t.generateSeries(t.stalenessProbeMetricName, start, 2), stale} {
+		statusCode, err := t.client.WriteSeries(ctx, series)
+		t.reportWrite(series, statusCode, err)
+		if err != nil || statusCode/100 != 2 {
+			checksFailedTotal.Inc()
+			level.Warn(logger).Log("msg", "Failed to write samples for the staleness check", "timestamp", series[0].Samples[0].Timestamp, "status_code", statusCode, "err", err)
+			return fmt.Errorf("staleness check failed: failed to write samples at timestamp %d (status code: %d): %v", series[0].Samples[0].Timestamp, statusCode, err)
+		}
+	}
+
+	sumQuery := fmt.Sprintf("sum(%s)", t.stalenessProbeSelector)
+	before, err := t.runInstantQueries(ctx, logger, start, sumQuery)
+	if err != nil {
+		return err
+	}
+	after, err := t.runInstantQueries(ctx, logger, end, t.stalenessProbeSelector)
+	if err != nil {
+		return err
+	}
+
+	expectedSum := 2 * t.generateValue(start)
+	if len(before[0]) != 1 || !compareSampleValues(expectedSum, float64(before[0][0].Value), t.cfg.ResultCheckTolerance) {
+		checksFailedTotal.Inc()
+		level.Warn(logger).Log("msg", "Staleness check failed: the sum before the staleness marker doesn't match the expected one", "query", sumQuery, "result", before[0].String(), "expected", expectedSum)
+		return fmt.Errorf("staleness check failed: query %s at timestamp %d returned %s while was expecting %v", sumQuery, start.UnixMilli(), before[0].String(), expectedSum)
+	}
+	if len(after[0]) != 1 || after[0][0].Metric["series_id"] != "0" {
+		checksFailedTotal.Inc()
+		level.Warn(logger).Log("msg", "Staleness check failed: the stale series is still returned at the staleness marker timestamp", "query", t.stalenessProbeSelector, "result", after[0].String())
+		return fmt.Errorf("staleness check failed: query %s at timestamp %d returned %s while was expecting only the series with series_id=\"0\"", t.stalenessProbeSelector, end.UnixMilli(), after[0].String())
+	}
+	return nil
+}
+
+// runNaNCheck writes two probe series at the current interval, one of them with a NaN sample, and checks that
+// their sum is NaN, rather than the NaN sample being dropped.
+func (t *WriteReadSeriesTest) runNaNCheck(ctx context.Context, now time.Time) error {
+	const checkName = "nan"
+
+	ts := alignTimestampToInterval(now, t.cfg.WriteInterval)
+	sumQuery := fmt.Sprintf("sum(max_over_time(%s[1s]))", t.nanProbeSelector)
+
+	sp, ctx := spanlogger.NewWithLogger(ctx, t.logger, "WriteReadSeriesTest.runNaNCheck")
+	defer sp.Finish()
+
+	logger := log.With(sp, "timestamp", ts.UnixMilli())
+
+	if !ts.After(t.nanProbeLastTimestamp) {
+		level.Debug(logger).Log("msg", "Skipped NaN check because the samples have already been written by the previous check", "last_timestamp", t.nanProbeLastTimestamp.UnixMilli())
+		return nil
+	}
+
+	checksTotal, checksFailedTotal := t.metrics.additionalCheckCounters(checkName)
+	checksTotal.Inc()
+
+	series := t.generateSeries(t.nanProbeMetricName, ts, 2)
+	series[0].Samples[0].Value = math.NaN()
+
+	t.nanProbeLastTimestamp = ts
+	statusCode, err := t.client.WriteSeries(ctx, series)
+	t.reportWrite(series, statusCode, err)
+	if err != nil || statusCode/100 != 2 {
+		checksFailedTotal.Inc()
+		level.Warn(logger).Log("msg", "Failed to write samples for the NaN check", "status_code", statusCode, "err", err)
+		return fmt.Errorf("NaN check failed: failed to write samples at timestamp %d (status code: %d): %v", ts.UnixMilli(), statusCode, err)
+	}
+
+	results, err := t.runInstantQueries(ctx, logger, ts, sumQuery)
+	if err != nil {
+		return err
+	}
+
+	if len(results[0]) != 1 || !math.IsNaN(float64(results[0][0].Value)) {
+		checksFailedTotal.Inc()
+		level.Warn(logger).Log("msg", "NaN check failed: the sum including a NaN sample is not NaN", "query", sumQuery, "result", results[0].String())
+		return fmt.Errorf("NaN check failed: query %s at timestamp %d returned %s while was expecting NaN", sumQuery, ts.UnixMilli(), results[0].String())
+	}
+	return nil
+}
+
+// runMetadataCheck writes the metadata of the written metric, and checks that the metadata API returns it.
+// The metadata is queried right after the write, because it may be only kept for a limited time.
+func (t *WriteReadSeriesTest) runMetadataCheck(ctx context.Context) error {
+	const checkName = "metadata"
+
+	sp, ctx := spanlogger.NewWithLogger(ctx, t.logger, "WriteReadSeriesTest.runMetadataCheck")
+	defer sp.Finish()
+
+	logger := log.With(sp, "metric", t.metricName)
+
+	metadata := prompb.MetricMetadata{
+		Type:             prompb.MetricMetadata_GAUGE,
+		MetricFamilyName: t.metricName,
+		Help:             "Series written by mimir-continuous-test to check the query results.",
+	}
+	if t.cfg.WaveShape == waveShapeCounter {
+		metadata.Type = prompb.MetricMetadata_COUNTER
+	}
+	expected := v1.Metadata{
+		Type: v1.MetricType(strings.ToLower(metadata.Type.String())),
+		Help: metadata.Help,
+		Unit: metadata.Unit,
+	}
+
+	checksTotal, checksFailedTotal := t.metrics.additionalCheckCounters(checkName)
+	checksTotal.Inc()
+
+	statusCode, err := t.client.WriteMetadata(ctx, []prompb.MetricMetadata{metadata})
+	if err != nil || statusCode/100 != 2 {
+		checksFailedTotal.Inc()
+		level.Warn(logger).Log("msg", "Failed to write metadata for the metadata check", "status_code", statusCode, "err", err)
+		return fmt.Errorf("metadata check failed: failed to write the metadata of %s (status code: %d): %v", t.metricName, statusCode, err)
+	}
+
+	t.metrics.queriesTotal.Inc()
+	result, err := t.client.Metadata(ctx, t.metricName)
+	if err != nil {
+		t.metrics.queriesFailedTotal.WithLabelValues(classifyQueryError(err), queryErrorStatusCode(err)).Inc()
+		level.Warn(logger).Log("msg", "Failed to query metadata", "err", err)
+		return errors.Wrap(err, "failed to query metadata")
+	}
+
+	for _, m := range result {
+		if m == expected {
+			return nil
+		}
+	}
+
+	checksFailedTotal.Inc()
+	level.Warn(logger).Log("msg", "Metadata check failed: the written metadata is not returned by the metadata API", "expected", fmt.Sprintf("%+v", expected), "result", fmt.Sprintf("%+v", result))
+	return fmt.Errorf("metadata check failed: the metadata API returned %+v for %s while was expecting %+v", result, t.metricName, expected)
+}
+
+// runHistogramIdentityCheck writes a native histogram probe sample, and checks that adding to it the same histogram
+// multiplied by 0 returns the original histogram.
+func (t *WriteReadSeriesTest) runHistogramIdentityCheck(ctx context.Context, now time.Time) error {
+	const checkName = "histogram_identity"
+
+	ts := alignTimestampToInterval(now, t.cfg.WriteInterval)
+	originalQuery := fmt.Sprintf("last_over_time(%s[1s])", t.histogramProbeSelector)
+	identityQuery := fmt.Sprintf("%s + 0 * %s", originalQuery, originalQuery)
+
+	sp, ctx := spanlogger.NewWithLogger(ctx, t.logger, "WriteReadSeriesTest.runHistogramIdentityCheck")
+	defer sp.Finish()
+
+	logger := log.With(sp, "timestamp", ts.UnixMilli())
+
+	checksTotal, checksFailedTotal := t.metrics.additionalCheckCounters(checkName)
+	checksTotal.Inc()
+
+	// Native histograms are not converted to OTLP, so the probe sample is always written through the remote write API.
+	series := appendLabels([]prompb.TimeSeries{generateHistogramSeries(t.histogramProbeMetricName, ts, t.histogramLayout)}, t.extraLabels)
+	statusCode, err := t.client.WriteSeries(ctx, series)
+	t.reportWrite(series, statusCode, err)
+	if err != nil || statusCode/100 != 2 {
+		checksFailedTotal.Inc()
+		level.Warn(logger).Log("msg", "Failed to write sample for the histogram identity check", "status_code", statusCode, "err", err)
+		return fmt.Errorf("histogram identity check failed: failed to write sample at timestamp %d (status code: %d): %v", ts.UnixMilli(), statusCode, err)
+	}
+
+	results, err := t.runInstantQueries(ctx, logger, ts, originalQuery, identityQuery)
+	if err != nil {
+		return err
+	}
+	original, identity := results[0], results[1]
+
+	if len(original) != 1 || original[0].Histogram == nil {
+		checksFailedTotal.Inc()
+		level.Warn(logger).Log("msg", "Histogram identity check failed: the native histogram probe sample is not queryable", "query", originalQuery, "result", original.String())
+		return fmt.Errorf("histogram identity check failed: query %s at timestamp %d returned %s while was expecting a single native histogram", originalQuery, ts.UnixMilli(), original.String())
+	}
+	if len(identity) != 1 || !original[0].Histogram.Equal(identity[0].Histogram) {
+		checksFailedTotal.Inc()
+		level.Warn(logger).Log("msg", "Histogram identity check failed: adding the histogram multiplied by 0 changed the histogram", "query", identityQuery, "original", original.String(), "result", identity.String())
+		return fmt.Errorf("histogram identity check failed: query %s at timestamp %d returned %s while was expecting %s", identityQuery, ts.UnixMilli(), identity.String(), original.String())
+	}
+	return nil
+}
+
+// runCustomCheck runs the input custom check as an instant query at the input time, and checks whether the
+// result matches the expected value computed by the check.
+func (t *WriteReadSeriesTest) runCustomCheck(ctx context.Context, check CustomCheck, now time.Time) error {
+	ts := time.UnixMilli(now.UnixMilli())
+	expectedValue := check.ExpectedValue(ts)
+
+	sp, ctx := spanlogger.NewWithLogger(ctx, t.logger, "WriteReadSeriesTest.runCustomCheck")
+	defer sp.Finish()
+
+	logger := log.With(sp, "check", check.Name, "query", check.Query, "ts", ts.UnixMilli())
+	level.Debug(logger).Log("msg", "Running custom check instant query")
+
+	t.metrics.queriesTotal.Inc()
+	vector, err := t.client.Query(ctx, check.Query, ts, WithResultsCacheEnabled(false))
+	if err != nil {
+		t.metrics.queriesFailedTotal.WithLabelValues(classifyQueryError(err), queryErrorStatusCode(err)).Inc()
+		level.Warn(logger).Log("msg", "Failed to execute instant query", "err", err)
+		return errors.Wrapf(err, "failed to execute instant query for custom check %s", check.Name)
+	}
+
+	checksTotal, checksFailedTotal := t.metrics.additionalCheckCounters(check.Name)
+	checksTotal.Inc()
+	if len(vector) != 1 || !compareSampleValues(expectedValue, float64(vector[0].Value), t.cfg.ResultCheckTolerance) {
+		checksFailedTotal.Inc()
+		level.Warn(logger).Log("msg", "Custom check failed", "expected", expectedValue, "result", vector.String())
+		return fmt.Errorf("custom check %s failed: query %s at timestamp %d returned %s while was expecting %f", check.Name, check.Query, ts.UnixMilli(), vector.String(), expectedValue)
+	}
+	return nil
+}
+
+// runLookbackCheck runs an instant query at the input time, without a range selector and with the configured
+// lookback delta, and checks that it returns the sum of the most recently written samples if they're within the
+// lookback delta of the query time, and an empty result otherwise, for example if the query time falls in a gap
+// of the writes longer than the lookback delta. The check is skipped if the number of series has changed within
+// the lookback delta, because the series no longer written are still selected until it expires.
+func (t *WriteReadSeriesTest) runLookbackCheck(ctx context.Context, now time.Time) error {
+	const checkName = "lookback"
+
+	ts := now
+	if _, ok := t.numSeriesIn(ts.Add(-t.cfg.QueryLookback), ts); !ok {
+		level.Debug(t.logger).Log("msg", "Skipped the lookback check because the number of series has changed within the lookback delta", "lookback_delta", t.cfg.QueryLookback)
+		return nil
+	}
+
+	// Samples are selected if they're within the lookback delta of the query time, excluding its left boundary.
+	query := fmt.Sprintf("sum(%s)", t.metricSelector)
+	expectSample := ts.Sub(t.queryMaxTime) < t.cfg.QueryLookback
+	expectedValue := t.generateSumValue(t.queryMaxTime)
+
+	sp, ctx := spanlogger.NewWithLogger(ctx, t.logger, "WriteReadSeriesTest.runLookbackCheck")
+	defer sp.Finish()
+
+	logger := log.With(sp, "query", query, "ts", ts.UnixMilli(), "lookback_delta", t.cfg.QueryLookback, "last_sample_ts", t.queryMaxTime.UnixMilli())
+	level.Debug(logger).Log("msg", "Running instant query with the lookback delta")
+
+	t.metrics.queriesTotal.Inc()
+	vector, err := t.client.Query(ctx, query, ts, WithResultsCacheEnabled(false), WithLookbackDelta(t.cfg.QueryLookback))
+	if err != nil {
+		t.metrics.queriesFailedTotal.WithLabelValues(classifyQueryError(err), queryErrorStatusCode(err)).Inc()
+		level.Warn(logger).Log("msg", "Failed to execute instant query", "err", err)
+		return errors.Wrap(err, "failed to execute instant query")
+	}
+
+	checksTotal, checksFailedTotal := t.metrics.additionalCheckCounters(checkName)
+	checksTotal.Inc()
+	if !expectSample {
+		if len(vector) != 0 {
+			checksFailedTotal.Inc()
+			level.Warn(logger).Log("msg", "Lookback check failed: the query returned samples older than the lookback delta", "result", vector.String())
+			return fmt.Errorf("lookback check failed: query %s at timestamp %d returned %s while was expecting an empty result, because the last sample at timestamp %d is older than the lookback delta %s", query, ts.UnixMilli(), vector.String(), t.queryMaxTime.UnixMilli(), t.cfg.QueryLookback)
+		}
+		return nil
+	}
+	if len(vector) != 1 || !compareSampleValues(expectedValue, float64(vector[0].Value), t.cfg.ResultCheckTolerance) {
+		checksFailedTotal.Inc()
+		level.Warn(logger).Log("msg", "Lookback check failed", "expected", expectedValue, "result", vector.String())
+		return fmt.Errorf("lookback check failed: query %s at timestamp %d returned %s while was expecting %f", query, ts.UnixMilli(), vector.String(), expectedValue)
+	}
+	return nil
+}
+
+// runSumOverTimeCheck runs a sum_over_time() instant query over the configured window, ending at the most
+// recently written sample, and checks whether the result matches the sum of the values written in the window.
+// The window may be partially covered by written samples (eg. if the tool started writing recently), in which
+// case only the written samples are expected to be summed.
+func (t *WriteReadSeriesTest) runSumOverTimeCheck(ctx context.Context) error {
+	const checkName = "sum_over_time"
+
+	ts := t.queryMaxTime
+	query := fmt.Sprintf("sum(sum_over_time(%s[%s]))", t.metricSelector, model.Duration(t.cfg.SumOverTimeCheckWindow))
+	expectedValue := generateValuesSum(maxTime(t.queryMinTime, ts.Add(-t.cfg.SumOverTimeCheckWindow)), ts, t.cfg.WriteInterval, 1, t.generateSumValue)
+
+	sp, ctx := spanlogger.NewWithLogger(ctx, t.logger, "WriteReadSeriesTest.runSumOverTimeCheck")
+	defer sp.Finish()
+
+	logger := log.With(sp, "query", query, "ts", ts.UnixMilli())
+	level.Debug(logger).Log("msg", "Running sum_over_time() instant query")
+
+	t.metrics.queriesTotal.Inc()
+	vector, err := t.client.Query(ctx, query, ts, WithResultsCacheEnabled(false))
+	if err != nil {
+		t.metrics.queriesFailedTotal.WithLabelValues(classifyQueryError(err), queryErrorStatusCode(err)).Inc()
+		level.Warn(logger).Log("msg", "Failed to execute instant query", "err", err)
+		return errors.Wrap(err, "failed to execute instant query")
+	}
+
+	checksTotal, checksFailedTotal := t.metrics.additionalCheckCounters(checkName)
+	checksTotal.Inc()
+	if len(vector) != 1 || !compareSampleValues(expectedValue, float64(vector[0].Value), t.cfg.ResultCheckTolerance) {
+		checksFailedTotal.Inc()
+		level.Warn(logger).Log("msg", "sum_over_time() check failed", "expected", expectedValue, "result", vector.String())
+		return fmt.Errorf("sum_over_time() check failed: query %s at timestamp %d returned %s while was expecting %f", query, ts.UnixMilli(), vector.String(), expectedValue)
+	}
+	return nil
+}
+
+// runMinMaxOverTimeCheck runs min_over_time() and max_over_time() instant queries over the configured window,
+// ending at the most recently written sample, and checks whether the results match the min and max of the
+// values written in the window.
+func (t *WriteReadSeriesTest) runMinMaxOverTimeCheck(ctx context.Context) error {
+	const checkName = "min_max_over_time"
+
+	ts := t.queryMaxTime
+	expectedMin, expectedMax, ok := generateValuesMinMax(maxTime(t.queryMinTime, ts.Add(-t.cfg.MinMaxOverTimeCheckWindow)), ts, t.cfg.WriteInterval, t.generateValue)
+	if !ok {
+		return nil
+	}
+
+	sp, ctx := spanlogger.NewWithLogger(ctx, t.logger, "WriteReadSeriesTest.runMinMaxOverTimeCheck")
+	defer sp.Finish()
+
+	checksTotal, checksFailedTotal := t.metrics.additionalCheckCounters(checkName)
+
+	window := model.Duration(t.cfg.MinMaxOverTimeCheckWindow)
+	for _, check := range []struct {
+		query         string
+		expectedValue float64
+	}{
+		{query: fmt.Sprintf("min(min_over_time(%s[%s]))", t.metricSelector, window), expectedValue: expectedMin},
+		{query: fmt.Sprintf("max(max_over_time(%s[%s]))", t.metricSelector, window), expectedValue: expectedMax},
+	} {
+		logger := log.With(sp, "query", check.query, "ts", ts.UnixMilli())
+		level.Debug(logger).Log("msg", "Running instant query")
+
+		t.metrics.queriesTotal.Inc()
+		vector, err := t.client.Query(ctx, check.query, ts, WithResultsCacheEnabled(false))
+		if err != nil {
+			t.metrics.queriesFailedTotal.WithLabelValues(classifyQueryError(err), queryErrorStatusCode(err)).Inc()
+			level.Warn(logger).Log("msg", "Failed to execute instant query", "err", err)
+			return errors.Wrap(err, "failed to execute instant query")
+		}
+
+		checksTotal.Inc()
+		if len(vector) != 1 || !compareSampleValues(check.expectedValue, float64(vector[0].Value), t.cfg.ResultCheckTolerance) {
+			checksFailedTotal.Inc()
+			level.Warn(logger).Log("msg", "min_over_time() / max_over_time() check failed", "expected", check.expectedValue, "result", vector.String())
+			return fmt.Errorf("min_over_time() / max_over_time() check failed: query %s at timestamp %d returned %s while was expecting %f", check.query, ts.UnixMilli(), vector.String(), check.expectedValue)
+		}
+	}
+	return nil
+}
+
+// runTimeModifiersCheck runs range and instant queries whose evaluation time is shifted back by the configured
+// offset through each of the configured PromQL time modifiers, and checks whether the results match the values
+// written at the shifted time, in order to catch any issue in the query-frontend time rewriting. The range queries
+// run with the results cache enabled, to cover the cached results too.
+func (t *WriteReadSeriesTest) runTimeModifiersCheck(ctx context.Context) error {
+	const checkName = "time_modifiers"
+
+	// The check requires written samples at the shifted time of all the points.
+	offset := t.cfg.TimeModifiersCheckOffset
+	end := t.queryMaxTime
+	at := end.Add(-offset)
+	if at.Before(t.queryMinTime) {
+		return nil
+	}
+	start := maxTime(t.queryMinTime.Add(offset), alignTimestampToInterval(end.Add(-time.Hour), t.cfg.WriteInterval))
+	step := t.cfg.WriteInterval
+
+	sp, ctx := spanlogger.NewWithLogger(ctx, t.logger, "WriteReadSeriesTest.runTimeModifiersCheck")
+	defer sp.Finish()
+
+	checksTotal, checksFailedTotal := t.metrics.additionalCheckCounters(checkName)
+
+	for _, modifier := range t.cfg.TimeModifiers {
+		var (
+			query         string
+			expectedValue func(time.Time) float64
+		)
+		switch modifier {
+		case timeModifierOffset:
+			query = fmt.Sprintf("sum(max_over_time(%s[1s] offset %s))", t.metricSelector, model.Duration(offset))
+			expectedValue = func(ts time.Time) float64 { return t.generateSumValue(ts.Add(-offset)) }
+		case timeModifierAt:
+			query = fmt.Sprintf("sum(max_over_time(%s[1s] @ %s))", t.metricSelector, strconv.FormatFloat(float64(at.UnixMilli())/1000, 'f', -1, 64))
+			expectedValue = func(time.Time) float64 { return t.generateSumValue(at) }
+		}
+
+		logger := log.With(sp, "query", query, "start", start.UnixMilli(), "end", end.UnixMilli(), "step", step)
+		level.Debug(logger).Log("msg", "Running range query")
 
-type WriteReadSeriesTest struct {
-	name    string
-	cfg     WriteReadSeriesTestConfig
-	client  MimirClient
-	logger  log.Logger
-	metrics *TestMetrics
+		t.metrics.queriesTotal.Inc()
+		matrix, err := t.client.QueryRange(ctx, query, start, end, step, WithResultsCacheEnabled(true))
+		if err != nil {
+			t.metrics.queriesFailedTotal.WithLabelValues(classifyQueryError(err), queryErrorStatusCode(err)).Inc()
+			level.Warn(logger).Log("msg", "Failed to execute range query", "err", err)
+			return errors.Wrap(err, "failed to execute range query")
+		}
 
-	lastWrittenTimestamp time.Time
-	queryMinTime         time.Time
-	queryMaxTime         time.Time
-}
+		checksTotal.Inc()
+		if err := t.verifySampleTimestamps(logger, matrix, start, end, step); err != nil {
+			checksFailedTotal.Inc()
+			return errors.Wrapf(err, "time modifiers check failed: range query %s", query)
+		}
+		if _, err := verifySamplesSum(matrix, 1, step, expectedValue, t.cfg.ResultCheckTolerance); err != nil {
+			checksFailedTotal.Inc()
+			level.Warn(logger).Log("msg", "Time modifiers check failed", "err", err)
+			return errors.Wrapf(err, "time modifiers check failed: range query %s", query)
+		}
 
-func NewWriteReadSeriesTest(cfg WriteReadSeriesTestConfig, client MimirClient, logger log.Logger, reg prometheus.Registerer) *WriteReadSeriesTest {
-	const name = "write-read-series"
+		results, err := t.runInstantQueries(ctx, sp, end, query)
+		if err != nil {
+			return err
+		}
 
-	return &WriteReadSeriesTest{
-		name:    name,
-		cfg:     cfg,
-		client:  client,
-		logger:  log.With(logger, "test", name),
-		metrics: NewTestMetrics(name, reg),
+		vector, expectedSum := results[0], expectedValue(end)
+		checksTotal.Inc()
+		if len(vector) != 1 || !compareSampleValues(expectedSum, float64(vector[0].Value), t.cfg.ResultCheckTolerance) {
+			checksFailedTotal.Inc()
+			level.Warn(sp).Log("msg", "Time modifiers check failed", "query", query, "ts", end.UnixMilli(), "expected", expectedSum, "result", vector.String())
+			return fmt.Errorf("time modifiers check failed: instant query %s at timestamp %d returned %s while was expecting %f", query, end.UnixMilli(), vector.String(), expectedSum)
+		}
 	}
+	return nil
 }
 
-// Name implements Test.
-func (t *WriteReadSeriesTest) Name() string {
-	return t.name
-}
+// runRelativeTimeCheck runs an instant query whose evaluation time is a relative time string, like the ones
+// sent by Grafana, and checks that the result matches the sum of the values written at the evaluation time
+// computed by the server. The check is disabled if the server doesn't support relative times.
+func (t *WriteReadSeriesTest) runRelativeTimeCheck(ctx context.Context) error {
+	const checkName = "relative_time"
 
-// Init implements Test.
-func (t *WriteReadSeriesTest) Init(ctx context.Context, now time.Time) error {
-	level.Info(t.logger).Log("msg", "Finding previously written samples time range to recover writes and reads from previous run")
+	sp, ctx := spanlogger.NewWithLogger(ctx, t.logger, "WriteReadSeriesTest.runRelativeTimeCheck")
+	defer sp.Finish()
 
-	from, to := t.findPreviouslyWrittenTimeRange(ctx, now)
-	if from.IsZero() || to.IsZero() {
-		level.Info(t.logger).Log("msg", "No valid previously written samples time range found, will continue writing from the nearest interval-aligned timestamp")
-		return nil
+	// The evaluation time is not aligned to the write interval, so the query relies on the lookback to select
+	// the last written samples.
+	query := fmt.Sprintf("sum(%s)", t.metricSelector)
+	relativeTime := "now-" + model.Duration(t.cfg.RelativeTimeCheckOffset).String()
+	logger := log.With(sp, "query", query, "time", relativeTime)
+	level.Debug(logger).Log("msg", "Running instant query")
+
+	t.metrics.queriesTotal.Inc()
+	vector, err := t.client.QueryRelativeTime(ctx, query, relativeTime, WithResultsCacheEnabled(true))
+	if err != nil {
+		if queryErrorStatusCode(err) == strconv.Itoa(http.StatusBadRequest) {
+			t.relativeTimeUnsupported = true
+			level.Warn(logger).Log("msg", "The server doesn't support relative times, the relative time check is skipped", "err", err)
+			return nil
+		}
+
+		t.metrics.queriesFailedTotal.WithLabelValues(classifyQueryError(err), queryErrorStatusCode(err)).Inc()
+		level.Warn(logger).Log("msg", "Failed to execute instant query", "err", err)
+		return errors.Wrap(err, "failed to execute instant query")
 	}
-	if to.Before(now.Add(-writeMaxAge)) {
-		level.Info(t.logger).Log("msg", "Previously written samples time range found but latest written sample is too old to recover", "last_sample_timestamp", to)
-		return nil
+
+	// The evaluation time is computed by the server, so it's only known from the returned sample. The samples
+	// are written at each write interval, so the one returned is the last one written before the evaluation time.
+	if len(vector) == 1 {
+		writtenAt := alignTimestampToInterval(vector[0].Timestamp.Time(), t.cfg.WriteInterval)
+		if writtenAt.Before(t.queryMinTime) || writtenAt.After(t.queryMaxTime) {
+			level.Debug(logger).Log("msg", "Skipped the relative time check because the evaluation time is outside the written time range", "ts", vector[0].Timestamp)
+			return nil
+		}
 	}
 
-	t.lastWrittenTimestamp = to
-	t.queryMinTime = from
-	t.queryMaxTime = to
-	level.Info(t.logger).Log("msg", "Successfully found previously written samples time range and recovered writes and reads from there", "last_written_timestamp", t.lastWrittenTimestamp, "query_min_time", t.queryMinTime, "query_max_time", t.queryMaxTime)
+	checksTotal, checksFailedTotal := t.metrics.additionalCheckCounters(checkName)
+	checksTotal.Inc()
+	if len(vector) != 1 {
+		checksFailedTotal.Inc()
+		level.Warn(logger).Log("msg", "Relative time check failed", "result", vector.String())
+		return fmt.Errorf("relative time check failed: instant query %s at time %s returned %d series while was expecting 1", query, relativeTime, len(vector))
+	}
 
+	ts := vector[0].Timestamp.Time()
+	expectedSum := t.generateSumValue(alignTimestampToInterval(ts, t.cfg.WriteInterval))
+	if !compareSampleValues(expectedSum, float64(vector[0].Value), t.cfg.ResultCheckTolerance) {
+		checksFailedTotal.Inc()
+		level.Warn(logger).Log("msg", "Relative time check failed", "ts", ts.UnixMilli(), "expected", expectedSum, "result", vector.String())
+		return fmt.Errorf("relative time check failed: instant query %s at time %s (evaluated at timestamp %d) returned %s while was expecting %f", query, relativeTime, ts.UnixMilli(), vector.String(), expectedSum)
+	}
 	return nil
 }
 
-// Run implements Test.
-func (t *WriteReadSeriesTest) Run(ctx context.Context, now time.Time) error {
-	// Configure the rate limiter to send a sample for each series per second. At startup, this test may catch up
-	// with previous missing writes: this rate limit reduces the chances to hit the ingestion limit on Mimir side.
-	writeLimiter := rate.NewLimiter(rate.Limit(t.cfg.NumSeries), t.cfg.NumSeries)
+// runQuerySplitCheck runs a range query straddling the most recent query-frontend split boundary, and checks
+// whether its points match the ones returned by an unsplit baseline of instant queries run at each step, in order
+// to catch any sample lost at the split boundaries. Both are run with the results cache disabled.
+func (t *WriteReadSeriesTest) runQuerySplitCheck(ctx context.Context) error {
+	const (
+		checkName = "query_split"
 
-	// Collect all errors on this test run
-	errs := new(multierror.MultiError)
+		// The number of steps queried on each side of the split boundary.
+		boundarySteps = 10
+	)
 
-	// Write series for each expected timestamp until now.
-	for timestamp := t.nextWriteTimestamp(now); !timestamp.After(now); timestamp = t.nextWriteTimestamp(now) {
-		if err := writeLimiter.WaitN(ctx, t.cfg.NumSeries); err != nil {
-			// Context has been canceled, so we should interrupt.
+	// The split boundary must have written samples on both sides. Splits are aligned to the split interval.
+	step := t.cfg.WriteInterval
+	boundary := alignTimestampToInterval(t.queryMaxTime.Add(-step), t.cfg.QueryFrontendSplitInterval)
+	if !boundary.After(t.queryMinTime) {
+		return nil
+	}
+	start := maxTime(t.queryMinTime, alignTimestampToInterval(boundary.Add(-boundarySteps*step), step))
+	end := minTime(t.queryMaxTime, alignTimestampToInterval(boundary.Add(boundarySteps*step), step))
+
+	sp, ctx := spanlogger.NewWithLogger(ctx, t.logger, "WriteReadSeriesTest.runQuerySplitCheck")
+	defer sp.Finish()
+
+	logger := log.With(sp, "query", t.queryMetricSum, "start", start.UnixMilli(), "end", end.UnixMilli(), "step", step, "split_boundary", boundary.UnixMilli())
+	level.Debug(logger).Log("msg", "Running range query")
+
+	t.metrics.queriesTotal.Inc()
+	matrix, err := t.client.QueryRange(ctx, t.queryMetricSum, start, end, step, WithResultsCacheEnabled(false))
+	if err != nil {
+		t.metrics.queriesFailedTotal.WithLabelValues(classifyQueryError(err), queryErrorStatusCode(err)).Inc()
+		level.Warn(logger).Log("msg", "Failed to execute range query", "err", err)
+		return errors.Wrap(err, "failed to execute range query")
+	}
+
+	// Build the unsplit baseline from the instant queries run at each step.
+	var baseline []model.SamplePair
+	for ts := start; !ts.After(end); ts = ts.Add(step) {
+		results, err := t.runInstantQueries(ctx, sp, ts, t.queryMetricSum)
+		if err != nil {
 			return err
 		}
-
-		if err := t.writeSamples(ctx, timestamp); err != nil {
-			errs.Add(err)
-			break
+		for _, sample := range results[0] {
+			baseline = append(baseline, model.SamplePair{Timestamp: sample.Timestamp, Value: sample.Value})
 		}
 	}
 
-	queryRanges, queryInstants, err := t.getQueryTimeRanges(now)
-	if err != nil {
-		errs.Add(err)
+	checksTotal, checksFailedTotal := t.metrics.additionalCheckCounters(checkName)
+	checksTotal.Inc()
+	if mismatches := countPointMismatches(matrix, baseline, t.cfg.ResultCheckTolerance); mismatches > 0 {
+		checksFailedTotal.Inc()
+		t.metrics.querySplitMismatchTotal.Add(float64(mismatches))
+		level.Warn(logger).Log("msg", "Query split check failed", "mismatches", mismatches, "range_query_result", matrix.String())
+		return fmt.Errorf("query split check failed: range query %s between %d and %d straddling the split boundary %d returned %d points different from the instant queries run at each step", t.queryMetricSum, start.UnixMilli(), end.UnixMilli(), boundary.UnixMilli(), mismatches)
 	}
-	for _, timeRange := range queryRanges {
-		err := t.runRangeQueryAndVerifyResult(ctx, timeRange[0], timeRange[1], true)
-		errs.Add(err)
-		err = t.runRangeQueryAndVerifyResult(ctx, timeRange[0], timeRange[1], false)
-		errs.Add(err)
+	return nil
+}
+
+// runRateAggregationCheck runs both sum(rate()) and rate(sum()) instant queries at the most recently written
+// sample, and checks whether their results match, in order to catch any aggregation issue.
+func (t *WriteReadSeriesTest) runRateAggregationCheck(ctx context.Context) error {
+	const checkName = "rate_aggregation"
+
+	// The check requires written samples over the whole range selector. The rate of the sum would see a change
+	// of the number of series as a counter increase or reset, so the number of series must not change either.
+	ts := t.queryMaxTime
+	if ts.Add(-rateAggregationCheckRange).Before(t.queryMinTime) {
+		return nil
 	}
-	for _, ts := range queryInstants {
-		err := t.runInstantQueryAndVerifyResult(ctx, ts, true)
-		errs.Add(err)
-		err = t.runInstantQueryAndVerifyResult(ctx, ts, false)
-		errs.Add(err)
+	if _, ok := t.numSeriesIn(ts.Add(-rateAggregationCheckRange), ts); !ok {
+		return nil
 	}
-	return errs.Err()
-}
 
-func (t *WriteReadSeriesTest) writeSamples(ctx context.Context, timestamp time.Time) error {
-	sp, ctx := spanlogger.NewWithLogger(ctx, t.logger, "WriteReadSeriesTest.writeSamples")
+	sp, ctx := spanlogger.NewWithLogger(ctx, t.logger, "WriteReadSeriesTest.runRateAggregationCheck")
 	defer sp.Finish()
-	logger := log.With(sp, "timestamp", timestamp.String(), "num_series", t.cfg.NumSeries)
 
-	statusCode, err := t.client.WriteSeries(ctx, generateSineWaveSeries(metricName, timestamp, t.cfg.NumSeries))
+	results, err := t.runInstantQueries(ctx, sp, ts, t.queryMetricSumOfRates, t.queryMetricRateOfSum)
+	if err != nil {
+		return err
+	}
 
-	t.metrics.writesTotal.Inc()
-	if statusCode/100 != 2 {
-		t.metrics.writesFailedTotal.WithLabelValues(strconv.Itoa(statusCode)).Inc()
-		level.Warn(logger).Log("msg", "Failed to remote write series", "status_code", statusCode, "err", err)
-	} else {
-		level.Debug(logger).Log("msg", "Remote write series succeeded")
+	sumOfRates, rateOfSum := results[0], results[1]
+
+	checksTotal, checksFailedTotal := t.metrics.additionalCheckCounters(checkName)
+	checksTotal.Inc()
+	if len(sumOfRates) != 1 || len(rateOfSum) != 1 || !compareSampleValues(float64(rateOfSum[0].Value), float64(sumOfRates[0].Value), t.cfg.ResultCheckTolerance) {
+		checksFailedTotal.Inc()
+		t.metrics.rateAggregationDivergenceTotal.Inc()
+		level.Warn(sp).Log("msg", "Rate aggregation check failed", "ts", ts.UnixMilli(), "sum_of_rates", sumOfRates.String(), "rate_of_sum", rateOfSum.String())
+		return fmt.Errorf("rate aggregation check failed: query %s at timestamp %d returned %s while query %s returned %s", t.queryMetricSumOfRates, ts.UnixMilli(), sumOfRates.String(), t.queryMetricRateOfSum, rateOfSum.String())
 	}
+	return nil
+}
 
-	// If the write request failed because of a 4xx error, retrying the request isn't expected to succeed.
-	// The series may have been not written at all or partially written (eg. we hit some limit).
-	// We keep writing the next interval, but we reset the query timestamp because we can't reliably
-	// assert on query results due to possible gaps.
-	if statusCode/100 == 4 {
-		t.lastWrittenTimestamp = timestamp
-		t.queryMinTime = time.Time{}
-		t.queryMaxTime = time.Time{}
+// runCounterRateCheck runs the rate of the sum query at the most recently written sample, and checks whether the
+// result matches the analytically expected slope. It only runs when the written series are counters, whose value
+// increases by 1 every second.
+func (t *WriteReadSeriesTest) runCounterRateCheck(ctx context.Context) error {
+	const checkName = "counter_rate"
+
+	// The check requires written samples over the whole range selector, with the same number of series.
+	ts := t.queryMaxTime
+	if ts.Add(-rateAggregationCheckRange).Before(t.queryMinTime) {
+		return nil
+	}
+	numSeries, ok := t.numSeriesIn(ts.Add(-rateAggregationCheckRange), ts)
+	if !ok {
 		return nil
 	}
 
-	// If the write request failed because of a network or 5xx error, we'll retry to write series
-	// in the next test run.
+	sp, ctx := spanlogger.NewWithLogger(ctx, t.logger, "WriteReadSeriesTest.runCounterRateCheck")
+	defer sp.Finish()
+
+	results, err := t.runInstantQueries(ctx, sp, ts, t.queryMetricRateOfSum)
 	if err != nil {
-		return errors.Wrap(err, "failed to remote write series")
-	}
-	if statusCode/100 != 2 {
-		return errors.Wrapf(err, "remote write series failed with status code %d", statusCode)
+		return err
 	}
 
-	// The write request succeeded.
-	t.lastWrittenTimestamp = timestamp
-	t.queryMaxTime = timestamp
-	if t.queryMinTime.IsZero() {
-		t.queryMinTime = timestamp
-	}
+	rateOfSum := results[0]
+	expectedRate := float64(numSeries)
 
+	checksTotal, checksFailedTotal := t.metrics.additionalCheckCounters(checkName)
+	checksTotal.Inc()
+	if len(rateOfSum) != 1 || !compareSampleValues(expectedRate, float64(rateOfSum[0].Value), t.cfg.ResultCheckTolerance) {
+		checksFailedTotal.Inc()
+		level.Warn(sp).Log("msg", "Counter rate check failed", "ts", ts.UnixMilli(), "expected", expectedRate, "actual", rateOfSum.String())
+		return fmt.Errorf("counter rate check failed: query %s at timestamp %d returned %s while was expecting %f", t.queryMetricRateOfSum, ts.UnixMilli(), rateOfSum.String(), expectedRate)
+	}
 	return nil
 }
 
-// getQueryTimeRanges returns the start/end time ranges to use to run test range queries,
-// and the timestamps to use to run test instant queries.
-func (t *WriteReadSeriesTest) getQueryTimeRanges(now time.Time) (ranges [][2]time.Time, instants []time.Time, err error) {
-	// The min and max allowed query timestamps are zero if there's no successfully written data yet.
-	if t.queryMinTime.IsZero() || t.queryMaxTime.IsZero() {
-		level.Info(t.logger).Log("msg", "Skipped queries because there's no valid time range to query")
-		return nil, nil, errors.New("no valid time range to query")
-	}
+// runRegexMatcherCheck runs the same instant query, at the most recently written sample, both with and without
+// a regex label matcher matching all written series, and checks whether their results match, in order to catch
+// any regex matcher issue.
+func (t *WriteReadSeriesTest) runRegexMatcherCheck(ctx context.Context) error {
+	return t.runInstantQueriesEquivalenceCheck(ctx, "regex_matcher", t.queryMetricSum, t.queryMetricSumWithRegexMatcher, t.metrics.regexMatcherDivergenceTotal)
+}
 
-	// Honor the configured max age.
-	adjustedQueryMinTime := maxTime(t.queryMinTime, now.Add(-t.cfg.MaxQueryAge))
-	if t.queryMaxTime.Before(adjustedQueryMinTime) {
-		level.Info(t.logger).Log("msg", "Skipped queries because there's no valid time range to query after honoring configured max query age", "min_valid_time", t.queryMinTime, "max_valid_time", t.queryMaxTime, "max_query_age", t.cfg.MaxQueryAge)
-		return nil, nil, errors.New("no valid time range to query after honoring configured max query age")
+// runNameMatcherCheck runs an instant query selecting the written series by the bare metric name, and the same
+// query selecting them with an explicit __name__ label matcher, and checks whether they return the same result.
+func (t *WriteReadSeriesTest) runNameMatcherCheck(ctx context.Context) error {
+	return t.runInstantQueriesEquivalenceCheck(ctx, "name_matcher", t.queryMetricSum, t.queryMetricSumWithNameMatcher, t.metrics.nameMatcherDivergenceTotal)
+}
+
+// runInstantQueriesEquivalenceCheck runs two instant queries which are expected to return the same single sample,
+// at the most recently written sample, and checks whether their results match. The divergence counter is
+// incremented each time they don't.
+func (t *WriteReadSeriesTest) runInstantQueriesEquivalenceCheck(ctx context.Context, checkName, queryA, queryB string, divergenceTotal prometheus.Counter) error {
+	ts := t.queryMaxTime
+
+	sp, ctx := spanlogger.NewWithLogger(ctx, t.logger, "WriteReadSeriesTest.runInstantQueriesEquivalenceCheck")
+	defer sp.Finish()
+
+	results, err := t.runInstantQueries(ctx, sp, ts, queryA, queryB)
+	if err != nil {
+		return err
 	}
 
-	// Last 1h.
-	if t.queryMaxTime.After(now.Add(-1 * time.Hour)) {
-		ranges = append(ranges, [2]time.Time{
-			maxTime(adjustedQueryMinTime, now.Add(-1*time.Hour)),
-			minTime(t.queryMaxTime, now),
-		})
-		instants = append(instants, minTime(t.queryMaxTime, now))
+	resultA, resultB := results[0], results[1]
+
+	checksTotal, checksFailedTotal := t.metrics.additionalCheckCounters(checkName)
+	checksTotal.Inc()
+	if len(resultA) != 1 || len(resultB) != 1 || !compareSampleValues(float64(resultA[0].Value), float64(resultB[0].Value), t.cfg.ResultCheckTolerance) {
+		checksFailedTotal.Inc()
+		divergenceTotal.Inc()
+		level.Warn(sp).Log("msg", "Instant queries equivalence check failed", "check", checkName, "ts", ts.UnixMilli(), "query_a", queryA, "result_a", resultA.String(), "query_b", queryB, "result_b", resultB.String())
+		return fmt.Errorf("%s check failed: query %s at timestamp %d returned %s while query %s returned %s", strings.ReplaceAll(checkName, "_", " "), queryA, ts.UnixMilli(), resultA.String(), queryB, resultB.String())
 	}
+	return nil
+}
 
-	// Last 24h (only if the actual time range is not already covered by "Last 1h").
-	if t.queryMaxTime.After(now.Add(-24*time.Hour)) && adjustedQueryMinTime.Before(now.Add(-1*time.Hour)) {
-		ranges = append(ranges, [2]time.Time{
-			maxTime(adjustedQueryMinTime, now.Add(-24*time.Hour)),
-			minTime(t.queryMaxTime, now),
-		})
-		instants = append(instants, maxTime(adjustedQueryMinTime, now.Add(-24*time.Hour)))
+// runEquivalentQueriesCheck runs two logically identical but textually different range queries over the last hour,
+// with the results cache enabled, and checks whether their results match, in order to catch any results cache
+// key issue.
+func (t *WriteReadSeriesTest) runEquivalentQueriesCheck(ctx context.Context) error {
+	const checkName = "equivalent_queries"
+
+	start := maxTime(t.queryMinTime, alignTimestampToInterval(t.queryMaxTime.Add(-time.Hour), t.cfg.WriteInterval))
+	end := t.queryMaxTime
+
+	sp, ctx := spanlogger.NewWithLogger(ctx, t.logger, "WriteReadSeriesTest.runEquivalentQueriesCheck")
+	defer sp.Finish()
+
+	results := make([]model.Matrix, 0, 2)
+	for _, query := range []string{t.queryMetricSum, t.queryMetricSumWithParentheses} {
+		logger := log.With(sp, "query", query, "start", start.UnixMilli(), "end", end.UnixMilli(), "step", t.cfg.WriteInterval)
+		level.Debug(logger).Log("msg", "Running range query")
+
+		t.metrics.queriesTotal.Inc()
+		matrix, err := t.client.QueryRange(ctx, query, start, end, t.cfg.WriteInterval, WithResultsCacheEnabled(true))
+		if err != nil {
+			t.metrics.queriesFailedTotal.WithLabelValues(classifyQueryError(err), queryErrorStatusCode(err)).Inc()
+			level.Warn(logger).Log("msg", "Failed to execute range query", "err", err)
+			return errors.Wrap(err, "failed to execute range query")
+		}
+
+		results = append(results, matrix)
 	}
 
-	// From last 23h to last 24h.
-	if adjustedQueryMinTime.Before(now.Add(-23*time.Hour)) && t.queryMaxTime.After(now.Add(-23*time.Hour)) {
-		ranges = append(ranges, [2]time.Time{
-			maxTime(adjustedQueryMinTime, now.Add(-24*time.Hour)),
-			minTime(t.queryMaxTime, now.Add(-23*time.Hour)),
-		})
+	checksTotal, checksFailedTotal := t.metrics.additionalCheckCounters(checkName)
+	checksTotal.Inc()
+	if err := compareMatrices(results[0], results[1], t.cfg.ResultCheckTolerance); err != nil {
+		checksFailedTotal.Inc()
+		t.metrics.equivalentQueriesDivergenceTotal.Inc()
+		level.Warn(sp).Log("msg", "Equivalent queries check failed", "first_query", t.queryMetricSum, "second_query", t.queryMetricSumWithParentheses, "err", err)
+		return errors.Wrapf(err, "equivalent queries check failed: queries %s and %s returned different results", t.queryMetricSum, t.queryMetricSumWithParentheses)
 	}
+	return nil
+}
 
-	// A random time range.
-	randMinTime := randTime(adjustedQueryMinTime, t.queryMaxTime)
-	ranges = append(ranges, [2]time.Time{randMinTime, randTime(randMinTime, t.queryMaxTime)})
-	instants = append(instants, randMinTime)
+// runInstantQueries runs the input instant queries at the input timestamp, and returns their results in the
+// same order. Returns error as soon as a query fails.
+func (t *WriteReadSeriesTest) runInstantQueries(ctx context.Context, logger log.Logger, ts time.Time, queries ...string) ([]model.Vector, error) {
+	results := make([]model.Vector, 0, len(queries))
+	for _, query := range queries {
+		queryLogger := log.With(logger, "query", query, "ts", ts.UnixMilli())
+		level.Debug(queryLogger).Log("msg", "Running instant query")
 
-	return ranges, instants, nil
-}
+		t.metrics.queriesTotal.Inc()
+		vector, err := t.client.Query(ctx, query, ts, WithResultsCacheEnabled(false))
+		if err != nil {
+			t.metrics.queriesFailedTotal.WithLabelValues(classifyQueryError(err), queryErrorStatusCode(err)).Inc()
+			level.Warn(queryLogger).Log("msg", "Failed to execute instant query", "err", err)
+			return nil, errors.Wrap(err, "failed to execute instant query")
+		}
 
-func (t *WriteReadSeriesTest) runRangeQueryAndVerifyResult(ctx context.Context, start, end time.Time, resultsCacheEnabled bool) error {
-	// We align start, end and step to write interval in order to avoid any false positives
-	// when checking results correctness. The min/max query time is always aligned.
-	start = maxTime(t.queryMinTime, alignTimestampToInterval(start, writeInterval))
-	end = minTime(t.queryMaxTime, alignTimestampToInterval(end, writeInterval))
-	if end.Before(start) {
-		return nil
+		results = append(results, vector)
 	}
+	return results, nil
+}
 
-	step := getQueryStep(start, end, writeInterval)
-
-	sp, ctx := spanlogger.NewWithLogger(ctx, t.logger, "WriteReadSeriesTest.runRangeQueryAndVerifyResult")
-	defer sp.Finish()
+// runFlushCheck triggers a flush of the ingesters and then checks whether the series written in the last hour
+// are still queryable, in order to catch any data loss caused by the flush.
+func (t *WriteReadSeriesTest) runFlushCheck(ctx context.Context) error {
+	const checkName = "flush"
 
-	logger := log.With(sp, "query", queryMetricSum, "start", start.UnixMilli(), "end", end.UnixMilli(), "step", step, "results_cache", strconv.FormatBool(resultsCacheEnabled))
-	level.Debug(logger).Log("msg", "Running range query")
+	checksTotal, checksFailedTotal := t.metrics.additionalCheckCounters(checkName)
+	checksTotal.Inc()
 
-	t.metrics.queriesTotal.Inc()
-	matrix, err := t.client.QueryRange(ctx, queryMetricSum, start, end, step, WithResultsCacheEnabled(resultsCacheEnabled))
-	if err != nil {
-		t.metrics.queriesFailedTotal.Inc()
-		level.Warn(logger).Log("msg", "Failed to execute range query", "err", err)
-		return errors.Wrap(err, "failed to execute range query")
+	level.Debug(t.logger).Log("msg", "Triggering flush")
+	if err := t.client.Flush(ctx); err != nil {
+		checksFailedTotal.Inc()
+		level.Warn(t.logger).Log("msg", "Failed to trigger flush", "err", err)
+		return errors.Wrap(err, "failed to trigger flush")
 	}
 
-	t.metrics.queryResultChecksTotal.Inc()
-	_, err = verifySineWaveSamplesSum(matrix, t.cfg.NumSeries, step)
-	if err != nil {
-		t.metrics.queryResultChecksFailedTotal.Inc()
-		level.Warn(logger).Log("msg", "Range query result check failed", "err", err)
-		return errors.Wrap(err, "range query result check failed")
+	start := maxTime(t.queryMinTime, t.queryMaxTime.Add(-time.Hour))
+	if err := t.runRangeQueryAndVerifyResult(ctx, start, t.queryMaxTime, false); err != nil {
+		checksFailedTotal.Inc()
+		return errors.Wrap(err, "query results check after flush failed")
 	}
 	return nil
 }
 
-func (t *WriteReadSeriesTest) runInstantQueryAndVerifyResult(ctx context.Context, ts time.Time, resultsCacheEnabled bool) error {
-	// We align the query timestamp to write interval in order to avoid any false positives
-	// when checking results correctness. The min/max query time is always aligned.
-	ts = maxTime(t.queryMinTime, alignTimestampToInterval(ts, writeInterval))
-	if t.queryMaxTime.Before(ts) {
+// runBlockPathCheck triggers a flush of the ingesters at the configured interval, and then checks that the samples
+// written since the previous flush are queryable from the flushed blocks. The flush waits until the blocks have
+// been shipped, but they're loaded asynchronously, so the flushed time range is polled until the query results
+// match or the deadline expires.
+func (t *WriteReadSeriesTest) runBlockPathCheck(ctx context.Context, now time.Time) error {
+	const checkName = "block_path"
+
+	if !t.lastBlockPathCheckTime.IsZero() && now.Sub(t.lastBlockPathCheckTime) < t.cfg.BlockPathCheckInterval {
 		return nil
 	}
+	t.lastBlockPathCheckTime = now
 
-	sp, ctx := spanlogger.NewWithLogger(ctx, t.logger, "WriteReadSeriesTest.runInstantQueryAndVerifyResult")
+	// The samples written before the previous flush have already been checked.
+	start := t.queryMinTime
+	if !t.lastFlushedMaxTime.IsZero() {
+		start = maxTime(start, t.lastFlushedMaxTime.Add(t.cfg.WriteInterval))
+	}
+	start, end, step, ok := t.rangeQueryParams(start, t.queryMaxTime)
+	if !ok {
+		return nil
+	}
+
+	sp, ctx := spanlogger.NewWithLogger(ctx, t.logger, "WriteReadSeriesTest.runBlockPathCheck")
 	defer sp.Finish()
 
-	logger := log.With(sp, "query", queryMetricSum, "ts", ts.UnixMilli(), "results_cache", strconv.FormatBool(resultsCacheEnabled))
-	level.Debug(logger).Log("msg", "Running instant query")
+	logger := log.With(sp, "query", t.queryMetricSum, "start", start.UnixMilli(), "end", end.UnixMilli(), "step", step)
 
-	t.metrics.queriesTotal.Inc()
-	vector, err := t.client.Query(ctx, queryMetricSum, ts, WithResultsCacheEnabled(resultsCacheEnabled))
-	if err != nil {
-		t.metrics.queriesFailedTotal.Inc()
-		level.Warn(logger).Log("msg", "Failed to execute instant query", "err", err)
-		return errors.Wrap(err, "failed to execute instant query")
-	}
+	checksTotal, checksFailedTotal := t.metrics.additionalCheckCounters(checkName)
+	checksTotal.Inc()
 
-	// Convert the vector to matrix to reuse the same results comparison utility.
-	matrix := make(model.Matrix, 0, len(vector))
-	for _, entry := range vector {
-		matrix = append(matrix, &model.SampleStream{
-			Metric: entry.Metric,
-			Values: []model.SamplePair{{
-				Timestamp: entry.Timestamp,
-				Value:     entry.Value,
-			}},
-		})
+	level.Debug(logger).Log("msg", "Triggering flush")
+	if err := t.client.Flush(ctx); err != nil {
+		checksFailedTotal.Inc()
+		level.Warn(logger).Log("msg", "Failed to trigger flush", "err", err)
+		return errors.Wrap(err, "failed to trigger flush")
 	}
+	t.lastFlushedMaxTime = end
 
-	t.metrics.queryResultChecksTotal.Inc()
-	_, err = verifySineWaveSamplesSum(matrix, t.cfg.NumSeries, 0)
-	if err != nil {
-		t.metrics.queryResultChecksFailedTotal.Inc()
-		level.Warn(logger).Log("msg", "Instant query result check failed", "err", err)
-		return errors.Wrap(err, "instant query result check failed")
+	level.Debug(logger).Log("msg", "Waiting until the flushed time range is queryable")
+	flushEnd := t.timeNow()
+	deadline := flushEnd.Add(t.cfg.BlockPathPollDeadline)
+
+	for {
+		t.metrics.queriesTotal.Inc()
+		matrix, err := t.client.QueryRange(ctx, t.queryMetricSum, start, end, step, WithResultsCacheEnabled(false))
+		if err != nil {
+			t.metrics.queriesFailedTotal.WithLabelValues(classifyQueryError(err), queryErrorStatusCode(err)).Inc()
+			level.Warn(logger).Log("msg", "Failed to execute range query", "err", err)
+		} else if mismatches, err := countSamplesSumMismatches(matrix, 1, start, end, step, t.generateSumValue, t.cfg.ResultCheckTolerance); err == nil && mismatches == 0 {
+			level.Debug(logger).Log("msg", "The flushed time range is queryable", "elapsed", t.timeNow().Sub(flushEnd))
+			return nil
+		}
+
+		if !t.timeNow().Before(deadline) {
+			checksFailedTotal.Inc()
+			level.Warn(logger).Log("msg", "The flushed time range is not queryable within the deadline", "deadline", t.cfg.BlockPathPollDeadline)
+			return fmt.Errorf("block path check failed: samples written between %d and %d are not queryable after the flush within %s", start.UnixMilli(), end.UnixMilli(), t.cfg.BlockPathPollDeadline)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(t.blockPathPollInterval):
+		}
 	}
-	return nil
 }
 
 func (t *WriteReadSeriesTest) nextWriteTimestamp(now time.Time) time.Time {
 	if t.lastWrittenTimestamp.IsZero() {
-		return alignTimestampToInterval(now, writeInterval)
+		return alignTimestampToInterval(now, t.cfg.WriteInterval)
+	}
+
+	return t.lastWrittenTimestamp.Add(t.cfg.WriteInterval)
+}
+
+// dayWindowStart returns the start of the day window ending at the input time. When the query age anchor is
+// midnight, the window starts at the last midnight before the input time in the configured location, so it may
+// be shorter than 24h, or last 25h on DST transitions. Otherwise, it starts 24h before the input time.
+func (t *WriteReadSeriesTest) dayWindowStart(end time.Time) time.Time {
+	if t.queryAgeLocation == nil {
+		return end.Add(-24 * time.Hour)
+	}
+
+	// Midnight is excluded, so that the window is never empty.
+	localEnd := end.Add(-time.Nanosecond).In(t.queryAgeLocation)
+	year, month, day := localEnd.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.queryAgeLocation).In(end.Location())
+}
+
+// writeJitterOffset returns a pseudo-random offset in the range [0, jitter), which is stable for the input
+// instance ID, so that restarting the tool doesn't shift its writes.
+func writeJitterOffset(instanceID string, jitter time.Duration) time.Duration {
+	if jitter <= 0 {
+		return 0
 	}
 
-	return t.lastWrittenTimestamp.Add(writeInterval)
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(instanceID))
+	return time.Duration(h.Sum64() % uint64(jitter))
 }
 
 func (t *WriteReadSeriesTest) findPreviouslyWrittenTimeRange(ctx context.Context, now time.Time) (from, to time.Time) {
-	end := alignTimestampToInterval(now, writeInterval)
-	step := writeInterval
+	end := alignTimestampToInterval(now, t.cfg.WriteInterval)
+	step := t.cfg.WriteInterval
 
 	var samples []model.SamplePair
 
 	for {
-		start := alignTimestampToInterval(maxTime(now.Add(-t.cfg.MaxQueryAge), end.Add(-24*time.Hour).Add(step)), writeInterval)
+		// The 24h windows exclude their start, which is the end of the previous window, while the windows
+		// aligned to midnight include it.
+		windowStart := t.dayWindowStart(end)
+		if t.queryAgeLocation == nil {
+			windowStart = windowStart.Add(step)
+		}
+
+		start := alignTimestampToInterval(maxTime(now.Add(-t.cfg.MaxQueryAge), windowStart), t.cfg.WriteInterval)
 		if !start.Before(end) {
 			// We've hit the max query age, so we'll keep the last computed valid time range (if any).
 			return
 		}
 
-		logger := log.With(t.logger, "query", queryMetricSum, "start", start, "end", end, "step", step)
+		logger := log.With(t.logger, "query", t.queryMetricSum, "start", start, "end", end, "step", step)
 		level.Debug(logger).Log("msg", "Executing query to find previously written samples")
 
-		matrix, err := t.client.QueryRange(ctx, queryMetricSum, start, end, step, WithResultsCacheEnabled(false))
+		matrix, err := t.queryPreviouslyWrittenSamples(ctx, logger, start, end, step)
 		if err != nil {
 			level.Warn(logger).Log("msg", "Failed to execute range query used to find previously written samples", "err", err)
 			return
@@ -354,7 +3529,7 @@ func (t *WriteReadSeriesTest) findPreviouslyWrittenTimeRange(ctx context.Context
 		samples = append(matrix[0].Values, samples...)
 		end = start.Add(-step)
 
-		lastMatchingIdx, _ := verifySineWaveSamplesSum(model.Matrix{{Values: samples}}, t.cfg.NumSeries, step)
+		lastMatchingIdx, _ := verifySamplesSum(model.Matrix{{Values: samples}}, 1, step, t.generateSumValue, t.cfg.ResultCheckTolerance)
 		if lastMatchingIdx == -1 {
 			return
 		}
@@ -368,5 +3543,35 @@ func (t *WriteReadSeriesTest) findPreviouslyWrittenTimeRange(ctx context.Context
 		if lastMatchingIdx != 0 || !samples[0].Timestamp.Time().Equal(start) {
 			return
 		}
+
+		// Pace the queries, in order to not overload the cluster when starting with a long history.
+		if t.cfg.InitQueryInterval > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(t.cfg.InitQueryInterval):
+			}
+		}
+	}
+}
+
+// queryPreviouslyWrittenSamples runs the range query used to find the previously written samples between start
+// and end, retrying it with exponential backoff up to the configured number of times if it's rate limited.
+// Queries failed for any other reason are not retried.
+func (t *WriteReadSeriesTest) queryPreviouslyWrittenSamples(ctx context.Context, logger log.Logger, start, end time.Time, step time.Duration) (model.Matrix, error) {
+	retries := backoff.New(ctx, backoff.Config{
+		MinBackoff: t.cfg.InitQueryBackoff.MinBackoff,
+		MaxBackoff: t.cfg.InitQueryBackoff.MaxBackoff,
+		MaxRetries: t.cfg.InitQueryRetries,
+	})
+
+	for {
+		matrix, err := t.client.QueryRange(ctx, t.queryMetricSum, start, end, step, WithResultsCacheEnabled(false))
+		if err == nil || queryErrorStatusCode(err) != strconv.Itoa(http.StatusTooManyRequests) || t.cfg.InitQueryRetries <= 0 || !retries.Ongoing() {
+			return matrix, err
+		}
+
+		level.Warn(logger).Log("msg", "Range query used to find previously written samples has been rate limited, retrying", "err", err, "retry", retries.NumRetries()+1)
+		retries.Wait()
 	}
 }
@@ -3,37 +3,109 @@
 package continuoustest
 
 import (
+	"bytes"
 	"context"
+	"encoding/csv"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/go-kit/log"
 	"github.com/grafana/dskit/flagext"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/prometheus/prometheus/prompb"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
 )
 
+func TestNewWriteReadSeriesTest(t *testing.T) {
+	tests := map[string]struct {
+		numSeries           int
+		maxCardinality      int
+		outOfOrderWindow    time.Duration
+		expectedErr         bool
+		expectedCardinality int
+	}{
+		"should succeed if max cardinality is disabled": {
+			numSeries:           100,
+			maxCardinality:      0,
+			expectedCardinality: 100,
+		},
+		"should succeed if cardinality is below max cardinality": {
+			numSeries:           99,
+			maxCardinality:      100,
+			expectedCardinality: 99,
+		},
+		"should succeed if cardinality is equal to max cardinality": {
+			numSeries:           99,
+			maxCardinality:      100,
+			outOfOrderWindow:    time.Hour,
+			expectedCardinality: 100,
+		},
+		"should fail if cardinality is above max cardinality": {
+			numSeries:      101,
+			maxCardinality: 100,
+			expectedErr:    true,
+		},
+		"should fail if cardinality is above max cardinality including probe series": {
+			numSeries:        100,
+			maxCardinality:   100,
+			outOfOrderWindow: time.Hour,
+			expectedErr:      true,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			cfg := WriteReadSeriesTestConfig{}
+			flagext.DefaultValues(&cfg)
+			cfg.NumSeries = testData.numSeries
+			cfg.MaxCardinality = testData.maxCardinality
+			cfg.OOOWindow = testData.outOfOrderWindow
+
+			reg := prometheus.NewPedanticRegistry()
+			test, err := NewWriteReadSeriesTest(cfg, &ClientMock{}, log.NewNopLogger(), reg)
+			if testData.expectedErr {
+				require.Error(t, err)
+				assert.Nil(t, test)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(fmt.Sprintf(`
+				# HELP mimir_continuous_test_cardinality Number of series written by the test.
+				# TYPE mimir_continuous_test_cardinality gauge
+				mimir_continuous_test_cardinality{test="write-read-series"} %d
+			`, testData.expectedCardinality)), "mimir_continuous_test_cardinality"))
+		})
+	}
+}
+
 func TestWriteReadSeriesTest_Run(t *testing.T) {
-	logger := log.NewNopLogger()
 	cfg := WriteReadSeriesTestConfig{}
 	flagext.DefaultValues(&cfg)
 	cfg.NumSeries = 2
 
 	t.Run("should write series with current timestamp if it's already aligned to write interval", func(t *testing.T) {
-		client := &ClientMock{}
+		test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
 		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
 		client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{}, nil)
 		client.On("Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Vector{}, nil)
 
-		reg := prometheus.NewPedanticRegistry()
-		test := NewWriteReadSeriesTest(cfg, client, logger, reg)
-
 		now := time.Unix(1000, 0)
 		// Ignore this error. It will be non-nil because the query mock does not return any data.
 		_ = test.Run(context.Background(), now)
@@ -43,7 +115,7 @@ func TestWriteReadSeriesTest_Run(t *testing.T) {
 		assert.Equal(t, int64(1000), test.lastWrittenTimestamp.Unix())
 
 		client.AssertNumberOfCalls(t, "QueryRange", 4)
-		client.AssertCalled(t, "QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", time.Unix(1000, 0), time.Unix(1000, 0), writeInterval, mock.Anything)
+		client.AssertCalled(t, "QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", time.Unix(1000, 0), time.Unix(1000, 0), defaultWriteInterval, mock.Anything)
 
 		client.AssertNumberOfCalls(t, "Query", 4)
 		client.AssertCalled(t, "Query", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", time.Unix(1000, 0), mock.Anything)
@@ -56,24 +128,17 @@ func TestWriteReadSeriesTest_Run(t *testing.T) {
 			# HELP mimir_continuous_test_queries_total Total number of attempted query requests.
 			# TYPE mimir_continuous_test_queries_total counter
 			mimir_continuous_test_queries_total{test="write-read-series"} 8
-
-			# HELP mimir_continuous_test_queries_failed_total Total number of failed query requests.
-			# TYPE mimir_continuous_test_queries_failed_total counter
-			mimir_continuous_test_queries_failed_total{test="write-read-series"} 0
 		`),
 			"mimir_continuous_test_writes_total", "mimir_continuous_test_writes_failed_total",
 			"mimir_continuous_test_queries_total", "mimir_continuous_test_queries_failed_total"))
 	})
 
 	t.Run("should write series with timestamp aligned to write interval", func(t *testing.T) {
-		client := &ClientMock{}
+		test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
 		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
 		client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{}, nil)
 		client.On("Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Vector{}, nil)
 
-		reg := prometheus.NewPedanticRegistry()
-		test := NewWriteReadSeriesTest(cfg, client, logger, reg)
-
 		now := time.Unix(999, 0)
 		// Ignore this error. It will be non-nil because the query mock does not return any data.
 		_ = test.Run(context.Background(), now)
@@ -83,7 +148,7 @@ func TestWriteReadSeriesTest_Run(t *testing.T) {
 		assert.Equal(t, int64(980), test.lastWrittenTimestamp.Unix())
 
 		client.AssertNumberOfCalls(t, "QueryRange", 4)
-		client.AssertCalled(t, "QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", time.Unix(980, 0), time.Unix(980, 0), writeInterval, mock.Anything)
+		client.AssertCalled(t, "QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", time.Unix(980, 0), time.Unix(980, 0), defaultWriteInterval, mock.Anything)
 
 		client.AssertNumberOfCalls(t, "Query", 4)
 		client.AssertCalled(t, "Query", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", time.Unix(980, 0), mock.Anything)
@@ -96,24 +161,17 @@ func TestWriteReadSeriesTest_Run(t *testing.T) {
 			# HELP mimir_continuous_test_queries_total Total number of attempted query requests.
 			# TYPE mimir_continuous_test_queries_total counter
 			mimir_continuous_test_queries_total{test="write-read-series"} 8
-
-			# HELP mimir_continuous_test_queries_failed_total Total number of failed query requests.
-			# TYPE mimir_continuous_test_queries_failed_total counter
-			mimir_continuous_test_queries_failed_total{test="write-read-series"} 0
 		`),
 			"mimir_continuous_test_writes_total", "mimir_continuous_test_writes_failed_total",
 			"mimir_continuous_test_queries_total", "mimir_continuous_test_queries_failed_total"))
 	})
 
 	t.Run("should write series from last written timestamp until now", func(t *testing.T) {
-		client := &ClientMock{}
+		test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
 		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
 		client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{}, nil)
 		client.On("Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Vector{}, nil)
 
-		reg := prometheus.NewPedanticRegistry()
-		test := NewWriteReadSeriesTest(cfg, client, logger, reg)
-
 		test.lastWrittenTimestamp = time.Unix(940, 0)
 		now := time.Unix(1000, 0)
 		// Ignore this error. It will be non-nil because the query mock does not return any data.
@@ -126,7 +184,7 @@ func TestWriteReadSeriesTest_Run(t *testing.T) {
 		assert.Equal(t, int64(1000), test.lastWrittenTimestamp.Unix())
 
 		client.AssertNumberOfCalls(t, "QueryRange", 4)
-		client.AssertCalled(t, "QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", time.Unix(960, 0), time.Unix(1000, 0), writeInterval, mock.Anything)
+		client.AssertCalled(t, "QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", time.Unix(960, 0), time.Unix(1000, 0), defaultWriteInterval, mock.Anything)
 
 		client.AssertNumberOfCalls(t, "Query", 4)
 		client.AssertCalled(t, "Query", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", time.Unix(1000, 0), mock.Anything)
@@ -139,22 +197,48 @@ func TestWriteReadSeriesTest_Run(t *testing.T) {
 			# HELP mimir_continuous_test_queries_total Total number of attempted query requests.
 			# TYPE mimir_continuous_test_queries_total counter
 			mimir_continuous_test_queries_total{test="write-read-series"} 8
-
-			# HELP mimir_continuous_test_queries_failed_total Total number of failed query requests.
-			# TYPE mimir_continuous_test_queries_failed_total counter
-			mimir_continuous_test_queries_failed_total{test="write-read-series"} 0
 		`),
 			"mimir_continuous_test_writes_total", "mimir_continuous_test_writes_failed_total",
 			"mimir_continuous_test_queries_total", "mimir_continuous_test_queries_failed_total"))
 	})
 
+	t.Run("should write multiple intervals at once up to the max samples per write", func(t *testing.T) {
+		cfg := cfg
+		cfg.MaxSamplesPerWrite = 5
+
+		test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+		client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{}, nil)
+		client.On("Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Vector{}, nil)
+
+		test.lastWrittenTimestamp = time.Unix(940, 0)
+		now := time.Unix(1000, 0)
+		// Ignore this error. It will be non-nil because the query mock does not return any data.
+		_ = test.Run(context.Background(), now)
+
+		// 2 whole intervals of 2 series fit in the max samples per write.
+		client.AssertNumberOfCalls(t, "WriteSeries", 2)
+		client.AssertCalled(t, "WriteSeries", mock.Anything, append(generateSineWaveSeries(metricName, time.Unix(960, 0), 2), generateSineWaveSeries(metricName, time.Unix(980, 0), 2)...))
+		client.AssertCalled(t, "WriteSeries", mock.Anything, generateSineWaveSeries(metricName, time.Unix(1000, 0), 2))
+		assert.Equal(t, int64(1000), test.lastWrittenTimestamp.Unix())
+		assert.Equal(t, int64(960), test.queryMinTime.Unix())
+		assert.Equal(t, int64(1000), test.queryMaxTime.Unix())
+
+		assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+			# HELP mimir_continuous_test_writes_total Total number of attempted write requests.
+			# TYPE mimir_continuous_test_writes_total counter
+			mimir_continuous_test_writes_total{test="write-read-series"} 2
+
+			# HELP mimir_continuous_test_write_samples_total Total number of samples sent in write requests, including failed ones.
+			# TYPE mimir_continuous_test_write_samples_total counter
+			mimir_continuous_test_write_samples_total{test="write-read-series"} 6
+		`), "mimir_continuous_test_writes_total", "mimir_continuous_test_write_samples_total"))
+	})
+
 	t.Run("should stop remote writing on network error", func(t *testing.T) {
-		client := &ClientMock{}
+		test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
 		client.On("WriteSeries", mock.Anything, mock.Anything).Return(0, errors.New("network error"))
 
-		reg := prometheus.NewPedanticRegistry()
-		test := NewWriteReadSeriesTest(cfg, client, logger, reg)
-
 		test.lastWrittenTimestamp = time.Unix(940, 0)
 		now := time.Unix(1000, 0)
 		err := test.Run(context.Background(), now)
@@ -180,12 +264,9 @@ func TestWriteReadSeriesTest_Run(t *testing.T) {
 	})
 
 	t.Run("should stop remote writing on 5xx error", func(t *testing.T) {
-		client := &ClientMock{}
+		test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
 		client.On("WriteSeries", mock.Anything, mock.Anything).Return(500, errors.New("500 error"))
 
-		reg := prometheus.NewPedanticRegistry()
-		test := NewWriteReadSeriesTest(cfg, client, logger, reg)
-
 		test.lastWrittenTimestamp = time.Unix(940, 0)
 		now := time.Unix(1000, 0)
 		err := test.Run(context.Background(), now)
@@ -211,17 +292,14 @@ func TestWriteReadSeriesTest_Run(t *testing.T) {
 	})
 
 	t.Run("should keep remote writing next intervals on 4xx error", func(t *testing.T) {
-		client := &ClientMock{}
+		test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
 		client.On("WriteSeries", mock.Anything, mock.Anything).Return(400, errors.New("400 error"))
 
-		reg := prometheus.NewPedanticRegistry()
-		test := NewWriteReadSeriesTest(cfg, client, logger, reg)
-
 		test.lastWrittenTimestamp = time.Unix(940, 0)
 		now := time.Unix(1000, 0)
 		err := test.Run(context.Background(), now)
 		// An error is expected for smoke-test mode, but we don't want to stop the test.
-		assert.Error(t, err)
+		assert.ErrorIs(t, err, errWriteRejected)
 
 		client.AssertNumberOfCalls(t, "WriteSeries", 3)
 		client.AssertCalled(t, "WriteSeries", mock.Anything, generateSineWaveSeries(metricName, time.Unix(960, 0), 2))
@@ -244,10 +322,33 @@ func TestWriteReadSeriesTest_Run(t *testing.T) {
 		`), "mimir_continuous_test_writes_total", "mimir_continuous_test_writes_failed_total", "mimir_continuous_test_queries_total"))
 	})
 
+	t.Run("should track failed queries by reason and status code", func(t *testing.T) {
+		now := time.Unix(1000, 0)
+
+		test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+		client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{}, &v1.Error{Type: v1.ErrServer, Msg: "server error: 500"})
+		client.On("Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Vector{}, context.DeadlineExceeded)
+
+		err := test.Run(context.Background(), now)
+		assert.Error(t, err)
+
+		assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+			# HELP mimir_continuous_test_queries_total Total number of attempted query requests.
+			# TYPE mimir_continuous_test_queries_total counter
+			mimir_continuous_test_queries_total{test="write-read-series"} 8
+
+			# HELP mimir_continuous_test_queries_failed_total Total number of failed query requests.
+			# TYPE mimir_continuous_test_queries_failed_total counter
+			mimir_continuous_test_queries_failed_total{reason="5xx",status_code="500",test="write-read-series"} 4
+			mimir_continuous_test_queries_failed_total{reason="timeout",status_code="0",test="write-read-series"} 4
+		`), "mimir_continuous_test_queries_total", "mimir_continuous_test_queries_failed_total"))
+	})
+
 	t.Run("should query written series, compare results and track no failure if results match", func(t *testing.T) {
 		now := time.Unix(1000, 0)
 
-		client := &ClientMock{}
+		test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
 		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
 		client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{
 			{Values: []model.SamplePair{newSamplePair(now, generateSineWaveValue(now)*float64(cfg.NumSeries))}},
@@ -256,9 +357,6 @@ func TestWriteReadSeriesTest_Run(t *testing.T) {
 			{Timestamp: model.Time(now.UnixMilli()), Value: model.SampleValue(generateSineWaveValue(now) * float64(cfg.NumSeries))},
 		}, nil)
 
-		reg := prometheus.NewPedanticRegistry()
-		test := NewWriteReadSeriesTest(cfg, client, logger, reg)
-
 		err := test.Run(context.Background(), now)
 		assert.NoError(t, err)
 
@@ -267,7 +365,7 @@ func TestWriteReadSeriesTest_Run(t *testing.T) {
 		assert.Equal(t, int64(1000), test.lastWrittenTimestamp.Unix())
 
 		client.AssertNumberOfCalls(t, "QueryRange", 4)
-		client.AssertCalled(t, "QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", time.Unix(1000, 0), time.Unix(1000, 0), writeInterval, mock.Anything)
+		client.AssertCalled(t, "QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", time.Unix(1000, 0), time.Unix(1000, 0), defaultWriteInterval, mock.Anything)
 
 		client.AssertNumberOfCalls(t, "Query", 4)
 		client.AssertCalled(t, "Query", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", time.Unix(1000, 0), mock.Anything)
@@ -281,17 +379,15 @@ func TestWriteReadSeriesTest_Run(t *testing.T) {
 			# TYPE mimir_continuous_test_queries_total counter
 			mimir_continuous_test_queries_total{test="write-read-series"} 8
 
-			# HELP mimir_continuous_test_queries_failed_total Total number of failed query requests.
-			# TYPE mimir_continuous_test_queries_failed_total counter
-			mimir_continuous_test_queries_failed_total{test="write-read-series"} 0
-
 			# HELP mimir_continuous_test_query_result_checks_total Total number of query results checked for correctness.
 			# TYPE mimir_continuous_test_query_result_checks_total counter
-			mimir_continuous_test_query_result_checks_total{test="write-read-series"} 8
+			mimir_continuous_test_query_result_checks_total{query_type="instant",read_path="query_api",storage="default",test="write-read-series"} 4
+			mimir_continuous_test_query_result_checks_total{query_type="range",read_path="query_api",storage="default",test="write-read-series"} 4
 
 			# HELP mimir_continuous_test_query_result_checks_failed_total Total number of query results failed when checking for correctness.
 			# TYPE mimir_continuous_test_query_result_checks_failed_total counter
-			mimir_continuous_test_query_result_checks_failed_total{test="write-read-series"} 0
+			mimir_continuous_test_query_result_checks_failed_total{query_type="instant",read_path="query_api",storage="default",test="write-read-series"} 0
+			mimir_continuous_test_query_result_checks_failed_total{query_type="range",read_path="query_api",storage="default",test="write-read-series"} 0
 		`),
 			"mimir_continuous_test_writes_total", "mimir_continuous_test_writes_failed_total",
 			"mimir_continuous_test_queries_total", "mimir_continuous_test_queries_failed_total",
@@ -301,7 +397,7 @@ func TestWriteReadSeriesTest_Run(t *testing.T) {
 	t.Run("should query written series, compare results and track failure if results don't match", func(t *testing.T) {
 		now := time.Unix(1000, 0)
 
-		client := &ClientMock{}
+		test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
 		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
 		client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{
 			{Values: []model.SamplePair{{Timestamp: model.Time(now.UnixMilli()), Value: 12345}}},
@@ -311,9 +407,6 @@ func TestWriteReadSeriesTest_Run(t *testing.T) {
 			{Timestamp: model.Time(now.UnixMilli()), Value: 12345},
 		}, nil)
 
-		reg := prometheus.NewPedanticRegistry()
-		test := NewWriteReadSeriesTest(cfg, client, logger, reg)
-
 		err := test.Run(context.Background(), now)
 		assert.Error(t, err)
 
@@ -322,7 +415,7 @@ func TestWriteReadSeriesTest_Run(t *testing.T) {
 		assert.Equal(t, int64(1000), test.lastWrittenTimestamp.Unix())
 
 		client.AssertNumberOfCalls(t, "QueryRange", 4)
-		client.AssertCalled(t, "QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", time.Unix(1000, 0), time.Unix(1000, 0), writeInterval, mock.Anything)
+		client.AssertCalled(t, "QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", time.Unix(1000, 0), time.Unix(1000, 0), defaultWriteInterval, mock.Anything)
 
 		client.AssertNumberOfCalls(t, "Query", 4)
 		client.AssertCalled(t, "Query", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", time.Unix(1000, 0), mock.Anything)
@@ -336,17 +429,15 @@ func TestWriteReadSeriesTest_Run(t *testing.T) {
 			# TYPE mimir_continuous_test_queries_total counter
 			mimir_continuous_test_queries_total{test="write-read-series"} 8
 
-			# HELP mimir_continuous_test_queries_failed_total Total number of failed query requests.
-			# TYPE mimir_continuous_test_queries_failed_total counter
-			mimir_continuous_test_queries_failed_total{test="write-read-series"} 0
-
 			# HELP mimir_continuous_test_query_result_checks_total Total number of query results checked for correctness.
 			# TYPE mimir_continuous_test_query_result_checks_total counter
-			mimir_continuous_test_query_result_checks_total{test="write-read-series"} 8
+			mimir_continuous_test_query_result_checks_total{query_type="instant",read_path="query_api",storage="default",test="write-read-series"} 4
+			mimir_continuous_test_query_result_checks_total{query_type="range",read_path="query_api",storage="default",test="write-read-series"} 4
 
 			# HELP mimir_continuous_test_query_result_checks_failed_total Total number of query results failed when checking for correctness.
 			# TYPE mimir_continuous_test_query_result_checks_failed_total counter
-			mimir_continuous_test_query_result_checks_failed_total{test="write-read-series"} 8
+			mimir_continuous_test_query_result_checks_failed_total{query_type="instant",read_path="query_api",storage="default",test="write-read-series"} 4
+			mimir_continuous_test_query_result_checks_failed_total{query_type="range",read_path="query_api",storage="default",test="write-read-series"} 4
 		`),
 			"mimir_continuous_test_writes_total", "mimir_continuous_test_writes_failed_total",
 			"mimir_continuous_test_queries_total", "mimir_continuous_test_queries_failed_total",
@@ -354,8 +445,665 @@ func TestWriteReadSeriesTest_Run(t *testing.T) {
 	})
 }
 
+func TestWriteReadSeriesTest_Run_RunInterval(t *testing.T) {
+	cfg := WriteReadSeriesTestConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.NumSeries = 2
+
+	test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
+	client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+	client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{}, nil)
+	client.On("Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Vector{}, nil)
+
+	// The wall clock is independent from the timestamp passed to Run, which we keep the same across runs
+	// to not write any sample after the first run.
+	now := time.Unix(10*86400, 0)
+	clock := now
+	test.timeNow = func() time.Time { return clock }
+
+	expectedMetrics := func(value float64) io.Reader {
+		return strings.NewReader(fmt.Sprintf(`
+			# HELP mimir_continuous_test_run_interval_seconds Wall time in seconds between the two most recent test runs.
+			# TYPE mimir_continuous_test_run_interval_seconds gauge
+			mimir_continuous_test_run_interval_seconds{test="write-read-series"} %g
+		`, value))
+	}
+
+	// Ignore errors. They will be non-nil because the query mock does not return any data.
+	_ = test.Run(context.Background(), now)
+	assert.NoError(t, testutil.GatherAndCompare(reg, expectedMetrics(0), "mimir_continuous_test_run_interval_seconds"))
+
+	clock = clock.Add(5 * time.Minute)
+	_ = test.Run(context.Background(), now)
+	assert.NoError(t, testutil.GatherAndCompare(reg, expectedMetrics(300), "mimir_continuous_test_run_interval_seconds"))
+
+	// The run has been delayed.
+	clock = clock.Add(7*time.Minute + 30*time.Second)
+	_ = test.Run(context.Background(), now)
+	assert.NoError(t, testutil.GatherAndCompare(reg, expectedMetrics(450), "mimir_continuous_test_run_interval_seconds"))
+}
+
+func TestWriteReadSeriesTest_Run_SkipsOverlappingRuns(t *testing.T) {
+	cfg := WriteReadSeriesTestConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.NumSeries = 2
+
+	// The write blocks until released, in order to keep the first run in progress.
+	writeStarted, releaseWrite := make(chan struct{}, 1), make(chan struct{})
+
+	test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
+	client.On("WriteSeries", mock.Anything, mock.Anything).Run(func(mock.Arguments) {
+		select {
+		case writeStarted <- struct{}{}:
+		default:
+		}
+		<-releaseWrite
+	}).Return(200, nil)
+	client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{}, nil)
+	client.On("Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Vector{}, nil)
+
+	expectedMetrics := func(value int) io.Reader {
+		return strings.NewReader(fmt.Sprintf(`
+			# HELP mimir_continuous_test_skipped_iterations_total Total number of runs skipped because the previous run was still in progress.
+			# TYPE mimir_continuous_test_skipped_iterations_total counter
+			mimir_continuous_test_skipped_iterations_total{test="write-read-series"} %d
+		`, value))
+	}
+
+	now := time.Unix(10*86400, 0)
+	firstRunDone := make(chan struct{})
+	go func() {
+		defer close(firstRunDone)
+		// Ignore the error. It will be non-nil because the query mock does not return any data.
+		_ = test.Run(context.Background(), now)
+	}()
+	<-writeStarted
+
+	// The run is skipped while the first one is in progress, without writing or querying.
+	require.ErrorIs(t, test.Run(context.Background(), now.Add(time.Minute)), errRunInProgress)
+	assert.NoError(t, testutil.GatherAndCompare(reg, expectedMetrics(1), "mimir_continuous_test_skipped_iterations_total"))
+	client.AssertNumberOfCalls(t, "WriteSeries", 1)
+
+	close(releaseWrite)
+	<-firstRunDone
+
+	// The next run is not skipped once the first one has finished.
+	err := test.Run(context.Background(), now.Add(time.Minute))
+	require.NotErrorIs(t, err, errRunInProgress)
+	assert.NoError(t, testutil.GatherAndCompare(reg, expectedMetrics(1), "mimir_continuous_test_skipped_iterations_total"))
+	assert.Equal(t, now.Add(time.Minute), test.lastWrittenTimestamp)
+}
+
+func TestWriteReadSeriesTest_Run_FailingQueriesDontTriggerWrites(t *testing.T) {
+	cfg := WriteReadSeriesTestConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.NumSeries = 2
+
+	test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+	client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+	client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix(nil), errors.New("network error"))
+	client.On("Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Vector(nil), errors.New("network error"))
+
+	// Queries keep failing across runs at the same time: once the samples for the current interval
+	// have been written, they should never be written again, no matter how many queries are re-run.
+	now := time.Unix(1000, 0)
+	for i := 0; i < 3; i++ {
+		assert.Error(t, test.Run(context.Background(), now))
+	}
+
+	client.AssertNumberOfCalls(t, "WriteSeries", 1)
+	assert.Equal(t, now, test.lastWrittenTimestamp)
+}
+
+func TestWriteReadSeriesTest_Run_WriteJitter(t *testing.T) {
+	t.Run("should fail if the write jitter is not lower than the write interval", func(t *testing.T) {
+		cfg := WriteReadSeriesTestConfig{}
+		flagext.DefaultValues(&cfg)
+		cfg.WriteJitter = cfg.WriteInterval
+
+		_, err := NewWriteReadSeriesTest(cfg, &ClientMock{}, log.NewNopLogger(), nil)
+		require.Error(t, err)
+	})
+
+	t.Run("should compute a stable offset per instance", func(t *testing.T) {
+		jitter := 15 * time.Second
+
+		assert.Zero(t, writeJitterOffset("instance-1", 0))
+		assert.Equal(t, writeJitterOffset("instance-1", jitter), writeJitterOffset("instance-1", jitter))
+		assert.NotEqual(t, writeJitterOffset("instance-1", jitter), writeJitterOffset("instance-2", jitter))
+
+		for _, instanceID := range []string{"", "instance-1", "instance-2", "instance-3"} {
+			offset := writeJitterOffset(instanceID, jitter)
+			assert.GreaterOrEqual(t, offset, time.Duration(0))
+			assert.Less(t, offset, jitter)
+		}
+	})
+
+	t.Run("should delay the write by the offset but keep the written timestamp aligned", func(t *testing.T) {
+		cfg := WriteReadSeriesTestConfig{}
+		flagext.DefaultValues(&cfg)
+		cfg.NumSeries = 2
+		cfg.WriteJitter = 15 * time.Second
+		cfg.InstanceID = "instance-1"
+
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+		client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{{Values: []model.SamplePair{{Timestamp: 980000, Value: model.SampleValue(generateSineWaveValue(time.Unix(980, 0)) * 2)}}}}, nil)
+		client.On("Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Vector{}, nil)
+
+		offset := writeJitterOffset(cfg.InstanceID, cfg.WriteJitter)
+		require.Greater(t, offset, time.Duration(0))
+		test.lastWrittenTimestamp = time.Unix(960, 0)
+
+		// The next interval timestamp has been reached, but the offset hasn't elapsed yet.
+		_ = test.Run(context.Background(), time.Unix(980, 0).Add(offset-time.Nanosecond))
+		client.AssertNumberOfCalls(t, "WriteSeries", 0)
+		assert.Equal(t, int64(960), test.lastWrittenTimestamp.Unix())
+
+		// The offset has elapsed.
+		_ = test.Run(context.Background(), time.Unix(980, 0).Add(offset))
+		client.AssertNumberOfCalls(t, "WriteSeries", 1)
+		assert.Equal(t, int64(980), test.lastWrittenTimestamp.Unix())
+		assert.Equal(t, int64(980000), client.Calls[0].Arguments.Get(1).([]prompb.TimeSeries)[0].Samples[0].Timestamp)
+	})
+}
+
+func TestWriteReadSeriesTest_Run_LeapSecond(t *testing.T) {
+	cfg := WriteReadSeriesTestConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.NumSeries = 2
+	cfg.WriteInterval = 7 * time.Second
+
+	test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+	client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+	client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{}, nil)
+	client.On("Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Vector{}, nil)
+
+	// A leap second has been inserted at 2016-12-31T23:59:60Z. Run the test straddling it, and
+	// check that the written samples timestamps are on the same evenly spaced grid.
+	leapSecond := time.Date(2017, time.January, 1, 0, 0, 0, 0, time.UTC)
+	for _, now := range []time.Time{
+		leapSecond.Add(-1500 * time.Millisecond),
+		leapSecond.Add(-time.Millisecond),
+		leapSecond,
+		leapSecond.Add(time.Millisecond),
+		leapSecond.Add(10 * time.Second),
+		leapSecond.Add(20 * time.Second),
+	} {
+		_ = test.Run(context.Background(), now)
+	}
+
+	var writtenTimestamps []int64
+	for _, call := range client.Calls {
+		if call.Method == "WriteSeries" {
+			writtenTimestamps = append(writtenTimestamps, call.Arguments.Get(1).([]prompb.TimeSeries)[0].Samples[0].Timestamp)
+		}
+	}
+
+	// 1483228800000 is the Unix timestamp of the leap second, in milliseconds.
+	assert.Equal(t, []int64{1483228796000, 1483228803000, 1483228810000, 1483228817000}, writtenTimestamps)
+}
+
+func TestWriteReadSeriesTest_Run_WithOutOfOrder(t *testing.T) {
+	cfg := WriteReadSeriesTestConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.NumSeries = 2
+	cfg.WithOutOfOrder = true
+	cfg.OOOWindow = 40 * time.Second
+
+	// Returns the timestamps of the written samples of the test metric, ignoring the out-of-order check probe.
+	writtenTimestamps := func(client *ClientMock) (timestamps []int64) {
+		for _, call := range client.Calls {
+			if call.Method != "WriteSeries" {
+				continue
+			}
+			if series := call.Arguments.Get(1).([]prompb.TimeSeries); series[0].Labels[0].Value == metricName {
+				timestamps = append(timestamps, series[0].Samples[0].Timestamp)
+			}
+		}
+		return timestamps
+	}
+
+	t.Run("should fail if the out-of-order window is lower than the write interval", func(t *testing.T) {
+		cfg := cfg
+		cfg.OOOWindow = cfg.WriteInterval - time.Second
+
+		_, err := NewWriteReadSeriesTest(cfg, &ClientMock{}, log.NewNopLogger(), nil)
+		require.Error(t, err)
+	})
+
+	t.Run("should write a timestamp after the following ones, and include it in the checked time range", func(t *testing.T) {
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+		client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{}, nil)
+		client.On("Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Vector{}, nil)
+
+		test.lastWrittenTimestamp = time.Unix(940, 0)
+		test.queryMinTime = time.Unix(900, 0)
+		test.queryMaxTime = time.Unix(940, 0)
+
+		_ = test.Run(context.Background(), time.Unix(1000, 0))
+
+		// The timestamp half of the out-of-order window before the last one is written last.
+		assert.Equal(t, []int64{960000, 1000000, 980000}, writtenTimestamps(client))
+		assert.Equal(t, time.Unix(1000, 0), test.lastWrittenTimestamp)
+		assert.Equal(t, time.Unix(900, 0), test.queryMinTime)
+		assert.Equal(t, time.Unix(1000, 0), test.queryMaxTime)
+	})
+
+	t.Run("should not write out-of-order if the run writes a single timestamp", func(t *testing.T) {
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+		client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{}, nil)
+		client.On("Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Vector{}, nil)
+
+		test.lastWrittenTimestamp = time.Unix(980, 0)
+
+		_ = test.Run(context.Background(), time.Unix(1000, 0))
+		assert.Equal(t, []int64{1000000}, writtenTimestamps(client))
+	})
+
+	t.Run("should reset the checked time range if the out-of-order write fails", func(t *testing.T) {
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("WriteSeries", mock.Anything, mock.MatchedBy(func(series []prompb.TimeSeries) bool {
+			return series[0].Samples[0].Timestamp == 980000
+		})).Return(500, errors.New("server error"))
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+
+		test.lastWrittenTimestamp = time.Unix(940, 0)
+		test.queryMinTime = time.Unix(900, 0)
+		test.queryMaxTime = time.Unix(940, 0)
+
+		assert.Error(t, test.Run(context.Background(), time.Unix(1000, 0)))
+		assert.Equal(t, []int64{960000, 1000000, 980000}, writtenTimestamps(client))
+		assert.Equal(t, time.Unix(1000, 0), test.lastWrittenTimestamp)
+		assert.True(t, test.queryMinTime.IsZero())
+		assert.True(t, test.queryMaxTime.IsZero())
+		client.AssertNotCalled(t, "QueryRange")
+	})
+}
+
+func TestWriteReadSeriesTest_Run_WriteRetries(t *testing.T) {
+	cfg := WriteReadSeriesTestConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.NumSeries = 2
+	cfg.WriteRetries = 2
+	cfg.WriteBackoff.MinBackoff = time.Millisecond
+	cfg.WriteBackoff.MaxBackoff = time.Millisecond
+
+	now := time.Unix(1000, 0)
+
+	tests := map[string]struct {
+		responses               []int
+		expectedWrites          int
+		expectedRetries         int
+		expectedLastWrittenTime time.Time
+	}{
+		"should not retry a successful write": {
+			responses:               []int{200},
+			expectedWrites:          1,
+			expectedLastWrittenTime: now,
+		},
+		"should retry a write failed because of a 5xx error": {
+			responses:               []int{500, 200},
+			expectedWrites:          2,
+			expectedRetries:         1,
+			expectedLastWrittenTime: now,
+		},
+		"should retry a write failed because of a network error": {
+			responses:               []int{0, 0, 200},
+			expectedWrites:          3,
+			expectedRetries:         2,
+			expectedLastWrittenTime: now,
+		},
+		"should not retry a write failed because of a 4xx error": {
+			responses:               []int{400},
+			expectedWrites:          1,
+			expectedLastWrittenTime: now,
+		},
+		"should retry a write failed because of a 429 error": {
+			responses:               []int{429, 200},
+			expectedWrites:          2,
+			expectedRetries:         1,
+			expectedLastWrittenTime: now,
+		},
+		"should keep writing after giving up retrying a write failed because of a 429 error": {
+			responses:               []int{429, 429, 429},
+			expectedWrites:          3,
+			expectedRetries:         2,
+			expectedLastWrittenTime: now,
+		},
+		"should give up after the configured number of retries": {
+			responses:       []int{500, 500, 500},
+			expectedWrites:  3,
+			expectedRetries: 2,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
+			for _, statusCode := range testData.responses {
+				var err error
+				if statusCode/100 != 2 {
+					err = errors.New("write failed")
+				}
+				client.On("WriteSeries", mock.Anything, mock.Anything).Return(statusCode, err).Once()
+			}
+			client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{}, nil)
+			client.On("Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Vector{}, nil)
+
+			// Ignore this error. It will be non-nil because the query mock does not return any data.
+			_ = test.Run(context.Background(), now)
+
+			client.AssertNumberOfCalls(t, "WriteSeries", testData.expectedWrites)
+			assert.Equal(t, testData.expectedLastWrittenTime, test.lastWrittenTimestamp)
+
+			// The writes are tracked once per interval, regardless of the retries.
+			assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(fmt.Sprintf(`
+				# HELP mimir_continuous_test_writes_total Total number of attempted write requests.
+				# TYPE mimir_continuous_test_writes_total counter
+				mimir_continuous_test_writes_total{test="write-read-series"} 1
+
+				# HELP mimir_continuous_test_write_retries_total Total number of retried write requests.
+				# TYPE mimir_continuous_test_write_retries_total counter
+				mimir_continuous_test_write_retries_total{test="write-read-series"} %d
+			`, testData.expectedRetries)), "mimir_continuous_test_writes_total", "mimir_continuous_test_write_retries_total"))
+		})
+	}
+}
+
+func TestWriteReadSeriesTest_Run_WriteErrorActions(t *testing.T) {
+	cfg := WriteReadSeriesTestConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.NumSeries = 2
+
+	now := time.Unix(1000, 0)
+
+	tests := map[string]struct {
+		writeErrorActions []string
+		statusCode        int
+		expectedWrites    int
+	}{
+		"should keep writing the next intervals on 400 error by default": {
+			statusCode:     400,
+			expectedWrites: 2,
+		},
+		"should keep writing the next intervals on 429 error by default": {
+			statusCode:     429,
+			expectedWrites: 2,
+		},
+		"should stop writing on 401 error by default": {
+			statusCode:     401,
+			expectedWrites: 1,
+		},
+		"should stop writing on 403 error by default": {
+			statusCode:     403,
+			expectedWrites: 1,
+		},
+		"should stop writing on 413 error by default": {
+			statusCode:     413,
+			expectedWrites: 1,
+		},
+		"should stop writing on 500 error by default": {
+			statusCode:     500,
+			expectedWrites: 1,
+		},
+		"should stop writing on a 4xx error if configured for the class": {
+			writeErrorActions: []string{"4xx=stop"},
+			statusCode:        400,
+			expectedWrites:    1,
+		},
+		"should apply the action configured for the exact status code over the class one": {
+			writeErrorActions: []string{"4xx=stop", "400=continue"},
+			statusCode:        400,
+			expectedWrites:    2,
+		},
+		"should keep writing on a 4xx error if no action is configured": {
+			writeErrorActions: []string{},
+			statusCode:        401,
+			expectedWrites:    2,
+		},
+		"should always stop writing on 415 error": {
+			writeErrorActions: []string{"4xx=continue", "415=continue"},
+			statusCode:        415,
+			expectedWrites:    1,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			cfg := cfg
+			if testData.writeErrorActions != nil {
+				cfg.WriteErrorActions = testData.writeErrorActions
+			}
+
+			test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+			client.On("WriteSeries", mock.Anything, mock.Anything).Return(testData.statusCode, errors.New("write failed"))
+
+			test.lastWrittenTimestamp = time.Unix(960, 0)
+
+			require.Error(t, test.Run(context.Background(), now))
+			client.AssertNumberOfCalls(t, "WriteSeries", testData.expectedWrites)
+		})
+	}
+
+	t.Run("should fail on invalid write error actions", func(t *testing.T) {
+		for _, actions := range [][]string{{"400"}, {"500=continue"}, {"5xx=stop"}, {"400=retry"}, {"400=stop", "400=continue"}} {
+			cfg := cfg
+			cfg.WriteErrorActions = actions
+
+			_, err := NewWriteReadSeriesTest(cfg, &ClientMock{}, log.NewNopLogger(), nil)
+			assert.Error(t, err, actions)
+		}
+	})
+}
+
+func TestWriteReadSeriesTest_Run_NonUTCLocalTimeZone(t *testing.T) {
+	// Run the testing tool in a time zone whose offset is not a multiple of the write interval.
+	originalLocal := time.Local
+	time.Local = time.FixedZone("UTC+05:30", 5*3600+1800)
+	t.Cleanup(func() { time.Local = originalLocal })
+
+	cfg := WriteReadSeriesTestConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.NumSeries = 2
+	cfg.WriteInterval = time.Hour
+
+	test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+	client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+	client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{}, nil)
+	client.On("Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Vector{}, nil)
+
+	// The current time is expressed in the local time zone, but samples must be written at timestamps
+	// aligned to the write interval in UTC.
+	now := time.Unix(10*3600+100, 0).In(time.Local)
+	expectedTs := time.Unix(10*3600, 0)
+
+	// Ignore this error. It will be non-nil because the query mock does not return any data.
+	_ = test.Run(context.Background(), now)
+
+	client.AssertNumberOfCalls(t, "WriteSeries", 1)
+	client.AssertCalled(t, "WriteSeries", mock.Anything, generateSineWaveSeries(metricName, expectedTs, 2))
+	assert.Equal(t, expectedTs.UnixMilli(), test.lastWrittenTimestamp.UnixMilli())
+	client.AssertCalled(t, "QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", mock.MatchedBy(func(start time.Time) bool {
+		return start.Equal(expectedTs)
+	}), mock.MatchedBy(func(end time.Time) bool {
+		return end.Equal(expectedTs)
+	}), time.Hour, mock.Anything)
+}
+
+func TestWriteReadSeriesTest_Run_MultipleEndpoints(t *testing.T) {
+	cfg := WriteReadSeriesTestConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.NumSeries = 2
+
+	failingClient := &ClientMock{}
+	failingClient.On("WriteSeries", mock.Anything, mock.Anything).Return(500, errors.New("server error"))
+
+	succeedingClient := &ClientMock{}
+	succeedingClient.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+	succeedingClient.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{}, nil)
+	succeedingClient.On("Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Vector{}, nil)
+
+	// Both tests are registered to the same registry, like when running the testing tool.
+	reg := prometheus.NewPedanticRegistry()
+	failingTest, err := NewWriteReadSeriesTestForEndpoint(cfg, "http://cluster-a", failingClient, log.NewNopLogger(), reg)
+	require.NoError(t, err)
+	succeedingTest, err := NewWriteReadSeriesTestForEndpoint(cfg, "http://cluster-b", succeedingClient, log.NewNopLogger(), reg)
+	require.NoError(t, err)
+
+	assert.Equal(t, "write-read-series-http://cluster-a", failingTest.Name())
+	assert.Equal(t, "write-read-series-http://cluster-b", succeedingTest.Name())
+
+	now := time.Unix(1000, 0)
+	assert.Error(t, failingTest.Run(context.Background(), now))
+	// Ignore this error. It will be non-nil because the query mock does not return any data.
+	_ = succeedingTest.Run(context.Background(), now)
+
+	failingClient.AssertNumberOfCalls(t, "WriteSeries", 1)
+	failingClient.AssertNotCalled(t, "QueryRange")
+	succeedingClient.AssertNumberOfCalls(t, "WriteSeries", 1)
+	succeedingClient.AssertCalled(t, "WriteSeries", mock.Anything, generateSineWaveSeries(metricName, now, 2))
+
+	assert.Equal(t, time.Time{}, failingTest.lastWrittenTimestamp)
+	assert.Equal(t, now, succeedingTest.lastWrittenTimestamp)
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP mimir_continuous_test_writes_total Total number of attempted write requests.
+		# TYPE mimir_continuous_test_writes_total counter
+		mimir_continuous_test_writes_total{test="write-read-series-http://cluster-a"} 1
+		mimir_continuous_test_writes_total{test="write-read-series-http://cluster-b"} 1
+
+		# HELP mimir_continuous_test_writes_failed_total Total number of failed write requests.
+		# TYPE mimir_continuous_test_writes_failed_total counter
+		mimir_continuous_test_writes_failed_total{status_code="500",test="write-read-series-http://cluster-a"} 1
+	`), "mimir_continuous_test_writes_total", "mimir_continuous_test_writes_failed_total"))
+}
+
+func TestWriteReadSeriesTest_Run_SecondaryBackend(t *testing.T) {
+	cfg := WriteReadSeriesTestConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.NumSeries = 2
+
+	now := time.Unix(1000, 0)
+	expectedValue := model.SampleValue(generateSineWaveValue(now) * 2)
+
+	primaryClient := &ClientMock{}
+	primaryClient.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+	primaryClient.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{{Values: []model.SamplePair{{Timestamp: model.Time(now.UnixMilli()), Value: expectedValue}}}}, nil)
+	primaryClient.On("Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Vector{{Timestamp: model.Time(now.UnixMilli()), Value: expectedValue}}, nil)
+
+	// The secondary backend diverges from the generated values.
+	secondaryClient := &ClientMock{}
+	secondaryClient.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+	secondaryClient.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{{Values: []model.SamplePair{{Timestamp: model.Time(now.UnixMilli()), Value: expectedValue + 1}}}}, nil)
+	secondaryClient.On("Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Vector{{Timestamp: model.Time(now.UnixMilli()), Value: expectedValue + 1}}, nil)
+
+	// Both tests are registered to the same registry, like when running the testing tool.
+	reg := prometheus.NewPedanticRegistry()
+	primaryTest, err := NewWriteReadSeriesTest(cfg, primaryClient, log.NewNopLogger(), reg)
+	require.NoError(t, err)
+	secondaryTest, err := NewWriteReadSeriesTestForSecondaryBackend(cfg, "", secondaryClient, log.NewNopLogger(), reg)
+	require.NoError(t, err)
+
+	assert.Equal(t, "write-read-series-secondary", secondaryTest.Name())
+
+	assert.NoError(t, primaryTest.Run(context.Background(), now))
+	assert.Error(t, secondaryTest.Run(context.Background(), now))
+
+	// The same series are written to both backends.
+	primaryClient.AssertCalled(t, "WriteSeries", mock.Anything, generateSineWaveSeries(metricName, now, 2))
+	secondaryClient.AssertCalled(t, "WriteSeries", mock.Anything, generateSineWaveSeries(metricName, now, 2))
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP mimir_continuous_test_query_result_checks_total Total number of query results checked for correctness.
+		# TYPE mimir_continuous_test_query_result_checks_total counter
+		mimir_continuous_test_query_result_checks_total{query_type="instant",read_path="query_api",storage="default",test="write-read-series"} 4
+		mimir_continuous_test_query_result_checks_total{query_type="range",read_path="query_api",storage="default",test="write-read-series"} 4
+		mimir_continuous_test_query_result_checks_total{query_type="instant",read_path="query_api",storage="default",test="write-read-series-secondary"} 4
+		mimir_continuous_test_query_result_checks_total{query_type="range",read_path="query_api",storage="default",test="write-read-series-secondary"} 4
+
+		# HELP mimir_continuous_test_query_result_checks_failed_total Total number of query results failed when checking for correctness.
+		# TYPE mimir_continuous_test_query_result_checks_failed_total counter
+		mimir_continuous_test_query_result_checks_failed_total{query_type="instant",read_path="query_api",storage="default",test="write-read-series"} 0
+		mimir_continuous_test_query_result_checks_failed_total{query_type="range",read_path="query_api",storage="default",test="write-read-series"} 0
+		mimir_continuous_test_query_result_checks_failed_total{query_type="instant",read_path="query_api",storage="default",test="write-read-series-secondary"} 4
+		mimir_continuous_test_query_result_checks_failed_total{query_type="range",read_path="query_api",storage="default",test="write-read-series-secondary"} 4
+	`), "mimir_continuous_test_query_result_checks_total", "mimir_continuous_test_query_result_checks_failed_total"))
+}
+
+func TestWriteReadSeriesTest_Run_SecondaryBackendWithMultipleTenants(t *testing.T) {
+	cfg := WriteReadSeriesTestConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.NumSeries = 2
+
+	client := &ClientMock{}
+	client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+	client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{}, nil)
+	client.On("Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Vector{}, nil)
+
+	// All tests are registered to the same registry, like when running the testing tool.
+	reg := prometheus.NewPedanticRegistry()
+	tenantTest, err := NewWriteReadSeriesTestForTenant(cfg, "tenant-1", client, log.NewNopLogger(), reg)
+	require.NoError(t, err)
+	secondaryTest, err := NewWriteReadSeriesTestForSecondaryBackend(cfg, "anonymous", client, log.NewNopLogger(), reg)
+	require.NoError(t, err)
+
+	now := time.Unix(1000, 0)
+	// Ignore these errors. They will be non-nil because the query mock does not return any data.
+	_ = tenantTest.Run(context.Background(), now)
+	_ = secondaryTest.Run(context.Background(), now)
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP mimir_continuous_test_writes_total Total number of attempted write requests.
+		# TYPE mimir_continuous_test_writes_total counter
+		mimir_continuous_test_writes_total{tenant="tenant-1",test="write-read-series"} 1
+		mimir_continuous_test_writes_total{tenant="anonymous",test="write-read-series-secondary"} 1
+	`), "mimir_continuous_test_writes_total"))
+}
+
+func TestWriteReadSeriesTest_Run_MultipleTenants(t *testing.T) {
+	cfg := WriteReadSeriesTestConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.NumSeries = 2
+
+	failingClient := &ClientMock{}
+	failingClient.On("WriteSeries", mock.Anything, mock.Anything).Return(500, errors.New("server error"))
+
+	succeedingClient := &ClientMock{}
+	succeedingClient.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+	succeedingClient.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{}, nil)
+	succeedingClient.On("Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Vector{}, nil)
+
+	// Both tests are registered to the same registry, like when running the testing tool.
+	reg := prometheus.NewPedanticRegistry()
+	failingTest, err := NewWriteReadSeriesTestForTenant(cfg, "tenant-1", failingClient, log.NewNopLogger(), reg)
+	require.NoError(t, err)
+	succeedingTest, err := NewWriteReadSeriesTestForTenant(cfg, "tenant-2", succeedingClient, log.NewNopLogger(), reg)
+	require.NoError(t, err)
+
+	now := time.Unix(1000, 0)
+	assert.Error(t, failingTest.Run(context.Background(), now))
+	// Ignore this error. It will be non-nil because the query mock does not return any data.
+	_ = succeedingTest.Run(context.Background(), now)
+
+	assert.Equal(t, time.Time{}, failingTest.lastWrittenTimestamp)
+	assert.Equal(t, now, succeedingTest.lastWrittenTimestamp)
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP mimir_continuous_test_writes_total Total number of attempted write requests.
+		# TYPE mimir_continuous_test_writes_total counter
+		mimir_continuous_test_writes_total{tenant="tenant-1",test="write-read-series"} 1
+		mimir_continuous_test_writes_total{tenant="tenant-2",test="write-read-series"} 1
+
+		# HELP mimir_continuous_test_writes_failed_total Total number of failed write requests.
+		# TYPE mimir_continuous_test_writes_failed_total counter
+		mimir_continuous_test_writes_failed_total{status_code="500",tenant="tenant-1",test="write-read-series"} 1
+	`), "mimir_continuous_test_writes_total", "mimir_continuous_test_writes_failed_total"))
+}
+
 func TestWriteReadSeriesTest_Init(t *testing.T) {
-	logger := log.NewNopLogger()
 	cfg := WriteReadSeriesTestConfig{}
 	flagext.DefaultValues(&cfg)
 	cfg.NumSeries = 2
@@ -364,10 +1112,8 @@ func TestWriteReadSeriesTest_Init(t *testing.T) {
 	now := time.Unix(10*86400, 0)
 
 	t.Run("no previously written samples found", func(t *testing.T) {
-		client := &ClientMock{}
-		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-24*time.Hour).Add(writeInterval), now, writeInterval, mock.Anything).Return(model.Matrix{}, nil)
-
-		test := NewWriteReadSeriesTest(cfg, client, logger, nil)
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-24*time.Hour).Add(defaultWriteInterval), now, defaultWriteInterval, mock.Anything).Return(model.Matrix{}, nil)
 
 		require.NoError(t, test.Init(context.Background(), now))
 
@@ -379,13 +1125,11 @@ func TestWriteReadSeriesTest_Init(t *testing.T) {
 	})
 
 	t.Run("previously written data points are in the range [-2h, -1m]", func(t *testing.T) {
-		client := &ClientMock{}
-		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-24*time.Hour).Add(writeInterval), now, writeInterval, mock.Anything).Return(model.Matrix{{
-			Values: generateSineWaveSamplesSum(now.Add(-2*time.Hour), now.Add(-1*time.Minute), cfg.NumSeries, writeInterval),
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-24*time.Hour).Add(defaultWriteInterval), now, defaultWriteInterval, mock.Anything).Return(model.Matrix{{
+			Values: generateSineWaveSamplesSum(now.Add(-2*time.Hour), now.Add(-1*time.Minute), cfg.NumSeries, defaultWriteInterval),
 		}}, nil)
 
-		test := NewWriteReadSeriesTest(cfg, client, logger, nil)
-
 		require.NoError(t, test.Init(context.Background(), now))
 
 		client.AssertNumberOfCalls(t, "QueryRange", 1)
@@ -396,16 +1140,14 @@ func TestWriteReadSeriesTest_Init(t *testing.T) {
 	})
 
 	t.Run("previously written data points are in the range [-36h, -1m]", func(t *testing.T) {
-		client := &ClientMock{}
-		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-24*time.Hour).Add(writeInterval), now, writeInterval, mock.Anything).Return(model.Matrix{{
-			Values: generateSineWaveSamplesSum(now.Add(-24*time.Hour).Add(writeInterval), now.Add(-1*time.Minute), cfg.NumSeries, writeInterval),
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-24*time.Hour).Add(defaultWriteInterval), now, defaultWriteInterval, mock.Anything).Return(model.Matrix{{
+			Values: generateSineWaveSamplesSum(now.Add(-24*time.Hour).Add(defaultWriteInterval), now.Add(-1*time.Minute), cfg.NumSeries, defaultWriteInterval),
 		}}, nil)
-		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-48*time.Hour).Add(writeInterval), now.Add(-24*time.Hour), writeInterval, mock.Anything).Return(model.Matrix{{
-			Values: generateSineWaveSamplesSum(now.Add(-36*time.Hour), now.Add(-24*time.Hour), cfg.NumSeries, writeInterval),
+		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-48*time.Hour).Add(defaultWriteInterval), now.Add(-24*time.Hour), defaultWriteInterval, mock.Anything).Return(model.Matrix{{
+			Values: generateSineWaveSamplesSum(now.Add(-36*time.Hour), now.Add(-24*time.Hour), cfg.NumSeries, defaultWriteInterval),
 		}}, nil)
 
-		test := NewWriteReadSeriesTest(cfg, client, logger, nil)
-
 		require.NoError(t, test.Init(context.Background(), now))
 
 		client.AssertNumberOfCalls(t, "QueryRange", 2)
@@ -415,77 +1157,96 @@ func TestWriteReadSeriesTest_Init(t *testing.T) {
 		require.Equal(t, now.Add(-1*time.Minute), test.queryMaxTime)
 	})
 
+	t.Run("calling Init multiple times recovers the same time range", func(t *testing.T) {
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-24*time.Hour).Add(defaultWriteInterval), now, defaultWriteInterval, mock.Anything).Return(model.Matrix{{
+			Values: generateSineWaveSamplesSum(now.Add(-24*time.Hour).Add(defaultWriteInterval), now.Add(-1*time.Minute), cfg.NumSeries, defaultWriteInterval),
+		}}, nil)
+		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-48*time.Hour).Add(defaultWriteInterval), now.Add(-24*time.Hour), defaultWriteInterval, mock.Anything).Return(model.Matrix{{
+			Values: generateSineWaveSamplesSum(now.Add(-36*time.Hour), now.Add(-24*time.Hour), cfg.NumSeries, defaultWriteInterval),
+		}}, nil)
+
+		require.NoError(t, test.Init(context.Background(), now))
+		client.AssertNumberOfCalls(t, "QueryRange", 2)
+
+		lastWrittenTimestamp, queryMinTime, queryMaxTime := test.lastWrittenTimestamp, test.queryMinTime, test.queryMaxTime
+
+		// Each call is expected to run the same scan and recover the same time range.
+		require.NoError(t, test.Init(context.Background(), now))
+		client.AssertNumberOfCalls(t, "QueryRange", 4)
+
+		require.Equal(t, lastWrittenTimestamp, test.lastWrittenTimestamp)
+		require.Equal(t, queryMinTime, test.queryMinTime)
+		require.Equal(t, queryMaxTime, test.queryMaxTime)
+		require.Equal(t, now.Add(-1*time.Minute), test.lastWrittenTimestamp)
+		require.Equal(t, now.Add(-36*time.Hour), test.queryMinTime)
+		require.Equal(t, now.Add(-1*time.Minute), test.queryMaxTime)
+	})
+
 	t.Run("previously written data points are in the range [-36h, -1m] but last data point of previous 24h period is missing", func(t *testing.T) {
-		client := &ClientMock{}
-		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-24*time.Hour).Add(writeInterval), now, writeInterval, mock.Anything).Return(model.Matrix{{
-			Values: generateSineWaveSamplesSum(now.Add(-24*time.Hour).Add(writeInterval), now.Add(-1*time.Minute), cfg.NumSeries, writeInterval),
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-24*time.Hour).Add(defaultWriteInterval), now, defaultWriteInterval, mock.Anything).Return(model.Matrix{{
+			Values: generateSineWaveSamplesSum(now.Add(-24*time.Hour).Add(defaultWriteInterval), now.Add(-1*time.Minute), cfg.NumSeries, defaultWriteInterval),
 		}}, nil)
-		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-48*time.Hour).Add(writeInterval), now.Add(-24*time.Hour), writeInterval, mock.Anything).Return(model.Matrix{{
+		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-48*time.Hour).Add(defaultWriteInterval), now.Add(-24*time.Hour), defaultWriteInterval, mock.Anything).Return(model.Matrix{{
 			// Last data point is missing.
-			Values: generateSineWaveSamplesSum(now.Add(-36*time.Hour), now.Add(-24*time.Hour).Add(-writeInterval), cfg.NumSeries, writeInterval),
+			Values: generateSineWaveSamplesSum(now.Add(-36*time.Hour), now.Add(-24*time.Hour).Add(-defaultWriteInterval), cfg.NumSeries, defaultWriteInterval),
 		}}, nil)
 
-		test := NewWriteReadSeriesTest(cfg, client, logger, nil)
-
 		require.NoError(t, test.Init(context.Background(), now))
 
 		client.AssertNumberOfCalls(t, "QueryRange", 2)
 
 		require.Equal(t, now.Add(-1*time.Minute), test.lastWrittenTimestamp)
-		require.Equal(t, now.Add(-24*time.Hour).Add(writeInterval), test.queryMinTime)
+		require.Equal(t, now.Add(-24*time.Hour).Add(defaultWriteInterval), test.queryMinTime)
 		require.Equal(t, now.Add(-1*time.Minute), test.queryMaxTime)
 	})
 
 	t.Run("previously written data points are in the range [-24h, -1m]", func(t *testing.T) {
-		client := &ClientMock{}
-		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-24*time.Hour).Add(writeInterval), now, writeInterval, mock.Anything).Return(model.Matrix{{
-			Values: generateSineWaveSamplesSum(now.Add(-24*time.Hour).Add(writeInterval), now.Add(-1*time.Minute), cfg.NumSeries, writeInterval),
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-24*time.Hour).Add(defaultWriteInterval), now, defaultWriteInterval, mock.Anything).Return(model.Matrix{{
+			Values: generateSineWaveSamplesSum(now.Add(-24*time.Hour).Add(defaultWriteInterval), now.Add(-1*time.Minute), cfg.NumSeries, defaultWriteInterval),
 		}}, nil)
-		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-48*time.Hour).Add(writeInterval), now.Add(-24*time.Hour), writeInterval, mock.Anything).Return(model.Matrix{{}}, nil)
-
-		test := NewWriteReadSeriesTest(cfg, client, logger, nil)
+		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-48*time.Hour).Add(defaultWriteInterval), now.Add(-24*time.Hour), defaultWriteInterval, mock.Anything).Return(model.Matrix{{}}, nil)
 
 		require.NoError(t, test.Init(context.Background(), now))
 
 		client.AssertNumberOfCalls(t, "QueryRange", 2)
 
 		require.Equal(t, now.Add(-1*time.Minute), test.lastWrittenTimestamp)
-		require.Equal(t, now.Add(-24*time.Hour).Add(writeInterval), test.queryMinTime)
+		require.Equal(t, now.Add(-24*time.Hour).Add(defaultWriteInterval), test.queryMinTime)
 		require.Equal(t, now.Add(-1*time.Minute), test.queryMaxTime)
 	})
 
 	t.Run("the configured query max age is > 24h", func(t *testing.T) {
-		client := &ClientMock{}
-		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-24*time.Hour).Add(writeInterval), now, writeInterval, mock.Anything).Return(model.Matrix{{
-			Values: generateSineWaveSamplesSum(now.Add(-24*time.Hour).Add(writeInterval), now.Add(-1*time.Minute), cfg.NumSeries, writeInterval),
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-24*time.Hour).Add(defaultWriteInterval), now, defaultWriteInterval, mock.Anything).Return(model.Matrix{{
+			Values: generateSineWaveSamplesSum(now.Add(-24*time.Hour).Add(defaultWriteInterval), now.Add(-1*time.Minute), cfg.NumSeries, defaultWriteInterval),
 		}}, nil)
-		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-48*time.Hour).Add(writeInterval), now.Add(-24*time.Hour), writeInterval, mock.Anything).Return(model.Matrix{{
-			Values: generateSineWaveSamplesSum(now.Add(-48*time.Hour).Add(writeInterval), now.Add(-24*time.Hour), cfg.NumSeries, writeInterval),
+		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-48*time.Hour).Add(defaultWriteInterval), now.Add(-24*time.Hour), defaultWriteInterval, mock.Anything).Return(model.Matrix{{
+			Values: generateSineWaveSamplesSum(now.Add(-48*time.Hour).Add(defaultWriteInterval), now.Add(-24*time.Hour), cfg.NumSeries, defaultWriteInterval),
 		}}, nil)
-		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-72*time.Hour).Add(writeInterval), now.Add(-48*time.Hour), writeInterval, mock.Anything).Return(model.Matrix{{
-			Values: generateSineWaveSamplesSum(now.Add(-72*time.Hour).Add(writeInterval), now.Add(-48*time.Hour), cfg.NumSeries, writeInterval),
+		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-72*time.Hour).Add(defaultWriteInterval), now.Add(-48*time.Hour), defaultWriteInterval, mock.Anything).Return(model.Matrix{{
+			Values: generateSineWaveSamplesSum(now.Add(-72*time.Hour).Add(defaultWriteInterval), now.Add(-48*time.Hour), cfg.NumSeries, defaultWriteInterval),
 		}}, nil)
 
-		test := NewWriteReadSeriesTest(cfg, client, logger, nil)
-
 		require.NoError(t, test.Init(context.Background(), now))
 
 		client.AssertNumberOfCalls(t, "QueryRange", 3)
 
 		require.Equal(t, now.Add(-1*time.Minute), test.lastWrittenTimestamp)
-		require.Equal(t, now.Add(-72*time.Hour).Add(writeInterval), test.queryMinTime)
+		require.Equal(t, now.Add(-72*time.Hour).Add(defaultWriteInterval), test.queryMinTime)
 		require.Equal(t, now.Add(-1*time.Minute), test.queryMaxTime)
 	})
 
 	t.Run("the configured query max age is < 24h", func(t *testing.T) {
-		client := &ClientMock{}
-		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-2*time.Hour), now, writeInterval, mock.Anything).Return(model.Matrix{{
-			Values: generateSineWaveSamplesSum(now.Add(-2*time.Hour), now.Add(-1*time.Minute), cfg.NumSeries, writeInterval),
-		}}, nil)
-
 		testCfg := cfg
 		testCfg.MaxQueryAge = 2 * time.Hour
-		test := NewWriteReadSeriesTest(testCfg, client, logger, nil)
+
+		test, client, _ := newMockedWriteReadSeriesTest(t, testCfg)
+		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-2*time.Hour), now, defaultWriteInterval, mock.Anything).Return(model.Matrix{{
+			Values: generateSineWaveSamplesSum(now.Add(-2*time.Hour), now.Add(-1*time.Minute), cfg.NumSeries, defaultWriteInterval),
+		}}, nil)
 
 		require.NoError(t, test.Init(context.Background(), now))
 
@@ -497,13 +1258,11 @@ func TestWriteReadSeriesTest_Init(t *testing.T) {
 	})
 
 	t.Run("the most recent previously written data point is older than 1h ago", func(t *testing.T) {
-		client := &ClientMock{}
-		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-24*time.Hour).Add(writeInterval), now, writeInterval, mock.Anything).Return(model.Matrix{{
-			Values: generateSineWaveSamplesSum(now.Add(-2*time.Hour).Add(writeInterval), now.Add(-1*time.Hour), cfg.NumSeries, writeInterval),
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-24*time.Hour).Add(defaultWriteInterval), now, defaultWriteInterval, mock.Anything).Return(model.Matrix{{
+			Values: generateSineWaveSamplesSum(now.Add(-2*time.Hour).Add(defaultWriteInterval), now.Add(-1*time.Hour), cfg.NumSeries, defaultWriteInterval),
 		}}, nil)
 
-		test := NewWriteReadSeriesTest(cfg, client, logger, nil)
-
 		require.NoError(t, test.Init(context.Background(), now))
 
 		client.AssertNumberOfCalls(t, "QueryRange", 1)
@@ -514,10 +1273,8 @@ func TestWriteReadSeriesTest_Init(t *testing.T) {
 	})
 
 	t.Run("the first query fails", func(t *testing.T) {
-		client := &ClientMock{}
-		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-24*time.Hour).Add(writeInterval), now, writeInterval, mock.Anything).Return(model.Matrix{}, errors.New("failed"))
-
-		test := NewWriteReadSeriesTest(cfg, client, logger, nil)
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-24*time.Hour).Add(defaultWriteInterval), now, defaultWriteInterval, mock.Anything).Return(model.Matrix{}, errors.New("failed"))
 
 		require.NoError(t, test.Init(context.Background(), now))
 
@@ -529,116 +1286,441 @@ func TestWriteReadSeriesTest_Init(t *testing.T) {
 	})
 
 	t.Run("a subsequent query fails", func(t *testing.T) {
-		client := &ClientMock{}
-		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-24*time.Hour).Add(writeInterval), now, writeInterval, mock.Anything).Return(model.Matrix{{
-			Values: generateSineWaveSamplesSum(now.Add(-24*time.Hour).Add(writeInterval), now.Add(-1*time.Minute), cfg.NumSeries, writeInterval),
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-24*time.Hour).Add(defaultWriteInterval), now, defaultWriteInterval, mock.Anything).Return(model.Matrix{{
+			Values: generateSineWaveSamplesSum(now.Add(-24*time.Hour).Add(defaultWriteInterval), now.Add(-1*time.Minute), cfg.NumSeries, defaultWriteInterval),
 		}}, nil)
-		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-48*time.Hour).Add(writeInterval), now.Add(-24*time.Hour), writeInterval, mock.Anything).Return(model.Matrix{{}}, errors.New("failed"))
-
-		test := NewWriteReadSeriesTest(cfg, client, logger, nil)
+		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-48*time.Hour).Add(defaultWriteInterval), now.Add(-24*time.Hour), defaultWriteInterval, mock.Anything).Return(model.Matrix{{}}, errors.New("failed"))
 
 		require.NoError(t, test.Init(context.Background(), now))
 
 		client.AssertNumberOfCalls(t, "QueryRange", 2)
 
 		require.Equal(t, now.Add(-1*time.Minute), test.lastWrittenTimestamp)
-		require.Equal(t, now.Add(-24*time.Hour).Add(writeInterval), test.queryMinTime)
+		require.Equal(t, now.Add(-24*time.Hour).Add(defaultWriteInterval), test.queryMinTime)
 		require.Equal(t, now.Add(-1*time.Minute), test.queryMaxTime)
 	})
 
 	t.Run("the testing tool has been restarted with a different number of series in the middle of the last 24h period", func(t *testing.T) {
-		client := &ClientMock{}
-		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-24*time.Hour).Add(writeInterval), now, writeInterval, mock.Anything).Return(model.Matrix{{
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-24*time.Hour).Add(defaultWriteInterval), now, defaultWriteInterval, mock.Anything).Return(model.Matrix{{
 			Values: append(
-				generateSineWaveSamplesSum(now.Add(-24*time.Hour).Add(writeInterval), now.Add(-67*time.Minute), cfg.NumSeries-1, writeInterval),
-				generateSineWaveSamplesSum(now.Add(-67*time.Minute).Add(writeInterval), now.Add(-1*time.Minute), cfg.NumSeries, writeInterval)...,
+				generateSineWaveSamplesSum(now.Add(-24*time.Hour).Add(defaultWriteInterval), now.Add(-67*time.Minute), cfg.NumSeries-1, defaultWriteInterval),
+				generateSineWaveSamplesSum(now.Add(-67*time.Minute).Add(defaultWriteInterval), now.Add(-1*time.Minute), cfg.NumSeries, defaultWriteInterval)...,
 			),
 		}}, nil)
 
-		test := NewWriteReadSeriesTest(cfg, client, logger, nil)
-
 		require.NoError(t, test.Init(context.Background(), now))
 
 		client.AssertNumberOfCalls(t, "QueryRange", 1)
 
 		require.Equal(t, now.Add(-1*time.Minute), test.lastWrittenTimestamp)
-		require.Equal(t, now.Add(-67*time.Minute).Add(writeInterval), test.queryMinTime)
+		require.Equal(t, now.Add(-67*time.Minute).Add(defaultWriteInterval), test.queryMinTime)
 		require.Equal(t, now.Add(-1*time.Minute), test.queryMaxTime)
 	})
 
 	t.Run("the testing tool has been restarted with a different number of series in the middle of the previous 24h period", func(t *testing.T) {
-		client := &ClientMock{}
-		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-24*time.Hour).Add(writeInterval), now, writeInterval, mock.Anything).Return(model.Matrix{{
-			Values: generateSineWaveSamplesSum(now.Add(-24*time.Hour).Add(writeInterval), now.Add(-1*time.Minute), cfg.NumSeries, writeInterval),
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-24*time.Hour).Add(defaultWriteInterval), now, defaultWriteInterval, mock.Anything).Return(model.Matrix{{
+			Values: generateSineWaveSamplesSum(now.Add(-24*time.Hour).Add(defaultWriteInterval), now.Add(-1*time.Minute), cfg.NumSeries, defaultWriteInterval),
 		}}, nil)
-		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-48*time.Hour).Add(writeInterval), now.Add(-24*time.Hour), writeInterval, mock.Anything).Return(model.Matrix{{
+		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-48*time.Hour).Add(defaultWriteInterval), now.Add(-24*time.Hour), defaultWriteInterval, mock.Anything).Return(model.Matrix{{
 			Values: append(
-				generateSineWaveSamplesSum(now.Add(-48*time.Hour).Add(writeInterval), now.Add(-36*time.Hour).Add(time.Minute), cfg.NumSeries-1, writeInterval),
-				generateSineWaveSamplesSum(now.Add(-36*time.Hour).Add(time.Minute+writeInterval), now.Add(-24*time.Hour), cfg.NumSeries, writeInterval)...,
+				generateSineWaveSamplesSum(now.Add(-48*time.Hour).Add(defaultWriteInterval), now.Add(-36*time.Hour).Add(time.Minute), cfg.NumSeries-1, defaultWriteInterval),
+				generateSineWaveSamplesSum(now.Add(-36*time.Hour).Add(time.Minute+defaultWriteInterval), now.Add(-24*time.Hour), cfg.NumSeries, defaultWriteInterval)...,
 			),
 		}}, nil)
 
-		test := NewWriteReadSeriesTest(cfg, client, logger, nil)
-
 		require.NoError(t, test.Init(context.Background(), now))
 
 		client.AssertNumberOfCalls(t, "QueryRange", 2)
 
 		require.Equal(t, now.Add(-1*time.Minute), test.lastWrittenTimestamp)
-		require.Equal(t, now.Add(-36*time.Hour).Add(time.Minute+writeInterval), test.queryMinTime)
+		require.Equal(t, now.Add(-36*time.Hour).Add(time.Minute+defaultWriteInterval), test.queryMinTime)
 		require.Equal(t, now.Add(-1*time.Minute), test.queryMaxTime)
 	})
 
 	t.Run("the testing tool has been restarted with a different number of series exactly at the beginning of this 24h period", func(t *testing.T) {
-		client := &ClientMock{}
-		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-24*time.Hour).Add(writeInterval), now, writeInterval, mock.Anything).Return(model.Matrix{{
-			Values: generateSineWaveSamplesSum(now.Add(-24*time.Hour).Add(writeInterval), now.Add(-1*time.Minute), cfg.NumSeries, writeInterval),
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-24*time.Hour).Add(defaultWriteInterval), now, defaultWriteInterval, mock.Anything).Return(model.Matrix{{
+			Values: generateSineWaveSamplesSum(now.Add(-24*time.Hour).Add(defaultWriteInterval), now.Add(-1*time.Minute), cfg.NumSeries, defaultWriteInterval),
 		}}, nil)
-		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-48*time.Hour).Add(writeInterval), now.Add(-24*time.Hour), writeInterval, mock.Anything).Return(model.Matrix{{
-			Values: generateSineWaveSamplesSum(now.Add(-24*time.Hour).Add(writeInterval), now.Add(-1*time.Minute), cfg.NumSeries-1, writeInterval),
+		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-48*time.Hour).Add(defaultWriteInterval), now.Add(-24*time.Hour), defaultWriteInterval, mock.Anything).Return(model.Matrix{{
+			Values: generateSineWaveSamplesSum(now.Add(-24*time.Hour).Add(defaultWriteInterval), now.Add(-1*time.Minute), cfg.NumSeries-1, defaultWriteInterval),
 		}}, nil)
 
-		test := NewWriteReadSeriesTest(cfg, client, logger, nil)
-
 		require.NoError(t, test.Init(context.Background(), now))
 
 		client.AssertNumberOfCalls(t, "QueryRange", 2)
 
 		require.Equal(t, now.Add(-1*time.Minute), test.lastWrittenTimestamp)
-		require.Equal(t, now.Add(-24*time.Hour).Add(writeInterval), test.queryMinTime)
+		require.Equal(t, now.Add(-24*time.Hour).Add(defaultWriteInterval), test.queryMinTime)
 		require.Equal(t, now.Add(-1*time.Minute), test.queryMaxTime)
 	})
 }
 
-func TestWriteReadSeriesTest_getRangeQueryTimeRanges(t *testing.T) {
+func TestWriteReadSeriesTest_SeriesPhaseOffsets(t *testing.T) {
 	cfg := WriteReadSeriesTestConfig{}
 	flagext.DefaultValues(&cfg)
-	cfg.MaxQueryAge = 2 * 24 * time.Hour
+	cfg.NumSeries = 3
+	cfg.MaxQueryAge = 3 * 24 * time.Hour
+	cfg.SeriesPhaseOffsetsEnabled = true
 
-	now := time.Unix(int64((10*24*time.Hour)+(2*time.Second)), 0)
+	now := time.Unix(10*86400, 0)
 
-	t.Run("min/max query time has not been set yet", func(t *testing.T) {
-		test := NewWriteReadSeriesTest(cfg, &ClientMock{}, log.NewNopLogger(), nil)
+	// The samples sum, as returned by the query sum(), of the series written between from and to.
+	samplesSum := func(from, to time.Time) model.Matrix {
+		var samples []model.SamplePair
+		for ts := from; !ts.After(to); ts = ts.Add(defaultWriteInterval) {
+			sum := 0.0
+			for _, s := range generateSineWaveSeriesWithPhaseOffsets(metricName, ts, cfg.NumSeries, 0) {
+				sum += s.Samples[0].Value
+			}
+			samples = append(samples, newSamplePair(ts, sum))
+		}
+		return model.Matrix{{Values: samples}}
+	}
+
+	t.Run("should write each series with a distinct phase offset", func(t *testing.T) {
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
 
-		actualRanges, actualInstants, err := test.getQueryTimeRanges(now)
-		assert.Error(t, err)
-		assert.Empty(t, actualRanges)
-		assert.Empty(t, actualInstants)
+		require.NoError(t, test.writeSamples(context.Background(), []time.Time{now}))
+		client.AssertCalled(t, "WriteSeries", mock.Anything, generateSineWaveSeriesWithPhaseOffsets(metricName, now, cfg.NumSeries, 0))
 	})
 
-	t.Run("min/max query time is older than max age", func(t *testing.T) {
-		test := NewWriteReadSeriesTest(cfg, &ClientMock{}, log.NewNopLogger(), nil)
-		test.queryMinTime = now.Add(-cfg.MaxQueryAge).Add(-time.Minute)
-		test.queryMaxTime = now.Add(-cfg.MaxQueryAge).Add(-time.Minute)
+	t.Run("should verify the query results against the sum of the per-series values", func(t *testing.T) {
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("QueryRange", mock.Anything, mock.Anything, now.Add(-2*time.Minute), now, defaultWriteInterval, mock.Anything).Return(samplesSum(now.Add(-2*time.Minute), now), nil)
 
-		actualRanges, actualInstants, err := test.getQueryTimeRanges(now)
-		assert.Error(t, err)
-		assert.Empty(t, actualRanges)
-		assert.Empty(t, actualInstants)
+		test.lastWrittenTimestamp = now
+		test.queryMinTime = now.Add(-2 * time.Minute)
+		test.queryMaxTime = now
+
+		require.NoError(t, test.runRangeQueryAndVerifyResult(context.Background(), now.Add(-2*time.Minute), now, false))
+
+		// The result of series all having the same phase doesn't match.
+		samePhaseClient := &ClientMock{}
+		samePhaseClient.On("QueryRange", mock.Anything, mock.Anything, now.Add(-2*time.Minute), now, defaultWriteInterval, mock.Anything).Return(model.Matrix{{Values: generateSineWaveSamplesSum(now.Add(-2*time.Minute), now, cfg.NumSeries, defaultWriteInterval)}}, nil)
+		test.client = samePhaseClient
+		require.Error(t, test.runRangeQueryAndVerifyResult(context.Background(), now.Add(-2*time.Minute), now, false))
+	})
+
+	t.Run("should find the previously written samples on init", func(t *testing.T) {
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-24*time.Hour).Add(defaultWriteInterval), now, defaultWriteInterval, mock.Anything).Return(samplesSum(now.Add(-2*time.Hour), now.Add(-time.Minute)), nil)
+
+		require.NoError(t, test.Init(context.Background(), now))
+		require.Equal(t, now.Add(-2*time.Hour), test.queryMinTime)
+		require.Equal(t, now.Add(-time.Minute), test.queryMaxTime)
+	})
+}
+
+func TestWriteReadSeriesTest_ValueRounding(t *testing.T) {
+	cfg := WriteReadSeriesTestConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.NumSeries = 2
+	cfg.MaxQueryAge = 3 * 24 * time.Hour
+	cfg.ValueRounding = 3
+
+	now := time.Unix(10*86400, 0)
+	roundedSamplesSum := func(from, to time.Time) model.Matrix {
+		var samples []model.SamplePair
+		for ts := from; !ts.After(to); ts = ts.Add(defaultWriteInterval) {
+			samples = append(samples, newSamplePair(ts, float64(cfg.NumSeries)*roundToSignificantDigits(generateSineWaveValue(ts), cfg.ValueRounding)))
+		}
+		return model.Matrix{{Values: samples}}
+	}
+
+	t.Run("should write the rounded values", func(t *testing.T) {
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+
+		ts := now.Add(defaultWriteInterval)
+		require.NoError(t, test.writeSamples(context.Background(), []time.Time{ts}))
+		client.AssertCalled(t, "WriteSeries", mock.Anything, roundSeriesValues(generateSineWaveSeries(metricName, ts, cfg.NumSeries), cfg.ValueRounding))
+	})
+
+	t.Run("should find the previously written rounded samples on init", func(t *testing.T) {
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-24*time.Hour).Add(defaultWriteInterval), now, defaultWriteInterval, mock.Anything).Return(roundedSamplesSum(now.Add(-2*time.Hour), now.Add(-time.Minute)), nil)
+
+		require.NoError(t, test.Init(context.Background(), now))
+		require.Equal(t, now.Add(-2*time.Hour), test.queryMinTime)
+		require.Equal(t, now.Add(-time.Minute), test.queryMaxTime)
+	})
+
+	t.Run("should verify the query results against the rounded values", func(t *testing.T) {
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("QueryRange", mock.Anything, mock.Anything, now.Add(-2*time.Minute), now, defaultWriteInterval, mock.Anything).Return(roundedSamplesSum(now.Add(-2*time.Minute), now), nil)
+
+		test.cfg.ResultCheckTolerance = 0
+		test.lastWrittenTimestamp = now
+		test.queryMinTime = now.Add(-2 * time.Minute)
+		test.queryMaxTime = now
+
+		require.NoError(t, test.runRangeQueryAndVerifyResult(context.Background(), now.Add(-2*time.Minute), now, false))
+	})
+}
+
+func TestWriteReadSeriesTest_Init_Seed(t *testing.T) {
+	cfg := WriteReadSeriesTestConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.NumSeries = 2
+	cfg.MaxQueryAge = 3 * 24 * time.Hour
+	cfg.InitSeed = true
+
+	now := time.Unix(10*86400, 0)
+	initQuery := func(client *ClientMock, result model.Matrix) {
+		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", mock.Anything, now, defaultWriteInterval, mock.Anything).Return(result, nil).Once()
+	}
+
+	t.Run("should seed the samples up to the write max age and recover the same time range on the next init", func(t *testing.T) {
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		initQuery(client, model.Matrix{})
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+
+		require.NoError(t, test.Init(context.Background(), now))
+
+		// A write for each interval, through the same generator used by Run.
+		client.AssertNumberOfCalls(t, "WriteSeries", int(writeMaxAge/defaultWriteInterval)+1)
+		client.AssertCalled(t, "WriteSeries", mock.Anything, generateSineWaveSeries(metricName, now.Add(-writeMaxAge), 2))
+		client.AssertCalled(t, "WriteSeries", mock.Anything, generateSineWaveSeries(metricName, now, 2))
+		require.Equal(t, now, test.lastWrittenTimestamp)
+		require.Equal(t, now.Add(-writeMaxAge), test.queryMinTime)
+		require.Equal(t, now, test.queryMaxTime)
+
+		// The seeded samples are found by the next init, which recovers the same time range.
+		recovered, err := NewWriteReadSeriesTest(cfg, client, log.NewNopLogger(), nil)
+		require.NoError(t, err)
+		initQuery(client, model.Matrix{{Values: generateSineWaveSamplesSum(now.Add(-writeMaxAge), now, cfg.NumSeries, defaultWriteInterval)}})
+		require.NoError(t, recovered.Init(context.Background(), now))
+		client.AssertNumberOfCalls(t, "WriteSeries", int(writeMaxAge/defaultWriteInterval)+1)
+		require.Equal(t, test.lastWrittenTimestamp, recovered.lastWrittenTimestamp)
+		require.Equal(t, test.queryMinTime, recovered.queryMinTime)
+		require.Equal(t, test.queryMaxTime, recovered.queryMaxTime)
+	})
+
+	t.Run("should seed the samples up to the max query age within the out-of-order window", func(t *testing.T) {
+		cfg := cfg
+		cfg.MaxQueryAge = 90 * time.Minute
+		cfg.OOOWindow = 2 * time.Hour
+		cfg.MaxSamplesPerWrite = 60
+
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		initQuery(client, model.Matrix{})
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+
+		require.NoError(t, test.Init(context.Background(), now))
+
+		// The 271 intervals are written 30 at a time.
+		client.AssertNumberOfCalls(t, "WriteSeries", 10)
+		require.Equal(t, now, test.lastWrittenTimestamp)
+		require.Equal(t, now.Add(-90*time.Minute), test.queryMinTime)
+		require.Equal(t, now, test.queryMaxTime)
+	})
+
+	t.Run("should stop seeding on a failed write without failing the init", func(t *testing.T) {
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		initQuery(client, model.Matrix{})
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil).Times(3)
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(500, errors.New("failed"))
+
+		require.NoError(t, test.Init(context.Background(), now))
+
+		// The next run continues writing from the last seeded sample.
+		require.Equal(t, now.Add(-writeMaxAge).Add(2*defaultWriteInterval), test.lastWrittenTimestamp)
+		require.Equal(t, now.Add(-writeMaxAge), test.queryMinTime)
+		require.Equal(t, now.Add(-writeMaxAge).Add(2*defaultWriteInterval), test.queryMaxTime)
+	})
+
+	t.Run("should not seed the samples if previously written samples are found", func(t *testing.T) {
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		initQuery(client, model.Matrix{{Values: generateSineWaveSamplesSum(now.Add(-2*time.Hour), now.Add(-time.Minute), cfg.NumSeries, defaultWriteInterval)}})
+
+		require.NoError(t, test.Init(context.Background(), now))
+
+		client.AssertNotCalled(t, "WriteSeries", mock.Anything, mock.Anything)
+		require.Equal(t, now.Add(-2*time.Hour), test.queryMinTime)
+		require.Equal(t, now.Add(-time.Minute), test.queryMaxTime)
+	})
+}
+
+func TestWriteReadSeriesTest_Init_ExpectedVersion(t *testing.T) {
+	cfg := WriteReadSeriesTestConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.NumSeries = 2
+	cfg.ExpectedVersion = "2.7.0"
+
+	now := time.Unix(10*86400, 0)
+
+	tests := map[string]struct {
+		buildInfo      v1.BuildinfoResult
+		buildInfoErr   error
+		expectedMetric string
+	}{
+		"should not track the version if it matches the expected one": {
+			buildInfo: v1.BuildinfoResult{Version: "2.7.0"},
+		},
+		"should track the version if it differs from the expected one": {
+			buildInfo: v1.BuildinfoResult{Version: "2.8.0"},
+			expectedMetric: `
+				# HELP mimir_continuous_test_target_version_info Set to 1, with the version observed at startup, when the version of the target differs from the expected one.
+				# TYPE mimir_continuous_test_target_version_info gauge
+				mimir_continuous_test_target_version_info{expected_version="2.7.0",test="write-read-series",version="2.8.0"} 1
+			`,
+		},
+		"should not fail if the build info can't be fetched": {
+			buildInfoErr: errors.New("not found"),
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
+			client.On("BuildInfo", mock.Anything).Return(testData.buildInfo, testData.buildInfoErr)
+			client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{}, nil)
+
+			require.NoError(t, test.Init(context.Background(), now))
+			client.AssertNumberOfCalls(t, "BuildInfo", 1)
+			client.AssertNumberOfCalls(t, "QueryRange", 1)
+
+			assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(testData.expectedMetric), "mimir_continuous_test_target_version_info"))
+		})
+	}
+
+	t.Run("should not check the version if no expected version is configured", func(t *testing.T) {
+		cfg := cfg
+		cfg.ExpectedVersion = ""
+
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{}, nil)
+
+		require.NoError(t, test.Init(context.Background(), now))
+		client.AssertNotCalled(t, "BuildInfo", mock.Anything)
+	})
+}
+
+func TestWriteReadSeriesTest_Init_QueryRetries(t *testing.T) {
+	const query = "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))"
+
+	cfg := WriteReadSeriesTestConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.NumSeries = 2
+	cfg.MaxQueryAge = 3 * 24 * time.Hour
+	cfg.InitQueryRetries = 2
+	cfg.InitQueryBackoff.MinBackoff = time.Millisecond
+	cfg.InitQueryBackoff.MaxBackoff = time.Millisecond
+
+	now := time.Unix(10*86400, 0)
+	firstWindowStart, firstWindowEnd := now.Add(-24*time.Hour).Add(defaultWriteInterval), now
+	secondWindowStart, secondWindowEnd := now.Add(-48*time.Hour).Add(defaultWriteInterval), now.Add(-24*time.Hour)
+	firstWindowResult := model.Matrix{{Values: generateSineWaveSamplesSum(firstWindowStart, now.Add(-1*time.Minute), cfg.NumSeries, defaultWriteInterval)}}
+	secondWindowResult := model.Matrix{{Values: generateSineWaveSamplesSum(now.Add(-36*time.Hour), secondWindowEnd, cfg.NumSeries, defaultWriteInterval)}}
+	rateLimitedErr := &v1.Error{Type: v1.ErrClient, Msg: "client error: 429"}
+
+	t.Run("should retry the same window if the query is rate limited", func(t *testing.T) {
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("QueryRange", mock.Anything, query, firstWindowStart, firstWindowEnd, defaultWriteInterval, mock.Anything).Return(firstWindowResult, nil)
+		client.On("QueryRange", mock.Anything, query, secondWindowStart, secondWindowEnd, defaultWriteInterval, mock.Anything).Return(model.Matrix(nil), rateLimitedErr).Twice()
+		client.On("QueryRange", mock.Anything, query, secondWindowStart, secondWindowEnd, defaultWriteInterval, mock.Anything).Return(secondWindowResult, nil)
+
+		require.NoError(t, test.Init(context.Background(), now))
+		client.AssertNumberOfCalls(t, "QueryRange", 4)
+
+		require.Equal(t, now.Add(-1*time.Minute), test.lastWrittenTimestamp)
+		require.Equal(t, now.Add(-36*time.Hour), test.queryMinTime)
+		require.Equal(t, now.Add(-1*time.Minute), test.queryMaxTime)
+	})
+
+	t.Run("should stop walking back if the query is still rate limited after all retries", func(t *testing.T) {
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("QueryRange", mock.Anything, query, firstWindowStart, firstWindowEnd, defaultWriteInterval, mock.Anything).Return(firstWindowResult, nil)
+		client.On("QueryRange", mock.Anything, query, secondWindowStart, secondWindowEnd, defaultWriteInterval, mock.Anything).Return(model.Matrix(nil), rateLimitedErr)
+
+		require.NoError(t, test.Init(context.Background(), now))
+		client.AssertNumberOfCalls(t, "QueryRange", 1+1+cfg.InitQueryRetries)
+
+		// The time range found before the rate limited query is recovered.
+		require.Equal(t, now.Add(-1*time.Minute), test.lastWrittenTimestamp)
+		require.Equal(t, firstWindowStart, test.queryMinTime)
+		require.Equal(t, now.Add(-1*time.Minute), test.queryMaxTime)
+	})
+
+	t.Run("should stop walking back without retrying if the query fails for any other reason", func(t *testing.T) {
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("QueryRange", mock.Anything, query, firstWindowStart, firstWindowEnd, defaultWriteInterval, mock.Anything).Return(firstWindowResult, nil)
+		client.On("QueryRange", mock.Anything, query, secondWindowStart, secondWindowEnd, defaultWriteInterval, mock.Anything).Return(model.Matrix(nil), &v1.Error{Type: v1.ErrServer, Msg: "server error: 500"})
+
+		require.NoError(t, test.Init(context.Background(), now))
+		client.AssertNumberOfCalls(t, "QueryRange", 2)
+
+		require.Equal(t, firstWindowStart, test.queryMinTime)
+	})
+
+	t.Run("should not retry if the retries are disabled", func(t *testing.T) {
+		noRetriesCfg := cfg
+		noRetriesCfg.InitQueryRetries = 0
+
+		test, client, _ := newMockedWriteReadSeriesTest(t, noRetriesCfg)
+		client.On("QueryRange", mock.Anything, query, firstWindowStart, firstWindowEnd, defaultWriteInterval, mock.Anything).Return(model.Matrix(nil), rateLimitedErr)
+
+		require.NoError(t, test.Init(context.Background(), now))
+		client.AssertNumberOfCalls(t, "QueryRange", 1)
+
+		require.Zero(t, test.queryMinTime)
+		require.Zero(t, test.queryMaxTime)
+	})
+
+	t.Run("should wait the configured interval between consecutive windows", func(t *testing.T) {
+		pacedCfg := cfg
+		pacedCfg.InitQueryInterval = 50 * time.Millisecond
+
+		test, client, _ := newMockedWriteReadSeriesTest(t, pacedCfg)
+		client.On("QueryRange", mock.Anything, query, firstWindowStart, firstWindowEnd, defaultWriteInterval, mock.Anything).Return(firstWindowResult, nil)
+		client.On("QueryRange", mock.Anything, query, secondWindowStart, secondWindowEnd, defaultWriteInterval, mock.Anything).Return(secondWindowResult, nil)
+
+		start := time.Now()
+		require.NoError(t, test.Init(context.Background(), now))
+		assert.GreaterOrEqual(t, time.Since(start), pacedCfg.InitQueryInterval)
+		client.AssertNumberOfCalls(t, "QueryRange", 2)
+
+		require.Equal(t, now.Add(-36*time.Hour), test.queryMinTime)
+	})
+}
+
+func TestWriteReadSeriesTest_getRangeQueryTimeRanges(t *testing.T) {
+	cfg := WriteReadSeriesTestConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.MaxQueryAge = 2 * 24 * time.Hour
+
+	now := time.Unix(int64((10*24*time.Hour)+(2*time.Second)), 0)
+
+	t.Run("min/max query time has not been set yet", func(t *testing.T) {
+		test, err := NewWriteReadSeriesTest(cfg, &ClientMock{}, log.NewNopLogger(), nil)
+		require.NoError(t, err)
+
+		actualRanges, actualInstants, err := test.getQueryTimeRanges(now)
+		assert.Error(t, err)
+		assert.Empty(t, actualRanges)
+		assert.Empty(t, actualInstants)
+	})
+
+	t.Run("min/max query time is older than max age", func(t *testing.T) {
+		test, err := NewWriteReadSeriesTest(cfg, &ClientMock{}, log.NewNopLogger(), nil)
+		require.NoError(t, err)
+		test.queryMinTime = now.Add(-cfg.MaxQueryAge).Add(-time.Minute)
+		test.queryMaxTime = now.Add(-cfg.MaxQueryAge).Add(-time.Minute)
+
+		actualRanges, actualInstants, err := test.getQueryTimeRanges(now)
+		assert.Error(t, err)
+		assert.Empty(t, actualRanges)
+		assert.Empty(t, actualInstants)
 	})
 
 	t.Run("min query time = max query time", func(t *testing.T) {
-		test := NewWriteReadSeriesTest(cfg, &ClientMock{}, log.NewNopLogger(), nil)
+		test, err := NewWriteReadSeriesTest(cfg, &ClientMock{}, log.NewNopLogger(), nil)
+		require.NoError(t, err)
 		test.queryMinTime = now.Add(-time.Minute)
 		test.queryMaxTime = now.Add(-time.Minute)
 
@@ -655,7 +1737,8 @@ func TestWriteReadSeriesTest_getRangeQueryTimeRanges(t *testing.T) {
 	})
 
 	t.Run("min and max query time are within the last 1h", func(t *testing.T) {
-		test := NewWriteReadSeriesTest(cfg, &ClientMock{}, log.NewNopLogger(), nil)
+		test, err := NewWriteReadSeriesTest(cfg, &ClientMock{}, log.NewNopLogger(), nil)
+		require.NoError(t, err)
 		test.queryMinTime = now.Add(-30 * time.Minute)
 		test.queryMaxTime = now.Add(-time.Minute)
 
@@ -676,7 +1759,8 @@ func TestWriteReadSeriesTest_getRangeQueryTimeRanges(t *testing.T) {
 	})
 
 	t.Run("min and max query time are within the last 2h", func(t *testing.T) {
-		test := NewWriteReadSeriesTest(cfg, &ClientMock{}, log.NewNopLogger(), nil)
+		test, err := NewWriteReadSeriesTest(cfg, &ClientMock{}, log.NewNopLogger(), nil)
+		require.NoError(t, err)
 		test.queryMinTime = now.Add(-90 * time.Minute)
 		test.queryMaxTime = now.Add(-80 * time.Minute)
 
@@ -696,8 +1780,36 @@ func TestWriteReadSeriesTest_getRangeQueryTimeRanges(t *testing.T) {
 		require.LessOrEqual(t, actualInstants[len(actualInstants)-1].Unix(), test.queryMaxTime.Unix())
 	})
 
+	t.Run("max query time is 2h old", func(t *testing.T) {
+		test, err := NewWriteReadSeriesTest(cfg, &ClientMock{}, log.NewNopLogger(), nil)
+		require.NoError(t, err)
+		test.queryMinTime = now.Add(-30 * time.Hour)
+		test.queryMaxTime = now.Add(-2 * time.Hour)
+
+		actualRanges, actualInstants, err := test.getQueryTimeRanges(now)
+		require.NoError(t, err)
+
+		// The last 1h window is skipped, because there's no data to query in it.
+		require.Len(t, actualRanges, 3)
+		require.Equal(t, [2]time.Time{now.Add(-24 * time.Hour), now.Add(-2 * time.Hour)}, actualRanges[0])  // Last 24h.
+		require.Equal(t, [2]time.Time{now.Add(-24 * time.Hour), now.Add(-23 * time.Hour)}, actualRanges[1]) // From last 23h to last 24h.
+
+		require.Len(t, actualInstants, 2)
+		require.Equal(t, now.Add(-24*time.Hour), actualInstants[0]) // Last 24h.
+
+		// No range or instant extends past the max query time.
+		for _, r := range actualRanges {
+			require.False(t, r[0].After(r[1]))
+			require.False(t, r[1].After(test.queryMaxTime))
+		}
+		for _, ts := range actualInstants {
+			require.False(t, ts.After(test.queryMaxTime))
+		}
+	})
+
 	t.Run("min query time is older than 24h", func(t *testing.T) {
-		test := NewWriteReadSeriesTest(cfg, &ClientMock{}, log.NewNopLogger(), nil)
+		test, err := NewWriteReadSeriesTest(cfg, &ClientMock{}, log.NewNopLogger(), nil)
+		require.NoError(t, err)
 		test.queryMinTime = now.Add(-30 * time.Hour)
 		test.queryMaxTime = now.Add(-time.Minute)
 
@@ -721,7 +1833,8 @@ func TestWriteReadSeriesTest_getRangeQueryTimeRanges(t *testing.T) {
 	})
 
 	t.Run("max query time is older than 24h but more recent than max query age", func(t *testing.T) {
-		test := NewWriteReadSeriesTest(cfg, &ClientMock{}, log.NewNopLogger(), nil)
+		test, err := NewWriteReadSeriesTest(cfg, &ClientMock{}, log.NewNopLogger(), nil)
+		require.NoError(t, err)
 		test.queryMinTime = now.Add(-30 * time.Hour)
 		test.queryMaxTime = now.Add(-25 * time.Hour)
 
@@ -742,7 +1855,8 @@ func TestWriteReadSeriesTest_getRangeQueryTimeRanges(t *testing.T) {
 		cfg := cfg
 		cfg.MaxQueryAge = 10 * time.Minute
 
-		test := NewWriteReadSeriesTest(cfg, &ClientMock{}, log.NewNopLogger(), nil)
+		test, err := NewWriteReadSeriesTest(cfg, &ClientMock{}, log.NewNopLogger(), nil)
+		require.NoError(t, err)
 		test.queryMinTime = now.Add(-30 * time.Hour)
 		test.queryMaxTime = now.Add(-time.Minute)
 
@@ -761,4 +1875,4875 @@ func TestWriteReadSeriesTest_getRangeQueryTimeRanges(t *testing.T) {
 		require.GreaterOrEqual(t, actualInstants[len(actualInstants)-1].Unix(), test.queryMinTime.Unix())
 		require.LessOrEqual(t, actualInstants[len(actualInstants)-1].Unix(), test.queryMaxTime.Unix())
 	})
+
+	t.Run("should never query before the min query time, where no data is expected to exist", func(t *testing.T) {
+		test, err := NewWriteReadSeriesTest(cfg, &ClientMock{}, log.NewNopLogger(), nil)
+		require.NoError(t, err)
+		test.queryMinTime = now.Add(-36 * time.Hour)
+		test.queryMaxTime = now.Add(-time.Minute)
+
+		// The random time range is different at each call.
+		for i := 0; i < 100; i++ {
+			actualRanges, actualInstants, err := test.getQueryTimeRanges(now)
+			require.NoError(t, err)
+
+			for _, actualRange := range actualRanges {
+				require.False(t, actualRange[0].Before(test.queryMinTime))
+			}
+			for _, actualInstant := range actualInstants {
+				require.False(t, actualInstant.Before(test.queryMinTime))
+			}
+		}
+	})
+
+	t.Run("additional random time ranges", func(t *testing.T) {
+		randomCfg := cfg
+		randomCfg.RandomQueryRanges = 3
+		randomCfg.RandomQueryRangeMinWidth = 10 * time.Minute
+		randomCfg.RandomQueryRangeMaxWidth = 2 * time.Hour
+
+		invalidCfg := randomCfg
+		invalidCfg.RandomQueryRangeMaxWidth = time.Minute
+		_, err := NewWriteReadSeriesTest(invalidCfg, &ClientMock{}, log.NewNopLogger(), nil)
+		require.Error(t, err)
+
+		test, err := NewWriteReadSeriesTest(randomCfg, &ClientMock{}, log.NewNopLogger(), nil)
+		require.NoError(t, err)
+		test.queryMinTime = now.Add(-36 * time.Hour)
+		test.queryMaxTime = now.Add(-time.Minute)
+
+		actualRanges, actualInstants, err := test.getQueryTimeRanges(now)
+		require.NoError(t, err)
+
+		// Last 1h, last 24h, from last 23h to last 24h, and the random time range, followed by the additional ones.
+		require.Len(t, actualRanges, 4+randomCfg.RandomQueryRanges)
+		require.Len(t, actualInstants, 3)
+
+		additionalRanges := actualRanges[4:]
+		for _, actualRange := range additionalRanges {
+			require.False(t, actualRange[0].Before(test.queryMinTime))
+			require.False(t, actualRange[1].After(test.queryMaxTime))
+			require.GreaterOrEqual(t, actualRange[1].Sub(actualRange[0]), randomCfg.RandomQueryRangeMinWidth)
+			require.LessOrEqual(t, actualRange[1].Sub(actualRange[0]), randomCfg.RandomQueryRangeMaxWidth)
+		}
+
+		// The additional random time ranges are the same at the same time of the run.
+		againRanges, _, err := test.getQueryTimeRanges(now)
+		require.NoError(t, err)
+		require.Equal(t, additionalRanges, againRanges[4:])
+	})
+}
+
+func TestWriteReadSeriesTest_QueryAgeAnchor(t *testing.T) {
+	newConfig := func(anchor, location string) WriteReadSeriesTestConfig {
+		cfg := WriteReadSeriesTestConfig{}
+		flagext.DefaultValues(&cfg)
+		cfg.NumSeries = 2
+		cfg.MaxQueryAge = 3 * 24 * time.Hour
+		cfg.QueryAgeAnchor = anchor
+		cfg.QueryAgeLocation = location
+		return cfg
+	}
+
+	t.Run("should fail on an unsupported anchor", func(t *testing.T) {
+		_, err := NewWriteReadSeriesTest(newConfig("noon", "UTC"), &ClientMock{}, log.NewNopLogger(), nil)
+		require.Error(t, err)
+	})
+
+	t.Run("should fail on an invalid location", func(t *testing.T) {
+		_, err := NewWriteReadSeriesTest(newConfig(queryAgeAnchorMidnight, "Nowhere/Unknown"), &ClientMock{}, log.NewNopLogger(), nil)
+		require.Error(t, err)
+	})
+
+	t.Run("day windows anchored to now span 24h", func(t *testing.T) {
+		test, err := NewWriteReadSeriesTest(newConfig(queryAgeAnchorNow, "America/New_York"), &ClientMock{}, log.NewNopLogger(), nil)
+		require.NoError(t, err)
+
+		end := time.Date(2022, time.March, 14, 15, 0, 0, 0, time.UTC)
+		assert.Equal(t, end.Add(-24*time.Hour), test.dayWindowStart(end))
+	})
+
+	t.Run("day windows anchored to midnight span a calendar day in the configured location", func(t *testing.T) {
+		loc, err := time.LoadLocation("America/New_York")
+		require.NoError(t, err)
+
+		test, err := NewWriteReadSeriesTest(newConfig(queryAgeAnchorMidnight, "America/New_York"), &ClientMock{}, log.NewNopLogger(), nil)
+		require.NoError(t, err)
+
+		tests := map[string]struct {
+			end              time.Time
+			expectedStart    time.Time
+			expectedDuration time.Duration
+		}{
+			"in the middle of the day": {
+				end:              time.Date(2022, time.March, 14, 15, 0, 0, 0, loc),
+				expectedStart:    time.Date(2022, time.March, 14, 0, 0, 0, 0, loc),
+				expectedDuration: 15 * time.Hour,
+			},
+			"at midnight": {
+				end:              time.Date(2022, time.March, 14, 0, 0, 0, 0, loc),
+				expectedStart:    time.Date(2022, time.March, 13, 0, 0, 0, 0, loc),
+				expectedDuration: 23 * time.Hour, // DST starts.
+			},
+			"at midnight at the end of the day when DST ends": {
+				end:              time.Date(2022, time.November, 7, 0, 0, 0, 0, loc),
+				expectedStart:    time.Date(2022, time.November, 6, 0, 0, 0, 0, loc),
+				expectedDuration: 25 * time.Hour,
+			},
+			"with a UTC end time": {
+				end:              time.Date(2022, time.March, 14, 3, 0, 0, 0, time.UTC),
+				expectedStart:    time.Date(2022, time.March, 13, 0, 0, 0, 0, loc),
+				expectedDuration: 22 * time.Hour, // The end time is 23:00 of the previous day in the configured location.
+			},
+		}
+
+		for testName, testData := range tests {
+			t.Run(testName, func(t *testing.T) {
+				start := test.dayWindowStart(testData.end)
+				assert.True(t, testData.expectedStart.Equal(start), "expected %s but got %s", testData.expectedStart, start)
+				assert.Equal(t, testData.expectedDuration, testData.end.Sub(start))
+			})
+		}
+	})
+
+	t.Run("query time ranges are aligned to midnight", func(t *testing.T) {
+		loc, err := time.LoadLocation("America/New_York")
+		require.NoError(t, err)
+
+		test, err := NewWriteReadSeriesTest(newConfig(queryAgeAnchorMidnight, "America/New_York"), &ClientMock{}, log.NewNopLogger(), nil)
+		require.NoError(t, err)
+
+		now := time.Date(2022, time.March, 14, 15, 0, 0, 0, loc)
+		midnight := time.Date(2022, time.March, 14, 0, 0, 0, 0, loc)
+		test.queryMinTime = now.Add(-30 * time.Hour)
+		test.queryMaxTime = now.Add(-time.Minute)
+
+		actualRanges, actualInstants, err := test.getQueryTimeRanges(now)
+		require.NoError(t, err)
+		require.Len(t, actualRanges, 4)
+		require.Equal(t, [2]time.Time{now.Add(-time.Hour), now.Add(-time.Minute)}, actualRanges[0]) // Last 1h.
+		require.Equal(t, [2]time.Time{midnight, now.Add(-time.Minute)}, actualRanges[1])            // Since midnight.
+		require.Equal(t, [2]time.Time{midnight, midnight.Add(time.Hour)}, actualRanges[2])          // First hour since midnight.
+
+		require.Len(t, actualInstants, 3)
+		require.Equal(t, now.Add(-time.Minute), actualInstants[0]) // Last 1h.
+		require.Equal(t, midnight, actualInstants[1])              // Since midnight.
+	})
+
+	t.Run("Init scans the previously written samples by calendar day", func(t *testing.T) {
+		cfg := newConfig(queryAgeAnchorMidnight, "UTC")
+		now := time.Unix(10*86400, 0).Add(6 * time.Hour)
+		midnight := time.Unix(10*86400, 0)
+
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", midnight, now, defaultWriteInterval, mock.Anything).Return(model.Matrix{{
+			Values: generateSineWaveSamplesSum(midnight, now.Add(-1*time.Minute), cfg.NumSeries, defaultWriteInterval),
+		}}, nil)
+		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", midnight.Add(-24*time.Hour), midnight.Add(-defaultWriteInterval), defaultWriteInterval, mock.Anything).Return(model.Matrix{{
+			Values: generateSineWaveSamplesSum(now.Add(-28*time.Hour), midnight.Add(-defaultWriteInterval), cfg.NumSeries, defaultWriteInterval),
+		}}, nil)
+
+		require.NoError(t, test.Init(context.Background(), now))
+
+		client.AssertNumberOfCalls(t, "QueryRange", 2)
+
+		require.Equal(t, now.Add(-1*time.Minute), test.lastWrittenTimestamp)
+		require.Equal(t, now.Add(-28*time.Hour), test.queryMinTime)
+		require.Equal(t, now.Add(-1*time.Minute), test.queryMaxTime)
+	})
+}
+
+func TestWriteReadSeriesTest_CustomWriteInterval(t *testing.T) {
+	logger := log.NewNopLogger()
+	cfg := WriteReadSeriesTestConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.NumSeries = 2
+	cfg.MaxQueryAge = 3 * 24 * time.Hour
+	cfg.WriteInterval = time.Minute
+
+	t.Run("should fail if the write interval is not greater than 0", func(t *testing.T) {
+		invalidCfg := cfg
+		invalidCfg.WriteInterval = 0
+
+		_, err := NewWriteReadSeriesTest(invalidCfg, &ClientMock{}, logger, nil)
+		require.Error(t, err)
+	})
+
+	t.Run("should find previously written samples at the configured write interval on init", func(t *testing.T) {
+		now := time.Unix(10*86400, 0)
+
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-24*time.Hour).Add(time.Minute), now, time.Minute, mock.Anything).Return(model.Matrix{{
+			Values: generateSineWaveSamplesSum(now.Add(-2*time.Hour), now.Add(-2*time.Minute), cfg.NumSeries, time.Minute),
+		}}, nil)
+
+		require.NoError(t, test.Init(context.Background(), now))
+
+		client.AssertNumberOfCalls(t, "QueryRange", 1)
+
+		require.Equal(t, now.Add(-2*time.Minute), test.lastWrittenTimestamp)
+		require.Equal(t, now.Add(-2*time.Hour), test.queryMinTime)
+		require.Equal(t, now.Add(-2*time.Minute), test.queryMaxTime)
+	})
+
+	t.Run("should write and query series at the configured write interval", func(t *testing.T) {
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+		client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{}, nil)
+		client.On("Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Vector{}, nil)
+
+		test.lastWrittenTimestamp = time.Unix(840, 0)
+		now := time.Unix(1000, 0)
+		// Ignore this error. It will be non-nil because the query mock does not return any data.
+		_ = test.Run(context.Background(), now)
+
+		client.AssertNumberOfCalls(t, "WriteSeries", 2)
+		client.AssertCalled(t, "WriteSeries", mock.Anything, generateSineWaveSeries(metricName, time.Unix(900, 0), 2))
+		client.AssertCalled(t, "WriteSeries", mock.Anything, generateSineWaveSeries(metricName, time.Unix(960, 0), 2))
+		assert.Equal(t, int64(960), test.lastWrittenTimestamp.Unix())
+
+		client.AssertNumberOfCalls(t, "QueryRange", 4)
+		client.AssertCalled(t, "QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", time.Unix(900, 0), time.Unix(960, 0), time.Minute, mock.Anything)
+
+		client.AssertNumberOfCalls(t, "Query", 4)
+		client.AssertCalled(t, "Query", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", time.Unix(960, 0), mock.Anything)
+	})
+}
+
+func TestWriteReadSeriesTest_SquareWave(t *testing.T) {
+	logger := log.NewNopLogger()
+	cfg := WriteReadSeriesTestConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.NumSeries = 2
+	cfg.WaveShape = "square"
+
+	t.Run("should fail on unsupported wave shape", func(t *testing.T) {
+		invalidCfg := cfg
+		invalidCfg.WaveShape = "triangle"
+
+		_, err := NewWriteReadSeriesTest(invalidCfg, &ClientMock{}, logger, nil)
+		require.Error(t, err)
+	})
+
+	t.Run("should write square wave series and verify the query results", func(t *testing.T) {
+		now := time.Unix(10*86400+360, 0)
+		queryMinTime := now.Add(-2 * time.Minute)
+
+		// The range includes a change from the high to the low plateau.
+		samples := make([]model.SamplePair, 0)
+		for ts := queryMinTime; !ts.After(now); ts = ts.Add(defaultWriteInterval) {
+			samples = append(samples, newSamplePair(ts, float64(cfg.NumSeries)*generateSquareWaveValue(ts, cfg.SquareWavePeriod)))
+		}
+
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+		client.On("QueryRange", mock.Anything, mock.Anything, queryMinTime, now, defaultWriteInterval, mock.Anything).Return(model.Matrix{{Values: samples}}, nil)
+		client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{}, nil)
+		client.On("Query", mock.Anything, mock.Anything, now, mock.Anything).Return(model.Vector{{Timestamp: model.Time(now.UnixMilli()), Value: -2}}, nil)
+		client.On("Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Vector{}, nil)
+
+		test.lastWrittenTimestamp = now.Add(-defaultWriteInterval)
+		test.queryMinTime = queryMinTime
+		test.queryMaxTime = now.Add(-defaultWriteInterval)
+
+		// Ignore this error. It will be non-nil because of the random range and instant queries, whose mocks
+		// don't return any data.
+		_ = test.Run(context.Background(), now)
+
+		client.AssertNumberOfCalls(t, "WriteSeries", 1)
+		client.AssertCalled(t, "WriteSeries", mock.Anything, generateSquareWaveSeries(metricName, now, 2, cfg.SquareWavePeriod))
+
+		// The last 1h range query and instant query results match the square wave.
+		require.NoError(t, test.runRangeQueryAndVerifyResult(context.Background(), queryMinTime, now, false))
+		require.NoError(t, test.runInstantQueryAndVerifyResult(context.Background(), now, false))
+
+		// A sine wave result doesn't match the square wave.
+		sineClient := &ClientMock{}
+		sineClient.On("Query", mock.Anything, mock.Anything, now, mock.Anything).Return(model.Vector{{Timestamp: model.Time(now.UnixMilli()), Value: model.SampleValue(2 * generateSineWaveValue(now))}}, nil)
+		test.client = sineClient
+		require.Error(t, test.runInstantQueryAndVerifyResult(context.Background(), now, false))
+	})
+}
+
+func TestWriteReadSeriesTest_ParquetStorage(t *testing.T) {
+	cfg := WriteReadSeriesTestConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.NumSeries = 2
+	cfg.ParquetQueryMinAge = 2 * time.Hour
+
+	now := time.Unix(10*86400, 0)
+	longRangeStart, longRangeEnd := now.Add(-4*time.Hour), now.Add(-3*time.Hour)
+	recentStart := now.Add(-time.Hour)
+	wrongValue := func(samples []model.SamplePair) []model.SamplePair {
+		samples[0].Value += 10
+		return samples
+	}
+
+	// parquetStorage returns whether the Parquet storage has been selected by the input request options.
+	parquetStorage := func(options []RequestOption) bool {
+		actual := &requestOptions{}
+		for _, option := range options {
+			option(actual)
+		}
+		return actual.parquetStorage
+	}
+
+	tests := map[string]struct {
+		start, end           time.Time
+		instant              bool
+		samples              []model.SamplePair
+		expectedErr          bool
+		expectedStorage      string
+		expectedChecks       int
+		expectedFailedChecks int
+	}{
+		"long-range range query returning the expected data": {
+			start:           longRangeStart,
+			end:             longRangeEnd,
+			samples:         generateSineWaveSamplesSum(longRangeStart, longRangeEnd, cfg.NumSeries, getQueryStep(longRangeStart, longRangeEnd, cfg.WriteInterval)),
+			expectedStorage: storageParquet,
+			expectedChecks:  1,
+		},
+		"long-range range query returning unexpected data": {
+			start:                longRangeStart,
+			end:                  longRangeEnd,
+			samples:              wrongValue(generateSineWaveSamplesSum(longRangeStart, longRangeEnd, cfg.NumSeries, getQueryStep(longRangeStart, longRangeEnd, cfg.WriteInterval))),
+			expectedErr:          true,
+			expectedStorage:      storageParquet,
+			expectedChecks:       1,
+			expectedFailedChecks: 1,
+		},
+		"long-range instant query returning the expected data": {
+			start:           longRangeStart,
+			instant:         true,
+			samples:         generateSineWaveSamplesSum(longRangeStart, longRangeStart, cfg.NumSeries, cfg.WriteInterval),
+			expectedStorage: storageParquet,
+			expectedChecks:  1,
+		},
+		"long-range instant query returning unexpected data": {
+			start:                longRangeStart,
+			instant:              true,
+			samples:              wrongValue(generateSineWaveSamplesSum(longRangeStart, longRangeStart, cfg.NumSeries, cfg.WriteInterval)),
+			expectedErr:          true,
+			expectedStorage:      storageParquet,
+			expectedChecks:       1,
+			expectedFailedChecks: 1,
+		},
+		"recent range query": {
+			start:           recentStart,
+			end:             now,
+			samples:         generateSineWaveSamplesSum(recentStart, now, cfg.NumSeries, getQueryStep(recentStart, now, cfg.WriteInterval)),
+			expectedStorage: storageDefault,
+			expectedChecks:  1,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
+			client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{{Values: testData.samples}}, nil)
+			client.On("Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Vector{{Timestamp: testData.samples[0].Timestamp, Value: testData.samples[0].Value}}, nil)
+
+			test.timeNow = func() time.Time { return now }
+			test.queryMinTime = now.Add(-24 * time.Hour)
+			test.queryMaxTime = now
+
+			var err error
+			if testData.instant {
+				err = test.runInstantQueryAndVerifyResult(context.Background(), testData.start, false)
+			} else {
+				err = test.runRangeQueryAndVerifyResult(context.Background(), testData.start, testData.end, false)
+			}
+			if testData.expectedErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			require.Len(t, client.Calls, 1)
+			options := client.Calls[0].Arguments.Get(len(client.Calls[0].Arguments) - 1).([]RequestOption)
+			assert.Equal(t, testData.expectedStorage == storageParquet, parquetStorage(options))
+
+			queryType := queryTypeRange
+			if testData.instant {
+				queryType = queryTypeInstant
+			}
+
+			// The counters of the default storage are always exported, for both query types.
+			var expectedTotal, expectedFailed []string
+			for _, storage := range []string{storageDefault, storageParquet} {
+				for _, qt := range []string{queryTypeInstant, queryTypeRange} {
+					checks, failedChecks := 0, 0
+					if storage == testData.expectedStorage && qt == queryType {
+						checks, failedChecks = testData.expectedChecks, testData.expectedFailedChecks
+					} else if storage != storageDefault {
+						continue
+					}
+
+					expectedTotal = append(expectedTotal, fmt.Sprintf(`mimir_continuous_test_query_result_checks_total{query_type=%q,read_path="query_api",storage=%q,test="write-read-series"} %d`, qt, storage, checks))
+					expectedFailed = append(expectedFailed, fmt.Sprintf(`mimir_continuous_test_query_result_checks_failed_total{query_type=%q,read_path="query_api",storage=%q,test="write-read-series"} %d`, qt, storage, failedChecks))
+				}
+			}
+
+			expected := `
+				# HELP mimir_continuous_test_query_result_checks_total Total number of query results checked for correctness.
+				# TYPE mimir_continuous_test_query_result_checks_total counter
+				` + strings.Join(expectedTotal, "\n") + `
+
+				# HELP mimir_continuous_test_query_result_checks_failed_total Total number of query results failed when checking for correctness.
+				# TYPE mimir_continuous_test_query_result_checks_failed_total counter
+				` + strings.Join(expectedFailed, "\n") + `
+			`
+
+			assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expected),
+				"mimir_continuous_test_query_result_checks_total", "mimir_continuous_test_query_result_checks_failed_total"))
+		})
+	}
+}
+
+func TestWriteReadSeriesTest_runRangeQueryAndVerifyResult_TimestampDeviations(t *testing.T) {
+	cfg := WriteReadSeriesTestConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.NumSeries = 2
+
+	now := time.Unix(10*86400, 0)
+	queryMinTime := now.Add(-2 * time.Minute)
+
+	// Shift a single sample by 1ms. Its value is still close enough to the expected one.
+	samples := generateSineWaveSamplesSum(queryMinTime, now, cfg.NumSeries, defaultWriteInterval)
+	samples[len(samples)-1].Timestamp++
+
+	test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
+	client.On("QueryRange", mock.Anything, mock.Anything, queryMinTime, now, defaultWriteInterval, mock.Anything).Return(model.Matrix{{Values: samples}}, nil)
+	client.On("Query", mock.Anything, mock.Anything, now, mock.Anything).Return(model.Vector{{Timestamp: model.Time(now.UnixMilli() - 1), Value: model.SampleValue(2 * generateSineWaveValue(now))}}, nil)
+
+	test.queryMinTime = queryMinTime
+	test.queryMaxTime = now
+
+	require.Error(t, test.runRangeQueryAndVerifyResult(context.Background(), queryMinTime, now, false))
+	require.Error(t, test.runInstantQueryAndVerifyResult(context.Background(), now, false))
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP mimir_continuous_test_query_result_checks_total Total number of query results checked for correctness.
+		# TYPE mimir_continuous_test_query_result_checks_total counter
+		mimir_continuous_test_query_result_checks_total{query_type="instant",read_path="query_api",storage="default",test="write-read-series"} 1
+		mimir_continuous_test_query_result_checks_total{query_type="range",read_path="query_api",storage="default",test="write-read-series"} 1
+
+		# HELP mimir_continuous_test_query_result_checks_failed_total Total number of query results failed when checking for correctness.
+		# TYPE mimir_continuous_test_query_result_checks_failed_total counter
+		mimir_continuous_test_query_result_checks_failed_total{query_type="instant",read_path="query_api",storage="default",test="write-read-series"} 1
+		mimir_continuous_test_query_result_checks_failed_total{query_type="range",read_path="query_api",storage="default",test="write-read-series"} 1
+
+		# HELP mimir_continuous_test_query_result_timestamp_deviations_total Total number of samples returned by queries whose timestamp doesn't exactly match the expected one.
+		# TYPE mimir_continuous_test_query_result_timestamp_deviations_total counter
+		mimir_continuous_test_query_result_timestamp_deviations_total{test="write-read-series"} 2
+	`), "mimir_continuous_test_query_result_checks_total", "mimir_continuous_test_query_result_checks_failed_total", "mimir_continuous_test_query_result_timestamp_deviations_total"))
+}
+
+func TestWriteReadSeriesTest_MetricNamePrefix(t *testing.T) {
+	logger := log.NewNopLogger()
+	cfg := WriteReadSeriesTestConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.NumSeries = 2
+	cfg.MaxQueryAge = 3 * 24 * time.Hour
+	cfg.MetricNamePrefix = "eu_west_"
+
+	t.Run("should fail if the prefix produces an invalid metric name", func(t *testing.T) {
+		invalidCfg := cfg
+		invalidCfg.MetricNamePrefix = "eu-west-"
+
+		_, err := NewWriteReadSeriesTest(invalidCfg, &ClientMock{}, logger, nil)
+		require.Error(t, err)
+	})
+
+	t.Run("should recover previously written samples of the prefixed metric on init", func(t *testing.T) {
+		now := time.Unix(10*86400, 0)
+
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("QueryRange", mock.Anything, "sum(max_over_time(eu_west_mimir_continuous_test_sine_wave[1s]))", now.Add(-24*time.Hour).Add(defaultWriteInterval), now, defaultWriteInterval, mock.Anything).Return(model.Matrix{{
+			Values: generateSineWaveSamplesSum(now.Add(-2*time.Hour), now.Add(-1*time.Minute), cfg.NumSeries, defaultWriteInterval),
+		}}, nil)
+
+		require.NoError(t, test.Init(context.Background(), now))
+
+		client.AssertNumberOfCalls(t, "QueryRange", 1)
+
+		require.Equal(t, now.Add(-1*time.Minute), test.lastWrittenTimestamp)
+		require.Equal(t, now.Add(-2*time.Hour), test.queryMinTime)
+		require.Equal(t, now.Add(-1*time.Minute), test.queryMaxTime)
+	})
+
+	t.Run("should write and query the prefixed metric", func(t *testing.T) {
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+		client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{}, nil)
+		client.On("Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Vector{}, nil)
+
+		now := time.Unix(1000, 0)
+		// Ignore this error. It will be non-nil because the query mock does not return any data.
+		_ = test.Run(context.Background(), now)
+
+		client.AssertNumberOfCalls(t, "WriteSeries", 1)
+		client.AssertCalled(t, "WriteSeries", mock.Anything, generateSineWaveSeries("eu_west_mimir_continuous_test_sine_wave", now, 2))
+		client.AssertCalled(t, "QueryRange", mock.Anything, "sum(max_over_time(eu_west_mimir_continuous_test_sine_wave[1s]))", now, now, defaultWriteInterval, mock.Anything)
+		client.AssertCalled(t, "Query", mock.Anything, "sum(max_over_time(eu_west_mimir_continuous_test_sine_wave[1s]))", now, mock.Anything)
+	})
+}
+
+func TestWriteReadSeriesTest_WritePath(t *testing.T) {
+	logger := log.NewNopLogger()
+	cfg := WriteReadSeriesTestConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.NumSeries = 2
+	cfg.WritePath = writePathOTLP
+
+	t.Run("should fail on unsupported write path", func(t *testing.T) {
+		invalidCfg := cfg
+		invalidCfg.WritePath = "unknown"
+
+		_, err := NewWriteReadSeriesTest(invalidCfg, &ClientMock{}, logger, nil)
+		require.Error(t, err)
+	})
+
+	t.Run("should fail if exemplars are enabled when writing through OTLP", func(t *testing.T) {
+		invalidCfg := cfg
+		invalidCfg.WithExemplars = true
+
+		_, err := NewWriteReadSeriesTest(invalidCfg, &ClientMock{}, logger, nil)
+		require.Error(t, err)
+	})
+
+	t.Run("should write series through OTLP and verify them like the ones written through remote write", func(t *testing.T) {
+		now := time.Unix(1000, 0)
+
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("WriteSeriesOTLP", mock.Anything, mock.Anything).Return(200, nil)
+		client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{{
+			Metric: model.Metric{},
+			Values: []model.SamplePair{newSamplePair(now, 2*generateSineWaveValue(now))},
+		}}, nil)
+		client.On("Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Vector{{
+			Metric:    model.Metric{},
+			Value:     model.SampleValue(2 * generateSineWaveValue(now)),
+			Timestamp: model.Time(now.UnixMilli()),
+		}}, nil)
+
+		require.NoError(t, test.Run(context.Background(), now))
+
+		client.AssertNumberOfCalls(t, "WriteSeriesOTLP", 1)
+		client.AssertCalled(t, "WriteSeriesOTLP", mock.Anything, generateSineWaveSeries(metricName, now, 2))
+		client.AssertNotCalled(t, "WriteSeries", mock.Anything, mock.Anything)
+		assert.Equal(t, now, test.lastWrittenTimestamp)
+	})
+}
+
+func TestWriteReadSeriesTest_ExtraLabels(t *testing.T) {
+	logger := log.NewNopLogger()
+	cfg := WriteReadSeriesTestConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.NumSeries = 2
+	cfg.MaxQueryAge = 3 * 24 * time.Hour
+	cfg.ExtraLabels = []string{"cluster=eu-west-1"}
+
+	t.Run("should fail if an extra label is invalid", func(t *testing.T) {
+		invalidCfg := cfg
+		invalidCfg.ExtraLabels = []string{"series_id=1"}
+
+		_, err := NewWriteReadSeriesTest(invalidCfg, &ClientMock{}, logger, nil)
+		require.Error(t, err)
+	})
+
+	t.Run("should write series with the extra labels and select them when querying", func(t *testing.T) {
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+		client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{}, nil)
+		client.On("Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Vector{}, nil)
+
+		now := time.Unix(1000, 0)
+		// Ignore this error. It will be non-nil because the query mock does not return any data.
+		_ = test.Run(context.Background(), now)
+
+		expectedSeries := generateSineWaveSeries("mimir_continuous_test_sine_wave", now, 2)
+		for i := range expectedSeries {
+			expectedSeries[i].Labels = append(expectedSeries[i].Labels, prompb.Label{Name: "cluster", Value: "eu-west-1"})
+		}
+
+		client.AssertNumberOfCalls(t, "WriteSeries", 1)
+		client.AssertCalled(t, "WriteSeries", mock.Anything, expectedSeries)
+		client.AssertCalled(t, "QueryRange", mock.Anything, `sum(max_over_time(mimir_continuous_test_sine_wave{cluster="eu-west-1"}[1s]))`, now, now, defaultWriteInterval, mock.Anything)
+		client.AssertCalled(t, "Query", mock.Anything, `sum(max_over_time(mimir_continuous_test_sine_wave{cluster="eu-west-1"}[1s]))`, now, mock.Anything)
+	})
+
+	t.Run("should select the series with the extra labels in the name matcher check", func(t *testing.T) {
+		test, err := NewWriteReadSeriesTest(cfg, &ClientMock{}, logger, nil)
+		require.NoError(t, err)
+		assert.Equal(t, `sum(max_over_time({__name__="mimir_continuous_test_sine_wave",cluster="eu-west-1"}[1s]))`, test.queryMetricSumWithNameMatcher)
+	})
+}
+
+func TestWriteReadSeriesTest_DryRun(t *testing.T) {
+	cfg := WriteReadSeriesTestConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.NumSeries = 2
+	cfg.DryRun = true
+	cfg.ValidateSchemaOnStart = true
+	cfg.LabelOrderCheckEnabled = true
+
+	logs := &bytes.Buffer{}
+	reg := prometheus.NewPedanticRegistry()
+
+	// The client mock has no expectations, so any request would make the test fail.
+	client := &ClientMock{}
+	test, err := NewWriteReadSeriesTest(cfg, client, log.NewLogfmtLogger(logs), reg)
+	require.NoError(t, err)
+
+	now := time.Unix(10*86400, 0)
+	require.NoError(t, test.ValidateSchema(context.Background(), now))
+	require.NoError(t, test.Init(context.Background(), now))
+	require.NoError(t, test.Run(context.Background(), now))
+	require.NoError(t, test.Run(context.Background(), now.Add(3*cfg.WriteInterval)))
+	assert.Empty(t, client.Calls)
+
+	// The written series are assumed to be successfully written.
+	assert.Equal(t, now.Add(3*cfg.WriteInterval), test.lastWrittenTimestamp)
+	assert.Equal(t, now, test.queryMinTime)
+	assert.Equal(t, now.Add(3*cfg.WriteInterval), test.queryMaxTime)
+
+	// The second run catches up with the skipped intervals.
+	assert.Equal(t, 1+3, strings.Count(logs.String(), `msg="Dry run: skipped writing series"`))
+	assert.Contains(t, logs.String(), `msg="Dry run: skipped writing series" selector=mimir_continuous_test_sine_wave timestamp=864060000 num_series=2`)
+	assert.Contains(t, logs.String(), `msg="Dry run: skipped range query" query=sum(max_over_time(mimir_continuous_test_sine_wave[1s])) start=864000000 end=864060000 step=20s storage=default`)
+	assert.Contains(t, logs.String(), `msg="Dry run: skipped instant query" query=sum(max_over_time(mimir_continuous_test_sine_wave[1s])) ts=864060000 storage=default`)
+	assert.Contains(t, logs.String(), `msg="Dry run: skipped schema validation"`)
+	assert.Contains(t, logs.String(), `msg="Dry run: skipped finding previously written samples time range"`)
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP mimir_continuous_test_writes_total Total number of attempted write requests.
+		# TYPE mimir_continuous_test_writes_total counter
+		mimir_continuous_test_writes_total{test="write-read-series"} 0
+
+		# HELP mimir_continuous_test_queries_total Total number of attempted query requests.
+		# TYPE mimir_continuous_test_queries_total counter
+		mimir_continuous_test_queries_total{test="write-read-series"} 0
+
+		# HELP mimir_continuous_test_query_result_checks_total Total number of query results checked for correctness.
+		# TYPE mimir_continuous_test_query_result_checks_total counter
+		mimir_continuous_test_query_result_checks_total{query_type="instant",read_path="query_api",storage="default",test="write-read-series"} 0
+		mimir_continuous_test_query_result_checks_total{query_type="range",read_path="query_api",storage="default",test="write-read-series"} 0
+	`), "mimir_continuous_test_writes_total", "mimir_continuous_test_queries_total", "mimir_continuous_test_query_result_checks_total", "mimir_continuous_test_additional_checks_total"))
+}
+
+func TestWriteReadSeriesTest_QueryStep(t *testing.T) {
+	logger := log.NewNopLogger()
+	cfg := WriteReadSeriesTestConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.NumSeries = 2
+	cfg.QueryStep = 3 * defaultWriteInterval
+
+	t.Run("should fail if the query step is not a multiple of the write interval", func(t *testing.T) {
+		for _, step := range []time.Duration{-defaultWriteInterval, defaultWriteInterval / 2, defaultWriteInterval + time.Second} {
+			invalidCfg := cfg
+			invalidCfg.QueryStep = step
+
+			_, err := NewWriteReadSeriesTest(invalidCfg, &ClientMock{}, logger, nil)
+			require.Error(t, err, "step: %s", step)
+		}
+	})
+
+	now := time.Unix(10*86400, 0)
+	queryMinTime := now.Add(-10 * time.Minute)
+
+	t.Run("should run the range queries with the configured step", func(t *testing.T) {
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("QueryRange", mock.Anything, mock.Anything, queryMinTime, now, cfg.QueryStep, mock.Anything).Return(model.Matrix{{
+			Values: generateSineWaveSamplesSum(queryMinTime, now, cfg.NumSeries, cfg.QueryStep),
+		}}, nil)
+
+		test.queryMinTime = queryMinTime
+		test.queryMaxTime = now
+
+		require.NoError(t, test.runRangeQueryAndVerifyResult(context.Background(), queryMinTime, now, false))
+		client.AssertNumberOfCalls(t, "QueryRange", 1)
+	})
+
+	t.Run("should fail if the points are not spaced by the configured step", func(t *testing.T) {
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("QueryRange", mock.Anything, mock.Anything, queryMinTime, now, cfg.QueryStep, mock.Anything).Return(model.Matrix{{
+			Values: generateSineWaveSamplesSum(queryMinTime, now, cfg.NumSeries, defaultWriteInterval),
+		}}, nil)
+
+		test.queryMinTime = queryMinTime
+		test.queryMaxTime = now
+
+		require.Error(t, test.runRangeQueryAndVerifyResult(context.Background(), queryMinTime, now, false))
+	})
+
+	t.Run("should use the write interval as step by default", func(t *testing.T) {
+		defaultCfg := cfg
+		defaultCfg.QueryStep = 0
+
+		test, client, _ := newMockedWriteReadSeriesTest(t, defaultCfg)
+		client.On("QueryRange", mock.Anything, mock.Anything, queryMinTime, now, defaultWriteInterval, mock.Anything).Return(model.Matrix{{
+			Values: generateSineWaveSamplesSum(queryMinTime, now, cfg.NumSeries, defaultWriteInterval),
+		}}, nil)
+
+		test.queryMinTime = queryMinTime
+		test.queryMaxTime = now
+
+		require.NoError(t, test.runRangeQueryAndVerifyResult(context.Background(), queryMinTime, now, false))
+	})
+}
+
+func TestWriteReadSeriesTest_SeriesChurn(t *testing.T) {
+	logger := log.NewNopLogger()
+	cfg := WriteReadSeriesTestConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.NumSeries = 4
+	cfg.SeriesChurnRate = 0.5
+
+	t.Run("should fail if the churn rate is out of range", func(t *testing.T) {
+		invalidCfg := cfg
+		invalidCfg.SeriesChurnRate = 1.5
+
+		_, err := NewWriteReadSeriesTest(invalidCfg, &ClientMock{}, logger, nil)
+		require.Error(t, err)
+	})
+
+	t.Run("should rotate the identity of the churned series at each interval", func(t *testing.T) {
+		var written [][]prompb.TimeSeries
+
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("WriteSeries", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			written = append(written, args.Get(1).([]prompb.TimeSeries))
+		}).Return(200, nil)
+		client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{}, nil)
+		client.On("Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Vector{}, nil)
+
+		// Ignore these errors. They will be non-nil because the query mock does not return any data.
+		now := time.Unix(1000, 0)
+		_ = test.Run(context.Background(), now)
+		_ = test.Run(context.Background(), now.Add(defaultWriteInterval))
+		require.Len(t, written, 2)
+
+		identities := map[string]struct{}{}
+		for idx, series := range written {
+			ts := now.Add(time.Duration(idx) * defaultWriteInterval)
+			require.Len(t, series, cfg.NumSeries)
+
+			churned := 0
+			sum := 0.0
+			for _, s := range series {
+				lbls := labelsToString(s.Labels)
+				identities[lbls] = struct{}{}
+				if strings.Contains(lbls, "churn_generation") {
+					churned++
+				}
+				sum += s.Samples[0].Value
+			}
+
+			// The churned series count is constant, and the sum of the values isn't affected by the churn.
+			assert.Equal(t, 2, churned)
+			assert.InDelta(t, float64(cfg.NumSeries)*generateSineWaveValue(ts), sum, 1e-9)
+		}
+
+		// The 2 non-churned series are written at both intervals, while the churned ones are new at each interval.
+		assert.Len(t, identities, 6)
+	})
+}
+
+func TestWriteReadSeriesTest_SetNumSeries(t *testing.T) {
+	logger := log.NewNopLogger()
+	cfg := WriteReadSeriesTestConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.NumSeries = 2
+	cfg.MaxCardinality = 10
+
+	t.Run("should fail on invalid number of series", func(t *testing.T) {
+		test, err := NewWriteReadSeriesTest(cfg, &ClientMock{}, logger, nil)
+		require.NoError(t, err)
+
+		require.Error(t, test.SetNumSeries(0))
+		require.Error(t, test.SetNumSeries(-1))
+		require.Error(t, test.SetNumSeries(11))
+		require.NoError(t, test.SetNumSeries(10))
+	})
+
+	t.Run("should reload the number of series from the file", func(t *testing.T) {
+		fileCfg := cfg
+		fileCfg.NumSeriesFile = filepath.Join(t.TempDir(), "num-series")
+
+		test, err := NewWriteReadSeriesTest(fileCfg, &ClientMock{}, logger, nil)
+		require.NoError(t, err)
+
+		// The file doesn't exist yet.
+		require.Error(t, test.ReloadNumSeries())
+
+		require.NoError(t, os.WriteFile(fileCfg.NumSeriesFile, []byte("invalid"), 0o644))
+		require.Error(t, test.ReloadNumSeries())
+
+		require.NoError(t, os.WriteFile(fileCfg.NumSeriesFile, []byte("4\n"), 0o644))
+		require.NoError(t, test.ReloadNumSeries())
+		assert.Equal(t, 4, test.nextNumSeries)
+
+		// The number of series currently written doesn't change until the next run.
+		assert.Equal(t, 2, test.cfg.NumSeries)
+	})
+
+	t.Run("should write the new number of series from the next run, and check each timestamp against the number of series written", func(t *testing.T) {
+		var written [][]prompb.TimeSeries
+
+		test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("WriteSeries", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			written = append(written, args.Get(1).([]prompb.TimeSeries))
+		}).Return(200, nil)
+		client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{}, nil)
+		client.On("Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Vector{}, nil)
+
+		// Ignore these errors. They will be non-nil because the query mock does not return any data.
+		now := time.Unix(1000, 0)
+		_ = test.Run(context.Background(), now)
+		require.NoError(t, test.SetNumSeries(4))
+		_ = test.Run(context.Background(), now.Add(defaultWriteInterval))
+		_ = test.Run(context.Background(), now.Add(2*defaultWriteInterval))
+
+		require.Len(t, written, 3)
+		assert.Len(t, written[0], 2)
+		assert.Len(t, written[1], 4)
+		assert.Len(t, written[2], 4)
+
+		assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+			# HELP mimir_continuous_test_cardinality Number of series written by the test.
+			# TYPE mimir_continuous_test_cardinality gauge
+			mimir_continuous_test_cardinality{test="write-read-series"} 4
+		`), "mimir_continuous_test_cardinality"))
+
+		assert.Equal(t, 2, test.numSeriesAt(now))
+		assert.Equal(t, 4, test.numSeriesAt(now.Add(defaultWriteInterval)))
+
+		numSeries, ok := test.numSeriesIn(now.Add(defaultWriteInterval), now.Add(2*defaultWriteInterval))
+		assert.True(t, ok)
+		assert.Equal(t, 4, numSeries)
+		_, ok = test.numSeriesIn(now, now.Add(2*defaultWriteInterval))
+		assert.False(t, ok)
+
+		// A query result spanning the change matches only if each timestamp is checked against the number of
+		// series written at that time.
+		matrix := model.Matrix{{Values: []model.SamplePair{
+			{Timestamp: model.Time(now.UnixMilli()), Value: model.SampleValue(2 * generateSineWaveValue(now))},
+			{Timestamp: model.Time(now.Add(defaultWriteInterval).UnixMilli()), Value: model.SampleValue(4 * generateSineWaveValue(now.Add(defaultWriteInterval)))},
+			{Timestamp: model.Time(now.Add(2 * defaultWriteInterval).UnixMilli()), Value: model.SampleValue(4 * generateSineWaveValue(now.Add(2*defaultWriteInterval)))},
+		}}}
+		_, err := verifySamplesSum(matrix, 1, defaultWriteInterval, test.generateSumValue, cfg.ResultCheckTolerance)
+		assert.NoError(t, err)
+		_, err = verifySamplesSum(matrix, 4, defaultWriteInterval, generateSineWaveValue, cfg.ResultCheckTolerance)
+		assert.Error(t, err)
+	})
+}
+
+func TestWriteReadSeriesTest_Run_QueryLatencySLO(t *testing.T) {
+	cfg := WriteReadSeriesTestConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.NumSeries = 2
+	cfg.QueryLatencySLO = 10 * time.Second
+
+	// The test runs 2 range and 2 instant queries, each one both with and without results cache.
+	const expectedQueries = 8
+
+	tests := map[string]struct {
+		queryLatency       time.Duration
+		expectedViolations int
+	}{
+		"fast queries": {
+			queryLatency:       time.Second,
+			expectedViolations: 0,
+		},
+		"queries as slow as the SLO": {
+			queryLatency:       10 * time.Second,
+			expectedViolations: 0,
+		},
+		"slow queries": {
+			queryLatency:       11 * time.Second,
+			expectedViolations: expectedQueries,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			now := time.Unix(10*86400, 0)
+			clock := now
+			advanceClock := func(mock.Arguments) { clock = clock.Add(testData.queryLatency) }
+
+			test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
+			client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(advanceClock).Return(model.Matrix{}, nil)
+			client.On("Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(advanceClock).Return(model.Vector{}, nil)
+
+			test.timeNow = func() time.Time { return clock }
+			test.lastWrittenTimestamp = now
+			test.queryMinTime = now.Add(-10 * time.Minute)
+			test.queryMaxTime = now
+
+			// Ignore this error. It will be non-nil because the query mock does not return any data.
+			_ = test.Run(context.Background(), now)
+
+			client.AssertNumberOfCalls(t, "QueryRange", expectedQueries/2)
+			client.AssertNumberOfCalls(t, "Query", expectedQueries/2)
+
+			assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(fmt.Sprintf(`
+				# HELP mimir_continuous_test_query_slo_violations_total Total number of queries whose latency exceeded the configured SLO.
+				# TYPE mimir_continuous_test_query_slo_violations_total counter
+				mimir_continuous_test_query_slo_violations_total{test="write-read-series"} %d
+			`, testData.expectedViolations)), "mimir_continuous_test_query_slo_violations_total"))
+		})
+	}
+}
+
+func TestWriteReadSeriesTest_Run_RequestDurations(t *testing.T) {
+	cfg := WriteReadSeriesTestConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.NumSeries = 2
+
+	now := time.Unix(10*86400, 0)
+	clock := now
+	advanceClock := func(mock.Arguments) { clock = clock.Add(2 * time.Second) }
+
+	// The requests fail, but their duration is tracked anyway.
+	test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
+	client.On("WriteSeries", mock.Anything, mock.Anything).Run(advanceClock).Return(500, errors.New("internal error"))
+	client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(advanceClock).Return(model.Matrix{}, errors.New("network error"))
+	client.On("Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(advanceClock).Return(model.Vector{}, errors.New("network error"))
+
+	test.timeNow = func() time.Time { return clock }
+	test.lastWrittenTimestamp = now.Add(-defaultWriteInterval)
+	test.queryMinTime = now.Add(-10 * time.Minute)
+	test.queryMaxTime = now.Add(-defaultWriteInterval)
+
+	require.Error(t, test.Run(context.Background(), now))
+
+	// The test runs 2 range and 2 instant queries, each one both with and without results cache.
+	client.AssertNumberOfCalls(t, "WriteSeries", 1)
+	client.AssertNumberOfCalls(t, "QueryRange", 4)
+	client.AssertNumberOfCalls(t, "Query", 4)
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP mimir_continuous_test_query_duration_seconds Time it takes to execute a query request, including failed ones.
+		# TYPE mimir_continuous_test_query_duration_seconds histogram
+		mimir_continuous_test_query_duration_seconds_bucket{le="0.005",test="write-read-series",type="instant"} 0
+		mimir_continuous_test_query_duration_seconds_bucket{le="0.01",test="write-read-series",type="instant"} 0
+		mimir_continuous_test_query_duration_seconds_bucket{le="0.025",test="write-read-series",type="instant"} 0
+		mimir_continuous_test_query_duration_seconds_bucket{le="0.05",test="write-read-series",type="instant"} 0
+		mimir_continuous_test_query_duration_seconds_bucket{le="0.1",test="write-read-series",type="instant"} 0
+		mimir_continuous_test_query_duration_seconds_bucket{le="0.25",test="write-read-series",type="instant"} 0
+		mimir_continuous_test_query_duration_seconds_bucket{le="0.5",test="write-read-series",type="instant"} 0
+		mimir_continuous_test_query_duration_seconds_bucket{le="1",test="write-read-series",type="instant"} 0
+		mimir_continuous_test_query_duration_seconds_bucket{le="2.5",test="write-read-series",type="instant"} 4
+		mimir_continuous_test_query_duration_seconds_bucket{le="5",test="write-read-series",type="instant"} 4
+		mimir_continuous_test_query_duration_seconds_bucket{le="10",test="write-read-series",type="instant"} 4
+		mimir_continuous_test_query_duration_seconds_bucket{le="+Inf",test="write-read-series",type="instant"} 4
+		mimir_continuous_test_query_duration_seconds_sum{test="write-read-series",type="instant"} 8
+		mimir_continuous_test_query_duration_seconds_count{test="write-read-series",type="instant"} 4
+		mimir_continuous_test_query_duration_seconds_bucket{le="0.005",test="write-read-series",type="range"} 0
+		mimir_continuous_test_query_duration_seconds_bucket{le="0.01",test="write-read-series",type="range"} 0
+		mimir_continuous_test_query_duration_seconds_bucket{le="0.025",test="write-read-series",type="range"} 0
+		mimir_continuous_test_query_duration_seconds_bucket{le="0.05",test="write-read-series",type="range"} 0
+		mimir_continuous_test_query_duration_seconds_bucket{le="0.1",test="write-read-series",type="range"} 0
+		mimir_continuous_test_query_duration_seconds_bucket{le="0.25",test="write-read-series",type="range"} 0
+		mimir_continuous_test_query_duration_seconds_bucket{le="0.5",test="write-read-series",type="range"} 0
+		mimir_continuous_test_query_duration_seconds_bucket{le="1",test="write-read-series",type="range"} 0
+		mimir_continuous_test_query_duration_seconds_bucket{le="2.5",test="write-read-series",type="range"} 4
+		mimir_continuous_test_query_duration_seconds_bucket{le="5",test="write-read-series",type="range"} 4
+		mimir_continuous_test_query_duration_seconds_bucket{le="10",test="write-read-series",type="range"} 4
+		mimir_continuous_test_query_duration_seconds_bucket{le="+Inf",test="write-read-series",type="range"} 4
+		mimir_continuous_test_query_duration_seconds_sum{test="write-read-series",type="range"} 8
+		mimir_continuous_test_query_duration_seconds_count{test="write-read-series",type="range"} 4
+
+		# HELP mimir_continuous_test_write_duration_seconds Time it takes to execute a write request, including failed ones.
+		# TYPE mimir_continuous_test_write_duration_seconds histogram
+		mimir_continuous_test_write_duration_seconds_bucket{le="0.005",test="write-read-series"} 0
+		mimir_continuous_test_write_duration_seconds_bucket{le="0.01",test="write-read-series"} 0
+		mimir_continuous_test_write_duration_seconds_bucket{le="0.025",test="write-read-series"} 0
+		mimir_continuous_test_write_duration_seconds_bucket{le="0.05",test="write-read-series"} 0
+		mimir_continuous_test_write_duration_seconds_bucket{le="0.1",test="write-read-series"} 0
+		mimir_continuous_test_write_duration_seconds_bucket{le="0.25",test="write-read-series"} 0
+		mimir_continuous_test_write_duration_seconds_bucket{le="0.5",test="write-read-series"} 0
+		mimir_continuous_test_write_duration_seconds_bucket{le="1",test="write-read-series"} 0
+		mimir_continuous_test_write_duration_seconds_bucket{le="2.5",test="write-read-series"} 1
+		mimir_continuous_test_write_duration_seconds_bucket{le="5",test="write-read-series"} 1
+		mimir_continuous_test_write_duration_seconds_bucket{le="10",test="write-read-series"} 1
+		mimir_continuous_test_write_duration_seconds_bucket{le="+Inf",test="write-read-series"} 1
+		mimir_continuous_test_write_duration_seconds_sum{test="write-read-series"} 2
+		mimir_continuous_test_write_duration_seconds_count{test="write-read-series"} 1
+	`), "mimir_continuous_test_query_duration_seconds", "mimir_continuous_test_write_duration_seconds"))
+}
+
+func TestWriteReadSeriesTest_Run_QueryTypes(t *testing.T) {
+	t.Run("should fail on an unsupported query type", func(t *testing.T) {
+		cfg := WriteReadSeriesTestConfig{}
+		flagext.DefaultValues(&cfg)
+		cfg.QueryTypes = []string{queryTypeRange, "exemplar"}
+
+		_, err := NewWriteReadSeriesTest(cfg, &ClientMock{}, log.NewNopLogger(), nil)
+		require.Error(t, err)
+	})
+
+	t.Run("should fail if no query type is enabled", func(t *testing.T) {
+		cfg := WriteReadSeriesTestConfig{}
+		flagext.DefaultValues(&cfg)
+		cfg.QueryTypes = nil
+
+		_, err := NewWriteReadSeriesTest(cfg, &ClientMock{}, log.NewNopLogger(), nil)
+		require.Error(t, err)
+	})
+
+	// The test runs 2 range and 2 instant queries, each one both with and without results cache.
+	tests := map[string]struct {
+		queryTypes             []string
+		expectedRangeQueries   int
+		expectedInstantQueries int
+	}{
+		"both query types": {
+			queryTypes:             []string{queryTypeInstant, queryTypeRange},
+			expectedRangeQueries:   4,
+			expectedInstantQueries: 4,
+		},
+		"range queries only": {
+			queryTypes:             []string{queryTypeRange},
+			expectedRangeQueries:   4,
+			expectedInstantQueries: 0,
+		},
+		"instant queries only": {
+			queryTypes:             []string{queryTypeInstant},
+			expectedRangeQueries:   0,
+			expectedInstantQueries: 4,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			cfg := WriteReadSeriesTestConfig{}
+			flagext.DefaultValues(&cfg)
+			cfg.NumSeries = 2
+			cfg.QueryTypes = testData.queryTypes
+
+			now := time.Unix(10*86400, 0)
+
+			test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
+			client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{}, nil)
+			client.On("Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Vector{}, nil)
+
+			test.lastWrittenTimestamp = now
+			test.queryMinTime = now.Add(-10 * time.Minute)
+			test.queryMaxTime = now
+
+			// Ignore this error. It will be non-nil because the query mock does not return any data.
+			_ = test.Run(context.Background(), now)
+
+			client.AssertNumberOfCalls(t, "QueryRange", testData.expectedRangeQueries)
+			client.AssertNumberOfCalls(t, "Query", testData.expectedInstantQueries)
+
+			// The query result checks are tracked by query type, and both query types are always exported.
+			assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(fmt.Sprintf(`
+				# HELP mimir_continuous_test_queries_total Total number of attempted query requests.
+				# TYPE mimir_continuous_test_queries_total counter
+				mimir_continuous_test_queries_total{test="write-read-series"} %d
+
+				# HELP mimir_continuous_test_query_result_checks_total Total number of query results checked for correctness.
+				# TYPE mimir_continuous_test_query_result_checks_total counter
+				mimir_continuous_test_query_result_checks_total{query_type="instant",read_path="query_api",storage="default",test="write-read-series"} %[2]d
+				mimir_continuous_test_query_result_checks_total{query_type="range",read_path="query_api",storage="default",test="write-read-series"} %[3]d
+
+				# HELP mimir_continuous_test_query_result_checks_failed_total Total number of query results failed when checking for correctness.
+				# TYPE mimir_continuous_test_query_result_checks_failed_total counter
+				mimir_continuous_test_query_result_checks_failed_total{query_type="instant",read_path="query_api",storage="default",test="write-read-series"} %[2]d
+				mimir_continuous_test_query_result_checks_failed_total{query_type="range",read_path="query_api",storage="default",test="write-read-series"} %[3]d
+			`, testData.expectedRangeQueries+testData.expectedInstantQueries, testData.expectedInstantQueries, testData.expectedRangeQueries)),
+				"mimir_continuous_test_queries_total",
+				"mimir_continuous_test_query_result_checks_total",
+				"mimir_continuous_test_query_result_checks_failed_total"))
+		})
+	}
+}
+
+func TestWriteReadSeriesTest_Run_QueryConcurrency(t *testing.T) {
+	t.Run("should fail if the query concurrency is not greater than 0", func(t *testing.T) {
+		cfg := WriteReadSeriesTestConfig{}
+		flagext.DefaultValues(&cfg)
+		cfg.QueryConcurrency = 0
+
+		_, err := NewWriteReadSeriesTest(cfg, &ClientMock{}, log.NewNopLogger(), nil)
+		require.Error(t, err)
+	})
+
+	t.Run("should run the queries concurrently up to the configured concurrency and check all results", func(t *testing.T) {
+		const queryConcurrency = 3
+
+		cfg := WriteReadSeriesTestConfig{}
+		flagext.DefaultValues(&cfg)
+		cfg.NumSeries = 2
+		cfg.QueryConcurrency = queryConcurrency
+
+		now := time.Unix(10*86400, 0)
+
+		var inflight, maxInflight atomic.Int64
+		trackInflight := func(mock.Arguments) {
+			current := inflight.Inc()
+			defer inflight.Dec()
+			for prev := maxInflight.Load(); current > prev && !maxInflight.CAS(prev, current); prev = maxInflight.Load() {
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		// The range queries return the expected sum, while the instant queries return an unexpected one.
+		test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(trackInflight).Return(model.Matrix{
+			{Values: []model.SamplePair{newSamplePair(now, generateSineWaveValue(now)*float64(cfg.NumSeries))}},
+		}, nil)
+		client.On("Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(trackInflight).Return(model.Vector{
+			{Timestamp: model.Time(now.UnixMilli()), Value: 12345},
+		}, nil)
+
+		test.lastWrittenTimestamp = now
+		test.queryMinTime = now
+		test.queryMaxTime = now
+
+		err := test.Run(context.Background(), now)
+		require.Error(t, err)
+		assert.Equal(t, 4, strings.Count(err.Error(), "instant query result check failed"))
+		assert.NotContains(t, err.Error(), "range query result check failed")
+
+		client.AssertNumberOfCalls(t, "QueryRange", 4)
+		client.AssertNumberOfCalls(t, "Query", 4)
+		assert.LessOrEqual(t, maxInflight.Load(), int64(queryConcurrency))
+
+		assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+			# HELP mimir_continuous_test_queries_total Total number of attempted query requests.
+			# TYPE mimir_continuous_test_queries_total counter
+			mimir_continuous_test_queries_total{test="write-read-series"} 8
+
+			# HELP mimir_continuous_test_query_result_checks_total Total number of query results checked for correctness.
+			# TYPE mimir_continuous_test_query_result_checks_total counter
+			mimir_continuous_test_query_result_checks_total{query_type="instant",read_path="query_api",storage="default",test="write-read-series"} 4
+			mimir_continuous_test_query_result_checks_total{query_type="range",read_path="query_api",storage="default",test="write-read-series"} 4
+
+			# HELP mimir_continuous_test_query_result_checks_failed_total Total number of query results failed when checking for correctness.
+			# TYPE mimir_continuous_test_query_result_checks_failed_total counter
+			mimir_continuous_test_query_result_checks_failed_total{query_type="instant",read_path="query_api",storage="default",test="write-read-series"} 4
+			mimir_continuous_test_query_result_checks_failed_total{query_type="range",read_path="query_api",storage="default",test="write-read-series"} 0
+
+			# HELP mimir_continuous_test_last_check_success Whether the query results of the written metric have been successfully checked by the last run (1) or not (0).
+			# TYPE mimir_continuous_test_last_check_success gauge
+			mimir_continuous_test_last_check_success{metric_name="mimir_continuous_test_sine_wave",query_type="instant",test="write-read-series"} 0
+			mimir_continuous_test_last_check_success{metric_name="mimir_continuous_test_sine_wave",query_type="range",test="write-read-series"} 1
+		`), "mimir_continuous_test_queries_total", "mimir_continuous_test_query_result_checks_total", "mimir_continuous_test_query_result_checks_failed_total", "mimir_continuous_test_last_check_success"))
+	})
+}
+
+func TestWriteReadSeriesTest_Run_CSVReport(t *testing.T) {
+	report := &bytes.Buffer{}
+
+	cfg := WriteReadSeriesTestConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.NumSeries = 2
+	cfg.CSVReportWriter = report
+
+	now := time.Unix(10*86400, 0).UTC()
+	clock := now
+	queryLatency := 2 * time.Second
+	advanceClock := func(mock.Arguments) { clock = clock.Add(queryLatency) }
+
+	test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+	client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil).Once()
+	client.On("WriteSeries", mock.Anything, mock.Anything).Return(500, errors.New("write failed")).Once()
+	client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(advanceClock).Return(model.Matrix{}, nil)
+	client.On("Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(advanceClock).Return(model.Vector{}, nil)
+
+	test.timeNow = func() time.Time { return clock }
+	test.lastWrittenTimestamp = now.Add(-cfg.WriteInterval)
+	test.queryMinTime = now.Add(-10 * time.Minute)
+	test.queryMaxTime = now.Add(-cfg.WriteInterval)
+
+	// Ignore the errors. They will be non-nil because the query mock does not return any data.
+	_ = test.Run(context.Background(), now)
+
+	queryLatency = 5 * time.Second
+	_ = test.Run(context.Background(), now.Add(cfg.WriteInterval))
+
+	rows, err := csv.NewReader(report).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+
+	assert.Equal(t, []string{"timestamp", "writes", "failures", "queries", "checks", "check_failures", "max_query_latency_seconds"}, rows[0])
+
+	// The first run successfully writes a sample, while all queries return no data so all checks fail.
+	assert.Equal(t, []string{now.Format(time.RFC3339), "1", "0", "8", "8", "8", "2"}, rows[1])
+
+	// The second run fails to write, so only the previous written samples are queried. The counters
+	// only account for the second run.
+	assert.Equal(t, []string{now.Add(cfg.WriteInterval).Format(time.RFC3339), "1", "1", "8", "8", "8", "5"}, rows[2])
+}
+
+func TestWriteReadSeriesTest_RunWithReport(t *testing.T) {
+	cfg := WriteReadSeriesTestConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.NumSeries = 2
+
+	now := time.Unix(10*86400, 0).UTC()
+
+	tests := map[string]struct {
+		writeStatusCode    int
+		writeErr           error
+		canceledCtx        bool
+		labelOrderCheck    bool
+		expectedWrites     map[string]RunReportWrites
+		expectedQueries    int
+		expectedChecks     int
+		expectedFirstError string
+	}{
+		"should report the writes, queries and checks of the run": {
+			writeStatusCode:    200,
+			expectedWrites:     map[string]RunReportWrites{"mimir_continuous_test_sine_wave": {Attempted: 1}},
+			expectedQueries:    8,
+			expectedChecks:     8,
+			expectedFirstError: "range query result check failed",
+		},
+		"should report the writes by metric name": {
+			writeStatusCode: 200,
+			labelOrderCheck: true,
+			expectedWrites: map[string]RunReportWrites{
+				"mimir_continuous_test_sine_wave":         {Attempted: 1},
+				"mimir_continuous_test_label_order_probe": {Attempted: 1},
+			},
+			expectedQueries:    9,
+			expectedChecks:     9,
+			expectedFirstError: "range query result check failed",
+		},
+		"should report the failed write as the first error": {
+			writeStatusCode:    500,
+			writeErr:           errors.New("write failed"),
+			expectedWrites:     map[string]RunReportWrites{"mimir_continuous_test_sine_wave": {Attempted: 1, Failed: 1}},
+			expectedQueries:    8,
+			expectedChecks:     8,
+			expectedFirstError: "failed to remote write series: write failed",
+		},
+		"should report the run interrupted before writing": {
+			canceledCtx:        true,
+			expectedWrites:     map[string]RunReportWrites{},
+			expectedFirstError: "context canceled",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			output := &bytes.Buffer{}
+			testCfg := cfg
+			testCfg.ReportWriter = output
+			testCfg.LabelOrderCheckEnabled = testData.labelOrderCheck
+
+			test, client, _ := newMockedWriteReadSeriesTest(t, testCfg)
+			client.On("WriteSeries", mock.Anything, mock.Anything).Return(testData.writeStatusCode, testData.writeErr)
+			client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{}, nil)
+			client.On("Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Vector{}, nil)
+
+			test.lastWrittenTimestamp = now.Add(-cfg.WriteInterval)
+			test.queryMinTime = now.Add(-10 * time.Minute)
+			test.queryMaxTime = now.Add(-cfg.WriteInterval)
+
+			ctx := context.Background()
+			if testData.canceledCtx {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithCancel(ctx)
+				cancel()
+			}
+
+			report, err := test.RunWithReport(ctx, now)
+			require.Error(t, err)
+
+			assert.Equal(t, now, report.Timestamp)
+			assert.Equal(t, testData.expectedWrites, report.Writes)
+			assert.Equal(t, testData.expectedQueries, report.QueriesAttempted)
+			assert.Equal(t, 0, report.QueriesFailed)
+			assert.Equal(t, 0, report.ChecksPassed)
+			assert.Equal(t, testData.expectedChecks, report.ChecksFailed)
+			assert.Contains(t, report.FirstError, testData.expectedFirstError)
+
+			// The same report is written as a single JSON line.
+			var written RunReport
+			require.NoError(t, json.Unmarshal(output.Bytes(), &written))
+			assert.Equal(t, report, written)
+			assert.Equal(t, 1, strings.Count(output.String(), "\n"))
+		})
+	}
+}
+
+func TestWriteReadSeriesTest_Run_CapturedResponses(t *testing.T) {
+	cfg := WriteReadSeriesTestConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.NumSeries = 2
+
+	// The fixtures contain the responses to the queries run at this timestamp.
+	now := time.Unix(1000, 0)
+
+	tests := map[string]struct {
+		rangeQueryFixture   string
+		instantQueryFixture string
+
+		// The number of range and instant query checks is the same.
+		expectedChecksPerQueryType       int
+		expectedFailedChecksPerQueryType int
+	}{
+		"float samples matching the written ones": {
+			rangeQueryFixture:          "query_range_sum_float.json",
+			instantQueryFixture:        "query_sum_float.json",
+			expectedChecksPerQueryType: 4,
+		},
+		"NaN samples": {
+			rangeQueryFixture:                "query_range_sum_nan.json",
+			instantQueryFixture:              "query_sum_nan.json",
+			expectedChecksPerQueryType:       4,
+			expectedFailedChecksPerQueryType: 4,
+		},
+		"native histogram samples": {
+			rangeQueryFixture:                "query_range_sum_histogram.json",
+			instantQueryFixture:              "query_sum_histogram.json",
+			expectedChecksPerQueryType:       4,
+			expectedFailedChecksPerQueryType: 4,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
+			client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(loadQueryResponseFixture(t, testData.rangeQueryFixture), nil)
+			client.On("Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(loadQueryResponseFixture(t, testData.instantQueryFixture), nil)
+
+			test.lastWrittenTimestamp = now
+			test.queryMinTime = now
+			test.queryMaxTime = now
+
+			err := test.Run(context.Background(), now)
+			if testData.expectedFailedChecksPerQueryType > 0 {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(fmt.Sprintf(`
+				# HELP mimir_continuous_test_query_result_checks_total Total number of query results checked for correctness.
+				# TYPE mimir_continuous_test_query_result_checks_total counter
+				mimir_continuous_test_query_result_checks_total{query_type="instant",read_path="query_api",storage="default",test="write-read-series"} %[1]d
+				mimir_continuous_test_query_result_checks_total{query_type="range",read_path="query_api",storage="default",test="write-read-series"} %[1]d
+
+				# HELP mimir_continuous_test_query_result_checks_failed_total Total number of query results failed when checking for correctness.
+				# TYPE mimir_continuous_test_query_result_checks_failed_total counter
+				mimir_continuous_test_query_result_checks_failed_total{query_type="instant",read_path="query_api",storage="default",test="write-read-series"} %[2]d
+				mimir_continuous_test_query_result_checks_failed_total{query_type="range",read_path="query_api",storage="default",test="write-read-series"} %[2]d
+			`, testData.expectedChecksPerQueryType, testData.expectedFailedChecksPerQueryType)),
+				"mimir_continuous_test_query_result_checks_total",
+				"mimir_continuous_test_query_result_checks_failed_total"))
+		})
+	}
+}
+
+func TestWriteReadSeriesTest_AdditionalChecks(t *testing.T) {
+	tests := map[string]struct {
+		setup func(cfg *WriteReadSeriesTestConfig)
+		run   func(t *testing.T, cfg WriteReadSeriesTestConfig)
+	}{
+		"left boundary": {
+			setup: func(cfg *WriteReadSeriesTestConfig) {
+				cfg.NumSeries = 2
+				cfg.LeftBoundaryCheckEnabled = true
+			},
+			run: func(t *testing.T, cfg WriteReadSeriesTestConfig) {
+				now := time.Unix(10*86400, 0)
+				lookbackTs := now.Add(-time.Hour)
+				start := lookbackTs.Add(defaultWriteInterval / 2)
+
+				tests := map[string]struct {
+					firstValue     float64
+					expectedFailed int
+				}{
+					"first point matches the sample preceding the range start": {
+						firstValue:     generateSineWaveValue(lookbackTs) * float64(cfg.NumSeries),
+						expectedFailed: 0,
+					},
+					"first point matches the sample following the range start": {
+						firstValue:     generateSineWaveValue(lookbackTs.Add(defaultWriteInterval)) * float64(cfg.NumSeries),
+						expectedFailed: 1,
+					},
+				}
+
+				for testName, testData := range tests {
+					t.Run(testName, func(t *testing.T) {
+						test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
+						client.On("QueryRange", mock.Anything, "sum(mimir_continuous_test_sine_wave)", start, now, defaultWriteInterval, mock.Anything).Return(model.Matrix{
+							{Values: []model.SamplePair{newSamplePair(start, testData.firstValue)}},
+						}, nil)
+
+						test.queryMinTime = now.Add(-2 * time.Hour)
+						test.queryMaxTime = now
+
+						err := test.runLeftBoundaryCheck(context.Background())
+						if testData.expectedFailed > 0 {
+							assert.Error(t, err)
+						} else {
+							assert.NoError(t, err)
+						}
+						client.AssertNumberOfCalls(t, "QueryRange", 1)
+
+						assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(fmt.Sprintf(`
+							# HELP mimir_continuous_test_additional_checks_total Total number of additional (opt-in) checks run.
+							# TYPE mimir_continuous_test_additional_checks_total counter
+							mimir_continuous_test_additional_checks_total{check="left_boundary",test="write-read-series"} 1
+
+							# HELP mimir_continuous_test_additional_checks_failed_total Total number of additional (opt-in) checks failed.
+							# TYPE mimir_continuous_test_additional_checks_failed_total counter
+							mimir_continuous_test_additional_checks_failed_total{check="left_boundary",test="write-read-series"} %d
+						`, testData.expectedFailed)), "mimir_continuous_test_additional_checks_total", "mimir_continuous_test_additional_checks_failed_total"))
+					})
+				}
+			},
+		},
+		"deep range": {
+			setup: func(cfg *WriteReadSeriesTestConfig) {
+				cfg.NumSeries = 2
+				cfg.MaxQueryAge = 3 * 24 * time.Hour
+				cfg.DeepRangeCheck = true
+			},
+			run: func(t *testing.T, cfg WriteReadSeriesTestConfig) {
+				now := time.Unix(10*86400, 0)
+
+				// The time range is split into 2 range queries, because it's larger than the max number of points.
+				firstStart := now.Add(-cfg.MaxQueryAge).Add(defaultWriteInterval)
+				firstEnd := firstStart.Add((maxRangeQueryPoints - 1) * defaultWriteInterval)
+				secondStart := firstEnd.Add(defaultWriteInterval)
+
+				tests := map[string]struct {
+					injectMismatch     bool
+					expectedMismatches int
+				}{
+					"all points match": {
+						injectMismatch:     false,
+						expectedMismatches: 0,
+					},
+					"a single point doesn't match": {
+						injectMismatch:     true,
+						expectedMismatches: 1,
+					},
+				}
+
+				for testName, testData := range tests {
+					t.Run(testName, func(t *testing.T) {
+						secondSamples := generateSineWaveSamplesSum(secondStart, now, cfg.NumSeries, defaultWriteInterval)
+						if testData.injectMismatch {
+							secondSamples[100].Value = 12345
+						}
+
+						test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
+						client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", firstStart, firstEnd, defaultWriteInterval, mock.Anything).Return(model.Matrix{
+							{Values: generateSineWaveSamplesSum(firstStart, firstEnd, cfg.NumSeries, defaultWriteInterval)},
+						}, nil)
+						client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", secondStart, now, defaultWriteInterval, mock.Anything).Return(model.Matrix{
+							{Values: secondSamples},
+						}, nil)
+
+						test.queryMinTime = now.Add(-4 * 24 * time.Hour)
+						test.queryMaxTime = now
+
+						err := test.runDeepRangeCheck(context.Background(), now)
+						if testData.expectedMismatches > 0 {
+							assert.Error(t, err)
+						} else {
+							assert.NoError(t, err)
+						}
+						client.AssertNumberOfCalls(t, "QueryRange", 2)
+
+						expectedFailed := 0
+						if testData.expectedMismatches > 0 {
+							expectedFailed = 1
+						}
+
+						assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(fmt.Sprintf(`
+							# HELP mimir_continuous_test_queries_total Total number of attempted query requests.
+							# TYPE mimir_continuous_test_queries_total counter
+							mimir_continuous_test_queries_total{test="write-read-series"} 2
+
+							# HELP mimir_continuous_test_additional_checks_total Total number of additional (opt-in) checks run.
+							# TYPE mimir_continuous_test_additional_checks_total counter
+							mimir_continuous_test_additional_checks_total{check="deep_range",test="write-read-series"} 1
+
+							# HELP mimir_continuous_test_additional_checks_failed_total Total number of additional (opt-in) checks failed.
+							# TYPE mimir_continuous_test_additional_checks_failed_total counter
+							mimir_continuous_test_additional_checks_failed_total{check="deep_range",test="write-read-series"} %d
+
+							# HELP mimir_continuous_test_deep_range_check_mismatched_points_total Total number of points missing or having an unexpected value in the deep range check.
+							# TYPE mimir_continuous_test_deep_range_check_mismatched_points_total counter
+							mimir_continuous_test_deep_range_check_mismatched_points_total{test="write-read-series"} %d
+						`, expectedFailed, testData.expectedMismatches)),
+							"mimir_continuous_test_queries_total", "mimir_continuous_test_additional_checks_total",
+							"mimir_continuous_test_additional_checks_failed_total", "mimir_continuous_test_deep_range_check_mismatched_points_total"))
+					})
+				}
+			},
+		},
+		"coarse step": {
+			setup: func(cfg *WriteReadSeriesTestConfig) {
+				cfg.NumSeries = 2
+				cfg.CoarseStepCheckFactor = 2
+			},
+			run: func(t *testing.T, cfg WriteReadSeriesTestConfig) {
+				now := time.Unix(10*86400, 0)
+				step := 2 * defaultWriteInterval
+
+				// The points fall between two written samples.
+				start := now.Add(-time.Hour).Add(defaultWriteInterval / 2)
+
+				// generatePoints returns the points of the range query, whose value is the sum of the samples written at
+				// the input offset from the point timestamp, aligned to the write interval.
+				generatePoints := func(offset time.Duration) []model.SamplePair {
+					var points []model.SamplePair
+					for ts := start; !ts.After(now); ts = ts.Add(step) {
+						value := generateSineWaveValue(alignTimestampToInterval(ts.Add(offset), defaultWriteInterval)) * float64(cfg.NumSeries)
+						points = append(points, model.SamplePair{Timestamp: model.Time(ts.UnixMilli()), Value: model.SampleValue(value)})
+					}
+					return points
+				}
+
+				tests := map[string]struct {
+					points             []model.SamplePair
+					expectedMismatches int
+				}{
+					"all points have the value of the most recent sample": {
+						points:             generatePoints(0),
+						expectedMismatches: 0,
+					},
+					"a point is missing": {
+						points:             generatePoints(0)[1:],
+						expectedMismatches: 1,
+					},
+					"a point has the value of the next sample": {
+						points:             append(append(generatePoints(0)[:10], generatePoints(defaultWriteInterval)[10]), generatePoints(0)[11:]...),
+						expectedMismatches: 1,
+					},
+				}
+
+				for testName, testData := range tests {
+					t.Run(testName, func(t *testing.T) {
+						test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
+						client.On("QueryRange", mock.Anything, "sum(mimir_continuous_test_sine_wave)", start, now, step, mock.Anything).Return(model.Matrix{{Values: testData.points}}, nil)
+
+						test.queryMinTime = now.Add(-2 * time.Hour)
+						test.queryMaxTime = now
+
+						err := test.runCoarseStepCheck(context.Background())
+						if testData.expectedMismatches > 0 {
+							assert.Error(t, err)
+						} else {
+							assert.NoError(t, err)
+						}
+						client.AssertNumberOfCalls(t, "QueryRange", 1)
+
+						expectedFailed := 0
+						if testData.expectedMismatches > 0 {
+							expectedFailed = 1
+						}
+
+						assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(fmt.Sprintf(`
+							# HELP mimir_continuous_test_additional_checks_total Total number of additional (opt-in) checks run.
+							# TYPE mimir_continuous_test_additional_checks_total counter
+							mimir_continuous_test_additional_checks_total{check="coarse_step",test="write-read-series"} 1
+
+							# HELP mimir_continuous_test_additional_checks_failed_total Total number of additional (opt-in) checks failed.
+							# TYPE mimir_continuous_test_additional_checks_failed_total counter
+							mimir_continuous_test_additional_checks_failed_total{check="coarse_step",test="write-read-series"} %d
+
+							# HELP mimir_continuous_test_coarse_step_check_mismatched_points_total Total number of points missing or not having the value of the most recent sample in the coarse step check.
+							# TYPE mimir_continuous_test_coarse_step_check_mismatched_points_total counter
+							mimir_continuous_test_coarse_step_check_mismatched_points_total{test="write-read-series"} %d
+						`, expectedFailed, testData.expectedMismatches)),
+							"mimir_continuous_test_additional_checks_total", "mimir_continuous_test_additional_checks_failed_total",
+							"mimir_continuous_test_coarse_step_check_mismatched_points_total"))
+					})
+				}
+			},
+		},
+		"out of order": {
+			setup: func(cfg *WriteReadSeriesTestConfig) {
+				cfg.OOOWindow = 10 * time.Minute
+			},
+			run: func(t *testing.T, cfg WriteReadSeriesTestConfig) {
+				now := time.Unix(10*86400, 0)
+				inOrderTs := now
+				outOfOrderTs := now.Add(-5 * time.Minute)
+
+				tests := map[string]struct {
+					outOfOrderWriteStatusCode int
+					outOfOrderWriteErr        error
+					queryResult               model.Vector
+					expectedQueries           int
+					expectedFailed            int
+				}{
+					"out-of-order sample is accepted and queryable": {
+						outOfOrderWriteStatusCode: 200,
+						queryResult:               model.Vector{{Timestamp: model.Time(outOfOrderTs.UnixMilli()), Value: model.SampleValue(generateSineWaveValue(outOfOrderTs))}},
+						expectedQueries:           1,
+						expectedFailed:            0,
+					},
+					"out-of-order sample is rejected": {
+						outOfOrderWriteStatusCode: 400,
+						outOfOrderWriteErr:        errors.New("out of order sample"),
+						expectedQueries:           0,
+						expectedFailed:            1,
+					},
+					"out-of-order sample is accepted but not queryable": {
+						outOfOrderWriteStatusCode: 200,
+						queryResult:               model.Vector{},
+						expectedQueries:           1,
+						expectedFailed:            1,
+					},
+				}
+
+				for testName, testData := range tests {
+					t.Run(testName, func(t *testing.T) {
+						test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
+						client.On("WriteSeries", mock.Anything, generateSineWaveSeries(outOfOrderProbeMetricName, inOrderTs, 1)).Return(200, nil)
+						client.On("WriteSeries", mock.Anything, generateSineWaveSeries(outOfOrderProbeMetricName, outOfOrderTs, 1)).Return(testData.outOfOrderWriteStatusCode, testData.outOfOrderWriteErr)
+						client.On("Query", mock.Anything, "max_over_time(mimir_continuous_test_out_of_order_probe[1s])", outOfOrderTs, mock.Anything).Return(testData.queryResult, nil)
+
+						err := test.runOutOfOrderCheck(context.Background(), now)
+						if testData.expectedFailed > 0 {
+							assert.Error(t, err)
+						} else {
+							assert.NoError(t, err)
+						}
+
+						client.AssertNumberOfCalls(t, "WriteSeries", 2)
+						client.AssertNumberOfCalls(t, "Query", testData.expectedQueries)
+
+						// The in-order sample must be written before the out-of-order one.
+						assert.Equal(t, generateSineWaveSeries(outOfOrderProbeMetricName, inOrderTs, 1), client.Calls[0].Arguments.Get(1))
+						assert.Equal(t, generateSineWaveSeries(outOfOrderProbeMetricName, outOfOrderTs, 1), client.Calls[1].Arguments.Get(1))
+
+						assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(fmt.Sprintf(`
+							# HELP mimir_continuous_test_additional_checks_total Total number of additional (opt-in) checks run.
+							# TYPE mimir_continuous_test_additional_checks_total counter
+							mimir_continuous_test_additional_checks_total{check="out_of_order",test="write-read-series"} 1
+
+							# HELP mimir_continuous_test_additional_checks_failed_total Total number of additional (opt-in) checks failed.
+							# TYPE mimir_continuous_test_additional_checks_failed_total counter
+							mimir_continuous_test_additional_checks_failed_total{check="out_of_order",test="write-read-series"} %d
+						`, testData.expectedFailed)), "mimir_continuous_test_additional_checks_total", "mimir_continuous_test_additional_checks_failed_total"))
+					})
+				}
+			},
+		},
+		"flush": {
+			setup: func(cfg *WriteReadSeriesTestConfig) {
+				cfg.NumSeries = 2
+				cfg.FlushCheckEnabled = true
+			},
+			run: func(t *testing.T, cfg WriteReadSeriesTestConfig) {
+				now := time.Unix(10*86400, 0)
+
+				tests := map[string]struct {
+					flushErr        error
+					queryResult     model.Matrix
+					expectedQueries int
+					expectedFailed  int
+				}{
+					"data is queryable after flush": {
+						queryResult:     model.Matrix{{Values: generateSineWaveSamplesSum(now.Add(-time.Hour), now, cfg.NumSeries, defaultWriteInterval)}},
+						expectedQueries: 1,
+						expectedFailed:  0,
+					},
+					"data is not queryable after flush": {
+						queryResult:     model.Matrix{},
+						expectedQueries: 1,
+						expectedFailed:  1,
+					},
+					"flush failed": {
+						flushErr:        errors.New("flush failed"),
+						expectedQueries: 0,
+						expectedFailed:  1,
+					},
+				}
+
+				for testName, testData := range tests {
+					t.Run(testName, func(t *testing.T) {
+						test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
+						client.On("Flush", mock.Anything).Return(testData.flushErr)
+						client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-time.Hour), now, defaultWriteInterval, mock.Anything).Return(testData.queryResult, nil)
+
+						test.queryMinTime = now.Add(-2 * time.Hour)
+						test.queryMaxTime = now
+
+						err := test.runFlushCheck(context.Background())
+						if testData.expectedFailed > 0 {
+							assert.Error(t, err)
+						} else {
+							assert.NoError(t, err)
+						}
+
+						// The query must run after the flush.
+						client.AssertNumberOfCalls(t, "Flush", 1)
+						client.AssertNumberOfCalls(t, "QueryRange", testData.expectedQueries)
+						assert.Equal(t, "Flush", client.Calls[0].Method)
+
+						assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(fmt.Sprintf(`
+							# HELP mimir_continuous_test_additional_checks_total Total number of additional (opt-in) checks run.
+							# TYPE mimir_continuous_test_additional_checks_total counter
+							mimir_continuous_test_additional_checks_total{check="flush",test="write-read-series"} 1
+
+							# HELP mimir_continuous_test_additional_checks_failed_total Total number of additional (opt-in) checks failed.
+							# TYPE mimir_continuous_test_additional_checks_failed_total counter
+							mimir_continuous_test_additional_checks_failed_total{check="flush",test="write-read-series"} %d
+						`, testData.expectedFailed)), "mimir_continuous_test_additional_checks_total", "mimir_continuous_test_additional_checks_failed_total"))
+					})
+				}
+			},
+		},
+		"burst consistency": {
+			setup: func(cfg *WriteReadSeriesTestConfig) {
+				cfg.NumSeries = 2
+				cfg.BurstIntervals = 3
+				cfg.BurstPollDeadline = 10 * time.Second
+			},
+			run: func(t *testing.T, cfg WriteReadSeriesTestConfig) {
+				now := time.Unix(1000, 0)
+				burstStart := time.Unix(960, 0)
+
+				partialResult := model.Matrix{{Values: generateSineWaveSamplesSum(burstStart, now.Add(-defaultWriteInterval), cfg.NumSeries, defaultWriteInterval)}}
+				completeResult := model.Matrix{{Values: generateSineWaveSamplesSum(burstStart, now, cfg.NumSeries, defaultWriteInterval)}}
+
+				tests := map[string]struct {
+					partialResults    int
+					expectedQueries   int
+					expectedErr       bool
+					expectedHistogram string
+				}{
+					"should track the time to consistency if samples are immediately queryable": {
+						partialResults:  0,
+						expectedQueries: 1,
+						expectedHistogram: `
+							mimir_continuous_test_burst_consistency_seconds_bucket{test="write-read-series",le="0.5"} 0
+							mimir_continuous_test_burst_consistency_seconds_bucket{test="write-read-series",le="1"} 0
+							mimir_continuous_test_burst_consistency_seconds_bucket{test="write-read-series",le="2"} 1
+							mimir_continuous_test_burst_consistency_seconds_bucket{test="write-read-series",le="4"} 1
+							mimir_continuous_test_burst_consistency_seconds_bucket{test="write-read-series",le="8"} 1
+							mimir_continuous_test_burst_consistency_seconds_bucket{test="write-read-series",le="16"} 1
+							mimir_continuous_test_burst_consistency_seconds_bucket{test="write-read-series",le="32"} 1
+							mimir_continuous_test_burst_consistency_seconds_bucket{test="write-read-series",le="64"} 1
+							mimir_continuous_test_burst_consistency_seconds_bucket{test="write-read-series",le="+Inf"} 1
+							mimir_continuous_test_burst_consistency_seconds_sum{test="write-read-series"} 2
+							mimir_continuous_test_burst_consistency_seconds_count{test="write-read-series"} 1
+						`,
+					},
+					"should track the time to consistency if samples are queryable after a few polls": {
+						partialResults:  2,
+						expectedQueries: 3,
+						expectedHistogram: `
+							mimir_continuous_test_burst_consistency_seconds_bucket{test="write-read-series",le="0.5"} 0
+							mimir_continuous_test_burst_consistency_seconds_bucket{test="write-read-series",le="1"} 0
+							mimir_continuous_test_burst_consistency_seconds_bucket{test="write-read-series",le="2"} 0
+							mimir_continuous_test_burst_consistency_seconds_bucket{test="write-read-series",le="4"} 0
+							mimir_continuous_test_burst_consistency_seconds_bucket{test="write-read-series",le="8"} 1
+							mimir_continuous_test_burst_consistency_seconds_bucket{test="write-read-series",le="16"} 1
+							mimir_continuous_test_burst_consistency_seconds_bucket{test="write-read-series",le="32"} 1
+							mimir_continuous_test_burst_consistency_seconds_bucket{test="write-read-series",le="64"} 1
+							mimir_continuous_test_burst_consistency_seconds_bucket{test="write-read-series",le="+Inf"} 1
+							mimir_continuous_test_burst_consistency_seconds_sum{test="write-read-series"} 6
+							mimir_continuous_test_burst_consistency_seconds_count{test="write-read-series"} 1
+						`,
+					},
+					"should fail if samples are not queryable within the deadline": {
+						partialResults:  10,
+						expectedQueries: 5,
+						expectedErr:     true,
+						expectedHistogram: `
+							mimir_continuous_test_burst_consistency_seconds_bucket{test="write-read-series",le="0.5"} 0
+							mimir_continuous_test_burst_consistency_seconds_bucket{test="write-read-series",le="1"} 0
+							mimir_continuous_test_burst_consistency_seconds_bucket{test="write-read-series",le="2"} 0
+							mimir_continuous_test_burst_consistency_seconds_bucket{test="write-read-series",le="4"} 0
+							mimir_continuous_test_burst_consistency_seconds_bucket{test="write-read-series",le="8"} 0
+							mimir_continuous_test_burst_consistency_seconds_bucket{test="write-read-series",le="16"} 0
+							mimir_continuous_test_burst_consistency_seconds_bucket{test="write-read-series",le="32"} 0
+							mimir_continuous_test_burst_consistency_seconds_bucket{test="write-read-series",le="64"} 0
+							mimir_continuous_test_burst_consistency_seconds_bucket{test="write-read-series",le="+Inf"} 0
+							mimir_continuous_test_burst_consistency_seconds_sum{test="write-read-series"} 0
+							mimir_continuous_test_burst_consistency_seconds_count{test="write-read-series"} 0
+						`,
+					},
+				}
+
+				for testName, testData := range tests {
+					t.Run(testName, func(t *testing.T) {
+						// Each query takes 2s.
+						clock := now
+						advanceClock := func(mock.Arguments) { clock = clock.Add(2 * time.Second) }
+
+						test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
+						client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+						if testData.partialResults > 0 {
+							client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", burstStart, now, defaultWriteInterval, mock.Anything).Run(advanceClock).Return(partialResult, nil).Times(testData.partialResults)
+						}
+						client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", burstStart, now, defaultWriteInterval, mock.Anything).Run(advanceClock).Return(completeResult, nil)
+
+						test.timeNow = func() time.Time { return clock }
+						test.burstPollInterval = 0
+						test.lastWrittenTimestamp = burstStart.Add(-defaultWriteInterval)
+
+						err := test.runBurstConsistencyCheck(context.Background(), now)
+						if testData.expectedErr {
+							assert.Error(t, err)
+						} else {
+							assert.NoError(t, err)
+						}
+
+						client.AssertNumberOfCalls(t, "WriteSeries", 3)
+						client.AssertCalled(t, "WriteSeries", mock.Anything, generateSineWaveSeries(metricName, burstStart, 2))
+						client.AssertCalled(t, "WriteSeries", mock.Anything, generateSineWaveSeries(metricName, burstStart.Add(defaultWriteInterval), 2))
+						client.AssertCalled(t, "WriteSeries", mock.Anything, generateSineWaveSeries(metricName, now, 2))
+						client.AssertNumberOfCalls(t, "QueryRange", testData.expectedQueries)
+
+						expectedFailed := 0
+						if testData.expectedErr {
+							expectedFailed = 1
+						}
+
+						assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(fmt.Sprintf(`
+							# HELP mimir_continuous_test_additional_checks_total Total number of additional (opt-in) checks run.
+							# TYPE mimir_continuous_test_additional_checks_total counter
+							mimir_continuous_test_additional_checks_total{check="burst_consistency",test="write-read-series"} 1
+
+							# HELP mimir_continuous_test_additional_checks_failed_total Total number of additional (opt-in) checks failed.
+							# TYPE mimir_continuous_test_additional_checks_failed_total counter
+							mimir_continuous_test_additional_checks_failed_total{check="burst_consistency",test="write-read-series"} %d
+
+							# HELP mimir_continuous_test_burst_consistency_seconds Time it takes for the samples written in a burst to be queryable.
+							# TYPE mimir_continuous_test_burst_consistency_seconds histogram
+						`, expectedFailed)+testData.expectedHistogram),
+							"mimir_continuous_test_additional_checks_total",
+							"mimir_continuous_test_additional_checks_failed_total",
+							"mimir_continuous_test_burst_consistency_seconds"))
+					})
+				}
+			},
+		},
+		"active series": {
+			setup: func(cfg *WriteReadSeriesTestConfig) {
+				cfg.NumSeries = 2
+				cfg.ActiveSeriesCheckEnabled = true
+			},
+			run: func(t *testing.T, cfg WriteReadSeriesTestConfig) {
+				t.Run("should fail on invalid config", func(t *testing.T) {
+					invalidCfg := cfg
+					invalidCfg.SeriesChurnRate = 0.5
+					_, err := NewWriteReadSeriesTest(invalidCfg, &ClientMock{}, log.NewNopLogger(), nil)
+					require.Error(t, err)
+
+					invalidCfg = cfg
+					invalidCfg.ActiveSeriesIdleTimeout = 0
+					_, err = NewWriteReadSeriesTest(invalidCfg, &ClientMock{}, log.NewNopLogger(), nil)
+					require.Error(t, err)
+				})
+
+				now := time.Unix(10*86400, 0)
+				matchers := []string{`__name__="mimir_continuous_test_sine_wave"`}
+
+				activeSeries := func(numSeries int) []model.LabelSet {
+					series := make([]model.LabelSet, 0, numSeries)
+					for i := 0; i < numSeries; i++ {
+						series = append(series, model.LabelSet{"__name__": "mimir_continuous_test_sine_wave", "series_id": model.LabelValue(fmt.Sprint(i))})
+					}
+					return series
+				}
+
+				tests := map[string]struct {
+					numSeriesChanges []numSeriesChange
+					queryMaxTime     time.Time
+					overcounts       int
+					expectedQueries  int
+					expectedErr      bool
+					expectedSkipped  bool
+				}{
+					"should pass if the number of active series immediately matches": {
+						expectedQueries: 1,
+					},
+					"should pass if the number of active series matches after a few polls": {
+						overcounts:      2,
+						expectedQueries: 3,
+					},
+					"should fail if the number of active series doesn't match within the deadline": {
+						overcounts:      10,
+						expectedQueries: 3,
+						expectedErr:     true,
+					},
+					"should skip the check if the number of series has changed within the idle timeout": {
+						numSeriesChanges: []numSeriesChange{{numSeries: 4}, {from: now.Add(-5 * time.Minute), numSeries: 2}},
+						expectedSkipped:  true,
+					},
+					"should run the check if the number of series has changed before the idle timeout": {
+						numSeriesChanges: []numSeriesChange{{numSeries: 4}, {from: now.Add(-15 * time.Minute), numSeries: 2}},
+						expectedQueries:  1,
+					},
+					"should skip the check if no series has been written within the idle timeout": {
+						queryMaxTime:    now.Add(-15 * time.Minute),
+						expectedSkipped: true,
+					},
+				}
+
+				for testName, testData := range tests {
+					t.Run(testName, func(t *testing.T) {
+						// Each query takes 20s.
+						clock := now
+						advanceClock := func(mock.Arguments) { clock = clock.Add(20 * time.Second) }
+
+						test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
+						if testData.overcounts > 0 {
+							client.On("ActiveSeries", mock.Anything, matchers).Run(advanceClock).Return(activeSeries(4), nil).Times(testData.overcounts)
+						}
+						client.On("ActiveSeries", mock.Anything, matchers).Run(advanceClock).Return(activeSeries(2), nil)
+
+						test.timeNow = func() time.Time { return clock }
+						test.activeSeriesPollInterval = 0
+						test.queryMinTime = now.Add(-time.Hour)
+						test.queryMaxTime = now
+						if !testData.queryMaxTime.IsZero() {
+							test.queryMaxTime = testData.queryMaxTime
+						}
+						if testData.numSeriesChanges != nil {
+							test.numSeriesChanges = testData.numSeriesChanges
+						}
+
+						err := test.runActiveSeriesCheck(context.Background(), now)
+						if testData.expectedErr {
+							assert.Error(t, err)
+						} else {
+							assert.NoError(t, err)
+						}
+						client.AssertNumberOfCalls(t, "ActiveSeries", testData.expectedQueries)
+
+						expectedMetrics := ""
+						if !testData.expectedSkipped {
+							expectedFailed := 0
+							if testData.expectedErr {
+								expectedFailed = 1
+							}
+
+							expectedMetrics = fmt.Sprintf(`
+								# HELP mimir_continuous_test_additional_checks_total Total number of additional (opt-in) checks run.
+								# TYPE mimir_continuous_test_additional_checks_total counter
+								mimir_continuous_test_additional_checks_total{check="active_series",test="write-read-series"} 1
+
+								# HELP mimir_continuous_test_additional_checks_failed_total Total number of additional (opt-in) checks failed.
+								# TYPE mimir_continuous_test_additional_checks_failed_total counter
+								mimir_continuous_test_additional_checks_failed_total{check="active_series",test="write-read-series"} %d
+							`, expectedFailed)
+						}
+
+						assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expectedMetrics),
+							"mimir_continuous_test_additional_checks_total",
+							"mimir_continuous_test_additional_checks_failed_total"))
+					})
+				}
+			},
+		},
+		"block path": {
+			setup: func(cfg *WriteReadSeriesTestConfig) {
+				cfg.NumSeries = 2
+				cfg.BlockPathCheckInterval = time.Hour
+				cfg.BlockPathPollDeadline = time.Minute
+			},
+			run: func(t *testing.T, cfg WriteReadSeriesTestConfig) {
+				t.Run("should fail on invalid config", func(t *testing.T) {
+					invalidCfg := cfg
+					invalidCfg.BlockPathCheckInterval = -time.Minute
+					_, err := NewWriteReadSeriesTest(invalidCfg, &ClientMock{}, log.NewNopLogger(), nil)
+					require.Error(t, err)
+
+					invalidCfg = cfg
+					invalidCfg.BlockPathPollDeadline = 0
+					_, err = NewWriteReadSeriesTest(invalidCfg, &ClientMock{}, log.NewNopLogger(), nil)
+					require.Error(t, err)
+				})
+
+				now := time.Unix(10*86400, 0)
+				flushedSamples := model.Matrix{{Values: generateSineWaveSamplesSum(now.Add(-10*time.Minute), now, cfg.NumSeries, defaultWriteInterval)}}
+
+				tests := map[string]struct {
+					flushErr        error
+					misses          int
+					expectedQueries int
+					expectedErr     bool
+				}{
+					"should pass if the flushed time range is immediately queryable": {
+						expectedQueries: 1,
+					},
+					"should pass if the flushed time range is queryable after a few polls": {
+						misses:          2,
+						expectedQueries: 3,
+					},
+					"should fail if the flushed time range is not queryable within the deadline": {
+						misses:          10,
+						expectedQueries: 3,
+						expectedErr:     true,
+					},
+					"should fail if the flush fails": {
+						flushErr:    errors.New("flush failed"),
+						expectedErr: true,
+					},
+				}
+
+				for testName, testData := range tests {
+					t.Run(testName, func(t *testing.T) {
+						// Each query takes 20s.
+						clock := now
+						advanceClock := func(mock.Arguments) { clock = clock.Add(20 * time.Second) }
+
+						test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
+						client.On("Flush", mock.Anything).Return(testData.flushErr)
+						if testData.misses > 0 {
+							client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-10*time.Minute), now, defaultWriteInterval, mock.Anything).Run(advanceClock).Return(model.Matrix{}, nil).Times(testData.misses)
+						}
+						client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-10*time.Minute), now, defaultWriteInterval, mock.Anything).Run(advanceClock).Return(flushedSamples, nil)
+
+						test.timeNow = func() time.Time { return clock }
+						test.blockPathPollInterval = 0
+						test.queryMinTime = now.Add(-10 * time.Minute)
+						test.queryMaxTime = now
+
+						err := test.runBlockPathCheck(context.Background(), now)
+						if testData.expectedErr {
+							assert.Error(t, err)
+						} else {
+							assert.NoError(t, err)
+						}
+						client.AssertNumberOfCalls(t, "Flush", 1)
+						client.AssertNumberOfCalls(t, "QueryRange", testData.expectedQueries)
+
+						expectedFailed := 0
+						if testData.expectedErr {
+							expectedFailed = 1
+						}
+						assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(fmt.Sprintf(`
+							# HELP mimir_continuous_test_additional_checks_total Total number of additional (opt-in) checks run.
+							# TYPE mimir_continuous_test_additional_checks_total counter
+							mimir_continuous_test_additional_checks_total{check="block_path",test="write-read-series"} 1
+
+							# HELP mimir_continuous_test_additional_checks_failed_total Total number of additional (opt-in) checks failed.
+							# TYPE mimir_continuous_test_additional_checks_failed_total counter
+							mimir_continuous_test_additional_checks_failed_total{check="block_path",test="write-read-series"} %d
+						`, expectedFailed)), "mimir_continuous_test_additional_checks_total", "mimir_continuous_test_additional_checks_failed_total"))
+					})
+				}
+
+				t.Run("should flush at the configured interval and only check the samples written since the previous flush", func(t *testing.T) {
+					test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+					client.On("Flush", mock.Anything).Return(nil)
+					client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(flushedSamples, nil).Once()
+
+					test.timeNow = func() time.Time { return now }
+					test.queryMinTime = now.Add(-10 * time.Minute)
+					test.queryMaxTime = now
+
+					require.NoError(t, test.runBlockPathCheck(context.Background(), now))
+					client.AssertNumberOfCalls(t, "Flush", 1)
+
+					// The check is not run again until the interval has elapsed.
+					test.queryMaxTime = now.Add(30 * time.Minute)
+					require.NoError(t, test.runBlockPathCheck(context.Background(), now.Add(30*time.Minute)))
+					client.AssertNumberOfCalls(t, "Flush", 1)
+
+					end := now.Add(time.Hour)
+					test.queryMaxTime = end
+					client.On("QueryRange", mock.Anything, mock.Anything, now.Add(defaultWriteInterval), end, mock.Anything, mock.Anything).Return(model.Matrix{{
+						Values: generateSineWaveSamplesSum(now.Add(defaultWriteInterval), end, cfg.NumSeries, defaultWriteInterval),
+					}}, nil).Once()
+
+					require.NoError(t, test.runBlockPathCheck(context.Background(), end))
+					client.AssertNumberOfCalls(t, "Flush", 2)
+					client.AssertNumberOfCalls(t, "QueryRange", 2)
+				})
+			},
+		},
+		"invalid step": {
+			setup: func(cfg *WriteReadSeriesTestConfig) {
+				cfg.NumSeries = 2
+				cfg.InvalidStepCheckEnabled = true
+			},
+			run: func(t *testing.T, cfg WriteReadSeriesTestConfig) {
+				now := time.Unix(10*86400, 0)
+				rejectedErr := &v1.Error{Type: v1.ErrBadData, Msg: "invalid parameter \"step\": zero or negative query resolution step widths are not accepted"}
+
+				tests := map[string]struct {
+					zeroStepErr           error
+					negativeStepErr       error
+					expectedErr           bool
+					expectedAccepted      int
+					expectedQueriesFailed int
+				}{
+					"should pass if the queries are rejected": {
+						zeroStepErr:     rejectedErr,
+						negativeStepErr: rejectedErr,
+					},
+					"should fail if the query with zero step is accepted": {
+						negativeStepErr:  rejectedErr,
+						expectedErr:      true,
+						expectedAccepted: 1,
+					},
+					"should fail if both queries are accepted": {
+						expectedErr:      true,
+						expectedAccepted: 2,
+					},
+					"should fail if a query fails with a 5xx error": {
+						zeroStepErr:           rejectedErr,
+						negativeStepErr:       &v1.Error{Type: v1.ErrServer, Msg: "server error: 500"},
+						expectedErr:           true,
+						expectedQueriesFailed: 1,
+					},
+				}
+
+				for testName, testData := range tests {
+					t.Run(testName, func(t *testing.T) {
+						test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
+						client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-time.Hour), now, time.Duration(0), mock.Anything).Return(model.Matrix{}, testData.zeroStepErr)
+						client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-time.Hour), now, -defaultWriteInterval, mock.Anything).Return(model.Matrix{}, testData.negativeStepErr)
+
+						err := test.runInvalidStepCheck(context.Background(), now)
+						if testData.expectedErr {
+							assert.Error(t, err)
+						} else {
+							assert.NoError(t, err)
+						}
+						client.AssertNumberOfCalls(t, "QueryRange", 2)
+
+						expectedFailed := 0
+						if testData.expectedErr {
+							expectedFailed = 1
+						}
+
+						assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(fmt.Sprintf(`
+							# HELP mimir_continuous_test_additional_checks_total Total number of additional (opt-in) checks run.
+							# TYPE mimir_continuous_test_additional_checks_total counter
+							mimir_continuous_test_additional_checks_total{check="invalid_step",test="write-read-series"} 1
+
+							# HELP mimir_continuous_test_additional_checks_failed_total Total number of additional (opt-in) checks failed.
+							# TYPE mimir_continuous_test_additional_checks_failed_total counter
+							mimir_continuous_test_additional_checks_failed_total{check="invalid_step",test="write-read-series"} %d
+
+							# HELP mimir_continuous_test_invalid_step_queries_accepted_total Total number of range queries with a zero or negative step, which have been unexpectedly accepted.
+							# TYPE mimir_continuous_test_invalid_step_queries_accepted_total counter
+							mimir_continuous_test_invalid_step_queries_accepted_total{test="write-read-series"} %d
+
+							# HELP mimir_continuous_test_queries_total Total number of attempted query requests.
+							# TYPE mimir_continuous_test_queries_total counter
+							mimir_continuous_test_queries_total{test="write-read-series"} 2
+						`, expectedFailed, testData.expectedAccepted)),
+							"mimir_continuous_test_additional_checks_total", "mimir_continuous_test_additional_checks_failed_total",
+							"mimir_continuous_test_invalid_step_queries_accepted_total", "mimir_continuous_test_queries_total"))
+
+						failedQueries, err := testutil.GatherAndCount(reg, "mimir_continuous_test_queries_failed_total")
+						require.NoError(t, err)
+						assert.Equal(t, testData.expectedQueriesFailed, failedQueries)
+					})
+				}
+			},
+		},
+		"duplicate sample": {
+			setup: func(cfg *WriteReadSeriesTestConfig) {
+				cfg.NumSeries = 2
+				cfg.DuplicateSampleCheckEnabled = true
+			},
+			run: func(t *testing.T, cfg WriteReadSeriesTestConfig) {
+				now := time.Unix(10*86400+150, 0)
+				original := generateSineWaveSeries("mimir_continuous_test_duplicate_sample_probe", now.Add(-10*time.Second), 1)
+				conflicting := generateSineWaveSeries("mimir_continuous_test_duplicate_sample_probe", now.Add(-10*time.Second), 1)
+				conflicting[0].Samples[0].Value++
+
+				tests := map[string]struct {
+					originalStatusCode    int
+					originalErr           error
+					conflictingStatusCode int
+					conflictingErr        error
+					expectedWrites        int
+					expectedErr           bool
+					expectedAccepted      int
+				}{
+					"should pass if the conflicting sample is rejected": {
+						originalStatusCode:    200,
+						conflictingStatusCode: 400,
+						conflictingErr:        errors.New("duplicate sample for timestamp"),
+						expectedWrites:        2,
+					},
+					"should fail if the conflicting sample is accepted": {
+						originalStatusCode:    200,
+						conflictingStatusCode: 200,
+						expectedWrites:        2,
+						expectedErr:           true,
+						expectedAccepted:      1,
+					},
+					"should fail if the conflicting sample write fails with a 5xx error": {
+						originalStatusCode:    200,
+						conflictingStatusCode: 500,
+						conflictingErr:        errors.New("server error"),
+						expectedWrites:        2,
+						expectedErr:           true,
+					},
+					"should fail if the original sample write fails": {
+						originalStatusCode: 500,
+						originalErr:        errors.New("server error"),
+						expectedWrites:     1,
+						expectedErr:        true,
+					},
+				}
+
+				for testName, testData := range tests {
+					t.Run(testName, func(t *testing.T) {
+						test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
+						client.On("WriteSeries", mock.Anything, original).Return(testData.originalStatusCode, testData.originalErr)
+						client.On("WriteSeries", mock.Anything, conflicting).Return(testData.conflictingStatusCode, testData.conflictingErr)
+
+						err := test.runDuplicateSampleCheck(context.Background(), now)
+						if testData.expectedErr {
+							assert.Error(t, err)
+						} else {
+							assert.NoError(t, err)
+						}
+						client.AssertNumberOfCalls(t, "WriteSeries", testData.expectedWrites)
+
+						expectedFailed := 0
+						if testData.expectedErr {
+							expectedFailed = 1
+						}
+
+						assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(fmt.Sprintf(`
+							# HELP mimir_continuous_test_additional_checks_total Total number of additional (opt-in) checks run.
+							# TYPE mimir_continuous_test_additional_checks_total counter
+							mimir_continuous_test_additional_checks_total{check="duplicate_sample",test="write-read-series"} 1
+
+							# HELP mimir_continuous_test_additional_checks_failed_total Total number of additional (opt-in) checks failed.
+							# TYPE mimir_continuous_test_additional_checks_failed_total counter
+							mimir_continuous_test_additional_checks_failed_total{check="duplicate_sample",test="write-read-series"} %d
+
+							# HELP mimir_continuous_test_duplicate_samples_accepted_total Total number of samples with the same timestamp but a different value of an already written sample, which have been unexpectedly accepted.
+							# TYPE mimir_continuous_test_duplicate_samples_accepted_total counter
+							mimir_continuous_test_duplicate_samples_accepted_total{test="write-read-series"} %d
+						`, expectedFailed, testData.expectedAccepted)),
+							"mimir_continuous_test_additional_checks_total",
+							"mimir_continuous_test_additional_checks_failed_total",
+							"mimir_continuous_test_duplicate_samples_accepted_total"))
+					})
+				}
+			},
+		},
+		"gap": {
+			setup: func(cfg *WriteReadSeriesTestConfig) {
+				cfg.NumSeries = 2
+				cfg.GapCheckEnabled = true
+			},
+			run: func(t *testing.T, cfg WriteReadSeriesTestConfig) {
+				now := time.Unix(10*86400+150, 0)
+				start, gap, end := now.Add(-50*time.Second), now.Add(-30*time.Second), now.Add(-10*time.Second)
+				lookbackQuery := "mimir_continuous_test_gap_probe"
+				rangeQuery := "count_over_time(mimir_continuous_test_gap_probe[10000ms])"
+
+				startValue, endValue := model.SampleValue(generateSineWaveValue(start)), model.SampleValue(generateSineWaveValue(end))
+				lookbackResult := model.Matrix{{Values: []model.SamplePair{
+					{Timestamp: model.Time(start.UnixMilli()), Value: startValue},
+					{Timestamp: model.Time(gap.UnixMilli()), Value: startValue},
+					{Timestamp: model.Time(end.UnixMilli()), Value: endValue},
+				}}}
+				rangeResult := model.Matrix{{Values: []model.SamplePair{
+					{Timestamp: model.Time(start.UnixMilli()), Value: 1},
+					{Timestamp: model.Time(end.UnixMilli()), Value: 1},
+				}}}
+
+				tests := map[string]struct {
+					writeStatusCode      int
+					lookbackResult       model.Matrix
+					rangeResult          model.Matrix
+					queryErr             error
+					expectedQueries      int
+					expectedErr          bool
+					expectedAnomalies    int
+					expectedFailedChecks int
+				}{
+					"should pass if the gap follows the PromQL semantics": {
+						writeStatusCode: 200,
+						lookbackResult:  lookbackResult,
+						rangeResult:     rangeResult,
+						expectedQueries: 2,
+					},
+					"should fail if the gap is not filled by the lookback": {
+						writeStatusCode:      200,
+						lookbackResult:       model.Matrix{{Values: []model.SamplePair{lookbackResult[0].Values[0], lookbackResult[0].Values[2]}}},
+						rangeResult:          rangeResult,
+						expectedQueries:      2,
+						expectedErr:          true,
+						expectedAnomalies:    1,
+						expectedFailedChecks: 1,
+					},
+					"should fail if the gap is filled with a value different than the preceding sample": {
+						writeStatusCode: 200,
+						lookbackResult: model.Matrix{{Values: []model.SamplePair{
+							lookbackResult[0].Values[0],
+							{Timestamp: model.Time(gap.UnixMilli()), Value: endValue},
+							lookbackResult[0].Values[2],
+						}}},
+						rangeResult:          rangeResult,
+						expectedQueries:      2,
+						expectedErr:          true,
+						expectedAnomalies:    1,
+						expectedFailedChecks: 1,
+					},
+					"should fail if a point is returned at the gap when selecting a range shorter than the write interval": {
+						writeStatusCode: 200,
+						lookbackResult:  lookbackResult,
+						rangeResult: model.Matrix{{Values: []model.SamplePair{
+							rangeResult[0].Values[0],
+							{Timestamp: model.Time(gap.UnixMilli()), Value: 1},
+							rangeResult[0].Values[1],
+						}}},
+						expectedQueries:      2,
+						expectedErr:          true,
+						expectedAnomalies:    1,
+						expectedFailedChecks: 1,
+					},
+					"should fail if the points surrounding the gap are missing": {
+						writeStatusCode:      200,
+						lookbackResult:       model.Matrix{},
+						rangeResult:          model.Matrix{},
+						expectedQueries:      2,
+						expectedErr:          true,
+						expectedAnomalies:    5,
+						expectedFailedChecks: 1,
+					},
+					"should fail if the write fails": {
+						writeStatusCode:      500,
+						expectedErr:          true,
+						expectedFailedChecks: 1,
+					},
+					"should not fail the check if the query fails": {
+						writeStatusCode: 200,
+						queryErr:        errors.New("failed"),
+						expectedQueries: 1,
+						expectedErr:     true,
+					},
+				}
+
+				for testName, testData := range tests {
+					t.Run(testName, func(t *testing.T) {
+						test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
+						client.On("WriteSeries", mock.Anything, mock.Anything).Return(testData.writeStatusCode, nil)
+						client.On("QueryRange", mock.Anything, lookbackQuery, start, end, 20*time.Second, mock.Anything).Return(testData.lookbackResult, testData.queryErr)
+						client.On("QueryRange", mock.Anything, rangeQuery, start, end, 20*time.Second, mock.Anything).Return(testData.rangeResult, testData.queryErr)
+
+						err := test.runGapCheck(context.Background(), now)
+						if testData.expectedErr {
+							assert.Error(t, err)
+						} else {
+							assert.NoError(t, err)
+						}
+						client.AssertNumberOfCalls(t, "QueryRange", testData.expectedQueries)
+
+						// Only the samples surrounding the gap are written.
+						var writtenTimestamps []int64
+						for _, call := range client.Calls {
+							if call.Method == "WriteSeries" {
+								writtenTimestamps = append(writtenTimestamps, call.Arguments.Get(1).([]prompb.TimeSeries)[0].Samples[0].Timestamp)
+							}
+						}
+						if testData.writeStatusCode == 200 {
+							assert.Equal(t, []int64{start.UnixMilli(), end.UnixMilli()}, writtenTimestamps)
+						}
+
+						assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(fmt.Sprintf(`
+							# HELP mimir_continuous_test_additional_checks_total Total number of additional (opt-in) checks run.
+							# TYPE mimir_continuous_test_additional_checks_total counter
+							mimir_continuous_test_additional_checks_total{check="gap",test="write-read-series"} 1
+
+							# HELP mimir_continuous_test_additional_checks_failed_total Total number of additional (opt-in) checks failed.
+							# TYPE mimir_continuous_test_additional_checks_failed_total counter
+							mimir_continuous_test_additional_checks_failed_total{check="gap",test="write-read-series"} %d
+
+							# HELP mimir_continuous_test_gap_check_anomalies_total Total number of points missing, unexpected or with an unexpected value in the range queries run by the gap check.
+							# TYPE mimir_continuous_test_gap_check_anomalies_total counter
+							mimir_continuous_test_gap_check_anomalies_total{test="write-read-series"} %d
+						`, testData.expectedFailedChecks, testData.expectedAnomalies)),
+							"mimir_continuous_test_additional_checks_total", "mimir_continuous_test_additional_checks_failed_total", "mimir_continuous_test_gap_check_anomalies_total"))
+					})
+				}
+
+				t.Run("should skip the check if the time range overlaps with the previous check", func(t *testing.T) {
+					test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+					client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+					client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{}, nil)
+
+					_ = test.runGapCheck(context.Background(), now)
+					client.AssertNumberOfCalls(t, "WriteSeries", 2)
+
+					// The previous check wrote a sample at the start of this check time range.
+					_ = test.runGapCheck(context.Background(), now.Add(40*time.Second))
+					client.AssertNumberOfCalls(t, "WriteSeries", 2)
+
+					_ = test.runGapCheck(context.Background(), now.Add(60*time.Second))
+					client.AssertNumberOfCalls(t, "WriteSeries", 4)
+				})
+			},
+		},
+		"staleness": {
+			setup: func(cfg *WriteReadSeriesTestConfig) {
+				cfg.NumSeries = 2
+				cfg.WithStaleness = true
+			},
+			run: func(t *testing.T, cfg WriteReadSeriesTestConfig) {
+				now := time.Unix(10*86400+150, 0)
+				start, end := now.Add(-30*time.Second), now.Add(-10*time.Second)
+				sumQuery := "sum(mimir_continuous_test_staleness_probe)"
+				selectorQuery := "mimir_continuous_test_staleness_probe"
+
+				expectedSum := model.SampleValue(2 * generateSineWaveValue(start))
+				notStaleSeries := &model.Sample{Metric: model.Metric{"__name__": "mimir_continuous_test_staleness_probe", "series_id": "0"}, Value: model.SampleValue(generateSineWaveValue(end))}
+				staleSeries := &model.Sample{Metric: model.Metric{"__name__": "mimir_continuous_test_staleness_probe", "series_id": "1"}, Value: model.SampleValue(generateSineWaveValue(start))}
+
+				tests := map[string]struct {
+					writeStatusCode      int
+					sumResult            model.Vector
+					selectorResult       model.Vector
+					queryErr             error
+					expectedQueries      int
+					expectedErr          bool
+					expectedFailedChecks int
+				}{
+					"should pass if the stale series is not returned at the staleness marker timestamp": {
+						writeStatusCode: 200,
+						sumResult:       model.Vector{{Value: expectedSum}},
+						selectorResult:  model.Vector{notStaleSeries},
+						expectedQueries: 2,
+					},
+					"should fail if the stale series is still returned at the staleness marker timestamp": {
+						writeStatusCode:      200,
+						sumResult:            model.Vector{{Value: expectedSum}},
+						selectorResult:       model.Vector{notStaleSeries, staleSeries},
+						expectedQueries:      2,
+						expectedErr:          true,
+						expectedFailedChecks: 1,
+					},
+					"should fail if the sum before the staleness marker doesn't match the expected one": {
+						writeStatusCode:      200,
+						sumResult:            model.Vector{{Value: expectedSum + 1}},
+						selectorResult:       model.Vector{notStaleSeries},
+						expectedQueries:      2,
+						expectedErr:          true,
+						expectedFailedChecks: 1,
+					},
+					"should fail if the write fails": {
+						writeStatusCode:      500,
+						expectedErr:          true,
+						expectedFailedChecks: 1,
+					},
+					"should not fail the check if the query fails": {
+						writeStatusCode: 200,
+						queryErr:        errors.New("failed"),
+						expectedQueries: 1,
+						expectedErr:     true,
+					},
+				}
+
+				for testName, testData := range tests {
+					t.Run(testName, func(t *testing.T) {
+						test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
+						client.On("WriteSeries", mock.Anything, mock.Anything).Return(testData.writeStatusCode, nil)
+						client.On("Query", mock.Anything, sumQuery, start, mock.Anything).Return(testData.sumResult, testData.queryErr)
+						client.On("Query", mock.Anything, selectorQuery, end, mock.Anything).Return(testData.selectorResult, testData.queryErr)
+
+						err := test.runStalenessCheck(context.Background(), now)
+						if testData.expectedErr {
+							assert.Error(t, err)
+						} else {
+							assert.NoError(t, err)
+						}
+						client.AssertNumberOfCalls(t, "Query", testData.expectedQueries)
+
+						// The staleness marker is written for the second series after the samples of both series.
+						if testData.writeStatusCode == 200 {
+							client.AssertNumberOfCalls(t, "WriteSeries", 2)
+							first := client.Calls[0].Arguments.Get(1).([]prompb.TimeSeries)
+							second := client.Calls[1].Arguments.Get(1).([]prompb.TimeSeries)
+							require.Len(t, first, 2)
+							require.Len(t, second, 2)
+							assert.Equal(t, start.UnixMilli(), first[1].Samples[0].Timestamp)
+							assert.False(t, value.IsStaleNaN(first[1].Samples[0].Value))
+							assert.Equal(t, end.UnixMilli(), second[1].Samples[0].Timestamp)
+							assert.False(t, value.IsStaleNaN(second[0].Samples[0].Value))
+							assert.True(t, value.IsStaleNaN(second[1].Samples[0].Value))
+						}
+
+						assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(fmt.Sprintf(`
+							# HELP mimir_continuous_test_additional_checks_total Total number of additional (opt-in) checks run.
+							# TYPE mimir_continuous_test_additional_checks_total counter
+							mimir_continuous_test_additional_checks_total{check="staleness",test="write-read-series"} 1
+
+							# HELP mimir_continuous_test_additional_checks_failed_total Total number of additional (opt-in) checks failed.
+							# TYPE mimir_continuous_test_additional_checks_failed_total counter
+							mimir_continuous_test_additional_checks_failed_total{check="staleness",test="write-read-series"} %d
+						`, testData.expectedFailedChecks)),
+							"mimir_continuous_test_additional_checks_total", "mimir_continuous_test_additional_checks_failed_total"))
+					})
+				}
+
+				t.Run("should skip the check if the time range overlaps with the previous check", func(t *testing.T) {
+					test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+					client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+					client.On("Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Vector{}, nil)
+
+					_ = test.runStalenessCheck(context.Background(), now)
+					client.AssertNumberOfCalls(t, "WriteSeries", 2)
+
+					// The previous check wrote the staleness marker at the start of this check time range.
+					_ = test.runStalenessCheck(context.Background(), now.Add(20*time.Second))
+					client.AssertNumberOfCalls(t, "WriteSeries", 2)
+
+					_ = test.runStalenessCheck(context.Background(), now.Add(40*time.Second))
+					client.AssertNumberOfCalls(t, "WriteSeries", 4)
+				})
+			},
+		},
+		"metadata": {
+			setup: func(cfg *WriteReadSeriesTestConfig) {
+				cfg.NumSeries = 2
+				cfg.WithMetadata = true
+			},
+			run: func(t *testing.T, cfg WriteReadSeriesTestConfig) {
+				const help = "Series written by mimir-continuous-test to check the query results."
+
+				tests := map[string]struct {
+					waveShape            string
+					writeStatusCode      int
+					result               []v1.Metadata
+					queryErr             error
+					expectedMetadata     prompb.MetricMetadata
+					expectedErr          bool
+					expectedFailedChecks int
+				}{
+					"should pass if the written metadata is returned": {
+						writeStatusCode:  200,
+						result:           []v1.Metadata{{Type: v1.MetricTypeGauge, Help: help}},
+						expectedMetadata: prompb.MetricMetadata{Type: prompb.MetricMetadata_GAUGE, MetricFamilyName: "mimir_continuous_test_sine_wave", Help: help},
+					},
+					"should pass if the written metadata is returned among other metadata": {
+						writeStatusCode:  200,
+						result:           []v1.Metadata{{Type: v1.MetricTypeGauge, Help: "other"}, {Type: v1.MetricTypeGauge, Help: help}},
+						expectedMetadata: prompb.MetricMetadata{Type: prompb.MetricMetadata_GAUGE, MetricFamilyName: "mimir_continuous_test_sine_wave", Help: help},
+					},
+					"should write the counter type for the counter wave shape": {
+						waveShape:        waveShapeCounter,
+						writeStatusCode:  200,
+						result:           []v1.Metadata{{Type: v1.MetricTypeCounter, Help: help}},
+						expectedMetadata: prompb.MetricMetadata{Type: prompb.MetricMetadata_COUNTER, MetricFamilyName: "mimir_continuous_test_sine_wave", Help: help},
+					},
+					"should fail if the metadata has been lost": {
+						writeStatusCode:      200,
+						result:               []v1.Metadata{},
+						expectedMetadata:     prompb.MetricMetadata{Type: prompb.MetricMetadata_GAUGE, MetricFamilyName: "mimir_continuous_test_sine_wave", Help: help},
+						expectedErr:          true,
+						expectedFailedChecks: 1,
+					},
+					"should fail if the returned metadata doesn't match the written one": {
+						writeStatusCode:      200,
+						result:               []v1.Metadata{{Type: v1.MetricTypeCounter, Help: help}},
+						expectedMetadata:     prompb.MetricMetadata{Type: prompb.MetricMetadata_GAUGE, MetricFamilyName: "mimir_continuous_test_sine_wave", Help: help},
+						expectedErr:          true,
+						expectedFailedChecks: 1,
+					},
+					"should fail if the write fails": {
+						writeStatusCode:      500,
+						expectedMetadata:     prompb.MetricMetadata{Type: prompb.MetricMetadata_GAUGE, MetricFamilyName: "mimir_continuous_test_sine_wave", Help: help},
+						expectedErr:          true,
+						expectedFailedChecks: 1,
+					},
+					"should not fail the check if the query fails": {
+						writeStatusCode:  200,
+						queryErr:         errors.New("failed"),
+						expectedMetadata: prompb.MetricMetadata{Type: prompb.MetricMetadata_GAUGE, MetricFamilyName: "mimir_continuous_test_sine_wave", Help: help},
+						expectedErr:      true,
+					},
+				}
+
+				for testName, testData := range tests {
+					t.Run(testName, func(t *testing.T) {
+						cfg := cfg
+						if testData.waveShape != "" {
+							cfg.WaveShape = testData.waveShape
+						}
+
+						test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
+						client.On("WriteMetadata", mock.Anything, []prompb.MetricMetadata{testData.expectedMetadata}).Return(testData.writeStatusCode, nil)
+						client.On("Metadata", mock.Anything, testData.expectedMetadata.MetricFamilyName).Return(testData.result, testData.queryErr)
+
+						err := test.runMetadataCheck(context.Background())
+						if testData.expectedErr {
+							assert.Error(t, err)
+						} else {
+							assert.NoError(t, err)
+						}
+
+						assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(fmt.Sprintf(`
+							# HELP mimir_continuous_test_additional_checks_total Total number of additional (opt-in) checks run.
+							# TYPE mimir_continuous_test_additional_checks_total counter
+							mimir_continuous_test_additional_checks_total{check="metadata",test="write-read-series"} 1
+
+							# HELP mimir_continuous_test_additional_checks_failed_total Total number of additional (opt-in) checks failed.
+							# TYPE mimir_continuous_test_additional_checks_failed_total counter
+							mimir_continuous_test_additional_checks_failed_total{check="metadata",test="write-read-series"} %d
+						`, testData.expectedFailedChecks)),
+							"mimir_continuous_test_additional_checks_total", "mimir_continuous_test_additional_checks_failed_total"))
+					})
+				}
+			},
+		},
+		"NaN": {
+			setup: func(cfg *WriteReadSeriesTestConfig) {
+				cfg.NumSeries = 2
+				cfg.WithNaN = true
+			},
+			run: func(t *testing.T, cfg WriteReadSeriesTestConfig) {
+				now := time.Unix(10*86400+150, 0)
+				ts := now.Add(-10 * time.Second)
+				query := "sum(max_over_time(mimir_continuous_test_nan_probe[1s]))"
+
+				tests := map[string]struct {
+					writeStatusCode      int
+					result               model.Vector
+					queryErr             error
+					expectedErr          bool
+					expectedFailedChecks int
+				}{
+					"should pass if the sum is NaN": {
+						writeStatusCode: 200,
+						result:          model.Vector{{Value: model.SampleValue(math.NaN())}},
+					},
+					"should fail if the NaN sample has been dropped": {
+						writeStatusCode:      200,
+						result:               model.Vector{{Value: model.SampleValue(generateSineWaveValue(ts))}},
+						expectedErr:          true,
+						expectedFailedChecks: 1,
+					},
+					"should fail if no point is returned": {
+						writeStatusCode:      200,
+						result:               model.Vector{},
+						expectedErr:          true,
+						expectedFailedChecks: 1,
+					},
+					"should fail if the write fails": {
+						writeStatusCode:      500,
+						expectedErr:          true,
+						expectedFailedChecks: 1,
+					},
+					"should not fail the check if the query fails": {
+						writeStatusCode: 200,
+						queryErr:        errors.New("failed"),
+						expectedErr:     true,
+					},
+				}
+
+				for testName, testData := range tests {
+					t.Run(testName, func(t *testing.T) {
+						test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
+						client.On("WriteSeries", mock.Anything, mock.Anything).Return(testData.writeStatusCode, nil)
+						client.On("Query", mock.Anything, query, ts, mock.Anything).Return(testData.result, testData.queryErr)
+
+						err := test.runNaNCheck(context.Background(), now)
+						if testData.expectedErr {
+							assert.Error(t, err)
+						} else {
+							assert.NoError(t, err)
+						}
+
+						// A genuine NaN is written, which is not a staleness marker.
+						written := client.Calls[0].Arguments.Get(1).([]prompb.TimeSeries)
+						require.Len(t, written, 2)
+						assert.True(t, math.IsNaN(written[0].Samples[0].Value))
+						assert.False(t, value.IsStaleNaN(written[0].Samples[0].Value))
+						assert.False(t, math.IsNaN(written[1].Samples[0].Value))
+
+						assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(fmt.Sprintf(`
+							# HELP mimir_continuous_test_additional_checks_total Total number of additional (opt-in) checks run.
+							# TYPE mimir_continuous_test_additional_checks_total counter
+							mimir_continuous_test_additional_checks_total{check="nan",test="write-read-series"} 1
+
+							# HELP mimir_continuous_test_additional_checks_failed_total Total number of additional (opt-in) checks failed.
+							# TYPE mimir_continuous_test_additional_checks_failed_total counter
+							mimir_continuous_test_additional_checks_failed_total{check="nan",test="write-read-series"} %d
+						`, testData.expectedFailedChecks)),
+							"mimir_continuous_test_additional_checks_total", "mimir_continuous_test_additional_checks_failed_total"))
+					})
+				}
+
+				t.Run("should skip the check if the samples have already been written", func(t *testing.T) {
+					test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+					client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+					client.On("Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Vector{}, nil)
+
+					_ = test.runNaNCheck(context.Background(), now)
+					_ = test.runNaNCheck(context.Background(), now.Add(5*time.Second))
+					client.AssertNumberOfCalls(t, "WriteSeries", 1)
+
+					_ = test.runNaNCheck(context.Background(), now.Add(20*time.Second))
+					client.AssertNumberOfCalls(t, "WriteSeries", 2)
+				})
+			},
+		},
+		"label order": {
+			setup: func(cfg *WriteReadSeriesTestConfig) {
+				cfg.NumSeries = 2
+				cfg.LabelOrderCheckEnabled = true
+			},
+			run: func(t *testing.T, cfg WriteReadSeriesTestConfig) {
+				now := time.Unix(10*86400+150, 0)
+				ts := now.Add(-10 * time.Second)
+				query := "count(max_over_time(mimir_continuous_test_label_order_probe[1s]))"
+				sample := prompb.Sample{Value: generateSineWaveValue(ts), Timestamp: ts.UnixMilli()}
+				expectedSeries := []prompb.TimeSeries{{
+					Labels:  []prompb.Label{{Name: "__name__", Value: "mimir_continuous_test_label_order_probe"}, {Name: "series_id", Value: "0"}},
+					Samples: []prompb.Sample{sample},
+				}, {
+					Labels:  []prompb.Label{{Name: "series_id", Value: "0"}, {Name: "__name__", Value: "mimir_continuous_test_label_order_probe"}},
+					Samples: []prompb.Sample{sample},
+				}}
+
+				tests := map[string]struct {
+					writeStatusCode         int
+					writeErr                error
+					queryResult             model.Vector
+					queryErr                error
+					expectedQueries         int
+					expectedErr             bool
+					expectedDuplicateSeries int
+					expectedFailedChecks    int
+				}{
+					"should pass if the series is stored once": {
+						writeStatusCode: 200,
+						queryResult:     model.Vector{{Timestamp: model.Time(ts.UnixMilli()), Value: 1}},
+						expectedQueries: 1,
+					},
+					"should fail if the series is stored twice": {
+						writeStatusCode:         200,
+						queryResult:             model.Vector{{Timestamp: model.Time(ts.UnixMilli()), Value: 2}},
+						expectedQueries:         1,
+						expectedErr:             true,
+						expectedDuplicateSeries: 1,
+						expectedFailedChecks:    1,
+					},
+					"should fail if the series is not queryable": {
+						writeStatusCode:      200,
+						queryResult:          model.Vector{},
+						expectedQueries:      1,
+						expectedErr:          true,
+						expectedFailedChecks: 1,
+					},
+					"should fail if the write fails": {
+						writeStatusCode:      500,
+						writeErr:             errors.New("server error"),
+						expectedErr:          true,
+						expectedFailedChecks: 1,
+					},
+					"should not fail the check if the query fails": {
+						writeStatusCode: 200,
+						queryErr:        errors.New("failed"),
+						expectedQueries: 1,
+						expectedErr:     true,
+					},
+				}
+
+				for testName, testData := range tests {
+					t.Run(testName, func(t *testing.T) {
+						test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
+						client.On("WriteSeries", mock.Anything, expectedSeries).Return(testData.writeStatusCode, testData.writeErr)
+						client.On("Query", mock.Anything, query, ts, mock.Anything).Return(testData.queryResult, testData.queryErr)
+
+						err := test.runLabelOrderCheck(context.Background(), now)
+						if testData.expectedErr {
+							assert.Error(t, err)
+						} else {
+							assert.NoError(t, err)
+						}
+						client.AssertNumberOfCalls(t, "WriteSeries", 1)
+						client.AssertNumberOfCalls(t, "Query", testData.expectedQueries)
+
+						assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(fmt.Sprintf(`
+							# HELP mimir_continuous_test_additional_checks_total Total number of additional (opt-in) checks run.
+							# TYPE mimir_continuous_test_additional_checks_total counter
+							mimir_continuous_test_additional_checks_total{check="label_order",test="write-read-series"} 1
+
+							# HELP mimir_continuous_test_additional_checks_failed_total Total number of additional (opt-in) checks failed.
+							# TYPE mimir_continuous_test_additional_checks_failed_total counter
+							mimir_continuous_test_additional_checks_failed_total{check="label_order",test="write-read-series"} %d
+
+							# HELP mimir_continuous_test_label_order_duplicate_series_total Total number of duplicate series unexpectedly stored when writing the same series with its labels in different orders in the label order check.
+							# TYPE mimir_continuous_test_label_order_duplicate_series_total counter
+							mimir_continuous_test_label_order_duplicate_series_total{test="write-read-series"} %d
+						`, testData.expectedFailedChecks, testData.expectedDuplicateSeries)),
+							"mimir_continuous_test_additional_checks_total",
+							"mimir_continuous_test_additional_checks_failed_total",
+							"mimir_continuous_test_label_order_duplicate_series_total"))
+					})
+				}
+			},
+		},
+		"histogram identity": {
+			setup: func(cfg *WriteReadSeriesTestConfig) {
+				cfg.NumSeries = 2
+				cfg.HistogramIdentityCheckEnabled = true
+			},
+			run: func(t *testing.T, cfg WriteReadSeriesTestConfig) {
+				now := time.Unix(10*86400+150, 0)
+				ts := now.Add(-10 * time.Second)
+				originalQuery := "last_over_time(mimir_continuous_test_histogram_probe[1s])"
+				identityQuery := originalQuery + " + 0 * " + originalQuery
+
+				histogram := func(count model.FloatString) *model.SampleHistogram {
+					return &model.SampleHistogram{
+						Count: count,
+						Sum:   18.4,
+						Buckets: model.HistogramBuckets{
+							{Boundaries: 3, Lower: -1, Upper: -0.001, Count: 2},
+							{Boundaries: 0, Lower: 1, Upper: 1.414, Count: 5},
+						},
+					}
+				}
+				sample := func(h *model.SampleHistogram) model.Vector {
+					return model.Vector{{Timestamp: model.Time(ts.UnixMilli()), Histogram: h}}
+				}
+
+				tests := map[string]struct {
+					writeStatusCode      int
+					writeErr             error
+					originalResult       model.Vector
+					identityResult       model.Vector
+					queryErr             error
+					expectedQueries      int
+					expectedErr          bool
+					expectedFailedChecks int
+				}{
+					"should pass if adding the histogram multiplied by 0 returns the original histogram": {
+						writeStatusCode: 200,
+						originalResult:  sample(histogram(9)),
+						identityResult:  sample(histogram(9)),
+						expectedQueries: 2,
+					},
+					"should fail if adding the histogram multiplied by 0 changes the histogram": {
+						writeStatusCode:      200,
+						originalResult:       sample(histogram(9)),
+						identityResult:       sample(histogram(18)),
+						expectedQueries:      2,
+						expectedErr:          true,
+						expectedFailedChecks: 1,
+					},
+					"should fail if adding the histogram multiplied by 0 returns no histogram": {
+						writeStatusCode:      200,
+						originalResult:       sample(histogram(9)),
+						identityResult:       model.Vector{},
+						expectedQueries:      2,
+						expectedErr:          true,
+						expectedFailedChecks: 1,
+					},
+					"should fail if the histogram is not queryable": {
+						writeStatusCode:      200,
+						originalResult:       model.Vector{},
+						identityResult:       model.Vector{},
+						expectedQueries:      2,
+						expectedErr:          true,
+						expectedFailedChecks: 1,
+					},
+					"should fail if the histogram is queried back as a float sample": {
+						writeStatusCode:      200,
+						originalResult:       model.Vector{{Timestamp: model.Time(ts.UnixMilli()), Value: 9}},
+						identityResult:       model.Vector{{Timestamp: model.Time(ts.UnixMilli()), Value: 9}},
+						expectedQueries:      2,
+						expectedErr:          true,
+						expectedFailedChecks: 1,
+					},
+					"should fail if the write fails": {
+						writeStatusCode:      500,
+						writeErr:             errors.New("server error"),
+						expectedErr:          true,
+						expectedFailedChecks: 1,
+					},
+					"should not fail the check if the query fails": {
+						writeStatusCode: 200,
+						queryErr:        errors.New("failed"),
+						expectedQueries: 1,
+						expectedErr:     true,
+					},
+				}
+
+				for testName, testData := range tests {
+					t.Run(testName, func(t *testing.T) {
+						test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
+						client.On("WriteSeries", mock.Anything, mock.Anything).Return(testData.writeStatusCode, testData.writeErr)
+						client.On("Query", mock.Anything, originalQuery, ts, mock.Anything).Return(testData.originalResult, testData.queryErr)
+						client.On("Query", mock.Anything, identityQuery, ts, mock.Anything).Return(testData.identityResult, testData.queryErr)
+
+						err := test.runHistogramIdentityCheck(context.Background(), now)
+						if testData.expectedErr {
+							assert.Error(t, err)
+						} else {
+							assert.NoError(t, err)
+						}
+						client.AssertNumberOfCalls(t, "WriteSeries", 1)
+						client.AssertNumberOfCalls(t, "Query", testData.expectedQueries)
+
+						// The probe is written as a single native histogram sample.
+						written := client.Calls[0].Arguments.Get(1).([]prompb.TimeSeries)
+						require.Len(t, written, 1)
+						assert.Equal(t, []prompb.Label{{Name: "__name__", Value: "mimir_continuous_test_histogram_probe"}}, written[0].Labels)
+						assert.Empty(t, written[0].Samples)
+						require.Len(t, written[0].Histograms, 1)
+						assert.Equal(t, ts.UnixMilli(), written[0].Histograms[0].Timestamp)
+
+						assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(fmt.Sprintf(`
+							# HELP mimir_continuous_test_additional_checks_total Total number of additional (opt-in) checks run.
+							# TYPE mimir_continuous_test_additional_checks_total counter
+							mimir_continuous_test_additional_checks_total{check="histogram_identity",test="write-read-series"} 1
+
+							# HELP mimir_continuous_test_additional_checks_failed_total Total number of additional (opt-in) checks failed.
+							# TYPE mimir_continuous_test_additional_checks_failed_total counter
+							mimir_continuous_test_additional_checks_failed_total{check="histogram_identity",test="write-read-series"} %d
+						`, testData.expectedFailedChecks)),
+							"mimir_continuous_test_additional_checks_total",
+							"mimir_continuous_test_additional_checks_failed_total"))
+					})
+				}
+			},
+		},
+		"lookback": {
+			setup: func(cfg *WriteReadSeriesTestConfig) {
+				cfg.NumSeries = 2
+				cfg.QueryLookback = time.Minute
+			},
+			run: func(t *testing.T, cfg WriteReadSeriesTestConfig) {
+				now := time.Unix(10*86400, 0)
+				lastSampleSum := func(lastSampleTs time.Time) model.Vector {
+					return model.Vector{{Timestamp: model.Time(now.UnixMilli()), Value: model.SampleValue(generateSineWaveValue(lastSampleTs) * float64(cfg.NumSeries))}}
+				}
+
+				tests := map[string]struct {
+					lastSampleTs   time.Time
+					queryResult    model.Vector
+					expectedFailed int
+				}{
+					"last sample within the lookback delta with matching result": {
+						lastSampleTs: now.Add(-20 * time.Second),
+						queryResult:  lastSampleSum(now.Add(-20 * time.Second)),
+					},
+					"last sample within the lookback delta with empty result": {
+						lastSampleTs:   now.Add(-20 * time.Second),
+						queryResult:    model.Vector{},
+						expectedFailed: 1,
+					},
+					"last sample within the lookback delta with mismatching result": {
+						lastSampleTs:   now.Add(-20 * time.Second),
+						queryResult:    lastSampleSum(now.Add(-40 * time.Second)),
+						expectedFailed: 1,
+					},
+					"query time in a gap longer than the lookback delta with empty result": {
+						lastSampleTs: now.Add(-5 * time.Minute),
+						queryResult:  model.Vector{},
+					},
+					"query time in a gap longer than the lookback delta with unexpected result": {
+						lastSampleTs:   now.Add(-5 * time.Minute),
+						queryResult:    lastSampleSum(now.Add(-5 * time.Minute)),
+						expectedFailed: 1,
+					},
+					"last sample exactly at the lookback delta with empty result": {
+						lastSampleTs: now.Add(-time.Minute),
+						queryResult:  model.Vector{},
+					},
+				}
+
+				for testName, testData := range tests {
+					t.Run(testName, func(t *testing.T) {
+						test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
+						client.On("Query", mock.Anything, "sum(mimir_continuous_test_sine_wave)", now, mock.Anything).Return(testData.queryResult, nil)
+
+						test.queryMinTime = now.Add(-time.Hour)
+						test.queryMaxTime = testData.lastSampleTs
+
+						err := test.runLookbackCheck(context.Background(), now)
+						if testData.expectedFailed > 0 {
+							assert.Error(t, err)
+						} else {
+							assert.NoError(t, err)
+						}
+						client.AssertNumberOfCalls(t, "Query", 1)
+
+						// The lookback delta is sent with the query.
+						options := &requestOptions{}
+						for _, option := range client.Calls[0].Arguments.Get(3).([]RequestOption) {
+							option(options)
+						}
+						assert.Equal(t, time.Minute, options.lookbackDelta)
+
+						assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(fmt.Sprintf(`
+							# HELP mimir_continuous_test_additional_checks_total Total number of additional (opt-in) checks run.
+							# TYPE mimir_continuous_test_additional_checks_total counter
+							mimir_continuous_test_additional_checks_total{check="lookback",test="write-read-series"} 1
+
+							# HELP mimir_continuous_test_additional_checks_failed_total Total number of additional (opt-in) checks failed.
+							# TYPE mimir_continuous_test_additional_checks_failed_total counter
+							mimir_continuous_test_additional_checks_failed_total{check="lookback",test="write-read-series"} %d
+						`, testData.expectedFailed)), "mimir_continuous_test_additional_checks_total", "mimir_continuous_test_additional_checks_failed_total"))
+					})
+				}
+
+				t.Run("should be skipped if the number of series has changed within the lookback delta", func(t *testing.T) {
+					test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+
+					test.queryMinTime = now.Add(-time.Hour)
+					test.queryMaxTime = now.Add(-20 * time.Second)
+					test.numSeriesChanges = []numSeriesChange{{numSeries: 2}, {from: now.Add(-40 * time.Second), numSeries: 1}}
+
+					require.NoError(t, test.runLookbackCheck(context.Background(), now))
+					client.AssertNotCalled(t, "Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+				})
+
+				t.Run("should fail to create the test if the series churn is enabled", func(t *testing.T) {
+					invalidCfg := cfg
+					invalidCfg.SeriesChurnRate = 0.5
+					_, err := NewWriteReadSeriesTest(invalidCfg, &ClientMock{}, log.NewNopLogger(), nil)
+					require.Error(t, err)
+				})
+			},
+		},
+		"sum over time": {
+			setup: func(cfg *WriteReadSeriesTestConfig) {
+				cfg.NumSeries = 2
+				cfg.SumOverTimeCheckWindow = 10 * time.Minute
+			},
+			run: func(t *testing.T, cfg WriteReadSeriesTestConfig) {
+				now := time.Unix(10*86400, 0)
+				fullWindowSum := generateValuesSum(now.Add(-10*time.Minute), now, defaultWriteInterval, cfg.NumSeries, generateSineWaveValue)
+				gappyWindowSum := generateValuesSum(now.Add(-4*time.Minute), now, defaultWriteInterval, cfg.NumSeries, generateSineWaveValue)
+
+				tests := map[string]struct {
+					queryMinTime   time.Time
+					queryResult    float64
+					expectedFailed int
+				}{
+					"complete window with matching result": {
+						queryMinTime:   now.Add(-time.Hour),
+						queryResult:    fullWindowSum,
+						expectedFailed: 0,
+					},
+					"complete window with a missing sample": {
+						queryMinTime:   now.Add(-time.Hour),
+						queryResult:    fullWindowSum - generateSineWaveValue(now.Add(-defaultWriteInterval*3))*float64(cfg.NumSeries),
+						expectedFailed: 1,
+					},
+					"gappy window with matching result": {
+						queryMinTime:   now.Add(-4 * time.Minute),
+						queryResult:    gappyWindowSum,
+						expectedFailed: 0,
+					},
+					"gappy window with unexpected samples before the first written one": {
+						queryMinTime:   now.Add(-4 * time.Minute),
+						queryResult:    fullWindowSum,
+						expectedFailed: 1,
+					},
+				}
+
+				for testName, testData := range tests {
+					t.Run(testName, func(t *testing.T) {
+						test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
+						client.On("Query", mock.Anything, "sum(sum_over_time(mimir_continuous_test_sine_wave[10m]))", now, mock.Anything).Return(model.Vector{
+							{Timestamp: model.Time(now.UnixMilli()), Value: model.SampleValue(testData.queryResult)},
+						}, nil)
+
+						test.queryMinTime = testData.queryMinTime
+						test.queryMaxTime = now
+
+						err := test.runSumOverTimeCheck(context.Background())
+						if testData.expectedFailed > 0 {
+							assert.Error(t, err)
+						} else {
+							assert.NoError(t, err)
+						}
+						client.AssertNumberOfCalls(t, "Query", 1)
+
+						assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(fmt.Sprintf(`
+							# HELP mimir_continuous_test_additional_checks_total Total number of additional (opt-in) checks run.
+							# TYPE mimir_continuous_test_additional_checks_total counter
+							mimir_continuous_test_additional_checks_total{check="sum_over_time",test="write-read-series"} 1
+
+							# HELP mimir_continuous_test_additional_checks_failed_total Total number of additional (opt-in) checks failed.
+							# TYPE mimir_continuous_test_additional_checks_failed_total counter
+							mimir_continuous_test_additional_checks_failed_total{check="sum_over_time",test="write-read-series"} %d
+						`, testData.expectedFailed)), "mimir_continuous_test_additional_checks_total", "mimir_continuous_test_additional_checks_failed_total"))
+					})
+				}
+			},
+		},
+		"min max over time": {
+			setup: func(cfg *WriteReadSeriesTestConfig) {
+				cfg.NumSeries = 2
+				cfg.MinMaxOverTimeCheckWindow = 5 * time.Minute
+			},
+			run: func(t *testing.T, cfg WriteReadSeriesTestConfig) {
+				// The 5m window ending at now covers the negative half of the sine wave period, which reaches its min in
+				// the middle of the window, while the 2m window ending at now only covers the increasing part of it.
+				now := time.Unix(10*86400, 0)
+				fullWindowMin, fullWindowMax, _ := generateValuesMinMax(now.Add(-5*time.Minute), now, defaultWriteInterval, generateSineWaveValue)
+				gappyWindowMin, gappyWindowMax, _ := generateValuesMinMax(now.Add(-2*time.Minute), now, defaultWriteInterval, generateSineWaveValue)
+				require.NotEqual(t, fullWindowMin, gappyWindowMin)
+
+				tests := map[string]struct {
+					queryMinTime    time.Time
+					minResult       float64
+					maxResult       float64
+					expectedChecks  int
+					expectedFailed  int
+					expectedQueries int
+				}{
+					"complete window with matching results": {
+						queryMinTime:    now.Add(-time.Hour),
+						minResult:       fullWindowMin,
+						maxResult:       fullWindowMax,
+						expectedChecks:  2,
+						expectedQueries: 2,
+					},
+					"complete window with mismatching min": {
+						queryMinTime:    now.Add(-time.Hour),
+						minResult:       -1.5,
+						maxResult:       fullWindowMax,
+						expectedChecks:  1,
+						expectedFailed:  1,
+						expectedQueries: 1,
+					},
+					"complete window with mismatching max": {
+						queryMinTime:    now.Add(-time.Hour),
+						minResult:       fullWindowMin,
+						maxResult:       1.5,
+						expectedChecks:  2,
+						expectedFailed:  1,
+						expectedQueries: 2,
+					},
+					"gappy window with matching results": {
+						queryMinTime:    now.Add(-2 * time.Minute),
+						minResult:       gappyWindowMin,
+						maxResult:       gappyWindowMax,
+						expectedChecks:  2,
+						expectedQueries: 2,
+					},
+					"gappy window with unexpected samples before the first written one": {
+						queryMinTime:    now.Add(-2 * time.Minute),
+						minResult:       fullWindowMin,
+						maxResult:       fullWindowMax,
+						expectedChecks:  1,
+						expectedFailed:  1,
+						expectedQueries: 1,
+					},
+				}
+
+				for testName, testData := range tests {
+					t.Run(testName, func(t *testing.T) {
+						test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
+						client.On("Query", mock.Anything, "min(min_over_time(mimir_continuous_test_sine_wave[5m]))", now, mock.Anything).Return(model.Vector{
+							{Timestamp: model.Time(now.UnixMilli()), Value: model.SampleValue(testData.minResult)},
+						}, nil)
+						client.On("Query", mock.Anything, "max(max_over_time(mimir_continuous_test_sine_wave[5m]))", now, mock.Anything).Return(model.Vector{
+							{Timestamp: model.Time(now.UnixMilli()), Value: model.SampleValue(testData.maxResult)},
+						}, nil)
+
+						test.queryMinTime = testData.queryMinTime
+						test.queryMaxTime = now
+
+						err := test.runMinMaxOverTimeCheck(context.Background())
+						if testData.expectedFailed > 0 {
+							assert.Error(t, err)
+						} else {
+							assert.NoError(t, err)
+						}
+						client.AssertNumberOfCalls(t, "Query", testData.expectedQueries)
+
+						assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(fmt.Sprintf(`
+							# HELP mimir_continuous_test_additional_checks_total Total number of additional (opt-in) checks run.
+							# TYPE mimir_continuous_test_additional_checks_total counter
+							mimir_continuous_test_additional_checks_total{check="min_max_over_time",test="write-read-series"} %d
+
+							# HELP mimir_continuous_test_additional_checks_failed_total Total number of additional (opt-in) checks failed.
+							# TYPE mimir_continuous_test_additional_checks_failed_total counter
+							mimir_continuous_test_additional_checks_failed_total{check="min_max_over_time",test="write-read-series"} %d
+						`, testData.expectedChecks, testData.expectedFailed)), "mimir_continuous_test_additional_checks_total", "mimir_continuous_test_additional_checks_failed_total"))
+					})
+				}
+			},
+		},
+		"counter rate": {
+			setup: func(cfg *WriteReadSeriesTestConfig) {
+				cfg.NumSeries = 2
+				cfg.WaveShape = waveShapeCounter
+			},
+			run: func(t *testing.T, cfg WriteReadSeriesTestConfig) {
+				now := time.Unix(10*86400, 0)
+
+				tests := map[string]struct {
+					queryMinTime    time.Time
+					rateOfSumResult model.Vector
+					expectedQueries int
+					expectedChecks  int
+					expectedFailed  int
+				}{
+					"should skip the check if samples have not been written over the whole range": {
+						queryMinTime:    now.Add(-4 * time.Minute),
+						expectedQueries: 0,
+					},
+					"should pass if the rate matches the expected slope": {
+						queryMinTime:    now.Add(-time.Hour),
+						rateOfSumResult: model.Vector{{Timestamp: model.Time(now.UnixMilli()), Value: 2}},
+						expectedQueries: 1,
+						expectedChecks:  1,
+					},
+					"should fail if the rate doesn't match the expected slope": {
+						queryMinTime:    now.Add(-time.Hour),
+						rateOfSumResult: model.Vector{{Timestamp: model.Time(now.UnixMilli()), Value: 1.9}},
+						expectedQueries: 1,
+						expectedChecks:  1,
+						expectedFailed:  1,
+					},
+					"should fail if the result is empty": {
+						queryMinTime:    now.Add(-time.Hour),
+						rateOfSumResult: model.Vector{},
+						expectedQueries: 1,
+						expectedChecks:  1,
+						expectedFailed:  1,
+					},
+				}
+
+				for testName, testData := range tests {
+					t.Run(testName, func(t *testing.T) {
+						test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
+						client.On("Query", mock.Anything, "rate(sum(mimir_continuous_test_sine_wave)[5m:20s])", now, mock.Anything).Return(testData.rateOfSumResult, nil)
+
+						test.queryMinTime = testData.queryMinTime
+						test.queryMaxTime = now
+
+						err := test.runCounterRateCheck(context.Background())
+						if testData.expectedFailed > 0 {
+							assert.Error(t, err)
+						} else {
+							assert.NoError(t, err)
+						}
+						client.AssertNumberOfCalls(t, "Query", testData.expectedQueries)
+
+						expectedMetrics := ""
+						if testData.expectedChecks > 0 {
+							expectedMetrics = fmt.Sprintf(`
+								# HELP mimir_continuous_test_additional_checks_total Total number of additional (opt-in) checks run.
+								# TYPE mimir_continuous_test_additional_checks_total counter
+								mimir_continuous_test_additional_checks_total{check="counter_rate",test="write-read-series"} %d
+
+								# HELP mimir_continuous_test_additional_checks_failed_total Total number of additional (opt-in) checks failed.
+								# TYPE mimir_continuous_test_additional_checks_failed_total counter
+								mimir_continuous_test_additional_checks_failed_total{check="counter_rate",test="write-read-series"} %d
+							`, testData.expectedChecks, testData.expectedFailed)
+						}
+
+						assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expectedMetrics),
+							"mimir_continuous_test_additional_checks_total",
+							"mimir_continuous_test_additional_checks_failed_total"))
+					})
+				}
+			},
+		},
+		"rate aggregation": {
+			setup: func(cfg *WriteReadSeriesTestConfig) {
+				cfg.NumSeries = 2
+				cfg.RateAggregationCheckEnabled = true
+			},
+			run: func(t *testing.T, cfg WriteReadSeriesTestConfig) {
+				now := time.Unix(10*86400, 0)
+
+				tests := map[string]struct {
+					queryMinTime       time.Time
+					sumOfRatesResult   model.Vector
+					rateOfSumResult    model.Vector
+					expectedQueries    int
+					expectedChecks     int
+					expectedDivergence int
+				}{
+					"should skip the check if samples have not been written over the whole range": {
+						queryMinTime:    now.Add(-4 * time.Minute),
+						expectedQueries: 0,
+					},
+					"should pass if results match": {
+						queryMinTime:     now.Add(-time.Hour),
+						sumOfRatesResult: model.Vector{{Timestamp: model.Time(now.UnixMilli()), Value: 0.5}},
+						rateOfSumResult:  model.Vector{{Timestamp: model.Time(now.UnixMilli()), Value: 0.5}},
+						expectedQueries:  2,
+						expectedChecks:   1,
+					},
+					"should fail if results don't match": {
+						queryMinTime:       now.Add(-time.Hour),
+						sumOfRatesResult:   model.Vector{{Timestamp: model.Time(now.UnixMilli()), Value: 0.5}},
+						rateOfSumResult:    model.Vector{{Timestamp: model.Time(now.UnixMilli()), Value: 0.6}},
+						expectedQueries:    2,
+						expectedChecks:     1,
+						expectedDivergence: 1,
+					},
+					"should fail if a result is empty": {
+						queryMinTime:       now.Add(-time.Hour),
+						sumOfRatesResult:   model.Vector{{Timestamp: model.Time(now.UnixMilli()), Value: 0.5}},
+						rateOfSumResult:    model.Vector{},
+						expectedQueries:    2,
+						expectedChecks:     1,
+						expectedDivergence: 1,
+					},
+				}
+
+				for testName, testData := range tests {
+					t.Run(testName, func(t *testing.T) {
+						test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
+						client.On("Query", mock.Anything, "sum(rate(mimir_continuous_test_sine_wave[5m]))", now, mock.Anything).Return(testData.sumOfRatesResult, nil)
+						client.On("Query", mock.Anything, "rate(sum(mimir_continuous_test_sine_wave)[5m:20s])", now, mock.Anything).Return(testData.rateOfSumResult, nil)
+
+						test.queryMinTime = testData.queryMinTime
+						test.queryMaxTime = now
+
+						err := test.runRateAggregationCheck(context.Background())
+						if testData.expectedDivergence > 0 {
+							assert.Error(t, err)
+						} else {
+							assert.NoError(t, err)
+						}
+						client.AssertNumberOfCalls(t, "Query", testData.expectedQueries)
+
+						expectedMetrics := fmt.Sprintf(`
+							# HELP mimir_continuous_test_rate_aggregation_divergence_total Total number of times the sum of the rates diverged from the rate of the sum in the rate aggregation check.
+							# TYPE mimir_continuous_test_rate_aggregation_divergence_total counter
+							mimir_continuous_test_rate_aggregation_divergence_total{test="write-read-series"} %d
+						`, testData.expectedDivergence)
+						if testData.expectedChecks > 0 {
+							expectedMetrics += fmt.Sprintf(`
+								# HELP mimir_continuous_test_additional_checks_total Total number of additional (opt-in) checks run.
+								# TYPE mimir_continuous_test_additional_checks_total counter
+								mimir_continuous_test_additional_checks_total{check="rate_aggregation",test="write-read-series"} %d
+
+								# HELP mimir_continuous_test_additional_checks_failed_total Total number of additional (opt-in) checks failed.
+								# TYPE mimir_continuous_test_additional_checks_failed_total counter
+								mimir_continuous_test_additional_checks_failed_total{check="rate_aggregation",test="write-read-series"} %d
+							`, testData.expectedChecks, testData.expectedDivergence)
+						}
+
+						assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expectedMetrics),
+							"mimir_continuous_test_rate_aggregation_divergence_total",
+							"mimir_continuous_test_additional_checks_total",
+							"mimir_continuous_test_additional_checks_failed_total"))
+					})
+				}
+			},
+		},
+		"instant queries equivalence": {
+			setup: func(cfg *WriteReadSeriesTestConfig) {
+				cfg.NumSeries = 2
+				cfg.RegexMatcherCheckEnabled = true
+				cfg.NameMatcherCheckEnabled = true
+			},
+			run: func(t *testing.T, cfg WriteReadSeriesTestConfig) {
+				now := time.Unix(10*86400, 0)
+
+				checks := map[string]struct {
+					run              func(test *WriteReadSeriesTest, ctx context.Context) error
+					checkName        string
+					queryA           string
+					queryB           string
+					divergenceMetric string
+					divergenceHelp   string
+				}{
+					"regex matcher": {
+						run:              (*WriteReadSeriesTest).runRegexMatcherCheck,
+						checkName:        "regex_matcher",
+						queryA:           "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))",
+						queryB:           `sum(max_over_time(mimir_continuous_test_sine_wave{series_id=~"[0-9]+"}[1s]))`,
+						divergenceMetric: "mimir_continuous_test_regex_matcher_divergence_total",
+						divergenceHelp:   "Total number of times the query with a regex label matcher diverged from the query without it in the regex matcher check.",
+					},
+					"name matcher": {
+						run:              (*WriteReadSeriesTest).runNameMatcherCheck,
+						checkName:        "name_matcher",
+						queryA:           "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))",
+						queryB:           `sum(max_over_time({__name__="mimir_continuous_test_sine_wave"}[1s]))`,
+						divergenceMetric: "mimir_continuous_test_name_matcher_divergence_total",
+						divergenceHelp:   "Total number of times the query with an explicit __name__ label matcher diverged from the query with the bare metric name in the name matcher check.",
+					},
+				}
+
+				tests := map[string]struct {
+					resultA            model.Vector
+					resultB            model.Vector
+					errB               error
+					expectedChecks     int
+					expectedDivergence int
+					expectedErr        bool
+				}{
+					"should pass if results match": {
+						resultA:        model.Vector{{Timestamp: model.Time(now.UnixMilli()), Value: 1.5}},
+						resultB:        model.Vector{{Timestamp: model.Time(now.UnixMilli()), Value: 1.5}},
+						expectedChecks: 1,
+					},
+					"should fail if the second query matches only some series": {
+						resultA:            model.Vector{{Timestamp: model.Time(now.UnixMilli()), Value: 1.5}},
+						resultB:            model.Vector{{Timestamp: model.Time(now.UnixMilli()), Value: 0.75}},
+						expectedChecks:     1,
+						expectedDivergence: 1,
+						expectedErr:        true,
+					},
+					"should fail if the second query matches no series": {
+						resultA:            model.Vector{{Timestamp: model.Time(now.UnixMilli()), Value: 1.5}},
+						resultB:            model.Vector{},
+						expectedChecks:     1,
+						expectedDivergence: 1,
+						expectedErr:        true,
+					},
+					"should not run the check if a query fails": {
+						resultA:     model.Vector{{Timestamp: model.Time(now.UnixMilli()), Value: 1.5}},
+						resultB:     model.Vector{},
+						errB:        errors.New("failed"),
+						expectedErr: true,
+					},
+				}
+
+				for checkName, checkData := range checks {
+					for testName, testData := range tests {
+						t.Run(checkName+" "+testName, func(t *testing.T) {
+							test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
+							client.On("Query", mock.Anything, checkData.queryA, now, mock.Anything).Return(testData.resultA, nil)
+							client.On("Query", mock.Anything, checkData.queryB, now, mock.Anything).Return(testData.resultB, testData.errB)
+
+							test.queryMinTime = now.Add(-time.Hour)
+							test.queryMaxTime = now
+
+							err := checkData.run(test, context.Background())
+							if testData.expectedErr {
+								assert.Error(t, err)
+							} else {
+								assert.NoError(t, err)
+							}
+							client.AssertNumberOfCalls(t, "Query", 2)
+
+							expectedMetrics := fmt.Sprintf(`
+								# HELP %s %s
+								# TYPE %s counter
+								%s{test="write-read-series"} %d
+							`, checkData.divergenceMetric, checkData.divergenceHelp, checkData.divergenceMetric, checkData.divergenceMetric, testData.expectedDivergence)
+							if testData.expectedChecks > 0 {
+								expectedMetrics += fmt.Sprintf(`
+									# HELP mimir_continuous_test_additional_checks_total Total number of additional (opt-in) checks run.
+									# TYPE mimir_continuous_test_additional_checks_total counter
+									mimir_continuous_test_additional_checks_total{check="%s",test="write-read-series"} %d
+
+									# HELP mimir_continuous_test_additional_checks_failed_total Total number of additional (opt-in) checks failed.
+									# TYPE mimir_continuous_test_additional_checks_failed_total counter
+									mimir_continuous_test_additional_checks_failed_total{check="%s",test="write-read-series"} %d
+								`, checkData.checkName, testData.expectedChecks, checkData.checkName, testData.expectedDivergence)
+							}
+
+							assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expectedMetrics),
+								checkData.divergenceMetric,
+								"mimir_continuous_test_additional_checks_total",
+								"mimir_continuous_test_additional_checks_failed_total"))
+						})
+					}
+				}
+			},
+		},
+		"equivalent queries": {
+			setup: func(cfg *WriteReadSeriesTestConfig) {
+				cfg.NumSeries = 2
+				cfg.EquivalentQueriesCheckEnabled = true
+			},
+			run: func(t *testing.T, cfg WriteReadSeriesTestConfig) {
+				now := time.Unix(10*86400, 0)
+				start := now.Add(-time.Hour)
+				expectedSamples := generateSineWaveSamplesSum(start, now, cfg.NumSeries, cfg.WriteInterval)
+
+				tests := map[string]struct {
+					firstResult        model.Matrix
+					secondResult       model.Matrix
+					secondErr          error
+					expectedChecks     int
+					expectedDivergence int
+					expectedErr        bool
+				}{
+					"should pass if results match": {
+						firstResult:    model.Matrix{{Values: expectedSamples}},
+						secondResult:   model.Matrix{{Values: expectedSamples}},
+						expectedChecks: 1,
+					},
+					"should fail if results have a different number of samples": {
+						firstResult:        model.Matrix{{Values: expectedSamples}},
+						secondResult:       model.Matrix{{Values: expectedSamples[1:]}},
+						expectedChecks:     1,
+						expectedDivergence: 1,
+						expectedErr:        true,
+					},
+					"should fail if results have different values": {
+						firstResult:        model.Matrix{{Values: expectedSamples}},
+						secondResult:       model.Matrix{{Values: generateSineWaveSamplesSum(start, now, cfg.NumSeries+1, cfg.WriteInterval)}},
+						expectedChecks:     1,
+						expectedDivergence: 1,
+						expectedErr:        true,
+					},
+					"should not run the check if a query fails": {
+						firstResult:  model.Matrix{{Values: expectedSamples}},
+						secondResult: model.Matrix{},
+						secondErr:    errors.New("failed"),
+						expectedErr:  true,
+					},
+				}
+
+				for testName, testData := range tests {
+					t.Run(testName, func(t *testing.T) {
+						test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
+						client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", start, now, cfg.WriteInterval, mock.Anything).Return(testData.firstResult, nil)
+						client.On("QueryRange", mock.Anything, "sum((max_over_time(mimir_continuous_test_sine_wave[1s])))", start, now, cfg.WriteInterval, mock.Anything).Return(testData.secondResult, testData.secondErr)
+
+						test.queryMinTime = now.Add(-2 * time.Hour)
+						test.queryMaxTime = now
+
+						err := test.runEquivalentQueriesCheck(context.Background())
+						if testData.expectedErr {
+							assert.Error(t, err)
+						} else {
+							assert.NoError(t, err)
+						}
+						client.AssertNumberOfCalls(t, "QueryRange", 2)
+
+						expectedMetrics := fmt.Sprintf(`
+							# HELP mimir_continuous_test_equivalent_queries_divergence_total Total number of times two logically identical but textually different queries returned different results in the equivalent queries check.
+							# TYPE mimir_continuous_test_equivalent_queries_divergence_total counter
+							mimir_continuous_test_equivalent_queries_divergence_total{test="write-read-series"} %d
+						`, testData.expectedDivergence)
+						if testData.expectedChecks > 0 {
+							expectedMetrics += fmt.Sprintf(`
+								# HELP mimir_continuous_test_additional_checks_total Total number of additional (opt-in) checks run.
+								# TYPE mimir_continuous_test_additional_checks_total counter
+								mimir_continuous_test_additional_checks_total{check="equivalent_queries",test="write-read-series"} %d
+
+								# HELP mimir_continuous_test_additional_checks_failed_total Total number of additional (opt-in) checks failed.
+								# TYPE mimir_continuous_test_additional_checks_failed_total counter
+								mimir_continuous_test_additional_checks_failed_total{check="equivalent_queries",test="write-read-series"} %d
+							`, testData.expectedChecks, testData.expectedDivergence)
+						}
+
+						assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expectedMetrics),
+							"mimir_continuous_test_equivalent_queries_divergence_total",
+							"mimir_continuous_test_additional_checks_total",
+							"mimir_continuous_test_additional_checks_failed_total"))
+					})
+				}
+			},
+		},
+		"absent data": {
+			setup: func(cfg *WriteReadSeriesTestConfig) {
+				cfg.NumSeries = 2
+				cfg.MaxQueryAge = 24 * time.Hour
+				cfg.AbsentDataCheckEnabled = true
+			},
+			run: func(t *testing.T, cfg WriteReadSeriesTestConfig) {
+				now := time.Unix(10*86400, 0)
+				queryMinTime := now.Add(-time.Hour)
+				queryTime := queryMinTime.Add(-time.Millisecond)
+				expectedQuery := "sum(count_over_time(mimir_continuous_test_sine_wave[22h59m59s999ms]))"
+
+				tests := map[string]struct {
+					queryMinTime            time.Time
+					queryResult             model.Vector
+					queryErr                error
+					expectedQueries         int
+					expectedChecks          int
+					expectedFailures        int
+					expectedUnexpectedCount int
+					expectedErr             bool
+				}{
+					"should pass if no data is returned": {
+						queryMinTime:    queryMinTime,
+						queryResult:     model.Vector{},
+						expectedQueries: 1,
+						expectedChecks:  1,
+					},
+					"should fail if data is unexpectedly returned": {
+						queryMinTime:            queryMinTime,
+						queryResult:             model.Vector{{Timestamp: model.Time(queryTime.UnixMilli()), Value: 6}},
+						expectedQueries:         1,
+						expectedChecks:          1,
+						expectedFailures:        1,
+						expectedUnexpectedCount: 6,
+						expectedErr:             true,
+					},
+					"should not run the check if the query fails": {
+						queryMinTime:    queryMinTime,
+						queryErr:        errors.New("failed"),
+						expectedQueries: 1,
+						expectedErr:     true,
+					},
+					"should not run the check if the min query time is older than the max query age": {
+						queryMinTime: now.Add(-48 * time.Hour),
+					},
+				}
+
+				for testName, testData := range tests {
+					t.Run(testName, func(t *testing.T) {
+						test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
+						client.On("Query", mock.Anything, expectedQuery, queryTime, mock.Anything).Return(testData.queryResult, testData.queryErr)
+
+						test.queryMinTime = testData.queryMinTime
+						test.queryMaxTime = now
+
+						err := test.runAbsentDataCheck(context.Background(), now)
+						if testData.expectedErr {
+							assert.Error(t, err)
+						} else {
+							assert.NoError(t, err)
+						}
+						client.AssertNumberOfCalls(t, "Query", testData.expectedQueries)
+
+						expectedMetrics := fmt.Sprintf(`
+							# HELP mimir_continuous_test_absent_data_unexpected_samples_total Total number of samples unexpectedly returned in the time range where no data is expected to exist in the absent data check.
+							# TYPE mimir_continuous_test_absent_data_unexpected_samples_total counter
+							mimir_continuous_test_absent_data_unexpected_samples_total{test="write-read-series"} %d
+						`, testData.expectedUnexpectedCount)
+						if testData.expectedChecks > 0 {
+							expectedMetrics += fmt.Sprintf(`
+								# HELP mimir_continuous_test_additional_checks_total Total number of additional (opt-in) checks run.
+								# TYPE mimir_continuous_test_additional_checks_total counter
+								mimir_continuous_test_additional_checks_total{check="absent_data",test="write-read-series"} %d
+
+								# HELP mimir_continuous_test_additional_checks_failed_total Total number of additional (opt-in) checks failed.
+								# TYPE mimir_continuous_test_additional_checks_failed_total counter
+								mimir_continuous_test_additional_checks_failed_total{check="absent_data",test="write-read-series"} %d
+							`, testData.expectedChecks, testData.expectedFailures)
+						}
+
+						assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expectedMetrics),
+							"mimir_continuous_test_absent_data_unexpected_samples_total",
+							"mimir_continuous_test_additional_checks_total",
+							"mimir_continuous_test_additional_checks_failed_total"))
+					})
+				}
+			},
+		},
+		"remote read": {
+			setup: func(cfg *WriteReadSeriesTestConfig) {
+				cfg.NumSeries = 2
+				cfg.RemoteReadCheckEnabled = true
+			},
+			run: func(t *testing.T, cfg WriteReadSeriesTestConfig) {
+				now := time.Unix(10*86400, 0)
+				start := now.Add(-time.Hour)
+
+				// Build the raw samples of each written series, as returned by the remote read API.
+				rawSeries := func(numSeries int) model.Matrix {
+					matrix := model.Matrix{}
+					for i := 0; i < numSeries; i++ {
+						matrix = append(matrix, &model.SampleStream{Values: generateSineWaveSamplesSum(start, now, 1, cfg.WriteInterval)})
+					}
+					return matrix
+				}
+
+				tests := map[string]struct {
+					result         model.Matrix
+					err            error
+					expectedChecks int
+					expectedFailed int
+					expectedErr    bool
+				}{
+					"should pass if the sum of the raw samples matches": {
+						result:         rawSeries(2),
+						expectedChecks: 1,
+					},
+					"should fail if a series is missing": {
+						result:         rawSeries(1),
+						expectedChecks: 1,
+						expectedFailed: 1,
+						expectedErr:    true,
+					},
+					"should fail if no series is returned": {
+						result:         model.Matrix{},
+						expectedChecks: 1,
+						expectedFailed: 1,
+						expectedErr:    true,
+					},
+					"should not check the result if the remote read fails": {
+						result:      model.Matrix{},
+						err:         errors.New("failed"),
+						expectedErr: true,
+					},
+				}
+
+				for testName, testData := range tests {
+					t.Run(testName, func(t *testing.T) {
+						expectedMatchers := []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "__name__", "mimir_continuous_test_sine_wave")}
+
+						test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
+						client.On("ReadSeries", mock.Anything, expectedMatchers, start, now).Return(testData.result, testData.err)
+
+						test.queryMinTime = now.Add(-2 * time.Hour)
+						test.queryMaxTime = now
+
+						err := test.runRemoteReadCheck(context.Background())
+						if testData.expectedErr {
+							assert.Error(t, err)
+						} else {
+							assert.NoError(t, err)
+						}
+						client.AssertNumberOfCalls(t, "ReadSeries", 1)
+
+						expectedMetrics := fmt.Sprintf(`
+							# HELP mimir_continuous_test_query_result_checks_total Total number of query results checked for correctness.
+							# TYPE mimir_continuous_test_query_result_checks_total counter
+							mimir_continuous_test_query_result_checks_total{query_type="instant",read_path="query_api",storage="default",test="write-read-series"} 0
+							mimir_continuous_test_query_result_checks_total{query_type="range",read_path="query_api",storage="default",test="write-read-series"} 0
+							mimir_continuous_test_query_result_checks_total{query_type="range",read_path="remote_read",storage="default",test="write-read-series"} %d
+
+							# HELP mimir_continuous_test_query_result_checks_failed_total Total number of query results failed when checking for correctness.
+							# TYPE mimir_continuous_test_query_result_checks_failed_total counter
+							mimir_continuous_test_query_result_checks_failed_total{query_type="instant",read_path="query_api",storage="default",test="write-read-series"} 0
+							mimir_continuous_test_query_result_checks_failed_total{query_type="range",read_path="query_api",storage="default",test="write-read-series"} 0
+							mimir_continuous_test_query_result_checks_failed_total{query_type="range",read_path="remote_read",storage="default",test="write-read-series"} %d
+						`, testData.expectedChecks, testData.expectedFailed)
+						if testData.expectedChecks == 0 {
+							expectedMetrics = `
+								# HELP mimir_continuous_test_query_result_checks_total Total number of query results checked for correctness.
+								# TYPE mimir_continuous_test_query_result_checks_total counter
+								mimir_continuous_test_query_result_checks_total{query_type="instant",read_path="query_api",storage="default",test="write-read-series"} 0
+								mimir_continuous_test_query_result_checks_total{query_type="range",read_path="query_api",storage="default",test="write-read-series"} 0
+
+								# HELP mimir_continuous_test_query_result_checks_failed_total Total number of query results failed when checking for correctness.
+								# TYPE mimir_continuous_test_query_result_checks_failed_total counter
+								mimir_continuous_test_query_result_checks_failed_total{query_type="instant",read_path="query_api",storage="default",test="write-read-series"} 0
+								mimir_continuous_test_query_result_checks_failed_total{query_type="range",read_path="query_api",storage="default",test="write-read-series"} 0
+							`
+						}
+
+						assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expectedMetrics),
+							"mimir_continuous_test_query_result_checks_total",
+							"mimir_continuous_test_query_result_checks_failed_total"))
+					})
+				}
+			},
+		},
+		"time modifiers": {
+			setup: func(cfg *WriteReadSeriesTestConfig) {
+				cfg.NumSeries = 2
+				cfg.TimeModifiersCheckOffset = 30 * time.Minute
+			},
+			run: func(t *testing.T, cfg WriteReadSeriesTestConfig) {
+				logger := log.NewNopLogger()
+				t.Run("should fail on invalid config", func(t *testing.T) {
+					invalidOffsetCfg := cfg
+					invalidOffsetCfg.TimeModifiersCheckOffset = defaultWriteInterval + time.Second
+					_, err := NewWriteReadSeriesTest(invalidOffsetCfg, &ClientMock{}, logger, nil)
+					require.Error(t, err)
+
+					invalidModifierCfg := cfg
+					invalidModifierCfg.TimeModifiers = []string{"unknown"}
+					_, err = NewWriteReadSeriesTest(invalidModifierCfg, &ClientMock{}, logger, nil)
+					require.Error(t, err)
+				})
+
+				now := time.Unix(10*86400, 0)
+				start := now.Add(-time.Hour)
+				at := now.Add(-cfg.TimeModifiersCheckOffset)
+
+				const (
+					offsetQuery = "sum(max_over_time(mimir_continuous_test_sine_wave[1s] offset 30m))"
+					atQuery     = "sum(max_over_time(mimir_continuous_test_sine_wave[1s] @ 862200))"
+				)
+
+				// The offset query returns, at each point, the values written at the shifted time, while the @ query
+				// returns the values written at the pinned time at all points.
+				offsetSamples := func(valueOffset time.Duration) []model.SamplePair {
+					var samples []model.SamplePair
+					for ts := start; !ts.After(now); ts = ts.Add(defaultWriteInterval) {
+						samples = append(samples, newSamplePair(ts, float64(cfg.NumSeries)*generateSineWaveValue(ts.Add(-valueOffset))))
+					}
+					return samples
+				}
+				atSamples := func() []model.SamplePair {
+					var samples []model.SamplePair
+					for ts := start; !ts.After(now); ts = ts.Add(defaultWriteInterval) {
+						samples = append(samples, newSamplePair(ts, float64(cfg.NumSeries)*generateSineWaveValue(at)))
+					}
+					return samples
+				}
+
+				tests := map[string]struct {
+					modifiers      []string
+					offsetSamples  []model.SamplePair
+					expectedChecks int
+					expectedFailed int
+				}{
+					"should pass if the results match the values written at the shifted time": {
+						modifiers:      []string{timeModifierOffset, timeModifierAt},
+						offsetSamples:  offsetSamples(cfg.TimeModifiersCheckOffset),
+						expectedChecks: 4,
+					},
+					"should fail if the offset is not applied": {
+						modifiers:      []string{timeModifierOffset, timeModifierAt},
+						offsetSamples:  offsetSamples(0),
+						expectedChecks: 1,
+						expectedFailed: 1,
+					},
+					"should run only the configured modifiers": {
+						modifiers:      []string{timeModifierAt},
+						offsetSamples:  offsetSamples(0),
+						expectedChecks: 2,
+					},
+				}
+
+				for testName, testData := range tests {
+					t.Run(testName, func(t *testing.T) {
+						testCfg := cfg
+						testCfg.TimeModifiers = testData.modifiers
+
+						offsetResult := testData.offsetSamples
+						test, client, reg := newMockedWriteReadSeriesTest(t, testCfg)
+						client.On("QueryRange", mock.Anything, offsetQuery, start, now, defaultWriteInterval, mock.Anything).Return(model.Matrix{{Values: offsetResult}}, nil)
+						client.On("QueryRange", mock.Anything, atQuery, start, now, defaultWriteInterval, mock.Anything).Return(model.Matrix{{Values: atSamples()}}, nil)
+						client.On("Query", mock.Anything, offsetQuery, now, mock.Anything).Return(model.Vector{{Timestamp: model.Time(now.UnixMilli()), Value: offsetResult[len(offsetResult)-1].Value}}, nil)
+						client.On("Query", mock.Anything, atQuery, now, mock.Anything).Return(model.Vector{{Timestamp: model.Time(now.UnixMilli()), Value: model.SampleValue(float64(cfg.NumSeries) * generateSineWaveValue(at))}}, nil)
+
+						test.queryMinTime = now.Add(-2 * time.Hour)
+						test.queryMaxTime = now
+
+						err := test.runTimeModifiersCheck(context.Background())
+						if testData.expectedFailed > 0 {
+							assert.Error(t, err)
+						} else {
+							assert.NoError(t, err)
+						}
+
+						assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(fmt.Sprintf(`
+							# HELP mimir_continuous_test_additional_checks_total Total number of additional (opt-in) checks run.
+							# TYPE mimir_continuous_test_additional_checks_total counter
+							mimir_continuous_test_additional_checks_total{check="time_modifiers",test="write-read-series"} %d
+
+							# HELP mimir_continuous_test_additional_checks_failed_total Total number of additional (opt-in) checks failed.
+							# TYPE mimir_continuous_test_additional_checks_failed_total counter
+							mimir_continuous_test_additional_checks_failed_total{check="time_modifiers",test="write-read-series"} %d
+						`, testData.expectedChecks, testData.expectedFailed)), "mimir_continuous_test_additional_checks_total", "mimir_continuous_test_additional_checks_failed_total"))
+					})
+				}
+
+				t.Run("should skip the check if no samples have been written at the shifted time", func(t *testing.T) {
+					test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+
+					test.queryMinTime = now.Add(-10 * time.Minute)
+					test.queryMaxTime = now
+
+					require.NoError(t, test.runTimeModifiersCheck(context.Background()))
+					client.AssertNumberOfCalls(t, "QueryRange", 0)
+				})
+			},
+		},
+		"relative time": {
+			setup: func(cfg *WriteReadSeriesTestConfig) {
+				cfg.NumSeries = 2
+				cfg.RelativeTimeCheckOffset = 5 * time.Minute
+			},
+			run: func(t *testing.T, cfg WriteReadSeriesTestConfig) {
+				logger := log.NewNopLogger()
+				t.Run("should fail on invalid config", func(t *testing.T) {
+					invalidCfg := cfg
+					invalidCfg.RelativeTimeCheckOffset = -time.Minute
+					_, err := NewWriteReadSeriesTest(invalidCfg, &ClientMock{}, logger, nil)
+					require.Error(t, err)
+				})
+
+				now := time.Unix(10*86400, 0)
+
+				// The server evaluates the query at its own current time, which is not aligned to the write interval.
+				evalTime := now.Add(-cfg.RelativeTimeCheckOffset).Add(-7 * time.Second)
+				writtenAt := alignTimestampToInterval(evalTime, defaultWriteInterval)
+				expectedSum := float64(cfg.NumSeries) * generateSineWaveValue(writtenAt)
+
+				tests := map[string]struct {
+					result               model.Vector
+					err                  error
+					expectedErr          bool
+					expectedChecks       int
+					expectedFailed       int
+					expectedQueryFailure bool
+					expectedUnsupported  bool
+				}{
+					"should pass if the result matches the values written at the evaluation time": {
+						result:         model.Vector{{Timestamp: model.Time(evalTime.UnixMilli()), Value: model.SampleValue(expectedSum)}},
+						expectedChecks: 1,
+					},
+					"should fail if the result doesn't match the values written at the evaluation time": {
+						result:         model.Vector{{Timestamp: model.Time(evalTime.UnixMilli()), Value: model.SampleValue(expectedSum + 1)}},
+						expectedErr:    true,
+						expectedChecks: 1,
+						expectedFailed: 1,
+					},
+					"should fail if no series is returned": {
+						result:         model.Vector{},
+						expectedErr:    true,
+						expectedChecks: 1,
+						expectedFailed: 1,
+					},
+					"should skip the check if the evaluation time is outside the written time range": {
+						result: model.Vector{{Timestamp: model.Time(now.Add(time.Hour).UnixMilli()), Value: 0}},
+					},
+					"should skip the check if the server doesn't support relative times": {
+						result:              model.Vector{},
+						err:                 &v1.Error{Type: v1.ErrBadData, Msg: "invalid parameter \"time\""},
+						expectedUnsupported: true,
+					},
+					"should fail if the query fails with any other error": {
+						result:               model.Vector{},
+						err:                  &v1.Error{Type: v1.ErrServer, Msg: "server error: 500"},
+						expectedErr:          true,
+						expectedQueryFailure: true,
+					},
+				}
+
+				for testName, testData := range tests {
+					t.Run(testName, func(t *testing.T) {
+						test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
+						client.On("QueryRelativeTime", mock.Anything, "sum(mimir_continuous_test_sine_wave)", "now-5m", mock.Anything).Return(testData.result, testData.err)
+
+						test.queryMinTime = now.Add(-time.Hour)
+						test.queryMaxTime = now
+
+						err := test.runRelativeTimeCheck(context.Background())
+						if testData.expectedErr {
+							assert.Error(t, err)
+						} else {
+							assert.NoError(t, err)
+						}
+						assert.Equal(t, testData.expectedUnsupported, test.relativeTimeUnsupported)
+
+						expectedMetrics := ""
+						if testData.expectedChecks > 0 {
+							expectedMetrics = fmt.Sprintf(`
+								# HELP mimir_continuous_test_additional_checks_total Total number of additional (opt-in) checks run.
+								# TYPE mimir_continuous_test_additional_checks_total counter
+								mimir_continuous_test_additional_checks_total{check="relative_time",test="write-read-series"} %d
+
+								# HELP mimir_continuous_test_additional_checks_failed_total Total number of additional (opt-in) checks failed.
+								# TYPE mimir_continuous_test_additional_checks_failed_total counter
+								mimir_continuous_test_additional_checks_failed_total{check="relative_time",test="write-read-series"} %d
+							`, testData.expectedChecks, testData.expectedFailed)
+						}
+						assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expectedMetrics), "mimir_continuous_test_additional_checks_total", "mimir_continuous_test_additional_checks_failed_total"))
+
+						expectedQueryFailures := 0
+						if testData.expectedQueryFailure {
+							expectedQueryFailures = 1
+						}
+						assert.Equal(t, expectedQueryFailures, testutil.CollectAndCount(test.metrics.queriesFailedTotal))
+					})
+				}
+
+				t.Run("should not run the check again once the server has rejected relative times", func(t *testing.T) {
+					test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+					client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+					client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{}, nil)
+					client.On("Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Vector{}, nil)
+					client.On("QueryRelativeTime", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Vector{}, &v1.Error{Type: v1.ErrBadData, Msg: "bad time"})
+
+					_ = test.Run(context.Background(), now)
+					_ = test.Run(context.Background(), now.Add(defaultWriteInterval))
+					client.AssertNumberOfCalls(t, "QueryRelativeTime", 1)
+				})
+			},
+		},
+		"query split": {
+			setup: func(cfg *WriteReadSeriesTestConfig) {
+				cfg.NumSeries = 2
+				cfg.QueryFrontendSplitInterval = time.Hour
+			},
+			run: func(t *testing.T, cfg WriteReadSeriesTestConfig) {
+				now := time.Unix(10*86400, 0)
+				boundary := now.Add(-time.Hour)
+				start := boundary.Add(-10 * defaultWriteInterval)
+				end := boundary.Add(10 * defaultWriteInterval)
+				expected := generateSineWaveSamplesSum(start, end, cfg.NumSeries, defaultWriteInterval)
+
+				// dropBoundary removes the point at the split boundary from the input points.
+				dropBoundary := func(points []model.SamplePair) []model.SamplePair {
+					var result []model.SamplePair
+					for _, p := range points {
+						if p.Timestamp != model.Time(boundary.UnixMilli()) {
+							result = append(result, p)
+						}
+					}
+					return result
+				}
+
+				tests := map[string]struct {
+					rangeQueryPoints   []model.SamplePair
+					expectedFailed     int
+					expectedMismatches int
+				}{
+					"should pass if the range query matches the instant queries": {
+						rangeQueryPoints: expected,
+					},
+					"should fail if the range query lost the point at the split boundary": {
+						rangeQueryPoints:   dropBoundary(expected),
+						expectedFailed:     1,
+						expectedMismatches: 1,
+					},
+				}
+
+				for testName, testData := range tests {
+					t.Run(testName, func(t *testing.T) {
+						test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
+						client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", start, end, defaultWriteInterval, mock.Anything).Return(model.Matrix{{Values: testData.rangeQueryPoints}}, nil)
+						for _, point := range expected {
+							client.On("Query", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", point.Timestamp.Time(), mock.Anything).Return(model.Vector{{Timestamp: point.Timestamp, Value: point.Value}}, nil)
+						}
+
+						test.queryMinTime = now.Add(-2 * time.Hour)
+						test.queryMaxTime = now
+
+						err := test.runQuerySplitCheck(context.Background())
+						if testData.expectedFailed > 0 {
+							assert.Error(t, err)
+						} else {
+							assert.NoError(t, err)
+						}
+						client.AssertNumberOfCalls(t, "QueryRange", 1)
+						client.AssertNumberOfCalls(t, "Query", len(expected))
+
+						assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(fmt.Sprintf(`
+							# HELP mimir_continuous_test_additional_checks_failed_total Total number of additional (opt-in) checks failed.
+							# TYPE mimir_continuous_test_additional_checks_failed_total counter
+							mimir_continuous_test_additional_checks_failed_total{check="query_split",test="write-read-series"} %d
+
+							# HELP mimir_continuous_test_query_split_mismatch_total Total number of points of the range queries straddling a query-frontend split boundary which differ from the unsplit baseline of instant queries.
+							# TYPE mimir_continuous_test_query_split_mismatch_total counter
+							mimir_continuous_test_query_split_mismatch_total{test="write-read-series"} %d
+						`, testData.expectedFailed, testData.expectedMismatches)), "mimir_continuous_test_additional_checks_failed_total", "mimir_continuous_test_query_split_mismatch_total"))
+					})
+				}
+
+				t.Run("should skip the check if no samples have been written before the split boundary", func(t *testing.T) {
+					test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+
+					test.queryMinTime = now.Add(-30 * time.Minute)
+					test.queryMaxTime = now
+
+					require.NoError(t, test.runQuerySplitCheck(context.Background()))
+					client.AssertNumberOfCalls(t, "QueryRange", 0)
+				})
+			},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			cfg := WriteReadSeriesTestConfig{}
+			flagext.DefaultValues(&cfg)
+			testData.setup(&cfg)
+			testData.run(t, cfg)
+		})
+	}
+}
+
+func TestWriteReadSeriesTest_ValidateSchema(t *testing.T) {
+	cfg := WriteReadSeriesTestConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.ValidateSchemaOnStart = true
+
+	now := time.UnixMilli(1000123)
+
+	t.Run("should write and query back the probe sample", func(t *testing.T) {
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("WriteSeries", mock.Anything, generateSineWaveSeries(schemaProbeMetricName, now, 1)).Return(200, nil)
+		client.On("Query", mock.Anything, schemaProbeMetricName, now, mock.Anything).Return(model.Vector{
+			{Timestamp: model.Time(now.UnixMilli()), Value: model.SampleValue(generateSineWaveValue(now))},
+		}, nil)
+
+		require.NoError(t, test.ValidateSchema(context.Background(), now))
+
+		client.AssertNumberOfCalls(t, "WriteSeries", 1)
+		client.AssertNumberOfCalls(t, "Query", 1)
+	})
+
+	t.Run("should do nothing if schema validation is disabled", func(t *testing.T) {
+		testCfg := cfg
+		testCfg.ValidateSchemaOnStart = false
+
+		test, client, _ := newMockedWriteReadSeriesTest(t, testCfg)
+
+		require.NoError(t, test.ValidateSchema(context.Background(), now))
+
+		client.AssertNumberOfCalls(t, "WriteSeries", 0)
+		client.AssertNumberOfCalls(t, "Query", 0)
+	})
+
+	t.Run("should fail if the probe sample is rejected", func(t *testing.T) {
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(400, errors.New("400 error"))
+
+		err := test.ValidateSchema(context.Background(), now)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to write the probe sample")
+
+		client.AssertNumberOfCalls(t, "Query", 0)
+	})
+
+	t.Run("should fail if the probe sample is not returned by the query", func(t *testing.T) {
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+		client.On("Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Vector{}, nil)
+
+		err := test.ValidateSchema(context.Background(), now)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "expected 1 series when querying the probe sample but got 0")
+	})
+
+	t.Run("should fail if the probe sample has an unexpected value", func(t *testing.T) {
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+		client.On("Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Vector{
+			{Timestamp: model.Time(now.UnixMilli()), Value: 12345},
+		}, nil)
+
+		err := test.ValidateSchema(context.Background(), now)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "the probe sample has value")
+	})
+}
+
+func TestWriteReadSeriesTest_ReferenceCompare(t *testing.T) {
+	cfg := WriteReadSeriesTestConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.NumSeries = 2
+	cfg.ReferenceCompareEnabled = true
+
+	now := time.Unix(10*86400, 0)
+	queryMinTime := now.Add(-2 * time.Minute)
+	expected := model.Matrix{{Values: generateSineWaveSamplesSum(queryMinTime, now, cfg.NumSeries, defaultWriteInterval)}}
+	diverging := model.Matrix{{Values: generateSineWaveSamplesSum(queryMinTime, now.Add(-defaultWriteInterval), cfg.NumSeries, defaultWriteInterval)}}
+	expectedVector := model.Vector{{Timestamp: model.Time(now.UnixMilli()), Value: model.SampleValue(2 * generateSineWaveValue(now))}}
+
+	t.Run("should write the series also to the reference", func(t *testing.T) {
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+		reference := &ClientMock{}
+		reference.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil).Once()
+		reference.On("WriteSeries", mock.Anything, mock.Anything).Return(500, nil).Once()
+
+		test.SetReferenceClient(reference)
+
+		require.NoError(t, test.writeSamples(context.Background(), []time.Time{now}))
+		reference.AssertCalled(t, "WriteSeries", mock.Anything, generateSineWaveSeries(metricName, now, 2))
+		assert.Equal(t, now, test.referenceMinTime)
+		assert.Equal(t, now, test.referenceMaxTime)
+
+		// A failed write to the reference doesn't fail the run, but the previously written range isn't compared anymore.
+		require.NoError(t, test.writeSamples(context.Background(), []time.Time{now.Add(defaultWriteInterval)}))
+		assert.True(t, test.referenceMinTime.IsZero())
+		assert.True(t, test.referenceMaxTime.IsZero())
+	})
+
+	tests := map[string]struct {
+		referenceMatrix   model.Matrix
+		referenceVector   model.Vector
+		referenceMinTime  time.Time
+		expectedErr       bool
+		expectedChecks    int
+		expectedMismatch  int
+		expectedReference bool
+	}{
+		"should pass if the results match the reference": {
+			referenceMatrix:   expected,
+			referenceVector:   expectedVector,
+			referenceMinTime:  queryMinTime,
+			expectedChecks:    2,
+			expectedReference: true,
+		},
+		"should fail if the results differ from the reference": {
+			referenceMatrix:   diverging,
+			referenceVector:   model.Vector{},
+			referenceMinTime:  queryMinTime,
+			expectedErr:       true,
+			expectedChecks:    2,
+			expectedMismatch:  2,
+			expectedReference: true,
+		},
+		"should skip the comparison if the series haven't been continuously written to the reference": {
+			expectedChecks: 0,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
+			client.On("QueryRange", mock.Anything, mock.Anything, queryMinTime, now, defaultWriteInterval, mock.Anything).Return(expected, nil)
+			client.On("Query", mock.Anything, mock.Anything, now, mock.Anything).Return(expectedVector, nil)
+			reference := &ClientMock{}
+			reference.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", queryMinTime, now, defaultWriteInterval, mock.Anything).Return(testData.referenceMatrix, nil)
+			reference.On("Query", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now, mock.Anything).Return(testData.referenceVector, nil)
+
+			test.SetReferenceClient(reference)
+			test.lastWrittenTimestamp = now
+			test.queryMinTime = queryMinTime
+			test.queryMaxTime = now
+			test.referenceMinTime = testData.referenceMinTime
+			test.referenceMaxTime = now
+
+			rangeErr := test.runRangeQueryAndVerifyResult(context.Background(), queryMinTime, now, false)
+			instantErr := test.runInstantQueryAndVerifyResult(context.Background(), now, false)
+			if testData.expectedErr {
+				assert.Error(t, rangeErr)
+				assert.Error(t, instantErr)
+			} else {
+				assert.NoError(t, rangeErr)
+				assert.NoError(t, instantErr)
+			}
+
+			if testData.expectedReference {
+				reference.AssertNumberOfCalls(t, "QueryRange", 1)
+				reference.AssertNumberOfCalls(t, "Query", 1)
+			} else {
+				reference.AssertNotCalled(t, "QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+				reference.AssertNotCalled(t, "Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			}
+
+			assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(fmt.Sprintf(`
+				# HELP mimir_continuous_test_reference_mismatch_total Total number of query results which differ from the result of the same query run against the reference backend.
+				# TYPE mimir_continuous_test_reference_mismatch_total counter
+				mimir_continuous_test_reference_mismatch_total{test="write-read-series"} %d
+			`, testData.expectedMismatch)), "mimir_continuous_test_reference_mismatch_total"))
+			assert.Equal(t, float64(testData.expectedChecks), testutil.ToFloat64(test.metrics.additionalChecksTotal.WithLabelValues("reference_compare")))
+		})
+	}
+}
+
+func TestWriteReadSeriesTest_Run_CustomChecks(t *testing.T) {
+	cfg := WriteReadSeriesTestConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.NumSeries = 2
+	cfg.CustomChecks = []CustomCheck{{
+		Name:          "passing",
+		Query:         "vector(time())",
+		ExpectedValue: func(now time.Time) float64 { return float64(now.Unix()) },
+	}, {
+		Name:          "failing",
+		Query:         "vector(1)",
+		ExpectedValue: func(time.Time) float64 { return 2 },
+	}}
+
+	now := time.Unix(10*86400, 0)
+
+	test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
+	client.On("Query", mock.Anything, "vector(time())", now, mock.Anything).Return(model.Vector{{Timestamp: model.Time(now.UnixMilli()), Value: model.SampleValue(now.Unix())}}, nil)
+	client.On("Query", mock.Anything, "vector(1)", now, mock.Anything).Return(model.Vector{{Timestamp: model.Time(now.UnixMilli()), Value: 1}}, nil)
+	client.On("Query", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", mock.Anything, mock.Anything).Return(model.Vector{}, nil)
+	client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{}, nil)
+
+	test.lastWrittenTimestamp = now
+	test.queryMinTime = now.Add(-10 * time.Minute)
+	test.queryMaxTime = now
+
+	err := test.Run(context.Background(), now)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "custom check failing failed")
+	assert.NotContains(t, err.Error(), "custom check passing failed")
+
+	client.AssertCalled(t, "Query", mock.Anything, "vector(time())", now, mock.Anything)
+	client.AssertCalled(t, "Query", mock.Anything, "vector(1)", now, mock.Anything)
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP mimir_continuous_test_additional_checks_total Total number of additional (opt-in) checks run.
+		# TYPE mimir_continuous_test_additional_checks_total counter
+		mimir_continuous_test_additional_checks_total{check="passing",test="write-read-series"} 1
+		mimir_continuous_test_additional_checks_total{check="failing",test="write-read-series"} 1
+
+		# HELP mimir_continuous_test_additional_checks_failed_total Total number of additional (opt-in) checks failed.
+		# TYPE mimir_continuous_test_additional_checks_failed_total counter
+		mimir_continuous_test_additional_checks_failed_total{check="passing",test="write-read-series"} 0
+		mimir_continuous_test_additional_checks_failed_total{check="failing",test="write-read-series"} 1
+	`), "mimir_continuous_test_additional_checks_total", "mimir_continuous_test_additional_checks_failed_total"))
+}
+
+func TestNewWriteReadSeriesTest_CustomChecksValidation(t *testing.T) {
+	expectedValue := func(time.Time) float64 { return 1 }
+
+	tests := map[string]struct {
+		checks      []CustomCheck
+		expectedErr string
+	}{
+		"valid checks": {
+			checks: []CustomCheck{{Name: "a", Query: "vector(1)", ExpectedValue: expectedValue}, {Name: "b", Query: "vector(1)", ExpectedValue: expectedValue}},
+		},
+		"missing name": {
+			checks:      []CustomCheck{{Query: "vector(1)", ExpectedValue: expectedValue}},
+			expectedErr: "the custom check name has not been set",
+		},
+		"duplicate name": {
+			checks:      []CustomCheck{{Name: "a", Query: "vector(1)", ExpectedValue: expectedValue}, {Name: "a", Query: "vector(2)", ExpectedValue: expectedValue}},
+			expectedErr: `the custom check name "a" is not unique`,
+		},
+		"missing expected value function": {
+			checks:      []CustomCheck{{Name: "a", Query: "vector(1)"}},
+			expectedErr: `the custom check "a" has no expected value function`,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			cfg := WriteReadSeriesTestConfig{}
+			flagext.DefaultValues(&cfg)
+			cfg.CustomChecks = testData.checks
+
+			_, err := NewWriteReadSeriesTest(cfg, &ClientMock{}, log.NewNopLogger(), nil)
+			if testData.expectedErr != "" {
+				require.EqualError(t, err, testData.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestWriteReadSeriesTestConfig_Validate(t *testing.T) {
+	tests := map[string]struct {
+		setup       func(cfg *WriteReadSeriesTestConfig)
+		expectedErr string
+	}{
+		"default config": {
+			setup: func(*WriteReadSeriesTestConfig) {},
+		},
+		"write interval is 0": {
+			setup:       func(cfg *WriteReadSeriesTestConfig) { cfg.WriteInterval = 0 },
+			expectedErr: "the write interval must be greater than 0",
+		},
+		"number of series is 0": {
+			setup:       func(cfg *WriteReadSeriesTestConfig) { cfg.NumSeries = 0 },
+			expectedErr: "the number of series must be greater than 0 but got 0",
+		},
+		"max query age is less than the write interval": {
+			setup:       func(cfg *WriteReadSeriesTestConfig) { cfg.MaxQueryAge = 10 * time.Second },
+			expectedErr: "the max query age must be at least the write interval (20s) but got 10s",
+		},
+		"max query age is equal to the write interval": {
+			setup: func(cfg *WriteReadSeriesTestConfig) { cfg.MaxQueryAge = cfg.WriteInterval },
+		},
+		"query step is not a multiple of the write interval": {
+			setup:       func(cfg *WriteReadSeriesTestConfig) { cfg.QueryStep = 30 * time.Second },
+			expectedErr: "the query step must be a multiple of the write interval (20s) but got 30s",
+		},
+		"time modifiers check offset is not a multiple of the write interval": {
+			setup:       func(cfg *WriteReadSeriesTestConfig) { cfg.TimeModifiersCheckOffset = 30 * time.Second },
+			expectedErr: "the time modifiers check offset must be a multiple of the write interval (20s) but got 30s",
+		},
+		"cardinality is above max cardinality": {
+			setup: func(cfg *WriteReadSeriesTestConfig) {
+				cfg.NumSeries = 100
+				cfg.MaxCardinality = 99
+			},
+			expectedErr: "the test would write 100 series, which exceeds the configured max cardinality 99",
+		},
+		"probe series push the cardinality above max cardinality": {
+			setup: func(cfg *WriteReadSeriesTestConfig) {
+				cfg.NumSeries = 100
+				cfg.MaxCardinality = 104
+				cfg.GapCheckEnabled = true
+				cfg.WithStaleness = true
+				cfg.WithNaN = true
+			},
+			expectedErr: "the test would write 105 series, which exceeds the configured max cardinality 104",
+		},
+		"value rounding is negative": {
+			setup:       func(cfg *WriteReadSeriesTestConfig) { cfg.ValueRounding = -1 },
+			expectedErr: "the value rounding must be between 0 and 17 significant digits but got -1",
+		},
+		"value rounding is greater than the max digits": {
+			setup:       func(cfg *WriteReadSeriesTestConfig) { cfg.ValueRounding = 18 },
+			expectedErr: "the value rounding must be between 0 and 17 significant digits but got 18",
+		},
+		"value rounding is set with the counter wave shape": {
+			setup: func(cfg *WriteReadSeriesTestConfig) {
+				cfg.ValueRounding = 6
+				cfg.WaveShape = waveShapeCounter
+			},
+			expectedErr: `the value rounding is not supported with the "counter" wave shape`,
+		},
+		"square wave period is not a multiple of twice the write interval": {
+			setup: func(cfg *WriteReadSeriesTestConfig) {
+				cfg.WaveShape = waveShapeSquare
+				cfg.SquareWavePeriod = time.Minute
+			},
+			expectedErr: "the square wave period must be a positive multiple of twice the write interval (40s) but got 1m0s",
+		},
+		"square wave period is 0": {
+			setup: func(cfg *WriteReadSeriesTestConfig) {
+				cfg.WaveShape = waveShapeSquare
+				cfg.SquareWavePeriod = 0
+			},
+			expectedErr: "the square wave period must be a positive multiple of twice the write interval (40s) but got 0s",
+		},
+		"series phase offsets are enabled with the sine wave shape": {
+			setup: func(cfg *WriteReadSeriesTestConfig) { cfg.SeriesPhaseOffsetsEnabled = true },
+		},
+		"series phase offsets are enabled with the square wave shape": {
+			setup: func(cfg *WriteReadSeriesTestConfig) {
+				cfg.SeriesPhaseOffsetsEnabled = true
+				cfg.WaveShape = waveShapeSquare
+			},
+			expectedErr: `the series phase offsets are only supported with the "sine" wave shape`,
+		},
+		"series phase offsets are enabled with the exemplars": {
+			setup: func(cfg *WriteReadSeriesTestConfig) {
+				cfg.SeriesPhaseOffsetsEnabled = true
+				cfg.WithExemplars = true
+			},
+			expectedErr: "the series phase offsets can't be enabled together with the exemplars, the min/max over time check or the rate aggregation check",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			cfg := WriteReadSeriesTestConfig{}
+			flagext.DefaultValues(&cfg)
+			testData.setup(&cfg)
+
+			err := cfg.Validate()
+			if testData.expectedErr != "" {
+				require.EqualError(t, err, testData.expectedErr)
+
+				// The test refuses to start with an invalid config.
+				_, err = NewWriteReadSeriesTest(cfg, &ClientMock{}, log.NewNopLogger(), nil)
+				require.EqualError(t, err, testData.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestWriteReadSeriesTest_CounterWaveShape(t *testing.T) {
+	cfg := WriteReadSeriesTestConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.NumSeries = 2
+	cfg.MaxQueryAge = 3 * 24 * time.Hour
+	cfg.WaveShape = waveShapeCounter
+
+	now := time.Unix(10*86400, 0)
+
+	t.Run("should recover previously written counter samples on init", func(t *testing.T) {
+		samples := make([]model.SamplePair, 0)
+		for ts := now.Add(-2 * time.Hour); !ts.After(now.Add(-time.Minute)); ts = ts.Add(defaultWriteInterval) {
+			samples = append(samples, newSamplePair(ts, float64(cfg.NumSeries)*generateCounterValue(ts)))
+		}
+
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("QueryRange", mock.Anything, "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))", now.Add(-24*time.Hour).Add(defaultWriteInterval), now, defaultWriteInterval, mock.Anything).Return(model.Matrix{{Values: samples}}, nil)
+
+		require.NoError(t, test.Init(context.Background(), now))
+
+		require.Equal(t, now.Add(-1*time.Minute), test.lastWrittenTimestamp)
+		require.Equal(t, now.Add(-2*time.Hour), test.queryMinTime)
+		require.Equal(t, now.Add(-1*time.Minute), test.queryMaxTime)
+	})
+
+	t.Run("should write counter series", func(t *testing.T) {
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+		client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{}, nil)
+		client.On("Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Vector{}, nil)
+
+		// Ignore this error. It will be non-nil because the query mock does not return any data.
+		_ = test.Run(context.Background(), now)
+
+		client.AssertCalled(t, "WriteSeries", mock.Anything, generateCounterSeries(metricName, now, 2))
+	})
+}
+
+func TestWriteReadSeriesTest_WithExemplars(t *testing.T) {
+	cfg := WriteReadSeriesTestConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.NumSeries = 2
+	cfg.WithExemplars = true
+
+	now := time.Unix(10*86400, 0)
+	exemplar := func(seriesID string) v1.Exemplar {
+		return v1.Exemplar{
+			Labels:    model.LabelSet{exemplarTraceIDLabel: model.LabelValue(exemplarTraceID(seriesID, now))},
+			Value:     model.SampleValue(generateSineWaveValue(now)),
+			Timestamp: model.Time(now.UnixMilli()),
+		}
+	}
+
+	tests := map[string]struct {
+		exemplars        []v1.ExemplarQueryResult
+		exemplarsErr     error
+		expectedChecks   int
+		expectedFailures int
+		expectedDrifted  int
+	}{
+		"should pass if the exemplars of all written samples are returned": {
+			exemplars: []v1.ExemplarQueryResult{
+				{SeriesLabels: model.LabelSet{"series_id": "0"}, Exemplars: []v1.Exemplar{exemplar("0")}},
+				{SeriesLabels: model.LabelSet{"series_id": "1"}, Exemplars: []v1.Exemplar{exemplar("1")}},
+			},
+			expectedChecks: 1,
+		},
+		"should fail if some exemplars are missing": {
+			exemplars: []v1.ExemplarQueryResult{
+				{SeriesLabels: model.LabelSet{"series_id": "0"}, Exemplars: []v1.Exemplar{exemplar("0")}},
+			},
+			expectedChecks:   1,
+			expectedFailures: 1,
+		},
+		"should fail if the exemplars are attached to the wrong series": {
+			exemplars: []v1.ExemplarQueryResult{
+				{SeriesLabels: model.LabelSet{"series_id": "0"}, Exemplars: []v1.Exemplar{exemplar("1")}},
+				{SeriesLabels: model.LabelSet{"series_id": "1"}, Exemplars: []v1.Exemplar{exemplar("0")}},
+			},
+			expectedChecks:   1,
+			expectedFailures: 1,
+			expectedDrifted:  2,
+		},
+		"should not run the check if the exemplars query fails": {
+			exemplars:    []v1.ExemplarQueryResult{},
+			exemplarsErr: errors.New("failed"),
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
+			client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+			client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{}, nil)
+			client.On("Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Vector{}, nil)
+			client.On("QueryExemplars", mock.Anything, "mimir_continuous_test_sine_wave", now, now).Return(testData.exemplars, testData.exemplarsErr)
+
+			test.lastWrittenTimestamp = now.Add(-cfg.WriteInterval)
+			test.queryMinTime = now.Add(-10 * time.Minute)
+			test.queryMaxTime = now.Add(-cfg.WriteInterval)
+
+			// Ignore the error. It will be non-nil because the query mocks do not return any data.
+			_ = test.Run(context.Background(), now)
+
+			// Only the samples written by this run have an exemplar attached.
+			client.AssertNumberOfCalls(t, "WriteSeries", 1)
+			client.AssertCalled(t, "WriteSeries", mock.Anything, appendExemplars(generateSineWaveSeries(metricName, now, 2)))
+			client.AssertNumberOfCalls(t, "QueryExemplars", 1)
+
+			expectedMetrics := ""
+			if testData.expectedChecks > 0 {
+				expectedMetrics = fmt.Sprintf(`
+					# HELP mimir_continuous_test_additional_checks_total Total number of additional (opt-in) checks run.
+					# TYPE mimir_continuous_test_additional_checks_total counter
+					mimir_continuous_test_additional_checks_total{check="exemplars",test="write-read-series"} %d
+
+					# HELP mimir_continuous_test_additional_checks_failed_total Total number of additional (opt-in) checks failed.
+					# TYPE mimir_continuous_test_additional_checks_failed_total counter
+					mimir_continuous_test_additional_checks_failed_total{check="exemplars",test="write-read-series"} %d
+				`, testData.expectedChecks, testData.expectedFailures)
+			}
+			expectedMetrics += fmt.Sprintf(`
+				# HELP mimir_continuous_test_exemplar_checks_failed_total Total number of exemplars whose trace ID doesn't match the one assigned to the series and timestamp they're attached to.
+				# TYPE mimir_continuous_test_exemplar_checks_failed_total counter
+				mimir_continuous_test_exemplar_checks_failed_total{test="write-read-series"} %d
+			`, testData.expectedDrifted)
+
+			assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expectedMetrics),
+				"mimir_continuous_test_additional_checks_total",
+				"mimir_continuous_test_additional_checks_failed_total",
+				"mimir_continuous_test_exemplar_checks_failed_total"))
+		})
+	}
+
+	t.Run("should not check the exemplars of the samples written by previous runs", func(t *testing.T) {
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+
+		test.queryMinTime = now.Add(-10 * time.Minute)
+		test.queryMaxTime = now
+
+		require.NoError(t, test.runExemplarsCheck(context.Background()))
+		client.AssertNotCalled(t, "QueryExemplars", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("should only check the exemplars written within the max age", func(t *testing.T) {
+		test, client, _ := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("QueryExemplars", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]v1.ExemplarQueryResult{}, nil)
+
+		test.exemplarsMinTime = now.Add(-10 * time.Minute)
+		test.queryMinTime = now.Add(-10 * time.Minute)
+		test.queryMaxTime = now
+
+		// An error is expected because no exemplars are returned.
+		require.Error(t, test.runExemplarsCheck(context.Background()))
+		client.AssertCalled(t, "QueryExemplars", mock.Anything, "mimir_continuous_test_sine_wave", now.Add(-cfg.ExemplarsCheckMaxAge), now)
+	})
+}
+
+// newMockedWriteReadSeriesTest returns a WriteReadSeriesTest built from the input config, backed by a new client
+// mock and registering its metrics to a new pedantic registry.
+func newMockedWriteReadSeriesTest(t *testing.T, cfg WriteReadSeriesTestConfig) (*WriteReadSeriesTest, *ClientMock, *prometheus.Registry) {
+	client := &ClientMock{}
+	reg := prometheus.NewPedanticRegistry()
+
+	test, err := NewWriteReadSeriesTest(cfg, client, log.NewNopLogger(), reg)
+	require.NoError(t, err)
+
+	return test, client, reg
+}
+
+func labelsToString(lbls []prompb.Label) string {
+	parts := make([]string, 0, len(lbls))
+	for _, l := range lbls {
+		parts = append(parts, l.Name+"="+l.Value)
+	}
+	return strings.Join(parts, ",")
+}
+
+// loadQueryResponseFixture loads a query API response, captured from a real Mimir cluster, from the testdata
+// directory and returns its result. It supports matrix and vector results.
+func loadQueryResponseFixture(t *testing.T, filename string) model.Value {
+	data, err := os.ReadFile(filepath.Join("testdata", filename))
+	require.NoError(t, err)
+
+	var res struct {
+		Status string `json:"status"`
+		Data   struct {
+			ResultType model.ValueType `json:"resultType"`
+			Result     json.RawMessage `json:"result"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(data, &res))
+	require.Equal(t, "success", res.Status)
+
+	switch res.Data.ResultType {
+	case model.ValMatrix:
+		var matrix model.Matrix
+		require.NoError(t, json.Unmarshal(res.Data.Result, &matrix))
+		return matrix
+	case model.ValVector:
+		var vector model.Vector
+		require.NoError(t, json.Unmarshal(res.Data.Result, &vector))
+		return vector
+	default:
+		require.Failf(t, "unsupported result type", "fixture %s has result type %s", filename, res.Data.ResultType)
+		return nil
+	}
+}
+
+func TestWriteReadSeriesTest_LastCheckSuccess(t *testing.T) {
+	const sumQuery = "sum(max_over_time(mimir_continuous_test_sine_wave[1s]))"
+
+	cfg := WriteReadSeriesTestConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.NumSeries = 2
+	cfg.HistogramIdentityCheckEnabled = true
+
+	now := time.Unix(1000, 0)
+
+	test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
+
+	expectGauges := func(t *testing.T, sineWaveValue, histogramValue int) {
+		assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(fmt.Sprintf(`
+			# HELP mimir_continuous_test_last_check_success Whether the query results of the written metric have been successfully checked by the last run (1) or not (0).
+			# TYPE mimir_continuous_test_last_check_success gauge
+			mimir_continuous_test_last_check_success{metric_name="mimir_continuous_test_histogram_probe",query_type="instant",test="write-read-series"} %d
+			mimir_continuous_test_last_check_success{metric_name="mimir_continuous_test_sine_wave",query_type="instant",test="write-read-series"} %d
+			mimir_continuous_test_last_check_success{metric_name="mimir_continuous_test_sine_wave",query_type="range",test="write-read-series"} %d
+		`, histogramValue, sineWaveValue, sineWaveValue)), "mimir_continuous_test_last_check_success"))
+	}
+
+	// The first run fails checking both the written series and the histogram probe.
+	client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+	client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{}, nil)
+	client.On("Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Vector{}, nil)
+
+	require.Error(t, test.Run(context.Background(), now))
+	expectGauges(t, 0, 0)
+
+	// The next run successfully checks the written series, while the histogram probe check keeps failing.
+	client.ExpectedCalls = nil
+	client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+	client.On("QueryRange", mock.Anything, sumQuery, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{{
+		Values: []model.SamplePair{newSamplePair(now, 2*generateSineWaveValue(now))},
+	}}, nil)
+	client.On("Query", mock.Anything, sumQuery, mock.Anything, mock.Anything).Return(model.Vector{{
+		Timestamp: model.Time(now.UnixMilli()),
+		Value:     model.SampleValue(2 * generateSineWaveValue(now)),
+	}}, nil)
+	client.On("Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Vector{}, nil)
+
+	require.Error(t, test.Run(context.Background(), now))
+	expectGauges(t, 1, 0)
+}
+
+func TestWriteReadSeriesTest_Warmup(t *testing.T) {
+	logger := log.NewNopLogger()
+	cfg := WriteReadSeriesTestConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.NumSeries = 2
+	cfg.WarmupDuration = 2 * defaultWriteInterval
+
+	t.Run("should fail on negative warmup duration", func(t *testing.T) {
+		invalidCfg := cfg
+		invalidCfg.WarmupDuration = -time.Minute
+
+		_, err := NewWriteReadSeriesTest(invalidCfg, &ClientMock{}, logger, nil)
+		require.Error(t, err)
+	})
+
+	newClient := func() *ClientMock {
+		client := &ClientMock{}
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+		client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{}, nil)
+		client.On("Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Vector{}, nil)
+		return client
+	}
+
+	t.Run("should write but not check the query results until the warmup is over", func(t *testing.T) {
+		client := newClient()
+		test, err := NewWriteReadSeriesTest(cfg, client, logger, nil)
+		require.NoError(t, err)
+
+		now := time.Unix(1000, 0)
+		require.NoError(t, test.Run(context.Background(), now))
+		require.NoError(t, test.Run(context.Background(), now.Add(cfg.WarmupDuration-defaultWriteInterval)))
+		client.AssertNumberOfCalls(t, "WriteSeries", 2)
+		client.AssertNumberOfCalls(t, "QueryRange", 0)
+		client.AssertNumberOfCalls(t, "Query", 0)
+
+		// The query mocks return no data, so the checks fail once the warmup is over.
+		require.Error(t, test.Run(context.Background(), now.Add(cfg.WarmupDuration)))
+		client.AssertNumberOfCalls(t, "WriteSeries", 3)
+		assert.Greater(t, len(client.Calls), 3)
+	})
+
+	t.Run("should derive the warmup from the previously written time range recovered after a restart", func(t *testing.T) {
+		client := newClient()
+		test, err := NewWriteReadSeriesTest(cfg, client, logger, nil)
+		require.NoError(t, err)
+
+		// Simulate the time range recovered by Init.
+		now := time.Unix(1000, 0)
+		test.lastWrittenTimestamp = now.Add(-defaultWriteInterval)
+		test.queryMinTime = now.Add(-cfg.WarmupDuration)
+		test.queryMaxTime = now.Add(-defaultWriteInterval)
+
+		require.Error(t, test.Run(context.Background(), now))
+		client.AssertNumberOfCalls(t, "WriteSeries", 1)
+		assert.Greater(t, len(client.Calls), 1)
+	})
+}
+
+func TestWriteReadSeriesTest_VerifiedOldestSampleAge(t *testing.T) {
+	cfg := WriteReadSeriesTestConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.NumSeries = 2
+
+	expectedMetric := func(age float64) string {
+		return fmt.Sprintf(`
+			# HELP mimir_continuous_test_verified_oldest_sample_age_seconds Age of the oldest sample of the written metric whose query results are checked, as of the last run. 0 if no samples are checked.
+			# TYPE mimir_continuous_test_verified_oldest_sample_age_seconds gauge
+			mimir_continuous_test_verified_oldest_sample_age_seconds{metric_name="mimir_continuous_test_sine_wave",test="write-read-series"} %v
+		`, age)
+	}
+
+	t.Run("should track the age of the oldest checked sample, even if no sample has been written by the run", func(t *testing.T) {
+		test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+		client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{}, nil)
+		client.On("Query", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Vector{}, nil)
+
+		test.lastWrittenTimestamp = time.Unix(1000, 0)
+		test.queryMinTime = time.Unix(400, 0)
+		test.queryMaxTime = time.Unix(1000, 0)
+
+		_ = test.Run(context.Background(), time.Unix(1000, 0))
+		assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expectedMetric(600)), "mimir_continuous_test_verified_oldest_sample_age_seconds"))
+
+		// The next run doesn't write any sample, because the next interval isn't due yet.
+		_ = test.Run(context.Background(), time.Unix(1010, 0))
+		client.AssertNumberOfCalls(t, "WriteSeries", 0)
+		assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expectedMetric(610)), "mimir_continuous_test_verified_oldest_sample_age_seconds"))
+	})
+
+	t.Run("should track 0 if the checked time range has been reset", func(t *testing.T) {
+		test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(400, errors.New("bad request"))
+
+		test.lastWrittenTimestamp = time.Unix(980, 0)
+		test.queryMinTime = time.Unix(400, 0)
+		test.queryMaxTime = time.Unix(980, 0)
+
+		require.Error(t, test.Run(context.Background(), time.Unix(1000, 0)))
+		assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expectedMetric(0)), "mimir_continuous_test_verified_oldest_sample_age_seconds"))
+	})
+
+	t.Run("should track 0 during the warmup", func(t *testing.T) {
+		cfg := cfg
+		cfg.WarmupDuration = time.Hour
+
+		test, client, reg := newMockedWriteReadSeriesTest(t, cfg)
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+
+		require.NoError(t, test.Run(context.Background(), time.Unix(1000, 0)))
+		assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expectedMetric(0)), "mimir_continuous_test_verified_oldest_sample_age_seconds"))
+	})
+}
+
+func TestNewWriteReadSeriesTest_HistogramLayout(t *testing.T) {
+	logger := log.NewNopLogger()
+	cfg := WriteReadSeriesTestConfig{}
+	flagext.DefaultValues(&cfg)
+
+	tests := map[string]struct {
+		schema          int
+		positiveBuckets int
+		negativeBuckets int
+		expectedErr     bool
+	}{
+		"default layout": {
+			schema: 1,
+		},
+		"custom layout": {
+			schema:          8,
+			positiveBuckets: 100,
+			negativeBuckets: 10,
+		},
+		"schema lower than the min": {
+			schema:      -5,
+			expectedErr: true,
+		},
+		"schema greater than the max": {
+			schema:      9,
+			expectedErr: true,
+		},
+		"negative number of buckets": {
+			schema:          1,
+			positiveBuckets: -1,
+			expectedErr:     true,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			testCfg := cfg
+			testCfg.HistogramSchema = testData.schema
+			testCfg.HistogramPositiveBuckets = testData.positiveBuckets
+			testCfg.HistogramNegativeBuckets = testData.negativeBuckets
+
+			test, err := NewWriteReadSeriesTest(testCfg, &ClientMock{}, logger, nil)
+			if testData.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, histogramLayout{schema: int32(testData.schema), positiveBuckets: testData.positiveBuckets, negativeBuckets: testData.negativeBuckets}, test.histogramLayout)
+		})
+	}
 }
@@ -21,6 +21,7 @@ import (
 type MetricsServer struct {
 	port     int
 	registry *prometheus.Registry
+	router   *mux.Router
 	srv      *http.Server
 }
 
@@ -29,9 +30,15 @@ func NewMetricsServer(port int, registry *prometheus.Registry) *MetricsServer {
 	return &MetricsServer{
 		port:     port,
 		registry: registry,
+		router:   mux.NewRouter(),
 	}
 }
 
+// Handle registers an additional handler for the input path. It must be called before Start.
+func (s *MetricsServer) Handle(path string, handler http.Handler) {
+	s.router.Handle(path, handler)
+}
+
 // Start the instrumentation server.
 func (s *MetricsServer) Start() error {
 	// Setup listener first, so we can fail early if the port is in use.
@@ -40,11 +47,10 @@ func (s *MetricsServer) Start() error {
 		return err
 	}
 
-	router := mux.NewRouter()
-	router.Handle("/metrics", promhttp.HandlerFor(s.registry, promhttp.HandlerOpts{}))
+	s.router.Handle("/metrics", promhttp.HandlerFor(s.registry, promhttp.HandlerOpts{}))
 
 	s.srv = &http.Server{
-		Handler: router,
+		Handler: s.router,
 	}
 
 	go func() {